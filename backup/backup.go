@@ -0,0 +1,154 @@
+// Package backup keeps an untouched copy of each file the first time the
+// editor overwrites it, so LUTs edited in place inside a mod folder can be
+// restored to the game's original.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileName is the name of the backup settings file in the user config
+// folder.
+const ConfigFileName = "backup.json"
+
+// Config holds the backup settings.
+type Config struct {
+	// Dir is the folder originals are kept in, or "" for DefaultDir
+	Dir string `json:"dir,omitempty"`
+}
+
+func configDir() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "hd2-lut-editor"), nil
+}
+
+// DefaultDir is where originals are kept unless another folder is chosen.
+func DefaultDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+// LoadConfig reads the backup settings, which are empty if they were never
+// saved.
+func LoadConfig() (Config, error) {
+	var cfg Config
+	dir, err := configDir()
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ConfigFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid backup settings: %v", err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig writes the backup settings, creating their folder if needed.
+func SaveConfig(cfg Config) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ConfigFileName), data, 0o644)
+}
+
+// Folder returns the folder originals are kept in.
+func (cfg Config) Folder() (string, error) {
+	if cfg.Dir != "" {
+		return cfg.Dir, nil
+	}
+	return DefaultDir()
+}
+
+// Path returns where the original of file is kept in dir. The file's absolute
+// path is repeated under dir, with the drive letter as the first folder, so
+// files of the same name in different mods are kept apart.
+func Path(dir, file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	volume := filepath.VolumeName(abs)
+	rest := strings.TrimLeft(abs[len(volume):], `\/`)
+	volume = strings.Trim(strings.NewReplacer(":", "", `\`, "_", "/", "_").Replace(volume), "_")
+	return filepath.Join(dir, volume, rest), nil
+}
+
+// Stash copies file into dir, unless an original of it is already kept there
+// or the file doesn't exist yet. It reports whether a copy was made.
+func Stash(dir, file string) (bool, error) {
+	path, err := Path(dir, file)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	if err := copyFile(path, file); err != nil {
+		// A partial copy would be taken for the original next time
+		os.Remove(path)
+		return false, err
+	}
+	return true, nil
+}
+
+// Restore copies the original of file kept in dir back over file.
+func Restore(dir, file string) error {
+	path, err := Path(dir, file)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no original of '%s' is kept in '%s'", file, dir)
+	}
+	return copyFile(file, path)
+}
+
+// copyFile writes a copy of src to dst.
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
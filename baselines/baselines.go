@@ -0,0 +1,213 @@
+// Package baselines keeps a library of vanilla texture sets, such as the
+// textures extracted from each game version, and finds the file in them an
+// edited texture should be compared against.
+package baselines
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileName is the name of the library file in the user config folder.
+const FileName = "baselines.json"
+
+// Set is a folder of vanilla textures.
+type Set struct {
+	// Name tells sets apart, and is matched against the gameVersion attribute
+	// of edited files
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+}
+
+// Library lists the registered sets. Sets earlier in the list are preferred
+// when more than one has a matching file.
+type Library struct {
+	Sets []Set `json:"sets"`
+}
+
+// Path is where the library is kept.
+func Path() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "hd2-lut-editor", FileName), nil
+}
+
+// Load reads the library, which is empty if it was never saved.
+func Load() (Library, error) {
+	var lib Library
+	path, err := Path()
+	if err != nil {
+		return lib, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lib, nil
+	} else if err != nil {
+		return lib, err
+	}
+	if err := json.Unmarshal(data, &lib); err != nil {
+		return Library{}, fmt.Errorf("invalid baseline library: %v", err)
+	}
+	return lib, nil
+}
+
+// Save writes the library, creating its folder if needed.
+func Save(lib Library) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(lib, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Entry is a texture found in a set.
+type Entry struct {
+	Set  string
+	Path string
+}
+
+// Index holds the DDS and EXR files found in the sets of a library.
+type Index struct {
+	entries []Entry
+	// byName maps a lowercase file name without its extension to the
+	// entries with it, in the order of their sets
+	byName map[string][]Entry
+	// hashes caches the file hashes computed while matching
+	hashes   map[string]string
+	hashesMu sync.Mutex
+}
+
+// key is the name files are matched by: the lowercase base name without its
+// extension, so an edited EXR matches the vanilla DDS.
+func key(path string) string {
+	base := filepath.Base(path)
+	return strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// Scan lists the DDS and EXR files in each set of lib, including subfolders.
+// Sets that can't be read are left out, and the first error is returned along
+// with the index of the rest.
+func Scan(lib Library) (*Index, error) {
+	idx := &Index{byName: make(map[string][]Entry), hashes: make(map[string]string)}
+	var firstErr error
+	for _, set := range lib.Sets {
+		err := filepath.WalkDir(set.Folder, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if d.IsDir() || ext != ".dds" && ext != ".exr" {
+				return nil
+			}
+			entry := Entry{Set: set.Name, Path: path}
+			idx.entries = append(idx.entries, entry)
+			idx.byName[key(path)] = append(idx.byName[key(path)], entry)
+			return nil
+		})
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("set %s: %v", set.Name, err)
+		}
+	}
+	return idx, firstErr
+}
+
+// Len returns the number of files found.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// Count returns the number of files found in the set named name.
+func (idx *Index) Count(name string) int {
+	n := 0
+	for _, entry := range idx.entries {
+		if entry.Set == name {
+			n++
+		}
+	}
+	return n
+}
+
+// Candidates returns the files with the same name as fileName, ignoring
+// case and extension, in the order of their sets.
+func (idx *Index) Candidates(fileName string) []Entry {
+	return idx.byName[key(fileName)]
+}
+
+// Match finds the baseline of the edited file fileName. hash is the SHA-256
+// of the baseline recorded in the file, as FileHash gives it, and version the
+// game version it was made for; either may be "". A file with the recorded
+// hash is the surest match, searched for among files of the same name first.
+// Otherwise the file of the same name in the set named version is used, or
+// failing that in the first set that has one.
+func (idx *Index) Match(fileName, hash, version string) (Entry, bool) {
+	candidates := idx.Candidates(fileName)
+	if hash != "" {
+		for _, entries := range [][]Entry{candidates, idx.entries} {
+			for _, entry := range entries {
+				if idx.hash(entry.Path) == hash {
+					return entry, true
+				}
+			}
+		}
+	}
+	for _, entry := range candidates {
+		if version != "" && entry.Set == version {
+			return entry, true
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0], true
+	}
+	return Entry{}, false
+}
+
+// hash returns the hash of the file at path, or "" if it can't be read.
+func (idx *Index) hash(path string) string {
+	idx.hashesMu.Lock()
+	hash, ok := idx.hashes[path]
+	idx.hashesMu.Unlock()
+	if ok {
+		return hash
+	}
+	hash, err := FileHash(path)
+	if err != nil {
+		hash = ""
+	}
+	idx.hashesMu.Lock()
+	idx.hashes[path] = hash
+	idx.hashesMu.Unlock()
+	return hash
+}
+
+// FileHash returns the hex SHA-256 of the file at path, as recorded in the
+// baselineHash attribute of edited files.
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
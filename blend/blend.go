@@ -0,0 +1,91 @@
+// Package blend combines new channel values with the ones already in an
+// image, for drawing and pasting onto existing LUT data.
+package blend
+
+type Mode int
+
+const (
+	// Replace overwrites the existing value
+	Replace Mode = iota
+	Add
+	Multiply
+	Min
+	Max
+	// Average is the mean of the existing and new values
+	Average
+)
+
+var Modes = []Mode{Replace, Add, Multiply, Min, Max, Average}
+
+func (m Mode) String() string {
+	switch m {
+	case Replace:
+		return "Replace"
+	case Add:
+		return "Add"
+	case Multiply:
+		return "Multiply"
+	case Min:
+		return "Min"
+	case Max:
+		return "Max"
+	case Average:
+		return "Average"
+	}
+	return "Unknown"
+}
+
+// Channel combines the existing value dst with the new value src.
+func (m Mode) Channel(dst, src float64) float64 {
+	switch m {
+	case Add:
+		return dst + src
+	case Multiply:
+		return dst * src
+	case Min:
+		return min(dst, src)
+	case Max:
+		return max(dst, src)
+	case Average:
+		return (dst + src) / 2
+	}
+	return src
+}
+
+// Pixel combines every channel of dst and src.
+func (m Mode) Pixel(dst, src [4]float64) [4]float64 {
+	for i := range dst {
+		dst[i] = m.Channel(dst[i], src[i])
+	}
+	return dst
+}
+
+// Lock marks the channels (R, G, B, A) that edits must leave unchanged, to
+// protect data packed into them.
+type Lock [4]bool
+
+func (l Lock) Any() bool {
+	return l[0] || l[1] || l[2] || l[3]
+}
+
+// Inverse locks exactly the channels l leaves unlocked.
+func (l Lock) Inverse() Lock {
+	return Lock{!l[0], !l[1], !l[2], !l[3]}
+}
+
+// Pixel combines dst and src like Mode.Pixel, except locked channels keep
+// their dst value.
+func (l Lock) Pixel(m Mode, dst, src [4]float64) [4]float64 {
+	edited := m.Pixel(dst, src)
+	for i, locked := range l {
+		if locked {
+			edited[i] = dst[i]
+		}
+	}
+	return edited
+}
+
+// Or locks the channels locked in either l or other.
+func (l Lock) Or(other Lock) Lock {
+	return Lock{l[0] || other[0], l[1] || other[1], l[2] || other[2], l[3] || other[3]}
+}
@@ -0,0 +1,124 @@
+// Package changes lists the texels of an image that differ from a baseline
+// version of it, as CSV or Markdown for mod changelogs.
+package changes
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Change is a texel whose value differs from the baseline.
+type Change struct {
+	X, Y          int
+	Before, After [4]float64
+}
+
+// Names labels the rows and columns of a LUT. Rows or columns without a name
+// are labelled by their index.
+type Names struct {
+	Rows    []string
+	Columns []string
+}
+
+func (n Names) Row(y int) string {
+	if y < len(n.Rows) && n.Rows[y] != "" {
+		return n.Rows[y]
+	}
+	return strconv.Itoa(y)
+}
+
+func (n Names) Column(x int) string {
+	if x < len(n.Columns) && n.Columns[x] != "" {
+		return n.Columns[x]
+	}
+	return strconv.Itoa(x)
+}
+
+// Diff compares img with base texel by texel, in row order. Both must have
+// the same size.
+func Diff(base, img hdrColors.RawImage) ([]Change, error) {
+	if base.Bounds().Size() != img.Bounds().Size() {
+		return nil, fmt.Errorf("baseline is %dx%d but image is %dx%d", base.Bounds().Dx(), base.Bounds().Dy(), img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	offset := base.Bounds().Min.Sub(img.Bounds().Min)
+	var changes []Change
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			before, after := base.RawAt(x+offset.X, y+offset.Y), img.RawAt(x, y)
+			if before != after {
+				changes = append(changes, Change{X: x - bounds.Min.X, Y: y - bounds.Min.Y, Before: before, After: after})
+			}
+		}
+	}
+	return changes, nil
+}
+
+var channelNames = []string{"R", "G", "B", "A"}
+
+// changedChannels lists the channels of c that differ, e.g. "R, A".
+func changedChannels(c Change) string {
+	var names []string
+	for i := range c.Before {
+		if c.Before[i] != c.After[i] {
+			names = append(names, channelNames[i])
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func formatColor(c [4]float64) string {
+	return fmt.Sprintf("(%g, %g, %g, %g)", float32(c[0]), float32(c[1]), float32(c[2]), float32(c[3]))
+}
+
+// WriteCSV writes one line per changed texel, with a channel per column.
+func WriteCSV(w io.Writer, changes []Change, names Names) error {
+	out := csv.NewWriter(w)
+	header := []string{"row", "column", "x", "y", "channels"}
+	for _, name := range channelNames {
+		header = append(header, "before_"+strings.ToLower(name), "after_"+strings.ToLower(name))
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		record := []string{names.Row(c.Y), names.Column(c.X), strconv.Itoa(c.X), strconv.Itoa(c.Y), changedChannels(c)}
+		for i := range c.Before {
+			record = append(record,
+				strconv.FormatFloat(c.Before[i], 'g', -1, 32),
+				strconv.FormatFloat(c.After[i], 'g', -1, 32),
+			)
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteMarkdown writes a heading naming the files and a table of changes.
+func WriteMarkdown(w io.Writer, title, baseline string, changes []Change, names Names) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Changes to %s\n\n", title)
+	fmt.Fprintf(&b, "Compared with `%s`: %d texel(s) changed.\n\n", baseline, len(changes))
+	if len(changes) > 0 {
+		b.WriteString("| Row | Column | Channels | Before | After |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, c := range changes {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+				escape(names.Row(c.Y)), escape(names.Column(c.X)), changedChannels(c), formatColor(c.Before), formatColor(c.After))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -1,86 +1,37 @@
 package clipboard
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"image"
-	"runtime"
-	"syscall"
-	"unsafe"
+	"image/draw"
+	"image/png"
+	"time"
 
 	"github.com/gopxl/pixel/v2"
 	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
 )
 
-// Calling a Windows DLL, see:
-// https://go.dev/wiki/WindowsDLLs
-var (
-	user32 = syscall.MustLoadDLL("user32")
-	// Opens the clipboard for examination and prevents other
-	// applications from modifying the clipboard content.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-openclipboard
-	openClipboard = user32.MustFindProc("OpenClipboard")
-	// Closes the clipboard.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-closeclipboard
-	closeClipboard = user32.MustFindProc("CloseClipboard")
-	// Empties the clipboard and frees handles to data in the clipboard.
-	// The function then assigns ownership of the clipboard to the
-	// window that currently has the clipboard open.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-emptyclipboard
-	emptyClipboard = user32.MustFindProc("EmptyClipboard")
-	// Retrieves data from the clipboard in a specified format.
-	// The clipboard must have been opened previously.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getclipboarddata
-	getClipboardData = user32.MustFindProc("GetClipboardData")
-	// Places data on the clipboard in a specified clipboard format.
-	// The window must be the current clipboard owner, and the
-	// application must have called the OpenClipboard function. (When
-	// responding to the WM_RENDERFORMAT message, the clipboard owner
-	// must not call OpenClipboard before calling SetClipboardData.)
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-setclipboarddata
-	setClipboardData = user32.MustFindProc("SetClipboardData")
-	// Determines whether the clipboard contains data in the specified format.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-isclipboardformatavailable
-	isClipboardFormatAvailable = user32.MustFindProc("IsClipboardFormatAvailable")
-	// Clipboard data formats are stored in an ordered list. To perform
-	// an enumeration of clipboard data formats, you make a series of
-	// calls to the EnumClipboardFormats function. For each call, the
-	// format parameter specifies an available clipboard format, and the
-	// function returns the next available clipboard format.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-isclipboardformatavailable
-	enumClipboardFormats = user32.MustFindProc("EnumClipboardFormats")
-	// Retrieves the clipboard sequence number for the current window station.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getclipboardsequencenumber
-	getClipboardSequenceNumber = user32.MustFindProc("GetClipboardSequenceNumber")
-	// Registers a new clipboard format. This format can then be used as
-	// a valid clipboard format.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-registerclipboardformata
-	registerClipboardFormatA = user32.MustFindProc("RegisterClipboardFormatA")
-
-	kernel32 = syscall.NewLazyDLL("kernel32")
-
-	// Locks a global memory object and returns a pointer to the first
-	// byte of the object's memory block.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globallock
-	gLock = kernel32.NewProc("GlobalLock")
-	// Decrements the lock count associated with a memory object that was
-	// allocated with GMEM_MOVEABLE. This function has no effect on memory
-	// objects allocated with GMEM_FIXED.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globalunlock
-	gUnlock = kernel32.NewProc("GlobalUnlock")
-	// Allocates the specified number of bytes from the heap.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globalalloc
-	gAlloc = kernel32.NewProc("GlobalAlloc")
-	// Frees the specified global memory object and invalidates its handle.
-	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globalfree
-	gFree   = kernel32.NewProc("GlobalFree")
-	memMove = kernel32.NewProc("RtlMoveMemory")
-)
+// The system clipboard is reached through a backend for each platform:
+// clipboard_windows.go, clipboard_x11.go and clipboard_darwin.go. Each
+// provides
+//
+//	initFormats() error
+//	writeData(format uint32, data []byte) error
+//	writeImage(img *image.NRGBA, pngData []byte) error
+//	hasFormat(format uint32) bool
+//	readData(format uint32) ([]byte, error)
+//
+// where writeData adds data to what this process last put on the clipboard,
+// so an HDR image and its rectangle can be pasted together, and writeImage
+// replaces the clipboard contents with an ordinary image.
 
 var (
 	FormatHDR  uint32
 	FormatRect uint32
+	FormatPNG  uint32
 )
 
 const (
@@ -89,12 +40,13 @@ const (
 	imgFormatUInt32  uint32 = iota
 )
 
-const (
-	gMemMoveable = 0x0002
-)
+// openTimeout is how long to wait for another application to close the
+// clipboard, or to hand over its contents, before giving up with ErrBusy.
+const openTimeout = time.Second
 
 var (
 	ErrUnavailable = errors.New("clipboard unavailable")
+	ErrBusy        = errors.New("clipboard is in use by another application")
 )
 
 type Vector struct {
@@ -138,31 +90,10 @@ type HDRHeader struct {
 	ByteLength uint32
 }
 
+// Init registers the editor's clipboard formats. The other functions fail or
+// report nothing on the clipboard if it returned an error.
 func Init() error {
-	formatNameHDR, err := syscall.BytePtrFromString("helldivers lut editor hdr image")
-	if err != nil {
-		return fmt.Errorf("failed to convert string to byte ptr")
-	}
-	pFmtName := unsafe.Pointer(formatNameHDR)
-	r, _, err := registerClipboardFormatA.Call(uintptr(pFmtName))
-
-	if r == 0 {
-		return err
-	}
-	FormatHDR = uint32(r)
-
-	formatNameRect, err := syscall.BytePtrFromString("helldivers lut editor rectangle")
-	if err != nil {
-		return fmt.Errorf("failed to convert string to byte ptr")
-	}
-	pFmtName = unsafe.Pointer(formatNameRect)
-	r, _, err = registerClipboardFormatA.Call(uintptr(pFmtName))
-
-	if r == 0 {
-		return err
-	}
-	FormatRect = uint32(r)
-	return nil
+	return initFormats()
 }
 
 func WriteHDR(img image.Image) error {
@@ -178,18 +109,6 @@ func WriteHDR(img image.Image) error {
 		return fmt.Errorf("not an HDR image")
 	}
 
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
-	for {
-		r, _, _ := openClipboard.Call(0)
-		if r == 0 {
-			continue
-		}
-		break
-	}
-	defer closeClipboard.Call()
-
 	data := make([]byte, 0)
 	var header HDRHeader
 
@@ -213,42 +132,28 @@ func WriteHDR(img image.Image) error {
 		return fmt.Errorf("failed to append pixels to data: %w", err)
 	}
 
-	hMem, _, err := gAlloc.Call(gMemMoveable, uintptr(len(data)))
-	if hMem == 0 {
-		return fmt.Errorf("failed to alloc global memory: %w", err)
-	}
-
-	p, _, err := gLock.Call(hMem)
-	if p == 0 {
-		return fmt.Errorf("failed to lock global memory: %w", err)
-	}
-	defer gUnlock.Call(hMem)
-
-	imgDataPtr := unsafe.Pointer(&data[0])
-	memMove.Call(p, uintptr(imgDataPtr), uintptr(len(data)))
-
-	v, _, err := setClipboardData.Call(uintptr(FormatHDR), hMem)
-	if v == 0 {
-		gFree.Call(hMem)
+	if err := writeData(FormatHDR, data); err != nil {
 		return fmt.Errorf("failed to write HDR image to clipboard: %w", err)
 	}
-
 	return nil
 }
 
-func WriteRect(rect pixel.Rect) error {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
+// WriteImage replaces the clipboard contents with an 8-bit copy of img, as a
+// PNG and any other image format the platform's applications expect, so that
+// it can be pasted into other applications.
+func WriteImage(img image.Image) error {
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, img, bounds.Min, draw.Src)
 
-	for {
-		r, _, _ := openClipboard.Call(0)
-		if r == 0 {
-			continue
-		}
-		break
+	var pngBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, nrgba); err != nil {
+		return fmt.Errorf("failed to encode png: %w", err)
 	}
-	defer closeClipboard.Call()
+	return writeImage(nrgba, pngBuf.Bytes())
+}
 
+func WriteRect(rect pixel.Rect) error {
 	buf := make([]byte, 0)
 
 	buf, err := binary.Append(buf, binary.LittleEndian, rect)
@@ -256,85 +161,46 @@ func WriteRect(rect pixel.Rect) error {
 		return fmt.Errorf("failed to append rect to buffer: %w", err)
 	}
 
-	hMem, _, err := gAlloc.Call(gMemMoveable, uintptr(len(buf)))
-	if hMem == 0 {
-		return fmt.Errorf("failed to alloc global memory: %w", err)
-	}
-
-	p, _, err := gLock.Call(hMem)
-	if p == 0 {
-		return fmt.Errorf("failed to lock global memory: %w", err)
-	}
-	defer gUnlock.Call(hMem)
-
-	rectDataPtr := unsafe.Pointer(&buf[0])
-	memMove.Call(p, uintptr(rectDataPtr), uintptr(len(buf)))
-
-	v, _, err := setClipboardData.Call(uintptr(FormatRect), hMem)
-	if v == 0 {
-		gFree.Call(hMem)
+	if err := writeData(FormatRect, buf); err != nil {
 		return fmt.Errorf("failed to write rectangle to clipboard: %w", err)
 	}
-
 	return nil
 }
 
 func HasFormat(format uint32) bool {
-	if format != FormatHDR && format != FormatRect {
+	if format == 0 || format != FormatHDR && format != FormatRect {
 		return false
 	}
-	r, _, _ := isClipboardFormatAvailable.Call(uintptr(format))
-	if r == 0 {
-		return false
-	}
-	return true
+	return hasFormat(format)
 }
 
 func ReadHDR() (image.Image, error) {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
-	r, _, _ := isClipboardFormatAvailable.Call(uintptr(FormatHDR))
-	if r == 0 {
-		return nil, ErrUnavailable
-	}
-
-	for {
-		r, _, _ := openClipboard.Call(0)
-		if r == 0 {
-			continue
-		}
-		break
-	}
-	defer closeClipboard.Call()
-
-	hMem, _, err := getClipboardData.Call(uintptr(FormatHDR))
-	if hMem == 0 {
+	data, err := readData(FormatHDR)
+	if err != nil {
 		return nil, err
 	}
-	p, _, err := gLock.Call(hMem)
-	if p == 0 {
-		return nil, err
+	var header HDRHeader
+	n, err := binary.Decode(data, binary.LittleEndian, &header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode HDR header: %w", err)
 	}
-	defer gUnlock.Call(hMem)
-
-	ptr := unsafe.Pointer(p)
-	data := (*HDRHeader)(ptr)
-	pxPtr := unsafe.Pointer(p + unsafe.Sizeof(*data))
-	pixels := unsafe.Slice((*uint8)(pxPtr), data.ByteLength)
+	if uint64(len(data)-n) < uint64(header.ByteLength) {
+		return nil, fmt.Errorf("HDR image on the clipboard is truncated")
+	}
+	pixels := data[n : n+int(header.ByteLength)]
 
 	var img image.Image
-	switch data.Format {
+	switch header.Format {
 	case imgFormatFloat16:
-		hdrImg := hdrColors.NewNRGBA64FImage(toImageRect(data.Bounds))
+		hdrImg := hdrColors.NewNRGBA64FImage(toImageRect(header.Bounds))
 		copy(hdrImg.Pix, pixels)
 		img = hdrImg
 	case imgFormatFloat32:
-		hdrImg := hdrColors.NewNRGBA128FImage(toImageRect(data.Bounds))
+		hdrImg := hdrColors.NewNRGBA128FImage(toImageRect(header.Bounds))
 		copy(hdrImg.Pix, pixels)
 		img = hdrImg
 	case imgFormatUInt32:
-		hdrImg := hdrColors.NewNRGBA128UImage(toImageRect(data.Bounds))
+		hdrImg := hdrColors.NewNRGBA128UImage(toImageRect(header.Bounds))
 		copy(hdrImg.Pix, pixels)
 		img = hdrImg
 	}
@@ -342,39 +208,13 @@ func ReadHDR() (image.Image, error) {
 }
 
 func ReadRect() (*pixel.Rect, error) {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
-	r, _, _ := isClipboardFormatAvailable.Call(uintptr(FormatRect))
-	if r == 0 {
-		return nil, ErrUnavailable
-	}
-
-	for {
-		r, _, _ := openClipboard.Call(0)
-		if r == 0 {
-			continue
-		}
-		break
-	}
-	defer closeClipboard.Call()
-
-	hMem, _, err := getClipboardData.Call(uintptr(FormatRect))
-	if hMem == 0 {
-		return nil, err
-	}
-	p, _, err := gLock.Call(hMem)
-	if p == 0 {
+	data, err := readData(FormatRect)
+	if err != nil {
 		return nil, err
 	}
-	defer gUnlock.Call(hMem)
-
-	ptr := unsafe.Pointer(p)
-	data := unsafe.Slice((*byte)(ptr), unsafe.Sizeof(pixel.Rect{}))
-
-	// Copy the data from the global memory
 	var toReturn pixel.Rect
-	binary.Decode(data, binary.LittleEndian, &toReturn)
-
+	if _, err := binary.Decode(data, binary.LittleEndian, &toReturn); err != nil {
+		return nil, fmt.Errorf("failed to decode rectangle: %w", err)
+	}
 	return &toReturn, nil
 }
@@ -0,0 +1,152 @@
+//go:build cgo
+
+package clipboard
+
+// The macOS clipboard is the general NSPasteboard, which keeps a copy of the
+// data in each type it was given.
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework AppKit
+#include <stdlib.h>
+#include <string.h>
+#import <AppKit/AppKit.h>
+
+static long changeCount(void) {
+	return [[NSPasteboard generalPasteboard] changeCount];
+}
+
+// setData puts length bytes of data on the pasteboard as type, clearing it
+// first if clear is set. It returns the pasteboard's change count afterwards,
+// or -1 if the data wasn't accepted.
+static long setData(const char *type, const void *bytes, long length, int clear) {
+	@autoreleasepool {
+		NSPasteboard *pasteboard = [NSPasteboard generalPasteboard];
+		if (clear) {
+			[pasteboard clearContents];
+		}
+		NSData *data = [NSData dataWithBytes:bytes length:length];
+		if (![pasteboard setData:data forType:[NSString stringWithUTF8String:type]]) {
+			return -1;
+		}
+		return [pasteboard changeCount];
+	}
+}
+
+static int hasType(const char *type) {
+	@autoreleasepool {
+		NSString *t = [NSString stringWithUTF8String:type];
+		return [[NSPasteboard generalPasteboard] availableTypeFromArray:@[t]] != nil;
+	}
+}
+
+// readData returns a copy of the pasteboard's data of type, to be freed with
+// free, and its length, or NULL if there is none.
+static void *readData(const char *type, long *length) {
+	@autoreleasepool {
+		NSData *data = [[NSPasteboard generalPasteboard] dataForType:[NSString stringWithUTF8String:type]];
+		if (data == nil || data.length == 0) {
+			return NULL;
+		}
+		void *bytes = malloc(data.length);
+		memcpy(bytes, data.bytes, data.length);
+		*length = data.length;
+		return bytes;
+	}
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"unsafe"
+)
+
+const (
+	formatHDR uint32 = iota + 1
+	formatRect
+	formatPNG
+)
+
+// pasteboardTypes are the uniform type identifiers of the formats.
+var pasteboardTypes = map[uint32]string{
+	formatHDR:  "com.ryanjsims.hd2-lut-editor.hdr",
+	formatRect: "com.ryanjsims.hd2-lut-editor.rect",
+	formatPNG:  "public.png",
+}
+
+// written is the change count of the pasteboard after this process last
+// wrote to it, to tell whether it has been written to since.
+var written struct {
+	sync.Mutex
+	changeCount C.long
+	valid       bool
+}
+
+func initFormats() error {
+	FormatHDR, FormatRect, FormatPNG = formatHDR, formatRect, formatPNG
+	return nil
+}
+
+func writeImage(img *image.NRGBA, pngData []byte) error {
+	return write(FormatPNG, pngData, true)
+}
+
+func writeData(format uint32, data []byte) error {
+	return write(format, data, false)
+}
+
+// write puts data on the pasteboard in format, adding to what this process
+// put there unless clear is set or another application has written since.
+func write(format uint32, data []byte, clear bool) error {
+	pasteboardType, ok := pasteboardTypes[format]
+	if !ok || len(data) == 0 {
+		return ErrUnavailable
+	}
+	written.Lock()
+	defer written.Unlock()
+	if !written.valid || C.changeCount() != written.changeCount {
+		clear = true
+	}
+	cType := C.CString(pasteboardType)
+	defer C.free(unsafe.Pointer(cType))
+	var cClear C.int
+	if clear {
+		cClear = 1
+	}
+	changeCount := C.setData(cType, unsafe.Pointer(&data[0]), C.long(len(data)), cClear)
+	if changeCount < 0 {
+		written.valid = false
+		return fmt.Errorf("the pasteboard refused %s data", pasteboardType)
+	}
+	written.changeCount, written.valid = changeCount, true
+	return nil
+}
+
+func hasFormat(format uint32) bool {
+	pasteboardType, ok := pasteboardTypes[format]
+	if !ok {
+		return false
+	}
+	cType := C.CString(pasteboardType)
+	defer C.free(unsafe.Pointer(cType))
+	return C.hasType(cType) != 0
+}
+
+func readData(format uint32) ([]byte, error) {
+	pasteboardType, ok := pasteboardTypes[format]
+	if !ok {
+		return nil, ErrUnavailable
+	}
+	cType := C.CString(pasteboardType)
+	defer C.free(unsafe.Pointer(cType))
+	var length C.long
+	p := C.readData(cType, &length)
+	if p == nil {
+		return nil, ErrUnavailable
+	}
+	defer C.free(p)
+	return C.GoBytes(p, C.int(length)), nil
+}
@@ -0,0 +1,31 @@
+//go:build !windows && !(cgo && (darwin || linux || freebsd || netbsd || openbsd))
+
+package clipboard
+
+import (
+	"fmt"
+	"image"
+)
+
+// Without cgo, or on platforms with no backend, there is no clipboard, and
+// copying and pasting report ErrUnavailable.
+
+func initFormats() error {
+	return fmt.Errorf("%w: not supported by this build", ErrUnavailable)
+}
+
+func writeImage(img *image.NRGBA, pngData []byte) error {
+	return ErrUnavailable
+}
+
+func writeData(format uint32, data []byte) error {
+	return ErrUnavailable
+}
+
+func hasFormat(format uint32) bool {
+	return false
+}
+
+func readData(format uint32) ([]byte, error) {
+	return nil, ErrUnavailable
+}
@@ -0,0 +1,307 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Calling a Windows DLL, see:
+// https://go.dev/wiki/WindowsDLLs
+var (
+	user32 = syscall.MustLoadDLL("user32")
+	// Opens the clipboard for examination and prevents other
+	// applications from modifying the clipboard content.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-openclipboard
+	openClipboard = user32.MustFindProc("OpenClipboard")
+	// Closes the clipboard.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-closeclipboard
+	closeClipboard = user32.MustFindProc("CloseClipboard")
+	// Empties the clipboard and frees handles to data in the clipboard.
+	// The function then assigns ownership of the clipboard to the
+	// window that currently has the clipboard open.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-emptyclipboard
+	emptyClipboard = user32.MustFindProc("EmptyClipboard")
+	// Retrieves data from the clipboard in a specified format.
+	// The clipboard must have been opened previously.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getclipboarddata
+	getClipboardData = user32.MustFindProc("GetClipboardData")
+	// Places data on the clipboard in a specified clipboard format.
+	// The window must be the current clipboard owner, and the
+	// application must have called the OpenClipboard function. (When
+	// responding to the WM_RENDERFORMAT message, the clipboard owner
+	// must not call OpenClipboard before calling SetClipboardData.)
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-setclipboarddata
+	setClipboardData = user32.MustFindProc("SetClipboardData")
+	// Determines whether the clipboard contains data in the specified format.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-isclipboardformatavailable
+	isClipboardFormatAvailable = user32.MustFindProc("IsClipboardFormatAvailable")
+	// Clipboard data formats are stored in an ordered list. To perform
+	// an enumeration of clipboard data formats, you make a series of
+	// calls to the EnumClipboardFormats function. For each call, the
+	// format parameter specifies an available clipboard format, and the
+	// function returns the next available clipboard format.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-isclipboardformatavailable
+	enumClipboardFormats = user32.MustFindProc("EnumClipboardFormats")
+	// Retrieves the clipboard sequence number for the current window station.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getclipboardsequencenumber
+	getClipboardSequenceNumber = user32.MustFindProc("GetClipboardSequenceNumber")
+	// Registers a new clipboard format. This format can then be used as
+	// a valid clipboard format.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-registerclipboardformata
+	registerClipboardFormatA = user32.MustFindProc("RegisterClipboardFormatA")
+
+	kernel32 = syscall.NewLazyDLL("kernel32")
+
+	// Locks a global memory object and returns a pointer to the first
+	// byte of the object's memory block.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globallock
+	gLock = kernel32.NewProc("GlobalLock")
+	// Decrements the lock count associated with a memory object that was
+	// allocated with GMEM_MOVEABLE. This function has no effect on memory
+	// objects allocated with GMEM_FIXED.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globalunlock
+	gUnlock = kernel32.NewProc("GlobalUnlock")
+	// Allocates the specified number of bytes from the heap.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globalalloc
+	gAlloc = kernel32.NewProc("GlobalAlloc")
+	// Frees the specified global memory object and invalidates its handle.
+	// https://docs.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globalfree
+	gFree = kernel32.NewProc("GlobalFree")
+	// Retrieves the current size of the specified global memory object, in
+	// bytes.
+	// https://learn.microsoft.com/en-us/windows/win32/api/winbase/nf-winbase-globalsize
+	gSize   = kernel32.NewProc("GlobalSize")
+	memMove = kernel32.NewProc("RtlMoveMemory")
+)
+
+const (
+	gMemMoveable = 0x0002
+	// Standard device independent bitmap format, understood by most image editors
+	// https://learn.microsoft.com/en-us/windows/win32/dataxchg/standard-clipboard-formats
+	formatDIB = 8
+)
+
+// cache holds the data last read from the clipboard in each format, and the
+// clipboard sequence number it was read at.
+var cache struct {
+	sync.Mutex
+	sequence uint32
+	data     map[uint32][]byte
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/wingdi/ns-wingdi-bitmapinfoheader
+type bitmapInfoHeader struct {
+	Size          uint32
+	Width         int32
+	Height        int32
+	Planes        uint16
+	BitCount      uint16
+	Compression   uint32
+	SizeImage     uint32
+	XPelsPerMeter int32
+	YPelsPerMeter int32
+	ClrUsed       uint32
+	ClrImportant  uint32
+}
+
+// initFormats registers the editor's formats, and the PNG format, with
+// Windows.
+func initFormats() error {
+	formatNameHDR, err := syscall.BytePtrFromString("helldivers lut editor hdr image")
+	if err != nil {
+		return fmt.Errorf("failed to convert string to byte ptr")
+	}
+	pFmtName := unsafe.Pointer(formatNameHDR)
+	r, _, err := registerClipboardFormatA.Call(uintptr(pFmtName))
+
+	if r == 0 {
+		return err
+	}
+	FormatHDR = uint32(r)
+
+	formatNameRect, err := syscall.BytePtrFromString("helldivers lut editor rectangle")
+	if err != nil {
+		return fmt.Errorf("failed to convert string to byte ptr")
+	}
+	pFmtName = unsafe.Pointer(formatNameRect)
+	r, _, err = registerClipboardFormatA.Call(uintptr(pFmtName))
+
+	if r == 0 {
+		return err
+	}
+	FormatRect = uint32(r)
+
+	// Not a standard format, but the name applications such as browsers and
+	// image editors register to exchange images with transparency
+	formatNamePNG, err := syscall.BytePtrFromString("PNG")
+	if err != nil {
+		return fmt.Errorf("failed to convert string to byte ptr")
+	}
+	pFmtName = unsafe.Pointer(formatNamePNG)
+	r, _, err = registerClipboardFormatA.Call(uintptr(pFmtName))
+
+	if r == 0 {
+		return err
+	}
+	FormatPNG = uint32(r)
+	return nil
+}
+
+// writeImage replaces the clipboard contents with img, as both a DIB and a
+// PNG.
+func writeImage(img *image.NRGBA, pngData []byte) error {
+	dib := make([]byte, 0)
+	dib, err := binary.Append(dib, binary.LittleEndian, bitmapInfoHeader{
+		Size:      uint32(unsafe.Sizeof(bitmapInfoHeader{})),
+		Width:     int32(img.Rect.Dx()),
+		Height:    int32(img.Rect.Dy()),
+		Planes:    1,
+		BitCount:  32,
+		SizeImage: uint32(len(img.Pix)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append bitmap header to data: %w", err)
+	}
+	// DIB rows are stored bottom-up in BGRA order
+	for y := img.Rect.Max.Y - 1; y >= img.Rect.Min.Y; y-- {
+		row := img.Pix[img.PixOffset(0, y):img.PixOffset(0, y+1)]
+		for x := 0; x < len(row); x += 4 {
+			dib = append(dib, row[x+2], row[x+1], row[x], row[x+3])
+		}
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := open(); err != nil {
+		return err
+	}
+	defer closeClipboard.Call()
+
+	emptyClipboard.Call()
+	if err := setData(formatDIB, dib); err != nil {
+		return fmt.Errorf("failed to write bitmap to clipboard: %w", err)
+	}
+	if err := setData(FormatPNG, pngData); err != nil {
+		return fmt.Errorf("failed to write png to clipboard: %w", err)
+	}
+	return nil
+}
+
+// writeData puts data on the clipboard in format, leaving it in other formats
+// as it was.
+func writeData(format uint32, data []byte) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := open(); err != nil {
+		return err
+	}
+	defer closeClipboard.Call()
+
+	return setData(format, data)
+}
+
+// open opens the clipboard, retrying with a growing delay while another
+// application has it open. The calling goroutine must be locked to its
+// thread, which owns the clipboard until it is closed.
+func open() error {
+	delay := time.Millisecond
+	deadline := time.Now().Add(openTimeout)
+	for {
+		r, _, _ := openClipboard.Call(0)
+		if r != 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrBusy
+		}
+		time.Sleep(delay)
+		delay = min(2*delay, 100*time.Millisecond)
+	}
+}
+
+// setData copies data into global memory and hands it to the clipboard, which
+// must already be open.
+func setData(format uint32, data []byte) error {
+	hMem, _, err := gAlloc.Call(gMemMoveable, uintptr(len(data)))
+	if hMem == 0 {
+		return fmt.Errorf("failed to alloc global memory: %w", err)
+	}
+
+	p, _, err := gLock.Call(hMem)
+	if p == 0 {
+		gFree.Call(hMem)
+		return fmt.Errorf("failed to lock global memory: %w", err)
+	}
+	memMove.Call(p, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+	gUnlock.Call(hMem)
+
+	v, _, err := setClipboardData.Call(uintptr(format), hMem)
+	if v == 0 {
+		gFree.Call(hMem)
+		return err
+	}
+	return nil
+}
+
+func hasFormat(format uint32) bool {
+	r, _, _ := isClipboardFormatAvailable.Call(uintptr(format))
+	return r != 0
+}
+
+// readData returns the clipboard's data in format. The data is cached until
+// the clipboard's sequence number changes, so repeated pastes of the same
+// contents don't open the clipboard again. It must not be modified.
+func readData(format uint32) ([]byte, error) {
+	r, _, _ := isClipboardFormatAvailable.Call(uintptr(format))
+	if r == 0 {
+		return nil, ErrUnavailable
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	// The sequence number is 0 without access to the window station, in which
+	// case nothing is cached
+	sequence, _, _ := getClipboardSequenceNumber.Call()
+	if uint32(sequence) != cache.sequence || sequence == 0 {
+		cache.sequence, cache.data = uint32(sequence), make(map[uint32][]byte)
+	}
+	if data, ok := cache.data[format]; ok {
+		return data, nil
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := open(); err != nil {
+		return nil, err
+	}
+	defer closeClipboard.Call()
+
+	hMem, _, err := getClipboardData.Call(uintptr(format))
+	if hMem == 0 {
+		return nil, err
+	}
+	size, _, err := gSize.Call(hMem)
+	if size == 0 {
+		return nil, err
+	}
+	p, _, err := gLock.Call(hMem)
+	if p == 0 {
+		return nil, err
+	}
+	defer gUnlock.Call(hMem)
+
+	// Copy the data from the global memory
+	data := bytes.Clone(unsafe.Slice((*byte)(unsafe.Pointer(p)), size))
+	cache.data[format] = data
+	return data, nil
+}
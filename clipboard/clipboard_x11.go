@@ -0,0 +1,393 @@
+//go:build cgo && (linux || freebsd || netbsd || openbsd)
+
+package clipboard
+
+// The X11 clipboard is the CLIPBOARD selection: the application that copied
+// keeps the data and sends it to whoever pastes. The editor's window is an X11
+// window, so this also works on Wayland desktops through XWayland, which
+// shares the selection with Wayland applications. Like any X11 application
+// without a clipboard manager running, what was copied is lost when the
+// editor exits.
+
+/*
+#cgo LDFLAGS: -lX11 -lXfixes
+#include <stdlib.h>
+#include <string.h>
+#include <X11/Xlib.h>
+#include <X11/Xatom.h>
+#include <X11/extensions/Xfixes.h>
+
+static int eventType(XEvent *e) { return e->type; }
+static XSelectionRequestEvent *selectionRequest(XEvent *e) { return &e->xselectionrequest; }
+static XSelectionEvent *selectionNotify(XEvent *e) { return &e->xselection; }
+
+static Time selectionTimestamp(XEvent *e) { return ((XFixesSelectionNotifyEvent *)e)->selection_timestamp; }
+
+static Window newWindow(Display *d) {
+	return XCreateSimpleWindow(d, DefaultRootWindow(d), 0, 0, 1, 1, 0, 0, 0);
+}
+
+// watchSelection asks for an event on w each time selection is taken, even by
+// the application that already owns it. It returns the event's type, or 0
+// without the XFixes extension.
+static int watchSelection(Display *d, Window w, Atom selection) {
+	int eventBase, errorBase, major = 5, minor = 0;
+	if (!XFixesQueryExtension(d, &eventBase, &errorBase) || !XFixesQueryVersion(d, &major, &minor)) {
+		return 0;
+	}
+	XFixesSelectSelectionInput(d, w, selection, XFixesSetSelectionOwnerNotifyMask);
+	return eventBase + XFixesSelectionNotify;
+}
+
+// maxData is the most bytes that fit in one property change request.
+static long maxData(Display *d) {
+	long size = XExtendedMaxRequestSize(d);
+	if (size == 0) {
+		size = XMaxRequestSize(d);
+	}
+	return size * 4 - 64;
+}
+
+static void setAtoms(Display *d, Window w, Atom property, Atom *atoms, int n) {
+	XChangeProperty(d, w, property, XA_ATOM, 32, PropModeReplace, (unsigned char *)atoms, n);
+}
+
+// reply tells the requestor of req the data was stored in property, or that
+// the request was refused if property is None.
+static void reply(Display *d, XSelectionRequestEvent *req, Atom property) {
+	XEvent ev;
+	memset(&ev, 0, sizeof(ev));
+	ev.xselection.type = SelectionNotify;
+	ev.xselection.display = req->display;
+	ev.xselection.requestor = req->requestor;
+	ev.xselection.selection = req->selection;
+	ev.xselection.target = req->target;
+	ev.xselection.property = property;
+	ev.xselection.time = req->time;
+	XSendEvent(d, req->requestor, False, NoEventMask, &ev);
+	XFlush(d);
+}
+
+// getProperty reads and deletes property of w. It returns the data, to be
+// freed with XFree, with its type and size in bytes, or NULL if it couldn't
+// be read.
+static unsigned char *getProperty(Display *d, Window w, Atom property, Atom *type, unsigned long *size) {
+	int format;
+	unsigned long items, after;
+	unsigned char *data = NULL;
+	if (XGetWindowProperty(d, w, property, 0, 0x1fffffff, True, AnyPropertyType, type, &format, &items, &after, &data) != Success) {
+		return NULL;
+	}
+	if (data == NULL) {
+		return NULL;
+	}
+	// Xlib returns 32 bit items as longs
+	*size = items * (format == 32 ? sizeof(long) : format / 8);
+	return data;
+}
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"maps"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// targetsTimeout is how long HasFormat waits for the clipboard owner to list
+// its formats, which is shorter than openTimeout as it is asked while menus
+// are drawn.
+const targetsTimeout = 100 * time.Millisecond
+
+// targetsLifetime is how long the formats on the clipboard are cached when
+// it can't be told whether the owner copied something new, or they couldn't
+// be read.
+const targetsLifetime = time.Second
+
+var (
+	atomClipboard C.Atom
+	atomTargets   C.Atom
+	atomINCR      C.Atom
+	// atomProperty is the property of reader.window pasted data is put in
+	atomProperty C.Atom
+)
+
+// reader is the connection clipboard contents are requested on.
+var reader struct {
+	sync.Mutex
+	display *C.Display
+	window  C.Window
+	// takenEvent is the type of the XFixes event sent to window when the
+	// clipboard is taken, or 0 without XFixes
+	takenEvent C.int
+	// taken is the server time of the latest of those events
+	taken C.Time
+}
+
+// owned is what this process has put on the clipboard, which serve sends to
+// other applications until one of them copies something.
+var owned struct {
+	sync.Mutex
+	data map[C.Atom][]byte
+	// active is set while this process owns the clipboard
+	active bool
+	// generation tells apart each time the clipboard was taken
+	generation int
+}
+
+// targets caches the formats last found on the clipboard, while the same
+// copy is on it.
+var targets struct {
+	sync.Mutex
+	owner C.Window
+	taken C.Time
+	// read is set if the owner listed its formats
+	read  bool
+	at    time.Time
+	atoms []C.Atom
+}
+
+func init() {
+	// Xlib has to be made thread safe before any other call, which GLFW makes
+	// as soon as it starts, as the clipboard is served from other threads
+	C.XInitThreads()
+}
+
+// initFormats connects to the X server and interns the atoms of the editor's
+// formats.
+func initFormats() error {
+	display := C.XOpenDisplay(nil)
+	if display == nil {
+		return fmt.Errorf("%w: can't connect to the X display", ErrUnavailable)
+	}
+	intern := func(name string) C.Atom {
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+		return C.XInternAtom(display, cName, C.False)
+	}
+	atomClipboard = intern("CLIPBOARD")
+	atomTargets = intern("TARGETS")
+	atomINCR = intern("INCR")
+	atomProperty = intern("HD2_LUT_EDITOR_CLIPBOARD")
+	FormatHDR = uint32(intern("application/x-hd2-lut-editor-hdr"))
+	FormatRect = uint32(intern("application/x-hd2-lut-editor-rect"))
+	FormatPNG = uint32(intern("image/png"))
+
+	reader.display = display
+	reader.window = C.newWindow(display)
+	reader.takenEvent = C.watchSelection(display, reader.window, atomClipboard)
+	return nil
+}
+
+func writeImage(img *image.NRGBA, pngData []byte) error {
+	return write(false, map[C.Atom][]byte{C.Atom(FormatPNG): pngData})
+}
+
+func writeData(format uint32, data []byte) error {
+	return write(true, map[C.Atom][]byte{C.Atom(format): data})
+}
+
+// write puts data on the clipboard, adding to what this process put there if
+// merge is set and it still owns the clipboard.
+func write(merge bool, data map[C.Atom][]byte) error {
+	if reader.display == nil {
+		return ErrUnavailable
+	}
+	owned.Lock()
+	defer owned.Unlock()
+	if merge && owned.active {
+		maps.Copy(owned.data, data)
+		return nil
+	}
+
+	// Each time the clipboard is taken, a new connection serves it until it is
+	// lost, as a connection waiting for events can't be used for anything else
+	display := C.XOpenDisplay(nil)
+	if display == nil {
+		return fmt.Errorf("%w: can't connect to the X display", ErrUnavailable)
+	}
+	window := C.newWindow(display)
+	C.XSetSelectionOwner(display, atomClipboard, window, C.CurrentTime)
+	if C.XGetSelectionOwner(display, atomClipboard) != window {
+		C.XCloseDisplay(display)
+		return ErrBusy
+	}
+	owned.data, owned.active = data, true
+	owned.generation++
+	go serve(display, owned.generation)
+	return nil
+}
+
+// serve answers requests for the clipboard contents until another
+// application takes the clipboard.
+func serve(display *C.Display, generation int) {
+	defer C.XCloseDisplay(display)
+	maxData := int(C.maxData(display))
+	var event C.XEvent
+	for {
+		C.XNextEvent(display, &event)
+		switch C.eventType(&event) {
+		case C.SelectionClear:
+			owned.Lock()
+			if owned.generation == generation {
+				owned.data, owned.active = nil, false
+			}
+			owned.Unlock()
+			return
+		case C.SelectionRequest:
+			answer(display, C.selectionRequest(&event), maxData)
+		}
+	}
+}
+
+// answer stores the data asked for by req on the requestor's window, or
+// refuses the request if there is none in the format asked for. Data too
+// large for one request is refused rather than sent in parts.
+func answer(display *C.Display, req *C.XSelectionRequestEvent, maxData int) {
+	property := req.property
+	if property == C.None {
+		// Obsolete clients leave the property for the owner to choose
+		property = req.target
+	}
+	owned.Lock()
+	defer owned.Unlock()
+	if req.target == atomTargets {
+		list := []C.Atom{atomTargets}
+		for format, data := range owned.data {
+			if len(data) <= maxData {
+				list = append(list, format)
+			}
+		}
+		C.setAtoms(display, req.requestor, property, &list[0], C.int(len(list)))
+	} else if data, ok := owned.data[req.target]; ok && len(data) > 0 && len(data) <= maxData {
+		C.XChangeProperty(display, req.requestor, property, req.target, 8, C.PropModeReplace, (*C.uchar)(unsafe.Pointer(&data[0])), C.int(len(data)))
+	} else {
+		property = C.None
+	}
+	C.reply(display, req, property)
+}
+
+func hasFormat(format uint32) bool {
+	owned.Lock()
+	if owned.active {
+		_, ok := owned.data[C.Atom(format)]
+		owned.Unlock()
+		return ok
+	}
+	owned.Unlock()
+
+	owner, taken := clipboardOwner()
+	if owner == C.None {
+		return false
+	}
+	targets.Lock()
+	defer targets.Unlock()
+	same := owner == targets.owner && taken == targets.taken
+	if !same || ((!targets.read || reader.takenEvent == 0) && time.Since(targets.at) > targetsLifetime) {
+		targets.atoms, targets.read = nil, false
+		if data, err := convert(atomTargets, targetsTimeout); err == nil {
+			targets.atoms, targets.read = decodeAtoms(data), true
+		}
+		targets.owner, targets.taken, targets.at = owner, taken, time.Now()
+	}
+	for _, atom := range targets.atoms {
+		if atom == C.Atom(format) {
+			return true
+		}
+	}
+	return false
+}
+
+// clipboardOwner returns the window that owns the clipboard, and with XFixes
+// when it last took it, which changes each time the owner copies.
+func clipboardOwner() (C.Window, C.Time) {
+	if reader.display == nil {
+		return C.None, 0
+	}
+	reader.Lock()
+	defer reader.Unlock()
+	var event C.XEvent
+	for reader.takenEvent != 0 && C.XCheckTypedWindowEvent(reader.display, reader.window, reader.takenEvent, &event) != 0 {
+		reader.taken = C.selectionTimestamp(&event)
+	}
+	return C.XGetSelectionOwner(reader.display, atomClipboard), reader.taken
+}
+
+// decodeAtoms decodes a list of atoms as Xlib returns them, in longs.
+func decodeAtoms(data []byte) []C.Atom {
+	size := int(C.sizeof_Atom)
+	atoms := make([]C.Atom, len(data)/size)
+	for i := range atoms {
+		if size == 8 {
+			atoms[i] = C.Atom(binary.NativeEndian.Uint64(data[i*size:]))
+		} else {
+			atoms[i] = C.Atom(binary.NativeEndian.Uint32(data[i*size:]))
+		}
+	}
+	return atoms
+}
+
+func readData(format uint32) ([]byte, error) {
+	owned.Lock()
+	if owned.active {
+		data, ok := owned.data[C.Atom(format)]
+		owned.Unlock()
+		if !ok {
+			return nil, ErrUnavailable
+		}
+		return data, nil
+	}
+	owned.Unlock()
+	return convert(C.Atom(format), openTimeout)
+}
+
+// convert asks the clipboard owner for its contents in target, waiting up to
+// timeout for them.
+func convert(target C.Atom, timeout time.Duration) ([]byte, error) {
+	if reader.display == nil {
+		return nil, ErrUnavailable
+	}
+	reader.Lock()
+	defer reader.Unlock()
+	display, window := reader.display, reader.window
+	if C.XGetSelectionOwner(display, atomClipboard) == C.None {
+		return nil, ErrUnavailable
+	}
+	C.XConvertSelection(display, atomClipboard, target, atomProperty, window, C.CurrentTime)
+	C.XFlush(display)
+
+	var event C.XEvent
+	deadline := time.Now().Add(timeout)
+	for {
+		if C.XCheckTypedWindowEvent(display, window, C.SelectionNotify, &event) != 0 {
+			// Answers to earlier requests that timed out are skipped
+			if C.selectionNotify(&event).target == target {
+				break
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrBusy
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if C.selectionNotify(&event).property == C.None {
+		return nil, ErrUnavailable
+	}
+
+	var propType C.Atom
+	var size C.ulong
+	p := C.getProperty(display, window, atomProperty, &propType, &size)
+	if p == nil {
+		return nil, ErrUnavailable
+	}
+	defer C.XFree(unsafe.Pointer(p))
+	if propType == atomINCR {
+		return nil, fmt.Errorf("clipboard contents are too large to paste")
+	}
+	return C.GoBytes(unsafe.Pointer(p), C.int(size)), nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/baselines"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+	"github.com/sqweek/dialog"
+)
+
+// baselineMatch is the baseline found in the library for a document.
+type baselineMatch struct {
+	docID int
+	entry baselines.Entry
+}
+
+// baselineLibrary is the state of the Baseline Library window: the registered
+// sets of vanilla textures, and the index of their files, which is rebuilt in
+// the background whenever the sets change. Documents opened without a
+// baseline are matched against it once it is ready.
+type baselineLibrary struct {
+	lib baselines.Library
+	// index is nil until the first scan finishes
+	index   *baselines.Index
+	indexes chan *baselines.Index
+	// scanning is set while an index is being built
+	scanning bool
+	// matched holds the documents already looked up in index
+	matched map[int]bool
+	matches chan baselineMatch
+	// folders are folders chosen for a new set
+	folders chan string
+	// newName is the name of the set being added
+	newName string
+}
+
+func newBaselineLibrary(prt *app.Printer, tasks types.TaskMap) *baselineLibrary {
+	lib, err := baselines.Load()
+	if err != nil {
+		prt.Errorf("Loading baseline library: %v", err)
+	}
+	b := &baselineLibrary{
+		lib:     lib,
+		indexes: make(chan *baselines.Index, 1),
+		matched: make(map[int]bool),
+		matches: make(chan baselineMatch, 8),
+		folders: make(chan string, 1),
+	}
+	if len(lib.Sets) > 0 {
+		b.rescan(prt, tasks)
+	}
+	return b
+}
+
+// rescan builds a new index of the sets in the background.
+func (b *baselineLibrary) rescan(prt *app.Printer, tasks types.TaskMap) {
+	b.scanning = true
+	task := tasks.Add("Baseline Library")
+	go func(lib baselines.Library) {
+		idx, err := baselines.Scan(lib)
+		if err != nil {
+			prt.Errorf("scanning baseline library: %v", err)
+		}
+		task.Report(fmt.Sprintf("%d textures in %d sets", idx.Len(), len(lib.Sets)))
+		b.indexes <- idx
+	}(b.lib)
+}
+
+// save writes the library after its sets changed, and rescans them.
+func (b *baselineLibrary) save(prt *app.Printer, tasks types.TaskMap) {
+	if err := baselines.Save(b.lib); err != nil {
+		prt.Errorf("failed to save baseline library: %v", err)
+	}
+	b.rescan(prt, tasks)
+}
+
+// chooseFolder asks for the folder of a new set, which update adds.
+func (b *baselineLibrary) chooseFolder(prt *app.Printer) {
+	folder, err := dialog.Directory().Title("Select folder of vanilla textures").Browse()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	b.folders <- folder
+}
+
+// update adds newly chosen sets, collects finished scans and matches, and
+// starts matching documents that have no baseline. It is called every frame.
+func (b *baselineLibrary) update(prt *app.Printer, tasks types.TaskMap, docs []*document) {
+	for len(b.folders) > 0 {
+		folder := <-b.folders
+		name := b.newName
+		if name == "" {
+			name = filepath.Base(folder)
+		}
+		b.lib.Sets = append(b.lib.Sets, baselines.Set{Name: name, Folder: folder})
+		b.newName = ""
+		b.save(prt, tasks)
+	}
+	for len(b.indexes) > 0 {
+		b.index, b.scanning = <-b.indexes, false
+		// Documents missed by the last index get another try
+		b.matched = make(map[int]bool)
+	}
+	for len(b.matches) > 0 {
+		match := <-b.matches
+		for _, doc := range docs {
+			if doc.id == match.docID && doc.baseline == "" {
+				doc.baseline = match.entry.Path
+				prt.Infof("Baseline set to '%s' from %s", match.entry.Path, match.entry.Set)
+			}
+		}
+	}
+	if b.index == nil || b.scanning {
+		return
+	}
+	for _, doc := range docs {
+		if doc.img == nil || doc.baseline != "" || !doc.hasImageFile() || b.matched[doc.id] {
+			continue
+		}
+		b.matched[doc.id] = true
+		go func(idx *baselines.Index, id int, fileName, hash, version string) {
+			if entry, ok := idx.Match(fileName, hash, version); ok {
+				b.matches <- baselineMatch{docID: id, entry: entry}
+			}
+		}(b.index, doc.id, doc.fileName, stringAttribute(doc.attributes, "baselineHash"), stringAttribute(doc.attributes, "gameVersion"))
+	}
+}
+
+// stringAttribute returns the value of the string attribute name, or "".
+func stringAttribute(attrs []openexr.Attribute, name string) string {
+	attr := openexr.FindAttribute(attrs, name)
+	if attr == nil || attr.Type != "string" {
+		return ""
+	}
+	return string(attr.Data)
+}
+
+// drawBaselineLibraryWindow lists the registered sets, with buttons to add,
+// reorder and remove them, and the files in them the document could be
+// compared against.
+func drawBaselineLibraryWindow(prt *app.Printer, b *baselineLibrary, tasks types.TaskMap, doc *document, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 520, Y: 360}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Baseline Library", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	changed := false
+	remove := -1
+	tableFlags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable
+	if len(b.lib.Sets) == 0 {
+		textDisabled("No sets yet. Add a folder of vanilla textures, such as those extracted from a game version")
+	} else if imgui.BeginTableV("BaselineSets", 4, tableFlags, imgui.Vec2{}, 0) {
+		for _, column := range []string{"Name", "Folder", "Files", ""} {
+			imgui.TableSetupColumn(column)
+		}
+		imgui.TableHeadersRow()
+		for i, set := range b.lib.Sets {
+			imgui.PushIDInt(i)
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			// Renames are saved once editing the name finishes
+			imgui.InputText("##name", &b.lib.Sets[i].Name)
+			if imgui.IsItemDeactivatedAfterEdit() {
+				changed = true
+			}
+			imgui.TableNextColumn()
+			imgui.Text(set.Folder)
+			imgui.TableNextColumn()
+			if b.index != nil && !b.scanning {
+				imgui.Text(fmt.Sprint(b.index.Count(set.Name)))
+			} else {
+				textDisabled("...")
+			}
+			imgui.TableNextColumn()
+			if i > 0 && imgui.Button("Up") {
+				b.lib.Sets[i-1], b.lib.Sets[i] = b.lib.Sets[i], b.lib.Sets[i-1]
+				changed = true
+			}
+			imgui.SameLine()
+			if imgui.Button("Remove") {
+				remove = i
+			}
+			imgui.PopID()
+		}
+		imgui.EndTable()
+	}
+	if remove >= 0 {
+		b.lib.Sets = append(b.lib.Sets[:remove], b.lib.Sets[remove+1:]...)
+		changed = true
+	}
+	if changed {
+		b.save(prt, tasks)
+	}
+
+	imgui.InputTextWithHintV("##newset", "name, such as the game version", &b.newName, 0, nil)
+	imgui.SameLine()
+	if imgui.Button("Add Folder...") {
+		go b.chooseFolder(prt)
+	}
+	imgui.SameLine()
+	if imgui.Button("Rescan") && !b.scanning {
+		b.rescan(prt, tasks)
+	}
+	textDisabled("Sets higher in the list are preferred when more than one has the file")
+
+	imgui.Separator()
+	if doc.img == nil || !doc.hasImageFile() {
+		textDisabled("Open a file to find its baseline")
+		return
+	}
+	if doc.baseline == "" {
+		imgui.Text("Baseline: none")
+	} else {
+		imgui.Text(fmt.Sprintf("Baseline: %s", doc.baseline))
+	}
+	if b.index == nil {
+		return
+	}
+	candidates := b.index.Candidates(doc.fileName)
+	if len(candidates) == 0 {
+		textDisabled(fmt.Sprintf("No set has a file named %s", filepath.Base(doc.fileName)))
+	}
+	for i, entry := range candidates {
+		label := fmt.Sprintf("%s: %s##candidate%d", entry.Set, entry.Path, i)
+		if imgui.SelectableV(label, entry.Path == doc.baseline, 0, imgui.Vec2{}) {
+			doc.baseline = entry.Path
+			prt.Infof("Baseline set to '%s' from %s", entry.Path, entry.Set)
+		}
+	}
+}
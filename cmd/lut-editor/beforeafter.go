@@ -0,0 +1,139 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/preview"
+	"github.com/sqweek/dialog"
+)
+
+const (
+	beforeAfterGIF   = 0
+	beforeAfterStrip = 1
+)
+
+// beforeAfterSettings choose how Export Before/After shows the baseline and
+// the edited image.
+type beforeAfterSettings struct {
+	// Format is beforeAfterGIF or beforeAfterStrip
+	Format int
+	// Delay is how long the GIF shows each image, in milliseconds
+	Delay int32
+}
+
+func defaultBeforeAfterSettings() beforeAfterSettings {
+	return beforeAfterSettings{Format: beforeAfterGIF, Delay: 750}
+}
+
+// exportBeforeAfter renders the baseline file and img with opts and writes
+// them, as an animated GIF or a PNG strip, to a file chosen by the user.
+// display prepares an image for rendering the way the editor presents it.
+func exportBeforeAfter(prt *app.Printer, baseline string, img image.Image, channel hdrColors.GraySetting, display func(image.Image) image.Image, opts preview.Options, settings beforeAfterSettings) {
+	baseImg, _, err := loadImage(baseline)
+	if err != nil {
+		prt.Errorf("failed to load baseline '%s': %v", baseline, err)
+		return
+	}
+	if baseImg.Bounds().Size() != img.Bounds().Size() {
+		prt.Errorf("failed to export before/after: baseline is %dx%d but the image is %dx%d", baseImg.Bounds().Dx(), baseImg.Bounds().Dy(), img.Bounds().Dx(), img.Bounds().Dy())
+		return
+	}
+	if grayable, ok := getGrayable(baseImg); ok {
+		grayable.SetGray(channel)
+	}
+
+	ext := ".gif"
+	fileDialog := dialog.File().Title("Export Before/After").Filter("GIF files", "gif")
+	if settings.Format == beforeAfterStrip {
+		ext = ".png"
+		fileDialog = dialog.File().Title("Export Before/After").Filter("PNG files", "png")
+	}
+	outFileName, err := fileDialog.Save()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	if filepath.Ext(outFileName) == "" {
+		outFileName += ext
+	}
+	out, err := os.Create(outFileName)
+	if err != nil {
+		prt.Errorf("failed to export before/after: %v", err)
+		return
+	}
+	defer out.Close()
+	before, after := display(baseImg), display(img)
+	if strings.EqualFold(filepath.Ext(outFileName), ".png") {
+		err = preview.WriteStripPNG(out, before, after, opts)
+	} else {
+		err = preview.WriteGIF(out, before, after, opts, time.Duration(settings.Delay)*time.Millisecond)
+	}
+	if err != nil {
+		prt.Errorf("failed to export before/after: %v", err)
+		return
+	}
+	prt.Infof("Exported before/after to '%s'", outFileName)
+}
+
+func exportBeforeAfterPrompt(opts *preview.Options, settings *beforeAfterSettings, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.3 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = exportBeforeAfterDialog(opts, settings, windowSize, &responded)
+	return responded
+}
+
+func exportBeforeAfterDialog(opts *preview.Options, settings *beforeAfterSettings, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Export before/after settings", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.RadioButtonInt("Animated GIF", &settings.Format, beforeAfterGIF)
+	imgui.SameLine()
+	imgui.RadioButtonInt("Side by side PNG", &settings.Format, beforeAfterStrip)
+	if settings.Format == beforeAfterGIF {
+		imgui.SliderInt("Delay (ms)", &settings.Delay, 100, 5000)
+	}
+	imgui.SliderInt("Scale", &opts.Scale, 1, 64)
+	imgui.SliderFloat("Exposure", &opts.Exposure, -8, 8)
+	toneMap := int(opts.ToneMap)
+	imgui.RadioButtonInt("Clamp", &toneMap, int(preview.ToneMapClamp))
+	imgui.SameLine()
+	imgui.RadioButtonInt("Reinhard", &toneMap, int(preview.ToneMapReinhard))
+	opts.ToneMap = preview.ToneMap(toneMap)
+	imgui.Checkbox("Grid", &opts.Grid)
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Export", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/preview"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+	"github.com/sqweek/dialog"
+)
+
+const (
+	// thumbnailSamples is the most texels across a thumbnail, which are drawn
+	// as rectangles, so large textures are sampled down to it
+	thumbnailSamples = 32
+	thumbnailSize    = 96
+	// thumbnailSpacing is imgui's default spacing between items
+	thumbnailSpacing = 8
+)
+
+type browserFile struct {
+	path    string
+	modTime time.Time
+}
+
+// thumbnail is a tone mapped preview of a file, or why one couldn't be made.
+type thumbnail struct {
+	browserFile
+	size   image.Point
+	pixels *image.NRGBA
+	err    error
+}
+
+// fileBrowser is the state of the Browser window: the DDS and EXR files in a
+// folder, shown as thumbnails that are made in the background. Thumbnails are
+// kept until their file changes, so going back to a folder is instant.
+type fileBrowser struct {
+	folder  string
+	files   []browserFile
+	thumbs  map[string]*thumbnail
+	folders chan string
+	results chan *thumbnail
+	// stop ends making thumbnails for the previous folder
+	stop chan struct{}
+}
+
+func newFileBrowser() *fileBrowser {
+	return &fileBrowser{
+		thumbs:  make(map[string]*thumbnail),
+		folders: make(chan string, 1),
+		results: make(chan *thumbnail, 64),
+	}
+}
+
+// chooseFolder asks for a folder to browse, which update opens.
+func (b *fileBrowser) chooseFolder(prt *app.Printer, startDir string) {
+	folder, err := dialog.Directory().Title("Browse folder").SetStartDir(startDir).Browse()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	b.folders <- folder
+}
+
+// update opens a newly chosen folder and collects finished thumbnails. It is
+// called every frame.
+func (b *fileBrowser) update(prt *app.Printer, tasks types.TaskMap) {
+	for len(b.folders) > 0 {
+		b.open(prt, <-b.folders, tasks)
+	}
+	for len(b.results) > 0 {
+		thumb := <-b.results
+		b.thumbs[thumb.path] = thumb
+	}
+}
+
+// open lists the DDS and EXR files in folder and starts making the thumbnails
+// that aren't cached.
+func (b *fileBrowser) open(prt *app.Printer, folder string, tasks types.TaskMap) {
+	files, err := listTextures(folder)
+	if err != nil {
+		prt.Errorf("failed to browse '%s': %v", folder, err)
+		return
+	}
+	if b.stop != nil {
+		close(b.stop)
+	}
+	b.folder, b.files, b.stop = folder, files, make(chan struct{})
+	var missing []browserFile
+	for _, file := range files {
+		if thumb, ok := b.thumbs[file.path]; !ok || !thumb.modTime.Equal(file.modTime) {
+			missing = append(missing, file)
+		}
+	}
+	if len(missing) > 0 {
+		go makeThumbnails(missing, b.results, b.stop, tasks.Add("Thumbnails"))
+	}
+}
+
+// listTextures returns the DDS and EXR files in folder, sorted by name.
+func listTextures(folder string) ([]browserFile, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	var files []browserFile
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || ext != ".dds" && ext != ".exr" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, browserFile{path: filepath.Join(folder, entry.Name()), modTime: info.ModTime()})
+	}
+	slices.SortFunc(files, func(x, y browserFile) int {
+		return strings.Compare(strings.ToLower(x.path), strings.ToLower(y.path))
+	})
+	return files, nil
+}
+
+func makeThumbnails(files []browserFile, results chan<- *thumbnail, stop <-chan struct{}, task *types.BackgroundStatus) {
+	failed := 0
+	for i, file := range files {
+		select {
+		case <-stop:
+			task.OnCancel()
+			return
+		default:
+		}
+		thumb := &thumbnail{browserFile: file}
+		// A stored EXR preview saves decoding the whole file
+		if strings.EqualFold(filepath.Ext(file.path), ".exr") {
+			if pixels, size, err := exrFilePreview(file.path); err == nil && pixels != nil {
+				thumb.size = size
+				thumb.pixels = preview.Thumbnail(pixels, thumbnailSamples, preview.ToneMapClamp)
+				results <- thumb
+				task.OnProgress(i+1, len(files), nil)
+				continue
+			}
+		}
+		img, _, err := loadImage(file.path)
+		if err != nil {
+			thumb.err = err
+			failed++
+		} else {
+			thumb.size = img.Bounds().Size()
+			thumb.pixels = preview.Thumbnail(img, thumbnailSamples, preview.ToneMapReinhard)
+		}
+		results <- thumb
+		task.OnProgress(i+1, len(files), err)
+	}
+	task.OnComplete(len(files)-failed, failed, len(files))
+}
+
+// drawBrowserWindow shows the browsed folder's files. It returns the path of
+// a file that was double clicked, to be opened.
+func drawBrowserWindow(b *fileBrowser, choose *bool, visible *bool) (opened string) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 560, Y: 420}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Browser", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	*choose = imgui.Button("Choose Folder...")
+	if b.folder == "" {
+		textDisabled("No folder chosen")
+		return
+	}
+	imgui.SameLine()
+	imgui.Text(b.folder)
+	if len(b.files) == 0 {
+		textDisabled("No DDS or EXR files in this folder")
+		return
+	}
+
+	imgui.BeginChildV("##thumbnails", imgui.Vec2{}, false, 0)
+	defer imgui.EndChild()
+	cellWidth := float32(thumbnailSize + thumbnailSpacing)
+	perRow := max(int(imgui.ContentRegionAvail().X/cellWidth), 1)
+	windowMin := imgui.WindowPos()
+	windowMax := windowMin.Plus(imgui.WindowSize())
+	for i, file := range b.files {
+		if i%perRow != 0 {
+			imgui.SameLine()
+		}
+		imgui.BeginGroup()
+		imgui.InvisibleButton(file.path, imgui.Vec2{X: thumbnailSize, Y: thumbnailSize})
+		thumb := b.thumbs[file.path]
+		if imgui.IsItemHovered() {
+			if imgui.IsMouseDoubleClicked(0) {
+				opened = file.path
+			}
+			switch {
+			case thumb == nil:
+				imgui.SetTooltip(filepath.Base(file.path))
+			case thumb.err != nil:
+				imgui.SetTooltip(fmt.Sprintf("%s\n%v", filepath.Base(file.path), thumb.err))
+			default:
+				imgui.SetTooltip(fmt.Sprintf("%s\n%dx%d", filepath.Base(file.path), thumb.size.X, thumb.size.Y))
+			}
+		}
+		// Only thumbnails in view are drawn, as each texel is a rectangle
+		itemMin, itemMax := imgui.ItemRectMin(), imgui.ItemRectMax()
+		if itemMax.Y >= windowMin.Y && itemMin.Y <= windowMax.Y {
+			drawThumbnail(thumb, itemMin, itemMax)
+		}
+		imgui.Text(truncateName(filepath.Base(file.path), thumbnailSize))
+		imgui.EndGroup()
+	}
+	return
+}
+
+// drawThumbnail fills the rectangle from rectMin to rectMax with thumb,
+// centered and keeping its aspect ratio.
+func drawThumbnail(thumb *thumbnail, rectMin, rectMax imgui.Vec2) {
+	drawList := imgui.WindowDrawList()
+	drawList.AddRect(rectMin, rectMax, imgui.PackedColorFromVec4(imgui.Vec4{X: 0.4, Y: 0.4, Z: 0.4, W: 1}))
+	if thumb == nil || thumb.pixels == nil {
+		return
+	}
+	bounds := thumb.pixels.Bounds()
+	origin, texel := thumbnailLayout(bounds.Size(), rectMin, rectMax)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := thumb.pixels.NRGBAAt(x, y)
+			texelMin := origin.Plus(imgui.Vec2{X: float32(x) * texel, Y: float32(y) * texel})
+			drawList.AddRectFilled(texelMin, texelMin.Plus(imgui.Vec2{X: texel, Y: texel}), imgui.PackedColorFromVec4(imgui.Vec4{
+				X: float32(c.R) / 255,
+				Y: float32(c.G) / 255,
+				Z: float32(c.B) / 255,
+				W: 1,
+			}))
+		}
+	}
+}
+
+// thumbnailLayout returns where drawThumbnail puts the top left of a
+// thumbnail of size in the rectangle from rectMin to rectMax, and the width
+// of each of its texels.
+func thumbnailLayout(size image.Point, rectMin, rectMax imgui.Vec2) (origin imgui.Vec2, texel float32) {
+	width, height := rectMax.X-rectMin.X, rectMax.Y-rectMin.Y
+	texel = min((width-2)/float32(size.X), (height-2)/float32(size.Y))
+	origin = imgui.Vec2{
+		X: rectMin.X + (width-texel*float32(size.X))/2,
+		Y: rectMin.Y + (height-texel*float32(size.Y))/2,
+	}
+	return
+}
+
+// truncateName shortens name with an ellipsis to fit in width.
+func truncateName(name string, width float32) string {
+	if imgui.CalcTextSize(name, false, 0).X <= width {
+		return name
+	}
+	runes := []rune(name)
+	for len(runes) > 1 && imgui.CalcTextSize(string(runes)+"...", false, 0).X > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// browseStartDir is where choosing a folder to browse starts: the folder
+// being browsed, or else the active document's.
+func browseStartDir(b *fileBrowser, doc *document) string {
+	if b.folder != "" {
+		return b.folder
+	}
+	if doc.hasImageFile() {
+		return filepath.Dir(doc.fileName)
+	}
+	return ""
+}
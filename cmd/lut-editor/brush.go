@@ -0,0 +1,177 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/tablet"
+)
+
+// brushShape is the outline of the draw and erase tools' brush.
+type brushShape int
+
+const (
+	brushSquare brushShape = iota
+	brushCircle
+)
+
+// pressureMode is what the pressure of a tablet's stylus changes when
+// drawing.
+type pressureMode int
+
+const (
+	pressureOff pressureMode = iota
+	// pressureOpacity scales how much of the color each dab gives, as if the
+	// brush were softer everywhere
+	pressureOpacity
+	// pressureValue scales the color drawn, so pressing lightly draws
+	// smaller values
+	pressureValue
+)
+
+// maxBrushSize is the widest brush, in texels.
+const maxBrushSize = 32
+
+// brushSettings are the draw tool's choices in the Tool window.
+type brushSettings struct {
+	// size is the width of the brush in texels
+	size  int32
+	shape brushShape
+	// hardness is the part of the brush's radius that takes all of the color.
+	// Outside it the color fades out towards the edge.
+	hardness float32
+	// opacity is the most of the color a stroke gives a texel
+	opacity float32
+	// flow is how much of the color each pass over a texel adds
+	flow float32
+	// eraseAlphaOnly makes the erase tool clear only alpha
+	eraseAlphaOnly bool
+	pressure       pressureMode
+}
+
+func defaultBrushSettings() brushSettings {
+	return brushSettings{size: 1, hardness: 1, opacity: 1, flow: 1, pressure: pressureOpacity}
+}
+
+// dab returns the texels the brush covers when centered on p, and how much of
+// the color each takes. Even sized brushes reach further up and left of p.
+func (b brushSettings) dab(p image.Point) ([]image.Point, []float64) {
+	size := int(min(max(b.size, 1), maxBrushSize))
+	first := -(size - 1) / 2
+	// Texels are measured from the middle of the brush, which falls between
+	// texels for even sizes
+	middle := float64(first) + float64(size-1)/2
+	radius := float64(size) / 2
+	points := make([]image.Point, 0, size*size)
+	coverage := make([]float64, 0, size*size)
+	for dy := first; dy < first+size; dy++ {
+		for dx := first; dx < first+size; dx++ {
+			x, y := float64(dx)-middle, float64(dy)-middle
+			distance := max(math.Abs(x), math.Abs(y))
+			if b.shape == brushCircle {
+				distance = math.Hypot(x, y)
+			}
+			weight := brushFalloff(distance/radius, float64(b.hardness))
+			if weight <= 0 {
+				continue
+			}
+			points = append(points, p.Add(image.Pt(dx, dy)))
+			coverage = append(coverage, weight)
+		}
+	}
+	return points, coverage
+}
+
+// brushFalloff returns how much of the color a texel distance from the middle
+// of a brush takes, as a fraction of its radius.
+func brushFalloff(distance, hardness float64) float64 {
+	if distance > 1 {
+		return 0
+	}
+	if distance <= hardness {
+		return 1
+	}
+	return (1 - distance) / (1 - hardness)
+}
+
+func (b brushSettings) newStroke() *editor.Stroke {
+	return editor.NewStroke(float64(b.opacity), float64(b.flow))
+}
+
+// pressureScales returns how much the pressure of pen scales the coverage of
+// a dab and the color channels it draws, by the brush's pressure mode. The
+// mouse draws at full strength.
+func (b brushSettings) pressureScales(pen tablet.Pen) (coverage, value float64) {
+	switch b.pressure {
+	case pressureOpacity:
+		return pen.Weight(), 1
+	case pressureValue:
+		return 1, pen.Weight()
+	}
+	return 1, 1
+}
+
+// eraseLock returns the channels the erase tool leaves alone.
+func (b brushSettings) eraseLock() blend.Lock {
+	if b.eraseAlphaOnly {
+		return blend.Lock{true, true, true, false}
+	}
+	return blend.Lock{}
+}
+
+// drawBrushDab shades the texels a dab of brush centered on p covers, in
+// image coordinates, more strongly where they take more of the color.
+func drawBrushDab(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, height int, brush brushSettings, p image.Point) {
+	points, coverage := brush.dab(p)
+	shade := imdraw.New(nil)
+	var bounds image.Rectangle
+	for i, q := range points {
+		texel := image.Rectangle{Min: q, Max: q.Add(image.Pt(1, 1))}
+		bounds = bounds.Union(texel)
+		area := imageRectToSelection(texel, spriteCenter, height)
+		shade.Color = pixel.RGBA{R: 0.9, G: 0.9, B: 0.9, A: 1}.Scaled(0.35 * coverage[i])
+		shade.Push(area.Min, area.Max)
+		shade.Rectangle(0)
+	}
+	shade.Draw(win)
+	drawBrushPreview(win, camZoom, imageRectToSelection(bounds, spriteCenter, height))
+}
+
+// drawBrushSliders edits the brush settings.
+func drawBrushSliders(brush *brushSettings) {
+	imgui.SliderIntV("Size", &brush.size, 1, maxBrushSize, "%d px", imgui.SliderFlagsAlwaysClamp)
+	imgui.Text("Shape")
+	imgui.SameLine()
+	imgui.RadioButtonInt("Square", (*int)(&brush.shape), int(brushSquare))
+	imgui.SameLine()
+	imgui.RadioButtonInt("Circle", (*int)(&brush.shape), int(brushCircle))
+	imgui.SliderFloatV("Hardness", &brush.hardness, 0, 1, "%.2f", imgui.SliderFlagsAlwaysClamp)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("How far out from the middle the brush gives the full color, before fading out to its edge")
+	}
+	imgui.SliderFloatV("Opacity", &brush.opacity, 0, 1, "%.2f", imgui.SliderFlagsAlwaysClamp)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("The most of the color one stroke gives a texel")
+	}
+	imgui.SliderFloatV("Flow", &brush.flow, 0.01, 1, "%.2f", imgui.SliderFlagsAlwaysClamp)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("How much of the color each pass over a texel adds, up to the opacity")
+	}
+	imgui.Checkbox("Erase Alpha Only", &brush.eraseAlphaOnly)
+	imgui.Text("Pen Pressure")
+	imgui.SameLine()
+	imgui.RadioButtonInt("Off##pressure", (*int)(&brush.pressure), int(pressureOff))
+	imgui.SameLine()
+	imgui.RadioButtonInt("Opacity##pressure", (*int)(&brush.pressure), int(pressureOpacity))
+	imgui.SameLine()
+	imgui.RadioButtonInt("Value", (*int)(&brush.pressure), int(pressureValue))
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Pressing a tablet's pen lightly draws smaller values, towards zero")
+	}
+}
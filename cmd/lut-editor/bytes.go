@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/x448/float16"
+)
+
+// pixelLayout describes how the pixels of an image are stored in its Pix
+// slice.
+type pixelLayout struct {
+	pix      []uint8
+	rect     image.Rectangle
+	stride   int
+	channels []string
+	// valueType is "float", "half" or "uint", each channel taking size bytes
+	// in order
+	valueType string
+	size      int
+	order     binary.ByteOrder
+}
+
+func imagePixelLayout(img image.Image) (pixelLayout, bool) {
+	if ddsImg, ok := img.(*dds.DDS); ok {
+		img = ddsImg.Image
+	}
+	rgba := []string{"R", "G", "B", "A"}
+	switch m := img.(type) {
+	case *hdrColors.NRGBA128FImage:
+		return pixelLayout{m.Pix, m.Rect, m.Stride, rgba, "float", 4, binary.LittleEndian}, true
+	case *hdrColors.NRGBA64FImage:
+		return pixelLayout{m.Pix, m.Rect, m.Stride, rgba, "half", 2, binary.LittleEndian}, true
+	case *hdrColors.NRGBA128UImage:
+		return pixelLayout{m.Pix, m.Rect, m.Stride, rgba, "uint", 4, binary.LittleEndian}, true
+	case *image.NRGBA:
+		return pixelLayout{m.Pix, m.Rect, m.Stride, rgba, "uint", 1, binary.BigEndian}, true
+	case *image.NRGBA64:
+		return pixelLayout{m.Pix, m.Rect, m.Stride, rgba, "uint", 2, binary.BigEndian}, true
+	case *image.Gray:
+		return pixelLayout{m.Pix, m.Rect, m.Stride, []string{"Y"}, "uint", 1, binary.BigEndian}, true
+	case *image.Gray16:
+		return pixelLayout{m.Pix, m.Rect, m.Stride, []string{"Y"}, "uint", 2, binary.BigEndian}, true
+	}
+	return pixelLayout{}, false
+}
+
+func (l pixelLayout) pixelBytes() int {
+	return len(l.channels) * l.size
+}
+
+func (l pixelLayout) offset(p image.Point) int {
+	return (p.Y-l.rect.Min.Y)*l.stride + (p.X-l.rect.Min.X)*l.pixelBytes()
+}
+
+func (l pixelLayout) typeName() string {
+	return fmt.Sprintf("%s%d", l.valueType, 8*l.size)
+}
+
+// value decodes one channel's bytes in the given byte order.
+func (l pixelLayout) value(b []byte, order binary.ByteOrder) string {
+	switch {
+	case l.size == 1:
+		return fmt.Sprint(b[0])
+	case l.valueType == "half":
+		return fmt.Sprintf("%g", float16.Frombits(order.Uint16(b)).Float32())
+	case l.valueType == "float":
+		return fmt.Sprintf("%g", math.Float32frombits(order.Uint32(b)))
+	case l.size == 2:
+		return fmt.Sprint(order.Uint16(b))
+	}
+	return fmt.Sprint(order.Uint32(b))
+}
+
+// bits formats the value in b as binary, most significant bit first. Floats
+// are split into sign, exponent and mantissa.
+func (l pixelLayout) bits(b []byte) string {
+	var v uint64
+	switch l.size {
+	case 1:
+		v = uint64(b[0])
+	case 2:
+		v = uint64(l.order.Uint16(b))
+	default:
+		v = uint64(l.order.Uint32(b))
+	}
+	s := fmt.Sprintf("%0*b", 8*l.size, v)
+	switch l.valueType {
+	case "float":
+		return s[:1] + " " + s[1:9] + " " + s[9:]
+	case "half":
+		return s[:1] + " " + s[1:6] + " " + s[6:]
+	}
+	var groups []string
+	for i := 0; i < len(s); i += 8 {
+		groups = append(groups, s[i:i+8])
+	}
+	return strings.Join(groups, " ")
+}
+
+func hexBytes(b []byte) string {
+	var parts []string
+	for _, v := range b {
+		parts = append(parts, fmt.Sprintf("%02X", v))
+	}
+	return strings.Join(parts, " ")
+}
+
+// drawBytesWindow shows the bytes stored for the texel under the mouse at
+// (hovX, hovY), or the last one hovered while the mouse is elsewhere.
+func drawBytesWindow(img image.Image, inspected *image.Point, hovX, hovY int, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 520, Y: 240}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Bytes", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+	if img == nil {
+		textDisabled("No image")
+		return
+	}
+	layout, ok := imagePixelLayout(img)
+	if !ok {
+		textDisabled(fmt.Sprintf("Unsupported image type %T", img))
+		return
+	}
+	if hovered := image.Pt(hovX, hovY); hovered.In(layout.rect) && !imgui.CurrentIO().WantCaptureMouse() {
+		*inspected = hovered
+	}
+	if !inspected.In(layout.rect) {
+		textDisabled("Hover over a texel")
+		return
+	}
+
+	offset := layout.offset(*inspected)
+	pix := layout.pix[offset : offset+layout.pixelBytes()]
+	imgui.Text(fmt.Sprintf("Texel (%d, %d): offset 0x%X (%d) of %d bytes", inspected.X, inspected.Y, offset, offset, len(layout.pix)))
+	endianness := "little-endian"
+	if layout.order == binary.BigEndian {
+		endianness = "big-endian"
+	}
+	imgui.Text(fmt.Sprintf("%d channels of %s, %s, stride %d", len(layout.channels), layout.typeName(), endianness, layout.stride))
+	imgui.Text(hexBytes(pix))
+
+	// The swapped value tells whether data was read with the wrong byte order
+	var swapped binary.ByteOrder = binary.BigEndian
+	if layout.order == binary.BigEndian {
+		swapped = binary.LittleEndian
+	}
+	tableFlags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable
+	if imgui.BeginTableV("BytesTable", 5, tableFlags, imgui.Vec2{}, 0) {
+		for _, column := range []string{"Channel", "Bytes", "Value", "Byte Swapped", "Bits"} {
+			imgui.TableSetupColumn(column)
+		}
+		imgui.TableHeadersRow()
+		for i, name := range layout.channels {
+			b := pix[i*layout.size : (i+1)*layout.size]
+			swappedValue := "-"
+			if layout.size > 1 {
+				swappedValue = layout.value(b, swapped)
+			}
+			imgui.TableNextRow()
+			for _, value := range []string{name, hexBytes(b), layout.value(b, layout.order), swappedValue, layout.bits(b)} {
+				imgui.TableNextColumn()
+				imgui.Text(value)
+			}
+		}
+		imgui.EndTable()
+	}
+}
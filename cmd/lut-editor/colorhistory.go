@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/prefs"
+)
+
+// useColor puts c at the front of the document's recently used colors, and
+// keeps them in store under the image's path so they come back when it is
+// opened again.
+func (d *document) useColor(store prefs.RecentColors, c [4]float32) error {
+	if len(d.recentColors) > 0 && d.recentColors[0] == c {
+		return nil
+	}
+	d.recentColors = prefs.AddRecentColor(d.recentColors, c)
+	if !d.hasImageFile() {
+		return nil
+	}
+	path, err := filepath.Abs(d.fileName)
+	if err != nil {
+		return err
+	}
+	store[path] = d.recentColors
+	return prefs.SaveRecentColors(store)
+}
+
+// restoreRecentColors takes the colors kept in store for the document's
+// image, unless its project already gave it some.
+func (d *document) restoreRecentColors(store prefs.RecentColors) {
+	if len(d.recentColors) > 0 || !d.hasImageFile() {
+		return
+	}
+	if path, err := filepath.Abs(d.fileName); err == nil {
+		d.recentColors = store[path]
+	}
+}
+
+// drawRecentColors adds swatches of the recently used colors to the Color
+// window. Clicking one makes it the current color.
+func drawRecentColors(recent [][4]float32, currColor *[4]float32) {
+	if len(recent) == 0 {
+		return
+	}
+	imgui.Separator()
+	imgui.Text("Recent")
+	for i, c := range recent {
+		if i%8 != 0 {
+			imgui.SameLine()
+		}
+		// The button's tooltip shows the color's values
+		if imgui.ColorButton(fmt.Sprintf("##recent%d", i), imgui.Vec4{X: c[0], Y: c[1], Z: c[2], W: c[3]}, imgui.ColorEditFlagsHDR|imgui.ColorEditFlagsAlphaPreview, imgui.Vec2{}) {
+			*currColor = c
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixelui/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/script"
+)
+
+// consoleLine is a line of the console's output: a command that was run, or
+// what it printed.
+type consoleLine struct {
+	text    string
+	command bool
+	err     bool
+}
+
+// console is the state of the Console window.
+type console struct {
+	input   string
+	lines   []consoleLine
+	history []string
+	// historyPos is the history entry shown in the input, or len(history)
+	// for a new command
+	historyPos int
+	// generation changes the input's ID when its text is replaced, as imgui
+	// keeps its own copy of the text while the input is being edited
+	generation int
+	focus      bool
+	active     bool
+	scroll     bool
+	// selected is set when a command changes the selection
+	selected bool
+}
+
+// run runs line with commands and records it and its output.
+func (c *console) run(commands script.Commands, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	c.lines = append(c.lines, consoleLine{text: line, command: true})
+	if len(c.history) == 0 || c.history[len(c.history)-1] != line {
+		c.history = append(c.history, line)
+	}
+	c.historyPos = len(c.history)
+	out, err := commands.Run(line)
+	if err != nil {
+		c.lines = append(c.lines, consoleLine{text: err.Error(), err: true})
+	} else if out != "" {
+		for _, text := range strings.Split(out, "\n") {
+			c.lines = append(c.lines, consoleLine{text: text})
+		}
+	}
+	c.scroll = true
+}
+
+// browse shows the previous (step -1) or next (step 1) command in the input.
+func (c *console) browse(step int) {
+	c.historyPos = min(max(c.historyPos+step, 0), len(c.history))
+	c.input = ""
+	if c.historyPos < len(c.history) {
+		c.input = c.history[c.historyPos]
+	}
+	c.generation++
+	c.focus = true
+}
+
+// consoleHistoryStep returns the history step asked for with the arrow keys.
+func consoleHistoryStep(ui *pixelui.UI) int {
+	if ui.JustPressed(pixel.KeyUp) {
+		return -1
+	}
+	if ui.JustPressed(pixel.KeyDown) {
+		return 1
+	}
+	return 0
+}
+
+// commandTarget is what console commands work on: the document open in the
+// window, or an image opened by a script run with --run.
+type commandTarget interface {
+	// image returns the image being edited, or nil if none is open
+	image() image.Image
+	// selected returns the selected texels, or an empty rectangle
+	selected() image.Rectangle
+	selectRect(rect image.Rectangle)
+	// lock returns the channels edits must leave alone
+	lock() blend.Lock
+	// edit applies op, as the command name, so it can be undone
+	edit(name string, op editor.Operation) error
+}
+
+// commandRect returns the texels a command works on, given by the text
+// argument i: "sel" for the selection, "all" for the whole image, or by
+// default the selection if there is one.
+func commandRect(t commandTarget, call script.Call, i int) (image.Rectangle, error) {
+	target, err := call.Text(i, "", "")
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	switch target {
+	case "":
+		if rect := t.selected(); !rect.Empty() {
+			return rect, nil
+		}
+		return t.image().Bounds(), nil
+	case "sel":
+		rect := t.selected()
+		if rect.Empty() {
+			return image.Rectangle{}, fmt.Errorf("%s: nothing is selected", call.Name)
+		}
+		return rect, nil
+	case "all":
+		return t.image().Bounds(), nil
+	}
+	return image.Rectangle{}, fmt.Errorf("%s: target must be sel or all, not %s", call.Name, target)
+}
+
+// consoleColor reads the color arguments from position i: r, g and b, and
+// optionally a. Channels that aren't given are locked.
+func consoleColor(call script.Call, i int) ([4]float64, blend.Lock, error) {
+	var c [4]float64
+	var lock blend.Lock
+	for j := range c {
+		if j == 3 && !call.Has(i+j, "") {
+			lock[3] = true
+			break
+		}
+		v, err := call.Number(i+j, "")
+		if err != nil {
+			return c, lock, err
+		}
+		c[j] = v
+	}
+	return c, lock, nil
+}
+
+// editCommands are the commands that read and edit t's image. Edits can be
+// undone, and leave locked channels and texels alone.
+func editCommands(t commandTarget) script.Commands {
+	commands := script.Commands{
+		{
+			Name:  "fill",
+			Usage: "fill(target, r, g, b[, a])",
+			Help:  "set the texels of target (sel or all) to a color, keeping alpha if a is left out",
+			Run: func(call script.Call) (string, error) {
+				rect, err := commandRect(t, call, 0)
+				if err != nil {
+					return "", err
+				}
+				color, lock, err := consoleColor(call, 1)
+				if err != nil {
+					return "", err
+				}
+				err = t.edit("fill", editor.Fill{Rect: rect, Color: color, Lock: lock.Or(t.lock())})
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Filled %d texels", rect.Dx()*rect.Dy()), nil
+			},
+		},
+		{
+			Name:  "stats",
+			Usage: "stats([target], ch=\"RGBA\")",
+			Help:  "show the minimum, maximum and mean of channels over target",
+			Run: func(call script.Call) (string, error) {
+				rect, err := commandRect(t, call, 0)
+				if err != nil {
+					return "", err
+				}
+				channels, err := call.Text(-1, "ch", "RGBA")
+				if err != nil {
+					return "", err
+				}
+				raw, ok := rawImage(t.image())
+				if !ok || rect.Empty() {
+					return "", fmt.Errorf("stats: no texels")
+				}
+				var lines []string
+				for _, ch := range strings.ToUpper(channels) {
+					i := strings.IndexRune("RGBA", ch)
+					if i < 0 {
+						return "", fmt.Errorf("stats: unknown channel %c", ch)
+					}
+					lo, hi, sum := math.Inf(1), math.Inf(-1), 0.0
+					for y := rect.Min.Y; y < rect.Max.Y; y++ {
+						for x := rect.Min.X; x < rect.Max.X; x++ {
+							v := raw.RawAt(x, y)[i]
+							lo, hi, sum = min(lo, v), max(hi, v), sum+v
+						}
+					}
+					lines = append(lines, fmt.Sprintf("%c: min %g, max %g, mean %g", ch, lo, hi, sum/float64(rect.Dx()*rect.Dy())))
+				}
+				return strings.Join(lines, "\n"), nil
+			},
+		},
+		{
+			Name:  "get",
+			Usage: "get(x, y)",
+			Help:  "show the stored values of a texel",
+			Run: func(call script.Call) (string, error) {
+				p, err := consolePoint(t, call)
+				if err != nil {
+					return "", err
+				}
+				raw, ok := rawImage(t.image())
+				if !ok {
+					return "", fmt.Errorf("get: not an HDR image")
+				}
+				v := raw.RawAt(p.X, p.Y)
+				return fmt.Sprintf("(%d, %d): %g, %g, %g, %g", p.X, p.Y, v[0], v[1], v[2], v[3]), nil
+			},
+		},
+		{
+			Name:  "set",
+			Usage: "set(x, y, r, g, b[, a])",
+			Help:  "set a texel to a color, keeping alpha if a is left out",
+			Run: func(call script.Call) (string, error) {
+				p, err := consolePoint(t, call)
+				if err != nil {
+					return "", err
+				}
+				color, lock, err := consoleColor(call, 2)
+				if err != nil {
+					return "", err
+				}
+				return "", t.edit("set", editor.Draw{Points: []image.Point{p}, Color: color, Lock: lock.Or(t.lock())})
+			},
+		},
+		{
+			Name:  "select",
+			Usage: "select(x, y, w, h)",
+			Help:  "select w by h texels from (x, y), or everything with select(all)",
+			Run: func(call script.Call) (string, error) {
+				rect := t.image().Bounds()
+				if target, err := call.Text(0, "", ""); err != nil || target != "all" {
+					var v [4]float64
+					for i := range v {
+						if v[i], err = call.Number(i, ""); err != nil {
+							return "", err
+						}
+					}
+					rect = image.Rect(int(v[0]), int(v[1]), int(v[0]+v[2]), int(v[1]+v[3])).Intersect(t.image().Bounds())
+				}
+				if rect.Empty() {
+					return "", fmt.Errorf("select: the area is outside the image")
+				}
+				t.selectRect(rect)
+				return fmt.Sprintf("Selected %dx%d texels at (%d, %d)", rect.Dx(), rect.Dy(), rect.Min.X, rect.Min.Y), nil
+			},
+		},
+	}
+	for i := range commands {
+		run := commands[i].Run
+		commands[i].Run = func(call script.Call) (string, error) {
+			if t.image() == nil {
+				return "", fmt.Errorf("%s: no image is open", call.Name)
+			}
+			return run(call)
+		}
+	}
+	return commands
+}
+
+// consolePoint reads a texel position from the first two arguments.
+func consolePoint(t commandTarget, call script.Call) (image.Point, error) {
+	x, err := call.Number(0, "x")
+	if err != nil {
+		return image.Point{}, err
+	}
+	y, err := call.Number(1, "y")
+	if err != nil {
+		return image.Point{}, err
+	}
+	p := image.Pt(int(x), int(y))
+	if !p.In(t.image().Bounds()) {
+		return image.Point{}, fmt.Errorf("%s: (%d, %d) is outside the image", call.Name, p.X, p.Y)
+	}
+	return p, nil
+}
+
+// documentTarget runs console commands on the document open in the window.
+type documentTarget struct {
+	doc         *document
+	console     *console
+	currColor   [4]float32
+	channelLock blend.Lock
+}
+
+func (t *documentTarget) image() image.Image {
+	return t.doc.img
+}
+
+func (t *documentTarget) selected() image.Rectangle {
+	if t.doc.selection.Area() == 0 || t.doc.pasteImg != nil {
+		return image.Rectangle{}
+	}
+	return selectionToImageRect(t.doc.selection, t.doc.sprite.Frame().Center(), t.doc.img.Bounds().Dy()).Intersect(t.doc.img.Bounds())
+}
+
+func (t *documentTarget) selectRect(rect image.Rectangle) {
+	t.doc.selection = imageToSelectionRect(rect, t.doc.sprite.Frame().Center(), t.doc.img.Bounds().Dy())
+	t.console.selected = true
+}
+
+func (t *documentTarget) lock() blend.Lock {
+	return t.channelLock
+}
+
+func (t *documentTarget) edit(name string, op editor.Operation) error {
+	if _, ok := rawImage(t.doc.img); !ok {
+		return fmt.Errorf("%s: not an HDR image", name)
+	}
+	t.doc.undoStack.Push("Console: "+name, t.doc.fileName, t.doc.saved, t.doc.img, t.currColor, t.doc.selection)
+	fill, ok := op.(editor.Fill)
+	if !ok {
+		return t.doc.apply(op)
+	}
+	// Fills are made to the rows edited together as other edits are
+	for _, rect := range t.doc.multiRowRects(fill.Rect) {
+		fill.Rect = rect
+		if err := t.doc.apply(fill); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consoleCommands are the commands the console runs on doc.
+func consoleCommands(doc *document, c *console, currColor [4]float32, channelLock blend.Lock) script.Commands {
+	return editCommands(&documentTarget{doc: doc, console: c, currColor: currColor, channelLock: channelLock})
+}
+
+// drawConsoleWindow shows the output of the commands run so far and an input
+// for the next one. historyStep moves through earlier commands while the input
+// is being edited.
+func drawConsoleWindow(c *console, commands script.Commands, historyStep int, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 520, Y: 300}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Console", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+	imgui.BeginChildV("##output", imgui.Vec2{Y: -imgui.FrameHeightWithSpacing()}, true, 0)
+	for _, line := range c.lines {
+		switch {
+		case line.command:
+			textDisabled("> " + line.text)
+		case line.err:
+			imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+			imgui.Text(line.text)
+			imgui.PopStyleColor()
+		default:
+			imgui.Text(line.text)
+		}
+	}
+	if c.scroll {
+		imgui.SetScrollHereY(1)
+		c.scroll = false
+	}
+	imgui.EndChild()
+
+	if c.active && historyStep != 0 {
+		c.browse(historyStep)
+	}
+	if c.focus {
+		imgui.SetKeyboardFocusHere()
+		c.focus = false
+	}
+	imgui.PushIDInt(c.generation)
+	if imgui.InputTextWithHintV("##command", "help() lists the commands", &c.input, imgui.InputTextFlagsEnterReturnsTrue, nil) {
+		c.run(commands, c.input)
+		c.input = ""
+		c.generation++
+		c.focus = true
+	}
+	c.active = imgui.IsItemActive()
+	imgui.PopID()
+}
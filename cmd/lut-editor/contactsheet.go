@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/preview"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+	"github.com/sqweek/dialog"
+)
+
+// exportContactSheet asks for a folder and a PNG to write, then renders a
+// labeled thumbnail of each DDS and EXR file in the folder to it.
+func exportContactSheet(prt *app.Printer, startDir string, task *types.BackgroundStatus) {
+	folder, err := dialog.Directory().Title("Select folder for contact sheet...").SetStartDir(startDir).Browse()
+	if err == dialog.ErrCancelled {
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("contact sheet: failed to get directory: %v", err)
+		task.OnCancel()
+		return
+	}
+	files, err := listTextures(folder)
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("no DDS or EXR files")
+	}
+	if err != nil {
+		prt.Errorf("contact sheet: failed to list '%s': %v", folder, err)
+		task.OnCancel()
+		return
+	}
+	outFileName, err := dialog.File().Title("Export Contact Sheet").Filter("PNG files", "png").SetStartDir(folder).SetStartFile(filepath.Base(folder) + ".png").Save()
+	if err == dialog.ErrCancelled {
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		task.OnCancel()
+		return
+	}
+	if filepath.Ext(outFileName) == "" {
+		outFileName += ".png"
+	}
+
+	opts := preview.DefaultSheetOptions()
+	entries := make([]preview.SheetEntry, len(files))
+	failed := 0
+	for i, file := range files {
+		entries[i].Name = filepath.Base(file.path)
+		img, _, err := loadImage(file.path)
+		if err != nil {
+			prt.Errorf("contact sheet: failed to load '%s': %v", file.path, err)
+			failed++
+		} else {
+			entries[i].Thumbnail = opts.Thumbnail(img)
+		}
+		task.OnProgress(i+1, len(files), err)
+	}
+
+	out, err := os.Create(outFileName)
+	if err == nil {
+		err = preview.WriteContactSheetPNG(out, entries, opts)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		prt.Errorf("contact sheet: failed to write '%s': %v", outFileName, err)
+		task.OnError(err)
+		return
+	}
+	task.OnComplete(len(files)-failed, failed, len(files))
+	prt.Infof("Exported a contact sheet of %d files to '%s'", len(files), outFileName)
+}
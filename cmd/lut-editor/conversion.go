@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/precision"
+)
+
+// conversionSettings choose the pixel type images are saved in, and which
+// values to report if converting to it loses precision.
+type conversionSettings struct {
+	// Precision is a precision.Format
+	Precision int
+	// Threshold is the error above which converted values are listed
+	Threshold float32
+	// MipMaps writes DDS files with a full mip chain, made with MipFilter, a
+	// dds.MipFilter
+	MipMaps   bool
+	MipFilter int
+}
+
+func defaultConversionSettings() conversionSettings {
+	return conversionSettings{
+		Precision: int(precision.Keep),
+		Threshold: precision.DefaultThreshold,
+	}
+}
+
+// ddsOptions returns what DDS files are written with.
+func (s conversionSettings) ddsOptions() dds.WriteHDROptions {
+	return dds.WriteHDROptions{MipMaps: s.MipMaps, Filter: dds.MipFilter(s.MipFilter)}
+}
+
+// convertedFile is the precision lost converting one file.
+type convertedFile struct {
+	Path string
+	precision.Report
+}
+
+// conversionReport lists the files that lost precision in a save or bulk
+// conversion.
+type conversionReport struct {
+	Title string
+	Files []convertedFile
+}
+
+// convertForSave converts img to the pixel type chosen in settings. The report
+// is nil if img already had that type.
+func convertForSave(img image.Image, settings conversionSettings) (image.Image, *precision.Report, error) {
+	converted, ok, err := precision.Convert(img, precision.Format(settings.Precision))
+	if err != nil || !ok {
+		return img, nil, err
+	}
+	report, err := precision.Measure(img, converted, float64(settings.Threshold))
+	if err != nil {
+		return nil, nil, err
+	}
+	return converted, &report, nil
+}
+
+// maxListedLosses limits the rows shown per file in the Precision Report
+// window.
+const maxListedLosses = 1000
+
+func drawConversionReportWindow(report *conversionReport, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 560, Y: 400}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Precision Report", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	imgui.Text(report.Title)
+	for i, file := range report.Files {
+		imgui.PushIDInt(i)
+		if imgui.CollapsingHeader(fmt.Sprintf("%s: %s", filepath.Base(file.Path), file.Summary())) {
+			tableFlags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable
+			if imgui.BeginTableV("LossTable", 6, tableFlags, imgui.Vec2{}, 0) {
+				for _, column := range []string{"X", "Y", "Channel", "Before", "After", "Error"} {
+					imgui.TableSetupColumn(column)
+				}
+				imgui.TableHeadersRow()
+				for _, loss := range file.Losses[:min(len(file.Losses), maxListedLosses)] {
+					if loss.Clipped {
+						imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+					}
+					imgui.TableNextRow()
+					for _, value := range []string{
+						fmt.Sprint(loss.X),
+						fmt.Sprint(loss.Y),
+						graphChannelNames[loss.Channel],
+						fmt.Sprintf("%g", loss.Before),
+						fmt.Sprintf("%g", loss.After),
+						fmt.Sprintf("%.3g", loss.Error()),
+					} {
+						imgui.TableNextColumn()
+						imgui.Text(value)
+					}
+					if loss.Clipped {
+						imgui.PopStyleColor()
+					}
+				}
+				imgui.EndTable()
+			}
+			if len(file.Losses) > maxListedLosses {
+				textDisabled(fmt.Sprintf("and %d more", len(file.Losses)-maxListedLosses))
+			}
+		}
+		imgui.PopID()
+	}
+}
+
+// reportConversion logs the precision lost saving path and, if any was, sends
+// it to reports for the Precision Report window.
+func reportConversion(prt *app.Printer, reports chan<- *conversionReport, path string, report precision.Report) {
+	prt.Infof("Converted '%s': %s", path, report.Summary())
+	if report.Lossless() {
+		return
+	}
+	reports <- &conversionReport{
+		Title: fmt.Sprintf("Saving %s", filepath.Base(path)),
+		Files: []convertedFile{{Path: path, Report: report}},
+	}
+}
+
+// drawConversionSettings adds the precision and mipmap choices to a dialog.
+func drawConversionSettings(settings *conversionSettings) {
+	imgui.Text("Precision")
+	for _, format := range precision.Formats {
+		imgui.SameLine()
+		imgui.RadioButtonInt(format.String(), &settings.Precision, int(format))
+	}
+	if settings.Precision != int(precision.Keep) {
+		imgui.DragFloatV("Report errors over", &settings.Threshold, 0.0001, 0.0, 0.0, "%.5f", imgui.SliderFlagsNone)
+		settings.Threshold = max(settings.Threshold, 0)
+	}
+	imgui.Checkbox("DDS mipmaps", &settings.MipMaps)
+	if settings.MipMaps {
+		for _, filter := range dds.MipFilters {
+			imgui.SameLine()
+			imgui.RadioButtonInt(filter.String(), &settings.MipFilter, int(filter))
+		}
+	}
+}
+
+func savePrecision(settings *conversionSettings, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.3 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = savePrecisionDialog(settings, windowSize, &responded)
+	return responded
+}
+
+func savePrecisionDialog(settings *conversionSettings, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Save precision", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	drawConversionSettings(settings)
+	textDisabled("Saves convert to this pixel type, and report what it loses. Mipmaps are\nfiltered from the image in linear space, for material slots the game samples mipped")
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("OK", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/precision"
+)
+
+// convertOptions are the arguments of the convert subcommand.
+type convertOptions struct {
+	// Inputs are paths or glob patterns of the files to convert
+	Inputs []string
+	// Output is the file to write a single input to, or the folder to write
+	// each output to. Outputs are written next to their inputs if it is empty.
+	Output string
+	// To is "dds" or "exr", or empty to convert EXR to DDS and DDS to EXR
+	To string
+	// Conversion sets the pixel type of the output files
+	Conversion conversionSettings
+	// NameTemplate names each output file, see expandOutputName
+	NameTemplate string
+}
+
+// precisionNames are the values of the convert subcommand's --format flag.
+var precisionNames = map[string]precision.Format{
+	"keep":  precision.Keep,
+	"float": precision.Float,
+	"half":  precision.Half,
+}
+
+// mipFilterNames are the values of the convert subcommand's --mipmaps flag,
+// and the mipmaps of export pipeline steps.
+var mipFilterNames = map[string]dds.MipFilter{
+	"box":    dds.MipFilterBox,
+	"kaiser": dds.MipFilterKaiser,
+}
+
+// isImageFileName reports whether path names a DDS or EXR file.
+func isImageFileName(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".dds" || ext == ".exr"
+}
+
+// expandConvertInputs returns the files matched by each of patterns, which
+// may be plain paths or glob patterns, as shells on Windows leave them
+// unexpanded. Files matched more than once are only returned the first time.
+func expandConvertInputs(patterns []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no such file", pattern)
+		}
+		for _, match := range matches {
+			if !seen[match] && isImageFileName(match) {
+				seen[match] = true
+				paths = append(paths, match)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no DDS or EXR files to convert")
+	}
+	return paths, nil
+}
+
+// outputPath returns where the conversion of inputPath is written. single is
+// set when inputPath is the only file converted.
+func (o convertOptions) outputPath(inputPath string, single bool) (string, error) {
+	var outExt string
+	switch {
+	case o.To != "":
+		outExt = "." + strings.ToLower(o.To)
+	case single && isImageFileName(o.Output):
+		outExt = strings.ToLower(filepath.Ext(o.Output))
+	case strings.EqualFold(filepath.Ext(inputPath), ".exr"):
+		outExt = ".dds"
+	default:
+		outExt = ".exr"
+	}
+	var outPath string
+	if isImageFileName(o.Output) {
+		if !single {
+			return "", fmt.Errorf("output %s is a file, but more than one file is being converted", o.Output)
+		}
+		if !strings.EqualFold(filepath.Ext(o.Output), outExt) {
+			return "", fmt.Errorf("output %s is not a %s file", o.Output, outExt)
+		}
+		outPath = o.Output
+	} else {
+		name, err := expandOutputName(o.NameTemplate, inputPath, outExt)
+		if err != nil {
+			return "", err
+		}
+		outDir := o.Output
+		if outDir == "" {
+			outDir = filepath.Dir(inputPath)
+		}
+		outPath = filepath.Join(outDir, name)
+	}
+	if filepath.Clean(outPath) == filepath.Clean(inputPath) {
+		return "", fmt.Errorf("output would overwrite input %v", inputPath)
+	}
+	return outPath, nil
+}
+
+// runConvert converts files for the convert subcommand, printing each file
+// written and the precision it lost. Files that fail are reported on standard
+// error, and the rest are still converted.
+func runConvert(opts convertOptions) error {
+	paths, err := expandConvertInputs(opts.Inputs)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, path := range paths {
+		outPath, err := convertFile(opts, path, len(paths) == 1)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s -> %s\n", path, outPath)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to convert", failed, len(paths))
+	}
+	return nil
+}
+
+// convertFile converts a single file, returning where it was written.
+func convertFile(opts convertOptions, path string, single bool) (string, error) {
+	outPath, err := opts.outputPath(path, single)
+	if err != nil {
+		return "", err
+	}
+	img, attrs, err := loadImage(path)
+	if err != nil {
+		return "", err
+	}
+	outImg, report, err := convertForSave(img, opts.Conversion)
+	if err != nil {
+		return "", err
+	}
+	if report != nil && !report.Lossless() {
+		fmt.Printf("%s: %s\n", path, report.Summary())
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return "", err
+	}
+	return outPath, writeImageFile(outImg, attrs, outPath, opts.Conversion.ddsOptions())
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"slices"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/colorspace"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+	"github.com/ryanjsims/hd2-lut-editor/icc"
+	"github.com/ryanjsims/hd2-lut-editor/mask"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/project"
+	"github.com/ryanjsims/hd2-lut-editor/provenance"
+	"github.com/ryanjsims/hd2-lut-editor/texhash"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+const defaultCamZoom float64 = 24.0
+
+// document holds the state of a single open image, shown as one editor tab.
+type document struct {
+	id       int
+	fileName string
+	// title names a document that has not been saved to disk yet
+	title          string
+	img            image.Image
+	saved          bool
+	refreshSprites bool
+	lastChannel    hdrColors.GraySetting
+	undoStack      types.UndoRedoStack
+	pic            *pixel.PictureData
+	sprite         *pixel.Sprite
+	// pyramid draws pic zoomed out
+	pyramid pyramid
+	// sampleLevel is the DDS array slice and mipmap colors are read from
+	sampleLevel ddsLevel
+	// recentColors are the colors last drawn with, newest first
+	recentColors [][4]float32
+	pasteImg     image.Image
+	// pasteLock locks channels pasteImg must not be applied to, on top of the
+	// channels locked in the Tool window
+	pasteLock       blend.Lock
+	pastePic        *pixel.PictureData
+	pasteSprite     *pixel.Sprite
+	selection       pixel.Rect
+	selectionStart  pixel.Vec
+	selectionEnd    pixel.Vec
+	selectionOffset pixel.Vec
+	camPos          pixel.Vec
+	camZoom         float64
+	// A/B snapshots for flicker comparison, and which one is being displayed
+	// in place of the live image (-1 for none)
+	snapshots     [2]*types.UndoRedoState
+	comparing     int
+	compareSprite *pixel.Sprite
+	// attributes are extra EXR header attributes written back on save
+	attributes []openexr.Attribute
+	// baseline is the file change reports compare against, usually the
+	// unmodified game LUT
+	baseline string
+	// projectFile is the .lutproj the document was opened from or last saved
+	// to, and the fields below are the session state kept in it
+	projectFile     string
+	embedImage      bool
+	notes           string
+	rowNames        []string
+	columnNames     []string
+	savedSelections []project.Selection
+	// locked texels are left alone by edits
+	locked mask.Mask
+	// projectView holds view settings from an opened project until the main
+	// loop applies them
+	projectView *project.View
+	// committed is the file as committed in git, set by Tools > Diff Against
+	// HEAD. committedChanges caches the texels that differ from it until the
+	// picture changes
+	committed        hdrColors.RawImage
+	committedPic     *pixel.PictureData
+	committedChanges []image.Point
+	// schema describes the image's layout, if a known one fits it. violations
+	// caches the values outside its limits until the picture changes
+	schema        *help.Schema
+	violationsPic *pixel.PictureData
+	violations    []help.Violation
+	// rowFilter dims rows not matching View > Row Filter
+	rowFilter rowFilter
+	// hiddenGroups are the indexes of the schema's column groups covered up
+	// in the view
+	hiddenGroups map[int]bool
+	// multiRows are the rows edited together: an edit to some columns of one
+	// of them is made to the same columns of all of them
+	multiRows map[int]bool
+	// hash is the content hash of the picture hashPic was made from
+	hash    texhash.Sum
+	hashPic *pixel.PictureData
+	// provenance records which edit last changed each texel, while
+	// Tools > Track Texel Provenance is on. provenanceRedo is the length of
+	// the redo stack when it last recorded, to tell undos and redos apart
+	// from edits
+	provenance     provenance.Map
+	provenanceRedo int
+	// layers are the layers the image is blended from, bottom first, or nil
+	// for a single layer. activeLayer is the one in img, and layerChange
+	// names the last change to the stack, which has no undo step
+	layers      []docLayer
+	activeLayer int
+	layerChange string
+}
+
+var snapshotNames = [2]string{"A", "B"}
+
+var nextDocumentID int
+
+func newDocument(fileName string, img image.Image, saved bool) *document {
+	nextDocumentID++
+	return &document{
+		id:             nextDocumentID,
+		fileName:       fileName,
+		img:            img,
+		saved:          saved,
+		refreshSprites: img != nil,
+		lastChannel:    hdrColors.GraySettingNone,
+		undoStack: types.UndoRedoStack{
+			UndoStack: make([]types.UndoRedoState, 0),
+			RedoStack: make([]types.UndoRedoState, 0),
+		},
+		selection: pixel.ZR,
+		camPos:    pixel.ZV,
+		camZoom:   defaultCamZoom,
+		comparing: -1,
+	}
+}
+
+// empty reports whether the document is a placeholder that can be replaced
+// by the next opened image without losing anything.
+func (d *document) empty() bool {
+	return d.img == nil && d.saved
+}
+
+// tabLabel returns the imgui label for the document's tab. The id suffix keeps
+// labels unique when two tabs show the same file name.
+func (d *document) tabLabel() string {
+	return fmt.Sprintf("%s##doc%d", d.displayName(), d.id)
+}
+
+func (d *document) displayName() string {
+	switch {
+	case d.fileName == "(new)" && d.title != "":
+		return d.title
+	case d.fileName == "":
+		return "(empty)"
+	case d.fileName == "(new)":
+		return d.fileName
+	}
+	return filepath.Base(d.fileName)
+}
+
+func (d *document) storeSnapshot(slot int, currColor [4]float32) {
+	d.setSnapshot(slot, "Snapshot "+snapshotNames[slot], d.img, currColor)
+}
+
+// setSnapshot stores img, which need not be the document's image, in
+// snapshot slot.
+func (d *document) setSnapshot(slot int, action string, img image.Image, currColor [4]float32) {
+	state := types.NewUndoRedoState(action, d.fileName, d.saved, img, currColor, d.selection)
+	d.snapshots[slot] = &state
+	if d.comparing == slot {
+		d.comparing = -1
+	}
+}
+
+func (d *document) hasSnapshots() [2]bool {
+	return [2]bool{d.snapshots[0] != nil, d.snapshots[1] != nil}
+}
+
+// nextSnapshot returns the snapshot to flip to: the other of A and B when both
+// are stored, otherwise between the single stored snapshot and the live image.
+func (d *document) nextSnapshot() int {
+	switch {
+	case d.snapshots[0] != nil && d.snapshots[1] != nil:
+		return 1 - max(d.comparing, 0)
+	case d.comparing >= 0:
+		return -1
+	case d.snapshots[0] != nil:
+		return 0
+	case d.snapshots[1] != nil:
+		return 1
+	}
+	return -1
+}
+
+// showSnapshot displays snapshot slot in place of the live image, or returns
+// to the live image if slot is -1.
+func (d *document) showSnapshot(slot int, channel hdrColors.GraySetting) error {
+	d.comparing = -1
+	if slot < 0 || d.snapshots[slot] == nil {
+		return nil
+	}
+	img, err := d.snapshots[slot].Image()
+	if err != nil {
+		return err
+	}
+	if img == nil {
+		return nil
+	}
+	if grayable, ok := img.(hdrColors.Grayable); ok {
+		grayable.SetGray(channel)
+	}
+	pic := pixel.PictureDataFromImage(img)
+	if d.compareSprite != nil {
+		d.compareSprite.Set(pic, pic.Bounds())
+	} else {
+		d.compareSprite = pixel.NewSprite(pic, pic.Bounds())
+	}
+	d.comparing = slot
+	return nil
+}
+
+// displayTransform returns the conversion from the primaries described by the
+// document's chromaticities attribute to the sRGB primaries the display is
+// assumed to use. It reports false if no conversion is needed.
+func (d *document) displayTransform() (colorspace.Mat3, bool) {
+	colorimetry := openexr.ColorimetryFromAttributes(d.attributes)
+	c := colorimetry.Chromaticities
+	if c == nil {
+		return colorspace.Identity, false
+	}
+	primaries := colorspace.Primaries{
+		Red:   colorspace.XY{X: float64(c.RedX), Y: float64(c.RedY)},
+		Green: colorspace.XY{X: float64(c.GreenX), Y: float64(c.GreenY)},
+		Blue:  colorspace.XY{X: float64(c.BlueX), Y: float64(c.BlueY)},
+		White: colorspace.XY{X: float64(c.WhiteX), Y: float64(c.WhiteY)},
+	}
+	neutral := primaries.White
+	if n := colorimetry.AdoptedNeutral; n != nil {
+		neutral = colorspace.XY{X: float64(n[0]), Y: float64(n[1])}
+	}
+	m := colorspace.Conversion(primaries, neutral, colorspace.Rec709)
+	if m.IsIdentity(1e-4) {
+		return colorspace.Identity, false
+	}
+	return m, true
+}
+
+// editRect returns the area whole-area operations like filters apply to: the
+// selection, or the whole image if nothing is selected. The second result
+// names which one it is for dialogs.
+func (d *document) editRect() (image.Rectangle, string) {
+	if d.selection != pixel.ZR && d.pasteImg == nil {
+		return selectionToImageRect(d.selection, d.sprite.Frame().Center(), d.img.Bounds().Dy()), "selection"
+	}
+	return d.img.Bounds(), "image"
+}
+
+// apply makes the edit op to the document's image, leaving locked texels
+// alone.
+func (d *document) apply(op editor.Operation) error {
+	edited := editor.Document{Image: d.img, Locked: &d.locked}
+	if _, err := edited.Apply(op); err != nil {
+		return err
+	}
+	d.saved = false
+	d.refreshSprites = true
+	return nil
+}
+
+// combinePasted blends the pasted or moved texels into the image under the
+// selection.
+func (d *document) combinePasted(mode blend.Mode, lock blend.Lock) error {
+	rect := selectionToImageRect(d.selection, d.sprite.Frame().Center(), d.img.Bounds().Dy())
+	keep := d.keepRelations(lock.Or(d.pasteLock))
+	defer keep()
+	return d.apply(editor.Combine{Rect: rect, Src: d.pasteImg, Mode: mode, Lock: lock.Or(d.pasteLock)})
+}
+
+// protectLocked returns a function that undoes any change made to the locked
+// texels since protectLocked was called.
+func (d *document) protectLocked() (restore func()) {
+	raw, ok := rawImage(d.img)
+	if !ok || d.locked.Len() == 0 {
+		return func() {}
+	}
+	return d.locked.Protect(raw)
+}
+
+// protectEdits is protectLocked, also correcting the texels changed since it
+// was called that break relations the schema enforces, leaving channels in
+// lock alone.
+func (d *document) protectEdits(lock blend.Lock) (restore func()) {
+	restoreLocked, keep := d.protectLocked(), d.keepRelations(lock)
+	return func() {
+		restoreLocked()
+		keep()
+	}
+}
+
+// colorManagement describes how documents are converted for presentation.
+type colorManagement struct {
+	// enabled is false to show the stored values unconverted
+	enabled bool
+	// display converts from sRGB to the monitor's color space, or is nil if
+	// the monitor is assumed to be sRGB
+	display     *icc.Display
+	profileName string
+	// encodeSRGB treats stored values as linear and sRGB encodes them for
+	// display, instead of showing them as they are stored
+	encodeSRGB bool
+}
+
+func (cm *colorManagement) setProfile(profile *icc.Profile, name string) {
+	if profile == nil {
+		cm.display, cm.profileName = nil, ""
+		return
+	}
+	cm.display, cm.profileName = profile.FromSRGB(), name
+}
+
+// sRGB returns cm without the monitor conversion, for images written to files
+// or the clipboard, which other programs expect to be sRGB.
+func (cm colorManagement) sRGB() colorManagement {
+	cm.display, cm.profileName = nil, ""
+	return cm
+}
+
+// displayImage returns img as it should be presented: sRGB encoded if the
+// values are being viewed as linear, and converted to the display primaries
+// and then the monitor profile when color managed. Single channel views are
+// never color managed.
+func (d *document) displayImage(img image.Image, channel hdrColors.GraySetting, cm colorManagement) image.Image {
+	if img == nil {
+		return img
+	}
+	var m colorspace.Mat3
+	var convert bool
+	display := cm.display
+	if cm.enabled && (channel == hdrColors.GraySettingNone || channel == hdrColors.GraySettingNoAlpha) {
+		m, convert = d.displayTransform()
+	} else {
+		display = nil
+	}
+	switch {
+	case !convert && display == nil && !cm.encodeSRGB:
+		return img
+	case convert && display == nil && !cm.encodeSRGB:
+		return colorspace.Transform(img, m)
+	}
+	return colorspace.TransformFunc(img, func(r, g, b float32) (float32, float32, float32) {
+		if convert {
+			r, g, b = m.Apply(r, g, b)
+		}
+		if cm.encodeSRGB {
+			r, g, b = colorspace.EncodeSRGBColor(r, g, b)
+		}
+		if display != nil {
+			r, g, b = display.Apply(r, g, b)
+		}
+		return r, g, b
+	})
+}
+
+// closeDocument removes docs[index], keeping at least one (possibly empty)
+// document open, and returns the new document list and active index.
+func closeDocument(docs []*document, active, index int) ([]*document, int) {
+	docs[index].undoStack.Clear()
+	docs = slices.Delete(docs, index, index+1)
+	if len(docs) == 0 {
+		docs = append(docs, newDocument("", nil, true))
+	}
+	if active > index || active >= len(docs) {
+		active--
+	}
+	return docs, max(active, 0)
+}
+
+// drawDocumentTabs shows a tab for each open document below the main menu bar.
+// It returns the index of the visible tab and the index of a tab whose close
+// button was pressed, or -1 for either.
+func drawDocumentTabs(docs []*document, selectDoc int) (active int, closing int) {
+	active, closing = -1, -1
+	viewport := imgui.MainViewport()
+	imgui.SetNextWindowPos(imgui.Vec2{
+		X: viewport.Pos().X,
+		Y: viewport.Pos().Y + imgui.FrameHeight(),
+	})
+	imgui.SetNextWindowSize(imgui.Vec2{
+		X: viewport.Size().X,
+		Y: 1.5 * imgui.FrameHeight(),
+	})
+
+	flags := (imgui.WindowFlagsNoDecoration | imgui.WindowFlagsNoMove |
+		imgui.WindowFlagsNoSavedSettings | imgui.WindowFlagsNoBringToFrontOnFocus |
+		imgui.WindowFlagsNoBackground)
+
+	if imgui.BeginV("Documents", nil, flags) {
+		if imgui.BeginTabBarV("DocumentTabs", imgui.TabBarFlagsFittingPolicyScroll) {
+			for i, doc := range docs {
+				open := true
+				tabFlags := imgui.TabItemFlagsNone
+				if !doc.saved {
+					tabFlags |= imgui.TabItemFlagsUnsavedDocument
+				}
+				if i == selectDoc {
+					tabFlags |= imgui.TabItemFlagsSetSelected
+				}
+				if imgui.BeginTabItemV(doc.tabLabel(), &open, tabFlags) {
+					active = i
+					imgui.EndTabItem()
+				}
+				if !open {
+					closing = i
+				}
+			}
+			imgui.EndTabBar()
+		}
+	}
+	imgui.End()
+	return
+}
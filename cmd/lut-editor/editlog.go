@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/editlog"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+	"github.com/sqweek/dialog"
+)
+
+// maxListedConflicts is how many conflicts applying an edit log lists in the
+// console before summing up the rest.
+const maxListedConflicts = 20
+
+// exportEditLog writes the edits in the undo history, from the state the file
+// was opened in to img, to an edit log chosen by the user.
+func exportEditLog(prt *app.Printer, source string, states []types.UndoRedoState, img image.Image) {
+	log, err := buildEditLog(prt, source, states, img)
+	if err != nil {
+		prt.Errorf("failed to export edit log: %v", err)
+		return
+	}
+	if len(log.Steps) == 0 {
+		prt.Infof("There are no edits in the undo history to export")
+		return
+	}
+
+	logFileName, err := dialog.File().Title("Export Edit Log").Filter("Edit logs", strings.TrimPrefix(editlog.Extension, ".")).Save()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	if filepath.Ext(logFileName) == "" {
+		logFileName += editlog.Extension
+	}
+	if err := editlog.Save(logFileName, log); err != nil {
+		prt.Errorf("failed to export edit log: %v", err)
+		return
+	}
+	prt.Infof("Wrote %d edits changing %d texels to '%s'", len(log.Steps), log.Texels(), logFileName)
+}
+
+// buildEditLog makes a step of the log from each pair of neighbouring states
+// in the undo history, and from the last of them to img. Most edits push
+// their undo state just before making their change, so each step is named
+// after the entry it starts from, except for the first, which is the file
+// being opened. The log starts after the last edit that resized the image.
+func buildEditLog(prt *app.Printer, source string, states []types.UndoRedoState, img image.Image) (*editlog.Log, error) {
+	var actions []string
+	var images []hdrColors.RawImage
+	for i := range states {
+		stateImg, err := states[i].Image()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read undo state '%s': %v", states[i].Action, err)
+		}
+		raw, ok := rawImage(stateImg)
+		if !ok {
+			continue
+		}
+		actions = append(actions, states[i].Action)
+		images = append(images, raw)
+	}
+	raw, ok := rawImage(img)
+	if !ok {
+		return nil, fmt.Errorf("not an HDR image")
+	}
+	images = append(images, raw)
+
+	bounds := raw.Bounds()
+	log := editlog.New(source, bounds.Dx(), bounds.Dy())
+	for i := 1; i < len(images); i++ {
+		action := "Edit"
+		switch {
+		case i == 1 && len(actions) > 1:
+			action = actions[1]
+		case i > 1:
+			action = actions[i-1]
+		}
+		if images[i-1].Bounds().Size() != images[i].Bounds().Size() {
+			if len(log.Steps) > 0 {
+				prt.Infof("The edit log starts after '%s', which resized the image", action)
+			}
+			log = editlog.New(source, bounds.Dx(), bounds.Dy())
+			continue
+		}
+		if _, err := log.Add(action, images[i-1], images[i]); err != nil {
+			return nil, err
+		}
+	}
+	return log, nil
+}
+
+// importEditLog reads an edit log chosen by the user and sends it to the main
+// loop to be applied.
+func importEditLog(prt *app.Printer, results chan<- *editlog.Log) {
+	logFileName, err := dialog.File().Title("Apply Edit Log").Filter("Edit logs", strings.TrimPrefix(editlog.Extension, ".")).Load()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	log, err := editlog.Load(logFileName)
+	if err != nil {
+		prt.Errorf("failed to read edit log: %v", err)
+		return
+	}
+	if log.Source == "" {
+		log.Source = filepath.Base(logFileName)
+	}
+	results <- log
+}
+
+// applyEditLog replays log onto the document as one undo step, keeping the
+// document's own value wherever both changed a channel, and lists those
+// conflicts in the console.
+func (d *document) applyEditLog(prt *app.Printer, log *editlog.Log, currColor [4]float32, lock blend.Lock) {
+	raw, ok := rawImage(d.img)
+	if !ok {
+		prt.Errorf("failed to apply edit log: not an HDR image")
+		return
+	}
+	if bounds := raw.Bounds(); bounds.Dx() != log.Width || bounds.Dy() != log.Height {
+		prt.Errorf("failed to apply edit log: it is for a %dx%d image but the image is %dx%d", log.Width, log.Height, bounds.Dx(), bounds.Dy())
+		return
+	}
+	d.undoStack.Push("Apply Edit Log", d.fileName, d.saved, d.img, currColor, d.selection)
+	restore := d.protectEdits(lock)
+	result, err := log.Apply(raw)
+	restore()
+	d.saved = d.saved && result.Changed == 0
+	d.refreshSprites = true
+	if err != nil {
+		prt.Errorf("failed to apply edit log: %v", err)
+		return
+	}
+	prt.Infof("Applied %d edits from '%s': %d texels changed, %d already had the edit, %d conflicts", len(log.Steps), log.Source, result.Changed, result.Matched, len(result.Conflicts))
+	names := d.names()
+	for i, c := range result.Conflicts {
+		if i == maxListedConflicts {
+			prt.Warnf("...and %d more conflicts", len(result.Conflicts)-i)
+			break
+		}
+		channels := make([]string, len(c.Channels))
+		for j, ch := range c.Channels {
+			channels[j] = string("RGBA"[ch])
+		}
+		prt.Warnf("'%s' set %s of row %s, column %s to %s, but it was also changed here; kept %s", c.Action, strings.Join(channels, ", "), names.Row(c.Y), names.Column(c.X), formatTexel(c.Want), formatTexel(c.Current))
+	}
+}
+
+func formatTexel(c [4]float64) string {
+	return fmt.Sprintf("(%g, %g, %g, %g)", float32(c[0]), float32(c[1]), float32(c[2]), float32(c[3]))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/preview"
+)
+
+// exrPreviewSize is the longest side of the preview saved in EXR files.
+// Smaller images are scaled up by whole pixels to about this size.
+const exrPreviewSize = 100
+
+// storedColors reads an image's stored values, whatever channel is being
+// viewed in the editor.
+type storedColors struct {
+	hdrColors.RawImage
+}
+
+func (s storedColors) At(x, y int) color.Color {
+	c := s.RawAt(x, y)
+	return hdrColors.NRGBA128F{R: float32(c[0]), G: float32(c[1]), B: float32(c[2]), A: float32(c[3])}
+}
+
+// exrPreview renders img for the preview attribute of an EXR file.
+func exrPreview(img image.Image) *image.NRGBA {
+	if raw, ok := rawImage(img); ok {
+		img = storedColors{raw}
+	}
+	thumb := preview.Thumbnail(img, exrPreviewSize, preview.ToneMapClamp)
+	size := thumb.Bounds().Size()
+	if scale := exrPreviewSize / max(size.X, size.Y); scale > 1 {
+		thumb = preview.Render(thumb, preview.Options{Scale: int32(scale)})
+	}
+	return thumb
+}
+
+// withEXRPreview returns attrs with a preview of img replacing any preview
+// loaded with the file.
+func withEXRPreview(attrs []openexr.Attribute, img image.Image) []openexr.Attribute {
+	return openexr.SetAttribute(attrs, openexr.EncodePreview(exrPreview(img)))
+}
+
+// exrFilePreview reads the preview stored in the EXR file at path, if it has
+// one, and the size of the full image, without decoding its pixels.
+func exrFilePreview(path string) (*image.NRGBA, image.Point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	defer f.Close()
+	hdr, err := openexr.LoadOpenEXRHeader(f)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	size := image.Pt(int(hdr.DataWindow.Width()), int(hdr.DataWindow.Height()))
+	img, err := openexr.DecodePreview(hdr.Attributes)
+	return img, size, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"path/filepath"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/changes"
+	"github.com/ryanjsims/hd2-lut-editor/gitrepo"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// committedImage is the version of a document's file committed in git.
+type committedImage struct {
+	docID    int
+	revision string
+	img      image.Image
+}
+
+// loadCommittedImage reads the version of fileName committed in revision and
+// sends it to results, for the document with id docID.
+func loadCommittedImage(prt *app.Printer, docID int, fileName, revision string, results chan<- *committedImage) {
+	data, err := gitrepo.Show(fileName, revision)
+	if err == gitrepo.ErrNotInRepository {
+		prt.Errorf("'%s' is not in a git repository", fileName)
+		return
+	} else if err != nil {
+		prt.Errorf("diff against %s: %v", revision, err)
+		return
+	}
+	img, _, err := decodeImage(bytes.NewReader(data), filepath.Ext(fileName))
+	if err != nil {
+		prt.Errorf("diff against %s: failed to decode committed image: %v", revision, err)
+		return
+	}
+	if _, ok := rawImage(img); !ok {
+		prt.Errorf("diff against %s: committed image is not an HDR image", revision)
+		return
+	}
+	results <- &committedImage{docID: docID, revision: revision, img: img}
+}
+
+// compareWithCommitted starts comparing the document against committed: it
+// is stored as snapshot A and shown, so T flips between it and the live image,
+// and the texels that differ are highlighted.
+func (d *document) compareWithCommitted(prt *app.Printer, committed *committedImage, currColor [4]float32, channel hdrColors.GraySetting) {
+	raw, _ := rawImage(committed.img)
+	d.committed, d.committedPic = raw, nil
+	d.setSnapshot(0, fmt.Sprintf("Snapshot %s", committed.revision), committed.img, currColor)
+	if err := d.showSnapshot(0, channel); err != nil {
+		prt.Errorf("failed to show %s: %v", committed.revision, err)
+	}
+	if d.img.Bounds().Size() != committed.img.Bounds().Size() {
+		prt.Infof("The image is %dx%d but was %dx%d in %s", d.img.Bounds().Dx(), d.img.Bounds().Dy(), committed.img.Bounds().Dx(), committed.img.Bounds().Dy(), committed.revision)
+		return
+	}
+	prt.Infof("Showing %s as snapshot A, %d texels differ. Press T to flip to the live image", committed.revision, len(d.changesSinceCommit()))
+}
+
+// changesSinceCommit returns the texels that differ from the committed image,
+// in image coordinates.
+func (d *document) changesSinceCommit() []image.Point {
+	if d.committed == nil || d.pic == nil {
+		return nil
+	}
+	if d.pic == d.committedPic {
+		return d.committedChanges
+	}
+	d.committedChanges, d.committedPic = nil, d.pic
+	raw, ok := rawImage(d.img)
+	if !ok {
+		return nil
+	}
+	diff, err := changes.Diff(d.committed, raw)
+	if err != nil {
+		return nil
+	}
+	for _, change := range diff {
+		d.committedChanges = append(d.committedChanges, image.Pt(change.X, change.Y))
+	}
+	return d.committedChanges
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/filter"
+)
+
+// gradientMapSettings are the choices in the Gradient Map dialog.
+type gradientMapSettings struct {
+	// input is the channel looked up in the gradient
+	input int
+	// outputs are the channels the gradient's color is written to
+	outputs [4]bool
+	stops   []gradientStop
+}
+
+type gradientStop struct {
+	pos   float32
+	color [4]float32
+}
+
+func defaultGradientMapSettings() gradientMapSettings {
+	return gradientMapSettings{
+		outputs: [4]bool{true, true, true, false},
+		stops: []gradientStop{
+			{pos: 0, color: [4]float32{0, 0, 0, 1}},
+			{pos: 1, color: [4]float32{1, 1, 1, 1}},
+		},
+	}
+}
+
+func (s gradientMapSettings) gradient() filter.Gradient {
+	g := make(filter.Gradient, len(s.stops))
+	for i, stop := range s.stops {
+		g[i].Pos = float64(stop.pos)
+		for j, v := range stop.color {
+			g[i].Color[j] = float64(v)
+		}
+	}
+	return g
+}
+
+func gradientMap(settings *gradientMapSettings, target string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.3 * viewport.Size().X,
+		Y: 0.4 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = gradientMapDialog(settings, target, windowSize, &responded)
+	return responded
+}
+
+func gradientMapDialog(settings *gradientMapSettings, target string, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Gradient Map", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Map a channel of each texel in the %s through a gradient", target))
+	imgui.Text("Input")
+	for i, name := range []string{"R", "G", "B", "A"} {
+		imgui.SameLine()
+		imgui.RadioButtonInt(name+"##input", &settings.input, i)
+	}
+	imgui.Text("Output")
+	for i, name := range []string{"R", "G", "B", "A"} {
+		imgui.SameLine()
+		imgui.Checkbox(name+"##output", &settings.outputs[i])
+	}
+	imgui.BeginChildV("##stops", imgui.Vec2{X: 0, Y: windowSize.Y * 0.45}, true, 0)
+	remove := -1
+	for i := range settings.stops {
+		stop := &settings.stops[i]
+		imgui.PushIDInt(i)
+		imgui.PushItemWidth(windowSize.X * 0.2)
+		imgui.DragFloatV("##pos", &stop.pos, 0.01, 0, 0, "%.3f", imgui.SliderFlagsNone)
+		imgui.PopItemWidth()
+		imgui.SameLine()
+		imgui.ColorEdit4V("##color", &stop.color, imgui.ColorEditFlagsFloat|imgui.ColorEditFlagsHDR)
+		imgui.SameLine()
+		// A gradient needs a stop to have a color at all
+		if len(settings.stops) > 1 && imgui.Button("Remove") {
+			remove = i
+		}
+		imgui.PopID()
+	}
+	if remove >= 0 {
+		settings.stops = append(settings.stops[:remove], settings.stops[remove+1:]...)
+	}
+	imgui.EndChild()
+	if imgui.Button("Add Stop") {
+		last := settings.stops[len(settings.stops)-1]
+		settings.stops = append(settings.stops, gradientStop{pos: last.pos + 0.25, color: last.color})
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.1,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .85,
+	})
+	if imgui.ButtonV("Apply", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
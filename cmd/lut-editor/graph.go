@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// graphSettings controls the Graph window, which plots one channel of the
+// texels along a row or column of the image.
+type graphSettings struct {
+	columns bool
+	channel int
+	// line is the row or column last plotted, kept while the mouse is off the
+	// image
+	line int
+}
+
+var graphChannelNames = []string{"Red", "Green", "Blue", "Alpha"}
+
+// graphValues returns channel of every texel along row (or column) line of
+// img, limited to span.
+func graphValues(img image.Image, span image.Rectangle, columns bool, line, channel int) []float32 {
+	raw, ok := rawImage(img)
+	if !ok {
+		return nil
+	}
+	var values []float32
+	if columns {
+		for y := span.Min.Y; y < span.Max.Y; y++ {
+			values = append(values, float32(raw.RawAt(line, y)[channel]))
+		}
+	} else {
+		for x := span.Min.X; x < span.Max.X; x++ {
+			values = append(values, float32(raw.RawAt(x, line)[channel]))
+		}
+	}
+	return values
+}
+
+// drawGraphWindow plots the row or column under the mouse at (hovX, hovY), or
+// the first row or column of the selection when the mouse isn't over the
+// image.
+func drawGraphWindow(doc *document, settings *graphSettings, hovX, hovY int, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 420, Y: 260}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Graph", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+	if doc.img == nil {
+		textDisabled("No image")
+		return
+	}
+
+	if imgui.RadioButton("Row", !settings.columns) {
+		settings.columns = false
+	}
+	imgui.SameLine()
+	if imgui.RadioButton("Column", settings.columns) {
+		settings.columns = true
+	}
+	for i, name := range graphChannelNames {
+		imgui.SameLine()
+		imgui.RadioButtonInt(name, &settings.channel, i)
+	}
+
+	bounds := doc.img.Bounds()
+	span := bounds
+	// Reading the plot means moving the mouse over this window, so that
+	// mustn't change what is plotted
+	hovered := image.Pt(hovX, hovY).In(bounds) && !imgui.CurrentIO().WantCaptureMouse()
+	source := "hovered"
+	if hovered {
+		settings.line = hovY
+		if settings.columns {
+			settings.line = hovX
+		}
+	} else if doc.selection.Area() > 0 {
+		span = selectionToImageRect(doc.selection, doc.sprite.Frame().Center(), bounds.Dy()).Intersect(bounds)
+		settings.line = span.Min.Y
+		if settings.columns {
+			settings.line = span.Min.X
+		}
+		source = "selected"
+	}
+	if settings.columns {
+		settings.line = min(max(settings.line, bounds.Min.X), bounds.Max.X-1)
+	} else {
+		settings.line = min(max(settings.line, bounds.Min.Y), bounds.Max.Y-1)
+	}
+
+	values := graphValues(doc.img, span, settings.columns, settings.line, settings.channel)
+	if len(values) == 0 {
+		textDisabled("Nothing to plot")
+		return
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	lineName := "Row"
+	if settings.columns {
+		lineName = "Column"
+	}
+	imgui.Text(fmt.Sprintf("%s %d (%s)  min %.4f  max %.4f", lineName, settings.line, source, lo, hi))
+	// Keep flat lines off the edges of the plot
+	pad := max((hi-lo)*0.05, 1e-3)
+	size := imgui.ContentRegionAvail()
+	imgui.PlotLinesV("##graph", values, 0, "", lo-pad, hi+pad, imgui.Vec2{X: size.X, Y: float32(math.Max(float64(size.Y), 60))})
+}
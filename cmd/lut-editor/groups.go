@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+// groupArea returns the columns of group, in sprite-centered world
+// coordinates.
+func groupArea(group help.ColumnGroup, spriteCenter pixel.Vec, height int) pixel.Rect {
+	return imageRectToSelection(image.Rect(group.First, 0, group.Last+1, height), spriteCenter, height)
+}
+
+// drawColumnGroups separates the schema's column groups with gaps in the
+// background color, and covers the columns of hidden groups with it, so only
+// the groups being edited show.
+func drawColumnGroups(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, height int, groups []help.ColumnGroup, hidden map[int]bool) {
+	background := pixel.RGBA{R: 0x55 / 255.0, G: 0x55 / 255.0, B: 0x55 / 255.0, A: 1}
+	gap := 2 / camZoom
+	shade := imdraw.New(nil)
+	shade.Color = background
+	for i, group := range groups {
+		area := groupArea(group, spriteCenter, height)
+		if hidden[i] {
+			shade.Push(area.Min, area.Max)
+			shade.Rectangle(0)
+		}
+		for _, x := range []float64{area.Min.X, area.Max.X} {
+			shade.Push(pixel.V(x-gap, area.Min.Y), pixel.V(x+gap, area.Max.Y))
+			shade.Rectangle(0)
+		}
+	}
+	shade.Draw(win)
+}
+
+// drawColumnGroupLabels writes the name of each group above its columns.
+// Labels are drawn behind the editor's windows.
+func drawColumnGroupLabels(cam pixel.Matrix, windowHeight float64, spriteCenter pixel.Vec, height int, groups []help.ColumnGroup, hidden map[int]bool) {
+	drawList := imgui.BackgroundDrawList()
+	for i, group := range groups {
+		area := groupArea(group, spriteCenter, height)
+		topLeft := cam.Project(pixel.V(area.Min.X, area.Max.Y))
+		label := group.Name
+		if hidden[i] {
+			label += " (hidden)"
+		}
+		pos := imgui.Vec2{
+			X: float32(topLeft.X) + 3,
+			Y: float32(windowHeight-topLeft.Y) - imgui.TextLineHeightWithSpacing(),
+		}
+		drawList.AddText(pos, imgui.PackedColorFromVec4(imgui.Vec4{X: 0.9, Y: 0.9, Z: 0.9, W: 1}), label)
+	}
+}
+
+// showColumnGroupsMenu shows or hides the schema's column groups. It returns
+// the response for the item chosen, and for a group, its index.
+func showColumnGroupsMenu(groupsVisible bool, groups []help.ColumnGroup, hidden map[int]bool) (response types.MenuResponse, index int) {
+	response = types.MenuResponseNone
+	if imgui.MenuItemV("Separate Groups", "", groupsVisible, true) {
+		response = types.MenuResponseViewColumnGroups
+	}
+	if imgui.MenuItemV("Show All Groups", "", false, len(hidden) > 0) {
+		response = types.MenuResponseViewShowAllGroups
+	}
+	imgui.Separator()
+	for i, group := range groups {
+		if imgui.MenuItemV(fmt.Sprintf("%s##group%d", group.Name, i), "", !hidden[i], groupsVisible) {
+			response, index = types.MenuResponseViewToggleGroup, i
+		}
+		if group.Description != "" && imgui.IsItemHovered() {
+			imgui.SetTooltip(group.Description)
+		}
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/fileinfo"
+	"github.com/ryanjsims/hd2-lut-editor/texhash"
+)
+
+// contentHash returns the hash of the document's texel values. It is cached
+// until the picture changes.
+func (d *document) contentHash() texhash.Sum {
+	if d.pic != nil && d.pic == d.hashPic {
+		return d.hash
+	}
+	d.hash, d.hashPic = texhash.Image(d.img), d.pic
+	return d.hash
+}
+
+// warnUnchanged tells the user when img, just saved to fileName, has exactly
+// the values of the baseline, so the saved file changes nothing in game.
+func warnUnchanged(prt *app.Printer, fileName, baseline string, img image.Image) {
+	if baseline == "" {
+		return
+	}
+	baseImg, _, err := loadImage(baseline)
+	if err != nil {
+		prt.Errorf("failed to load baseline '%s': %v", baseline, err)
+		return
+	}
+	if hash := texhash.Image(img); hash == texhash.Image(baseImg) {
+		prt.Errorf("'%s' is identical to the baseline '%s' (hash %s), it has no changes", fileName, baseline, hash)
+	}
+}
+
+// printInfo writes the header fields and content hash of each file to
+// standard output, for the --info flag.
+func printInfo(paths []string) error {
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Println()
+		}
+		info, err := fileinfo.Load(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		img, _, err := loadImage(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		fmt.Println(path)
+		for _, field := range info.Fields {
+			fmt.Printf("  %s: %s\n", field.Name, field.Value)
+		}
+		fmt.Printf("  Content hash: %s\n", texhash.Image(img))
+	}
+	return nil
+}
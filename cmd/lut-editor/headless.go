@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/script"
+	"github.com/ryanjsims/hd2-lut-editor/texhash"
+)
+
+// headlessSession runs console commands without a window, for --run. It
+// keeps its own undo history of the changes made since the image was opened.
+type headlessSession struct {
+	doc        editor.Document
+	fileName   string
+	attributes []openexr.Attribute
+	selection  image.Rectangle
+	history    editor.History
+}
+
+func (s *headlessSession) image() image.Image {
+	return s.doc.Image
+}
+
+func (s *headlessSession) selected() image.Rectangle {
+	return s.selection
+}
+
+func (s *headlessSession) selectRect(rect image.Rectangle) {
+	s.selection = rect
+}
+
+func (s *headlessSession) lock() blend.Lock {
+	return blend.Lock{}
+}
+
+func (s *headlessSession) edit(name string, op editor.Operation) error {
+	_, err := s.history.Do(&s.doc, op)
+	return err
+}
+
+func (s *headlessSession) open(path string) error {
+	img, attrs, err := loadImage(path)
+	if err != nil {
+		return fmt.Errorf("open: %v", err)
+	}
+	*s = headlessSession{doc: editor.Document{Image: img}, fileName: path, attributes: attrs}
+	return nil
+}
+
+// commands are the console's edit commands, and commands to open and save
+// files, undo, and check results.
+func (s *headlessSession) commands() script.Commands {
+	return append(editCommands(s),
+		script.Command{
+			Name:  "open",
+			Usage: "open(path)",
+			Help:  "open an EXR or DDS file",
+			Run: func(call script.Call) (string, error) {
+				path, err := call.Text(0, "path", "")
+				if err != nil {
+					return "", err
+				}
+				if path == "" {
+					return "", fmt.Errorf("open: missing path")
+				}
+				return "", s.open(path)
+			},
+		},
+		script.Command{
+			Name:  "save",
+			Usage: "save([path])",
+			Help:  "save the image, to path if given",
+			Run: func(call script.Call) (string, error) {
+				path, err := call.Text(0, "path", s.fileName)
+				if err != nil {
+					return "", err
+				}
+				if s.doc.Image == nil {
+					return "", fmt.Errorf("save: no image is open")
+				}
+				if err := writeImageFile(s.doc.Image, s.attributes, path, dds.WriteHDROptions{}); err != nil {
+					return "", fmt.Errorf("save: %v", err)
+				}
+				return fmt.Sprintf("Saved '%s'", path), nil
+			},
+		},
+		script.Command{
+			Name:  "undo",
+			Usage: "undo()",
+			Help:  "undo the last edit",
+			Run: func(call script.Call) (string, error) {
+				if ok, err := s.history.Undo(&s.doc); err != nil || !ok {
+					return "", fmt.Errorf("undo: nothing to undo")
+				}
+				return "", nil
+			},
+		},
+		script.Command{
+			Name:  "redo",
+			Usage: "redo()",
+			Help:  "redo the last undone edit",
+			Run: func(call script.Call) (string, error) {
+				if ok, err := s.history.Redo(&s.doc); err != nil || !ok {
+					return "", fmt.Errorf("redo: nothing to redo")
+				}
+				return "", nil
+			},
+		},
+		script.Command{
+			Name:  "hash",
+			Usage: "hash([expected])",
+			Help:  "show the content hash of the image, or fail if it isn't expected",
+			Run: func(call script.Call) (string, error) {
+				if s.doc.Image == nil {
+					return "", fmt.Errorf("hash: no image is open")
+				}
+				expected, err := call.Text(0, "", "")
+				if err != nil {
+					return "", err
+				}
+				hash := texhash.Image(s.doc.Image).String()
+				if expected != "" && !strings.EqualFold(expected, hash) {
+					return "", fmt.Errorf("hash: expected %s, got %s", expected, hash)
+				}
+				return hash, nil
+			},
+		},
+		script.Command{
+			Name:  "expect",
+			Usage: "expect(x, y, r, g, b[, a], tolerance=0)",
+			Help:  "fail unless a texel has the given values",
+			Run: func(call script.Call) (string, error) {
+				if s.doc.Image == nil {
+					return "", fmt.Errorf("expect: no image is open")
+				}
+				p, err := consolePoint(s, call)
+				if err != nil {
+					return "", err
+				}
+				want, lock, err := consoleColor(call, 2)
+				if err != nil {
+					return "", err
+				}
+				tolerance := 0.0
+				if call.Has(-1, "tolerance") {
+					if tolerance, err = call.Number(-1, "tolerance"); err != nil {
+						return "", err
+					}
+				}
+				raw, ok := rawImage(s.doc.Image)
+				if !ok {
+					return "", fmt.Errorf("expect: not an HDR image")
+				}
+				got := raw.RawAt(p.X, p.Y)
+				for i := range got {
+					if !lock[i] && (got[i] < want[i]-tolerance || got[i] > want[i]+tolerance) {
+						return "", fmt.Errorf("expect: (%d, %d) is %g, %g, %g, %g", p.X, p.Y, got[0], got[1], got[2], got[3])
+					}
+				}
+				return "", nil
+			},
+		},
+	)
+}
+
+// runHeadless runs the commands in the script at scriptPath, one per line,
+// without opening a window. Blank lines and lines starting with # are
+// skipped. The script is run once for each of paths, opened first, or once
+// with no image open if there are none. Output goes to standard output, and
+// the first failing command stops the run.
+func runHeadless(scriptPath string, paths []string) error {
+	data, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return err
+	}
+	run := func(path string) error {
+		var session headlessSession
+		if path != "" {
+			if err := session.open(path); err != nil {
+				return err
+			}
+		}
+		commands := session.commands()
+		lines := bufio.NewScanner(strings.NewReader(string(data)))
+		for n := 1; lines.Scan(); n++ {
+			line := strings.TrimSpace(lines.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			out, err := commands.Run(line)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %v", scriptPath, n, err)
+			}
+			if out != "" {
+				fmt.Println(out)
+			}
+		}
+		return lines.Err()
+	}
+	if len(paths) == 0 {
+		return run("")
+	}
+	for _, path := range paths {
+		if err := run(path); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/prefs"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+// undoDelay is how long an edit waits for another before it becomes an undo
+// step.
+func undoDelay(undo prefs.Undo) time.Duration {
+	return time.Duration(float64(undo.Delay) * float64(time.Second))
+}
+
+// undoCoalesces reports whether quick runs of action are merged into one undo
+// step.
+func undoCoalesces(undo prefs.Undo, action string) bool {
+	switch action {
+	case "Draw", "Erase":
+		return undo.Draw
+	case "Pick Color", "Edit Color":
+		return undo.Color
+	default:
+		return undo.Selection
+	}
+}
+
+// drawHistoryWindow lists the undo steps of a document, oldest first, with the
+// current one highlighted, the steps that can be redone after it, and an edit
+// still waiting to become a step. Clicking a step returns an undo or redo
+// response to go back or forward to it. Changes to the undo preferences are
+// saved straight away.
+func drawHistoryWindow(prt *app.Printer, undoStack *types.UndoRedoStack, preferences *prefs.Preferences, visible *bool) (resp types.MenuResponse, index int) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 280, Y: 360}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("History", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	resp = types.MenuResponseNone
+	if imgui.CollapsingHeader("Undo Settings") {
+		changed := imgui.SliderFloatV("Merge Delay", &preferences.Undo.Delay, 0, 5, "%.1f s", 0)
+		preferences.Undo.Delay = max(preferences.Undo.Delay, 0)
+		imgui.Text("Merge quick runs of:")
+		changed = imgui.Checkbox("Drawing", &preferences.Undo.Draw) || changed
+		changed = imgui.Checkbox("Color Edits", &preferences.Undo.Color) || changed
+		changed = imgui.Checkbox("Selection Changes", &preferences.Undo.Selection) || changed
+		changed = imgui.SliderInt("Full Snapshot Every", &preferences.Undo.Checkpoint, 1, 64) || changed
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Steps in between keep only the parts of the image they changed,\nusing less memory but taking longer to undo")
+		}
+		if changed {
+			if err := prefs.Save(*preferences); err != nil {
+				prt.Errorf("failed to save preferences: %v", err)
+			}
+		}
+		imgui.Separator()
+	}
+
+	current := len(undoStack.UndoStack) - 1
+	for i, state := range undoStack.UndoStack {
+		if imgui.SelectableV(fmt.Sprintf("%s##undo%d", state.Action, i), i == current, 0, imgui.Vec2{}) && i != current {
+			resp, index = types.MenuResponseUndo, i
+		}
+	}
+	for i := len(undoStack.RedoStack) - 1; i >= 0; i-- {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 0.5, Y: 0.5, Z: 0.5, W: 1})
+		if imgui.Selectable(fmt.Sprintf("%s##redo%d", undoStack.RedoStack[i].Action, i)) {
+			resp, index = types.MenuResponseRedo, i
+		}
+		imgui.PopStyleColor()
+	}
+	if action, ok := undoStack.Pending(); ok {
+		textDisabled(fmt.Sprintf("%s (waiting for more edits)", action))
+		imgui.SameLine()
+		if imgui.Button("Now") {
+			undoStack.Flush()
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Make the waiting edit an undo step now")
+		}
+	}
+	return resp, index
+}
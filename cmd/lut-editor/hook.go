@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/prefs"
+	"github.com/ryanjsims/hd2-lut-editor/shell"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+// maxHookOutputLines limits the output of a save hook kept for the status bar
+// tooltip. All of it is logged.
+const maxHookOutputLines = 20
+
+// expandHookCommand fills in the tokens of a save hook command for a file
+// saved to path.
+func expandHookCommand(command, path string) string {
+	return strings.NewReplacer(
+		"{path}", shell.Quote(path),
+		"{dir}", shell.Quote(filepath.Dir(path)),
+		"{name}", shell.Quote(filepath.Base(path)),
+	).Replace(command)
+}
+
+// taskContext makes task cancellable, returning a context that is cancelled
+// along with it. stop must be called once the task is done.
+func taskContext(task *types.BackgroundStatus) (ctx context.Context, stop context.CancelFunc) {
+	task.Cancellable = true
+	ctx, stop = context.WithCancel(context.Background())
+	go func() {
+		for ctx.Err() == nil {
+			if task.Cancelled() {
+				stop()
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	return ctx, stop
+}
+
+// runCommand runs line with the system shell in dir, returning what it
+// printed.
+func runCommand(ctx context.Context, line, dir string) (string, error) {
+	var out bytes.Buffer
+	cmd := shell.Command(ctx, line)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return strings.TrimSpace(out.String()), err
+}
+
+// lastLines returns the end of a command's output kept for the status bar.
+func lastLines(output string) string {
+	lines := strings.Split(output, "\n")
+	return strings.Join(lines[max(len(lines)-maxHookOutputLines, 0):], "\n")
+}
+
+// runSaveHook runs command after path was saved, in the folder of path. Its
+// output is logged and kept in task, whose message is the last line of it.
+func runSaveHook(prt *app.Printer, command, path string, task *types.BackgroundStatus) {
+	ctx, stop := taskContext(task)
+	defer stop()
+	output, err := runCommand(ctx, expandHookCommand(command, path), filepath.Dir(path))
+	if task.Cancelled() {
+		prt.Infof("Save hook for '%s' cancelled", path)
+		task.OnCancel()
+		return
+	}
+
+	if output != "" {
+		prt.Infof("Save hook for '%s':\n%s", path, output)
+	}
+	task.Output = lastLines(output)
+	if err != nil {
+		prt.Errorf("save hook for '%s': %v", path, err)
+		task.OnError(err)
+		return
+	}
+	message := strings.TrimSpace(output[strings.LastIndex(output, "\n")+1:])
+	if message == "" {
+		message = "done"
+	}
+	task.Report(message)
+}
+
+func saveHookPrompt(hook *prefs.Hook, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.4 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = saveHookDialog(hook, windowSize, &responded)
+	return responded
+}
+
+func saveHookDialog(hook *prefs.Hook, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Save Hook", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Checkbox("Run after each save", &hook.Enabled)
+	imgui.InputText("Command", &hook.Command)
+	textDisabled("{path}, {dir} and {name} are replaced by the saved file's path, folder and name")
+	textDisabled("It runs in the saved file's folder, for example: repack.bat {name}")
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("OK", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/sqweek/dialog"
+)
+
+// importRawSettings describe the headerless data File > Import Raw... reads.
+// fileSize is the size of the file at path, or -1 if it can't be read.
+type importRawSettings struct {
+	path          string
+	fileSize      int64
+	width, height int32
+	channels      int32
+	dataType      int
+	offset        int32
+}
+
+func defaultImportRawSettings() importRawSettings {
+	return importRawSettings{fileSize: -1, width: 23, height: 8, channels: 4, dataType: int(hdrColors.DataTypeFloat)}
+}
+
+func (s importRawSettings) layout() hdrColors.RawLayout {
+	return hdrColors.RawLayout{
+		Width:    int(s.width),
+		Height:   int(s.height),
+		Channels: int(s.channels),
+		Type:     hdrColors.DataType(s.dataType),
+		Offset:   int64(s.offset),
+	}
+}
+
+func (s importRawSettings) withFile(path string) importRawSettings {
+	s.path, s.fileSize = path, -1
+	if info, err := os.Stat(path); err == nil {
+		s.fileSize = info.Size()
+	}
+	return s
+}
+
+func chooseRawFile(prt *app.Printer, paths chan<- string) {
+	path, err := dialog.File().Title("Import Raw").Filter("All files", "*").Load()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	paths <- path
+}
+
+// importRaw opens the data in settings as an image in a new tab. Like a
+// downloaded image, it has no file to save to until one is chosen.
+func importRaw(prt *app.Printer, settings importRawSettings, openedDocs chan<- *document, currColor [4]float32) {
+	f, err := os.Open(settings.path)
+	if err != nil {
+		prt.Errorf("Failed to open '%s': %v", settings.path, err)
+		return
+	}
+	defer f.Close()
+	layout := settings.layout()
+	img, err := hdrColors.DecodeRawData(f, layout)
+	if err != nil {
+		prt.Errorf("Failed to import '%s': %v", settings.path, err)
+		return
+	}
+	if settings.fileSize > layout.Size() {
+		prt.Infof("Imported '%s', ignoring the last %d bytes", settings.path, settings.fileSize-layout.Size())
+	}
+	newDoc := newDocument("(new)", img, true)
+	newDoc.title = filepath.Base(settings.path)
+	newDoc.undoStack.Push("Import Raw", newDoc.fileName, true, newDoc.img, currColor, newDoc.selection)
+	openedDocs <- newDoc
+}
+
+func importRawPrompt(settings *importRawSettings, browse *bool, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.4 * viewport.Size().X,
+		Y: 0.35 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = importRawDialog(settings, browse, windowSize, &responded)
+	return responded
+}
+
+func importRawDialog(settings *importRawSettings, browse *bool, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Import Raw", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	if imgui.InputText("File", &settings.path) {
+		*settings = settings.withFile(settings.path)
+	}
+	imgui.SameLine()
+	*browse = imgui.Button("Browse...")
+	imgui.InputInt("Width", &settings.width)
+	imgui.InputInt("Height", &settings.height)
+	imgui.InputInt("Channels", &settings.channels)
+	settings.width = max(settings.width, 1)
+	settings.height = max(settings.height, 1)
+	settings.channels = min(max(settings.channels, 1), 4)
+	imgui.Text("Type")
+	for t := hdrColors.DataType(0); t < hdrColors.DataTypesCount; t++ {
+		imgui.SameLine()
+		imgui.RadioButtonInt(t.String(), &settings.dataType, int(t))
+	}
+	imgui.InputInt("Skip bytes", &settings.offset)
+	settings.offset = max(settings.offset, 0)
+	needed := settings.layout().Size()
+	if settings.fileSize >= 0 {
+		imgui.Text(fmt.Sprintf("Reads %d of the file's %d bytes", min(needed, settings.fileSize), settings.fileSize))
+		if needed > settings.fileSize {
+			imgui.SameLine()
+			imgui.Text(fmt.Sprintf("(%d short)", needed-settings.fileSize))
+		}
+	} else {
+		imgui.Text(fmt.Sprintf("Reads %d bytes", needed))
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Import", buttonSize) && settings.path != "" {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
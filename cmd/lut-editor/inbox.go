@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/prefs"
+	"github.com/sqweek/dialog"
+)
+
+// inboxPollInterval is how often the inbox folder is checked for new files.
+const inboxPollInterval = 2 * time.Second
+
+// maxInboxListed limits the files listed in the Inbox window.
+const maxInboxListed = 10
+
+// inbox watches a folder for new or changed DDS and EXR files, such as those
+// an extractor dumps, and offers them for opening. Files already in the
+// folder when watching starts aren't offered.
+type inbox struct {
+	folder string
+	// pending are the files offered for opening, oldest first
+	pending []string
+	// folders are newly chosen folders to watch
+	folders chan string
+	arrived chan []string
+	stop    chan struct{}
+}
+
+func newInbox() *inbox {
+	return &inbox{
+		folders: make(chan string, 1),
+		arrived: make(chan []string, 8),
+	}
+}
+
+// chooseFolder asks for a folder to watch, which update starts watching.
+func (b *inbox) chooseFolder(prt *app.Printer, startDir string) {
+	folder, err := dialog.Directory().Title("Select inbox folder to watch").SetStartDir(startDir).Browse()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	b.folders <- folder
+}
+
+// watch starts watching folder in place of any folder watched before, or
+// stops watching if it is "".
+func (b *inbox) watch(folder string) {
+	if b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+	b.folder, b.pending = folder, nil
+	if folder != "" {
+		b.stop = make(chan struct{})
+		go watchInbox(folder, b.arrived, b.stop)
+	}
+}
+
+// update starts watching a newly chosen folder, keeping it in preferences,
+// and collects the files that have arrived. It is called every frame.
+func (b *inbox) update(prt *app.Printer, preferences *prefs.Preferences) {
+	for len(b.folders) > 0 {
+		folder := <-b.folders
+		b.watch(folder)
+		preferences.Inbox = folder
+		if err := prefs.Save(*preferences); err != nil {
+			prt.Errorf("failed to save preferences: %v", err)
+		}
+		prt.Infof("Watching inbox '%s'", folder)
+	}
+	for len(b.arrived) > 0 {
+		for _, path := range <-b.arrived {
+			// Files found just before the folder changed are dropped
+			if filepath.Dir(path) == b.folder && !slices.Contains(b.pending, path) {
+				b.pending = append(b.pending, path)
+			}
+		}
+	}
+}
+
+// watchInbox lists the files in folder every inboxPollInterval until stop is
+// closed, sending those that are new or changed to arrived.
+func watchInbox(folder string, arrived chan<- []string, stop <-chan struct{}) {
+	seen := make(map[string]time.Time)
+	if files, err := listTextures(folder); err == nil {
+		for _, file := range files {
+			seen[file.path] = file.modTime
+		}
+	}
+	// Files are only offered once they are unchanged between two checks, so
+	// ones still being written aren't opened half finished
+	changing := make(map[string]time.Time)
+	ticker := time.NewTicker(inboxPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		files, err := listTextures(folder)
+		if err != nil {
+			continue
+		}
+		var ready []string
+		for _, file := range files {
+			if modTime, ok := seen[file.path]; ok && modTime.Equal(file.modTime) {
+				continue
+			}
+			if modTime, ok := changing[file.path]; ok && modTime.Equal(file.modTime) {
+				delete(changing, file.path)
+				seen[file.path] = file.modTime
+				ready = append(ready, file.path)
+			} else {
+				changing[file.path] = file.modTime
+			}
+		}
+		if len(ready) == 0 {
+			continue
+		}
+		select {
+		case arrived <- ready:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// drawInboxWindow lists the files that have arrived in the inbox, and returns
+// those chosen to be opened. Opened and dismissed files are taken off the
+// list.
+func drawInboxWindow(b *inbox) (opened []string) {
+	viewport := imgui.MainViewport()
+	imgui.SetNextWindowPosV(imgui.Vec2{
+		X: viewport.Pos().X + viewport.Size().X - 16,
+		Y: viewport.Pos().Y + imgui.FrameHeight() + 16,
+	}, imgui.ConditionFirstUseEver, imgui.Vec2{X: 1, Y: 0})
+	imgui.BeginV("Inbox", nil, imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	imgui.Text(fmt.Sprintf("%d new in %s", len(b.pending), filepath.Base(b.folder)))
+	var dismissed []string
+	for i, path := range b.pending[:min(len(b.pending), maxInboxListed)] {
+		imgui.PushIDInt(i)
+		if imgui.Button("Open") {
+			opened = append(opened, path)
+		}
+		imgui.SameLine()
+		if imgui.Button("X") {
+			dismissed = append(dismissed, path)
+		}
+		imgui.SameLine()
+		imgui.Text(filepath.Base(path))
+		imgui.PopID()
+	}
+	if len(b.pending) > maxInboxListed {
+		textDisabled(fmt.Sprintf("and %d more", len(b.pending)-maxInboxListed))
+	}
+	if imgui.Button("Open All") {
+		opened = slices.Clone(b.pending)
+	}
+	imgui.SameLine()
+	if imgui.Button("Dismiss All") {
+		dismissed = slices.Clone(b.pending)
+	}
+	b.pending = slices.DeleteFunc(b.pending, func(path string) bool {
+		return slices.Contains(opened, path) || slices.Contains(dismissed, path)
+	})
+	return opened
+}
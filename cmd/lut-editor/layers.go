@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/layers"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+// docLayer is a layer of a document. While a layer is active, its image and
+// undo history are kept in the document's own img and undoStack, so every
+// edit works on it unchanged, and img, undo and redo here are stale.
+type docLayer struct {
+	layers.Layer
+	img        image.Image
+	undo, redo []types.UndoRedoState
+}
+
+// hasLayers reports whether the document is a stack of layers rather than a
+// single image.
+func (d *document) hasLayers() bool {
+	return len(d.layers) > 0
+}
+
+// layerImage returns the image of layer i.
+func (d *document) layerImage(i int) image.Image {
+	if i == d.activeLayer {
+		return d.img
+	}
+	return d.layers[i].img
+}
+
+// flattened returns the image the document's layers blend into, which is
+// what is shown and saved, or its image if it has no layers.
+func (d *document) flattened() image.Image {
+	if !d.hasLayers() || d.img == nil {
+		return d.img
+	}
+	if img := d.flatten(len(d.layers)); img != nil {
+		return img
+	}
+	return d.img
+}
+
+// flatten blends the bottom n layers into a new image, or returns nil if they
+// can't be.
+func (d *document) flatten(n int) image.Image {
+	out := editor.Copy(d.img, d.img.Bounds())
+	raw, ok := rawImage(out)
+	if !ok {
+		return nil
+	}
+	stack := make([]layers.Layer, n)
+	images := make([]hdrColors.RawImage, n)
+	for i := range n {
+		stack[i] = d.layers[i].Layer
+		if images[i], ok = rawImage(d.layerImage(i)); !ok {
+			return nil
+		}
+	}
+	if err := layers.Flatten(raw, stack, images); err != nil {
+		return nil
+	}
+	return out
+}
+
+// selectLayer makes layer i the one edits are made to, swapping its image and
+// undo history into the document.
+func (d *document) selectLayer(i int) {
+	if i == d.activeLayer || i < 0 || i >= len(d.layers) {
+		return
+	}
+	d.undoStack.Flush()
+	active := &d.layers[d.activeLayer]
+	active.img, active.undo, active.redo = d.img, d.undoStack.UndoStack, d.undoStack.RedoStack
+	next := d.layers[i]
+	d.img, d.undoStack.UndoStack, d.undoStack.RedoStack = next.img, next.undo, next.redo
+	d.activeLayer = i
+	d.provenanceRedo = len(d.undoStack.RedoStack)
+	d.refreshSprites = true
+}
+
+// addLayer adds a layer blended with blend above the active layer and selects
+// it. Normal layers start as a copy of what the layers up to the active one
+// blend into, and the others with the values that leave it unchanged, so the
+// image looks the same until the new layer is edited.
+func (d *document) addLayer(blend layers.Blend, currColor [4]float32) {
+	if d.img == nil {
+		return
+	}
+	if !d.hasLayers() {
+		d.layers = []docLayer{{Layer: layers.New("Base", layers.BlendNormal)}}
+		d.activeLayer = 0
+	}
+	img := d.flatten(d.activeLayer + 1)
+	if img == nil {
+		return
+	}
+	if value, ok := blend.Identity(); ok {
+		raw, _ := rawImage(img)
+		bounds := raw.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				raw.SetRaw(x, y, value)
+			}
+		}
+	}
+	layer := layers.New(fmt.Sprintf("Layer %d", len(d.layers)), blend)
+	d.insertLayer(docLayer{Layer: layer, img: img}, currColor)
+}
+
+// duplicateLayer adds a copy of the active layer above it and selects it.
+func (d *document) duplicateLayer(currColor [4]float32) {
+	if !d.hasLayers() || d.img == nil {
+		return
+	}
+	layer := d.layers[d.activeLayer].Layer
+	layer.Name += " copy"
+	d.insertLayer(docLayer{Layer: layer, img: editor.Copy(d.img, d.img.Bounds())}, currColor)
+}
+
+// insertLayer puts layer above the active layer, with a history starting from
+// its image, and selects it.
+func (d *document) insertLayer(layer docLayer, currColor [4]float32) {
+	layer.undo = []types.UndoRedoState{types.NewUndoRedoState("New Layer", d.fileName, d.saved, layer.img, currColor, d.selection)}
+	layer.redo = make([]types.UndoRedoState, 0)
+	d.layers = slices.Insert(d.layers, d.activeLayer+1, layer)
+	d.selectLayer(d.activeLayer + 1)
+	d.layerEdited("New Layer")
+}
+
+// deleteLayer removes the active layer, with its history, and selects the one
+// below it. Once a single plain layer is left, the document goes back to
+// being a single image.
+func (d *document) deleteLayer() {
+	if len(d.layers) < 2 {
+		return
+	}
+	deleting := d.activeLayer
+	if deleting > 0 {
+		d.selectLayer(deleting - 1)
+	} else {
+		d.selectLayer(1)
+	}
+	d.layers = slices.Delete(d.layers, deleting, deleting+1)
+	if d.activeLayer > deleting {
+		d.activeLayer--
+	}
+	if len(d.layers) == 1 && d.layers[0].Layer == layers.New(d.layers[0].Name, layers.BlendNormal) {
+		d.layers, d.activeLayer = nil, 0
+	}
+	d.layerEdited("Delete Layer")
+}
+
+// moveLayer moves the active layer up or down the stack by offset.
+func (d *document) moveLayer(offset int) {
+	to := d.activeLayer + offset
+	if !d.hasLayers() || to < 0 || to >= len(d.layers) {
+		return
+	}
+	d.layers[d.activeLayer], d.layers[to] = d.layers[to], d.layers[d.activeLayer]
+	d.activeLayer = to
+	d.layerEdited("Move Layer")
+}
+
+// layerEdited notes a change to the layer stack, which changes the image
+// without an undo step.
+func (d *document) layerEdited(action string) {
+	d.layerChange = action
+	d.saved = false
+	d.refreshSprites = true
+}
+
+// drawLayersWindow lists the layers of the document, top first, to select,
+// hide, reorder and blend them.
+func drawLayersWindow(doc *document, currColor [4]float32, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 300, Y: 360}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Layers", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	if doc.img == nil {
+		textDisabled("No image open")
+		return
+	}
+	if imgui.Button("Add Layer") {
+		imgui.OpenPopup("addLayer")
+	}
+	if imgui.BeginPopup("addLayer") {
+		for _, blend := range layers.Blends {
+			if imgui.MenuItem(blend.String()) {
+				doc.addLayer(blend, currColor)
+			}
+		}
+		imgui.EndPopup()
+	}
+	if !doc.hasLayers() {
+		textDisabled("The image is a single layer. Add layers to try out")
+		textDisabled("changes over it, blended in when shown and saved")
+		return
+	}
+	imgui.SameLine()
+	if imgui.Button("Duplicate") {
+		doc.duplicateLayer(currColor)
+	}
+	imgui.SameLine()
+	if imgui.Button("Delete") {
+		doc.deleteLayer()
+	}
+	imgui.SameLine()
+	if imgui.Button("Up") {
+		doc.moveLayer(1)
+	}
+	imgui.SameLine()
+	if imgui.Button("Down") {
+		doc.moveLayer(-1)
+	}
+	if !doc.hasLayers() {
+		return
+	}
+
+	active := &doc.layers[doc.activeLayer]
+	imgui.InputText("Name", &active.Name)
+	opacity := float32(active.Opacity)
+	if imgui.SliderFloatV("Opacity", &opacity, 0, 1, "%.2f", 0) {
+		active.Opacity = float64(opacity)
+		doc.layerEdited("Layer Opacity")
+	}
+	if imgui.BeginCombo("Blend", active.Blend.String()) {
+		for _, blend := range layers.Blends {
+			if imgui.SelectableV(blend.String(), blend == active.Blend, 0, imgui.Vec2{}) && blend != active.Blend {
+				active.Blend = blend
+				doc.layerEdited("Layer Blend")
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.Separator()
+
+	for i := len(doc.layers) - 1; i >= 0; i-- {
+		imgui.PushIDInt(i)
+		if imgui.Checkbox("##visible", &doc.layers[i].Visible) {
+			doc.layerEdited("Layer Visibility")
+		}
+		imgui.SameLine()
+		label := doc.layers[i].Name
+		if doc.layers[i].Blend != layers.BlendNormal {
+			label += fmt.Sprintf(" (%s)", doc.layers[i].Blend)
+		}
+		if imgui.SelectableV(label, i == doc.activeLayer, 0, imgui.Vec2{}) {
+			doc.selectLayer(i)
+		}
+		imgui.PopID()
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// ddsLevel is an array slice and mipmap of a DDS to sample colors from.
+type ddsLevel struct {
+	image, mip int
+}
+
+func (l ddsLevel) String() string {
+	return fmt.Sprintf("image %d, mip %d", l.image, l.mip)
+}
+
+// ddsLevels returns the document's image as a DDS, if it has array slices or
+// mipmaps besides the first.
+func (d *document) ddsLevels() (*dds.DDS, bool) {
+	ddsImg, ok := d.img.(*dds.DDS)
+	if !ok || len(ddsImg.Images) == 0 {
+		return nil, false
+	}
+	return ddsImg, len(ddsImg.Images) > 1 || len(ddsImg.Images[0].MipMaps) > 1
+}
+
+// sampledLevel returns the image colors are sampled from, or nil for the
+// image being edited.
+func (d *document) sampledLevel() image.Image {
+	ddsImg, ok := d.ddsLevels()
+	level := d.sampleLevel
+	if !ok || level == (ddsLevel{}) || level.image >= len(ddsImg.Images) || level.mip >= len(ddsImg.Images[level.image].MipMaps) {
+		return nil
+	}
+	return ddsImg.Images[level.image].MipMaps[level.mip].Image
+}
+
+// sampleColor is getImgColorAtCoords on the document's image, reading from the
+// chosen DDS level instead where one is. x and y are scaled down to the
+// level's size.
+func (d *document) sampleColor(prt *app.Printer, x, y int, viewedChannel hdrColors.GraySetting) [4]float32 {
+	level := d.sampledLevel()
+	if level == nil {
+		return getImgColorAtCoords(prt, d.img, x, y, viewedChannel)
+	}
+	w, h := d.img.Bounds().Dx(), d.img.Bounds().Dy()
+	// Rows are counted from the bottom, as getImgColorAtCoords takes them
+	if x < 0 || y < 0 || x >= w || y >= h {
+		return [4]float32{}
+	}
+	lw, lh := level.Bounds().Dx(), level.Bounds().Dy()
+	top := (h - y - 1) * lh / h
+	return getImgColorAtCoords(prt, level, x*lw/w, lh-top-1, viewedChannel)
+}
+
+// drawSampleLevelCombo adds the choice of DDS level to sample colors from to
+// the File Info window.
+func drawSampleLevelCombo(doc *document) {
+	ddsImg, ok := doc.ddsLevels()
+	if !ok {
+		return
+	}
+	if imgui.BeginCombo("Sample colors from", doc.sampleLevel.String()) {
+		for i, img := range ddsImg.Images {
+			for j, mip := range img.MipMaps {
+				level := ddsLevel{image: i, mip: j}
+				label := fmt.Sprintf("%s (%dx%d)", level, mip.Bounds().Dx(), mip.Bounds().Dy())
+				if imgui.SelectableV(label, level == doc.sampleLevel, 0, imgui.Vec2{}) {
+					doc.sampleLevel = level
+				}
+			}
+		}
+		imgui.EndCombo()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("The level the status bar and color picker read, scaled to fit the image.\nEdits are always made to image 0, mip 0")
+	}
+}
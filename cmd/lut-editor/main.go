@@ -2,18 +2,22 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	_ "embed"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"io"
+	"io/fs"
 	"math"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime/debug"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/gopxl/pixel/v2"
 	"github.com/gopxl/pixel/v2/backends/opengl"
@@ -24,13 +28,30 @@ import (
 	"github.com/inkyblackness/imgui-go/v4"
 	"github.com/jwalton/go-supportscolor"
 	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/changes"
 	"github.com/ryanjsims/hd2-lut-editor/clipboard"
 	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/editlog"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/fileinfo"
+	"github.com/ryanjsims/hd2-lut-editor/filter"
 	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+	"github.com/ryanjsims/hd2-lut-editor/icc"
+	"github.com/ryanjsims/hd2-lut-editor/instance"
+	"github.com/ryanjsims/hd2-lut-editor/linked"
 	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/precision"
+	"github.com/ryanjsims/hd2-lut-editor/prefs"
+	"github.com/ryanjsims/hd2-lut-editor/presets"
+	"github.com/ryanjsims/hd2-lut-editor/preview"
+	"github.com/ryanjsims/hd2-lut-editor/project"
+	"github.com/ryanjsims/hd2-lut-editor/provenance"
+	"github.com/ryanjsims/hd2-lut-editor/shell"
+	"github.com/ryanjsims/hd2-lut-editor/tablet"
 	"github.com/ryanjsims/hd2-lut-editor/types"
 	"github.com/sqweek/dialog"
-	"github.com/x448/float16"
 )
 
 var (
@@ -43,53 +64,44 @@ const (
 	toolDraw         lmbTool = iota
 	toolSelect       lmbTool = iota
 	toolMoveSelected lmbTool = iota
+	toolZoom         lmbTool = iota
+	toolErase        lmbTool = iota
 )
 
-const baseTitle string = "Helldiver 2 LUT Editor"
-
-func run() {
-	logFile, err := os.OpenFile("lut-editor.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		logFile = os.Stderr
-	} else {
-		defer logFile.Close()
-	}
+// paints reports whether the tool changes texels under the brush. The
+// selection isn't shown while it is active.
+func (t lmbTool) paints() bool {
+	return t == toolDraw || t == toolErase
+}
 
-	prt := app.NewPrinter(
-		supportscolor.SupportsColor(logFile.Fd(), supportscolor.SniffFlagsOption(true)).SupportsColor,
-		logFile,
-		logFile,
-	)
+const baseTitle string = "Helldiver 2 LUT Editor"
 
+func run(prt *app.Printer, imagePaths []string, singleInstance bool) {
 	defer func() {
 		if r := recover(); r != nil {
 			prt.Errorf("panic: %v\n%s", r, debug.Stack())
 		}
 	}()
 
-	err = clipboard.Init()
+	err := clipboard.Init()
 	if err != nil {
 		fmt.Println(err)
 	}
 
-	parser := argparse.NewParser(
-		"lut_editor",
-		"An HDR pixel editor, made for editing Helldivers 2 material LUTs in floating point image formats",
-		&argparse.ParserConfig{
-			DisableDefaultShowHelp: true,
-		},
-	)
-	imagePath := parser.String("p", "path", &argparse.Option{
-		Positional: true,
-		Help:       "Path to an EXR or HDR DDS image to load",
-		Required:   false,
-	})
-
-	if err = parser.Parse(nil); err != nil {
-		if err == argparse.BreakAfterHelpError {
+	forwardedPaths := make(chan []string, 8)
+	if singleInstance {
+		paths := forwardablePaths(imagePaths)
+		if forwarded, err := instance.Forward(paths); err != nil {
+			prt.Errorf("Forwarding to running instance: %v", err)
+		} else if forwarded {
+			prt.Infof("Opened %d path(s) in running instance", len(paths))
 			os.Exit(0)
 		}
-		prt.Fatalf("%v", err)
+		if listener, err := instance.Listen(forwardedPaths); err != nil {
+			prt.Errorf("Listening for other instances: %v", err)
+		} else {
+			defer listener.Close()
+		}
 	}
 
 	cfg := opengl.WindowConfig{
@@ -103,6 +115,12 @@ func run() {
 		prt.Fatalf("%v", err)
 	}
 
+	penTablet, err := tablet.Open()
+	if err != nil {
+		prt.Infof("Pen tablet not detected, drawing at full pressure: %v", err)
+	}
+	defer penTablet.Close()
+
 	clearColor := color.RGBA{
 		R: 0x55,
 		G: 0x55,
@@ -113,166 +131,500 @@ func run() {
 
 	ui := pixelui.New(win, &Atlas, 0)
 
+	toolCursors := map[lmbTool]*opengl.Cursor{
+		toolDraw:         opengl.CreateStandardCursor(opengl.CrosshairCursor),
+		toolSelect:       opengl.CreateStandardCursor(opengl.CrosshairCursor),
+		toolMoveSelected: opengl.CreateStandardCursor(opengl.HandCursor),
+		toolZoom:         opengl.CreateStandardCursor(opengl.CrosshairCursor),
+		toolErase:        opengl.CreateStandardCursor(opengl.CrosshairCursor),
+	}
+	arrowCursor := opengl.CreateStandardCursor(opengl.ArrowCursor)
+	currCursor := arrowCursor
+
 	var (
-		camPos                   = pixel.ZV
-		camZoom                  = 24.0
-		camZoomSpeed             = 1.05
-		dragStart                = pixel.ZV
-		currColor                = [4]float32{0.0, 0.0, 0.0, 0.0}
-		precision         int32  = 3
-		fileName          string = ""
-		saved             bool   = true
-		refreshSprites    bool   = false
-		channelsVisible   bool   = true
-		colorVisible      bool   = true
-		gridVisible       bool   = true
-		toolsVisible      bool   = true
-		newImageConfirm   bool
-		newImageWidth     int32                 = 23
-		newImageHeight    int32                 = 8
-		newImagePrecision int                   = 0
-		response          types.MenuResponse    = types.MenuResponseNone
-		viewedChannel     hdrColors.GraySetting = hdrColors.GraySettingNoAlpha
-		lastChannel       hdrColors.GraySetting = hdrColors.GraySettingNoAlpha
-		undoStack         types.UndoRedoStack   = types.UndoRedoStack{
-			UndoStack: make([]types.UndoRedoState, 0),
-			RedoStack: make([]types.UndoRedoState, 0),
-		}
-		backgroundTasks = make(types.TaskMap)
-		img             image.Image
-		pasteImg        image.Image
-		pastePic        *pixel.PictureData
-		pasteSprite     *pixel.Sprite
-		tool            lmbTool = toolDraw
-		prevTool        lmbTool = toolDraw
-		selection               = pixel.ZR
-		selectionStart          = pixel.ZV
-		selectionEnd            = pixel.ZV
-		selectionOffset         = pixel.ZV
+		camZoomSpeed                             = 1.05
+		trackpadPanSpeed                         = 20.0
+		dragStart                                = pixel.ZV
+		zoomStart                                = pixel.ZV
+		zooming                                  = false
+		currColor                                = [4]float32{0.0, 0.0, 0.0, 0.0}
+		precision          int32                 = 3
+		channelsVisible    bool                  = true
+		colorVisible       bool                  = true
+		gridVisible        bool                  = true
+		toolsVisible       bool                  = true
+		trackpadMode       bool                  = false
+		newImage                                 = newImageSettings{width: 23, height: 8, schemaDefaults: true}
+		response           types.MenuResponse    = types.MenuResponseNone
+		viewedChannel      hdrColors.GraySetting = hdrColors.GraySettingNoAlpha
+		backgroundTasks                          = make(types.TaskMap)
+		tool               lmbTool               = toolDraw
+		prevTool           lmbTool               = toolDraw
+		toolDoc            *document             = nil
+		dragHeld           bool                  = false
+		docs                                     = []*document{newDocument("", nil, true)}
+		activeDoc          int                   = 0
+		selectDoc          int                   = -1
+		closingDoc         int                   = -1
+		openedDocs                               = make(chan *document, 8)
+		previewOptions                           = preview.DefaultOptions()
+		beforeAfter                              = defaultBeforeAfterSettings()
+		bulkSettings                             = defaultBulkConvertSettings()
+		headerComparisons                        = make(chan *headerComparison, 1)
+		comparedHeaders    *headerComparison     = nil
+		headersVisible     bool                  = false
+		saveConversion                           = defaultConversionSettings()
+		conversionChoice                         = defaultConversionSettings()
+		conversionReports                        = make(chan *conversionReport, 1)
+		conversionShown    *conversionReport     = nil
+		conversionVisible  bool                  = false
+		savedPaths                               = make(chan string, 4)
+		hookChoice                               = prefs.Hook{}
+		fileInfoVisible    bool                  = false
+		colorManaged                             = colorManagement{enabled: true}
+		displayProfiles                          = make(chan *displayProfile, 1)
+		committedImages                          = make(chan *committedImage, 1)
+		browser                                  = newFileBrowser()
+		inboxWatcher                             = newInbox()
+		baselineLib                              = newBaselineLibrary(prt, backgroundTasks)
+		baselineLibVisible bool                  = false
+		presetLib                                = loadPresetLibrary(prt)
+		presetsVisible     bool                  = false
+		importedRows                             = make(chan *presets.SharedRow, 1)
+		importedEditLogs                         = make(chan *editlog.Log, 1)
+		mergeSessions                            = make(chan *mergeSession, 1)
+		merging            *mergeSession         = nil
+		mergeVisible       bool                  = false
+		schemaPacks                              = make(chan int, 1)
+		openAsFiles                              = make(chan string, 1)
+		openAsChoice                             = defaultOpenAsSettings()
+		rawFiles                                 = make(chan string, 1)
+		importRawChoice                          = defaultImportRawSettings()
+		browserVisible     bool                  = false
+		rampSamples                              = newRampSampler()
+		rampVisible        bool                  = false
+		chooseRamp         bool                  = false
+		chooseBrowsed      bool                  = false
+		openURLText        string                = ""
+		schemaURLText      string                = ""
+		shiftX             int32                 = 0
+		shiftY             int32                 = 0
+		repeatChoice                             = repeatSettings{count: 5, dy: 1}
+		blendMode          blend.Mode            = blend.Replace
+		channelLock        blend.Lock            = blend.Lock{}
+		brush                                    = defaultBrushSettings()
+		stroke             *editor.Stroke        = nil
+		lastDab            image.Point           = image.Pt(-1, -1)
+		mirror             mirrorMode            = mirrorOff
+		snap               snapMode              = snapOff
+		pasteFrom          int                   = 0
+		pasteInto          int                   = 0
+		interpolateColumns bool                  = false
+		interpolateEasing  int                   = int(filter.EasingLinear)
+		quantizeSettings                         = quantizeSettings{step: 0.1}
+		recolorChoice                            = defaultRecolorSettings()
+		selectAmount       int32                 = 1
+		goToChoice         goToSettings          = goToSettings{}
+		findChoice                               = defaultFindSettings()
+		searched           bool                  = false
+		featherRadius      int32                 = 0
+		featherChoice      int32                 = 0
+		graphVisible       bool                  = false
+		bytesVisible       bool                  = false
+		consoleVisible     bool                  = false
+		memoryVisible      bool                  = false
+		historyVisible     bool                  = false
+		rowFilterVisible   bool                  = false
+		keepUndo           int32                 = 20
+		commandConsole     console
+		inspectedTexel     = image.Pt(-1, -1)
+		graph              graphSettings
+		metadata           metadataInput
+		usage              patternUsage
+		jitterMin          float32    = -0.05
+		jitterMax          float32    = 0.05
+		jitterSeed         int32      = 1
+		gradientMapChoice             = defaultGradientMapSettings()
+		projectVisible     bool       = false
+		layersVisible      bool       = false
+		labelsVisible      bool       = false
+		tooltipsVisible    bool       = true
+		usageVisible       bool       = false
+		schemaEditVisible  bool       = false
+		schemaEditing                 = newSchemaEditor()
+		selectionName      string     = ""
+		rowStyleCopied     *rowStyle  = nil
+		rowStyleChoice                = defaultRowStyleSettings()
+		linkedLUTs         *workspace = nil
+		linkChoice                    = linkSettings{pattern: -1}
+		swapRows           [2]int32
+		lockedVisible      bool = true
+		violationsVisible  bool = true
+		groupsVisible      bool = true
+		smoothZoom         bool = true
 	)
 
-	if imagePath != nil && len(*imagePath) > 0 {
-		img, err = loadImage(*imagePath)
-
-		if err != nil {
-			prt.Errorf("Loading image '%s': %v", *imagePath, err)
-			img = nil
-		} else {
-			lastChannel = hdrColors.GraySettingNone
-			newImageWidth = int32(img.Bounds().Dx())
-			newImageHeight = int32(img.Bounds().Dy())
-			undoStack.Push("Load File", *imagePath, true, img, currColor, selection)
-		}
+	if path, err := icc.DisplayProfilePath(); err != nil {
+		prt.Infof("Display profile not detected, assuming sRGB: %v", err)
+	} else if profile, err := icc.Load(path); err != nil {
+		prt.Errorf("Loading display profile '%s': %v", path, err)
+	} else {
+		colorManaged.setProfile(profile, profileName(profile, path))
 	}
 
-	newImageConfirm = img == nil
+	preferences, err := prefs.Load()
+	if err != nil {
+		prt.Errorf("Loading preferences: %v", err)
+	}
+	hookChoice = preferences.SaveHook
+	schemaURLText = preferences.SchemaURL
+	inboxWatcher.watch(preferences.Inbox)
+	recentColors, err := prefs.LoadRecentColors()
+	if err != nil {
+		prt.Errorf("Loading recent colors: %v", err)
+	}
 
-	var pic *pixel.PictureData
-	var sprite *pixel.Sprite
-	if img != nil {
-		pic = pixel.PictureDataFromImage(img)
-		sprite = pixel.NewSprite(pic, pic.Bounds())
+	schemas, err := help.LoadAll(help.Paths())
+	if err != nil {
+		prt.Errorf("Loading schemas: %v", err)
 	}
 
-	if imagePath != nil {
-		fileName = *imagePath
+	for _, imagePath := range imagePaths {
+		if isURL(imagePath) {
+			go openURL(prt, imagePath, openedDocs, currColor, backgroundTasks.Add("Download"))
+			continue
+		} else if len(imagePath) == 0 {
+			continue
+		}
+		loadedDoc, err := loadDocument(imagePath, currColor, nil)
+		if err != nil {
+			prt.Errorf("Loading image '%s': %v", imagePath, err)
+			continue
+		}
+		loadedDoc.detectSchema(prt, schemas)
+		loadedDoc.restoreRecentColors(recentColors)
+		if docs[0].empty() {
+			newImage.width = int32(loadedDoc.img.Bounds().Dx())
+			newImage.height = int32(loadedDoc.img.Bounds().Dy())
+			docs[0] = loadedDoc
+		} else {
+			docs = append(docs, loadedDoc)
+		}
 	}
 
 	for !win.Closed() {
 		ui.NewFrame()
 		win.Clear(clearColor)
-		if refreshSprites && img != nil {
-			refreshSprites = false
-			pic = pixel.PictureDataFromImage(img)
-			if sprite != nil {
-				sprite.Set(pic, pic.Bounds())
+
+		for len(openedDocs) > 0 {
+			opened := <-openedDocs
+			if opened.schema == nil {
+				opened.detectSchema(prt, schemas)
+			}
+			opened.restoreRecentColors(recentColors)
+			if docs[activeDoc].empty() {
+				docs[activeDoc] = opened
+			} else {
+				docs = append(docs, opened)
+				activeDoc = len(docs) - 1
+			}
+			selectDoc = activeDoc
+			if opened.projectView != nil {
+				viewedChannel = hdrColors.GraySetting(opened.projectView.Channel)
+				gridVisible = opened.projectView.Grid
+				opened.projectView = nil
+			}
+		}
+		for len(forwardedPaths) > 0 {
+			for _, path := range <-forwardedPaths {
+				if isURL(path) {
+					go openURL(prt, path, openedDocs, currColor, backgroundTasks.Add("Download"))
+				} else {
+					go openPath(prt, path, openedDocs, currColor, backgroundTasks.Add("Open"))
+				}
+			}
+			win.Focus()
+		}
+		for len(displayProfiles) > 0 {
+			chosen := <-displayProfiles
+			colorManaged.setProfile(chosen.profile, chosen.name)
+			for _, d := range docs {
+				d.refreshSprites = d.img != nil
+			}
+		}
+		for len(committedImages) > 0 {
+			committed := <-committedImages
+			for _, d := range docs {
+				if d.id == committed.docID && d.img != nil {
+					d.compareWithCommitted(prt, committed, currColor, viewedChannel)
+				}
+			}
+		}
+		browser.update(prt, backgroundTasks)
+		inboxWatcher.update(prt, &preferences)
+		baselineLib.update(prt, backgroundTasks, docs)
+		for _, d := range docs {
+			d.undoStack.SetCheckpointEvery(int(preferences.Undo.Checkpoint))
+			if preferences.Provenance {
+				d.trackProvenance(prt)
+			}
+		}
+		presetLib.update(prt)
+		rampSamples.update()
+		doc := docs[activeDoc]
+		if doc != toolDoc {
+			switchToolDocument(toolDoc, doc, &tool, &prevTool)
+			toolDoc = doc
+			stroke, lastDab, zooming = nil, image.Pt(-1, -1), false
+			// A button still held from the last tab starts nothing in this one
+			dragHeld = ui.Pressed(pixel.MouseButtonLeft)
+		}
+		if !ui.Pressed(pixel.MouseButtonLeft) {
+			dragHeld = false
+		}
+		for len(importedRows) > 0 {
+			doc.applySharedRow(prt, <-importedRows, currColor, channelLock)
+		}
+		for len(importedEditLogs) > 0 {
+			doc.applyEditLog(prt, <-importedEditLogs, currColor, channelLock)
+		}
+		for len(openAsFiles) > 0 {
+			openAsChoice = openAsChoice.withFile(<-openAsFiles)
+		}
+		for len(rawFiles) > 0 {
+			importRawChoice = importRawChoice.withFile(<-rawFiles)
+		}
+
+		if doc.refreshSprites && doc.img != nil {
+			doc.refreshSprites = false
+			doc.recordProvenance()
+			doc.pic = pixel.PictureDataFromImage(doc.displayImage(doc.flattened(), viewedChannel, colorManaged))
+			if doc.sprite != nil {
+				doc.sprite.Set(doc.pic, doc.pic.Bounds())
 			} else {
-				sprite = pixel.NewSprite(pic, pic.Bounds())
+				doc.sprite = pixel.NewSprite(doc.pic, doc.pic.Bounds())
 			}
 
-			if pasteImg != nil {
-				pastePic = pixel.PictureDataFromImage(pasteImg)
-				if pasteSprite != nil {
-					pasteSprite.Set(pastePic, pastePic.Bounds())
+			if doc.pasteImg != nil {
+				doc.pastePic = pixel.PictureDataFromImage(doc.displayImage(doc.pasteImg, viewedChannel, colorManaged))
+				if doc.pasteSprite != nil {
+					doc.pasteSprite.Set(doc.pastePic, doc.pastePic.Bounds())
 				} else {
-					pasteSprite = pixel.NewSprite(pastePic, pastePic.Bounds())
+					doc.pasteSprite = pixel.NewSprite(doc.pastePic, doc.pastePic.Bounds())
 				}
 			}
 		}
 
-		cam := pixel.IM.Scaled(camPos, camZoom).Moved(win.Bounds().Center().Sub(camPos))
+		cam := pixel.IM.Scaled(doc.camPos, doc.camZoom).Moved(win.Bounds().Center().Sub(doc.camPos))
 
 		if ui.JustPressed(pixel.MouseButtonMiddle) {
 			dragStart = cam.Unproject(win.MousePosition())
 		} else if ui.Pressed(pixel.MouseButtonMiddle) {
-			tempCamPos := camPos.Sub(cam.Unproject(win.MousePosition()).Sub(dragStart))
-			cam = pixel.IM.Scaled(tempCamPos, camZoom).Moved(win.Bounds().Center().Sub(tempCamPos))
+			tempCamPos := doc.camPos.Sub(cam.Unproject(win.MousePosition()).Sub(dragStart))
+			cam = pixel.IM.Scaled(tempCamPos, doc.camZoom).Moved(win.Bounds().Center().Sub(tempCamPos))
 		} else if ui.JustReleased(pixel.MouseButtonMiddle) {
-			camPos = camPos.Sub(cam.Unproject(win.MousePosition()).Sub(dragStart))
-			cam = pixel.IM.Scaled(camPos, camZoom).Moved(win.Bounds().Center().Sub(camPos))
+			doc.camPos = doc.camPos.Sub(cam.Unproject(win.MousePosition()).Sub(dragStart))
+			cam = pixel.IM.Scaled(doc.camPos, doc.camZoom).Moved(win.Bounds().Center().Sub(doc.camPos))
+		}
+
+		if ui.Pressed(pixel.MouseButtonRight) && doc.sprite != nil {
+			x, y := getPixelCoords(cam, doc.sprite.Frame().Center(), win.MousePosition())
+			currColor = doc.sampleColor(prt, x, y, viewedChannel)
+			doc.undoStack.DelayedPush(undoDelay(preferences.Undo), "Pick Color", &doc.fileName, &doc.saved, &doc.img, &currColor, &doc.selection)
 		}
 
-		if ui.Pressed(pixel.MouseButtonRight) && sprite != nil {
-			x, y := getPixelCoords(cam, sprite.Frame().Center(), win.MousePosition())
-			currColor = getImgColorAtCoords(prt, img, x, y, viewedChannel)
-			undoStack.DelayedPush(1*time.Second, "Pick Color", &fileName, &saved, &img, &currColor, &selection)
+		pen := penTablet.Pen()
+		// The stylus's eraser tip erases whichever tool is chosen
+		lmb := tool
+		if pen.Erasing() {
+			lmb = toolErase
 		}
 
-		if ui.Pressed(pixel.MouseButtonLeft) && sprite != nil {
-			x, y := getPixelCoords(cam, sprite.Frame().Center(), win.MousePosition())
-			y = img.Bounds().Dy() - y - 1
+		if ui.JustPressed(pixel.MouseButtonLeft) {
+			// Each stroke builds up its own coverage of the texels
+			stroke, lastDab = nil, image.Pt(-1, -1)
+		}
+		if ui.Pressed(pixel.MouseButtonLeft) && doc.sprite != nil && !dragHeld {
+			x, y := getPixelCoords(cam, doc.sprite.Frame().Center(), win.MousePosition())
+			y = doc.img.Bounds().Dy() - y - 1
 			point := image.Rect(x, y, x, y)
-			if point.In(img.Bounds()) {
-				switch tool {
-				case toolDraw:
-					setHDRFromFloats(x, y, currColor, img)
-					refreshSprites = true
-					saved = false
-					undoStack.DelayedPush(1*time.Second, "Draw", &fileName, &saved, &img, &currColor, &selection)
+			if point.In(doc.img.Bounds()) {
+				switch lmb {
+				case toolDraw, toolErase:
+					if stroke == nil {
+						stroke = brush.newStroke()
+						if lmb == toolDraw {
+							if err := doc.useColor(recentColors, currColor); err != nil {
+								prt.Errorf("failed to save recent colors: %v", err)
+							}
+						}
+					} else if point.Min == lastDab {
+						// Only moving onto another texel dabs again, so
+						// holding the button still doesn't keep adding
+						break
+					}
+					lastDab = point.Min
+					draw := editor.Draw{
+						Color:  [4]float64{float64(currColor[0]), float64(currColor[1]), float64(currColor[2]), float64(currColor[3])},
+						Mode:   blendMode,
+						Lock:   channelLock,
+						Stroke: stroke,
+					}
+					action := "Draw"
+					if lmb == toolErase {
+						draw.Color, draw.Mode, draw.Lock = [4]float64{}, blend.Replace, channelLock.Or(brush.eraseLock())
+						action = "Erase"
+					}
+					pressure, value := brush.pressureScales(pen)
+					for i := range 3 {
+						draw.Color[i] *= value
+					}
+					// Where mirrored or repeated dabs overlap, texels take the
+					// most color any of them gives
+					dabbed := make(map[image.Point]int)
+					points, coverage := brush.dab(point.Min)
+					for i := range coverage {
+						coverage[i] *= pressure
+					}
+					for i, center := range points {
+						for _, p := range doc.multiRowPoints(mirrorPoints(center, doc.mirrorArea(), mirror)) {
+							if !p.In(doc.img.Bounds()) || doc.locked.Contains(p) {
+								continue
+							}
+							if j, ok := dabbed[p]; ok {
+								draw.Coverage[j] = max(draw.Coverage[j], coverage[i])
+								continue
+							}
+							dabbed[p] = len(draw.Points)
+							draw.Points = append(draw.Points, p)
+							draw.Coverage = append(draw.Coverage, coverage[i])
+						}
+					}
+					if len(draw.Points) == 0 {
+						break
+					}
+					keep := doc.keepRelations(draw.Lock)
+					err := doc.apply(draw)
+					keep()
+					if err != nil {
+						prt.Errorf("failed to %s: %v", strings.ToLower(action), err)
+						break
+					}
+					doc.undoStack.DelayedPush(undoDelay(preferences.Undo), action, &doc.fileName, &doc.saved, &doc.img, &currColor, &doc.selection)
 				case toolSelect:
 					mousePos := cam.Unproject(win.MousePosition())
-					clampedX := math.Max(0, math.Min(float64(x), float64(img.Bounds().Dx())))
-					clampedY := math.Max(0, math.Min(float64(y), float64(img.Bounds().Dy())))
+					clampedX := math.Max(0, math.Min(float64(x), float64(doc.img.Bounds().Dx())))
+					clampedY := math.Max(0, math.Min(float64(y), float64(doc.img.Bounds().Dy())))
 					if ui.JustPressed(pixel.MouseButtonLeft) {
-						selectionStart = fromPixelCoords(cam, sprite.Frame().Center(), int(clampedX), img.Bounds().Dy()-int(clampedY))
+						doc.selectionStart = fromPixelCoords(cam, doc.sprite.Frame().Center(), int(clampedX), doc.img.Bounds().Dy()-int(clampedY))
 					}
-					if selectionStart.X < mousePos.X {
-						clampedX = math.Max(0, math.Min(float64(x+1), float64(img.Bounds().Dx())))
+					if doc.selectionStart.X < mousePos.X {
+						clampedX = math.Max(0, math.Min(float64(x+1), float64(doc.img.Bounds().Dx())))
 					}
-					if selectionStart.Y > mousePos.Y {
-						clampedY = math.Max(0, math.Min(float64(y+1), float64(img.Bounds().Dy())))
+					if doc.selectionStart.Y > mousePos.Y {
+						clampedY = math.Max(0, math.Min(float64(y+1), float64(doc.img.Bounds().Dy())))
 					}
-					selectionEnd = fromPixelCoords(cam, sprite.Frame().Center(), int(clampedX), img.Bounds().Dy()-int(clampedY))
-					selection.Min = selectionStart
-					selection.Max = selectionEnd
-					selection = selection.Norm()
-					undoStack.DelayedPush(1*time.Second, "Change Selection", &fileName, &saved, &img, &currColor, &selection)
+					doc.selectionEnd = fromPixelCoords(cam, doc.sprite.Frame().Center(), int(clampedX), doc.img.Bounds().Dy()-int(clampedY))
+					doc.selection.Min = doc.selectionStart
+					doc.selection.Max = doc.selectionEnd
+					doc.selection = doc.selection.Norm()
+					doc.undoStack.DelayedPush(undoDelay(preferences.Undo), "Change Selection", &doc.fileName, &doc.saved, &doc.img, &currColor, &doc.selection)
 				case toolMoveSelected:
 					if ui.JustPressed(pixel.MouseButtonLeft) {
-						selectionStart = fromPixelCoords(cam, sprite.Frame().Center(), x, img.Bounds().Dy()-y)
+						doc.selectionStart = fromPixelCoords(cam, doc.sprite.Frame().Center(), x, doc.img.Bounds().Dy()-y)
+					}
+					doc.selectionEnd = fromPixelCoords(cam, doc.sprite.Frame().Center(), x, doc.img.Bounds().Dy()-y)
+					doc.selectionOffset = doc.selectionEnd.Sub(doc.selectionStart)
+					if snap != snapOff {
+						doc.selectionOffset = snapOffset(doc.selection, doc.selectionOffset, doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), doc.snapStep(snap))
 					}
-					selectionEnd = fromPixelCoords(cam, sprite.Frame().Center(), x, img.Bounds().Dy()-y)
-					selectionOffset = selectionEnd.Sub(selectionStart)
-					undoStack.DelayedPush(1*time.Second, "Move Selection", &fileName, &saved, &img, &currColor, &selection)
+					doc.undoStack.DelayedPush(undoDelay(preferences.Undo), "Move Selection", &doc.fileName, &doc.saved, &doc.img, &currColor, &doc.selection)
 				}
 			}
 		}
-		if ui.JustReleased(pixel.MouseButtonLeft) && selectionOffset != pixel.ZV {
-			selection = selection.Moved(selectionOffset)
-			selectionOffset = pixel.ZV
+		// The zoom tool works anywhere in the window, not only over the image
+		if lmb == toolZoom && doc.sprite != nil {
+			if ui.JustPressed(pixel.MouseButtonLeft) {
+				zoomStart = cam.Unproject(win.MousePosition())
+				zooming = true
+			} else if zooming && ui.JustReleased(pixel.MouseButtonLeft) {
+				zooming = false
+				area := pixel.Rect{Min: zoomStart, Max: cam.Unproject(win.MousePosition())}
+				doc.camPos, doc.camZoom = zoomToRect(area, win.Bounds().Size(), doc.camZoom)
+				cam = pixel.IM.Scaled(doc.camPos, doc.camZoom).Moved(win.Bounds().Center().Sub(doc.camPos))
+			} else if zooming && ui.JustPressed(pixel.KeyEscape) {
+				zooming = false
+			}
+		} else {
+			zooming = false
+		}
+		if ui.JustReleased(pixel.MouseButtonLeft) && doc.selectionOffset != pixel.ZV {
+			doc.selection = doc.selection.Moved(doc.selectionOffset)
+			doc.selectionOffset = pixel.ZV
+		}
+
+		// Nudge the selection, or the pixels being moved, with the arrow keys
+		if (tool == toolSelect || tool == toolMoveSelected) && doc.img != nil && doc.selection != pixel.ZR &&
+			doc.selectionOffset == pixel.ZV && !imgui.CurrentIO().WantCaptureKeyboard() &&
+			!(ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) {
+			delta := nudgeDelta(ui)
+			if tool == toolMoveSelected && snap != snapOff && delta != pixel.ZV {
+				step := doc.snapStep(snap)
+				delta = snapOffset(doc.selection, pixel.V(delta.X*float64(step.X), delta.Y*float64(step.Y)), doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), step)
+			}
+			moved := doc.selection.Moved(delta)
+			imageRect := doc.sprite.Frame().Moved(doc.sprite.Frame().Center().Scaled(-1))
+			// A plain selection has to stay on the image, floating pixels don't
+			if delta != pixel.ZV && (tool == toolMoveSelected || imageRect.Intersect(moved) == moved) {
+				doc.selection = moved
+				if tool == toolMoveSelected {
+					doc.saved = false
+					doc.undoStack.DelayedPush(undoDelay(preferences.Undo), "Nudge Pixels", &doc.fileName, &doc.saved, &doc.img, &currColor, &doc.selection)
+				} else {
+					doc.undoStack.DelayedPush(undoDelay(preferences.Undo), "Nudge Selection", &doc.fileName, &doc.saved, &doc.img, &currColor, &doc.selection)
+				}
+			}
 		}
 
 		// Undo
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
 			!(ui.Pressed(pixel.KeyLeftShift) || ui.Pressed(pixel.KeyRightShift)) &&
-			ui.JustPressed(pixel.KeyZ) && len(undoStack.UndoStack) > 1 {
-			handleUndo(prt, &undoStack, max(0, len(undoStack.UndoStack)-2), &img, &refreshSprites, &lastChannel, &currColor, &selection)
+			ui.JustPressed(pixel.KeyZ) && len(doc.undoStack.UndoStack) > 1 {
+			handleUndo(prt, &doc.undoStack, max(0, len(doc.undoStack.UndoStack)-2), &doc.img, &doc.refreshSprites, &doc.lastChannel, &currColor, &doc.selection)
 		}
 		// Redo
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
 			(ui.Pressed(pixel.KeyLeftShift) || ui.Pressed(pixel.KeyRightShift)) &&
-			ui.JustPressed(pixel.KeyZ) && len(undoStack.RedoStack) > 0 {
-			handleRedo(prt, &undoStack, max(0, len(undoStack.RedoStack)-1), &img, &refreshSprites, &lastChannel, &currColor, &selection)
+			ui.JustPressed(pixel.KeyZ) && len(doc.undoStack.RedoStack) > 0 {
+			handleRedo(prt, &doc.undoStack, max(0, len(doc.undoStack.RedoStack)-1), &doc.img, &doc.refreshSprites, &doc.lastChannel, &currColor, &doc.selection)
+		}
+
+		// Toggle between showing values as stored and sRGB encoding them
+		if ui.JustPressed(pixel.KeyG) && !imgui.CurrentIO().WantCaptureKeyboard() &&
+			!ui.Pressed(pixel.KeyLeftControl) && !ui.Pressed(pixel.KeyRightControl) {
+			response = types.MenuResponseViewEncodeSRGB
+		}
+
+		// Go to and find shortcuts
+		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
+			!imgui.CurrentIO().WantCaptureKeyboard() && doc.img != nil {
+			if ui.JustPressed(pixel.KeyG) {
+				response = types.MenuResponseEditGoTo
+			} else if ui.JustPressed(pixel.KeyF) {
+				response = types.MenuResponseEditFind
+			}
+		}
+		if ui.JustPressed(pixel.KeyF3) && !imgui.CurrentIO().WantCaptureKeyboard() && doc.img != nil && searched {
+			response = types.MenuResponseEditFindNext
+		}
+
+		// Flip between stored A/B snapshots
+		if ui.JustPressed(pixel.KeyT) && !imgui.CurrentIO().WantCaptureKeyboard() && doc.img != nil {
+			response = types.MenuResponseSnapshotFlip
+		}
+		// Any click on the image returns to the live image so edits are visible
+		if doc.comparing >= 0 && ui.JustPressed(pixel.MouseButtonLeft) && !imgui.CurrentIO().WantCaptureMouse() {
+			doc.comparing = -1
 		}
 
 		// New file shortcut
@@ -282,154 +634,583 @@ func run() {
 
 		// Open file shortcut
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) && ui.JustPressed(pixel.KeyO) {
-			go openFile(prt, &fileName, &img, &refreshSprites, &lastChannel, currColor, selection, &undoStack)
+			go openFile(prt, openedDocs, currColor, backgroundTasks.Add("Open"))
 		}
 
 		// Save shortcut
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
-			ui.JustPressed(pixel.KeyS) && img != nil {
-			if fileName == "(new)" || len(fileName) == 0 {
-				go saveFileAs(prt, &fileName, img, &saved, currColor, selection, &undoStack)
+			ui.JustPressed(pixel.KeyS) && doc.img != nil {
+			if doc.fileName == "(new)" || len(doc.fileName) == 0 {
+				go saveFileAs(prt, &doc.fileName, doc.flattened(), doc.img, doc.attributes, saveConversion, conversionReports, savedPaths, &doc.saved, currColor, doc.selection, &doc.undoStack, &doc.provenance, doc.baseline)
 			} else {
-				go saveFile(prt, fileName, img, &saved, currColor, selection, &undoStack)
+				go saveFile(prt, doc.fileName, doc.flattened(), doc.img, doc.attributes, saveConversion, conversionReports, savedPaths, &doc.saved, currColor, doc.selection, &doc.undoStack, &doc.provenance, doc.baseline)
 			}
 		}
 
 		// Save As shortcut
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
 			(ui.Pressed(pixel.KeyLeftShift) || ui.Pressed(pixel.KeyRightShift)) &&
-			ui.JustPressed(pixel.KeyS) && img != nil {
-			go saveFileAs(prt, &fileName, img, &saved, currColor, selection, &undoStack)
+			ui.JustPressed(pixel.KeyS) && doc.img != nil {
+			go saveFileAs(prt, &doc.fileName, doc.flattened(), doc.img, doc.attributes, saveConversion, conversionReports, savedPaths, &doc.saved, currColor, doc.selection, &doc.undoStack, &doc.provenance, doc.baseline)
 		}
 
 		// Copy shortcut
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
-			ui.JustPressed(pixel.KeyC) && img != nil && selection != pixel.ZR {
-			err := handleCopy(selection, sprite.Frame().Center(), img)
+			ui.JustPressed(pixel.KeyC) && doc.img != nil && doc.selection != pixel.ZR {
+			err := handleCopy(doc.selection, doc.sprite.Frame().Center(), doc.img)
 			if err != nil {
-				prt.Errorf("failed to copy image: %v", err)
+				reportClipboardError(prt, backgroundTasks, "copy image", err)
 			}
 		}
 
 		// Cut shortcut
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
-			ui.JustPressed(pixel.KeyX) && img != nil && selection != pixel.ZR {
-			undoStack.Push("Cut", fileName, saved, img, currColor, selection)
-			err := handleCut(selection, sprite.Frame().Center(), img)
-			if err != nil {
-				prt.Errorf("failed to cut image: %v", err)
-			} else {
-				saved = false
-				refreshSprites = true
+			ui.JustPressed(pixel.KeyX) && doc.img != nil && doc.selection != pixel.ZR {
+			doc.undoStack.Push("Cut", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+			if err := handleCut(doc, channelLock); err != nil {
+				reportClipboardError(prt, backgroundTasks, "cut image", err)
 			}
 		}
 
 		// Paste shortcut
 		if (ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)) &&
-			ui.JustPressed(pixel.KeyV) && img != nil {
-			newPasteImg, newSelection, err := handlePaste(img.Bounds(), viewedChannel, sprite.Frame().Center())
+			ui.JustPressed(pixel.KeyV) && doc.img != nil {
+			target := pasteTarget(cam, doc, win.MousePosition(), win.Bounds().Center())
+			newPasteImg, newSelection, err := handlePaste(doc.img.Bounds(), viewedChannel, doc.sprite.Frame().Center(), target)
 			if err == clipboard.ErrUnavailable {
 				// do nothing
 			} else if err != nil {
-				prt.Errorf("failed to paste image: %v", err)
+				reportClipboardError(prt, backgroundTasks, "paste image", err)
 			} else {
-				refreshSprites = true
+				doc.refreshSprites = true
 				prevTool = tool
 				tool = toolMoveSelected
-				pasteImg = newPasteImg
-				if newSelection != nil {
-					selection = *newSelection
-				}
+				doc.pasteImg = newPasteImg
+				doc.pasteLock = blend.Lock{}
+				doc.selection = *newSelection
 			}
 		}
 
 		// Finish moving pixels shortcut
-		if tool == toolMoveSelected && ui.JustPressed(pixel.KeyEnter) && img != nil && pasteImg != nil {
-			undoStack.Push("Finish pixels", fileName, saved, img, currColor, selection)
-			handleImageCombine(selection, sprite.Frame().Center(), img, pasteImg)
-			refreshSprites = true
+		if tool == toolMoveSelected && ui.JustPressed(pixel.KeyEnter) && doc.img != nil && doc.pasteImg != nil {
+			doc.undoStack.Push("Finish pixels", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+			if err := doc.combinePasted(blendMode, channelLock); err != nil {
+				prt.Errorf("failed to place pixels: %v", err)
+			}
 			tool = prevTool
-			saved = false
-			pasteImg = nil
-			pastePic = nil
-			pasteSprite = nil
+			doc.pasteImg = nil
+			doc.pastePic = nil
+			doc.pasteSprite = nil
 		}
 
 		// Cancel moving pixels shortcut
-		if tool == toolMoveSelected && ui.JustPressed(pixel.KeyEscape) && img != nil && pasteImg != nil {
+		if tool == toolMoveSelected && ui.JustPressed(pixel.KeyEscape) && doc.img != nil && doc.pasteImg != nil {
 			tool = prevTool
-			pasteImg = nil
-			pastePic = nil
-			pasteSprite = nil
+			doc.pasteImg = nil
+			doc.pastePic = nil
+			doc.pasteSprite = nil
 		}
 
 		// Clear selection
-		if tool == toolSelect && ui.JustPressed(pixel.KeyEscape) && img != nil {
-			selection = pixel.ZR
+		if tool == toolSelect && ui.JustPressed(pixel.KeyEscape) && doc.img != nil {
+			doc.selection = pixel.ZR
 		}
 
 		win.SetMatrix(cam)
-		if sprite != nil {
-			sprite.Draw(win, pixel.IM)
+		if doc.comparing >= 0 && doc.compareSprite != nil {
+			doc.compareSprite.Draw(win, pixel.IM)
+		} else if doc.sprite != nil {
+			sprite, matrix := doc.sprite, pixel.IM
+			if smoothZoom {
+				if zoomedOut, scale, ok := doc.pyramid.draw(doc.pic, doc.camZoom); ok {
+					sprite, matrix = zoomedOut, scale
+				}
+			}
+			sprite.Draw(win, matrix)
 		}
-		if tool == toolSelect && pasteSprite != nil {
-			pasteImg = nil
-			pastePic = nil
-			pasteSprite = nil
+		if tool == toolSelect && doc.pasteSprite != nil {
+			doc.pasteImg = nil
+			doc.pastePic = nil
+			doc.pasteSprite = nil
 		}
-		if pasteSprite != nil {
-			pasteSprite.Draw(win, pixel.IM.Moved(selection.Moved(selectionOffset).Center()))
+		if doc.pasteSprite != nil {
+			doc.pasteSprite.Draw(win, pixel.IM.Moved(doc.selection.Moved(doc.selectionOffset).Center()))
 		}
 
-		nextResponse, index := showMainMenuBar(img, channelsVisible, colorVisible, gridVisible, toolsVisible, &undoStack, selection)
+		_, hasRows := doc.selectedRows()
+		nextResponse, index := showMainMenuBar(doc.img, doc.hasSnapshots(), doc.comparing, bytesVisible, channelsVisible, consoleVisible, memoryVisible, historyVisible, rowFilterVisible, colorVisible, fileInfoVisible, graphVisible, gridVisible, smoothZoom, toolsVisible, trackpadMode, colorManaged.enabled, colorManaged.profileName, doc.baseline != "", doc.hasImageFile(), hasRows, doc.committed != nil, browserVisible, presetsVisible, projectVisible, layersVisible, labelsVisible, tooltipsVisible, usageVisible, schemaEditVisible, linkedLUTs != nil, doc.locked.Len(), lockedVisible, colorManaged.encodeSRGB, violationsVisible, groupsVisible, doc.hiddenGroups, schemas, doc.schema, &doc.undoStack, doc.selection, featherRadius, len(doc.multiRowList()), rowStyleCopied != nil, searched, pipelineNames(preferences.Pipelines), inboxWatcher.folder != "", preferences.Provenance)
 		if nextResponse != types.MenuResponseNone {
 			response = nextResponse
 		}
 
 		switch response {
 		case types.MenuResponseImageNew:
-			if createNewImage(&img, &newImageConfirm, &refreshSprites, &saved, &fileName, &lastChannel, &newImageWidth, &newImageHeight, &newImagePrecision) {
+			var newImg image.Image
+			if createNewImage(&newImg, &newImage, schemas) {
+				response = types.MenuResponseNone
+				if newImg != nil {
+					newDoc := newDocument("(new)", newImg, false)
+					newDoc.undoStack.Push("New Image", newDoc.fileName, newDoc.saved, newDoc.img, currColor, newDoc.selection)
+					openedDocs <- newDoc
+				}
+			}
+		case types.MenuResponseImageNewFromTemplate:
+			response = types.MenuResponseNone
+			schema := &schemas[index]
+			newDoc := newDocument("(new)", newTemplateImage(schema, newImage), false)
+			newDoc.setSchema(schema)
+			newDoc.undoStack.Push("New from Template", newDoc.fileName, newDoc.saved, newDoc.img, currColor, newDoc.selection)
+			openedDocs <- newDoc
+			labelsVisible = true
+		case types.MenuResponseViewTexelLabels:
+			response = types.MenuResponseNone
+			labelsVisible = !labelsVisible
+		case types.MenuResponseViewTexelTooltips:
+			response = types.MenuResponseNone
+			tooltipsVisible = !tooltipsVisible
+		case types.MenuResponseViewPatternUsage:
+			response = types.MenuResponseNone
+			usageVisible = !usageVisible
+		case types.MenuResponseHelpSchemaEditor:
+			response = types.MenuResponseNone
+			schemaEditVisible = !schemaEditVisible
+		case types.MenuResponseHelpUpdateSchemas:
+			var confirmed bool
+			if openURLPrompt("Update Schemas", "Update", &schemaURLText, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					preferences.SchemaURL = strings.TrimSpace(schemaURLText)
+					if err := prefs.Save(preferences); err != nil {
+						prt.Errorf("failed to save preferences: %v", err)
+					}
+					go updateSchemas(prt, preferences.SchemaURL, schemaPacks, backgroundTasks.Add("Update Schemas"))
+				} else {
+					schemaURLText = preferences.SchemaURL
+				}
+			}
+		case types.MenuResponseImageNewFromClipboard:
+			response = types.MenuResponseNone
+			newImg, err := newImageFromClipboard()
+			if err == clipboard.ErrUnavailable {
+				// do nothing
+			} else if err != nil {
+				reportClipboardError(prt, backgroundTasks, "create image from clipboard", err)
+			} else {
+				newDoc := newDocument("(new)", newImg, false)
+				newDoc.undoStack.Push("New from Clipboard", newDoc.fileName, newDoc.saved, newDoc.img, currColor, newDoc.selection)
+				openedDocs <- newDoc
+			}
+		case types.MenuResponseImageDuplicate:
+			response = types.MenuResponseNone
+			newDoc := newDocument("(new)", editor.Copy(doc.flattened(), doc.img.Bounds()), false)
+			newDoc.camPos = doc.camPos
+			newDoc.camZoom = doc.camZoom
+			newDoc.attributes = slices.Clone(doc.attributes)
+			newDoc.undoStack.Push("Duplicate Image", newDoc.fileName, newDoc.saved, newDoc.img, currColor, newDoc.selection)
+			openedDocs <- newDoc
+		case types.MenuResponseImageShiftWrap:
+			rect, target := doc.editRect()
+			var confirmed bool
+			if shiftWrap(&shiftX, &shiftY, target, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed && (shiftX != 0 || shiftY != 0) {
+					doc.undoStack.Push("Shift with Wrap", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+					if err := doc.apply(editor.ShiftWrap{Rect: rect, DX: int(shiftX), DY: int(shiftY)}); err != nil {
+						prt.Errorf("failed to shift image: %v", err)
+					}
+				}
+			}
+		case types.MenuResponseImageRepeatSelection:
+			selected := selectionToImageRect(doc.selection, doc.sprite.Frame().Center(), doc.img.Bounds().Dy()).Size()
+			var confirmed bool
+			if repeatPrompt(&repeatChoice, selected, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed && (repeatChoice.dx != 0 || repeatChoice.dy != 0) {
+					doc.undoStack.Push("Repeat Selection", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+					restore := doc.protectEdits(channelLock)
+					placed, err := doc.repeatSelection(repeatChoice, blendMode, channelLock)
+					restore()
+					if err != nil {
+						prt.Errorf("failed to repeat selection: %v", err)
+					} else {
+						prt.Infof("Placed %d of %d copies", placed, repeatChoice.count)
+						doc.saved = false
+						doc.refreshSprites = true
+					}
+				}
+			}
+		case types.MenuResponseEditGoTo:
+			var confirmed bool
+			names := doc.names()
+			if goToPrompt(&goToChoice, doc.img.Bounds().Size(), names.Rows, names.Columns, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					doc.goTo(image.Pt(int(goToChoice.x), int(goToChoice.y)))
+					if tool.paints() {
+						tool = toolSelect
+					}
+				}
+			}
+		case types.MenuResponseEditFind, types.MenuResponseEditFindNext:
+			if response == types.MenuResponseEditFind {
+				var confirmed bool
+				if !findPrompt(&findChoice, &confirmed) {
+					break
+				}
+				response = types.MenuResponseNone
+				if !confirmed {
+					break
+				}
+				searched = true
+			}
+			response = types.MenuResponseNone
+			if found, ok := doc.findNext(findChoice); ok {
+				doc.goTo(found)
+				if tool.paints() {
+					tool = toolSelect
+				}
+				prt.Infof("Found %s at (%d, %d)", findChoice, found.X, found.Y)
+			} else {
+				prt.Infof("No texel has %s", findChoice)
+			}
+		case types.MenuResponseSelectGrow, types.MenuResponseSelectShrink:
+			grow := response == types.MenuResponseSelectGrow
+			title, text := "Grow selection", "Move each edge of the selection out by"
+			if !grow {
+				title, text = "Shrink selection", "Move each edge of the selection in by"
+			}
+			var confirmed bool
+			if selectionAmount(title, text, &selectAmount, &confirmed) {
+				response = types.MenuResponseNone
+				if !confirmed {
+					break
+				}
+				n := int(selectAmount)
+				if !grow {
+					n = -n
+				}
+				if err := doc.growSelection(n); err != nil {
+					prt.Errorf("failed to resize selection: %v", err)
+				}
+			}
+		case types.MenuResponseSelectAddMultiRows, types.MenuResponseSelectRemoveMultiRows:
+			add := response == types.MenuResponseSelectAddMultiRows
+			response = types.MenuResponseNone
+			if err := doc.setMultiRows(add); err != nil {
+				prt.Errorf("failed to change rows edited together: %v", err)
+			}
+		case types.MenuResponseSelectClearMultiRows:
+			response = types.MenuResponseNone
+			doc.multiRows = nil
+		case types.MenuResponseSelectChanged:
+			response = types.MenuResponseNone
+			changed, locked, err := doc.selectChanged()
+			if err != nil {
+				prt.Errorf("failed to select changed texels: %v", err)
+				break
+			}
+			rect, _ := doc.editRect()
+			if locked > 0 {
+				prt.Infof("Selected the %dx%d texels around the %d changed from the baseline, and locked the %d unchanged among them. Edit > Unlock All Texels unlocks them", rect.Dx(), rect.Dy(), changed, locked)
+			} else {
+				prt.Infof("Selected the %d texels changed from the baseline", changed)
+			}
+		case types.MenuResponseSelectFeather:
+			var confirmed bool
+			if selectionAmount("Feather selection", "Fade filters out towards the selection's edges over", &featherChoice, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					featherRadius = featherChoice
+				} else {
+					featherChoice = featherRadius
+				}
+			}
+		case types.MenuResponseFilterInterpolate:
+			rects, target := doc.editRects()
+			var confirmed bool
+			if interpolate(&interpolateColumns, &interpolateEasing, target, &confirmed) {
+				response = types.MenuResponseNone
+				if raw, ok := rawImage(doc.img); confirmed && ok {
+					doc.undoStack.Push("Interpolate", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+					restore := doc.protectEdits(channelLock)
+					feather := doc.featherEachEdit(rects, int(featherRadius))
+					for _, rect := range rects {
+						filter.Interpolate(raw, rect, interpolateColumns, filter.Easing(interpolateEasing), channelLock)
+					}
+					feather()
+					restore()
+					doc.saved = false
+					doc.refreshSprites = true
+				}
+			}
+		case types.MenuResponseFilterJitter:
+			rects, target := doc.editRects()
+			var confirmed bool
+			if jitter(&jitterMin, &jitterMax, &jitterSeed, target, &confirmed) {
+				response = types.MenuResponseNone
+				if raw, ok := rawImage(doc.img); confirmed && ok {
+					doc.undoStack.Push("Jitter", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+					restore := doc.protectEdits(channelLock)
+					feather := doc.featherEachEdit(rects, int(featherRadius))
+					for _, rect := range rects {
+						filter.Jitter(raw, rect, float64(jitterMin), float64(jitterMax), uint64(jitterSeed), channelLock)
+					}
+					feather()
+					restore()
+					doc.saved = false
+					doc.refreshSprites = true
+				}
+			}
+		case types.MenuResponseFilterGradientMap:
+			rects, target := doc.editRects()
+			var confirmed bool
+			if gradientMap(&gradientMapChoice, target, &confirmed) {
+				response = types.MenuResponseNone
+				if raw, ok := rawImage(doc.img); confirmed && ok {
+					doc.undoStack.Push("Gradient Map", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+					restore := doc.protectEdits(channelLock)
+					feather := doc.featherEachEdit(rects, int(featherRadius))
+					changed := 0
+					for _, rect := range rects {
+						changed += filter.GradientMap(raw, rect, gradientMapChoice.gradient(), gradientMapChoice.input, gradientMapChoice.outputs, channelLock)
+					}
+					feather()
+					restore()
+					doc.saved = doc.saved && changed == 0
+					doc.refreshSprites = true
+				}
+			}
+		case types.MenuResponseFilterRecolor:
+			rects, target := doc.editRects()
+			var confirmed bool
+			if recolor(&recolorChoice, doc.schema, target, &confirmed) {
+				response = types.MenuResponseNone
+				if raw, ok := rawImage(doc.img); confirmed && ok {
+					doc.undoStack.Push("Recolor", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+					restore := doc.protectEdits(channelLock)
+					feather := doc.featherEachEdit(rects, int(featherRadius))
+					columns := recolorChoice.columns(doc.schema, raw.Bounds().Max.X)
+					changed := 0
+					for _, rect := range rects {
+						changed += filter.Recolor(raw, rect, columns, filter.HueSpace(recolorChoice.space), float64(recolorChoice.hue), float64(recolorChoice.saturation), channelLock)
+					}
+					feather()
+					restore()
+					doc.saved = doc.saved && changed == 0
+					doc.refreshSprites = true
+				}
+			}
+		case types.MenuResponseFilterSnapSchemaValues:
+			response = types.MenuResponseNone
+			rects, _ := doc.editRects()
+			raw, ok := rawImage(doc.img)
+			if !ok || doc.schema == nil {
+				break
+			}
+			doc.undoStack.Push("Snap to Schema Values", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+			restore := doc.protectEdits(channelLock)
+			var snapped []help.Snapped
+			for _, rect := range rects {
+				snapped = append(snapped, doc.schema.SnapValues(raw, rect, channelLock)...)
+			}
+			restore()
+			summary := snappedSummary(doc.schema, snapped)
+			prt.Infof("Snap to Schema Values: %s", summary)
+			backgroundTasks.Add("Snap to Schema Values").Report(summary)
+			doc.saved = doc.saved && len(snapped) == 0
+			doc.refreshSprites = true
+		case types.MenuResponseFilterQuantize:
+			rects, target := doc.editRects()
+			var confirmed bool
+			if quantize(&quantizeSettings, target, &confirmed) {
+				response = types.MenuResponseNone
+				raw, ok := rawImage(doc.img)
+				if !confirmed || !ok {
+					break
+				}
+				var values []float64
+				if quantizeSettings.useList {
+					var err error
+					if values, err = filter.ParseValues(quantizeSettings.values); err != nil {
+						prt.Errorf("failed to quantize: %v", err)
+						break
+					}
+				}
+				doc.undoStack.Push("Quantize", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+				var changed int
+				restore := doc.protectEdits(channelLock)
+				feather := doc.featherEachEdit(rects, int(featherRadius))
+				total := 0
+				for _, rect := range rects {
+					if quantizeSettings.useList {
+						changed += filter.QuantizeValues(raw, rect, values, channelLock)
+					} else {
+						changed += filter.QuantizeStep(raw, rect, float64(quantizeSettings.step), channelLock)
+					}
+					total += rect.Dx() * rect.Dy()
+				}
+				feather()
+				restore()
+				prt.Infof("Quantize changed %d texels", changed)
+				backgroundTasks.Add("Quantize").Report(fmt.Sprintf("%d of %d texels changed", changed, total))
+				doc.saved = doc.saved && changed == 0
+				doc.refreshSprites = true
+			}
+		case types.MenuResponseImageSavePrecision:
+			var confirmed bool
+			if savePrecision(&conversionChoice, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					saveConversion = conversionChoice
+				} else {
+					conversionChoice = saveConversion
+				}
+			}
+		case types.MenuResponseImageSaveHook:
+			var confirmed bool
+			if saveHookPrompt(&hookChoice, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					preferences.SaveHook = hookChoice
+					if err := prefs.Save(preferences); err != nil {
+						prt.Errorf("failed to save preferences: %v", err)
+					}
+				} else {
+					hookChoice = preferences.SaveHook
+				}
+			}
+		case types.MenuResponseImageExportPipeline:
+			response = types.MenuResponseNone
+			if index < 0 || index >= len(preferences.Pipelines) {
+				break
+			}
+			pipeline := preferences.Pipelines[index]
+			go runPipeline(prt, pipeline, doc.flattened(), doc.attributes, doc.fileName, conversionReports, backgroundTasks.Add(pipelineNames(preferences.Pipelines)[index]))
+		case types.MenuResponseImageReloadPreferences:
+			response = types.MenuResponseNone
+			if reloaded, err := prefs.Load(); err != nil {
+				prt.Errorf("Loading preferences: %v", err)
+			} else {
+				preferences = reloaded
+				hookChoice = preferences.SaveHook
+				if preferences.Inbox != inboxWatcher.folder {
+					inboxWatcher.watch(preferences.Inbox)
+				}
+				prt.Infof("Reloaded preferences: %d export pipelines", len(preferences.Pipelines))
+			}
+		case types.MenuResponseImageSaveCopy:
+			response = types.MenuResponseNone
+			go saveFileCopy(prt, doc.flattened(), doc.attributes, saveConversion, conversionReports)
+		case types.MenuResponseImageRestoreOriginal:
+			var confirmed bool
+			if restoreOriginalPrompt(doc.displayName(), &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					img, attrs, err := restoreOriginal(doc.fileName)
+					if err != nil {
+						prt.Errorf("failed to restore original: %v", err)
+					} else {
+						doc.undoStack.Push("Restore Original", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+						doc.img, doc.attributes = img, attrs
+						doc.saved = true
+						doc.refreshSprites = true
+						doc.lastChannel = hdrColors.GraySettingAlpha
+						prt.Infof("Restored the original of '%s'", doc.fileName)
+					}
+				}
+			}
+		case types.MenuResponseImageWatchInbox:
+			response = types.MenuResponseNone
+			go inboxWatcher.chooseFolder(prt, preferences.Inbox)
+		case types.MenuResponseImageStopInbox:
+			response = types.MenuResponseNone
+			inboxWatcher.watch("")
+			preferences.Inbox = ""
+			if err := prefs.Save(preferences); err != nil {
+				prt.Errorf("failed to save preferences: %v", err)
+			}
+		case types.MenuResponseImageBackupFolder:
+			response = types.MenuResponseNone
+			go chooseBackupFolder(prt)
+		case types.MenuResponseImageExportPreview:
+			var confirmed bool
+			if exportPreview(&previewOptions, doc.committed != nil, &confirmed) {
 				response = types.MenuResponseNone
-				if img != nil {
-					undoStack.Clear()
-					undoStack.Push("New Image", fileName, saved, img, currColor, selection)
+				if confirmed {
+					opts := previewOptions
+					opts.Names, opts.Changed = doc.names(), doc.changesSinceCommit()
+					go exportPreviewPNG(prt, doc.displayImage(doc.flattened(), viewedChannel, colorManaged.sRGB()), opts)
 				}
 			}
 		case types.MenuResponseImageSave:
 			response = types.MenuResponseNone
-			if fileName == "(new)" || len(fileName) == 0 {
-				go saveFileAs(prt, &fileName, img, &saved, currColor, selection, &undoStack)
+			if doc.fileName == "(new)" || len(doc.fileName) == 0 {
+				go saveFileAs(prt, &doc.fileName, doc.flattened(), doc.img, doc.attributes, saveConversion, conversionReports, savedPaths, &doc.saved, currColor, doc.selection, &doc.undoStack, &doc.provenance, doc.baseline)
 			} else {
-				go saveFile(prt, fileName, img, &saved, currColor, selection, &undoStack)
+				go saveFile(prt, doc.fileName, doc.flattened(), doc.img, doc.attributes, saveConversion, conversionReports, savedPaths, &doc.saved, currColor, doc.selection, &doc.undoStack, &doc.provenance, doc.baseline)
 			}
 		case types.MenuResponseImageOpen:
 			response = types.MenuResponseNone
-			go openFile(prt, &fileName, &img, &refreshSprites, &lastChannel, currColor, selection, &undoStack)
+			go openFile(prt, openedDocs, currColor, backgroundTasks.Add("Open"))
+		case types.MenuResponseImageOpenAs:
+			var confirmed, browse bool
+			responded := openAsPrompt(&openAsChoice, &browse, &confirmed)
+			if browse {
+				go chooseOpenAsFile(prt, openAsFiles)
+			}
+			if responded {
+				response = types.MenuResponseNone
+				if confirmed {
+					go openPathAs(prt, openAsChoice, openedDocs, currColor)
+				}
+			}
+		case types.MenuResponseImageImportRaw:
+			var confirmed, browse bool
+			responded := importRawPrompt(&importRawChoice, &browse, &confirmed)
+			if browse {
+				go chooseRawFile(prt, rawFiles)
+			}
+			if responded {
+				response = types.MenuResponseNone
+				if confirmed {
+					go importRaw(prt, importRawChoice, openedDocs, currColor)
+				}
+			}
+		case types.MenuResponseImageBrowse:
+			response = types.MenuResponseNone
+			browserVisible = true
+			go browser.chooseFolder(prt, browseStartDir(browser, doc))
+		case types.MenuResponseImageOpenURL:
+			var confirmed bool
+			if openURLPrompt("Open URL", "Open", &openURLText, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					go openURL(prt, strings.TrimSpace(openURLText), openedDocs, currColor, backgroundTasks.Add("Download"))
+				}
+			}
 		case types.MenuResponseImageSaveAs:
 			response = types.MenuResponseNone
-			go saveFileAs(prt, &fileName, img, &saved, currColor, selection, &undoStack)
+			go saveFileAs(prt, &doc.fileName, doc.flattened(), doc.img, doc.attributes, saveConversion, conversionReports, savedPaths, &doc.saved, currColor, doc.selection, &doc.undoStack, &doc.provenance, doc.baseline)
 		case types.MenuResponseBulkConvertToDDS:
+			var confirmed bool
+			if !bulkConvert(&bulkSettings, "Bulk convert EXR to DDS", &confirmed) {
+				break
+			}
 			response = types.MenuResponseNone
-			taskIdx := len(backgroundTasks)
-			backgroundTasks[types.TaskID(taskIdx)] = &types.BackgroundStatus{
-				Name:     "Bulk DDS->EXR Conversion",
-				Message:  "",
-				Progress: 0,
-				Total:    -1,
-				Status:   types.TaskIdle,
+			if !confirmed {
+				break
 			}
-			go bulkConvertFiles(prt, true, backgroundTasks[types.TaskID(taskIdx)])
+			go bulkConvertFiles(prt, true, bulkSettings, conversionReports, backgroundTasks.Add("Bulk DDS->EXR Conversion"))
 		case types.MenuResponseBulkConvertToEXR:
+			var confirmed bool
+			if !bulkConvert(&bulkSettings, "Bulk convert DDS to EXR", &confirmed) {
+				break
+			}
 			response = types.MenuResponseNone
-			taskIdx := len(backgroundTasks)
-			backgroundTasks[types.TaskID(taskIdx)] = &types.BackgroundStatus{
-				Name:     "Bulk EXR->DDS Conversion",
-				Message:  "",
-				Progress: 0,
-				Total:    -1,
-				Status:   types.TaskIdle,
+			if !confirmed {
+				break
 			}
-			go bulkConvertFiles(prt, false, backgroundTasks[types.TaskID(taskIdx)])
+			go bulkConvertFiles(prt, false, bulkSettings, conversionReports, backgroundTasks.Add("Bulk EXR->DDS Conversion"))
 		case types.MenuResponseViewChannels:
 			response = types.MenuResponseNone
 			channelsVisible = !channelsVisible
@@ -439,112 +1220,690 @@ func run() {
 		case types.MenuResponseViewGrid:
 			response = types.MenuResponseNone
 			gridVisible = !gridVisible
+		case types.MenuResponseViewSmoothZoom:
+			response = types.MenuResponseNone
+			smoothZoom = !smoothZoom
 		case types.MenuResponseViewTools:
 			response = types.MenuResponseNone
 			toolsVisible = !toolsVisible
-		case types.MenuResponseCopy:
+		case types.MenuResponseViewTrackpad:
 			response = types.MenuResponseNone
-			err := handleCopy(selection, sprite.Frame().Center(), img)
-			if err != nil {
-				prt.Errorf("failed to copy image: %v", err)
-			}
-		case types.MenuResponseCut:
+			trackpadMode = !trackpadMode
+		case types.MenuResponseViewGraph:
 			response = types.MenuResponseNone
-			undoStack.Push("Cut", fileName, saved, img, currColor, selection)
-			err := handleCut(selection, sprite.Frame().Center(), img)
-			if err != nil {
-				prt.Errorf("failed to cut image: %v", err)
-			} else {
-				saved = false
-				refreshSprites = true
-			}
+			graphVisible = !graphVisible
+		case types.MenuResponseViewBytes:
+			response = types.MenuResponseNone
+			bytesVisible = !bytesVisible
+		case types.MenuResponseViewConsole:
+			response = types.MenuResponseNone
+			consoleVisible = !consoleVisible
+		case types.MenuResponseViewMemory:
+			response = types.MenuResponseNone
+			memoryVisible = !memoryVisible
+		case types.MenuResponseViewHistory:
+			response = types.MenuResponseNone
+			historyVisible = !historyVisible
+		case types.MenuResponseViewRowFilter:
+			response = types.MenuResponseNone
+			rowFilterVisible = !rowFilterVisible
+		case types.MenuResponseViewFileInfo:
+			response = types.MenuResponseNone
+			fileInfoVisible = !fileInfoVisible
+		case types.MenuResponseViewColorManaged:
+			response = types.MenuResponseNone
+			colorManaged.enabled = !colorManaged.enabled
+			for _, d := range docs {
+				d.refreshSprites = d.img != nil
+			}
+		case types.MenuResponseViewDisplayProfileNone:
+			response = types.MenuResponseNone
+			colorManaged.setProfile(nil, "")
+			for _, d := range docs {
+				d.refreshSprites = d.img != nil
+			}
+		case types.MenuResponseViewDisplayProfileDetect:
+			response = types.MenuResponseNone
+			go detectDisplayProfile(prt, displayProfiles)
+		case types.MenuResponseViewDisplayProfileChoose:
+			response = types.MenuResponseNone
+			go chooseDisplayProfile(prt, displayProfiles)
+		case types.MenuResponseCopy:
+			response = types.MenuResponseNone
+			err := handleCopy(doc.selection, doc.sprite.Frame().Center(), doc.img)
+			if err != nil {
+				reportClipboardError(prt, backgroundTasks, "copy image", err)
+			}
+		case types.MenuResponseEditCopyRowStyle:
+			response = types.MenuResponseNone
+			style, err := doc.copyRowStyle()
+			if err != nil {
+				prt.Errorf("failed to copy row style: %v", err)
+				break
+			}
+			rowStyleCopied = style
+			prt.Infof("Copied the style of %s", style.name)
+		case types.MenuResponseEditPasteRowStyle:
+			if rowStyleCopied == nil {
+				response = types.MenuResponseNone
+				break
+			}
+			var confirmed bool
+			if pasteRowStylePrompt(&rowStyleChoice, rowStyleCopied, doc.schema, &confirmed) {
+				response = types.MenuResponseNone
+				if !confirmed {
+					break
+				}
+				doc.undoStack.Push("Paste Row Style", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+				restore := doc.protectEdits(channelLock)
+				rows, err := doc.pasteRowStyle(rowStyleCopied, rowStyleChoice.columns(doc.schema, len(rowStyleCopied.texels)), channelLock)
+				restore()
+				if err != nil {
+					prt.Errorf("failed to paste row style: %v", err)
+					break
+				}
+				prt.Infof("Pasted the style of %s over %d rows", rowStyleCopied.name, rows)
+				doc.saved = false
+				doc.refreshSprites = true
+			}
+		case types.MenuResponseCopyViewport, types.MenuResponseCopyViewportNoOverlays:
+			overlays := response == types.MenuResponseCopyViewport
+			response = types.MenuResponseNone
+			err := handleCopyViewport(win, cam, doc, viewedChannel, colorManaged.sRGB(), previewOptions, overlays && gridVisible, overlays)
+			if err != nil {
+				reportClipboardError(prt, backgroundTasks, "copy viewport", err)
+			}
+		case types.MenuResponseCut:
+			response = types.MenuResponseNone
+			doc.undoStack.Push("Cut", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+			if err := handleCut(doc, channelLock); err != nil {
+				reportClipboardError(prt, backgroundTasks, "cut image", err)
+			}
 		case types.MenuResponsePaste:
 			response = types.MenuResponseNone
-			newPasteImg, newSelection, err := handlePaste(img.Bounds(), viewedChannel, sprite.Frame().Center())
+			// The menu is under the cursor, so the pixels go in the middle of the view
+			target := pasteTarget(cam, doc, win.Bounds().Center())
+			newPasteImg, newSelection, err := handlePaste(doc.img.Bounds(), viewedChannel, doc.sprite.Frame().Center(), target)
 			if err == clipboard.ErrUnavailable {
 				// do nothing
 			} else if err != nil {
-				prt.Errorf("failed to paste image: %v", err)
+				reportClipboardError(prt, backgroundTasks, "paste image", err)
 			} else {
-				refreshSprites = true
+				doc.refreshSprites = true
 				prevTool = tool
 				tool = toolMoveSelected
-				pasteImg = newPasteImg
-				if newSelection != nil {
-					selection = *newSelection
+				doc.pasteImg = newPasteImg
+				doc.pasteLock = blend.Lock{}
+				doc.selection = *newSelection
+			}
+		case types.MenuResponsePasteSpecial:
+			var confirmed bool
+			if pasteSpecial(&pasteFrom, &pasteInto, &confirmed) {
+				response = types.MenuResponseNone
+				if !confirmed {
+					break
+				}
+				target := pasteTarget(cam, doc, win.Bounds().Center())
+				newPasteImg, newSelection, err := handlePaste(doc.img.Bounds(), viewedChannel, doc.sprite.Frame().Center(), target)
+				if err == clipboard.ErrUnavailable {
+					// do nothing
+				} else if err != nil {
+					reportClipboardError(prt, backgroundTasks, "paste image", err)
+				} else {
+					copyChannel(newPasteImg, pasteFrom, pasteInto)
+					doc.refreshSprites = true
+					prevTool = tool
+					tool = toolMoveSelected
+					doc.pasteImg = newPasteImg
+					// Only the target channel is written when the pixels are applied
+					doc.pasteLock = blend.Lock{true, true, true, true}
+					doc.pasteLock[pasteInto] = false
+					doc.selection = *newSelection
+				}
+			}
+		case types.MenuResponseSnapshotStoreA, types.MenuResponseSnapshotStoreB:
+			slot := 0
+			if response == types.MenuResponseSnapshotStoreB {
+				slot = 1
+			}
+			response = types.MenuResponseNone
+			doc.storeSnapshot(slot, currColor)
+		case types.MenuResponseSnapshotFlip:
+			response = types.MenuResponseNone
+			if err := doc.showSnapshot(doc.nextSnapshot(), viewedChannel); err != nil {
+				prt.Errorf("failed to show snapshot: %v", err)
+			}
+		case types.MenuResponseSnapshotLive:
+			response = types.MenuResponseNone
+			doc.comparing = -1
+		case types.MenuResponseToolsCompareHeaders:
+			response = types.MenuResponseNone
+			go compareHeaders(prt, headerComparisons)
+		case types.MenuResponseToolsRegisterFileTypes, types.MenuResponseToolsRegisterDefault:
+			makeDefault := response == types.MenuResponseToolsRegisterDefault
+			response = types.MenuResponseNone
+			if err := registerFileTypes(makeDefault); err != nil {
+				prt.Errorf("failed to register file types: %v", err)
+			}
+		case types.MenuResponseToolsSetBaseline:
+			response = types.MenuResponseNone
+			go chooseBaseline(prt, &doc.baseline)
+		case types.MenuResponseToolsBaselineLibrary:
+			response = types.MenuResponseNone
+			baselineLibVisible = true
+		case types.MenuResponseToolsProvenance:
+			response = types.MenuResponseNone
+			preferences.Provenance = !preferences.Provenance
+			if !preferences.Provenance {
+				for _, d := range docs {
+					d.provenance.Stop()
+				}
+			}
+			if err := prefs.Save(preferences); err != nil {
+				prt.Errorf("failed to save preferences: %v", err)
+			}
+		case types.MenuResponseToolsDiffHEAD:
+			response = types.MenuResponseNone
+			if doc.committed != nil {
+				doc.committed, doc.committedChanges = nil, nil
+				doc.comparing = -1
+			} else {
+				go loadCommittedImage(prt, doc.id, doc.fileName, "HEAD", committedImages)
+			}
+		case types.MenuResponseToolsExportChangeReport:
+			response = types.MenuResponseNone
+			go exportChangeReport(prt, doc.displayName(), doc.baseline, editor.Copy(doc.flattened(), doc.img.Bounds()), doc.names())
+		case types.MenuResponseToolsExportEditLog:
+			response = types.MenuResponseNone
+			doc.undoStack.Flush()
+			go exportEditLog(prt, doc.displayName(), slices.Clone(doc.undoStack.UndoStack), editor.Copy(doc.img, doc.img.Bounds()))
+		case types.MenuResponseToolsApplyEditLog:
+			response = types.MenuResponseNone
+			go importEditLog(prt, importedEditLogs)
+		case types.MenuResponseToolsMerge:
+			response = types.MenuResponseNone
+			go loadMerge(prt, mergeSessions)
+		case types.MenuResponseToolsExportBeforeAfter:
+			var confirmed bool
+			if exportBeforeAfterPrompt(&previewOptions, &beforeAfter, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					cm, channel := colorManaged.sRGB(), viewedChannel
+					display := func(img image.Image) image.Image {
+						return doc.displayImage(img, channel, cm)
+					}
+					go exportBeforeAfter(prt, doc.baseline, editor.Copy(doc.flattened(), doc.img.Bounds()), viewedChannel, display, previewOptions, beforeAfter)
+				}
+			}
+		case types.MenuResponseToolsMatchBaseline:
+			response = types.MenuResponseNone
+			raw, ok := rawImage(doc.img)
+			base := doc.baselineRaw()
+			if !ok || base == nil {
+				prt.Errorf("failed to match levels: baseline '%s' could not be loaded", doc.baseline)
+				break
+			} else if base.Bounds() != raw.Bounds() {
+				prt.Errorf("failed to match levels: baseline is %dx%d but the image is %dx%d", base.Bounds().Dx(), base.Bounds().Dy(), raw.Bounds().Dx(), raw.Bounds().Dy())
+				break
+			}
+			rects, target := doc.editRects()
+			doc.undoStack.Push("Match Levels to Baseline", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+			restore := doc.protectEdits(channelLock)
+			feather := doc.featherEachEdit(rects, int(featherRadius))
+			changed := 0
+			for _, rect := range rects {
+				changed += filter.MatchLevels(raw, base, rect, channelLock)
+			}
+			feather()
+			restore()
+			prt.Infof("Match Levels to Baseline changed %d texels of the %s", changed, target)
+			backgroundTasks.Add("Match Levels").Report(fmt.Sprintf("%d texels changed", changed))
+			doc.saved = doc.saved && changed == 0
+			doc.refreshSprites = true
+		case types.MenuResponseProjectOpen:
+			response = types.MenuResponseNone
+			go openProject(prt, openedDocs, currColor, backgroundTasks.Add("Open"))
+		case types.MenuResponseProjectSave, types.MenuResponseProjectSaveAs:
+			saveAs := response == types.MenuResponseProjectSaveAs
+			response = types.MenuResponseNone
+			proj := doc.project(viewedChannel, gridVisible)
+			go saveProject(prt, &doc.projectFile, proj, editor.Copy(doc.flattened(), doc.img.Bounds()), doc.attributes, doc.saved, saveAs)
+		case types.MenuResponseLockTexels, types.MenuResponseUnlockTexels:
+			lock := response == types.MenuResponseLockTexels
+			response = types.MenuResponseNone
+			rect := selectionToImageRect(doc.selection.Moved(doc.selectionOffset), doc.sprite.Frame().Center(), doc.img.Bounds().Dy()).Intersect(doc.img.Bounds())
+			if lock {
+				doc.locked.Add(rect)
+			} else {
+				doc.locked.Remove(rect)
+			}
+		case types.MenuResponseUnlockAllTexels:
+			response = types.MenuResponseNone
+			doc.locked.Clear()
+		case types.MenuResponseViewEncodeSRGB:
+			response = types.MenuResponseNone
+			colorManaged.encodeSRGB = !colorManaged.encodeSRGB
+			for _, d := range docs {
+				d.refreshSprites = d.img != nil
+			}
+		case types.MenuResponseViewLockedTexels:
+			response = types.MenuResponseNone
+			lockedVisible = !lockedVisible
+		case types.MenuResponseViewSchemaViolations:
+			response = types.MenuResponseNone
+			violationsVisible = !violationsVisible
+		case types.MenuResponseViewColumnGroups:
+			response = types.MenuResponseNone
+			groupsVisible = !groupsVisible
+		case types.MenuResponseViewShowAllGroups:
+			response = types.MenuResponseNone
+			doc.hiddenGroups = nil
+		case types.MenuResponseViewToggleGroup:
+			response = types.MenuResponseNone
+			if doc.hiddenGroups[index] {
+				delete(doc.hiddenGroups, index)
+			} else {
+				if doc.hiddenGroups == nil {
+					doc.hiddenGroups = make(map[int]bool)
+				}
+				doc.hiddenGroups[index] = true
+			}
+		case types.MenuResponseViewSchema:
+			response = types.MenuResponseNone
+			if index < 0 {
+				doc.setSchema(nil)
+			} else {
+				doc.setSchema(&schemas[index])
+			}
+		case types.MenuResponseImageImportRow:
+			response = types.MenuResponseNone
+			go importRow(prt, importedRows)
+		case types.MenuResponseImageExportRow:
+			response = types.MenuResponseNone
+			if preset, ok := doc.rowPreset(); ok {
+				go exportRow(prt, presets.NewSharedRow(preset, doc.schema))
+			}
+		case types.MenuResponseViewPresets:
+			response = types.MenuResponseNone
+			presetsVisible = !presetsVisible
+		case types.MenuResponseViewBrowser:
+			response = types.MenuResponseNone
+			browserVisible = !browserVisible
+		case types.MenuResponseViewProject:
+			response = types.MenuResponseNone
+			projectVisible = !projectVisible
+		case types.MenuResponseViewLayers:
+			response = types.MenuResponseNone
+			layersVisible = !layersVisible
+		case types.MenuResponseToolsContactSheet:
+			response = types.MenuResponseNone
+			go exportContactSheet(prt, browseStartDir(browser, doc), backgroundTasks.Add("Contact Sheet"))
+		case types.MenuResponseToolsGenerateVariants:
+			response = types.MenuResponseNone
+			if doc.img != nil {
+				go generateVariants(prt, editor.Copy(doc.flattened(), doc.img.Bounds()), slices.Clone(doc.attributes), doc.fileName, saveConversion, browseStartDir(browser, doc), backgroundTasks.Add("Generate Variants"))
+			}
+		case types.MenuResponseToolsSampleRamp:
+			response = types.MenuResponseNone
+			rampVisible = true
+		case types.MenuResponseToolsLinkLUTs:
+			var confirmed bool
+			if linkLUTs(docs, doc, &linkChoice, &confirmed) {
+				response = types.MenuResponseNone
+				if confirmed {
+					linkedLUTs = &workspace{
+						material: doc,
+						pattern:  docs[linkChoice.pattern],
+						link:     linked.Link{Channel: linkChoice.channel, Encoding: linked.Encoding(linkChoice.encoding)},
+					}
 				}
+				linkChoice.pattern = -1
+			}
+		case types.MenuResponseToolsUnlinkLUTs:
+			response = types.MenuResponseNone
+			linkedLUTs = nil
+		case types.MenuResponseToolsUnregisterFileTypes:
+			response = types.MenuResponseNone
+			if err := shell.UnregisterFileTypes(); err != nil {
+				prt.Errorf("failed to unregister file types: %v", err)
 			}
 		case types.MenuResponseUndo:
 			response = types.MenuResponseNone
-			handleUndo(prt, &undoStack, index, &img, &refreshSprites, &lastChannel, &currColor, &selection)
+			handleUndo(prt, &doc.undoStack, index, &doc.img, &doc.refreshSprites, &doc.lastChannel, &currColor, &doc.selection)
 		case types.MenuResponseRedo:
 			response = types.MenuResponseNone
-			handleRedo(prt, &undoStack, index, &img, &refreshSprites, &lastChannel, &currColor, &selection)
+			handleRedo(prt, &doc.undoStack, index, &doc.img, &doc.refreshSprites, &doc.lastChannel, &currColor, &doc.selection)
 		default:
 			// Do nothing
 			response = types.MenuResponseNone
 		}
 
-		if gridVisible && sprite != nil {
-			drawGrid(win, camZoom, sprite.Frame())
+		if gridVisible && doc.sprite != nil {
+			drawGrid(win, doc.camZoom, doc.sprite.Frame())
+		}
+
+		if (tool == toolSelect || tool == toolMoveSelected) && doc.selection != pixel.ZR {
+			drawSelection(win, doc.camZoom, doc.selection.Moved(doc.selectionOffset))
+		}
+
+		if zooming {
+			drawZoomMarquee(win, doc.camZoom, pixel.Rect{Min: zoomStart, Max: cam.Unproject(win.MousePosition())}.Norm())
+		}
+
+		if tool.paints() && doc.sprite != nil {
+			drawMirrorAxes(win, doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), doc.mirrorArea(), mirror)
+		}
+
+		if doc.sprite != nil {
+			drawMultiRows(win, doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Dx(), doc.img.Bounds().Dy(), doc.multiRowList())
+		}
+
+		if rows := doc.filteredRows(); rows != nil && doc.sprite != nil {
+			drawFilteredRows(win, doc.sprite.Frame().Center(), doc.img.Bounds().Dx(), doc.img.Bounds().Dy(), rows)
+		}
+
+		if groupsVisible && doc.schema != nil && len(doc.schema.Groups) > 0 && doc.sprite != nil {
+			drawColumnGroups(win, doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), doc.schema.Groups, doc.hiddenGroups)
+			drawColumnGroupLabels(cam, win.Bounds().H(), doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), doc.schema.Groups, doc.hiddenGroups)
+		}
+		if labelsVisible && doc.sprite != nil {
+			drawTexelLabels(cam, win.Bounds().H(), doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Size(), doc.names())
+		}
+
+		if lockedVisible && doc.sprite != nil {
+			drawLockedTexels(win, doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), doc.locked.Rects())
+		}
+
+		if linkedLUTs != nil && doc == linkedLUTs.pattern && doc.sprite != nil {
+			drawReferences(win, doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), linkedLUTs.selectedReferences())
+		}
+
+		if doc.committed != nil && doc.sprite != nil {
+			drawReferences(win, doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), doc.changesSinceCommit())
+		}
+
+		if violationsVisible && doc.schema != nil && doc.sprite != nil {
+			drawViolations(win, doc.camZoom, doc.sprite.Frame().Center(), doc.img.Bounds().Dy(), violatingTexels(doc.schemaViolations()))
 		}
 
-		if (tool == toolSelect || tool == toolMoveSelected) && selection != pixel.ZR {
-			drawSelection(win, camZoom, selection.Moved(selectionOffset))
+		nextCursor := arrowCursor
+		if doc.sprite != nil && !imgui.CurrentIO().WantCaptureMouse() {
+			nextCursor = toolCursors[lmb]
+			if lmb.paints() || lmb == toolSelect {
+				brushX, brushY := getPixelCoords(cam, doc.sprite.Frame().Center(), win.MousePosition())
+				if image.Pt(brushX, brushY).In(doc.img.Bounds()) {
+					if lmb.paints() {
+						height := doc.img.Bounds().Dy()
+						for _, p := range doc.multiRowPoints(mirrorPoints(image.Pt(brushX, height-brushY-1), doc.mirrorArea(), mirror)) {
+							drawBrushDab(win, doc.camZoom, doc.sprite.Frame().Center(), height, brush, p)
+						}
+					} else {
+						drawBrushPreview(win, doc.camZoom, brushFootprint(doc.sprite.Frame().Center(), brushX, brushY))
+					}
+				}
+			}
+		}
+		if nextCursor != currCursor {
+			win.SetCursor(nextCursor)
+			currCursor = nextCursor
 		}
 
 		if toolsVisible {
 			tempPrevTool := tool
-			drawToolWindow(&tool, &toolsVisible)
-			if tool != tempPrevTool && tool == toolMoveSelected && selection != pixel.ZR {
-				undoStack.Push("Start move pixels", fileName, saved, img, currColor, selection)
-				handleStartMoveSelection(selection, sprite.Frame().Center(), img, &pasteImg, &refreshSprites, &prevTool, &tempPrevTool)
-				saved = false
+			drawToolWindow(&tool, &blendMode, &channelLock, &brush, &mirror, &snap, &toolsVisible)
+			if tool != tempPrevTool && tool == toolMoveSelected && doc.selection != pixel.ZR {
+				doc.undoStack.Push("Start move pixels", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+				if err := handleStartMoveSelection(doc, channelLock, &prevTool, &tempPrevTool); err != nil {
+					prt.Errorf("failed to move pixels: %v", err)
+				}
+				doc.pasteLock = blend.Lock{}
 			}
-			if tool != tempPrevTool && tempPrevTool == toolMoveSelected && selection != pixel.ZR {
-				undoStack.Push("End move pixels", fileName, saved, img, currColor, selection)
-				handleImageCombine(selection, sprite.Frame().Center(), img, pasteImg)
-				pasteImg = nil
-				refreshSprites = true
-				saved = false
+			if tool != tempPrevTool && tempPrevTool == toolMoveSelected && doc.selection != pixel.ZR {
+				doc.undoStack.Push("End move pixels", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+				if err := doc.combinePasted(blendMode, channelLock); err != nil {
+					prt.Errorf("failed to place pixels: %v", err)
+				}
+				doc.pasteImg = nil
 			}
 		}
 
 		if colorVisible {
 			prevColor := currColor
-			drawColorWindow(&precision, &currColor, &colorVisible)
+			drawColorWindow(&precision, &currColor, doc.recentColors, &colorVisible)
 			if prevColor != currColor {
-				undoStack.DelayedPush(1*time.Second, "Edit Color", &fileName, &saved, &img, &currColor, &selection)
+				doc.undoStack.DelayedPush(undoDelay(preferences.Undo), "Edit Color", &doc.fileName, &doc.saved, &doc.img, &currColor, &doc.selection)
 			}
 		}
+		// Edits that aren't merged become a step as soon as the stroke, drag or
+		// key press making them is over
+		if action, ok := doc.undoStack.Pending(); ok && !undoCoalesces(preferences.Undo, action) &&
+			!ui.Pressed(pixel.MouseButtonLeft) && !ui.Pressed(pixel.MouseButtonRight) && !imgui.IsAnyItemActive() {
+			doc.undoStack.Flush()
+		}
 		if channelsVisible {
 			drawChannelWindow(&viewedChannel, &channelsVisible)
 		}
+		if fileInfoVisible {
+			drawFileInfoWindow(prt, doc, colorManaged, &metadata, &fileInfoVisible)
+		}
+		if len(headerComparisons) > 0 {
+			comparedHeaders = <-headerComparisons
+			headersVisible = true
+		}
+		if headersVisible && comparedHeaders != nil {
+			drawHeaderComparisonWindow(comparedHeaders, &headersVisible)
+		}
+		if len(conversionReports) > 0 {
+			conversionShown = <-conversionReports
+			conversionVisible = true
+		}
+		for len(savedPaths) > 0 {
+			path := <-savedPaths
+			if hook := preferences.SaveHook; hook.Enabled && strings.TrimSpace(hook.Command) != "" {
+				go runSaveHook(prt, hook.Command, path, backgroundTasks.Add("Save Hook"))
+			}
+		}
+		if conversionVisible && conversionShown != nil {
+			drawConversionReportWindow(conversionShown, &conversionVisible)
+		}
+		if len(inboxWatcher.pending) > 0 {
+			for _, path := range drawInboxWindow(inboxWatcher) {
+				go openPath(prt, path, openedDocs, currColor, backgroundTasks.Add("Open"))
+			}
+		}
+		if baselineLibVisible {
+			drawBaselineLibraryWindow(prt, baselineLib, backgroundTasks, doc, &baselineLibVisible)
+		}
+		if browserVisible {
+			if opened := drawBrowserWindow(browser, &chooseBrowsed, &browserVisible); opened != "" {
+				go openPath(prt, opened, openedDocs, currColor, backgroundTasks.Add("Open"))
+			}
+			if chooseBrowsed {
+				go browser.chooseFolder(prt, browseStartDir(browser, doc))
+			}
+		}
 
 		center := pixel.ZV
-		if sprite != nil {
-			center = sprite.Frame().Center()
+		if doc.sprite != nil {
+			center = doc.sprite.Frame().Center()
 		}
 		hovX, hovY := getPixelCoords(cam, center, win.MousePosition())
-		hovColor := getImgColorAtCoords(prt, img, hovX, hovY, viewedChannel)
+		hovColor := doc.sampleColor(prt, hovX, hovY, viewedChannel)
 		hovY = -hovY - 1
-		if img != nil {
-			hovY += img.Bounds().Dy()
+		if doc.img != nil {
+			hovY += doc.img.Bounds().Dy()
+		}
+		if doc.comparing < 0 && !imgui.CurrentIO().WantCaptureMouse() {
+			var tooltip []string
+			if tooltipsVisible {
+				if text := doc.texelTooltip(hovX, hovY); text != "" {
+					tooltip = append(tooltip, text)
+				}
+			}
+			if stamp, ok := doc.provenance.At(hovX, hovY); ok {
+				tooltip = append(tooltip, stamp.String())
+			}
+			if len(tooltip) > 0 {
+				imgui.SetTooltip(strings.Join(tooltip, "\n"))
+			}
 		}
 		pixelSelection := pixel.Rect{
-			Min: selection.Min.Add(center),
-			Max: selection.Max.Add(center),
+			Min: doc.selection.Min.Add(center),
+			Max: doc.selection.Max.Add(center),
+		}
+		sampledFrom := ""
+		if doc.sampledLevel() != nil {
+			sampledFrom = doc.sampleLevel.String()
+		}
+		drawStatusBar(cam.Unproject(win.MousePosition()).Add(center), hovColor, sampledFrom, backgroundTasks, pixelSelection)
+		if graphVisible {
+			drawGraphWindow(doc, &graph, hovX, hovY, &graphVisible)
+		}
+		if bytesVisible {
+			drawBytesWindow(doc.img, &inspectedTexel, hovX, hovY, &bytesVisible)
+		}
+		if consoleVisible {
+			drawConsoleWindow(&commandConsole, consoleCommands(doc, &commandConsole, currColor, channelLock), consoleHistoryStep(ui), &consoleVisible)
+			if commandConsole.selected && tool.paints() {
+				// The selection is only shown by the selection tools
+				tool = toolSelect
+			}
+			commandConsole.selected = false
+		}
+		if memoryVisible {
+			drawMemoryWindow(docs, &keepUndo, &memoryVisible)
+		}
+		if rowFilterVisible {
+			drawRowFilterWindow(doc, &rowFilterVisible)
+		}
+		if historyVisible {
+			switch resp, index := drawHistoryWindow(prt, &doc.undoStack, &preferences, &historyVisible); resp {
+			case types.MenuResponseUndo:
+				handleUndo(prt, &doc.undoStack, index, &doc.img, &doc.refreshSprites, &doc.lastChannel, &currColor, &doc.selection)
+			case types.MenuResponseRedo:
+				handleRedo(prt, &doc.undoStack, index, &doc.img, &doc.refreshSprites, &doc.lastChannel, &currColor, &doc.selection)
+			}
+		}
+		if linkedLUTs != nil {
+			linkedVisible := true
+			if drawWorkspaceWindow(linkedLUTs, &swapRows, &linkedVisible) {
+				material, pattern := linkedLUTs.material, linkedLUTs.pattern
+				material.undoStack.Push("Swap Rows", material.fileName, material.saved, material.img, currColor, material.selection)
+				pattern.undoStack.Push("Swap Rows", pattern.fileName, pattern.saved, pattern.img, currColor, pattern.selection)
+				restoreMaterial, restorePattern := material.protectLocked(), pattern.protectLocked()
+				changed, err := linkedLUTs.swapRows(int(swapRows[0]), int(swapRows[1]))
+				restoreMaterial()
+				restorePattern()
+				if err != nil {
+					prt.Errorf("failed to swap rows: %v", err)
+				} else {
+					material.saved, material.refreshSprites = false, true
+					pattern.saved, pattern.refreshSprites = false, true
+					prt.Infof("Swapped rows %d and %d and updated %d pattern texels", swapRows[0], swapRows[1], changed)
+				}
+			}
+			if !linkedVisible {
+				linkedLUTs = nil
+			}
+		}
+		if usageVisible {
+			drawPatternUsageWindow(&usage, doc, linkedLUTs, &usageVisible)
+		}
+		if schemaEditVisible && drawSchemaEditorWindow(prt, schemaEditing, schemas, doc, &schemaEditVisible) {
+			schemas = reloadSchemas(prt, docs)
+		}
+		if len(schemaPacks) > 0 {
+			<-schemaPacks
+			schemas = reloadSchemas(prt, docs)
+		}
+		if presetsVisible {
+			if apply := drawPresetsWindow(prt, presetLib, doc, &presetsVisible); apply >= 0 {
+				doc.applyPreset(prt, presetLib.list[apply], currColor, channelLock)
+			}
+		}
+		if rampVisible {
+			count, hasSelection := 0, false
+			var rect image.Rectangle
+			if doc.img != nil {
+				var target string
+				rect, target = doc.editRect()
+				count, hasSelection = rect.Dx(), target == "selection"
+			}
+			if drawRampWindow(rampSamples, count, hasSelection, &chooseRamp, &rampVisible) {
+				doc.undoStack.Push("Sample Ramp", doc.fileName, doc.saved, doc.img, currColor, doc.selection)
+				restore := doc.protectEdits(channelLock)
+				doc.writeRamp(rampSamples.sample(count), rect, channelLock)
+				restore()
+				doc.saved = false
+				doc.refreshSprites = true
+			}
+			if chooseRamp {
+				go rampSamples.chooseReference(prt)
+			}
+		}
+		if projectVisible && drawProjectWindow(doc, &selectionName, &projectVisible) && tool.paints() {
+			// The selection is only shown by the selection tools
+			tool = toolSelect
+		}
+		if layersVisible {
+			drawLayersWindow(doc, currColor, &layersVisible)
+		}
+		if len(mergeSessions) > 0 {
+			merging = <-mergeSessions
+			mergeVisible = true
+			prt.Infof("Merged '%s' and '%s': %s", filepath.Base(merging.paths[1]), filepath.Base(merging.paths[2]), merging.summary())
+		}
+		if mergeVisible && merging != nil {
+			if merged := drawMergeWindow(merging, doc.names(), &mergeVisible); merged != nil {
+				newDoc := newDocument("(new)", merged, false)
+				newDoc.attributes = slices.Clone(merging.attrs)
+				newDoc.undoStack.Push("Merge Images", newDoc.fileName, newDoc.saved, newDoc.img, currColor, newDoc.selection)
+				openedDocs <- newDoc
+				merging = nil
+			}
+		}
+
+		tabsActive, tabsClosing := drawDocumentTabs(docs, selectDoc)
+		selectDoc = -1
+		if tabsClosing >= 0 {
+			closingDoc = tabsClosing
+		}
+		if closingDoc >= 0 {
+			closeConfirmed := docs[closingDoc].saved
+			if !closeConfirmed {
+				viewport := imgui.MainViewport()
+				windowSize := imgui.Vec2{
+					X: 0.2 * viewport.Size().X,
+					Y: 0.2 * viewport.Size().Y,
+				}
+				var responded bool
+				centerWindow(windowSize)
+				closeConfirmed = confirmationDialog(windowSize, "Discard unsaved changes?", "Close Image", "Discard", "Cancel", &responded)
+				if responded && !closeConfirmed {
+					closingDoc = -1
+				}
+			}
+			if closeConfirmed {
+				if linkedLUTs != nil && linkedLUTs.has(docs[closingDoc]) {
+					linkedLUTs = nil
+				}
+				docs, activeDoc = closeDocument(docs, activeDoc, closingDoc)
+				closingDoc = -1
+				tabsActive = -1
+			}
+		}
+		if tabsActive >= 0 {
+			activeDoc = tabsActive
 		}
-		drawStatusBar(cam.Unproject(win.MousePosition()).Add(center), hovColor, backgroundTasks, pixelSelection)
 
 		ui.Draw(win)
 
 		modified := ""
-		if !saved {
+		if !doc.saved {
 			modified = "*"
 		}
-		if lastChannel != viewedChannel {
-			grayable, ok := getGrayable(img)
+		if doc.lastChannel != viewedChannel && doc.img != nil {
+			grayable, ok := getGrayable(doc.img)
 
 			if ok {
 				grayable.SetGray(viewedChannel)
@@ -552,21 +1911,44 @@ func run() {
 				prt.Errorf("failed to set gray")
 			}
 
-			pasteGrayable, ok := getGrayable(pasteImg)
+			pasteGrayable, ok := getGrayable(doc.pasteImg)
 			if ok {
 				pasteGrayable.SetGray(viewedChannel)
 			}
-			lastChannel = viewedChannel
-			refreshSprites = true
+			doc.lastChannel = viewedChannel
+			doc.refreshSprites = true
+			if err := doc.showSnapshot(doc.comparing, viewedChannel); err != nil {
+				prt.Errorf("failed to show snapshot: %v", err)
+			}
+		}
+		if doc.comparing >= 0 {
+			modified += fmt.Sprintf(" [Snapshot %s]", snapshotNames[doc.comparing])
 		}
-		win.SetTitle(fmt.Sprintf("%s - %s%s", baseTitle, fileName, modified))
+		titleName := doc.fileName
+		if titleName == "(new)" {
+			titleName = doc.displayName()
+		}
+		win.SetTitle(fmt.Sprintf("%s - %s%s", baseTitle, titleName, modified))
 
-		camZoom *= math.Pow(camZoomSpeed, ui.MouseScroll().Y)
+		ctrlPressed := ui.Pressed(pixel.KeyLeftControl) || ui.Pressed(pixel.KeyRightControl)
+		doc.camPos, doc.camZoom = applyScrollGesture(ui.MouseScroll(), doc.camPos, doc.camZoom, camZoomSpeed, trackpadPanSpeed, trackpadMode, ctrlPressed)
 
 		win.Update()
 	}
 }
 
+// applyScrollGesture turns scroll deltas into camera movement. Mouse wheels and
+// trackpad pinches (which GLFW reports as ctrl+scroll) zoom, while horizontal
+// deltas and, in trackpad mode, two-finger vertical scrolls pan the camera.
+func applyScrollGesture(scroll, camPos pixel.Vec, camZoom, zoomSpeed, panSpeed float64, trackpadMode, ctrlPressed bool) (pixel.Vec, float64) {
+	if ctrlPressed || !trackpadMode {
+		camZoom *= math.Pow(zoomSpeed, scroll.Y)
+		scroll.Y = 0
+	}
+	pan := pixel.V(-scroll.X, scroll.Y).Scaled(panSpeed / camZoom)
+	return camPos.Add(pan), camZoom
+}
+
 func handleUndo(prt *app.Printer, undoStack *types.UndoRedoStack, index int, img *image.Image, refreshSprite *bool, lastChannel *hdrColors.GraySetting, currColor *[4]float32, selection *pixel.Rect) {
 	state, err := undoStack.Undo(index)
 	if err != nil {
@@ -586,18 +1968,12 @@ func handleRedo(prt *app.Printer, undoStack *types.UndoRedoStack, index int, img
 }
 
 func restoreState(prt *app.Printer, state *types.UndoRedoState, img *image.Image, refreshSprite *bool, lastChannel *hdrColors.GraySetting, currColor *[4]float32, selection *pixel.Rect) {
-	if len(state.Img) > 0 {
-		bufR := bufio.NewReader(bytes.NewBuffer(state.Img))
-		exr, err := openexr.LoadOpenEXR(*bufR)
-		if err != nil {
-			prt.Errorf("%v", err)
-			return
-		}
-		newImg, err := exr.HdrImage()
-		if err != nil {
-			prt.Errorf("%v", err)
-			return
-		}
+	newImg, err := state.Image()
+	if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	if newImg != nil {
 		*img = newImg
 	}
 	*refreshSprite = true
@@ -606,40 +1982,63 @@ func restoreState(prt *app.Printer, state *types.UndoRedoState, img *image.Image
 	*selection = state.Selection
 }
 
-func writeImage(out io.Writer, img image.Image, fileName string) (err error) {
+func writeImage(out io.Writer, img image.Image, attrs []openexr.Attribute, fileName string, ddsOpts dds.WriteHDROptions) (err error) {
 	if filepath.Ext(fileName) == ".exr" {
-		err = openexr.WriteHDR(out, img)
+		err = openexr.WriteHDRWithAttributes(out, img, withEXRPreview(attrs, img))
 	} else if filepath.Ext(fileName) == ".dds" {
-		err = dds.WriteHDR(out, img)
+		err = dds.WriteHDRWithOptions(out, img, ddsOpts)
 	} else {
 		err = fmt.Errorf("only saving to .exr or .dds implemented currently")
 	}
 	return
 }
 
-func saveFile(prt *app.Printer, fileName string, img image.Image, saved *bool, currColor [4]float32, selection pixel.Rect, undoStack *types.UndoRedoStack) {
+func writeImageFile(img image.Image, attrs []openexr.Attribute, fileName string, ddsOpts dds.WriteHDROptions) error {
 	out, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		prt.Errorf("failed to save: %v", err)
-		return
+		return err
 	}
 
 	defer out.Close()
 
-	err = writeImage(out, img, fileName)
+	err = writeImage(out, img, attrs, fileName, ddsOpts)
 	if err != nil {
-		prt.Errorf("failed to write img to %s: %v", fileName, err)
-		return
+		return fmt.Errorf("failed to write img to %s: %v", fileName, err)
 	}
 	length, err := out.Seek(0, io.SeekCurrent)
 	if err == nil {
 		out.Truncate(length)
 	}
+	return nil
+}
+
+// saveFile writes img to fileName, converted to the pixel type chosen in conv.
+// What the conversion lost is sent to reports, and fileName to savedPaths once
+// it is written. layer is the image undoStack is for, which is img unless img
+// is flattened from layers.
+func saveFile(prt *app.Printer, fileName string, img, layer image.Image, attrs []openexr.Attribute, conv conversionSettings, reports chan<- *conversionReport, savedPaths chan<- string, saved *bool, currColor [4]float32, selection pixel.Rect, undoStack *types.UndoRedoStack, prov *provenance.Map, baseline string) {
+	out, report, err := convertForSave(img, conv)
+	if err == nil {
+		err = stashOriginal(prt, fileName)
+	}
+	if err == nil {
+		err = writeImageFile(out, attrs, fileName, conv.ddsOptions())
+	}
+	if err != nil {
+		prt.Errorf("failed to save: %v", err)
+		return
+	}
 	*saved = true
-	undoStack.Push("Save File", fileName, true, img, currColor, selection)
+	undoStack.Push("Save File", fileName, true, layer, currColor, selection)
+	saveProvenance(prt, prov, fileName)
+	savedPaths <- fileName
+	if report != nil {
+		reportConversion(prt, reports, fileName, *report)
+	}
+	warnUnchanged(prt, fileName, baseline, img)
 }
 
-func saveFileAs(prt *app.Printer, fileName *string, img image.Image, saved *bool, currColor [4]float32, selection pixel.Rect, undoStack *types.UndoRedoStack) {
+func saveFileAs(prt *app.Printer, fileName *string, img, layer image.Image, attrs []openexr.Attribute, conv conversionSettings, reports chan<- *conversionReport, savedPaths chan<- string, saved *bool, currColor [4]float32, selection pixel.Rect, undoStack *types.UndoRedoStack, prov *provenance.Map, baseline string) {
 	nextFileName, err := dialog.File().Filter("DDS or EXR files", "dds", "exr").Save()
 	if err == dialog.ErrCancelled {
 		return
@@ -648,62 +2047,289 @@ func saveFileAs(prt *app.Printer, fileName *string, img image.Image, saved *bool
 		return
 	}
 	*fileName = nextFileName
-	saveFile(prt, *fileName, img, saved, currColor, selection, undoStack)
+	saveFile(prt, *fileName, img, layer, attrs, conv, reports, savedPaths, saved, currColor, selection, undoStack, prov, baseline)
 }
 
-func bulkConvertFiles(prt *app.Printer, exrToDDS bool, task *types.BackgroundStatus) {
-	var directionString, globStr, outSuffix string
-	if exrToDDS {
-		directionString = "EXR to DDS"
-		globStr = "*.exr"
-		outSuffix = ".dds"
-	} else {
-		directionString = "DDS to EXR"
-		globStr = "*.dds"
-		outSuffix = ".exr"
+// saveFileCopy writes img to a new path without changing the document's file
+// name or saved state.
+func saveFileCopy(prt *app.Printer, img image.Image, attrs []openexr.Attribute, conv conversionSettings, reports chan<- *conversionReport) {
+	copyFileName, err := dialog.File().Title("Save a Copy").Filter("DDS or EXR files", "dds", "exr").Save()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	out, report, err := convertForSave(img, conv)
+	if err == nil {
+		err = stashOriginal(prt, copyFileName)
+	}
+	if err == nil {
+		err = writeImageFile(out, attrs, copyFileName, conv.ddsOptions())
 	}
-	cwd, err := os.Getwd()
 	if err != nil {
-		task.OnCancel()
-		prt.Errorf("bulk convert: failed to get current working directory: %v", err)
+		prt.Errorf("failed to save copy: %v", err)
 		return
 	}
-	folderName, err := dialog.Directory().Title(fmt.Sprintf("Select folder to bulk convert %v...", directionString)).SetStartDir(cwd).Browse()
+	if report != nil {
+		reportConversion(prt, reports, copyFileName, *report)
+	}
+}
+
+func chooseBaseline(prt *app.Printer, baseline *string) {
+	baselineFileName, err := dialog.File().Title("Set Baseline").Filter("DDS or EXR files", "dds", "exr").Load()
 	if err == dialog.ErrCancelled {
-		task.OnCancel()
 		return
 	} else if err != nil {
-		prt.Errorf("bulk convert: failed to get directory: %v", err)
-		task.OnCancel()
+		prt.Errorf("%v", err)
 		return
 	}
+	*baseline = baselineFileName
+	prt.Infof("Baseline set to '%s'", baselineFileName)
+}
 
-	var success, failed int = 0, 0
-	matches, err := filepath.Glob(filepath.Join(folderName, globStr))
-	for idx, path := range matches {
-		convImg, err := loadImage(path)
-		if task != nil && err != nil {
-			task.OnProgress(idx+1, len(matches), err)
-		}
+// exportChangeReport writes every texel of img that differs from the baseline
+// file to a CSV or Markdown file chosen by the user.
+func exportChangeReport(prt *app.Printer, title, baseline string, img image.Image, names changes.Names) {
+	baseImg, _, err := loadImage(baseline)
+	if err != nil {
+		prt.Errorf("failed to load baseline '%s': %v", baseline, err)
+		return
+	}
+	rawBase, ok := rawImage(baseImg)
+	if !ok {
+		prt.Errorf("baseline '%s' is not an HDR image", baseline)
+		return
+	}
+	rawImg, ok := rawImage(img)
+	if !ok {
+		prt.Errorf("failed to export change report: not an HDR image")
+		return
+	}
+	diff, err := changes.Diff(rawBase, rawImg)
+	if err != nil {
+		prt.Errorf("failed to export change report: %v", err)
+		return
+	}
+
+	reportFileName, err := dialog.File().Title("Export Change Report").Filter("CSV files", "csv").Filter("Markdown files", "md").Save()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	if filepath.Ext(reportFileName) == "" {
+		reportFileName += ".csv"
+	}
+	out, err := os.Create(reportFileName)
+	if err != nil {
+		prt.Errorf("failed to export change report: %v", err)
+		return
+	}
+	defer out.Close()
+	if strings.EqualFold(filepath.Ext(reportFileName), ".md") {
+		err = changes.WriteMarkdown(out, title, filepath.Base(baseline), diff, names)
+	} else {
+		err = changes.WriteCSV(out, diff, names)
+	}
+	if err != nil {
+		prt.Errorf("failed to export change report: %v", err)
+		return
+	}
+	prt.Infof("Wrote %d changed texels to '%s'", len(diff), reportFileName)
+}
+
+// exportPreviewPNG writes a tone-mapped 8-bit rendering of img, as currently
+// viewed, to a PNG chosen by the user.
+func exportPreviewPNG(prt *app.Printer, img image.Image, opts preview.Options) {
+	pngFileName, err := dialog.File().Title("Export Preview PNG").Filter("PNG files", "png").Save()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	if filepath.Ext(pngFileName) == "" {
+		pngFileName += ".png"
+	}
+	out, err := os.Create(pngFileName)
+	if err != nil {
+		prt.Errorf("failed to export preview: %v", err)
+		return
+	}
+	defer out.Close()
+	if err := preview.WritePNG(out, img, opts); err != nil {
+		prt.Errorf("failed to export preview: %v", err)
+	}
+}
+
+type bulkConvertSettings struct {
+	// NameTemplate names each output file, see expandOutputName
+	NameTemplate string
+	// Recursive converts files in subfolders too
+	Recursive bool
+	// SeparateOutput asks for an output folder rather than writing each output
+	// next to its input
+	SeparateOutput bool
+	// MirrorTree recreates the input subfolders under the output folder
+	MirrorTree bool
+	// Conversion sets the pixel type of the output files
+	Conversion conversionSettings
+}
+
+func defaultBulkConvertSettings() bulkConvertSettings {
+	return bulkConvertSettings{
+		NameTemplate: "{name}.{ext}",
+		MirrorTree:   true,
+		Conversion:   defaultConversionSettings(),
+	}
+}
+
+// expandOutputName fills in the tokens of a bulk conversion name template:
+//
+//	{name}      input file name without its extension
+//	{inext}     input file extension, without the dot
+//	{ext}       output file extension, without the dot
+//	{parentdir} name of the folder containing the input file
+func expandOutputName(template, inputPath, outExt string) (string, error) {
+	inExt := filepath.Ext(inputPath)
+	name := strings.NewReplacer(
+		"{name}", strings.TrimSuffix(filepath.Base(inputPath), inExt),
+		"{inext}", strings.TrimPrefix(inExt, "."),
+		"{ext}", strings.TrimPrefix(outExt, "."),
+		"{parentdir}", filepath.Base(filepath.Dir(inputPath)),
+	).Replace(template)
+	if strings.ContainsAny(name, "{}") {
+		return "", fmt.Errorf("unknown token in name template %q", template)
+	}
+	if len(strings.TrimSpace(name)) == 0 {
+		return "", fmt.Errorf("name template %q produced an empty file name", template)
+	}
+	return name, nil
+}
+
+// bulkConvertOutputPath returns where the conversion of inputPath, found while
+// searching inputRoot, should be written.
+func bulkConvertOutputPath(settings bulkConvertSettings, inputPath, inputRoot, outputRoot, outExt string) (string, error) {
+	name, err := expandOutputName(settings.NameTemplate, inputPath, outExt)
+	if err != nil {
+		return "", err
+	}
+	outDir := filepath.Dir(inputPath)
+	if settings.SeparateOutput {
+		outDir = outputRoot
+		if settings.MirrorTree {
+			rel, err := filepath.Rel(inputRoot, filepath.Dir(inputPath))
+			if err != nil {
+				return "", err
+			}
+			outDir = filepath.Join(outputRoot, rel)
+		}
+	}
+	outPath := filepath.Join(outDir, name)
+	if filepath.Clean(outPath) == filepath.Clean(inputPath) {
+		return "", fmt.Errorf("output would overwrite input %v", inputPath)
+	}
+	return outPath, nil
+}
+
+func findBulkConvertInputs(folderName, inExt string, recursive bool) ([]string, error) {
+	if !recursive {
+		return filepath.Glob(filepath.Join(folderName, "*"+inExt))
+	}
+	matches := make([]string, 0)
+	err := filepath.WalkDir(folderName, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), inExt) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// bulkConvertFiles converts every file in a folder chosen by the user. The
+// files that lose precision on the way are listed in a report sent to reports.
+func bulkConvertFiles(prt *app.Printer, exrToDDS bool, settings bulkConvertSettings, reports chan<- *conversionReport, task *types.BackgroundStatus) {
+	var directionString, inSuffix, outSuffix string
+	if exrToDDS {
+		directionString = "EXR to DDS"
+		inSuffix = ".exr"
+		outSuffix = ".dds"
+	} else {
+		directionString = "DDS to EXR"
+		inSuffix = ".dds"
+		outSuffix = ".exr"
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		task.OnCancel()
+		prt.Errorf("bulk convert: failed to get current working directory: %v", err)
+		return
+	}
+	folderName, err := dialog.Directory().Title(fmt.Sprintf("Select folder to bulk convert %v...", directionString)).SetStartDir(cwd).Browse()
+	if err == dialog.ErrCancelled {
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("bulk convert: failed to get directory: %v", err)
+		task.OnCancel()
+		return
+	}
+
+	var outputRoot string
+	if settings.SeparateOutput {
+		outputRoot, err = dialog.Directory().Title("Select output folder...").SetStartDir(folderName).Browse()
+		if err == dialog.ErrCancelled {
+			task.OnCancel()
+			return
+		} else if err != nil {
+			prt.Errorf("bulk convert: failed to get output directory: %v", err)
+			task.OnCancel()
+			return
+		}
+	}
+
+	var success, failed int = 0, 0
+	var lossy []convertedFile
+	matches, err := findBulkConvertInputs(folderName, inSuffix, settings.Recursive)
+	if err != nil {
+		prt.Errorf("bulk convert: failed to list %v: %v", folderName, err)
+	}
+	for idx, path := range matches {
+		convImg, _, err := loadImage(path)
+		if task != nil && err != nil {
+			task.OnProgress(idx+1, len(matches), err)
+		}
 		if err != nil {
 			prt.Errorf("bulk convert: failed to load %v: %v", path, err)
 			failed += 1
 			continue
 		}
 
-		convertedPath := strings.TrimSuffix(path, filepath.Ext(path)) + outSuffix
-		out, err := os.OpenFile(convertedPath, os.O_CREATE|os.O_WRONLY, 0644)
+		convertedPath, err := bulkConvertOutputPath(settings, path, folderName, outputRoot, outSuffix)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(convertedPath), 0755)
+		}
 		if task != nil && err != nil {
 			task.OnProgress(idx+1, len(matches), err)
 		}
 		if err != nil {
-			prt.Errorf("bulk convert: failed to open %v: %v", convertedPath, err)
+			prt.Errorf("bulk convert: %v", err)
 			failed += 1
 			continue
 		}
-		defer out.Close()
 
-		err = writeImage(out, convImg, convertedPath)
+		outImg, report, err := convertForSave(convImg, settings.Conversion)
+		if err == nil {
+			err = writeImageFile(outImg, nil, convertedPath, settings.Conversion.ddsOptions())
+		}
+		if err == nil && report != nil && !report.Lossless() {
+			lossy = append(lossy, convertedFile{Path: convertedPath, Report: *report})
+		}
 		if err != nil {
 			prt.Errorf("bulk convert: failed to write %v: %v", convertedPath, err)
 			failed += 1
@@ -717,27 +2343,288 @@ func bulkConvertFiles(prt *app.Printer, exrToDDS bool, task *types.BackgroundSta
 	if task != nil {
 		task.OnComplete(success, failed, len(matches))
 	}
+	if len(lossy) > 0 {
+		prt.Infof("bulk convert: %d of %d files lost precision converting to %s", len(lossy), success, precision.Format(settings.Conversion.Precision))
+		reports <- &conversionReport{
+			Title: fmt.Sprintf("Bulk %v conversion", directionString),
+			Files: lossy,
+		}
+	}
+}
+
+type headerComparison struct {
+	Paths [2]string
+	Rows  []fileinfo.Row
+}
+
+// compareHeaders asks for two files and sends the comparison of their headers
+// to results.
+func compareHeaders(prt *app.Printer, results chan<- *headerComparison) {
+	var comparison headerComparison
+	var infos [2]*fileinfo.Info
+	for i, title := range []string{"Select first file to compare", "Select second file to compare"} {
+		path, err := dialog.File().Title(title).Filter("DDS or EXR files", "dds", "exr").Load()
+		if err == dialog.ErrCancelled {
+			return
+		} else if err != nil {
+			prt.Errorf("%v", err)
+			return
+		}
+		infos[i], err = fileinfo.Load(path)
+		if err != nil {
+			prt.Errorf("compare headers: failed to read %v: %v", path, err)
+			return
+		}
+		comparison.Paths[i] = path
+	}
+	comparison.Rows = fileinfo.Compare(infos[0], infos[1])
+	results <- &comparison
 }
 
-func openFile(prt *app.Printer, fileName *string, img *image.Image, refreshSprite *bool, lastChannel *hdrColors.GraySetting, currColor [4]float32, selection pixel.Rect, undoStack *types.UndoRedoStack) {
+func openFile(prt *app.Printer, openedDocs chan<- *document, currColor [4]float32, task *types.BackgroundStatus) {
 	nextFileName, err := dialog.File().Filter("DDS or EXR files", "dds", "exr").Load()
+	if err == dialog.ErrCancelled {
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		task.OnCancel()
+		return
+	}
+	openPath(prt, nextFileName, openedDocs, currColor, task)
+}
+
+// displayProfile is a monitor profile picked from the View menu.
+type displayProfile struct {
+	profile *icc.Profile
+	name    string
+}
+
+func profileName(profile *icc.Profile, path string) string {
+	if profile.Description != "" {
+		return profile.Description
+	}
+	return filepath.Base(path)
+}
+
+func detectDisplayProfile(prt *app.Printer, displayProfiles chan<- *displayProfile) {
+	path, err := icc.DisplayProfilePath()
+	if err != nil {
+		prt.Errorf("Detecting display profile: %v", err)
+		return
+	}
+	loadDisplayProfile(prt, path, displayProfiles)
+}
+
+func chooseDisplayProfile(prt *app.Printer, displayProfiles chan<- *displayProfile) {
+	path, err := dialog.File().Filter("ICC profiles", "icc", "icm").Load()
 	if err == dialog.ErrCancelled {
 		return
 	} else if err != nil {
 		prt.Errorf("%v", err)
 		return
 	}
-	nextImg, err := loadImage(nextFileName)
+	loadDisplayProfile(prt, path, displayProfiles)
+}
+
+func loadDisplayProfile(prt *app.Printer, path string, displayProfiles chan<- *displayProfile) {
+	profile, err := icc.Load(path)
 	if err != nil {
-		prt.Errorf("Failed to load '%s': %v", nextFileName, err)
+		prt.Errorf("Loading display profile '%s': %v", path, err)
 		return
 	}
-	*fileName = nextFileName
-	*img = nextImg
-	*refreshSprite = true
-	*lastChannel = hdrColors.GraySettingNone
-	undoStack.Clear()
-	undoStack.Push("Load File", *fileName, true, *img, currColor, selection)
+	prt.Infof("Using display profile '%s'", path)
+	displayProfiles <- &displayProfile{profile: profile, name: profileName(profile, path)}
+}
+
+// openPath loads the image or project at path in the background, reporting
+// its progress to task, which can be cancelled from the status bar.
+func openPath(prt *app.Printer, path string, openedDocs chan<- *document, currColor [4]float32, task *types.BackgroundStatus) {
+	task.Name = fmt.Sprintf("Open %s", filepath.Base(path))
+	task.Cancellable = true
+	newDoc, err := loadDocument(path, currColor, task)
+	if errors.Is(err, errLoadCancelled) {
+		prt.Infof("Cancelled opening '%s'", path)
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("Failed to load '%s': %v", path, err)
+		task.OnError(err)
+		return
+	}
+	openedDocs <- newDoc
+	task.OnComplete(1, 0, 1)
+}
+
+// forwardablePaths makes local paths absolute, since the running instance may
+// have a different working directory.
+func forwardablePaths(paths []string) []string {
+	forwarded := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if len(path) == 0 {
+			continue
+		}
+		if !isURL(path) {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+		}
+		forwarded = append(forwarded, path)
+	}
+	return forwarded
+}
+
+// loadDocument loads the image or project at path into a new document,
+// reporting its progress to task if it isn't nil.
+func loadDocument(path string, currColor [4]float32, task *types.BackgroundStatus) (*document, error) {
+	if strings.EqualFold(filepath.Ext(path), project.Extension) {
+		return loadProject(path, currColor, task)
+	}
+	img, attrs, err := loadImageProgress(path, task)
+	if err != nil {
+		return nil, err
+	}
+	newDoc := newDocument(path, img, true)
+	newDoc.attributes = attrs
+	newDoc.undoStack.Push("Load File", newDoc.fileName, true, newDoc.img, currColor, newDoc.selection)
+	return newDoc, nil
+}
+
+// registerFileTypes associates DDS and EXR files with the running executable.
+// Files opened from Explorer share one window.
+func registerFileTypes(makeDefault bool) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return shell.RegisterFileTypes(exePath, []string{"--single-instance"}, makeDefault)
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// errLoadCancelled is returned by loads cancelled from the status bar.
+var errLoadCancelled = errors.New("cancelled")
+
+// progressWriter reports the number of bytes written through it to a task,
+// and fails once the task is cancelled.
+type progressWriter struct {
+	task    *types.BackgroundStatus
+	written int
+	total   int
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	if p.task.Cancelled() {
+		return 0, errLoadCancelled
+	}
+	p.written += len(b)
+	if p.total > 0 {
+		p.task.OnProgress(p.written, p.total, nil)
+	}
+	return len(b), nil
+}
+
+// progressReader reports the number of bytes read through it to a task, and
+// fails once the task is cancelled.
+type progressReader struct {
+	r     io.Reader
+	task  *types.BackgroundStatus
+	read  int
+	total int
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if p.task.Cancelled() {
+		return 0, errLoadCancelled
+	}
+	n, err := p.r.Read(b)
+	p.read += n
+	if p.total > 0 {
+		p.task.OnProgress(p.read, p.total, nil)
+	}
+	return n, err
+}
+
+// downloadImage saves the file at url to a temporary file and returns its
+// path. The extension is taken from the URL, or from the file's magic number
+// if the URL doesn't end in .dds or .exr.
+func downloadImage(url string, task *types.BackgroundStatus) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned %v", resp.Status)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	ext := strings.ToLower(path.Ext(resp.Request.URL.Path))
+	if ext != ".dds" && ext != ".exr" {
+		magic, err := body.Peek(4)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case string(magic) == "DDS ":
+			ext = ".dds"
+		case binary.LittleEndian.Uint32(magic) == openexr.EXR_MAGIC:
+			ext = ".exr"
+		default:
+			return "", fmt.Errorf("not a DDS or EXR file")
+		}
+	}
+
+	out, err := os.CreateTemp("", "lut-editor-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	progress := &progressWriter{task: task, total: int(resp.ContentLength)}
+	if _, err := io.Copy(io.MultiWriter(out, progress), body); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// openURL downloads an image and opens it in a new tab. The document is not
+// associated with the temporary download, so saving asks for a new location.
+func openURL(prt *app.Printer, url string, openedDocs chan<- *document, currColor [4]float32, task *types.BackgroundStatus) {
+	task.Name = fmt.Sprintf("Download %s", path.Base(url))
+	task.Cancellable = true
+	tempPath, err := downloadImage(url, task)
+	if errors.Is(err, errLoadCancelled) {
+		prt.Infof("Cancelled downloading '%s'", url)
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("Failed to download '%s': %v", url, err)
+		task.OnError(err)
+		return
+	}
+	defer os.Remove(tempPath)
+
+	task.Name = fmt.Sprintf("Open %s", path.Base(url))
+	img, attrs, err := loadImageProgress(tempPath, task)
+	if errors.Is(err, errLoadCancelled) {
+		prt.Infof("Cancelled opening '%s'", url)
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("Failed to load '%s': %v", url, err)
+		task.OnError(err)
+		return
+	}
+	newDoc := newDocument("(new)", img, true)
+	newDoc.title = path.Base(url)
+	newDoc.attributes = attrs
+	newDoc.undoStack.Push("Load URL", newDoc.fileName, true, newDoc.img, currColor, newDoc.selection)
+	openedDocs <- newDoc
+	task.OnComplete(1, 0, 1)
 }
 
 func getPixelCoords(camera pixel.Matrix, spriteCenter pixel.Vec, mousePosition pixel.Vec) (x, y int) {
@@ -746,6 +2633,29 @@ func getPixelCoords(camera pixel.Matrix, spriteCenter pixel.Vec, mousePosition p
 	return
 }
 
+// nudgeDelta returns how far the arrow keys pressed this frame move a
+// selection: one pixel, or 8 with shift held.
+func nudgeDelta(ui *pixelui.UI) pixel.Vec {
+	step := 1.0
+	if ui.Pressed(pixel.KeyLeftShift) || ui.Pressed(pixel.KeyRightShift) {
+		step = 8.0
+	}
+	delta := pixel.ZV
+	if ui.JustPressed(pixel.KeyLeft) || ui.Repeated(pixel.KeyLeft) {
+		delta.X -= step
+	}
+	if ui.JustPressed(pixel.KeyRight) || ui.Repeated(pixel.KeyRight) {
+		delta.X += step
+	}
+	if ui.JustPressed(pixel.KeyUp) || ui.Repeated(pixel.KeyUp) {
+		delta.Y += step
+	}
+	if ui.JustPressed(pixel.KeyDown) || ui.Repeated(pixel.KeyDown) {
+		delta.Y -= step
+	}
+	return delta
+}
+
 func fromPixelCoords(_ pixel.Matrix, spriteCenter pixel.Vec, x, y int) pixel.Vec {
 	return pixel.V(float64(x), float64(y)).Sub(spriteCenter)
 }
@@ -782,29 +2692,161 @@ func drawChannelWindow(viewedChannel *hdrColors.GraySetting, visible *bool) {
 	imgui.End()
 }
 
-func drawToolWindow(currentTool *lmbTool, visible *bool) {
-	imgui.BeginV("Tool", visible, imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoCollapse)
+func drawFileInfoWindow(prt *app.Printer, doc *document, colorManaged colorManagement, metadata *metadataInput, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 420, Y: 320}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("File Info", visible, imgui.WindowFlagsNoCollapse)
 	{
-		imgui.RadioButtonInt("Draw", (*int)(currentTool), int(toolDraw))
-		imgui.RadioButtonInt("Select", (*int)(currentTool), int(toolSelect))
-		imgui.RadioButtonInt("Move Selected Pixels", (*int)(currentTool), int(toolMoveSelected))
+		if doc.img == nil {
+			textDisabled("No image")
+		} else {
+			imgui.Text(fmt.Sprintf("File: %s", doc.fileName))
+			imgui.Text(fmt.Sprintf("Size: %dx%d", doc.img.Bounds().Dx(), doc.img.Bounds().Dy()))
+			imgui.Text(fmt.Sprintf("Pixel type: %s", fileinfo.ModelName(doc.img.ColorModel())))
+			imgui.Text(fmt.Sprintf("Content hash: %s", doc.contentHash()))
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("XXH64 of the texel values, the same whatever format the image is saved in")
+			}
+			drawSampleLevelCombo(doc)
+			drawSchemaInfo(doc)
+
+			imgui.Separator()
+			colorimetry := openexr.ColorimetryFromAttributes(doc.attributes)
+			if c := colorimetry.Chromaticities; c != nil {
+				imgui.Text(fmt.Sprintf("Red primary: (%.4f, %.4f)", c.RedX, c.RedY))
+				imgui.Text(fmt.Sprintf("Green primary: (%.4f, %.4f)", c.GreenX, c.GreenY))
+				imgui.Text(fmt.Sprintf("Blue primary: (%.4f, %.4f)", c.BlueX, c.BlueY))
+				imgui.Text(fmt.Sprintf("White point: (%.4f, %.4f)", c.WhiteX, c.WhiteY))
+			} else {
+				textDisabled("Chromaticities: not set (Rec. 709 / sRGB)")
+			}
+			if colorimetry.WhiteLuminance != nil {
+				imgui.Text(fmt.Sprintf("White luminance: %g cd/m^2", *colorimetry.WhiteLuminance))
+			}
+			if n := colorimetry.AdoptedNeutral; n != nil {
+				imgui.Text(fmt.Sprintf("Adopted neutral: (%.4f, %.4f)", n[0], n[1]))
+			}
+			if !colorManaged.enabled {
+				textDisabled("Display: unmanaged (View > Color Management is off)")
+			} else {
+				if _, ok := doc.displayTransform(); ok {
+					imgui.Text("Display: converted to sRGB primaries")
+				}
+				if colorManaged.display != nil {
+					imgui.Text(fmt.Sprintf("Display profile: %s", colorManaged.profileName))
+				}
+			}
+			if colorManaged.encodeSRGB {
+				imgui.Text("Values: linear, sRGB encoded for display")
+			} else {
+				imgui.Text("Values: shown as stored")
+			}
+
+			// String attributes are listed for editing with the metadata
+			other := slices.DeleteFunc(slices.Clone(doc.attributes), func(attr openexr.Attribute) bool {
+				return attr.Type == "string"
+			})
+			if len(other) > 0 {
+				imgui.Separator()
+				for _, field := range fileinfo.DescribeAttributes(other) {
+					imgui.Text(fmt.Sprintf("%s: %s", field.Name, field.Value))
+				}
+			}
+			imgui.Separator()
+			drawMetadata(prt, doc, metadata)
+		}
 	}
 	imgui.End()
 }
 
-func drawColorWindow(precision *int32, currColor *([4]float32), visible *bool) {
-	imgui.BeginV("Color", visible, imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoCollapse)
+func drawHeaderComparisonWindow(comparison *headerComparison, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 640, Y: 480}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Compare Headers", visible, imgui.WindowFlagsNoCollapse)
 	{
-		format := fmt.Sprintf("%%.%df", *precision)
-		imgui.ColorEdit4V("Color", currColor, imgui.ColorEditFlagsFloat|imgui.ColorEditFlagsHDR|imgui.ColorEditFlagsNoInputs)
-		imgui.DragFloatV("Red", &currColor[0], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
-		imgui.DragFloatV("Green", &currColor[1], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
-		imgui.DragFloatV("Blue", &currColor[2], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
-		imgui.DragFloatV("Alpha", &currColor[3], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
-		imgui.InputInt("Precision", precision)
-		*precision = min(max(*precision, 0), 10)
-	}
-	imgui.End()
+		mismatches := 0
+		for _, row := range comparison.Rows {
+			if row.Mismatch {
+				mismatches++
+			}
+		}
+		imgui.Text(fmt.Sprintf("%d mismatched field(s)", mismatches))
+		tableFlags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable | imgui.TableFlagsScrollY
+		if imgui.BeginTableV("HeaderTable", 3, tableFlags, imgui.Vec2{}, 0) {
+			imgui.TableSetupScrollFreeze(0, 1)
+			imgui.TableSetupColumn("Field")
+			imgui.TableSetupColumn(filepath.Base(comparison.Paths[0]))
+			imgui.TableSetupColumn(filepath.Base(comparison.Paths[1]))
+			imgui.TableHeadersRow()
+			for _, row := range comparison.Rows {
+				if row.Mismatch {
+					imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+				}
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				imgui.Text(row.Name)
+				for _, value := range row.Values {
+					imgui.TableNextColumn()
+					if value == "" {
+						textDisabled("(missing)")
+					} else {
+						imgui.Text(value)
+					}
+				}
+				if row.Mismatch {
+					imgui.PopStyleColor()
+				}
+			}
+			imgui.EndTable()
+		}
+	}
+	imgui.End()
+}
+
+func drawToolWindow(currentTool *lmbTool, blendMode *blend.Mode, channelLock *blend.Lock, brush *brushSettings, mirror *mirrorMode, snap *snapMode, visible *bool) {
+	imgui.BeginV("Tool", visible, imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoCollapse)
+	{
+		imgui.RadioButtonInt("Draw", (*int)(currentTool), int(toolDraw))
+		imgui.RadioButtonInt("Erase", (*int)(currentTool), int(toolErase))
+		imgui.RadioButtonInt("Select", (*int)(currentTool), int(toolSelect))
+		imgui.RadioButtonInt("Move Selected Pixels", (*int)(currentTool), int(toolMoveSelected))
+		imgui.RadioButtonInt("Zoom", (*int)(currentTool), int(toolZoom))
+		imgui.Separator()
+		// Used by drawing and when moved or pasted pixels are applied
+		if imgui.BeginCombo("Blend", blendMode.String()) {
+			for _, mode := range blend.Modes {
+				if imgui.SelectableV(mode.String(), mode == *blendMode, 0, imgui.Vec2{}) {
+					*blendMode = mode
+				}
+			}
+			imgui.EndCombo()
+		}
+		imgui.Text("Lock")
+		for i, name := range []string{"R", "G", "B", "A"} {
+			imgui.SameLine()
+			imgui.Checkbox(name, &channelLock[i])
+		}
+		drawBrushSliders(brush)
+		// Mirrors across the middle of the selection, or of the image
+		drawMirrorCombo(mirror)
+		// Lines moved pixels up with the selection size or schema blocks
+		drawSnapCombo(snap)
+	}
+	imgui.End()
+}
+
+func drawColorWindow(precision *int32, currColor *([4]float32), recent [][4]float32, visible *bool) {
+	imgui.BeginV("Color", visible, imgui.WindowFlagsAlwaysAutoResize|imgui.WindowFlagsNoCollapse)
+	{
+		format := fmt.Sprintf("%%.%df", *precision)
+		imgui.ColorEdit4V("Color", currColor, imgui.ColorEditFlagsFloat|imgui.ColorEditFlagsHDR|imgui.ColorEditFlagsNoInputs)
+		imgui.DragFloatV("Red", &currColor[0], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
+		imgui.DragFloatV("Green", &currColor[1], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
+		imgui.DragFloatV("Blue", &currColor[2], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
+		imgui.DragFloatV("Alpha", &currColor[3], 0.01, 0.0, 0.0, format, imgui.SliderFlagsNone)
+		imgui.InputInt("Precision", precision)
+		*precision = min(max(*precision, 0), 10)
+		drawRecentColors(recent, currColor)
+	}
+	imgui.End()
 }
 
 func drawGrid(win *opengl.Window, camZoom float64, spriteFrame pixel.Rect) {
@@ -862,7 +2904,61 @@ func drawSelection(win *opengl.Window, camZoom float64, selectionArea pixel.Rect
 	selectionBox.Draw(win)
 }
 
-func drawStatusBar(mousePos pixel.Vec, color [4]float32, tasks types.TaskMap, selection pixel.Rect) {
+// drawLockedTexels shades the locked texels, given as rectangles in image
+// coordinates.
+func drawLockedTexels(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, height int, rects []image.Rectangle) {
+	if len(rects) == 0 {
+		return
+	}
+	shade := imdraw.New(nil)
+	for _, rect := range rects {
+		area := imageRectToSelection(rect, spriteCenter, height)
+		shade.Color = pixel.RGBA{
+			R: 0.1,
+			G: 0.1,
+			B: 0.1,
+			A: 0.45,
+		}
+		shade.Push(area.Min)
+		shade.Push(area.Max)
+		shade.Rectangle(0)
+		shade.Color = pixel.RGBA{
+			R: 0.8,
+			G: 0.3,
+			B: 0.3,
+			A: 0.8,
+		}
+		shade.Push(area.Min)
+		shade.Push(area.Max)
+		shade.Rectangle(1.5 / camZoom)
+	}
+	shade.Draw(win)
+}
+
+// brushFootprint returns the area, in sprite-centered world coordinates, that
+// the active tool will affect when clicking at pixel (x, y).
+func brushFootprint(spriteCenter pixel.Vec, x, y int) pixel.Rect {
+	min := pixel.V(float64(x), float64(y)).Sub(spriteCenter)
+	return pixel.Rect{Min: min, Max: min.Add(pixel.V(1, 1))}
+}
+
+func drawBrushPreview(win *opengl.Window, camZoom float64, footprint pixel.Rect) {
+	outline := imdraw.New(nil)
+	outline.Color = pixel.RGBA{
+		R: 0.9,
+		G: 0.9,
+		B: 0.9,
+		A: 0.8,
+	}
+	outline.Push(footprint.Min)
+	outline.Push(footprint.Max)
+	outline.Rectangle(2.0 / camZoom)
+	outline.Draw(win)
+}
+
+// drawStatusBar shows the mouse position, the color under it, read from the
+// DDS level sampledFrom names if it isn't "", and the running tasks.
+func drawStatusBar(mousePos pixel.Vec, color [4]float32, sampledFrom string, tasks types.TaskMap, selection pixel.Rect) {
 	viewport := imgui.MainViewport()
 	imgui.SetNextWindowPos(imgui.Vec2{
 		X: viewport.Pos().X,
@@ -874,7 +2970,8 @@ func drawStatusBar(mousePos pixel.Vec, color [4]float32, tasks types.TaskMap, se
 		Y: imgui.FrameHeight(),
 	})
 
-	flags := (imgui.WindowFlagsNoDecoration | imgui.WindowFlagsNoInputs |
+	// Inputs are left on for the Cancel buttons of tasks
+	flags := (imgui.WindowFlagsNoDecoration |
 		imgui.WindowFlagsNoMove | imgui.WindowFlagsNoScrollWithMouse |
 		imgui.WindowFlagsNoSavedSettings | imgui.WindowFlagsNoBringToFrontOnFocus |
 		imgui.WindowFlagsNoBackground | imgui.WindowFlagsMenuBar)
@@ -882,6 +2979,9 @@ func drawStatusBar(mousePos pixel.Vec, color [4]float32, tasks types.TaskMap, se
 	if imgui.BeginV("StatusBar", nil, flags) {
 		if imgui.BeginMenuBar() {
 			imgui.Textf("Mouse: (%.1f, %.1f) RGBA: (%3.3f, %3.3f, %3.3f, %3.3f)", mousePos.X, mousePos.Y, color[0], color[1], color[2], color[3])
+			if sampledFrom != "" {
+				imgui.Textf("from %s", sampledFrom)
+			}
 			imgui.Separator()
 			if selection.Area() > 0 {
 				imgui.Textf("Selection: (%d, %d) -> (%d, %d)", int(selection.Min.X), int(selection.Min.Y), int(selection.Max.X), int(selection.Max.Y))
@@ -900,12 +3000,18 @@ func drawStatusBar(mousePos pixel.Vec, color [4]float32, tasks types.TaskMap, se
 				switch task.Status {
 				case types.TaskRunning:
 					imgui.Text(task.Name)
+					if task.Cancellable && imgui.Button("Cancel") {
+						task.Cancel()
+					}
 					imgui.ProgressBar(float32(task.Progress) / float32(task.Total))
 				case types.TaskIdle:
 					imgui.Text(task.Name)
 					imgui.ProgressBarV(-float32(imgui.Time()), imgui.Vec2{X: -1.0, Y: 0.0}, "Starting...")
 				case types.TaskFinished, types.TaskFailed:
 					imgui.Textf("%v: %v", task.Name, task.Message)
+					if task.Output != "" && imgui.IsItemHovered() {
+						imgui.SetTooltip(task.Output)
+					}
 				case types.TaskCancelled:
 					delete(tasks, lastTask)
 				}
@@ -917,34 +3023,101 @@ func drawStatusBar(mousePos pixel.Vec, color [4]float32, tasks types.TaskMap, se
 	}
 }
 
-func createNewImage(img *image.Image, newImageConfirm, refreshSprite, saved *bool, fileName *string, lastChannel *hdrColors.GraySetting, width, height *int32, precision *int) bool {
+// newImageSettings are the choices in the New Image dialog.
+type newImageSettings struct {
+	width, height int32
+	// precision is an index into hdrColors.Formats
+	precision int
+	// fill is the value every texel starts with
+	fill [4]float32
+	// schemaDefaults starts the columns of a schema matching the size from
+	// their channels' default values instead, where the schema gives them
+	schemaDefaults bool
+}
+
+// newImageSchema returns the first schema matching the size chosen in
+// settings that gives default values, or nil.
+func newImageSchema(settings newImageSettings, schemas []help.Schema) *help.Schema {
+	for _, i := range help.Match(schemas, image.Pt(int(settings.width), int(settings.height))) {
+		if schemas[i].HasDefaults() {
+			return &schemas[i]
+		}
+	}
+	return nil
+}
+
+func createNewImage(img *image.Image, settings *newImageSettings, schemas []help.Schema) bool {
 	viewport := imgui.MainViewport()
 	windowSize := imgui.Vec2{
-		X: 0.2 * viewport.Size().X,
-		Y: 0.2 * viewport.Size().Y,
+		X: 0.25 * viewport.Size().X,
+		Y: 0.3 * viewport.Size().Y,
 	}
 	var responded bool
 	centerWindow(windowSize)
-	if *newImageConfirm || confirmationDialog(windowSize, "Create new file?", "New File", "Confirm", "Cancel", &responded) {
-		if newImageDialog(width, height, precision, windowSize, &responded) {
-			*width = max(*width, 1)
-			*height = max(*height, 1)
-			switch *precision {
-			case 0:
-				*img = hdrColors.NewNRGBA128FImage(image.Rect(0, 0, int(*width), int(*height)))
-			case 1:
-				*img = hdrColors.NewNRGBA64FImage(image.Rect(0, 0, int(*width), int(*height)))
-			}
-			*refreshSprite = true
-			*saved = false
-			*fileName = "(new)"
-			*lastChannel = hdrColors.GraySettingNone
-		}
-		*newImageConfirm = !responded || *img == nil
+	schema := newImageSchema(*settings, schemas)
+	if newImageDialog(settings, schema, windowSize, &responded) {
+		settings.width = max(settings.width, 1)
+		settings.height = max(settings.height, 1)
+		rect := image.Rect(0, 0, int(settings.width), int(settings.height))
+		raw := hdrColors.Formats[settings.precision].New(rect)
+		if !settings.schemaDefaults {
+			schema = nil
+		}
+		fill := [4]float64{float64(settings.fill[0]), float64(settings.fill[1]), float64(settings.fill[2]), float64(settings.fill[3])}
+		for x := 0; x < rect.Dx(); x++ {
+			c := fill
+			if schema != nil {
+				c = schema.Defaults(x, fill)
+			}
+			for y := 0; y < rect.Dy(); y++ {
+				raw.SetRaw(x, y, c)
+			}
+		}
+		*img = raw
 	}
 	return responded
 }
 
+// newTemplateImage returns an image the size of schema, in the precision
+// chosen for new images, with each column started from the schema's defaults
+// and limits.
+func newTemplateImage(schema *help.Schema, settings newImageSettings) image.Image {
+	rect := image.Rectangle{Max: schema.Size()}
+	raw := hdrColors.Formats[settings.precision].New(rect)
+	fill := [4]float64{float64(settings.fill[0]), float64(settings.fill[1]), float64(settings.fill[2]), float64(settings.fill[3])}
+	for x := 0; x < rect.Dx(); x++ {
+		c := schema.Template(x, fill)
+		if c == ([4]float64{}) {
+			continue
+		}
+		for y := 0; y < rect.Dy(); y++ {
+			raw.SetRaw(x, y, c)
+		}
+	}
+	return raw
+}
+
+// reportClipboardError logs a failed clipboard action, and also shows it in the
+// status bar when another application was holding the clipboard, as trying
+// again later will likely work.
+func reportClipboardError(prt *app.Printer, tasks types.TaskMap, action string, err error) {
+	prt.Errorf("failed to %s: %v", action, err)
+	if errors.Is(err, clipboard.ErrBusy) {
+		tasks.Add("Clipboard").OnError(err)
+	}
+}
+
+func newImageFromClipboard() (image.Image, error) {
+	clipImg, err := clipboard.ReadHDR()
+	if err != nil {
+		return nil, err
+	}
+	if clipImg == nil {
+		return nil, fmt.Errorf("unknown clipboard image format")
+	}
+	return clipImg, nil
+}
+
 func getGrayable(img image.Image) (hdrColors.Grayable, bool) {
 	if img == nil {
 		return nil, false
@@ -984,26 +3157,53 @@ func getGrayable(img image.Image) (hdrColors.Grayable, bool) {
 	return grayable, ok
 }
 
-func loadImage(path string) (image.Image, error) {
+// loadImage decodes the image at path. For EXR files it also returns the
+// header attributes not needed to decode the pixels, so they can be kept when
+// the image is saved again.
+func loadImage(path string) (image.Image, []openexr.Attribute, error) {
+	return loadImageProgress(path, nil)
+}
+
+// loadImageProgress is loadImage reporting the bytes read to task, and
+// stopping with errLoadCancelled if it is cancelled. task may be nil.
+func loadImageProgress(path string, task *types.BackgroundStatus) (image.Image, []openexr.Attribute, error) {
 	im, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer im.Close()
+	if task == nil {
+		return decodeImage(im, filepath.Ext(path))
+	}
+	info, err := im.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	img, attrs, err := decodeImage(&progressReader{r: im, task: task, total: int(info.Size())}, filepath.Ext(path))
+	if task.Cancelled() {
+		return nil, nil, errLoadCancelled
+	}
+	return img, attrs, err
+}
 
+// decodeImage reads an image in the format given by the file extension ext.
+func decodeImage(r io.Reader, ext string) (image.Image, []openexr.Attribute, error) {
 	var img image.Image
-	if filepath.Ext(path) == ".exr" {
+	var attrs []openexr.Attribute
+	var err error
+	if ext == ".exr" {
 		var exr *openexr.OpenEXR
-		bufR := bufio.NewReader(im)
+		bufR := bufio.NewReader(r)
 		exr, err = openexr.LoadOpenEXR(*bufR)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		attrs = exr.Attributes
 		img, err = exr.HdrImage()
 	} else {
-		img, _, err = image.Decode(im)
+		img, _, err = image.Decode(r)
 	}
-	return img, err
+	return img, attrs, err
 }
 
 func textCentered(text string) {
@@ -1040,7 +3240,7 @@ func imageToSelectionRect(rect image.Rectangle, center pixel.Vec, height int) pi
 
 func handleCopy(selection pixel.Rect, center pixel.Vec, img image.Image) error {
 	imageRect := selectionToImageRect(selection, center, img.Bounds().Dy())
-	copiedImg := copySubImage(img, imageRect)
+	copiedImg := editor.Copy(img, imageRect)
 	err := clipboard.WriteHDR(copiedImg)
 	if err != nil {
 		return err
@@ -1048,17 +3248,45 @@ func handleCopy(selection pixel.Rect, center pixel.Vec, img image.Image) error {
 	return clipboard.WriteRect(selection)
 }
 
-func handleCut(selection pixel.Rect, center pixel.Vec, img image.Image) error {
-	imageRect := selectionToImageRect(selection, center, img.Bounds().Dy())
-	cutImg := cutSubImage(img, imageRect)
-	err := clipboard.WriteHDR(cutImg)
+// handleCopyViewport copies the part of the document visible in the window to
+// the system clipboard as an ordinary 8-bit image, scaled to the current zoom.
+// The tone mapping settings are shared with Export Preview PNG.
+func handleCopyViewport(win *opengl.Window, cam pixel.Matrix, doc *document, viewedChannel hdrColors.GraySetting, colorManaged colorManagement, opts preview.Options, grid, selection bool) error {
+	center := doc.sprite.Frame().Center()
+	height := doc.img.Bounds().Dy()
+	x0, y0 := getPixelCoords(cam, center, win.Bounds().Min)
+	x1, y1 := getPixelCoords(cam, center, win.Bounds().Max)
+	opts.Bounds = image.Rect(x0, height-y1-1, x1+1, height-y0).Intersect(doc.img.Bounds())
+	if opts.Bounds.Empty() {
+		return fmt.Errorf("image is not in view")
+	}
+	opts.Scale = int32(min(max(math.Round(doc.camZoom), 1), 64))
+	opts.Grid = grid
+	opts.Selection = image.Rectangle{}
+	if selection && doc.selection.Area() > 0 {
+		opts.Selection = selectionToImageRect(doc.selection, center, height)
+	}
+	return clipboard.WriteImage(preview.Render(doc.displayImage(doc.img, viewedChannel, colorManaged), opts))
+}
+
+// handleCut cuts the selection of doc, leaving locked channels and texels,
+// and copies what was cut to the clipboard.
+func handleCut(doc *document, lock blend.Lock) error {
+	cut := &editor.Cut{Rect: selectionToImageRect(doc.selection, doc.sprite.Frame().Center(), doc.img.Bounds().Dy()), Lock: lock}
+	if err := doc.apply(cut); err != nil {
+		return err
+	}
+	err := clipboard.WriteHDR(cut.Result)
 	if err != nil {
 		return err
 	}
-	return clipboard.WriteRect(selection)
+	return clipboard.WriteRect(doc.selection)
 }
 
-func handlePaste(bounds image.Rectangle, viewedChannel hdrColors.GraySetting, center pixel.Vec) (image.Image, *pixel.Rect, error) {
+// handlePaste reads the image on the clipboard and where to put it. Pixels go
+// back where they were copied from if that fits in bounds, and are otherwise
+// centered on target, moved inside bounds as far as they fit.
+func handlePaste(bounds image.Rectangle, viewedChannel hdrColors.GraySetting, center pixel.Vec, target image.Point) (image.Image, *pixel.Rect, error) {
 	pasteImg, err := clipboard.ReadHDR()
 	if err != nil {
 		return nil, nil, err
@@ -1073,353 +3301,82 @@ func handlePaste(bounds image.Rectangle, viewedChannel hdrColors.GraySetting, ce
 	if imageRect.In(bounds) {
 		return pasteImg, storedSelection, nil
 	}
-	return pasteImg, nil, nil
-}
-
-func handleStartMoveSelection(selection pixel.Rect, center pixel.Vec, img image.Image, pasteImg *image.Image, refreshSprites *bool, prevTool, tool *lmbTool) {
-	imageRect := selectionToImageRect(selection, center, img.Bounds().Dy())
-	*pasteImg = cutSubImage(img, imageRect)
-	*refreshSprites = true
-	*prevTool = *tool
-	*tool = toolMoveSelected
+	placed := placePaste(pasteImg.Bounds().Size(), bounds, target)
+	selection := imageRectToSelection(placed, center, bounds.Dy())
+	return pasteImg, &selection, nil
 }
 
-func handleImageCombine(selection pixel.Rect, center pixel.Vec, img image.Image, pasteImg image.Image) {
-	imageRect := selectionToImageRect(selection, center, img.Bounds().Dy())
-	combineSubImage(img, pasteImg, imageRect)
+// placePaste returns a rectangle of size centered on target, moved inside
+// bounds as far as it fits. Pixels wider or taller than bounds are lined up
+// with its top left.
+func placePaste(size image.Point, bounds image.Rectangle, target image.Point) image.Rectangle {
+	corner := target.Sub(size.Div(2))
+	corner.X = max(bounds.Min.X, min(corner.X, bounds.Max.X-size.X))
+	corner.Y = max(bounds.Min.Y, min(corner.Y, bounds.Max.Y-size.Y))
+	return image.Rectangle{Min: corner, Max: corner.Add(size)}
 }
 
-func copySubImage(img image.Image, selection image.Rectangle) image.Image {
-	switch img.ColorModel() {
-	case hdrColors.NRGBA128FModel:
-		hdr, ok := img.(*hdrColors.NRGBA128FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128FImage)
-			if !ok {
-				break
-			}
-		}
-		oldGray := hdr.Grayscale
-		hdr.SetGray(hdrColors.GraySettingNone)
-		toReturn := hdrColors.NewNRGBA128FImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
-		for y := selection.Min.Y; y < selection.Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				toReturn.Set(retX, retY, hdr.NRGBA128FAt(x, y))
-			}
-		}
-		toReturn.SetGray(oldGray)
-		hdr.SetGray(oldGray)
-		return toReturn
-	case hdrColors.NRGBA128UModel:
-		hdr, ok := img.(*hdrColors.NRGBA128UImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128UImage)
-			if !ok {
-				break
-			}
-		}
-		oldGray := hdr.Grayscale
-		hdr.SetGray(hdrColors.GraySettingNone)
-		toReturn := hdrColors.NewNRGBA128UImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
-		for y := selection.Min.Y; y < selection.Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				toReturn.Set(retX, retY, hdr.NRGBA128UAt(x, y))
-			}
-		}
-		toReturn.SetGray(oldGray)
-		hdr.SetGray(oldGray)
-		return toReturn
-	case hdrColors.NRGBA64FModel:
-		hdr, ok := img.(*hdrColors.NRGBA64FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA64FImage)
-			if !ok {
-				break
-			}
+// pasteTarget returns the first of points, in window coordinates, that is over
+// the image, as the texel it is over. If none are it returns the image center.
+func pasteTarget(cam pixel.Matrix, doc *document, points ...pixel.Vec) image.Point {
+	bounds := doc.img.Bounds()
+	for _, p := range points {
+		x, y := getPixelCoords(cam, doc.sprite.Frame().Center(), p)
+		texel := image.Pt(x, bounds.Dy()-y-1)
+		if texel.In(bounds) {
+			return texel
 		}
-		oldGray := hdr.Grayscale
-		hdr.SetGray(hdrColors.GraySettingNone)
-		toReturn := hdrColors.NewNRGBA64FImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
-		for y := selection.Min.Y; y < selection.Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				toReturn.Set(retX, retY, hdr.NRGBA64FAt(x, y))
-			}
-		}
-		toReturn.SetGray(oldGray)
-		hdr.SetGray(oldGray)
-		return toReturn
 	}
-	return nil
+	return image.Pt((bounds.Min.X+bounds.Max.X)/2, (bounds.Min.Y+bounds.Max.Y)/2)
 }
 
-func cutSubImage(img image.Image, selection image.Rectangle) image.Image {
-	switch img.ColorModel() {
-	case hdrColors.NRGBA128FModel:
-		hdr, ok := img.(*hdrColors.NRGBA128FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128FImage)
-			if !ok {
-				break
-			}
-		}
-		oldGray := hdr.Grayscale
-		hdr.SetGray(hdrColors.GraySettingNone)
-		toReturn := hdrColors.NewNRGBA128FImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
-		for y := selection.Min.Y; y < selection.Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				toReturn.Set(retX, retY, hdr.NRGBA128FAt(x, y))
-				hdr.Set(x, y, hdrColors.NRGBA128F{
-					R: 0,
-					G: 0,
-					B: 0,
-					A: 0,
-				})
-			}
-		}
-		toReturn.SetGray(oldGray)
-		hdr.SetGray(oldGray)
-		return toReturn
-	case hdrColors.NRGBA128UModel:
-		hdr, ok := img.(*hdrColors.NRGBA128UImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128UImage)
-			if !ok {
-				break
-			}
-		}
-		oldGray := hdr.Grayscale
-		hdr.SetGray(hdrColors.GraySettingNone)
-		toReturn := hdrColors.NewNRGBA128UImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
-		for y := selection.Min.Y; y < selection.Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				toReturn.Set(retX, retY, hdr.NRGBA128UAt(x, y))
-				hdr.Set(x, y, hdrColors.NRGBA128U{
-					R: 0,
-					G: 0,
-					B: 0,
-					A: 0,
-				})
-			}
-		}
-		toReturn.SetGray(oldGray)
-		hdr.SetGray(oldGray)
-		return toReturn
-	case hdrColors.NRGBA64FModel:
-		hdr, ok := img.(*hdrColors.NRGBA64FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA64FImage)
-			if !ok {
-				break
-			}
-		}
-		oldGray := hdr.Grayscale
-		hdr.SetGray(hdrColors.GraySettingNone)
-		toReturn := hdrColors.NewNRGBA64FImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
-		for y := selection.Min.Y; y < selection.Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				toReturn.Set(retX, retY, hdr.NRGBA64FAt(x, y))
-				hdr.Set(x, y, hdrColors.NRGBA64F{
-					R: 0,
-					G: 0,
-					B: 0,
-					A: 0,
-				})
-			}
-		}
-		toReturn.SetGray(oldGray)
-		hdr.SetGray(oldGray)
-		return toReturn
+func handleStartMoveSelection(doc *document, lock blend.Lock, prevTool, tool *lmbTool) error {
+	cut := &editor.Cut{Rect: selectionToImageRect(doc.selection, doc.sprite.Frame().Center(), doc.img.Bounds().Dy()), Lock: lock}
+	if err := doc.apply(cut); err != nil {
+		return err
 	}
+	doc.pasteImg = cut.Result
+	*prevTool = *tool
+	*tool = toolMoveSelected
 	return nil
 }
 
-func combineSubImage(img, pasteImg image.Image, selection image.Rectangle) {
-	switch img.ColorModel() {
-	case hdrColors.NRGBA128FModel:
-		hdr, ok := img.(*hdrColors.NRGBA128FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128FImage)
-			if !ok {
-				break
-			}
-		}
-		pasteHdr, ok := pasteImg.(*hdrColors.NRGBA128FImage)
-		if !ok {
-			break
-		}
-		oldGray := pasteHdr.Grayscale
-		pasteHdr.SetGray(hdrColors.GraySettingNone)
-		for y := int(math.Max(0, float64(selection.Min.Y))); y < selection.Max.Y && y < img.Bounds().Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				hdr.Set(x, y, pasteHdr.NRGBA128FAt(retX, retY))
-			}
-		}
-		pasteHdr.SetGray(oldGray)
-	case hdrColors.NRGBA128UModel:
-		hdr, ok := img.(*hdrColors.NRGBA128UImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128UImage)
-			if !ok {
-				break
-			}
-		}
-		pasteHdr, ok := pasteImg.(*hdrColors.NRGBA128UImage)
-		if !ok {
-			break
-		}
-		oldGray := pasteHdr.Grayscale
-		pasteHdr.SetGray(hdrColors.GraySettingNone)
-		for y := int(math.Max(0, float64(selection.Min.Y))); y < selection.Max.Y && y < img.Bounds().Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				hdr.Set(x, y, pasteHdr.NRGBA128UAt(retX, retY))
-			}
-		}
-		pasteHdr.SetGray(oldGray)
-	case hdrColors.NRGBA64FModel:
-		hdr, ok := img.(*hdrColors.NRGBA64FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA64FImage)
-			if !ok {
-				break
-			}
-		}
-		pasteHdr, ok := pasteImg.(*hdrColors.NRGBA64FImage)
-		if !ok {
-			break
-		}
-		oldGray := pasteHdr.Grayscale
-		pasteHdr.SetGray(hdrColors.GraySettingNone)
-		for y := int(math.Max(0, float64(selection.Min.Y))); y < selection.Max.Y && y < img.Bounds().Max.Y; y++ {
-			retY := y - selection.Min.Y
-			for x := selection.Min.X; x < selection.Max.X; x++ {
-				retX := x - selection.Min.X
-				hdr.Set(x, y, pasteHdr.NRGBA64FAt(retX, retY))
-			}
-		}
-		pasteHdr.SetGray(oldGray)
+// switchToolDocument drops the drag state of the tool when the active
+// document changes from from to to. Pixels being moved float over their own
+// document, so the move tool is only kept while to has some, and is picked
+// again on returning to a document that does.
+func switchToolDocument(from, to *document, tool, prevTool *lmbTool) {
+	if from != nil {
+		from.selectionOffset = pixel.ZV
+	}
+	if *tool == toolMoveSelected && to.pasteImg == nil {
+		*tool = *prevTool
+	} else if *tool != toolMoveSelected && to.pasteImg != nil {
+		*prevTool, *tool = *tool, toolMoveSelected
 	}
 }
 
-func setHDRFromFloats(x, y int, currColor [4]float32, img image.Image) {
-	switch img.ColorModel() {
-	case hdrColors.NRGBA128FModel:
-		color := hdrColors.NRGBA128F{
-			R: currColor[0],
-			G: currColor[1],
-			B: currColor[2],
-			A: currColor[3],
-		}
-		hdr, ok := img.(*hdrColors.NRGBA128FImage)
-		if ok {
-			hdr.Set(x, y, color)
-		} else {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok := ddsImg.Image.(*hdrColors.NRGBA128FImage)
-			if !ok {
-				break
-			}
-			hdr.Set(x, y, color)
-		}
-	case hdrColors.NRGBA128UModel:
-		color := hdrColors.NRGBA128U{
-			R: uint32(currColor[0] * float32(0xFFFFFFFF)),
-			G: uint32(currColor[1] * float32(0xFFFFFFFF)),
-			B: uint32(currColor[2] * float32(0xFFFFFFFF)),
-			A: uint32(currColor[3] * float32(0xFFFFFFFF)),
-		}
-		hdr, ok := img.(*hdrColors.NRGBA128UImage)
-		if ok {
-			hdr.Set(x, y, color)
-		} else {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok := ddsImg.Image.(*hdrColors.NRGBA128UImage)
-			if !ok {
-				break
-			}
-			hdr.Set(x, y, color)
-		}
-	case hdrColors.NRGBA64FModel:
-		color := hdrColors.NRGBA64F{
-			R: float16.Fromfloat32(currColor[0]),
-			G: float16.Fromfloat32(currColor[1]),
-			B: float16.Fromfloat32(currColor[2]),
-			A: float16.Fromfloat32(currColor[3]),
-		}
-		hdr, ok := img.(*hdrColors.NRGBA64FImage)
-		if ok {
-			hdr.Set(x, y, color)
-		} else {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				break
-			}
-			hdr, ok := ddsImg.Image.(*hdrColors.NRGBA64FImage)
-			if !ok {
-				break
-			}
-			hdr.Set(x, y, color)
+// copyChannel copies channel from of every pixel in img into channel into.
+func copyChannel(img image.Image, from, into int) {
+	raw, ok := rawImage(img)
+	if !ok || from == into {
+		return
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := raw.RawAt(x, y)
+			c[into] = c[from]
+			raw.SetRaw(x, y, c)
 		}
 	}
 }
 
+// rawImage returns the stored pixels of img, looking inside DDS images.
+func rawImage(img image.Image) (hdrColors.RawImage, bool) {
+	return editor.Raw(img)
+}
+
 func hdrColorToFloats(prt *app.Printer, pxColor color.Color, colorModel color.Model) [4]float32 {
 	var color [4]float32
 	switch colorModel {
@@ -1459,68 +3416,479 @@ func hdrColorToFloats(prt *app.Printer, pxColor color.Color, colorModel color.Mo
 	return color
 }
 
-func centerWindow(windowSize imgui.Vec2) {
+func centerWindow(windowSize imgui.Vec2) {
+	viewport := imgui.MainViewport()
+	imgui.SetNextWindowPos(imgui.Vec2{
+		X: 0.5 * (viewport.Size().X - windowSize.X),
+		Y: 0.5 * (viewport.Size().Y - windowSize.Y),
+	})
+	imgui.SetNextWindowSize(windowSize)
+}
+
+// textDisabled writes text in the style's disabled text color, for hints and
+// notes that shouldn't draw the eye.
+func textDisabled(text string) {
+	imgui.PushStyleColor(imgui.StyleColorText, imgui.CurrentStyle().Color(imgui.StyleColorTextDisabled))
+	imgui.Text(text)
+	imgui.PopStyleColor()
+}
+
+func confirmationDialog(windowSize imgui.Vec2, text, title, confirm, deny string, responded *bool) (response bool) {
+	*responded = false
+	imgui.BeginV(title, nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .33,
+	})
+	textCentered(text)
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .75,
+	})
+
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.2,
+	}
+
+	if imgui.ButtonV(confirm, buttonSize) {
+		*responded = true
+		response = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV(deny, buttonSize) {
+		*responded = true
+		response = false
+	}
+	imgui.End()
+	return
+}
+
+func newImageDialog(settings *newImageSettings, schema *help.Schema, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("New file settings", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.InputInt("Width", &settings.width)
+	imgui.InputInt("Height", &settings.height)
+	for i, format := range hdrColors.Formats {
+		if i > 0 {
+			imgui.SameLine()
+		}
+		imgui.RadioButtonInt(format.Name, &settings.precision, i)
+	}
+	imgui.ColorEdit4V("Fill", &settings.fill, imgui.ColorEditFlagsFloat|imgui.ColorEditFlagsHDR)
+	if schema != nil {
+		imgui.Checkbox(fmt.Sprintf("Use %s defaults", schema.Name), &settings.schemaDefaults)
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Start the schema's columns from their default values, where it gives them, instead of the fill")
+		}
+	} else {
+		textDisabled("No schema with defaults matches this size")
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.2,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .75,
+	})
+	if imgui.ButtonV("OK", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+func exportPreview(opts *preview.Options, diffing bool, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = exportPreviewDialog(opts, diffing, windowSize, &responded)
+	return responded
+}
+
+func exportPreviewDialog(opts *preview.Options, diffing bool, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Export preview settings", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.SliderInt("Scale", &opts.Scale, 1, 64)
+	imgui.SliderFloat("Exposure", &opts.Exposure, -8, 8)
+	toneMap := int(opts.ToneMap)
+	imgui.RadioButtonInt("Clamp", &toneMap, int(preview.ToneMapClamp))
+	imgui.SameLine()
+	imgui.RadioButtonInt("Reinhard", &toneMap, int(preview.ToneMapReinhard))
+	opts.ToneMap = preview.ToneMap(toneMap)
+	imgui.Checkbox("Grid", &opts.Grid)
+	imgui.SameLine()
+	imgui.Checkbox("Row and column labels", &opts.Labels)
+	if diffing {
+		imgui.Checkbox("Changes since commit", &opts.Diff)
+	} else {
+		textDisabled("Diff against HEAD to mark changed texels")
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Export", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+func pasteSpecial(from, into *int, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.2 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = pasteSpecialDialog(from, into, windowSize, &responded)
+	return responded
+}
+
+func pasteSpecialDialog(from, into *int, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Paste special", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	channels := []string{"Red", "Green", "Blue", "Alpha"}
+	imgui.Text("From")
+	for i, name := range channels {
+		imgui.SameLine()
+		imgui.RadioButtonInt(name+"##from", from, i)
+	}
+	imgui.Text("Into")
+	for i, name := range channels {
+		imgui.SameLine()
+		imgui.RadioButtonInt(name+"##into", into, i)
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Paste", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+func interpolate(columns *bool, easing *int, target string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.2 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = interpolateDialog(columns, easing, target, windowSize, &responded)
+	return responded
+}
+
+func interpolateDialog(columns *bool, easing *int, target string, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Interpolate", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	if *columns {
+		imgui.Text(fmt.Sprintf("Fill the %s between its first and last columns", target))
+	} else {
+		imgui.Text(fmt.Sprintf("Fill the %s between its first and last rows", target))
+	}
+	imgui.Checkbox("Columns", columns)
+	imgui.RadioButtonInt("Linear", easing, int(filter.EasingLinear))
+	imgui.SameLine()
+	imgui.RadioButtonInt("Smoothstep", easing, int(filter.EasingSmoothstep))
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Interpolate", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+func jitter(minOffset, maxOffset *float32, seed *int32, target string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.2 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = jitterDialog(minOffset, maxOffset, seed, target, windowSize, &responded)
+	return responded
+}
+
+func jitterDialog(minOffset, maxOffset *float32, seed *int32, target string, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Jitter", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Add a random offset to each channel in the %s", target))
+	imgui.DragFloatV("Min offset", minOffset, 0.001, 0.0, 0.0, "%.4f", imgui.SliderFlagsNone)
+	imgui.DragFloatV("Max offset", maxOffset, 0.001, 0.0, 0.0, "%.4f", imgui.SliderFlagsNone)
+	*maxOffset = max(*maxOffset, *minOffset)
+	imgui.InputInt("Seed", seed)
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Jitter", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+type quantizeSettings struct {
+	// useList snaps to the closest of values instead of a multiple of step
+	useList bool
+	step    float32
+	values  string
+}
+
+func quantize(settings *quantizeSettings, target string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.2 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = quantizeDialog(settings, target, windowSize, &responded)
+	return responded
+}
+
+func quantizeDialog(settings *quantizeSettings, target string, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Quantize", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Snap each channel in the %s to", target))
+	if imgui.RadioButton("Multiples of", !settings.useList) {
+		settings.useList = false
+	}
+	imgui.SameLine()
+	imgui.DragFloatV("##step", &settings.step, 0.01, 0.0, 0.0, "%.4f", imgui.SliderFlagsNone)
+	settings.step = max(settings.step, 0)
+	if imgui.RadioButton("Values", settings.useList) {
+		settings.useList = true
+	}
+	imgui.SameLine()
+	imgui.InputTextWithHintV("##values", "0, 1, 2, 5", &settings.values, 0, nil)
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Quantize", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+func shiftWrap(x, y *int32, target string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.2 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = shiftWrapDialog(x, y, target, windowSize, &responded)
+	return responded
+}
+
+func shiftWrapDialog(x, y *int32, target string, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Shift with wrap", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Offset the %s, wrapping pixels around its edges", target))
+	imgui.InputInt("Right", x)
+	imgui.InputInt("Down", y)
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Shift", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+func bulkConvert(settings *bulkConvertSettings, title string, confirmed *bool) bool {
 	viewport := imgui.MainViewport()
-	imgui.SetNextWindowPos(imgui.Vec2{
-		X: 0.5 * (viewport.Size().X - windowSize.X),
-		Y: 0.5 * (viewport.Size().Y - windowSize.Y),
-	})
-	imgui.SetNextWindowSize(windowSize)
+	windowSize := imgui.Vec2{
+		X: 0.3 * viewport.Size().X,
+		Y: 0.3 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = bulkConvertDialog(settings, title, windowSize, &responded)
+	return responded
 }
 
-func confirmationDialog(windowSize imgui.Vec2, text, title, confirm, deny string, responded *bool) (response bool) {
+func bulkConvertDialog(settings *bulkConvertSettings, title string, windowSize imgui.Vec2, responded *bool) (resp bool) {
 	*responded = false
 	imgui.BeginV(title, nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
-
-	imgui.SetCursorPos(imgui.Vec2{
-		X: imgui.CursorPosX(),
-		Y: windowSize.Y * .33,
-	})
-	textCentered(text)
-	imgui.SetCursorPos(imgui.Vec2{
-		X: imgui.CursorPosX(),
-		Y: windowSize.Y * .75,
-	})
-
+	imgui.InputText("Output name", &settings.NameTemplate)
+	textDisabled("Tokens: {name} {inext} {ext} {parentdir}")
+	imgui.Checkbox("Include subfolders", &settings.Recursive)
+	imgui.Checkbox("Write to a different folder", &settings.SeparateOutput)
+	if settings.SeparateOutput {
+		imgui.Checkbox("Mirror folder tree", &settings.MirrorTree)
+	}
+	drawConversionSettings(&settings.Conversion)
 	buttonSize := imgui.Vec2{
 		X: windowSize.X * 0.3,
-		Y: windowSize.Y * 0.2,
+		Y: windowSize.Y * 0.15,
 	}
-
-	if imgui.ButtonV(confirm, buttonSize) {
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Convert", buttonSize) {
 		*responded = true
-		response = true
+		resp = true
 	}
 	imgui.SameLine()
 	imgui.SetCursorPos(imgui.Vec2{
 		X: windowSize.X * 0.65,
 		Y: imgui.CursorPosY(),
 	})
-	if imgui.ButtonV(deny, buttonSize) {
+	if imgui.ButtonV("Cancel", buttonSize) {
 		*responded = true
-		response = false
+		resp = false
 	}
 	imgui.End()
 	return
 }
 
-func newImageDialog(width, height *int32, precision *int, windowSize imgui.Vec2, responded *bool) (resp bool) {
+func openURLPrompt(title, action string, url *string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.4 * viewport.Size().X,
+		Y: 0.15 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = openURLDialog(title, action, url, windowSize, &responded)
+	return responded
+}
+
+func openURLDialog(title, action string, url *string, windowSize imgui.Vec2, responded *bool) (resp bool) {
 	*responded = false
-	imgui.BeginV("New file settings", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
-	imgui.InputInt("Width", width)
-	imgui.InputInt("Height", height)
-	imgui.RadioButtonInt("Float", precision, 0)
-	imgui.SameLine()
-	imgui.RadioButtonInt("Half", precision, 1)
+	imgui.BeginV(title, nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.InputText("URL", url)
 	buttonSize := imgui.Vec2{
 		X: windowSize.X * 0.3,
-		Y: windowSize.Y * 0.2,
+		Y: windowSize.Y * 0.25,
 	}
 	imgui.SetCursorPos(imgui.Vec2{
 		X: imgui.CursorPosX(),
-		Y: windowSize.Y * .75,
+		Y: windowSize.Y * .65,
 	})
-	if imgui.ButtonV("OK", buttonSize) {
+	if imgui.ButtonV(action, buttonSize) && isURL(strings.TrimSpace(*url)) {
 		*responded = true
 		resp = true
 	}
@@ -1537,20 +3905,44 @@ func newImageDialog(width, height *int32, precision *int, windowSize imgui.Vec2,
 	return
 }
 
-func showMainMenuBar(img image.Image, channelsVisible, colorVisible, gridVisible, toolsVisible bool, undoStack *types.UndoRedoStack, selection pixel.Rect) (types.MenuResponse, int) {
+func showMainMenuBar(img image.Image, snapshots [2]bool, comparing int, bytesVisible, channelsVisible, consoleVisible, memoryVisible, historyVisible, rowFilterVisible, colorVisible, fileInfoVisible, graphVisible, gridVisible, smoothZoom, toolsVisible, trackpadMode, colorManaged bool, displayProfile string, hasBaseline, hasImageFile, hasRows, diffingHEAD, browserVisible, presetsVisible, projectVisible, layersVisible, labelsVisible, tooltipsVisible, usageVisible, schemaEditVisible, lutsLinked bool, lockedTexels int, lockedVisible, encodeSRGB, violationsVisible, groupsVisible bool, hiddenGroups map[int]bool, schemas []help.Schema, schema *help.Schema, undoStack *types.UndoRedoStack, selection pixel.Rect, feather int32, multiRows int, hasRowStyle, searched bool, pipelines []string, watchingInbox, trackingProvenance bool) (types.MenuResponse, int) {
 	response := types.MenuResponseNone
 	index := -1
 	if imgui.BeginMainMenuBar() {
 		if imgui.BeginMenu("File") {
-			response = showFileMenu(img)
+			response, index = showFileMenu(img, hasRows, hasImageFile, pipelines, watchingInbox, schemas)
 			imgui.EndMenu()
 		}
 		if imgui.BeginMenu("Edit") {
-			response, index = showEditMenu(undoStack, selection)
+			response, index = showEditMenu(img, undoStack, selection, lockedTexels, hasRowStyle, searched)
+			imgui.EndMenu()
+		}
+		if imgui.BeginMenu("Select") {
+			response = showSelectMenu(img, selection, feather, multiRows, hasBaseline)
+			imgui.EndMenu()
+		}
+		if imgui.BeginMenu("Image") {
+			response = showImageMenu(img, snapshots, comparing, selection)
+			imgui.EndMenu()
+		}
+		if imgui.BeginMenu("Filter") {
+			response = showFilterMenu(img, schema)
+			imgui.EndMenu()
+		}
+		if imgui.BeginMenu("Tools") {
+			response = showToolsMenu(img, hasBaseline, hasImageFile, diffingHEAD, lutsLinked, trackingProvenance)
 			imgui.EndMenu()
 		}
 		if imgui.BeginMenu("View") {
-			response = showViewMenu(channelsVisible, colorVisible, gridVisible, toolsVisible)
+			size := image.Point{}
+			if img != nil {
+				size = img.Bounds().Size()
+			}
+			response, index = showViewMenu(browserVisible, bytesVisible, channelsVisible, consoleVisible, memoryVisible, historyVisible, rowFilterVisible, colorVisible, fileInfoVisible, graphVisible, gridVisible, smoothZoom, toolsVisible, trackpadMode, colorManaged, displayProfile, presetsVisible, projectVisible, layersVisible, labelsVisible, tooltipsVisible, usageVisible, lockedVisible, encodeSRGB, violationsVisible, groupsVisible, hiddenGroups, schemas, size, schema)
+			imgui.EndMenu()
+		}
+		if imgui.BeginMenu("Help") {
+			response = showHelpMenu(schemaEditVisible)
 			imgui.EndMenu()
 		}
 		imgui.EndMainMenuBar()
@@ -1558,39 +3950,141 @@ func showMainMenuBar(img image.Image, channelsVisible, colorVisible, gridVisible
 	return response, index
 }
 
-func showFileMenu(img image.Image) types.MenuResponse {
-	response := types.MenuResponseNone
+func showFileMenu(img image.Image, hasRows, hasImageFile bool, pipelines []string, watchingInbox bool, schemas []help.Schema) (response types.MenuResponse, index int) {
+	response = types.MenuResponseNone
 	if imgui.MenuItemV("New", "ctrl-n", false, true) {
 		response = types.MenuResponseImageNew
 	}
+	if imgui.BeginMenuV("New from Template", len(schemas) > 0) {
+		for i := range schemas {
+			size := schemas[i].Size()
+			if imgui.MenuItemV(fmt.Sprintf("%s##template%d", schemas[i].Name, i), fmt.Sprintf("%dx%d", size.X, size.Y), false, true) {
+				response, index = types.MenuResponseImageNewFromTemplate, i
+			}
+			if schemas[i].Description != "" && imgui.IsItemHovered() {
+				imgui.SetTooltip(schemas[i].Description)
+			}
+		}
+		imgui.EndMenu()
+	}
+	if imgui.MenuItemV("New from Clipboard", "", false, clipboard.HasFormat(clipboard.FormatHDR)) {
+		response = types.MenuResponseImageNewFromClipboard
+	}
 	if imgui.MenuItemV("Open...", "ctrl-o", false, true) {
 		response = types.MenuResponseImageOpen
 	}
+	if imgui.MenuItem("Open As...") {
+		response = types.MenuResponseImageOpenAs
+	}
+	if imgui.MenuItem("Open URL...") {
+		response = types.MenuResponseImageOpenURL
+	}
+	if imgui.MenuItem("Browse Folder...") {
+		response = types.MenuResponseImageBrowse
+	}
+	if imgui.MenuItem("Watch Inbox Folder...") {
+		response = types.MenuResponseImageWatchInbox
+	}
+	if imgui.MenuItemV("Stop Watching Inbox", "", false, watchingInbox) {
+		response = types.MenuResponseImageStopInbox
+	}
+	if imgui.MenuItem("Open Project...") {
+		response = types.MenuResponseProjectOpen
+	}
 	if imgui.MenuItemV("Save", "ctrl-s", false, img != nil) {
 		response = types.MenuResponseImageSave
 	}
 	if imgui.MenuItemV("Save As...", "ctrl-shift-s", false, img != nil) {
 		response = types.MenuResponseImageSaveAs
 	}
+	if imgui.MenuItemV("Save a Copy...", "", false, img != nil) {
+		response = types.MenuResponseImageSaveCopy
+	}
+	if imgui.MenuItemV("Restore Original...", "", false, hasImageFile) {
+		response = types.MenuResponseImageRestoreOriginal
+	}
+	if imgui.MenuItem("Backup Folder...") {
+		response = types.MenuResponseImageBackupFolder
+	}
+	if imgui.MenuItem("Save Precision...") {
+		response = types.MenuResponseImageSavePrecision
+	}
+	if imgui.MenuItem("Save Hook...") {
+		response = types.MenuResponseImageSaveHook
+	}
+	if imgui.BeginMenuV("Export Pipeline", img != nil && hasImageFile) {
+		for i, name := range pipelines {
+			if imgui.MenuItem(name) {
+				response, index = types.MenuResponseImageExportPipeline, i
+			}
+		}
+		if len(pipelines) == 0 {
+			textDisabled("No pipelines in the preferences file")
+		}
+		imgui.Separator()
+		if imgui.MenuItem("Reload Preferences") {
+			response = types.MenuResponseImageReloadPreferences
+		}
+		imgui.EndMenu()
+	}
+	if imgui.MenuItem("Import Raw...") {
+		response = types.MenuResponseImageImportRaw
+	}
+	if imgui.MenuItemV("Import Row...", "", false, hasRows) {
+		response = types.MenuResponseImageImportRow
+	}
+	if imgui.MenuItemV("Export Row...", "", false, hasRows) {
+		response = types.MenuResponseImageExportRow
+	}
+	if imgui.MenuItemV("Save Project", "", false, img != nil) {
+		response = types.MenuResponseProjectSave
+	}
+	if imgui.MenuItemV("Save Project As...", "", false, img != nil) {
+		response = types.MenuResponseProjectSaveAs
+	}
+	if imgui.MenuItemV("Export Preview PNG...", "", false, img != nil) {
+		response = types.MenuResponseImageExportPreview
+	}
 	if imgui.MenuItem("Convert to DDS...") {
 		response = types.MenuResponseBulkConvertToDDS
 	}
 	if imgui.MenuItem("Convert to EXR...") {
 		response = types.MenuResponseBulkConvertToEXR
 	}
-	return response
+	return response, index
 }
 
-func showEditMenu(undoStack *types.UndoRedoStack, selection pixel.Rect) (resp types.MenuResponse, index int) {
+func showEditMenu(img image.Image, undoStack *types.UndoRedoStack, selection pixel.Rect, lockedTexels int, hasRowStyle, searched bool) (resp types.MenuResponse, index int) {
 	if imgui.MenuItemV("Copy", "ctrl-c", false, selection != pixel.ZR && selection.Area() > 0) {
 		resp = types.MenuResponseCopy
 	}
 	if imgui.MenuItemV("Cut", "ctrl-x", false, selection != pixel.ZR && selection.Area() > 0) {
 		resp = types.MenuResponseCut
 	}
+	if imgui.MenuItemV("Copy Viewport Image", "", false, img != nil) {
+		resp = types.MenuResponseCopyViewport
+	}
+	if imgui.MenuItemV("Copy Viewport Image (No Overlays)", "", false, img != nil) {
+		resp = types.MenuResponseCopyViewportNoOverlays
+	}
 	if imgui.MenuItemV("Paste", "ctrl-v", false, clipboard.HasFormat(clipboard.FormatHDR)) {
 		resp = types.MenuResponsePaste
 	}
+	if imgui.MenuItemV("Paste Special...", "", false, img != nil && clipboard.HasFormat(clipboard.FormatHDR)) {
+		resp = types.MenuResponsePasteSpecial
+	}
+	if imgui.MenuItemV("Paste as New Image", "", false, clipboard.HasFormat(clipboard.FormatHDR)) {
+		resp = types.MenuResponseImageNewFromClipboard
+	}
+	if imgui.MenuItemV("Copy Row Style", "", false, img != nil && selection != pixel.ZR && selection.Area() > 0) {
+		resp = types.MenuResponseEditCopyRowStyle
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Keep the top selected row to paste its columns over other rows")
+	}
+	if imgui.MenuItemV("Paste Row Style...", "", false, hasRowStyle && selection != pixel.ZR && selection.Area() > 0) {
+		resp = types.MenuResponseEditPasteRowStyle
+	}
 	if imgui.MenuItemV("Undo", "ctrl-z", false, len(undoStack.UndoStack) > 0) {
 		resp = types.MenuResponseUndo
 		index = max(len(undoStack.UndoStack)-2, 0)
@@ -1617,26 +4111,401 @@ func showEditMenu(undoStack *types.UndoRedoStack, selection pixel.Rect) (resp ty
 		}
 		imgui.EndMenu()
 	}
+	imgui.Separator()
+	if imgui.MenuItemV("Go To...", "ctrl-g", false, img != nil) {
+		resp = types.MenuResponseEditGoTo
+	}
+	if imgui.MenuItemV("Find...", "ctrl-f", false, img != nil) {
+		resp = types.MenuResponseEditFind
+	}
+	if imgui.MenuItemV("Find Next", "f3", false, img != nil && searched) {
+		resp = types.MenuResponseEditFindNext
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Lock Texels", "", false, selection != pixel.ZR && selection.Area() > 0) {
+		resp = types.MenuResponseLockTexels
+	}
+	if imgui.MenuItemV("Unlock Texels", "", false, selection != pixel.ZR && selection.Area() > 0 && lockedTexels > 0) {
+		resp = types.MenuResponseUnlockTexels
+	}
+	if imgui.MenuItemV("Unlock All Texels", "", false, lockedTexels > 0) {
+		resp = types.MenuResponseUnlockAllTexels
+	}
 	return
 }
 
-func showViewMenu(channelsVisible, colorVisible, gridVisible, toolsVisible bool) types.MenuResponse {
+func showImageMenu(img image.Image, snapshots [2]bool, comparing int, selection pixel.Rect) types.MenuResponse {
+	response := types.MenuResponseNone
+	if imgui.MenuItemV("Duplicate", "", false, img != nil) {
+		response = types.MenuResponseImageDuplicate
+	}
+	if imgui.MenuItemV("Shift with Wrap...", "", false, img != nil) {
+		response = types.MenuResponseImageShiftWrap
+	}
+	if imgui.MenuItemV("Repeat Selection...", "", false, selection != pixel.ZR && selection.Area() > 0) {
+		response = types.MenuResponseImageRepeatSelection
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Store Snapshot A", "", false, img != nil) {
+		response = types.MenuResponseSnapshotStoreA
+	}
+	if imgui.MenuItemV("Store Snapshot B", "", false, img != nil) {
+		response = types.MenuResponseSnapshotStoreB
+	}
+	if imgui.MenuItemV("Flip Snapshots", "t", false, snapshots[0] || snapshots[1]) {
+		response = types.MenuResponseSnapshotFlip
+	}
+	if imgui.MenuItemV("Show Live Image", "", comparing < 0, comparing >= 0) {
+		response = types.MenuResponseSnapshotLive
+	}
+	return response
+}
+
+func showFilterMenu(img image.Image, schema *help.Schema) types.MenuResponse {
+	response := types.MenuResponseNone
+	if imgui.MenuItemV("Interpolate...", "", false, img != nil) {
+		response = types.MenuResponseFilterInterpolate
+	}
+	if imgui.MenuItemV("Jitter...", "", false, img != nil) {
+		response = types.MenuResponseFilterJitter
+	}
+	if imgui.MenuItemV("Quantize...", "", false, img != nil) {
+		response = types.MenuResponseFilterQuantize
+	}
+	if imgui.MenuItemV("Gradient Map...", "", false, img != nil) {
+		response = types.MenuResponseFilterGradientMap
+	}
+	if imgui.MenuItemV("Recolor...", "", false, img != nil) {
+		response = types.MenuResponseFilterRecolor
+	}
+	if imgui.MenuItemV("Snap to Schema Values", "", false, img != nil && schema != nil && schema.HasValues()) {
+		response = types.MenuResponseFilterSnapSchemaValues
+	}
+	return response
+}
+
+func showToolsMenu(img image.Image, hasBaseline, hasImageFile, diffingHEAD, lutsLinked, trackingProvenance bool) types.MenuResponse {
 	response := types.MenuResponseNone
+	if imgui.MenuItem("Compare Headers...") {
+		response = types.MenuResponseToolsCompareHeaders
+	}
+	if imgui.MenuItem("Export Contact Sheet...") {
+		response = types.MenuResponseToolsContactSheet
+	}
+	if imgui.MenuItemV("Generate Variants...", "", false, img != nil) {
+		response = types.MenuResponseToolsGenerateVariants
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Set Baseline...", "", false, img != nil) {
+		response = types.MenuResponseToolsSetBaseline
+	}
+	if imgui.MenuItem("Baseline Library...") {
+		response = types.MenuResponseToolsBaselineLibrary
+	}
+	if imgui.MenuItemV("Export Change Report...", "", false, img != nil && hasBaseline) {
+		response = types.MenuResponseToolsExportChangeReport
+	}
+	if imgui.MenuItemV("Export Before/After...", "", false, img != nil && hasBaseline) {
+		response = types.MenuResponseToolsExportBeforeAfter
+	}
+	if imgui.MenuItemV("Match Levels to Baseline", "", false, img != nil && hasBaseline) {
+		response = types.MenuResponseToolsMatchBaseline
+	}
+	if imgui.MenuItemV("Diff Against HEAD", "", diffingHEAD, img != nil && hasImageFile) {
+		response = types.MenuResponseToolsDiffHEAD
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Export Edit Log...", "", false, img != nil) {
+		response = types.MenuResponseToolsExportEditLog
+	}
+	if imgui.MenuItemV("Apply Edit Log...", "", false, img != nil) {
+		response = types.MenuResponseToolsApplyEditLog
+	}
+	if imgui.MenuItem("Merge...") {
+		response = types.MenuResponseToolsMerge
+	}
+	if imgui.MenuItemV("Track Texel Provenance", "", trackingProvenance, true) {
+		response = types.MenuResponseToolsProvenance
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Sample Ramp...", "", false, img != nil) {
+		response = types.MenuResponseToolsSampleRamp
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Link Pattern LUT...", "", false, img != nil) {
+		response = types.MenuResponseToolsLinkLUTs
+	}
+	if imgui.MenuItemV("Unlink LUTs", "", false, lutsLinked) {
+		response = types.MenuResponseToolsUnlinkLUTs
+	}
+	imgui.Separator()
+	if imgui.BeginMenu("File Associations") {
+		if imgui.MenuItem("Add to Open With") {
+			response = types.MenuResponseToolsRegisterFileTypes
+		}
+		if imgui.MenuItem("Open DDS/EXR Files by Default") {
+			response = types.MenuResponseToolsRegisterDefault
+		}
+		if imgui.MenuItem("Remove") {
+			response = types.MenuResponseToolsUnregisterFileTypes
+		}
+		imgui.EndMenu()
+	}
+	return response
+}
+
+func showViewMenu(browserVisible, bytesVisible, channelsVisible, consoleVisible, memoryVisible, historyVisible, rowFilterVisible, colorVisible, fileInfoVisible, graphVisible, gridVisible, smoothZoom, toolsVisible, trackpadMode, colorManaged bool, displayProfile string, presetsVisible, projectVisible, layersVisible, labelsVisible, tooltipsVisible, usageVisible, lockedVisible, encodeSRGB, violationsVisible, groupsVisible bool, hiddenGroups map[int]bool, schemas []help.Schema, size image.Point, schema *help.Schema) (response types.MenuResponse, index int) {
+	response = types.MenuResponseNone
+	if imgui.MenuItemV("Browser", "", browserVisible, true) {
+		response = types.MenuResponseViewBrowser
+	}
+	if imgui.MenuItemV("Bytes", "", bytesVisible, true) {
+		response = types.MenuResponseViewBytes
+	}
 	if imgui.MenuItemV("Channels", "", channelsVisible, true) {
 		response = types.MenuResponseViewChannels
 	}
 	if imgui.MenuItemV("Color", "", colorVisible, true) {
 		response = types.MenuResponseViewColor
 	}
+	if imgui.MenuItemV("Console", "", consoleVisible, true) {
+		response = types.MenuResponseViewConsole
+	}
+	if imgui.MenuItemV("Memory Usage", "", memoryVisible, true) {
+		response = types.MenuResponseViewMemory
+	}
+	if imgui.MenuItemV("History", "", historyVisible, true) {
+		response = types.MenuResponseViewHistory
+	}
+	if imgui.MenuItemV("File Info", "", fileInfoVisible, true) {
+		response = types.MenuResponseViewFileInfo
+	}
+	if imgui.MenuItemV("Graph", "", graphVisible, true) {
+		response = types.MenuResponseViewGraph
+	}
 	if imgui.MenuItemV("Grid", "", gridVisible, true) {
 		response = types.MenuResponseViewGrid
 	}
+	if imgui.MenuItemV("Smooth Zoomed Out View", "", smoothZoom, true) {
+		response = types.MenuResponseViewSmoothZoom
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Average texels together when zoomed out, instead of showing every few")
+	}
+	if imgui.MenuItemV("Locked Texels", "", lockedVisible, true) {
+		response = types.MenuResponseViewLockedTexels
+	}
+	if imgui.MenuItemV("Presets", "", presetsVisible, true) {
+		response = types.MenuResponseViewPresets
+	}
+	if imgui.MenuItemV("Project", "", projectVisible, true) {
+		response = types.MenuResponseViewProject
+	}
+	if imgui.MenuItemV("Layers", "", layersVisible, true) {
+		response = types.MenuResponseViewLayers
+	}
+	if imgui.MenuItemV("Row and Column Labels", "", labelsVisible, true) {
+		response = types.MenuResponseViewTexelLabels
+	}
+	if imgui.MenuItemV("Texel Tooltips", "", tooltipsVisible, true) {
+		response = types.MenuResponseViewTexelTooltips
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Describe the hovered texel with the schema's names and valid values")
+	}
+	if imgui.MenuItemV("Pattern ID Usage", "", usageVisible, true) {
+		response = types.MenuResponseViewPatternUsage
+	}
+	if imgui.MenuItemV("Row Filter", "", rowFilterVisible, true) {
+		response = types.MenuResponseViewRowFilter
+	}
+	if imgui.MenuItemV("Schema Violations", "", violationsVisible, true) {
+		response = types.MenuResponseViewSchemaViolations
+	}
 	if imgui.MenuItemV("Tools", "", toolsVisible, true) {
 		response = types.MenuResponseViewTools
 	}
+	imgui.Separator()
+	if imgui.BeginMenuV("Schema", size != image.Point{}) {
+		if chosen, ok := showSchemaMenu(schemas, size, schema); ok {
+			response, index = types.MenuResponseViewSchema, chosen
+		}
+		imgui.EndMenu()
+	}
+	if imgui.BeginMenuV("Column Groups", schema != nil && len(schema.Groups) > 0) {
+		if resp, group := showColumnGroupsMenu(groupsVisible, schema.Groups, hiddenGroups); resp != types.MenuResponseNone {
+			response, index = resp, group
+		}
+		imgui.EndMenu()
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Trackpad Gestures", "", trackpadMode, true) {
+		response = types.MenuResponseViewTrackpad
+	}
+	if imgui.MenuItemV("Encode Values as sRGB", "g", encodeSRGB, true) {
+		response = types.MenuResponseViewEncodeSRGB
+	}
+	if imgui.MenuItemV("Color Management", "", colorManaged, true) {
+		response = types.MenuResponseViewColorManaged
+	}
+	if imgui.BeginMenuV("Display Profile", colorManaged) {
+		if imgui.MenuItemV("sRGB (No Profile)", "", displayProfile == "", true) {
+			response = types.MenuResponseViewDisplayProfileNone
+		}
+		if imgui.MenuItem("Detect Monitor Profile") {
+			response = types.MenuResponseViewDisplayProfileDetect
+		}
+		if imgui.MenuItem("Choose Profile...") {
+			response = types.MenuResponseViewDisplayProfileChoose
+		}
+		if displayProfile != "" {
+			imgui.Separator()
+			textDisabled(fmt.Sprintf("Using: %s", displayProfile))
+		}
+		imgui.EndMenu()
+	}
+	return
+}
+
+func showHelpMenu(schemaEditVisible bool) types.MenuResponse {
+	response := types.MenuResponseNone
+	if imgui.MenuItemV("Schema Editor", "", schemaEditVisible, true) {
+		response = types.MenuResponseHelpSchemaEditor
+	}
+	if imgui.MenuItem("Update Schemas...") {
+		response = types.MenuResponseHelpUpdateSchemas
+	}
 	return response
 }
 
 func main() {
-	opengl.Run(run)
+	logFile, err := os.OpenFile("lut-editor.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logFile = os.Stderr
+	} else {
+		defer logFile.Close()
+	}
+
+	prt := app.NewPrinter(
+		supportscolor.SupportsColor(logFile.Fd(), supportscolor.SniffFlagsOption(true)).SupportsColor,
+		logFile,
+		logFile,
+	)
+
+	parser := argparse.NewParser(
+		"lut_editor",
+		"An HDR pixel editor, made for editing Helldivers 2 material LUTs in floating point image formats",
+		&argparse.ParserConfig{
+			DisableDefaultShowHelp: true,
+		},
+	)
+	imagePaths := parser.Strings("p", "path", &argparse.Option{
+		Positional: true,
+		Help:       "Paths or http(s) URLs of EXR or HDR DDS images to load, each opened in its own tab",
+		Required:   false,
+	})
+	registerTypes := parser.Flag("", "register-file-types", &argparse.Option{
+		Help: "Add the editor to the Open With menu for .dds and .exr files (Windows only), then exit",
+	})
+	registerDefault := parser.Flag("", "default", &argparse.Option{
+		Help: "With --register-file-types, also open .dds and .exr files with the editor when double clicked",
+	})
+	unregisterTypes := parser.Flag("", "unregister-file-types", &argparse.Option{
+		Help: "Remove the file type registrations added by --register-file-types, then exit",
+	})
+	printInfoFlag := parser.Flag("", "info", &argparse.Option{
+		Help: "Print the header fields and content hash of each path, then exit",
+	})
+	runScript := parser.String("", "run", &argparse.Option{
+		Help: "Run the console commands in a script file without opening a window, once for each path if any are given, then exit",
+	})
+	singleInstance := parser.Flag("s", "single-instance", &argparse.Option{
+		Help: "Open the given paths as new tabs in an editor that is already running, if there is one",
+	})
+
+	convertCommand := parser.AddCommand("convert", "Convert EXR and DDS images without opening a window", nil)
+	convertInputs := convertCommand.Strings("i", "input", &argparse.Option{
+		Positional: true,
+		Required:   true,
+		Help:       "Paths or glob patterns of the EXR or DDS images to convert",
+	})
+	convertOutput := convertCommand.String("o", "output", &argparse.Option{
+		Help: "The .dds or .exr file to write a single input to, or the folder to write each converted file to. Files are written next to their inputs by default",
+	})
+	convertTo := convertCommand.String("t", "to", &argparse.Option{
+		Choices: []interface{}{"dds", "exr"},
+		Help:    "File type to convert to. By default it is the type of a .dds or .exr output, otherwise EXR converts to DDS and DDS to EXR",
+	})
+	convertFormat := convertCommand.String("f", "format", &argparse.Option{
+		Default: "keep",
+		Choices: []interface{}{"keep", "float", "half"},
+		Help:    "Pixel format to convert to: keep the input's, 32-bit float or 16-bit half",
+	})
+	convertMipMaps := convertCommand.String("m", "mipmaps", &argparse.Option{
+		Choices: []interface{}{"box", "kaiser"},
+		Help:    "Write DDS files with a full mip chain, made with a box or Kaiser filter",
+	})
+	convertName := convertCommand.String("n", "name", &argparse.Option{
+		Default: "{name}.{ext}",
+		Help:    "Names the converted files from the tokens {name}, {inext}, {ext} and {parentdir}, unless the output is a single file",
+	})
+
+	if err = parser.Parse(nil); err != nil {
+		if err == argparse.BreakAfterHelpError {
+			os.Exit(0)
+		}
+		prt.Fatalf("%v", err)
+	}
+
+	if convertCommand.Invoked {
+		conversion := defaultConversionSettings()
+		conversion.Precision = int(precisionNames[*convertFormat])
+		if filter, ok := mipFilterNames[*convertMipMaps]; ok {
+			conversion.MipMaps, conversion.MipFilter = true, int(filter)
+		}
+		err := runConvert(convertOptions{
+			Inputs:       *convertInputs,
+			Output:       *convertOutput,
+			To:           *convertTo,
+			Conversion:   conversion,
+			NameTemplate: *convertName,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *registerTypes || *unregisterTypes {
+		if *registerTypes {
+			err = registerFileTypes(*registerDefault)
+		} else {
+			err = shell.UnregisterFileTypes()
+		}
+		if err != nil {
+			prt.Fatalf("%v", err)
+		}
+		os.Exit(0)
+	}
+
+	if *printInfoFlag {
+		if err := printInfo(*imagePaths); err != nil {
+			prt.Fatalf("%v", err)
+		}
+		os.Exit(0)
+	}
+
+	if *runScript != "" {
+		// Failures go to standard error rather than the log, for CI jobs
+		if err := runHeadless(*runScript, *imagePaths); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	opengl.Run(func() {
+		run(prt, *imagePaths, *singleInstance)
+	})
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// memoryUsage is the memory held by a document, in bytes.
+type memoryUsage struct {
+	image     int
+	undo      int
+	snapshots int
+	// floating is the pasted or moved pixels not yet put down
+	floating int
+	sprites  int
+	// mips is the DDS mipmaps and images after the first
+	mips int
+}
+
+func (m memoryUsage) total() int {
+	return m.image + m.undo + m.snapshots + m.floating + m.sprites + m.mips
+}
+
+func (m memoryUsage) add(other memoryUsage) memoryUsage {
+	return memoryUsage{
+		image:     m.image + other.image,
+		undo:      m.undo + other.undo,
+		snapshots: m.snapshots + other.snapshots,
+		floating:  m.floating + other.floating,
+		sprites:   m.sprites + other.sprites,
+		mips:      m.mips + other.mips,
+	}
+}
+
+// pictureBytes returns the memory used by a sprite's picture, or 0 if there
+// is none.
+func pictureBytes(pic *pixel.PictureData) int {
+	if pic == nil {
+		return 0
+	}
+	return len(pic.Pix) * 4
+}
+
+func (d *document) memoryUsage() memoryUsage {
+	var m memoryUsage
+	if d.img != nil {
+		m.image = hdrColors.PixelBytes(d.img)
+		if ddsImg, ok := d.img.(*dds.DDS); ok {
+			m.mips = ddsImg.ExtraBytes()
+		}
+	}
+	m.undo = d.undoStack.Bytes()
+	for _, snapshot := range d.snapshots {
+		if snapshot != nil {
+			m.snapshots += snapshot.Bytes()
+		}
+	}
+	if d.pasteImg != nil {
+		m.floating = hdrColors.PixelBytes(d.pasteImg)
+	}
+	m.sprites = pictureBytes(d.pic) + pictureBytes(d.pastePic) + d.pyramid.bytes()
+	return m
+}
+
+// formatBytes writes n bytes in the largest unit it is at least one of.
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	v, suffix := float64(n)/unit, "KiB"
+	for _, next := range []string{"MiB", "GiB"} {
+		if v < unit {
+			break
+		}
+		v, suffix = v/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", v, suffix)
+}
+
+// drawMemoryWindow shows the memory held by each open document, with buttons
+// to free what can be done without: old undo states, keeping the newest
+// keepUndo, and DDS mipmaps that are never saved.
+func drawMemoryWindow(docs []*document, keepUndo *int32, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 640, Y: 260}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Memory", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	columns := []string{"Document", "Image", "Undo", "Snapshots", "Floating", "Sprites", "Mipmaps", ""}
+	tableFlags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable
+	if imgui.BeginTableV("MemoryTable", len(columns), tableFlags, imgui.Vec2{}, 0) {
+		for _, name := range columns {
+			imgui.TableSetupColumn(name)
+		}
+		imgui.TableHeadersRow()
+		var total memoryUsage
+		for i, doc := range docs {
+			m := doc.memoryUsage()
+			total = total.add(m)
+			imgui.PushIDInt(i)
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(doc.displayName())
+			for _, n := range []int{m.image, m.undo, m.snapshots, m.floating, m.sprites, m.mips} {
+				imgui.TableNextColumn()
+				imgui.Text(formatBytes(n))
+			}
+			imgui.TableNextColumn()
+			if len(doc.undoStack.UndoStack) > int(*keepUndo) && imgui.Button("Trim Undo") {
+				doc.undoStack.Trim(int(*keepUndo))
+			}
+			if ddsImg, ok := doc.img.(*dds.DDS); ok && m.mips > 0 {
+				imgui.SameLine()
+				if imgui.Button("Drop Mipmaps") {
+					ddsImg.DropExtra()
+				}
+			}
+			imgui.PopID()
+		}
+		imgui.TableNextRow()
+		imgui.TableNextColumn()
+		imgui.Text("Total")
+		for _, n := range []int{total.image, total.undo, total.snapshots, total.floating, total.sprites, total.mips} {
+			imgui.TableNextColumn()
+			imgui.Text(formatBytes(n))
+		}
+		imgui.EndTable()
+	}
+
+	imgui.InputInt("Undo states to keep", keepUndo)
+	*keepUndo = max(*keepUndo, 1)
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	imgui.Text(fmt.Sprintf("Go heap: %s in use, %s from the system", formatBytes(int(stats.HeapAlloc)), formatBytes(int(stats.Sys))))
+	if imgui.Button("Free Memory") {
+		debug.FreeOSMemory()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Return memory that is no longer used to the system")
+	}
+}
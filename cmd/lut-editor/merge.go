@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/changes"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/merge"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/sqweek/dialog"
+)
+
+// mergeTitles are the titles of the dialogs choosing the images to merge, in
+// the order of mergeSession.paths.
+var mergeTitles = [3]string{"Merge: select the base image", "Merge: select their image", "Merge: select your image"}
+
+// mergeSession is a three-way merge waiting for its conflicts to be resolved.
+type mergeSession struct {
+	// paths are the base, their and my image
+	paths [3]string
+	// img is the merged image, with mine's values where they conflict
+	img image.Image
+	// attrs are my image's EXR attributes, kept for the merged image
+	attrs  []openexr.Attribute
+	result *merge.Result
+}
+
+// loadMerge asks for the base, their and my image, merges them and sends the
+// merge to the main loop for its conflicts to be resolved.
+func loadMerge(prt *app.Printer, results chan<- *mergeSession) {
+	var session mergeSession
+	var images [3]hdrColors.RawImage
+	var mine image.Image
+	for i, title := range mergeTitles {
+		path, err := dialog.File().Title(title).Filter("DDS or EXR files", "dds", "exr").Load()
+		if err == dialog.ErrCancelled {
+			return
+		} else if err != nil {
+			prt.Errorf("%v", err)
+			return
+		}
+		img, attrs, err := loadImage(path)
+		if err != nil {
+			prt.Errorf("merge: failed to read %s: %v", path, err)
+			return
+		}
+		raw, ok := rawImage(img)
+		if !ok {
+			prt.Errorf("merge: %s is not an HDR image", path)
+			return
+		}
+		session.paths[i], images[i], mine, session.attrs = path, raw, img, attrs
+	}
+	session.img = editor.Copy(mine, mine.Bounds())
+	out, ok := rawImage(session.img)
+	if !ok {
+		prt.Errorf("merge: %s is not an HDR image", session.paths[2])
+		return
+	}
+	result, err := merge.Merge(out, images[0], images[1], images[2])
+	if err != nil {
+		prt.Errorf("merge: %v", err)
+		return
+	}
+	session.result = result
+	results <- &session
+}
+
+// summary describes what the merge took from each image.
+func (m *mergeSession) summary() string {
+	r := m.result
+	return fmt.Sprintf("%d texels changed only in theirs, %d only in mine, %d in both without conflict, %d conflicts", r.FromTheirs, r.FromMine, r.Both, len(r.Conflicts))
+}
+
+// resolved returns the merged image with each conflict resolved as chosen.
+func (m *mergeSession) resolved() image.Image {
+	img := editor.Copy(m.img, m.img.Bounds())
+	if raw, ok := rawImage(img); ok {
+		merge.Resolve(raw, m.result.Conflicts)
+	}
+	return img
+}
+
+// drawMergeWindow lists the conflicts of the merge, choosing for each which
+// image's values are kept, and returns the merged image once Open Merged
+// Image is pressed.
+func drawMergeWindow(m *mergeSession, names changes.Names, visible *bool) image.Image {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 640, Y: 480}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Merge", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	for i, label := range []string{"Base", "Theirs", "Mine"} {
+		imgui.Text(fmt.Sprintf("%s: %s", label, filepath.Base(m.paths[i])))
+	}
+	imgui.Text(m.summary())
+	var merged image.Image
+	if imgui.Button("Open Merged Image") {
+		merged = m.resolved()
+		*visible = false
+	}
+	if len(m.result.Conflicts) == 0 {
+		return merged
+	}
+	for _, choice := range merge.Choices {
+		imgui.SameLine()
+		if imgui.Button(fmt.Sprintf("All %s", choice)) {
+			for i := range m.result.Conflicts {
+				m.result.Conflicts[i].Choice = choice
+			}
+		}
+	}
+
+	tableFlags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable | imgui.TableFlagsScrollY
+	if imgui.BeginTableV("MergeTable", 5, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("Texel")
+		for _, choice := range merge.Choices {
+			imgui.TableSetupColumn(choice.String())
+		}
+		imgui.TableSetupColumn("Channels")
+		imgui.TableHeadersRow()
+		for i := range m.result.Conflicts {
+			c := &m.result.Conflicts[i]
+			imgui.PushIDInt(i)
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.Text(fmt.Sprintf("Row %s, column %s", names.Row(c.Y), names.Column(c.X)))
+			for _, choice := range merge.Choices {
+				imgui.TableNextColumn()
+				if imgui.RadioButton(fmt.Sprintf("%s##%s", formatTexel(c.Version(choice)), choice), c.Choice == choice) {
+					c.Choice = choice
+				}
+			}
+			imgui.TableNextColumn()
+			channels := make([]string, len(c.Channels))
+			for j, ch := range c.Channels {
+				channels[j] = string("RGBA"[ch])
+			}
+			imgui.Text(strings.Join(channels, ", "))
+			imgui.PopID()
+		}
+		imgui.EndTable()
+	}
+	return merged
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/baselines"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+)
+
+// metadataNames are the string attributes suggested in the File Info window
+// for recording where a modded texture came from.
+var metadataNames = []string{"author", "modName", "gameVersion", "baselineHash"}
+
+// metadataInput is the attribute being added in the File Info window.
+type metadataInput struct {
+	name, value string
+}
+
+// drawMetadata lets the string attributes of doc be edited, removed and
+// added. Any change marks doc unsaved.
+func drawMetadata(prt *app.Printer, doc *document, input *metadataInput) {
+	imgui.Text("Metadata (saved in EXR files)")
+	remove := ""
+	for i, attr := range doc.attributes {
+		if attr.Type != "string" {
+			continue
+		}
+		imgui.PushIDInt(i)
+		value := string(attr.Data)
+		if imgui.InputText(attr.Name, &value) {
+			doc.attributes[i] = openexr.StringAttribute(attr.Name, value)
+			doc.saved = false
+		}
+		imgui.SameLine()
+		if imgui.Button("Remove") {
+			remove = attr.Name
+		}
+		imgui.PopID()
+	}
+	if remove != "" {
+		doc.attributes = openexr.RemoveAttribute(doc.attributes, remove)
+		doc.saved = false
+	}
+
+	imgui.InputTextWithHintV("Name##metadata", "attribute name", &input.name, 0, nil)
+	imgui.InputTextWithHintV("Value##metadata", "text", &input.value, 0, nil)
+	for _, name := range metadataNames {
+		if openexr.FindAttribute(doc.attributes, name) != nil {
+			continue
+		}
+		if imgui.Button(name) {
+			input.name = name
+		}
+		imgui.SameLine()
+	}
+	if doc.baseline != "" {
+		if imgui.Button("Hash Baseline") {
+			if hash, err := baselines.FileHash(doc.baseline); err != nil {
+				prt.Errorf("Hashing baseline '%s': %v", doc.baseline, err)
+			} else {
+				input.name, input.value = "baselineHash", hash
+			}
+		}
+		imgui.SameLine()
+	}
+	imgui.Spacing()
+	name := strings.TrimSpace(input.name)
+	if imgui.Button("Add Attribute") && name != "" {
+		if attr := openexr.FindAttribute(doc.attributes, name); attr != nil && attr.Type != "string" {
+			prt.Errorf("Attribute '%s' is already used for %s data", name, attr.Type)
+		} else {
+			doc.attributes = openexr.SetAttribute(doc.attributes, openexr.StringAttribute(name, input.value))
+			doc.saved = false
+			*input = metadataInput{}
+		}
+	}
+}
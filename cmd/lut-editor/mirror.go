@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// mirrorMode chooses the axes drawing is mirrored across.
+type mirrorMode int
+
+const (
+	mirrorOff        mirrorMode = 0
+	mirrorLeftRight  mirrorMode = 1
+	mirrorTopBottom  mirrorMode = 2
+	mirrorFourWay    mirrorMode = 3
+	mirrorModesCount            = 4
+)
+
+var mirrorModeNames = []string{"Off", "Left-Right", "Top-Bottom", "Both"}
+
+func (m mirrorMode) String() string {
+	return mirrorModeNames[m]
+}
+
+// mirrorPoints returns p and its reflections across the center lines of
+// area, in image coordinates. Reflections that land on p itself are left out.
+func mirrorPoints(p image.Point, area image.Rectangle, mode mirrorMode) []image.Point {
+	points := []image.Point{p}
+	if mode == mirrorLeftRight || mode == mirrorFourWay {
+		for _, q := range points {
+			if r := image.Pt(area.Min.X+area.Max.X-1-q.X, q.Y); r != q {
+				points = append(points, r)
+			}
+		}
+	}
+	if mode == mirrorTopBottom || mode == mirrorFourWay {
+		for _, q := range points {
+			if r := image.Pt(q.X, area.Min.Y+area.Max.Y-1-q.Y); r != q {
+				points = append(points, r)
+			}
+		}
+	}
+	return points
+}
+
+// mirrorArea is the area drawing is mirrored within: the selection, or the
+// whole image.
+func (d *document) mirrorArea() image.Rectangle {
+	rect, _ := d.editRect()
+	return rect
+}
+
+// drawMirrorAxes draws the center lines of area that drawing is mirrored
+// across.
+func drawMirrorAxes(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, height int, area image.Rectangle, mode mirrorMode) {
+	if mode == mirrorOff {
+		return
+	}
+	axes := imdraw.New(nil)
+	axes.Color = pixel.RGBA{R: 0.2, G: 0.8, B: 1, A: 0.8}
+	bottom, top := float64(height-area.Max.Y)-spriteCenter.Y, float64(height-area.Min.Y)-spriteCenter.Y
+	left, right := float64(area.Min.X)-spriteCenter.X, float64(area.Max.X)-spriteCenter.X
+	if mode == mirrorLeftRight || mode == mirrorFourWay {
+		x := (left + right) / 2
+		axes.Push(pixel.V(x, bottom), pixel.V(x, top))
+		axes.Line(2 / camZoom)
+	}
+	if mode == mirrorTopBottom || mode == mirrorFourWay {
+		y := (bottom + top) / 2
+		axes.Push(pixel.V(left, y), pixel.V(right, y))
+		axes.Line(2 / camZoom)
+	}
+	axes.Draw(win)
+}
+
+// drawMirrorCombo adds the mirror choice to the Tool window.
+func drawMirrorCombo(mode *mirrorMode) {
+	if imgui.BeginCombo("Mirror", mode.String()) {
+		for m := mirrorMode(0); m < mirrorModesCount; m++ {
+			if imgui.SelectableV(m.String(), m == *mode, 0, imgui.Vec2{}) {
+				*mode = m
+			}
+		}
+		imgui.EndCombo()
+	}
+}
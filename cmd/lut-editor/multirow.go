@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/ryanjsims/hd2-lut-editor/filter"
+)
+
+// setMultiRows adds the rows of the selection to the rows edited together, or
+// takes them out if add is false.
+func (d *document) setMultiRows(add bool) error {
+	if d.selection == pixel.ZR || d.pasteImg != nil {
+		return fmt.Errorf("nothing is selected")
+	}
+	rect, _ := d.editRect()
+	rect = rect.Intersect(d.img.Bounds())
+	if d.multiRows == nil {
+		d.multiRows = make(map[int]bool)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if add {
+			d.multiRows[y] = true
+		} else {
+			delete(d.multiRows, y)
+		}
+	}
+	return nil
+}
+
+// multiRowList returns the rows edited together, top to bottom.
+func (d *document) multiRowList() []int {
+	rows := make([]int, 0, len(d.multiRows))
+	for y := range d.multiRows {
+		if d.img != nil && y < d.img.Bounds().Dy() {
+			rows = append(rows, y)
+		}
+	}
+	slices.Sort(rows)
+	return rows
+}
+
+// multiRowRects returns rect, and if every row it covers is edited together,
+// the same columns of each of the other rows edited together. Each rectangle
+// covers a single row then.
+func (d *document) multiRowRects(rect image.Rectangle) []image.Rectangle {
+	rows := d.multiRowList()
+	if len(rows) < 2 || rect.Empty() {
+		return []image.Rectangle{rect}
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if !d.multiRows[y] {
+			return []image.Rectangle{rect}
+		}
+	}
+	rects := make([]image.Rectangle, len(rows))
+	for i, y := range rows {
+		rects[i] = image.Rect(rect.Min.X, y, rect.Max.X, y+1)
+	}
+	return rects
+}
+
+// editRects is editRect spread over the rows edited together. The second
+// result names the area for dialogs.
+func (d *document) editRects() ([]image.Rectangle, string) {
+	rect, target := d.editRect()
+	rects := d.multiRowRects(rect)
+	if len(rects) > 1 {
+		target = fmt.Sprintf("%s in %d rows", target, len(rects))
+	}
+	return rects, target
+}
+
+// multiRowPoints adds to points the same columns of each of the rows edited
+// together, for those points in one of them.
+func (d *document) multiRowPoints(points []image.Point) []image.Point {
+	rows := d.multiRowList()
+	if len(rows) < 2 {
+		return points
+	}
+	seen := make(map[image.Point]bool, len(points))
+	result := make([]image.Point, 0, len(points)*len(rows))
+	add := func(p image.Point) {
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	for _, p := range points {
+		add(p)
+		if !d.multiRows[p.Y] {
+			continue
+		}
+		for _, y := range rows {
+			add(image.Pt(p.X, y))
+		}
+	}
+	return result
+}
+
+// featherEachEdit is featherEdits for an edit to each of rects. Each is faded
+// out towards its own edges.
+func (d *document) featherEachEdit(rects []image.Rectangle, radius int) (apply func()) {
+	if len(rects) < 2 {
+		return d.featherEdits(radius)
+	}
+	raw, ok := rawImage(d.img)
+	if !ok || radius <= 0 || d.selection == pixel.ZR || d.pasteImg != nil {
+		return func() {}
+	}
+	feathers := make([]func(), len(rects))
+	for i, rect := range rects {
+		feathers[i] = filter.Feather(raw, rect, radius)
+	}
+	return func() {
+		for _, feather := range feathers {
+			feather()
+		}
+	}
+}
+
+// drawMultiRows outlines the rows edited together.
+func drawMultiRows(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, width, height int, rows []int) {
+	if len(rows) == 0 {
+		return
+	}
+	outline := imdraw.New(nil)
+	outline.Color = pixel.RGBA{R: 1, G: 0.6, B: 0.1, A: 0.9}
+	for _, y := range rows {
+		rect := imageRectToSelection(image.Rect(0, y, width, y+1), spriteCenter, height)
+		outline.Push(rect.Min, rect.Max)
+		outline.Rectangle(1.5 / camZoom)
+	}
+	outline.Draw(win)
+}
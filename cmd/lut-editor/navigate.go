@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// goToSettings is the texel Edit > Go To jumps to.
+type goToSettings struct {
+	x, y int32
+}
+
+// findSettings describe the values Edit > Find looks for in one channel:
+// within tolerance of value, or from min to max inclusive.
+type findSettings struct {
+	channel   int
+	useRange  bool
+	value     float32
+	tolerance float32
+	min, max  float32
+}
+
+func defaultFindSettings() findSettings {
+	return findSettings{tolerance: 0.001, max: 1}
+}
+
+func (s findSettings) matches(v float64) bool {
+	if s.useRange {
+		return v >= float64(s.min) && v <= float64(s.max)
+	}
+	return math.Abs(v-float64(s.value)) <= float64(s.tolerance)
+}
+
+func (s findSettings) String() string {
+	if s.useRange {
+		return fmt.Sprintf("%s from %g to %g", graphChannelNames[s.channel], s.min, s.max)
+	}
+	return fmt.Sprintf("%s of %g", graphChannelNames[s.channel], s.value)
+}
+
+// goTo centers the camera on texel p and selects it.
+func (d *document) goTo(p image.Point) {
+	center := d.sprite.Frame().Center()
+	height := d.img.Bounds().Dy()
+	d.camPos = pixel.V(float64(p.X)+0.5, float64(height-p.Y)-0.5).Sub(center)
+	d.selection = imageRectToSelection(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))}, center, height)
+}
+
+// findNext returns the first texel after the top left of the selection, in
+// row order and wrapping around, whose value matches settings. Without a
+// selection the search starts at the first texel.
+func (d *document) findNext(settings findSettings) (image.Point, bool) {
+	raw, ok := rawImage(d.img)
+	if !ok {
+		return image.Point{}, false
+	}
+	bounds := raw.Bounds()
+	width, count := bounds.Dx(), bounds.Dx()*bounds.Dy()
+	start := 0
+	if d.selection != pixel.ZR && d.pasteImg == nil {
+		rect, _ := d.editRect()
+		start = (rect.Min.Y-bounds.Min.Y)*width + rect.Min.X - bounds.Min.X + 1
+	}
+	for i := 0; i < count; i++ {
+		index := (start + i) % count
+		p := image.Pt(bounds.Min.X+index%width, bounds.Min.Y+index/width)
+		if settings.matches(raw.RawAt(p.X, p.Y)[settings.channel]) {
+			return p, true
+		}
+	}
+	return image.Point{}, false
+}
+
+func goToPrompt(settings *goToSettings, size image.Point, rowNames, columnNames []string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = goToDialog(settings, size, rowNames, columnNames, windowSize, &responded)
+	return responded
+}
+
+func goToDialog(settings *goToSettings, size image.Point, rowNames, columnNames []string, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Go to", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.InputInt("X", &settings.x)
+	imgui.InputInt("Y", &settings.y)
+	settings.x = min(max(settings.x, 0), int32(size.X-1))
+	settings.y = min(max(settings.y, 0), int32(size.Y-1))
+	drawNameCombo("Row", rowNames, size.Y, &settings.y)
+	drawNameCombo("Column", columnNames, size.X, &settings.x)
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Go", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
+
+// drawNameCombo picks one of count rows or columns by name. Unnamed ones are
+// listed by index.
+func drawNameCombo(label string, names []string, count int, index *int32) {
+	name := func(i int) string {
+		if i < len(names) && names[i] != "" {
+			return fmt.Sprintf("%d: %s", i, names[i])
+		}
+		return fmt.Sprintf("%s %d", label, i)
+	}
+	if imgui.BeginCombo(label, name(int(*index))) {
+		for i := 0; i < count; i++ {
+			if imgui.SelectableV(name(i), int32(i) == *index, 0, imgui.Vec2{}) {
+				*index = int32(i)
+			}
+		}
+		imgui.EndCombo()
+	}
+}
+
+func findPrompt(settings *findSettings, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = findDialog(settings, windowSize, &responded)
+	return responded
+}
+
+func findDialog(settings *findSettings, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Find value", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text("Channel")
+	for i, name := range graphChannelNames {
+		imgui.SameLine()
+		imgui.RadioButtonInt(name+"##find", &settings.channel, i)
+	}
+	imgui.Checkbox("Range", &settings.useRange)
+	if settings.useRange {
+		imgui.DragFloatV("Min", &settings.min, 0.01, 0.0, 0.0, "%.4f", imgui.SliderFlagsNone)
+		imgui.DragFloatV("Max", &settings.max, 0.01, 0.0, 0.0, "%.4f", imgui.SliderFlagsNone)
+	} else {
+		imgui.DragFloatV("Value", &settings.value, 0.01, 0.0, 0.0, "%.4f", imgui.SliderFlagsNone)
+		imgui.DragFloatV("Tolerance", &settings.tolerance, 0.0001, 0.0, 0.0, "%.5f", imgui.SliderFlagsNone)
+		settings.tolerance = max(settings.tolerance, 0)
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Find Next", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/sqweek/dialog"
+)
+
+// openAsSettings describe how File > Open As... reads a file whose header is
+// wrong or missing. format indexes dds.OverrideFormats.
+type openAsSettings struct {
+	path          string
+	format        int32
+	width, height int32
+}
+
+func defaultOpenAsSettings() openAsSettings {
+	return openAsSettings{width: 23, height: 8}
+}
+
+// withFile returns settings for opening path, taking the size and format from
+// its DDS header where it has a usable one.
+func (s openAsSettings) withFile(path string) openAsSettings {
+	s.path = path
+	f, err := os.Open(path)
+	if err != nil {
+		return s
+	}
+	defer f.Close()
+	hdr, err := dds.DecodeHeader(f)
+	if err != nil {
+		return s
+	}
+	if hdr.Width > 0 && hdr.Height > 0 {
+		s.width, s.height = int32(hdr.Width), int32(hdr.Height)
+	}
+	if hdr.PixelFormat.FourCC != [4]byte{'D', 'X', '1', '0'} {
+		return s
+	}
+	if dx10, err := dds.DecodeDXT10Header(f); err == nil {
+		for i, format := range dds.OverrideFormats {
+			if format == dx10.DXGIFormat {
+				s.format = int32(i)
+			}
+		}
+	}
+	return s
+}
+
+func chooseOpenAsFile(prt *app.Printer, paths chan<- string) {
+	path, err := dialog.File().Title("Open As").Filter("DDS files", "dds").Filter("All files", "*").Load()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	paths <- path
+}
+
+// openPathAs opens the file in settings as raw texture data in a new tab. The
+// document is not associated with the file, so saving asks for a location
+// rather than overwriting the original data.
+func openPathAs(prt *app.Printer, settings openAsSettings, openedDocs chan<- *document, currColor [4]float32) {
+	f, err := os.Open(settings.path)
+	if err != nil {
+		prt.Errorf("Failed to open '%s': %v", settings.path, err)
+		return
+	}
+	defer f.Close()
+	format := dds.OverrideFormats[settings.format]
+	img, err := dds.DecodeAs(f, format, int(settings.width), int(settings.height))
+	if err != nil {
+		prt.Errorf("Failed to load '%s' as %v: %v", settings.path, format, err)
+		return
+	}
+	prt.Infof("Opened '%s' as %dx%d %v", settings.path, settings.width, settings.height, format)
+	newDoc := newDocument("(new)", img, true)
+	newDoc.title = filepath.Base(settings.path)
+	newDoc.undoStack.Push("Load File", newDoc.fileName, true, newDoc.img, currColor, newDoc.selection)
+	openedDocs <- newDoc
+}
+
+func openAsPrompt(settings *openAsSettings, browse *bool, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.4 * viewport.Size().X,
+		Y: 0.3 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = openAsDialog(settings, browse, windowSize, &responded)
+	return responded
+}
+
+func openAsDialog(settings *openAsSettings, browse *bool, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Open As", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.InputText("File", &settings.path)
+	imgui.SameLine()
+	*browse = imgui.Button("Browse...")
+	if imgui.BeginCombo("Format", dds.OverrideFormats[settings.format].String()) {
+		for i, format := range dds.OverrideFormats {
+			if imgui.SelectableV(format.String(), int32(i) == settings.format, 0, imgui.Vec2{}) {
+				settings.format = int32(i)
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.InputInt("Width", &settings.width)
+	imgui.InputInt("Height", &settings.height)
+	settings.width = max(settings.width, 1)
+	settings.height = max(settings.height, 1)
+	imgui.Text("A DDS header is skipped, other files are read from the start.")
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Open", buttonSize) && settings.path != "" {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/backup"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/sqweek/dialog"
+)
+
+// backupFolder returns the folder originals are kept in.
+func backupFolder() (string, error) {
+	cfg, err := backup.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	return cfg.Folder()
+}
+
+// stashOriginal keeps a copy of fileName in the backup folder before it is
+// overwritten for the first time. Saving should not go ahead if it fails.
+func stashOriginal(prt *app.Printer, fileName string) error {
+	dir, err := backupFolder()
+	if err != nil {
+		return fmt.Errorf("failed to find backup folder: %v", err)
+	}
+	stashed, err := backup.Stash(dir, fileName)
+	if err != nil {
+		return fmt.Errorf("failed to back up the original of '%s': %v", fileName, err)
+	}
+	if stashed {
+		prt.Infof("Kept the original of '%s' in '%s'", fileName, dir)
+	}
+	return nil
+}
+
+// restoreOriginal copies the kept original of fileName back over it, and
+// returns it loaded.
+func restoreOriginal(fileName string) (image.Image, []openexr.Attribute, error) {
+	dir, err := backupFolder()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find backup folder: %v", err)
+	}
+	if err := backup.Restore(dir, fileName); err != nil {
+		return nil, nil, err
+	}
+	return loadImage(fileName)
+}
+
+// chooseBackupFolder asks for the folder to keep originals in.
+func chooseBackupFolder(prt *app.Printer) {
+	cfg, err := backup.LoadConfig()
+	if err != nil {
+		prt.Errorf("failed to load backup settings: %v", err)
+	}
+	dir, _ := cfg.Folder()
+	chosen, err := dialog.Directory().Title("Select folder for originals...").SetStartDir(dir).Browse()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("failed to get directory: %v", err)
+		return
+	}
+	cfg.Dir = chosen
+	if err := backup.SaveConfig(cfg); err != nil {
+		prt.Errorf("failed to save backup settings: %v", err)
+		return
+	}
+	prt.Infof("Originals will be kept in '%s'", chosen)
+}
+
+func restoreOriginalPrompt(name string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.2 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = confirmationDialog(windowSize, fmt.Sprintf("Overwrite %s with its original?", name), "Restore Original", "Restore", "Cancel", &responded)
+	return responded
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/linked"
+)
+
+// patternUsage counts the texels of a pattern LUT using each ID. The pattern
+// is the linked one while a material or pattern LUT of the workspace is open,
+// or else the open image, read from channel as row indexes.
+type patternUsage struct {
+	channel int
+	// uses caches the counts until the pattern's picture or link changes
+	pic  *pixel.PictureData
+	link linked.Link
+	uses []linked.Use
+}
+
+// usage returns how many texels of pattern use each ID under link.
+func (u *patternUsage) usage(pattern *document, link linked.Link) []linked.Use {
+	if pattern.pic == u.pic && link == u.link && u.pic != nil {
+		return u.uses
+	}
+	raw, ok := rawImage(pattern.img)
+	if !ok {
+		return nil
+	}
+	u.uses, u.pic, u.link = link.Usage(raw), pattern.pic, link
+	return u.uses
+}
+
+// drawPatternUsageWindow lists the IDs used in the pattern LUT and how many
+// texels use each. When the pattern is linked to a material LUT, IDs the
+// material LUT has no row for are flagged, and clicking an ID selects its
+// material row, highlighting the texels using it in the pattern's tab.
+func drawPatternUsageWindow(u *patternUsage, doc *document, w *workspace, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 360, Y: 360}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Pattern ID Usage", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	pattern := doc
+	link := linked.Link{Channel: u.channel, Encoding: linked.EncodingIndex}
+	var material *document
+	if w != nil && w.has(doc) && w.material.img != nil {
+		pattern, material, link = w.pattern, w.material, w.link
+		link.Rows = material.img.Bounds().Dy()
+		imgui.Text(fmt.Sprintf("Pattern: %s (%s, %s)", pattern.displayName(), graphChannelNames[link.Channel], link.Encoding))
+		imgui.Text(fmt.Sprintf("Material: %s", material.displayName()))
+	} else {
+		imgui.Text("Channel")
+		for i, name := range graphChannelNames {
+			imgui.SameLine()
+			imgui.RadioButtonInt(name, &u.channel, i)
+		}
+		textDisabled("Link a material LUT in Tools to find missing IDs")
+	}
+	if pattern.img == nil {
+		textDisabled("No image open")
+		return
+	}
+	uses := u.usage(pattern, link)
+	total := pattern.img.Bounds().Dx() * pattern.img.Bounds().Dy()
+	missing, missingTexels := 0, 0
+	for _, use := range uses {
+		if material != nil && (use.Row < 0 || use.Row >= link.Rows) {
+			missing++
+			missingTexels += use.Texels
+		}
+	}
+	imgui.Text(fmt.Sprintf("%d IDs used", len(uses)))
+	if missing > 0 {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+		imgui.Text(fmt.Sprintf("%d IDs, used by %d texels, are missing from the material LUT", missing, missingTexels))
+		imgui.PopStyleColor()
+	}
+	imgui.Separator()
+
+	columns := 3
+	if material != nil {
+		columns = 4
+	}
+	tableFlags := imgui.TableFlagsBorders | imgui.TableFlagsRowBg | imgui.TableFlagsResizable | imgui.TableFlagsScrollY
+	if !imgui.BeginTableV("UsageTable", columns, tableFlags, imgui.Vec2{}, 0) {
+		return
+	}
+	imgui.TableSetupScrollFreeze(0, 1)
+	imgui.TableSetupColumn("ID")
+	if material != nil {
+		imgui.TableSetupColumn("Material Row")
+	}
+	imgui.TableSetupColumn("Texels")
+	imgui.TableSetupColumn("Share")
+	imgui.TableHeadersRow()
+	var names func(int) string
+	if material != nil {
+		names = material.names().Row
+	}
+	for _, use := range uses {
+		missingRow := material != nil && (use.Row < 0 || use.Row >= link.Rows)
+		if missingRow {
+			imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+		}
+		imgui.TableNextRow()
+		imgui.TableNextColumn()
+		label := fmt.Sprintf("%d##id%d", use.Row, use.Row)
+		if material != nil && !missingRow {
+			if imgui.SelectableV(label, false, imgui.SelectableFlagsSpanAllColumns, imgui.Vec2{}) && material.sprite != nil && material.pasteImg == nil {
+				row := image.Rect(0, use.Row, material.img.Bounds().Dx(), use.Row+1).Add(material.img.Bounds().Min)
+				material.selection = imageRectToSelection(row, material.sprite.Frame().Center(), material.img.Bounds().Dy())
+			}
+		} else {
+			imgui.Text(fmt.Sprintf("%d", use.Row))
+		}
+		if material != nil {
+			imgui.TableNextColumn()
+			if missingRow {
+				imgui.Text("(missing)")
+			} else {
+				imgui.Text(names(use.Row))
+			}
+		}
+		imgui.TableNextColumn()
+		imgui.Text(fmt.Sprintf("%d", use.Texels))
+		imgui.TableNextColumn()
+		imgui.Text(fmt.Sprintf("%.1f%%", 100*float64(use.Texels)/float64(max(total, 1))))
+		if missingRow {
+			imgui.PopStyleColor()
+		}
+	}
+	imgui.EndTable()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/precision"
+	"github.com/ryanjsims/hd2-lut-editor/prefs"
+	"github.com/ryanjsims/hd2-lut-editor/preview"
+	"github.com/ryanjsims/hd2-lut-editor/shell"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+// pipelineNames returns the File > Export Pipeline menu entries for
+// pipelines.
+func pipelineNames(pipelines []prefs.Pipeline) []string {
+	names := make([]string, len(pipelines))
+	for i, pipeline := range pipelines {
+		names[i] = pipeline.Name
+		if names[i] == "" {
+			names[i] = fmt.Sprintf("Pipeline %d", i+1)
+		}
+	}
+	return names
+}
+
+// pipelineOutputPath returns where step writes the export of the image saved
+// at fileName.
+func pipelineOutputPath(step prefs.PipelineStep, fileName string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	path := strings.ReplaceAll(step.Path, "{name}", name)
+	if strings.ContainsAny(path, "{}") {
+		return "", fmt.Errorf("unknown token in path %q", step.Path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(fileName), path)
+	}
+	if filepath.Clean(path) == filepath.Clean(fileName) {
+		return "", fmt.Errorf("%s would overwrite the open file", step.Path)
+	}
+	return path, nil
+}
+
+// writePipelineFile writes img to path for a pipeline step, in the precision
+// it names. What the conversion lost is sent to reports.
+func writePipelineFile(prt *app.Printer, step prefs.PipelineStep, img image.Image, attrs []openexr.Attribute, path string, reports chan<- *conversionReport) error {
+	format, ok := precisionNames[strings.ToLower(step.Precision)]
+	if step.Precision == "" {
+		format, ok = precision.Keep, true
+	}
+	if !ok {
+		return fmt.Errorf("unknown precision %q", step.Precision)
+	}
+	var ddsOpts dds.WriteHDROptions
+	if step.MipMaps != "" {
+		filter, ok := mipFilterNames[strings.ToLower(step.MipMaps)]
+		if !ok {
+			return fmt.Errorf("unknown mipmap filter %q", step.MipMaps)
+		}
+		ddsOpts = dds.WriteHDROptions{MipMaps: true, Filter: filter}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		return preview.WritePNG(out, img, preview.DefaultOptions())
+	case ".exr", ".dds":
+		conv := conversionSettings{Precision: int(format), Threshold: precision.DefaultThreshold}
+		converted, report, err := convertForSave(img, conv)
+		if err != nil {
+			return err
+		}
+		if err := writeImageFile(converted, attrs, path, ddsOpts); err != nil {
+			return err
+		}
+		if report != nil {
+			reportConversion(prt, reports, path, *report)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not an .exr, .dds or .png file", path)
+}
+
+// runPipeline writes each file of pipeline from img, the image of the file
+// fileName, and runs its commands, in order. It stops at the first step that
+// fails.
+func runPipeline(prt *app.Printer, pipeline prefs.Pipeline, img image.Image, attrs []openexr.Attribute, fileName string, reports chan<- *conversionReport, task *types.BackgroundStatus) {
+	ctx, stop := taskContext(task)
+	defer stop()
+	var lastOut string
+	var outputs []string
+	written := 0
+	for i, step := range pipeline.Steps {
+		task.OnProgress(i, len(pipeline.Steps), nil)
+		var err error
+		if step.Command != "" {
+			line := strings.ReplaceAll(expandHookCommand(step.Command, fileName), "{out}", shell.Quote(lastOut))
+			var output string
+			output, err = runCommand(ctx, line, filepath.Dir(fileName))
+			if output != "" {
+				prt.Infof("Export pipeline '%s', step %d:\n%s", pipeline.Name, i+1, output)
+				outputs = append(outputs, output)
+			}
+		} else {
+			var path string
+			path, err = pipelineOutputPath(step, fileName)
+			if err == nil {
+				err = writePipelineFile(prt, step, img, attrs, path, reports)
+			}
+			if err == nil {
+				prt.Infof("Export pipeline '%s' wrote '%s'", pipeline.Name, path)
+				lastOut = path
+				written++
+			}
+		}
+		task.Output = lastLines(strings.Join(outputs, "\n"))
+		if task.Cancelled() {
+			task.OnCancel()
+			return
+		}
+		if err != nil {
+			prt.Errorf("export pipeline '%s', step %d: %v", pipeline.Name, i+1, err)
+			task.OnError(fmt.Errorf("step %d: %v", i+1, err))
+			return
+		}
+	}
+	task.Report(fmt.Sprintf("wrote %d files, ran %d commands", written, len(pipeline.Steps)-written))
+}
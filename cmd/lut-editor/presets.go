@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/presets"
+	"github.com/sqweek/dialog"
+)
+
+// presetLibrary is the user's material presets, shown in the Presets window.
+// Every change is saved straight away.
+type presetLibrary struct {
+	path string
+	list []presets.Preset
+	// filter hides presets whose names don't contain it
+	filter string
+	// name is what the next captured preset is called
+	name     string
+	imported chan []presets.Preset
+}
+
+func loadPresetLibrary(prt *app.Printer) *presetLibrary {
+	lib := &presetLibrary{imported: make(chan []presets.Preset, 1)}
+	path, err := presets.LibraryPath()
+	if err != nil {
+		prt.Errorf("presets will not be saved: %v", err)
+		return lib
+	}
+	lib.path = path
+	lib.list, err = presets.Load(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		prt.Errorf("failed to load presets: %v", err)
+	}
+	return lib
+}
+
+func (l *presetLibrary) save(prt *app.Printer) {
+	if l.path == "" {
+		return
+	}
+	if err := presets.Save(l.path, l.list); err != nil {
+		prt.Errorf("failed to save presets: %v", err)
+	}
+}
+
+// update adds the presets of an imported pack to the library. It is called
+// every frame.
+func (l *presetLibrary) update(prt *app.Printer) {
+	for len(l.imported) > 0 {
+		var added, replaced int
+		l.list, added, replaced = presets.Merge(l.list, <-l.imported)
+		l.save(prt)
+		prt.Infof("Imported %d new presets, replaced %d", added, replaced)
+	}
+}
+
+func importPresets(prt *app.Printer, results chan<- []presets.Preset) {
+	packFileName, err := dialog.File().Title("Import Preset Pack").Filter("Preset packs", "json").Load()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	imported, err := presets.Load(packFileName)
+	if err != nil {
+		prt.Errorf("failed to import presets: %v", err)
+		return
+	}
+	results <- imported
+}
+
+func exportPresets(prt *app.Printer, list []presets.Preset) {
+	packFileName, err := dialog.File().Title("Export Preset Pack").Filter("Preset packs", "json").Save()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	if filepath.Ext(packFileName) == "" {
+		packFileName += ".json"
+	}
+	if err := presets.Save(packFileName, list); err != nil {
+		prt.Errorf("failed to export presets: %v", err)
+		return
+	}
+	prt.Infof("Exported %d presets to '%s'", len(list), packFileName)
+}
+
+// selectedRows returns the rows presets are applied to and captured from: the
+// rows of the selection, across the whole image.
+func (d *document) selectedRows() (image.Rectangle, bool) {
+	if d.img == nil || d.selection == pixel.ZR || d.pasteImg != nil {
+		return image.Rectangle{}, false
+	}
+	rect, _ := d.editRect()
+	bounds := d.img.Bounds()
+	rect.Min.X, rect.Max.X = bounds.Min.X, bounds.Max.X
+	return rect, !rect.Empty()
+}
+
+// applyPreset writes preset into every selected row.
+func (d *document) applyPreset(prt *app.Printer, preset presets.Preset, currColor [4]float32, lock blend.Lock) {
+	rows, ok := d.selectedRows()
+	raw, isRaw := rawImage(d.img)
+	if !ok || !isRaw {
+		return
+	}
+	if len(preset.Values) != rows.Dx() {
+		prt.Infof("'%s' has %d columns but the image has %d", preset.Name, len(preset.Values), rows.Dx())
+	}
+	d.undoStack.Push(fmt.Sprintf("Apply %s", preset.Name), d.fileName, d.saved, d.img, currColor, d.selection)
+	restore := d.protectEdits(lock)
+	changed := 0
+	for y := rows.Min.Y; y < rows.Max.Y; y++ {
+		changed += presets.Apply(raw, y, preset, lock)
+	}
+	restore()
+	prt.Infof("Applied '%s' to %d rows, %d texels changed", preset.Name, rows.Dy(), changed)
+	d.saved = d.saved && changed == 0
+	d.refreshSprites = true
+}
+
+// capturePreset adds the first selected row to the library as a preset
+// called name, replacing any preset already called that.
+func (l *presetLibrary) capturePreset(prt *app.Printer, doc *document, name string) {
+	rows, ok := doc.selectedRows()
+	raw, isRaw := rawImage(doc.img)
+	if !ok || !isRaw {
+		return
+	}
+	schema := ""
+	if doc.schema != nil {
+		schema = doc.schema.Name
+	}
+	preset := presets.Capture(name, schema, raw, rows.Min.Y)
+	if i := presets.Find(l.list, name); i >= 0 {
+		l.list[i] = preset
+		prt.Infof("Replaced preset '%s' with row %d", name, rows.Min.Y)
+	} else {
+		l.list = append(l.list, preset)
+		prt.Infof("Captured row %d as preset '%s'", rows.Min.Y, name)
+	}
+	l.save(prt)
+}
+
+// drawPresetsWindow lists the library's presets. It returns the index of the
+// preset to apply to the selected rows, or -1.
+func drawPresetsWindow(prt *app.Printer, lib *presetLibrary, doc *document, visible *bool) (apply int) {
+	apply = -1
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 360, Y: 420}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Presets", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	rows, hasRows := doc.selectedRows()
+	if !hasRows {
+		textDisabled("Select rows to apply or capture presets")
+	} else if rows.Dy() == 1 {
+		imgui.Text(fmt.Sprintf("Row %d selected", rows.Min.Y))
+	} else {
+		imgui.Text(fmt.Sprintf("Rows %d to %d selected", rows.Min.Y, rows.Max.Y-1))
+	}
+
+	imgui.InputTextWithHintV("##presetName", "Name", &lib.name, 0, nil)
+	imgui.SameLine()
+	if imgui.Button("Capture Row") && hasRows {
+		name := strings.TrimSpace(lib.name)
+		if name == "" {
+			name = fmt.Sprintf("Preset %d", len(lib.list)+1)
+		}
+		lib.capturePreset(prt, doc, name)
+		lib.name = ""
+	}
+	if imgui.Button("Import Pack...") {
+		go importPresets(prt, lib.imported)
+	}
+	imgui.SameLine()
+	if imgui.Button("Export Pack...") && len(lib.list) > 0 {
+		go exportPresets(prt, slices.Clone(lib.list))
+	}
+	imgui.Separator()
+
+	if len(lib.list) == 0 {
+		textDisabled("No presets yet. Select a row and capture it")
+		return
+	}
+	imgui.InputTextWithHintV("##presetFilter", "Filter", &lib.filter, 0, nil)
+	filter := strings.ToLower(strings.TrimSpace(lib.filter))
+	width := 0
+	if doc.img != nil {
+		width = doc.img.Bounds().Dx()
+	}
+	deleting := -1
+	for i, preset := range lib.list {
+		if filter != "" && !strings.Contains(strings.ToLower(preset.Name), filter) {
+			continue
+		}
+		imgui.PushIDInt(i)
+		if imgui.Button("Apply") && hasRows {
+			apply = i
+		}
+		imgui.SameLine()
+		if imgui.Button("Delete") {
+			deleting = i
+		}
+		imgui.SameLine()
+		if width != 0 && len(preset.Values) != width {
+			textDisabled(fmt.Sprintf("%s (%d columns)", preset.Name, len(preset.Values)))
+		} else {
+			imgui.Text(preset.Name)
+		}
+		if imgui.IsItemHovered() && preset.Schema != "" {
+			imgui.SetTooltip(fmt.Sprintf("Captured from a %s LUT", preset.Schema))
+		}
+		imgui.PopID()
+	}
+	if deleting >= 0 {
+		lib.list = slices.Delete(lib.list, deleting, deleting+1)
+		lib.save(prt)
+		apply = -1
+	}
+	return
+}
+
+// rowPreset captures the first selected row, named as in the Project window
+// or the schema.
+func (d *document) rowPreset() (presets.Preset, bool) {
+	rows, ok := d.selectedRows()
+	raw, isRaw := rawImage(d.img)
+	if !ok || !isRaw {
+		return presets.Preset{}, false
+	}
+	name := fmt.Sprintf("Row %d", rows.Min.Y)
+	if names := d.names(); rows.Min.Y < len(names.Rows) && names.Rows[rows.Min.Y] != "" {
+		name = names.Rows[rows.Min.Y]
+	}
+	schema := ""
+	if d.schema != nil {
+		schema = d.schema.Name
+	}
+	return presets.Capture(name, schema, raw, rows.Min.Y), true
+}
+
+func exportRow(prt *app.Printer, row presets.SharedRow) {
+	rowFileName, err := dialog.File().Title("Export Row").Filter("LUT rows", strings.TrimPrefix(presets.RowExtension, ".")).Save()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	if filepath.Ext(rowFileName) == "" {
+		rowFileName += presets.RowExtension
+	}
+	if err := presets.WriteRow(rowFileName, row); err != nil {
+		prt.Errorf("failed to export row: %v", err)
+		return
+	}
+	prt.Infof("Exported '%s' to '%s'", row.Name, rowFileName)
+}
+
+func importRow(prt *app.Printer, results chan<- *presets.SharedRow) {
+	rowFileName, err := dialog.File().Title("Import Row").Filter("LUT rows", strings.TrimPrefix(presets.RowExtension, ".")).Load()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	row, err := presets.ReadRow(rowFileName)
+	if err != nil {
+		prt.Errorf("failed to import row: %v", err)
+		return
+	}
+	results <- &row
+}
+
+// applySharedRow writes an imported row into the selected rows, noting when it
+// was made for another kind of LUT.
+func (d *document) applySharedRow(prt *app.Printer, row *presets.SharedRow, currColor [4]float32, lock blend.Lock) {
+	if _, ok := d.selectedRows(); !ok {
+		prt.Errorf("failed to import '%s': select the rows to write it into", row.Name)
+		return
+	}
+	if row.Schema != "" && (d.schema == nil || !strings.EqualFold(d.schema.Name, row.Schema)) {
+		prt.Infof("'%s' was exported from a %s LUT", row.Name, row.Schema)
+	}
+	d.applyPreset(prt, row.Preset(), currColor, lock)
+}
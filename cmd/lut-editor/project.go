@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/project"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+	"github.com/sqweek/dialog"
+)
+
+// hasImageFile reports whether the document's image is stored in a file of its
+// own, rather than only in memory or in a project.
+func (d *document) hasImageFile() bool {
+	return d.fileName != "" && d.fileName != "(new)"
+}
+
+// project collects the document's session state for saving. The image is left
+// to be embedded by saveProject if the document has no file of its own or
+// embedImage is set.
+func (d *document) project(channel hdrColors.GraySetting, grid bool) *project.Project {
+	proj := &project.Project{
+		Baseline:    d.baseline,
+		RowNames:    trimNames(d.rowNames),
+		ColumnNames: trimNames(d.columnNames),
+		Selections:  slices.Clone(d.savedSelections),
+		Notes:       d.notes,
+		View: project.View{
+			CamX:    d.camPos.X,
+			CamY:    d.camPos.Y,
+			Zoom:    d.camZoom,
+			Channel: int(channel),
+			Grid:    grid,
+		},
+		RecentColors: slices.Clone(d.recentColors),
+	}
+	for _, rect := range d.locked.Rects() {
+		proj.Locked = append(proj.Locked, project.NewRegion(rect))
+	}
+	if d.hasImageFile() && !d.embedImage {
+		proj.Image = d.fileName
+	} else {
+		proj.EmbeddedFormat = "exr"
+		if strings.EqualFold(filepath.Ext(d.fileName), ".dds") {
+			proj.EmbeddedFormat = "dds"
+		}
+	}
+	return proj
+}
+
+// applyProject restores the session state saved in proj. The view settings
+// shared by all documents are left in projectView for the main loop to apply.
+func (d *document) applyProject(proj *project.Project) {
+	d.baseline = proj.Baseline
+	d.rowNames = slices.Clone(proj.RowNames)
+	d.columnNames = slices.Clone(proj.ColumnNames)
+	d.savedSelections = slices.Clone(proj.Selections)
+	d.notes = proj.Notes
+	d.recentColors = slices.Clone(proj.RecentColors)
+	d.locked.Clear()
+	for _, region := range proj.Locked {
+		d.locked.Add(region.Rect())
+	}
+	d.embedImage = proj.Embedded()
+	d.camPos = pixel.V(proj.View.CamX, proj.View.CamY)
+	if proj.View.Zoom > 0 {
+		d.camZoom = proj.View.Zoom
+	}
+	view := proj.View
+	if view.Channel < int(hdrColors.GraySettingNone) || view.Channel > int(hdrColors.GraySettingNoAlpha) {
+		view.Channel = int(hdrColors.GraySettingNoAlpha)
+	}
+	d.projectView = &view
+}
+
+// trimNames drops trailing empty names, which the Project window adds for
+// every row and column of the image.
+func trimNames(names []string) []string {
+	end := len(names)
+	for end > 0 && names[end-1] == "" {
+		end--
+	}
+	return slices.Clone(names[:end])
+}
+
+// loadProject opens the project at path, along with the image it refers to or
+// embeds, in a new document.
+func loadProject(path string, currColor [4]float32, task *types.BackgroundStatus) (*document, error) {
+	proj, err := project.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	var newDoc *document
+	if proj.Embedded() {
+		img, attrs, err := decodeImage(bytes.NewReader(proj.EmbeddedImage), "."+proj.EmbeddedFormat)
+		if err != nil {
+			return nil, fmt.Errorf("embedded image: %v", err)
+		}
+		newDoc = newDocument("(new)", img, true)
+		newDoc.title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		newDoc.attributes = attrs
+	} else {
+		img, attrs, err := loadImageProgress(proj.Image, task)
+		if errors.Is(err, errLoadCancelled) {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("image '%s': %v", proj.Image, err)
+		}
+		newDoc = newDocument(proj.Image, img, true)
+		newDoc.attributes = attrs
+	}
+	newDoc.projectFile = path
+	newDoc.applyProject(proj)
+	newDoc.undoStack.Push("Open Project", newDoc.fileName, true, newDoc.img, currColor, newDoc.selection)
+	return newDoc, nil
+}
+
+func openProject(prt *app.Printer, openedDocs chan<- *document, currColor [4]float32, task *types.BackgroundStatus) {
+	projectFileName, err := dialog.File().Title("Open Project").Filter("LUT projects", "lutproj").Load()
+	if err == dialog.ErrCancelled {
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		task.OnCancel()
+		return
+	}
+	openPath(prt, projectFileName, openedDocs, currColor, task)
+}
+
+// saveProject writes proj to projectFile, asking for a file name first if
+// there isn't one yet or saveAs is set. img is embedded when proj doesn't refer
+// to an image file.
+func saveProject(prt *app.Printer, projectFile *string, proj *project.Project, img image.Image, attrs []openexr.Attribute, imageSaved, saveAs bool) {
+	projectFileName := *projectFile
+	if saveAs || projectFileName == "" {
+		var err error
+		projectFileName, err = dialog.File().Title("Save Project").Filter("LUT projects", "lutproj").Save()
+		if err == dialog.ErrCancelled {
+			return
+		} else if err != nil {
+			prt.Errorf("%v", err)
+			return
+		}
+		if filepath.Ext(projectFileName) == "" {
+			projectFileName += project.Extension
+		}
+	}
+
+	if proj.Image == "" {
+		var buf bytes.Buffer
+		if err := writeImage(&buf, img, attrs, "embedded."+proj.EmbeddedFormat, dds.WriteHDROptions{}); err != nil {
+			prt.Errorf("failed to embed image: %v", err)
+			return
+		}
+		proj.EmbeddedImage = buf.Bytes()
+	} else if !imageSaved {
+		prt.Infof("The image has unsaved changes, which the project will include once the image is saved")
+	}
+	if err := proj.Save(projectFileName); err != nil {
+		prt.Errorf("failed to save project: %v", err)
+		return
+	}
+	*projectFile = projectFileName
+	prt.Infof("Saved project '%s'", projectFileName)
+}
+
+// imageRectToSelection is the inverse of selectionToImageRect.
+func imageRectToSelection(rect image.Rectangle, center pixel.Vec, height int) pixel.Rect {
+	return pixel.R(
+		float64(rect.Min.X),
+		float64(height-rect.Max.Y),
+		float64(rect.Max.X),
+		float64(height-rect.Min.Y),
+	).Moved(center.Scaled(-1))
+}
+
+// drawProjectWindow edits the notes, saved selections and row and column
+// names stored in the document's project. It reports whether a saved
+// selection was restored.
+func drawProjectWindow(doc *document, selectionName *string, visible *bool) (restored bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 360, Y: 420}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Project", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+	if doc.img == nil {
+		textDisabled("No image")
+		return
+	}
+
+	if doc.projectFile != "" {
+		imgui.Text(fmt.Sprintf("Project: %s", filepath.Base(doc.projectFile)))
+	} else {
+		textDisabled("Not saved as a project")
+	}
+	if doc.hasImageFile() {
+		imgui.Checkbox("Embed image in project", &doc.embedImage)
+	} else {
+		textDisabled("Image is embedded in the project")
+	}
+
+	imgui.Text("Notes")
+	imgui.InputTextMultilineV("##notes", &doc.notes, imgui.Vec2{X: -1, Y: 6 * imgui.TextLineHeight()}, 0, nil)
+
+	if imgui.CollapsingHeader("Selections") {
+		height := doc.img.Bounds().Dy()
+		deleting := -1
+		for i, saved := range doc.savedSelections {
+			imgui.PushIDInt(i)
+			if imgui.Button("Select") && doc.pasteImg == nil {
+				doc.selection = imageRectToSelection(saved.Rect(), doc.sprite.Frame().Center(), height)
+				restored = true
+			}
+			imgui.SameLine()
+			if imgui.Button("Delete") {
+				deleting = i
+			}
+			imgui.SameLine()
+			imgui.Text(fmt.Sprintf("%s (%d, %d) %dx%d", saved.Name, saved.X, saved.Y, saved.Width, saved.Height))
+			imgui.PopID()
+		}
+		if deleting >= 0 {
+			doc.savedSelections = slices.Delete(doc.savedSelections, deleting, deleting+1)
+		}
+		imgui.InputTextWithHintV("##selectionName", "Name", selectionName, 0, nil)
+		imgui.SameLine()
+		if imgui.Button("Save Selection") && doc.selection != pixel.ZR && doc.pasteImg == nil {
+			rect, _ := doc.editRect()
+			name := strings.TrimSpace(*selectionName)
+			if name == "" {
+				name = fmt.Sprintf("Selection %d", len(doc.savedSelections)+1)
+			}
+			doc.savedSelections = append(doc.savedSelections, project.NewSelection(name, rect))
+			*selectionName = ""
+		}
+	}
+
+	if imgui.CollapsingHeader("Row Names") {
+		drawNameInputs("Row", &doc.rowNames, doc.img.Bounds().Dy(), func(i int) string { return schemaHint(doc.schema, true, i) })
+	}
+	if imgui.CollapsingHeader("Column Names") {
+		drawNameInputs("Column", &doc.columnNames, doc.img.Bounds().Dx(), func(i int) string { return schemaHint(doc.schema, false, i) })
+	}
+	return
+}
+
+// drawNameInputs edits the first count names. Empty names show hint, the
+// name the schema gives them.
+func drawNameInputs(label string, names *[]string, count int, hint func(int) string) {
+	if len(*names) < count {
+		*names = append(*names, make([]string, count-len(*names))...)
+	}
+	for i := 0; i < count; i++ {
+		imgui.InputTextWithHintV(fmt.Sprintf("%s %d", label, i), hint(i), &(*names)[i], 0, nil)
+	}
+}
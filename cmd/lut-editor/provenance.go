@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os/user"
+	"time"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/provenance"
+)
+
+// provenanceUser is the name of the person running the editor, stamped on
+// edits when the document doesn't name its author.
+var provenanceUser = func() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}()
+
+// recordProvenance stamps the texels changed since it was last called, with
+// the edit that changed them. It is called when the picture is refreshed after
+// an edit. Most edits push their undo state just before making their change,
+// so the newest undo state names the edit, unless an edit merged into a
+// delayed push is still pending, or the change was an undo or redo, or a
+// change to the layers. The texels are those of the flattened layers.
+func (d *document) recordProvenance() {
+	redo, layerChange := len(d.undoStack.RedoStack), d.layerChange
+	defer func() { d.provenanceRedo, d.layerChange = redo, "" }()
+	if d.img == nil || !d.provenance.Tracking() {
+		return
+	}
+	raw, ok := rawImage(d.flattened())
+	if !ok {
+		return
+	}
+	action := "Edit"
+	if pending, ok := d.undoStack.Pending(); ok {
+		action = pending
+	} else if n := len(d.undoStack.UndoStack); n > 0 {
+		action = d.undoStack.UndoStack[n-1].Action
+	}
+	switch {
+	case redo > d.provenanceRedo:
+		action = "Undo"
+	case redo < d.provenanceRedo:
+		action = "Redo"
+	case layerChange != "":
+		action = layerChange
+	}
+	author := stringAttribute(d.attributes, "author")
+	if author == "" {
+		author = provenanceUser
+	}
+	d.provenance.Record(provenance.Stamp{Action: action, Author: author, Time: time.Now()}, raw)
+}
+
+// trackProvenance starts recording the provenance of the document's texels,
+// picking up the stamps saved next to its file, if any.
+func (d *document) trackProvenance(prt *app.Printer) {
+	if d.img == nil || d.provenance.Tracking() {
+		return
+	}
+	raw, ok := rawImage(d.flattened())
+	if !ok {
+		return
+	}
+	d.provenanceRedo = len(d.undoStack.RedoStack)
+	if d.hasImageFile() {
+		err := d.provenance.Load(provenance.SidecarPath(d.fileName), raw)
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			prt.Warnf("ignoring the provenance of %s: %v", d.fileName, err)
+		}
+	}
+	d.provenance.Start(raw)
+}
+
+// saveProvenance writes the stamps of prov next to the image saved at
+// fileName, if it is tracking.
+func saveProvenance(prt *app.Printer, prov *provenance.Map, fileName string) {
+	if !prov.Tracking() {
+		return
+	}
+	if err := prov.Save(provenance.SidecarPath(fileName)); err != nil {
+		prt.Errorf("failed to save provenance: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/gopxl/pixel/v2"
+)
+
+// pyramid holds ever smaller copies of a document's picture, each half the
+// size of the last, so noisy LUT data zoomed out is drawn averaged instead of
+// aliasing. Levels are made as they are first needed.
+type pyramid struct {
+	// pic is the picture the levels were made from
+	pic *pixel.PictureData
+	// levels[i] is 1/2^(i+1) the size of pic
+	levels []*pixel.PictureData
+	sprite *pixel.Sprite
+	shown  *pixel.PictureData
+}
+
+// pyramidLevel returns the level to draw a picture at zoom with: 0 for the
+// picture itself, or n for the copy 1/2^n its size.
+func pyramidLevel(zoom float64) int {
+	if zoom >= 1 || zoom <= 0 {
+		return 0
+	}
+	return int(math.Floor(math.Log2(1 / zoom)))
+}
+
+// downsample returns src at half its size, rounded up, with each texel the
+// average of those it covers.
+func downsample(src *pixel.PictureData) *pixel.PictureData {
+	w, h := int(src.Rect.W()), int(src.Rect.H())
+	dw, dh := (w+1)/2, (h+1)/2
+	dst := pixel.MakePictureData(pixel.R(0, 0, float64(dw), float64(dh)))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.Pix[sy*src.Stride+sx]
+					sum[0] += int(c.R)
+					sum[1] += int(c.G)
+					sum[2] += int(c.B)
+					sum[3] += int(c.A)
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			dst.Pix[y*dst.Stride+x] = color.RGBA{
+				R: uint8((sum[0] + n/2) / n),
+				G: uint8((sum[1] + n/2) / n),
+				B: uint8((sum[2] + n/2) / n),
+				A: uint8((sum[3] + n/2) / n),
+			}
+		}
+	}
+	return dst
+}
+
+// draw returns the sprite to draw pic with at zoom, and the matrix scaling it
+// to cover pic. It returns false when pic should be drawn as it is.
+func (p *pyramid) draw(pic *pixel.PictureData, zoom float64) (*pixel.Sprite, pixel.Matrix, bool) {
+	level := pyramidLevel(zoom)
+	if pic == nil || level == 0 {
+		return nil, pixel.IM, false
+	}
+	if p.pic != pic {
+		p.pic, p.levels = pic, nil
+	}
+	for len(p.levels) < level {
+		last := pic
+		if len(p.levels) > 0 {
+			last = p.levels[len(p.levels)-1]
+		}
+		if last.Rect.W() <= 1 && last.Rect.H() <= 1 {
+			break
+		}
+		p.levels = append(p.levels, downsample(last))
+	}
+	if len(p.levels) == 0 {
+		return nil, pixel.IM, false
+	}
+	shown := p.levels[min(level, len(p.levels))-1]
+	if p.sprite == nil {
+		p.sprite = pixel.NewSprite(shown, shown.Bounds())
+	} else if p.shown != shown {
+		p.sprite.Set(shown, shown.Bounds())
+	}
+	p.shown = shown
+	scale := pixel.V(pic.Rect.W()/shown.Rect.W(), pic.Rect.H()/shown.Rect.H())
+	return p.sprite, pixel.IM.ScaledXY(pixel.ZV, scale), true
+}
+
+// bytes returns the memory held by the levels made so far.
+func (p *pyramid) bytes() int {
+	n := 0
+	for _, level := range p.levels {
+		n += pictureBytes(level)
+	}
+	return n
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"math"
+	"path/filepath"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/colorspace"
+	"github.com/ryanjsims/hd2-lut-editor/preview"
+	"github.com/sqweek/dialog"
+)
+
+// rampPreviewSamples is the most texels across the reference shown in the
+// Sample Ramp window. Colors are sampled from the full image.
+const rampPreviewSamples = 96
+
+// rampReference is an image colors can be sampled from, such as concept art.
+type rampReference struct {
+	path    string
+	img     image.Image
+	preview *thumbnail
+}
+
+// rampSampler is the state of the Sample Ramp window: a reference image and
+// the line drawn across it. The line's ends are fractions of the reference's
+// width and height, from the top left.
+type rampSampler struct {
+	reference  *rampReference
+	start, end [2]float64
+	// decode makes sRGB encoded references linear, for LUTs of linear values
+	decode     bool
+	references chan *rampReference
+}
+
+func newRampSampler() *rampSampler {
+	return &rampSampler{
+		start:      [2]float64{0, 0.5},
+		end:        [2]float64{1, 0.5},
+		references: make(chan *rampReference, 1),
+	}
+}
+
+// chooseReference asks for a reference image and loads it for update to pick
+// up.
+func (s *rampSampler) chooseReference(prt *app.Printer) {
+	path, err := dialog.File().Title("Sample Ramp").Filter("Images", "png", "jpg", "jpeg", "dds", "exr").Load()
+	if err == dialog.ErrCancelled {
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		return
+	}
+	img, _, err := loadImage(path)
+	if err != nil {
+		prt.Errorf("Loading reference '%s': %v", path, err)
+		return
+	}
+	s.references <- &rampReference{
+		path:    path,
+		img:     img,
+		preview: &thumbnail{pixels: preview.Thumbnail(img, rampPreviewSamples, preview.ToneMapClamp)},
+	}
+}
+
+// update picks up a newly loaded reference. It is called every frame.
+func (s *rampSampler) update() {
+	for len(s.references) > 0 {
+		s.reference = <-s.references
+	}
+}
+
+// sample returns count colors evenly spaced along the line, each averaging
+// the texels along its share of the line.
+func (s *rampSampler) sample(count int) [][3]float64 {
+	if s.reference == nil || count <= 0 {
+		return nil
+	}
+	bounds := s.reference.img.Bounds()
+	at := func(t float64) [3]float64 {
+		x := bounds.Min.X + int((s.start[0]+t*(s.end[0]-s.start[0]))*float64(bounds.Dx()))
+		y := bounds.Min.Y + int((s.start[1]+t*(s.end[1]-s.start[1]))*float64(bounds.Dy()))
+		x, y = min(max(x, bounds.Min.X), bounds.Max.X-1), min(max(y, bounds.Min.Y), bounds.Max.Y-1)
+		return referenceColor(s.reference.img, x, y)
+	}
+	length := math.Hypot((s.end[0]-s.start[0])*float64(bounds.Dx()), (s.end[1]-s.start[1])*float64(bounds.Dy()))
+	steps := min(max(int(length/float64(count)), 1), 64)
+	ramp := make([][3]float64, count)
+	for i := range ramp {
+		var sum [3]float64
+		for j := 0; j < steps; j++ {
+			c := at((float64(i) + (float64(j)+0.5)/float64(steps)) / float64(count))
+			for k := range sum {
+				sum[k] += c[k]
+			}
+		}
+		for k := range sum {
+			ramp[i][k] = sum[k] / float64(steps)
+			if s.decode {
+				ramp[i][k] = float64(colorspace.DecodeSRGB(float32(ramp[i][k])))
+			}
+		}
+	}
+	return ramp
+}
+
+// referenceColor returns the color of a texel of img, unpremultiplied. HDR
+// images keep their stored values.
+func referenceColor(img image.Image, x, y int) [3]float64 {
+	if raw, ok := rawImage(img); ok {
+		c := raw.RawAt(x, y)
+		return [3]float64{c[0], c[1], c[2]}
+	}
+	c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+	return [3]float64{float64(c.R) / math.MaxUint16, float64(c.G) / math.MaxUint16, float64(c.B) / math.MaxUint16}
+}
+
+// writeRamp replaces the color channels of each row of rect with ramp, which
+// has a color for each column. Alpha and the channels in lock are kept.
+func (d *document) writeRamp(ramp [][3]float64, rect image.Rectangle, lock blend.Lock) {
+	raw, ok := rawImage(d.img)
+	if !ok {
+		return
+	}
+	lock[3] = true
+	rect = rect.Intersect(d.img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X && x-rect.Min.X < len(ramp); x++ {
+			c := ramp[x-rect.Min.X]
+			raw.SetRaw(x, y, lock.Pixel(blend.Replace, raw.RawAt(x, y), [4]float64{c[0], c[1], c[2], 1}))
+		}
+	}
+}
+
+// drawRampWindow shows the reference with the sampled line, which is drawn by
+// dragging across it, and the count colors sampled along it. It returns true
+// when the colors should be written into the selection.
+func drawRampWindow(s *rampSampler, count int, hasSelection bool, choose *bool, visible *bool) (write bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 380, Y: 440}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Sample Ramp", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+	*choose = imgui.Button("Load Reference...")
+	if s.reference == nil {
+		textDisabled("No reference image")
+		return
+	}
+	imgui.SameLine()
+	imgui.Text(filepath.Base(s.reference.path))
+
+	width := imgui.ContentRegionAvail().X
+	imgui.InvisibleButton("##reference", imgui.Vec2{X: width, Y: 0.75 * width})
+	rectMin, rectMax := imgui.ItemRectMin(), imgui.ItemRectMax()
+	drawThumbnail(s.reference.preview, rectMin, rectMax)
+	size := s.reference.preview.pixels.Bounds().Size()
+	origin, texel := thumbnailLayout(size, rectMin, rectMax)
+	extent := imgui.Vec2{X: texel * float32(size.X), Y: texel * float32(size.Y)}
+	toReference := func(p imgui.Vec2) [2]float64 {
+		return [2]float64{
+			min(max(float64((p.X-origin.X)/extent.X), 0), 1),
+			min(max(float64((p.Y-origin.Y)/extent.Y), 0), 1),
+		}
+	}
+	toScreen := func(p [2]float64) imgui.Vec2 {
+		return origin.Plus(imgui.Vec2{X: float32(p[0]) * extent.X, Y: float32(p[1]) * extent.Y})
+	}
+	if imgui.IsItemClicked() {
+		s.start = toReference(imgui.MousePos())
+	}
+	if imgui.IsItemActive() {
+		s.end = toReference(imgui.MousePos())
+	}
+	drawList := imgui.WindowDrawList()
+	white := imgui.PackedColorFromVec4(imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1})
+	drawList.AddLineV(toScreen(s.start), toScreen(s.end), imgui.PackedColorFromVec4(imgui.Vec4{W: 1}), 3)
+	drawList.AddLineV(toScreen(s.start), toScreen(s.end), white, 1)
+	imgui.Checkbox("Decode sRGB", &s.decode)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Make the reference's colors linear, for LUTs holding linear values")
+	}
+
+	ramp := s.sample(count)
+	imgui.Text(fmt.Sprintf("%d colors", count))
+	imgui.Dummy(imgui.Vec2{X: width, Y: 24})
+	stripMin := imgui.ItemRectMin()
+	step := width / float32(max(count, 1))
+	for i, c := range ramp {
+		swatchMin := stripMin.Plus(imgui.Vec2{X: float32(i) * step})
+		drawList.AddRectFilled(swatchMin, swatchMin.Plus(imgui.Vec2{X: step, Y: 24}), imgui.PackedColorFromVec4(imgui.Vec4{
+			X: float32(min(max(c[0], 0), 1)),
+			Y: float32(min(max(c[1], 0), 1)),
+			Z: float32(min(max(c[2], 0), 1)),
+			W: 1,
+		}))
+	}
+	if !hasSelection {
+		textDisabled("Select the LUT texels to write the ramp into")
+		return
+	}
+	return imgui.Button("Write to Selection")
+}
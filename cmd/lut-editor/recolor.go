@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/filter"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+)
+
+var hueSpaceNames = []string{"HSV", "OKLCh"}
+
+// recolorSettings are the choices in the Recolor dialog.
+type recolorSettings struct {
+	space      int32
+	hue        float32
+	saturation float32
+	// colorOnly recolors only the columns the schema marks as colors
+	colorOnly bool
+}
+
+func defaultRecolorSettings() recolorSettings {
+	return recolorSettings{space: int32(filter.HueSpaceOKLCh), saturation: 1, colorOnly: true}
+}
+
+// columns returns the columns recolored in an image width wide, or nil for
+// all of them.
+func (s recolorSettings) columns(schema *help.Schema, width int) []bool {
+	if !s.colorOnly || schema == nil || !schema.HasColorColumns() {
+		return nil
+	}
+	return schema.ColorColumns(width)
+}
+
+func recolor(settings *recolorSettings, schema *help.Schema, target string, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.3 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = recolorDialog(settings, schema, target, windowSize, &responded)
+	return responded
+}
+
+func recolorDialog(settings *recolorSettings, schema *help.Schema, target string, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Recolor", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Shift the hue and saturation of the %s", target))
+	if imgui.BeginCombo("Color model", hueSpaceNames[settings.space]) {
+		for i, name := range hueSpaceNames {
+			if imgui.SelectableV(name, int32(i) == settings.space, 0, imgui.Vec2{}) {
+				settings.space = int32(i)
+			}
+		}
+		imgui.EndCombo()
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("HSV keeps each color's largest channel, OKLCh its perceived lightness")
+	}
+	imgui.SliderFloatV("Hue", &settings.hue, -180, 180, "%.0f degrees", imgui.SliderFlagsAlwaysClamp)
+	imgui.SliderFloatV("Saturation", &settings.saturation, 0, 2, "x%.2f", imgui.SliderFlagsAlwaysClamp)
+	if schema != nil && schema.HasColorColumns() {
+		imgui.Checkbox("Only color columns", &settings.colorOnly)
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Leave the columns holding scalar parameters, such as roughness, alone")
+		}
+	} else {
+		textDisabled("The image's schema marks no color columns, so every column is recolored")
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Recolor", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// repeatSettings describe Image > Repeat Selection...: count copies of the
+// selection, each offset by (dx, dy) texels from the one before.
+type repeatSettings struct {
+	count  int32
+	dx, dy int32
+}
+
+// repeatSelection stamps copies of the selected texels into the image as
+// settings describe, blending them in with mode. Copies are clipped to the
+// image, and the number that landed at least partly inside it is returned.
+func (d *document) repeatSelection(settings repeatSettings, mode blend.Mode, lock blend.Lock) (int, error) {
+	raw, ok := rawImage(d.img)
+	if !ok {
+		return 0, fmt.Errorf("not an HDR image")
+	}
+	rect := selectionToImageRect(d.selection, d.sprite.Frame().Center(), d.img.Bounds().Dy()).Intersect(d.img.Bounds())
+	if rect.Empty() {
+		return 0, fmt.Errorf("nothing is selected")
+	}
+	// Copy the selection first, so copies overlapping it repeat the original
+	src := hdrColors.NewNRGBA128FImage(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			src.SetRaw(x-rect.Min.X, y-rect.Min.Y, raw.RawAt(x, y))
+		}
+	}
+	placed := 0
+	for i := 1; i <= int(settings.count); i++ {
+		target := rect.Add(image.Pt(i*int(settings.dx), i*int(settings.dy)))
+		if !target.Overlaps(d.img.Bounds()) {
+			continue
+		}
+		if err := (editor.Combine{Rect: target, Src: src, Mode: mode, Lock: lock}).Apply(raw); err != nil {
+			return placed, err
+		}
+		placed++
+	}
+	return placed, nil
+}
+
+func repeatPrompt(settings *repeatSettings, selected image.Point, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.3 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = repeatDialog(settings, selected, windowSize, &responded)
+	return responded
+}
+
+func repeatDialog(settings *repeatSettings, selected image.Point, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Repeat selection", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Stamp copies of the %dx%d selection, each offset from the last", selected.X, selected.Y))
+	imgui.InputInt("Copies", &settings.count)
+	settings.count = max(settings.count, 1)
+	imgui.InputInt("Right", &settings.dx)
+	imgui.InputInt("Down", &settings.dy)
+	// The usual offsets, placing each copy next to the one before
+	if imgui.Button("Stack Below") {
+		settings.dx, settings.dy = 0, int32(selected.Y)
+	}
+	imgui.SameLine()
+	if imgui.Button("Stack Right") {
+		settings.dx, settings.dy = int32(selected.X), 0
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Repeat", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
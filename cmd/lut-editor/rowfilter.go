@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/changes"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// rowFilter dims the rows of a document that don't match a query, to focus
+// on some materials of a large LUT. The matches are cached until the query or
+// the picture changes.
+type rowFilter struct {
+	query   string
+	pic     *pixel.PictureData
+	matched string
+	rows    []bool
+	// compared is whether there was a baseline to find changed rows with
+	compared bool
+	// baseline caches the document's baseline file, loaded the first time a
+	// query asks which rows changed
+	baselinePath string
+	baseline     hdrColors.RawImage
+}
+
+// rowQueryMatches reports whether a row matches every word of query. The
+// words "changed" and "unchanged" match rows that do or don't differ from
+// the baseline, when there is one to compare with; other words match part of
+// the row's name or of the name of a saved selection covering it, ignoring
+// case.
+func rowQueryMatches(query string, names []string, changed, compared bool) bool {
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if compared && word == "changed" {
+			if !changed {
+				return false
+			}
+			continue
+		} else if compared && word == "unchanged" {
+			if changed {
+				return false
+			}
+			continue
+		}
+		found := false
+		for _, name := range names {
+			if strings.Contains(strings.ToLower(name), word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// baselineRaw returns the document's baseline image, or nil if it has none
+// or it can't be compared with the image.
+func (d *document) baselineRaw() hdrColors.RawImage {
+	f := &d.rowFilter
+	if d.baseline == "" {
+		return nil
+	}
+	if f.baselinePath != d.baseline {
+		f.baselinePath, f.baseline = d.baseline, nil
+		if img, _, err := loadImage(d.baseline); err == nil {
+			f.baseline, _ = rawImage(img)
+		}
+	}
+	return f.baseline
+}
+
+// changedRows returns which rows differ from the baseline file, or from the
+// committed file if there is no baseline, and whether either was there to
+// compare with.
+func (d *document) changedRows() ([]bool, bool) {
+	raw, ok := rawImage(d.img)
+	if !ok {
+		return nil, false
+	}
+	base := d.baselineRaw()
+	if base == nil {
+		base = d.committed
+	}
+	if base == nil {
+		return nil, false
+	}
+	diff, err := changes.Diff(base, raw)
+	if err != nil {
+		return nil, false
+	}
+	rows := make([]bool, raw.Bounds().Dy())
+	for _, change := range diff {
+		rows[change.Y] = true
+	}
+	return rows, true
+}
+
+// filteredRows returns which rows match the document's row filter, or nil if
+// there is no filter.
+func (d *document) filteredRows() []bool {
+	f := &d.rowFilter
+	if strings.TrimSpace(f.query) == "" || d.img == nil {
+		return nil
+	}
+	if f.pic == d.pic && f.matched == f.query && f.baselinePath == d.baseline && f.pic != nil {
+		return f.rows
+	}
+	f.pic, f.matched = d.pic, f.query
+	changed, compared := d.changedRows()
+	f.compared = compared
+	names := d.names()
+	height := d.img.Bounds().Dy()
+	f.rows = make([]bool, height)
+	for y := range f.rows {
+		rowNames := []string{names.Row(y)}
+		for _, s := range d.savedSelections {
+			if rect := s.Rect(); y >= rect.Min.Y && y < rect.Max.Y {
+				rowNames = append(rowNames, s.Name)
+			}
+		}
+		f.rows[y] = rowQueryMatches(f.query, rowNames, compared && changed[y], compared)
+	}
+	return f.rows
+}
+
+// drawFilteredRows dims the rows that don't match the row filter.
+func drawFilteredRows(win *opengl.Window, spriteCenter pixel.Vec, width, height int, rows []bool) {
+	shade := imdraw.New(nil)
+	shade.Color = pixel.RGBA{R: 0.1, G: 0.1, B: 0.1, A: 0.75}
+	for y, matched := range rows {
+		if matched {
+			continue
+		}
+		area := imageRectToSelection(image.Rect(0, y, width, y+1), spriteCenter, height)
+		shade.Push(area.Min, area.Max)
+		shade.Rectangle(0)
+	}
+	shade.Draw(win)
+}
+
+// drawRowFilterWindow edits the document's row filter.
+func drawRowFilterWindow(doc *document, visible *bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 320, Y: 110}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Row Filter", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	imgui.InputTextWithHintV("##rowfilter", "changed helmet", &doc.rowFilter.query, 0, nil)
+	imgui.SameLine()
+	if imgui.Button("Clear") {
+		doc.rowFilter.query = ""
+	}
+	if rows := doc.filteredRows(); rows != nil {
+		matched := 0
+		for _, m := range rows {
+			if m {
+				matched++
+			}
+		}
+		imgui.Text(fmt.Sprintf("%d of %d rows match", matched, len(rows)))
+		if !doc.rowFilter.compared {
+			textDisabled("No baseline or git diff to find changed rows with")
+		}
+	} else {
+		textDisabled("Type words from row names or saved selections")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+)
+
+// rowStyle is a material row kept by Copy Row Style, to be pasted over other
+// rows a group of columns at a time.
+type rowStyle struct {
+	// name describes the row copied for dialogs
+	name   string
+	texels [][4]float64
+}
+
+// rowStyleSettings are the choices in the Paste Row Style dialog.
+type rowStyleSettings struct {
+	// allColumns pastes every column, instead of only the chosen groups
+	allColumns bool
+	// groups are the names of the schema column groups pasted. Names are
+	// kept rather than indexes so the choice carries over between schemas.
+	groups map[string]bool
+}
+
+func defaultRowStyleSettings() rowStyleSettings {
+	return rowStyleSettings{allColumns: true, groups: make(map[string]bool)}
+}
+
+// columns reports which of width columns are pasted with the groups of
+// schema, which may be nil. Every column is pasted if there are no groups.
+func (s rowStyleSettings) columns(schema *help.Schema, width int) []bool {
+	all := s.allColumns || schema == nil || len(schema.Groups) == 0
+	columns := make([]bool, width)
+	for x := range columns {
+		columns[x] = all
+	}
+	if all {
+		return columns
+	}
+	for _, group := range schema.Groups {
+		if !s.groups[group.Name] {
+			continue
+		}
+		for x := max(group.First, 0); x <= group.Last && x < width; x++ {
+			columns[x] = true
+		}
+	}
+	return columns
+}
+
+// copyRowStyle keeps the top row of the selection for Paste Row Style.
+func (d *document) copyRowStyle() (*rowStyle, error) {
+	raw, ok := rawImage(d.img)
+	if !ok {
+		return nil, fmt.Errorf("not an HDR image")
+	}
+	if d.selection == pixel.ZR || d.pasteImg != nil {
+		return nil, fmt.Errorf("nothing is selected")
+	}
+	rect, _ := d.editRect()
+	bounds := raw.Bounds()
+	y := rect.Min.Y
+	if y < bounds.Min.Y || y >= bounds.Max.Y {
+		return nil, fmt.Errorf("the selection is outside the image")
+	}
+	style := &rowStyle{name: fmt.Sprintf("row %d", y), texels: make([][4]float64, bounds.Dx())}
+	if names := d.names().Rows; y < len(names) && names[y] != "" {
+		style.name = fmt.Sprintf("row %d (%s)", y, names[y])
+	}
+	for x := range style.texels {
+		style.texels[x] = raw.RawAt(bounds.Min.X+x, y)
+	}
+	return style, nil
+}
+
+// pasteRowStyle writes the chosen columns of style over each row of the
+// selection, and of the rows edited together with it, leaving locked
+// channels alone. It returns the number of rows written to.
+func (d *document) pasteRowStyle(style *rowStyle, columns []bool, lock blend.Lock) (int, error) {
+	raw, ok := rawImage(d.img)
+	if !ok {
+		return 0, fmt.Errorf("not an HDR image")
+	}
+	if d.selection == pixel.ZR || d.pasteImg != nil {
+		return 0, fmt.Errorf("nothing is selected")
+	}
+	bounds := raw.Bounds()
+	rects, _ := d.editRects()
+	rows := make(map[int]bool)
+	for _, rect := range rects {
+		rect = rect.Intersect(bounds)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			rows[y] = true
+		}
+	}
+	for y := range rows {
+		for x, texel := range style.texels {
+			if x >= len(columns) || !columns[x] || x >= bounds.Dx() {
+				continue
+			}
+			p := image.Pt(bounds.Min.X+x, y)
+			raw.SetRaw(p.X, p.Y, lock.Pixel(blend.Replace, raw.RawAt(p.X, p.Y), texel))
+		}
+	}
+	return len(rows), nil
+}
+
+func pasteRowStylePrompt(settings *rowStyleSettings, style *rowStyle, schema *help.Schema, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.3 * viewport.Size().X,
+		Y: 0.4 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = pasteRowStyleDialog(settings, style, schema, windowSize, &responded)
+	return responded
+}
+
+func pasteRowStyleDialog(settings *rowStyleSettings, style *rowStyle, schema *help.Schema, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Paste Row Style", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Copy columns of %s to the selected rows", style.name))
+	imgui.Checkbox("All columns", &settings.allColumns)
+	var groups []help.ColumnGroup
+	if schema != nil {
+		groups = schema.Groups
+	}
+	if len(groups) == 0 {
+		textDisabled("The image's schema has no column groups to choose from")
+	} else if !settings.allColumns {
+		imgui.BeginChildV("##groups", imgui.Vec2{X: 0, Y: windowSize.Y * 0.5}, true, 0)
+		for i, group := range groups {
+			imgui.PushIDInt(i)
+			checked := settings.groups[group.Name]
+			if imgui.Checkbox(fmt.Sprintf("%s (columns %d-%d)", group.Name, group.First, group.Last), &checked) {
+				settings.groups[group.Name] = checked
+			}
+			if group.Description != "" && imgui.IsItemHovered() {
+				imgui.SetTooltip(group.Description)
+			}
+			imgui.PopID()
+		}
+		imgui.EndChild()
+	}
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.1,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .85,
+	})
+	if imgui.ButtonV("Paste", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
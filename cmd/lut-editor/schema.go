@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/changes"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+)
+
+// detectSchema activates the schema whose sizes fit the document's image.
+// When several fit the first is used, and the others can be chosen in View >
+// Schema.
+func (d *document) detectSchema(prt *app.Printer, schemas []help.Schema) {
+	d.setSchema(nil)
+	if d.img == nil {
+		return
+	}
+	matches := help.Match(schemas, d.img.Bounds().Size())
+	if len(matches) == 0 {
+		return
+	}
+	d.setSchema(&schemas[matches[0]])
+	if len(matches) > 1 {
+		prt.Infof("'%s' fits %d schemas, using %s. Choose another in View > Schema", d.displayName(), len(matches), d.schema.Name)
+	} else {
+		prt.Infof("Using the %s schema for '%s'", d.schema.Name, d.displayName())
+	}
+}
+
+// reloadSchemas loads the schemas again, after they were changed, and points
+// docs at the new ones.
+func reloadSchemas(prt *app.Printer, docs []*document) []help.Schema {
+	schemas, err := help.LoadAll(help.Paths())
+	if err != nil {
+		prt.Errorf("Loading schemas: %v", err)
+	}
+	for _, d := range docs {
+		if !d.empty() {
+			d.reloadSchema(prt, schemas)
+		}
+	}
+	return schemas
+}
+
+// reloadSchema points the document at the schema of the same name in
+// schemas, which replace the ones it was using, or detects its schema again
+// if there is none.
+func (d *document) reloadSchema(prt *app.Printer, schemas []help.Schema) {
+	if d.schema == nil {
+		d.detectSchema(prt, schemas)
+		return
+	}
+	for i := range schemas {
+		if schemas[i].Name == d.schema.Name && schemas[i].Matches(d.img.Bounds().Size()) {
+			d.setSchema(&schemas[i])
+			return
+		}
+	}
+	d.detectSchema(prt, schemas)
+}
+
+func (d *document) setSchema(schema *help.Schema) {
+	d.schema, d.violationsPic, d.violations, d.hiddenGroups = schema, nil, nil, nil
+}
+
+// schemaViolations returns the values outside the limits of the document's
+// schema. They are cached until the picture changes.
+func (d *document) schemaViolations() []help.Violation {
+	if d.schema == nil || d.pic == nil {
+		return nil
+	}
+	if d.pic == d.violationsPic {
+		return d.violations
+	}
+	d.violations, d.violationsPic = nil, d.pic
+	if raw, ok := rawImage(d.img); ok {
+		d.violations = d.schema.Validate(raw)
+	}
+	return d.violations
+}
+
+// keepRelations returns a function that corrects the texels changed since
+// keepRelations was called that break relations the document's schema
+// enforces, leaving channels in lock alone.
+func (d *document) keepRelations(lock blend.Lock) (apply func()) {
+	raw, ok := rawImage(d.img)
+	if !ok || d.schema == nil || !d.schema.HasRelations(true) {
+		return func() {}
+	}
+	bounds := raw.Bounds()
+	before := make([][4]float64, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			before = append(before, raw.RawAt(x, y))
+		}
+	}
+	return func() {
+		var changed []image.Point
+		i := 0
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if raw.RawAt(x, y) != before[i] {
+					changed = append(changed, image.Pt(x, y))
+				}
+				i++
+			}
+		}
+		d.schema.EnforceRelations(raw, changed, lock)
+	}
+}
+
+// violatingTexels returns the texels with a value outside the schema's
+// limits, in image coordinates.
+func violatingTexels(violations []help.Violation) []image.Point {
+	var points []image.Point
+	for _, v := range violations {
+		point := image.Pt(v.X, v.Y)
+		if len(points) == 0 || points[len(points)-1] != point {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// names labels the document's rows and columns, with the names set in the
+// Project window taking precedence over those from the schema.
+func (d *document) names() changes.Names {
+	names := changes.Names{Rows: d.rowNames, Columns: d.columnNames}
+	if d.schema == nil {
+		return names
+	}
+	names.Rows = withSchemaNames(names.Rows, len(d.schema.Rows), func(i int) string { return d.schema.Rows[i].Name })
+	names.Columns = withSchemaNames(names.Columns, len(d.schema.Columns), func(i int) string { return d.schema.Columns[i].Name })
+	return names
+}
+
+// withSchemaNames fills the empty entries of names with the schema's name
+// for the same row or column.
+func withSchemaNames(names []string, count int, schemaName func(int) string) []string {
+	filled := make([]string, max(len(names), count))
+	copy(filled, names)
+	for i := 0; i < count; i++ {
+		if filled[i] == "" {
+			filled[i] = schemaName(i)
+		}
+	}
+	return filled
+}
+
+// schemaHint returns the schema's name for row or column i, for showing in
+// empty name inputs.
+func schemaHint(schema *help.Schema, row bool, i int) string {
+	if schema == nil {
+		return ""
+	}
+	if row {
+		if r := schema.Row(i); r != nil {
+			return r.Name
+		}
+	} else if c := schema.Column(i); c != nil {
+		return c.Name
+	}
+	return ""
+}
+
+// texelTooltip describes texel x, y of the image with its schema: the names
+// and descriptions of its row and column, and the value of each channel with
+// what it means and its valid values. It returns "" without a schema.
+func (d *document) texelTooltip(x, y int) string {
+	if d.schema == nil || d.img == nil {
+		return ""
+	}
+	raw, ok := rawImage(d.img)
+	bounds := d.img.Bounds()
+	if !ok || x < 0 || y < 0 || x >= bounds.Dx() || y >= bounds.Dy() {
+		return ""
+	}
+	names := d.names()
+	lines := []string{fmt.Sprintf("Row %d: %s", y, names.Row(y))}
+	if row := d.schema.Row(y); row != nil && row.Description != "" {
+		lines[0] += " - " + row.Description
+	}
+	lines = append(lines, fmt.Sprintf("Column %d: %s", x, names.Column(x)))
+	if column := d.schema.Column(x); column != nil && column.Description != "" {
+		lines[1] += " - " + column.Description
+	}
+	value := raw.RawAt(bounds.Min.X+x, bounds.Min.Y+y)
+	for i, v := range value {
+		line := fmt.Sprintf("%c: %g", "RGBA"[i], float32(v))
+		if channel := d.schema.Channel(x, i); channel != nil {
+			line += "  " + channel.Name
+			if r := channel.Range(); r != "" {
+				line += fmt.Sprintf(", valid %s", r)
+			}
+			if !channel.Valid(v) {
+				line += " (out of range)"
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// drawTexelLabels writes the name of each row to its left and of each column
+// below it, from the schema or the names given in the editor. Names are left
+// out where they don't fit at the current zoom. Labels are drawn behind the
+// editor's windows.
+func drawTexelLabels(cam pixel.Matrix, windowHeight, camZoom float64, spriteCenter pixel.Vec, size image.Point, names changes.Names) {
+	drawList := imgui.BackgroundDrawList()
+	color := imgui.PackedColorFromVec4(imgui.Vec4{X: 0.9, Y: 0.9, Z: 0.9, W: 1})
+	lineHeight := imgui.TextLineHeight()
+	if camZoom >= float64(lineHeight) {
+		for y := 0; y < size.Y; y++ {
+			area := imageRectToSelection(image.Rect(0, y, 1, y+1), spriteCenter, size.Y)
+			left := cam.Project(pixel.V(area.Min.X, area.Center().Y))
+			label := names.Row(y)
+			pos := imgui.Vec2{
+				X: float32(left.X) - imgui.CalcTextSize(label, false, 0).X - 6,
+				Y: float32(windowHeight-left.Y) - lineHeight/2,
+			}
+			drawList.AddText(pos, color, label)
+		}
+	}
+	for x := 0; x < size.X; x++ {
+		label := names.Column(x)
+		width := imgui.CalcTextSize(label, false, 0).X
+		if float64(width) > camZoom {
+			continue
+		}
+		area := imageRectToSelection(image.Rect(x, 0, x+1, size.Y), spriteCenter, size.Y)
+		bottom := cam.Project(pixel.V(area.Center().X, area.Min.Y))
+		pos := imgui.Vec2{
+			X: float32(bottom.X) - width/2,
+			Y: float32(windowHeight-bottom.Y) + 3,
+		}
+		drawList.AddText(pos, color, label)
+	}
+}
+
+// drawSchemaInfo adds the document's schema to the File Info window.
+func drawSchemaInfo(doc *document) {
+	if doc.schema == nil {
+		textDisabled("Schema: none fits this image size")
+		return
+	}
+	imgui.Text(fmt.Sprintf("Schema: %s", doc.schema.Name))
+	if doc.schema.Description != "" {
+		imgui.Text(doc.schema.Description)
+	}
+	textDisabled(fmt.Sprintf("From %s", doc.schema.Source))
+	outside := 0
+	var relations []*help.Relation
+	broken := make(map[*help.Relation]int)
+	for _, v := range doc.schemaViolations() {
+		if v.Relation == nil {
+			outside++
+			continue
+		}
+		if broken[v.Relation] == 0 {
+			relations = append(relations, v.Relation)
+		}
+		broken[v.Relation]++
+	}
+	if outside > 0 {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+		imgui.Text(fmt.Sprintf("%d values outside the schema's limits", outside))
+		imgui.PopStyleColor()
+	} else {
+		imgui.Text("All values within the schema's limits")
+	}
+	for _, relation := range relations {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+		imgui.Text(fmt.Sprintf("%d texels break %s", broken[relation], relation))
+		imgui.PopStyleColor()
+	}
+}
+
+// showSchemaMenu lists the loaded schemas, those fitting the image first. It
+// returns the index of the chosen schema, or -1 for none.
+func showSchemaMenu(schemas []help.Schema, size image.Point, current *help.Schema) (chosen int, ok bool) {
+	if imgui.MenuItemV("None", "", current == nil, true) {
+		return -1, true
+	}
+	for _, fits := range []bool{true, false} {
+		separated := false
+		for i := range schemas {
+			if schemas[i].Matches(size) != fits {
+				continue
+			}
+			if !fits && !separated {
+				imgui.Separator()
+				textDisabled("Other sizes")
+				separated = true
+			}
+			if imgui.MenuItemV(fmt.Sprintf("%s##schema%d", schemas[i].Name, i), "", current == &schemas[i], true) {
+				chosen, ok = i, true
+			}
+		}
+	}
+	if len(schemas) == 0 {
+		textDisabled("No schemas loaded")
+	}
+	return
+}
+
+// snappedSummary describes the values Snap to Schema Values changed, counted
+// by column and channel, in the order they were first changed.
+func snappedSummary(schema *help.Schema, snapped []help.Snapped) string {
+	if len(snapped) == 0 {
+		return "all values are already valid"
+	}
+	type key struct{ x, channel int }
+	var order []key
+	counts := make(map[key]int)
+	for _, s := range snapped {
+		k := key{s.X, s.Channel}
+		if counts[k] == 0 {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+	parts := make([]string, len(order))
+	for i, k := range order {
+		parts[i] = fmt.Sprintf("%d in %s %s", counts[k], schema.Column(k.x).Name, schema.Channel(k.x, k.channel).Name)
+	}
+	return fmt.Sprintf("snapped %d values: %s", len(snapped), strings.Join(parts, ", "))
+}
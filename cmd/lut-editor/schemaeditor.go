@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+)
+
+// schemaEditor is the schema being written in the Schema Editor window.
+type schemaEditor struct {
+	schema help.Schema
+	// replacing is the name of the schema saving replaces in the file, which
+	// is the name it was opened with
+	replacing string
+	// column is the column whose channels are being edited, or -1
+	column int
+	// values holds the text of each channel's list of values while its column
+	// is being edited
+	values [4]string
+}
+
+func newSchemaEditor() *schemaEditor {
+	e := &schemaEditor{}
+	e.open(help.Schema{Name: "New schema"})
+	return e
+}
+
+// open starts editing a copy of s.
+func (e *schemaEditor) open(s help.Schema) {
+	e.schema = s.Clone()
+	e.replacing = s.Name
+	e.selectColumn(-1)
+}
+
+// fromImage starts a schema for images the size of doc's, with its rows and
+// columns named as they are in the editor.
+func (e *schemaEditor) fromImage(doc *document) {
+	size := doc.img.Bounds().Size()
+	s := help.Schema{
+		Name:    strings.TrimSuffix(filepath.Base(doc.displayName()), filepath.Ext(doc.displayName())),
+		Width:   help.MinMax{Min: size.X, Max: size.X},
+		Height:  help.MinMax{Min: size.Y, Max: size.Y},
+		Rows:    make([]help.Row, size.Y),
+		Columns: make([]help.Column, size.X),
+	}
+	names := doc.names()
+	for y := range s.Rows {
+		s.Rows[y].Name = names.Row(y)
+	}
+	for x := range s.Columns {
+		s.Columns[x].Name = names.Column(x)
+	}
+	e.open(s)
+}
+
+func (e *schemaEditor) selectColumn(x int) {
+	e.column = x
+	e.values = [4]string{}
+	if x < 0 || x >= len(e.schema.Columns) {
+		e.column = -1
+		return
+	}
+	for i, channel := range e.schema.Columns[x].Channels {
+		values := make([]string, len(channel.Values))
+		for j, v := range channel.Values {
+			values[j] = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		e.values[i] = strings.Join(values, ", ")
+	}
+}
+
+// path returns the file the schema is saved to: the user schema file it came
+// from, or a new one in the user schema folder named after it. Schemas from
+// the community pack are saved to a file of their own, as updating the pack
+// replaces it.
+func (e *schemaEditor) path() (string, error) {
+	dir, err := help.UserDir()
+	if err != nil {
+		return "", err
+	}
+	if e.schema.Source != "" && filepath.Dir(e.schema.Source) == dir && filepath.Base(e.schema.Source) != help.PackFileName {
+		return e.schema.Source, nil
+	}
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(e.schema.Name))
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// save writes the schema to the user schema folder.
+func (e *schemaEditor) save(prt *app.Printer) bool {
+	path, err := e.path()
+	if err != nil {
+		prt.Errorf("failed to save schema: %v", err)
+		return false
+	}
+	if err := help.Save(path, e.schema, e.replacing); err != nil {
+		prt.Errorf("failed to save schema: %v", err)
+		return false
+	}
+	e.schema.Source, e.replacing = path, e.schema.Name
+	prt.Infof("Saved the %s schema to '%s'", e.schema.Name, path)
+	return true
+}
+
+// optionalFloat edits a value that may be left unset, with a checkbox to set
+// it.
+func optionalFloat(label string, value **float64) {
+	set := *value != nil
+	if imgui.Checkbox("##set"+label, &set) {
+		if set {
+			*value = new(float64)
+		} else {
+			*value = nil
+		}
+	}
+	imgui.SameLine()
+	if *value == nil {
+		textDisabled(label)
+		return
+	}
+	v := float32(**value)
+	imgui.SetNextItemWidth(120)
+	if imgui.DragFloatV(label, &v, 0.01, 0.0, 0.0, "%g", imgui.SliderFlagsNone) {
+		**value = float64(v)
+	}
+}
+
+// parseValues reads a comma separated list of numbers, skipping anything
+// that isn't one.
+func parseValues(text string) []float64 {
+	var values []float64
+	for _, field := range strings.Split(text, ",") {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// drawSchemaEditorWindow edits a schema's name, sizes, rows, columns and
+// channels, and saves it to the user schema folder. It reports whether the
+// schema was saved, so the schemas can be reloaded.
+func drawSchemaEditorWindow(prt *app.Printer, e *schemaEditor, schemas []help.Schema, doc *document, visible *bool) (saved bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 480, Y: 560}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Schema Editor", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	if imgui.Button("New") {
+		e.open(help.Schema{Name: "New schema"})
+	}
+	imgui.SameLine()
+	if imgui.Button("Open...") {
+		imgui.OpenPopup("openSchema")
+	}
+	if imgui.BeginPopup("openSchema") {
+		for i := range schemas {
+			if imgui.MenuItem(fmt.Sprintf("%s##schema%d", schemas[i].Name, i)) {
+				e.open(schemas[i])
+			}
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip(schemas[i].Source)
+			}
+		}
+		imgui.EndPopup()
+	}
+	imgui.SameLine()
+	if imgui.Button("From Image") && doc.img != nil {
+		e.fromImage(doc)
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Start a schema for images the size of the current one, named as in the Project window")
+	}
+	imgui.SameLine()
+	if imgui.Button("Save") {
+		saved = e.save(prt)
+	}
+	if path, err := e.path(); err == nil {
+		textDisabled(fmt.Sprintf("Saves to %s", path))
+	}
+	imgui.Separator()
+
+	s := &e.schema
+	imgui.InputText("Name", &s.Name)
+	imgui.InputText("Description", &s.Description)
+	for _, size := range []struct {
+		label  string
+		limits *help.MinMax
+	}{{"Width", &s.Width}, {"Height", &s.Height}} {
+		minimum, maximum := int32(size.limits.Min), int32(size.limits.Max)
+		imgui.SetNextItemWidth(120)
+		if imgui.InputInt(fmt.Sprintf("Min %s", size.label), &minimum) {
+			size.limits.Min = max(int(minimum), 0)
+		}
+		imgui.SameLine()
+		imgui.SetNextItemWidth(120)
+		if imgui.InputInt(fmt.Sprintf("Max %s (0 for any)", size.label), &maximum) {
+			size.limits.Max = max(int(maximum), 0)
+		}
+	}
+
+	if imgui.CollapsingHeader(fmt.Sprintf("Rows (%d)###rows", len(s.Rows))) {
+		for y := range s.Rows {
+			imgui.PushIDInt(y)
+			imgui.SetNextItemWidth(140)
+			imgui.InputTextWithHintV("##name", fmt.Sprintf("Row %d", y), &s.Rows[y].Name, 0, nil)
+			imgui.SameLine()
+			imgui.InputTextWithHintV("##description", "Description", &s.Rows[y].Description, 0, nil)
+			imgui.PopID()
+		}
+		if imgui.Button("Add Row") {
+			s.Rows = append(s.Rows, help.Row{})
+		}
+		imgui.SameLine()
+		if imgui.Button("Remove Last Row") && len(s.Rows) > 0 {
+			s.Rows = s.Rows[:len(s.Rows)-1]
+		}
+	}
+
+	if imgui.CollapsingHeader(fmt.Sprintf("Columns (%d)###columns", len(s.Columns))) {
+		for x := range s.Columns {
+			label := s.Columns[x].Name
+			if label == "" {
+				label = fmt.Sprintf("Column %d", x)
+			}
+			if imgui.SelectableV(fmt.Sprintf("%d: %s##column%d", x, label, x), x == e.column, 0, imgui.Vec2{}) {
+				e.selectColumn(x)
+			}
+		}
+		if imgui.Button("Add Column") {
+			s.Columns = append(s.Columns, help.Column{})
+			e.selectColumn(len(s.Columns) - 1)
+		}
+		imgui.SameLine()
+		if imgui.Button("Remove Last Column") && len(s.Columns) > 0 {
+			s.Columns = s.Columns[:len(s.Columns)-1]
+			e.selectColumn(min(e.column, len(s.Columns)-1))
+		}
+		if e.column >= 0 {
+			drawColumnEditor(e)
+		}
+	}
+	return saved
+}
+
+// drawColumnEditor edits the selected column and its channels.
+func drawColumnEditor(e *schemaEditor) {
+	column := &e.schema.Columns[e.column]
+	imgui.Separator()
+	imgui.Text(fmt.Sprintf("Column %d", e.column))
+	imgui.InputText("Column Name", &column.Name)
+	imgui.InputText("Column Description", &column.Description)
+	imgui.Checkbox("Holds a color in R, G and B", &column.Color)
+	used := len(column.Channels)
+	for i, name := range graphChannelNames {
+		imgui.PushIDInt(i)
+		if i >= used {
+			if imgui.Button(fmt.Sprintf("Describe %s", name)) {
+				for len(column.Channels) <= i {
+					column.Channels = append(column.Channels, help.Channel{})
+				}
+			}
+			imgui.PopID()
+			break
+		}
+		channel := &column.Channels[i]
+		if imgui.TreeNode(fmt.Sprintf("%s: %s###channel", name, channel.Name)) {
+			imgui.InputText("Name", &channel.Name)
+			imgui.InputText("Description", &channel.Description)
+			optionalFloat("Min", &channel.Min)
+			optionalFloat("Max", &channel.Max)
+			optionalFloat("Default", &channel.Default)
+			if imgui.InputTextWithHintV("Values", "Any, or a list such as 0, 1, 2", &e.values[i], 0, nil) {
+				channel.Values = parseValues(e.values[i])
+			}
+			if i == used-1 && imgui.Button("Stop Describing") {
+				column.Channels = column.Channels[:i]
+				e.values[i] = ""
+			}
+			imgui.TreePop()
+		}
+		imgui.PopID()
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/help"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+// downloadSchemaPack returns the contents of the schema pack at url, failing
+// if it is larger than help.MaxPackSize.
+func downloadSchemaPack(url string, task *types.BackgroundStatus) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %v", resp.Status)
+	}
+	if resp.ContentLength > help.MaxPackSize {
+		return nil, fmt.Errorf("schema pack is larger than %d MiB", help.MaxPackSize>>20)
+	}
+	body := &progressReader{r: io.LimitReader(resp.Body, help.MaxPackSize+1), task: task, total: int(resp.ContentLength)}
+	return io.ReadAll(body)
+}
+
+// updateSchemas downloads the schema pack at url and installs it in the user
+// schema folder once every schema in it checks out, sending the number of
+// schemas installed to the main loop so it can reload them.
+func updateSchemas(prt *app.Printer, url string, installed chan<- int, task *types.BackgroundStatus) {
+	task.Cancellable = true
+	data, err := downloadSchemaPack(url, task)
+	if errors.Is(err, errLoadCancelled) {
+		prt.Infof("Cancelled updating schemas")
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("Failed to download schemas from '%s': %v", url, err)
+		task.OnError(err)
+		return
+	}
+	schemas, err := help.Install(data)
+	if err != nil {
+		prt.Errorf("Schemas from '%s' were not installed: %v", url, err)
+		task.OnError(err)
+		return
+	}
+	prt.Infof("Installed %d schemas from '%s' to '%s'", len(schemas), url, schemas[0].Source)
+	task.Report(fmt.Sprintf("%d schemas installed", len(schemas)))
+	installed <- len(schemas)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/changes"
+	"github.com/ryanjsims/hd2-lut-editor/filter"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+)
+
+// growSelection moves each edge of the selection out by n texels, or in if n
+// is negative, keeping it within the image.
+func (d *document) growSelection(n int) error {
+	if d.selection == pixel.ZR || d.pasteImg != nil {
+		return fmt.Errorf("nothing is selected")
+	}
+	rect, _ := d.editRect()
+	if n < 0 && (-2*n >= rect.Dx() || -2*n >= rect.Dy()) {
+		return fmt.Errorf("the %dx%d selection is too small to shrink by %d", rect.Dx(), rect.Dy(), -n)
+	}
+	rect = rect.Inset(-n).Intersect(d.img.Bounds())
+	d.selection = imageRectToSelection(rect, d.sprite.Frame().Center(), d.img.Bounds().Dy())
+	return nil
+}
+
+// selectChanged selects the texels of the image that differ from the
+// baseline. Selections are rectangles, so the rectangle around them is
+// selected, and the texels in it that haven't changed are locked, leaving
+// only the changed texels to be edited. It returns how many texels changed,
+// and how many it locked.
+func (d *document) selectChanged() (changed, locked int, err error) {
+	if d.pasteImg != nil {
+		return 0, 0, fmt.Errorf("put down the pasted texels first")
+	}
+	base := d.baselineRaw()
+	if base == nil {
+		return 0, 0, fmt.Errorf("baseline '%s' could not be loaded", d.baseline)
+	}
+	raw, ok := rawImage(d.flattened())
+	if !ok {
+		return 0, 0, fmt.Errorf("not an HDR image")
+	}
+	diff, err := changes.Diff(base, raw)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(diff) == 0 {
+		return 0, 0, fmt.Errorf("no texels differ from the baseline")
+	}
+	points := make(map[image.Point]bool, len(diff))
+	var rect image.Rectangle
+	for _, c := range diff {
+		p := image.Pt(c.X, c.Y)
+		points[p] = true
+		rect = rect.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+	}
+	rect = rect.Add(d.img.Bounds().Min)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			p := image.Pt(x, y)
+			if !points[p.Sub(d.img.Bounds().Min)] && !d.locked.Contains(p) {
+				d.locked.Add(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+				locked++
+			}
+		}
+	}
+	d.selection = imageRectToSelection(rect, d.sprite.Frame().Center(), d.img.Bounds().Dy())
+	return len(diff), locked, nil
+}
+
+// featherEdits returns a function that fades an edit made to the selection
+// since featherEdits was called out towards the selection's edges, over
+// radius texels. Edits to the whole image are left as they are.
+func (d *document) featherEdits(radius int) (apply func()) {
+	raw, ok := rawImage(d.img)
+	if !ok || radius <= 0 || d.selection == pixel.ZR || d.pasteImg != nil {
+		return func() {}
+	}
+	rect, _ := d.editRect()
+	return filter.Feather(raw, rect, radius)
+}
+
+func showSelectMenu(img image.Image, selection pixel.Rect, feather int32, multiRows int, hasBaseline bool) types.MenuResponse {
+	response := types.MenuResponseNone
+	selected := img != nil && selection != pixel.ZR && selection.Area() > 0
+	if imgui.MenuItemV("Changed Pixels", "", false, img != nil && hasBaseline) {
+		response = types.MenuResponseSelectChanged
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Select the texels that differ from the baseline, locking any unchanged texels between them")
+	}
+	if imgui.MenuItemV("Grow...", "", false, selected) {
+		response = types.MenuResponseSelectGrow
+	}
+	if imgui.MenuItemV("Shrink...", "", false, selected) {
+		response = types.MenuResponseSelectShrink
+	}
+	label := "Feather..."
+	if feather > 0 {
+		label = fmt.Sprintf("Feather (%d px)...", feather)
+	}
+	if imgui.MenuItemV(label, "", feather > 0, true) {
+		response = types.MenuResponseSelectFeather
+	}
+	imgui.Separator()
+	if imgui.MenuItemV("Edit Rows Together", "", false, selected) {
+		response = types.MenuResponseSelectAddMultiRows
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Edits to these rows are made to the same columns of all of them")
+	}
+	if imgui.MenuItemV("Stop Editing Rows Together", "", false, selected && multiRows > 0) {
+		response = types.MenuResponseSelectRemoveMultiRows
+	}
+	label = "Clear Rows Edited Together"
+	if multiRows > 0 {
+		label = fmt.Sprintf("Clear Rows Edited Together (%d)", multiRows)
+	}
+	if imgui.MenuItemV(label, "", false, multiRows > 0) {
+		response = types.MenuResponseSelectClearMultiRows
+	}
+	return response
+}
+
+func selectionAmount(title, text string, amount *int32, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.2 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = selectionAmountDialog(title, text, amount, windowSize, &responded)
+	return responded
+}
+
+func selectionAmountDialog(title, text string, amount *int32, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV(title, nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(text)
+	imgui.InputInt("Pixels", amount)
+	*amount = max(*amount, 0)
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("OK", buttonSize) {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// snapMode chooses the grid that moved and pasted pixels snap to.
+type snapMode int
+
+const (
+	snapOff       snapMode = 0
+	snapSelection snapMode = 1
+	snapSchema    snapMode = 2
+	snapModeCount          = 3
+)
+
+var snapModeNames = []string{"Off", "Selection Size", "Schema Blocks"}
+
+func (m snapMode) String() string {
+	return snapModeNames[m]
+}
+
+// snapStep returns the spacing in texels of the grid the selection snaps to
+// while its pixels are moved.
+func (d *document) snapStep(mode snapMode) image.Point {
+	switch mode {
+	case snapSelection:
+		rect := selectionToImageRect(d.selection, d.sprite.Frame().Center(), d.img.Bounds().Dy())
+		return image.Pt(max(rect.Dx(), 1), max(rect.Dy(), 1))
+	case snapSchema:
+		if d.schema != nil {
+			return d.schema.Snap()
+		}
+	}
+	return image.Pt(1, 1)
+}
+
+// snapOffset adjusts offset, which moves selection in world coordinates, so
+// the top left of the moved selection lands on a multiple of step in image
+// coordinates.
+func snapOffset(selection pixel.Rect, offset, center pixel.Vec, height int, step image.Point) pixel.Vec {
+	moved := selectionToImageRect(selection.Moved(offset), center, height)
+	nearest := func(v, step int) int {
+		return int(math.Round(float64(v)/float64(step))) * step
+	}
+	dx := nearest(moved.Min.X, step.X) - moved.Min.X
+	dy := nearest(moved.Min.Y, step.Y) - moved.Min.Y
+	// Image y runs down, world y up
+	return offset.Add(pixel.V(float64(dx), -float64(dy)))
+}
+
+// drawSnapCombo adds the snap choice to the Tool window.
+func drawSnapCombo(mode *snapMode) {
+	if imgui.BeginCombo("Snap", mode.String()) {
+		for m := snapMode(0); m < snapModeCount; m++ {
+			if imgui.SelectableV(m.String(), m == *mode, 0, imgui.Vec2{}) {
+				*mode = m
+			}
+		}
+		imgui.EndCombo()
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/app"
+	"github.com/ryanjsims/hd2-lut-editor/editor"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+	"github.com/ryanjsims/hd2-lut-editor/types"
+	"github.com/ryanjsims/hd2-lut-editor/variants"
+	"github.com/sqweek/dialog"
+)
+
+// variantFileName returns the file a variant of fileName is written to in
+// folder. Documents without a file of their own are written as EXR.
+func variantFileName(folder, fileName, variant string) string {
+	ext := filepath.Ext(fileName)
+	base := strings.TrimSuffix(filepath.Base(fileName), ext)
+	if ext != ".dds" && ext != ".exr" {
+		base, ext = "lut", ".exr"
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, variant)
+	return filepath.Join(folder, base+"_"+name+ext)
+}
+
+// generateVariants asks for a CSV of per-row overrides and a folder, then
+// writes a copy of img to the folder for each variant in the CSV with its
+// overrides applied. img should be a copy the editor no longer changes.
+func generateVariants(prt *app.Printer, img image.Image, attrs []openexr.Attribute, fileName string, conv conversionSettings, startDir string, task *types.BackgroundStatus) {
+	csvFileName, err := dialog.File().Title("Select variant overrides...").Filter("CSV files", "csv").SetStartDir(startDir).Load()
+	if err == dialog.ErrCancelled {
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("%v", err)
+		task.OnCancel()
+		return
+	}
+	in, err := os.Open(csvFileName)
+	if err != nil {
+		prt.Errorf("generate variants: %v", err)
+		task.OnError(err)
+		return
+	}
+	list, err := variants.Parse(in)
+	in.Close()
+	if err != nil {
+		prt.Errorf("generate variants: failed to read '%s': %v", csvFileName, err)
+		task.OnError(err)
+		return
+	}
+	folder, err := dialog.Directory().Title("Select folder for variants...").SetStartDir(filepath.Dir(csvFileName)).Browse()
+	if err == dialog.ErrCancelled {
+		task.OnCancel()
+		return
+	} else if err != nil {
+		prt.Errorf("generate variants: failed to get directory: %v", err)
+		task.OnCancel()
+		return
+	}
+
+	failed := 0
+	for i := range list {
+		variant := &list[i]
+		outFileName := variantFileName(folder, fileName, variant.Name)
+		err := writeVariant(img, attrs, conv, variant, outFileName)
+		if err != nil {
+			prt.Errorf("generate variants: failed to write '%s': %v", outFileName, err)
+			failed++
+		}
+		task.OnProgress(i+1, len(list), err)
+	}
+	task.OnComplete(len(list)-failed, failed, len(list))
+	prt.Infof("Generated %d of %d variants in '%s'", len(list)-failed, len(list), folder)
+}
+
+func writeVariant(img image.Image, attrs []openexr.Attribute, conv conversionSettings, variant *variants.Variant, fileName string) error {
+	out := editor.Copy(img, img.Bounds())
+	raw, ok := rawImage(out)
+	if out == nil || !ok {
+		return fmt.Errorf("not an HDR image")
+	}
+	variant.Apply(raw)
+	converted, _, err := convertForSave(out, conv)
+	if err != nil {
+		return err
+	}
+	return writeImageFile(converted, attrs, fileName, conv.ddsOptions())
+}
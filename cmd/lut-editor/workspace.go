@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+	"github.com/inkyblackness/imgui-go/v4"
+	"github.com/ryanjsims/hd2-lut-editor/linked"
+)
+
+// workspace links a material LUT document to a pattern LUT document whose
+// texels refer to the material LUT's rows.
+type workspace struct {
+	material *document
+	pattern  *document
+	link     linked.Link
+	// references caches the pattern texels referring to the selected material
+	// rows, until the selection or the pattern's picture changes
+	referenceRows [2]int
+	referencePic  *pixel.PictureData
+	references    []image.Point
+	// dangling caches the number of pattern texels referring to missing rows
+	danglingPic *pixel.PictureData
+	dangling    int
+}
+
+// linkSettings are the choices in the Link Pattern LUT dialog.
+type linkSettings struct {
+	pattern  int
+	channel  int
+	encoding int
+}
+
+func (w *workspace) has(doc *document) bool {
+	return doc == w.material || doc == w.pattern
+}
+
+// selectedRows returns the material rows covered by the material document's
+// selection.
+func (w *workspace) selectedRows() (first, last int, ok bool) {
+	if w.material.selection == pixel.ZR || w.material.pasteImg != nil {
+		return 0, 0, false
+	}
+	rect, _ := w.material.editRect()
+	return rect.Min.Y, rect.Max.Y, true
+}
+
+// selectedReferences returns the pattern texels referring to the selected
+// material rows.
+func (w *workspace) selectedReferences() []image.Point {
+	first, last, ok := w.selectedRows()
+	if !ok {
+		return nil
+	}
+	rows := [2]int{first, last}
+	if rows == w.referenceRows && w.pattern.pic == w.referencePic {
+		return w.references
+	}
+	raw, ok := rawImage(w.pattern.img)
+	if !ok {
+		return nil
+	}
+	w.link.Rows = w.material.img.Bounds().Dy()
+	w.references = w.link.References(raw, first, last)
+	w.referenceRows, w.referencePic = rows, w.pattern.pic
+	return w.references
+}
+
+func (w *workspace) danglingReferences() int {
+	if w.pattern.pic == w.danglingPic && w.danglingPic != nil {
+		return w.dangling
+	}
+	raw, ok := rawImage(w.pattern.img)
+	if !ok {
+		return 0
+	}
+	w.link.Rows = w.material.img.Bounds().Dy()
+	w.dangling = w.link.Dangling(raw)
+	w.danglingPic = w.pattern.pic
+	return w.dangling
+}
+
+// swapRows exchanges two material rows, rewriting the pattern so every texel
+// keeps its material. It returns the number of pattern texels rewritten.
+func (w *workspace) swapRows(a, b int) (int, error) {
+	material, ok := rawImage(w.material.img)
+	if !ok {
+		return 0, fmt.Errorf("material LUT is not an HDR image")
+	}
+	pattern, ok := rawImage(w.pattern.img)
+	if !ok {
+		return 0, fmt.Errorf("pattern LUT is not an HDR image")
+	}
+	w.link.Rows = w.material.img.Bounds().Dy()
+	w.referencePic, w.danglingPic = nil, nil
+	return w.link.SwapRows(material, pattern, a, b)
+}
+
+// drawReferences highlights pattern texels, given in image coordinates.
+func drawReferences(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, height int, points []image.Point) {
+	drawTexelHighlights(win, camZoom, spriteCenter, height, points, pixel.RGBA{R: 0.9, G: 0.7, B: 0.1})
+}
+
+// drawViolations highlights texels with values outside the schema's limits.
+func drawViolations(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, height int, points []image.Point) {
+	drawTexelHighlights(win, camZoom, spriteCenter, height, points, pixel.RGBA{R: 0.95, G: 0.2, B: 0.2})
+}
+
+// drawTexelHighlights fills and outlines texels, given in image coordinates,
+// in color. Its alpha is ignored.
+func drawTexelHighlights(win *opengl.Window, camZoom float64, spriteCenter pixel.Vec, height int, points []image.Point, color pixel.RGBA) {
+	if len(points) == 0 {
+		return
+	}
+	highlight := imdraw.New(nil)
+	color.A = 0.35
+	highlight.Color = color
+	for _, point := range points {
+		footprint := brushFootprint(spriteCenter, point.X, height-point.Y-1)
+		highlight.Push(footprint.Min)
+		highlight.Push(footprint.Max)
+		highlight.Rectangle(0)
+	}
+	color.A = 0.9
+	highlight.Color = color
+	for _, point := range points {
+		footprint := brushFootprint(spriteCenter, point.X, height-point.Y-1)
+		highlight.Push(footprint.Min)
+		highlight.Push(footprint.Max)
+		highlight.Rectangle(1.0 / camZoom)
+	}
+	highlight.Draw(win)
+}
+
+// drawWorkspaceWindow shows the linked LUTs and the pattern texels using the
+// selected material rows. It reports whether Swap Rows was pressed.
+func drawWorkspaceWindow(w *workspace, swap *[2]int32, visible *bool) (swapping bool) {
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: 360, Y: 220}, imgui.ConditionFirstUseEver)
+	imgui.BeginV("Linked LUTs", visible, imgui.WindowFlagsNoCollapse)
+	defer imgui.End()
+
+	imgui.Text(fmt.Sprintf("Material: %s", w.material.displayName()))
+	imgui.Text(fmt.Sprintf("Pattern: %s (%s, %s)", w.pattern.displayName(), graphChannelNames[w.link.Channel], w.link.Encoding))
+	if dangling := w.danglingReferences(); dangling > 0 {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1})
+		imgui.Text(fmt.Sprintf("%d pattern texels refer to rows the material LUT doesn't have", dangling))
+		imgui.PopStyleColor()
+	}
+	imgui.Separator()
+
+	if first, last, ok := w.selectedRows(); ok {
+		count := len(w.selectedReferences())
+		if last-first == 1 {
+			imgui.Text(fmt.Sprintf("Row %d is used by %d pattern texels", first, count))
+		} else {
+			imgui.Text(fmt.Sprintf("Rows %d-%d are used by %d pattern texels", first, last-1, count))
+		}
+		textDisabled("Highlighted in the pattern's tab")
+	} else {
+		textDisabled("Select material rows to find them in the pattern")
+	}
+	imgui.Separator()
+
+	imgui.InputInt("Row A", &swap[0])
+	imgui.InputInt("Row B", &swap[1])
+	swapping = imgui.Button("Swap Rows")
+	return
+}
+
+func linkLUTs(docs []*document, material *document, settings *linkSettings, confirmed *bool) bool {
+	viewport := imgui.MainViewport()
+	windowSize := imgui.Vec2{
+		X: 0.25 * viewport.Size().X,
+		Y: 0.25 * viewport.Size().Y,
+	}
+	var responded bool
+	centerWindow(windowSize)
+	*confirmed = linkLUTsDialog(docs, material, settings, windowSize, &responded)
+	return responded
+}
+
+func linkLUTsDialog(docs []*document, material *document, settings *linkSettings, windowSize imgui.Vec2, responded *bool) (resp bool) {
+	*responded = false
+	imgui.BeginV("Link pattern LUT", nil, imgui.WindowFlagsNoMove|imgui.WindowFlagsNoResize|imgui.WindowFlagsNoCollapse)
+	imgui.Text(fmt.Sprintf("Material LUT: %s", material.displayName()))
+	preview := "(none)"
+	if settings.pattern >= 0 && settings.pattern < len(docs) {
+		preview = docs[settings.pattern].displayName()
+	}
+	if imgui.BeginCombo("Pattern LUT", preview) {
+		for i, doc := range docs {
+			if doc == material || doc.img == nil {
+				continue
+			}
+			if imgui.SelectableV(doc.tabLabel(), i == settings.pattern, 0, imgui.Vec2{}) {
+				settings.pattern = i
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.Text("Channel")
+	for i, name := range graphChannelNames {
+		imgui.SameLine()
+		imgui.RadioButtonInt(name, &settings.channel, i)
+	}
+	for _, encoding := range linked.Encodings {
+		imgui.RadioButtonInt(encoding.String(), &settings.encoding, int(encoding))
+		imgui.SameLine()
+	}
+	imgui.Spacing()
+	valid := settings.pattern >= 0 && settings.pattern < len(docs) && docs[settings.pattern] != material && docs[settings.pattern].img != nil
+	buttonSize := imgui.Vec2{
+		X: windowSize.X * 0.3,
+		Y: windowSize.Y * 0.15,
+	}
+	imgui.SetCursorPos(imgui.Vec2{
+		X: imgui.CursorPosX(),
+		Y: windowSize.Y * .8,
+	})
+	if imgui.ButtonV("Link", buttonSize) && valid {
+		*responded = true
+		resp = true
+	}
+	imgui.SameLine()
+	imgui.SetCursorPos(imgui.Vec2{
+		X: windowSize.X * 0.65,
+		Y: imgui.CursorPosY(),
+	})
+	if imgui.ButtonV("Cancel", buttonSize) {
+		*responded = true
+		resp = false
+	}
+	imgui.End()
+	return
+}
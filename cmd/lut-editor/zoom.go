@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/gopxl/pixel/v2"
+	"github.com/gopxl/pixel/v2/backends/opengl"
+	"github.com/gopxl/pixel/v2/ext/imdraw"
+)
+
+// zoomClickFactor is how much a click with the zoom tool, rather than a drag,
+// zooms in.
+const zoomClickFactor = 2
+
+// zoomToRect returns the camera position and zoom that fit area, in world
+// coordinates, to a window of size view. Areas under a screen pixel across,
+// as left by a click, zoom in by zoomClickFactor around their center instead.
+func zoomToRect(area pixel.Rect, view pixel.Vec, camZoom float64) (pixel.Vec, float64) {
+	area = area.Norm()
+	if area.W()*camZoom < 1 || area.H()*camZoom < 1 {
+		return area.Center(), camZoom * zoomClickFactor
+	}
+	return area.Center(), min(view.X/area.W(), view.Y/area.H())
+}
+
+// drawZoomMarquee outlines the area being dragged out with the zoom tool.
+func drawZoomMarquee(win *opengl.Window, camZoom float64, area pixel.Rect) {
+	marquee := imdraw.New(nil)
+	marquee.Color = pixel.RGBA{R: 0.9, G: 0.9, B: 0.9, A: 0.6}
+	marquee.Push(area.Min, area.Max)
+	marquee.Rectangle(1 / camZoom)
+	marquee.Draw(win)
+}
@@ -0,0 +1,241 @@
+// Package colorspace converts linear RGB values between sets of primaries, for
+// presenting images whose files describe their own colorimetry.
+package colorspace
+
+import (
+	"image"
+	"math"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// XY is a CIE 1931 xy chromaticity coordinate.
+type XY struct {
+	X, Y float64
+}
+
+// Primaries describes an RGB color space by the chromaticities of its red,
+// green and blue primaries and its white point.
+type Primaries struct {
+	Red, Green, Blue, White XY
+}
+
+var (
+	D65 = XY{X: 0.3127, Y: 0.3290}
+
+	// Rec709 (and sRGB) primaries, which is what the editor assumes a display
+	// uses and what EXR files without chromaticities are defined to use.
+	Rec709 = Primaries{
+		Red:   XY{X: 0.64, Y: 0.33},
+		Green: XY{X: 0.30, Y: 0.60},
+		Blue:  XY{X: 0.15, Y: 0.06},
+		White: D65,
+	}
+)
+
+type Mat3 [3][3]float64
+
+var Identity = Mat3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// bradford is the cone response matrix used for chromatic adaptation.
+var bradford = Mat3{
+	{0.8951, 0.2664, -0.1614},
+	{-0.7502, 1.7135, 0.0367},
+	{0.0389, -0.0685, 1.0296},
+}
+
+func (m Mat3) Mul(n Mat3) Mat3 {
+	var out Mat3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return out
+}
+
+func (m Mat3) MulVec(v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
+
+func (m Mat3) Inverse() Mat3 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	if det == 0 {
+		return Identity
+	}
+	inv := Mat3{
+		{m[1][1]*m[2][2] - m[1][2]*m[2][1], m[0][2]*m[2][1] - m[0][1]*m[2][2], m[0][1]*m[1][2] - m[0][2]*m[1][1]},
+		{m[1][2]*m[2][0] - m[1][0]*m[2][2], m[0][0]*m[2][2] - m[0][2]*m[2][0], m[0][2]*m[1][0] - m[0][0]*m[1][2]},
+		{m[1][0]*m[2][1] - m[1][1]*m[2][0], m[0][1]*m[2][0] - m[0][0]*m[2][1], m[0][0]*m[1][1] - m[0][1]*m[1][0]},
+	}
+	for i := range inv {
+		for j := range inv[i] {
+			inv[i][j] /= det
+		}
+	}
+	return inv
+}
+
+// IsIdentity reports whether m is within eps of the identity matrix in every
+// element, i.e. whether applying it would make no visible difference.
+func (m Mat3) IsIdentity(eps float64) bool {
+	for i := range m {
+		for j := range m[i] {
+			if math.Abs(m[i][j]-Identity[i][j]) > eps {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (m Mat3) Apply(r, g, b float32) (float32, float32, float32) {
+	out := m.MulVec([3]float64{float64(r), float64(g), float64(b)})
+	return float32(out[0]), float32(out[1]), float32(out[2])
+}
+
+func (c XY) xyz() [3]float64 {
+	if c.Y == 0 {
+		return [3]float64{}
+	}
+	return [3]float64{c.X / c.Y, 1, (1 - c.X - c.Y) / c.Y}
+}
+
+// ToXYZ returns the matrix converting linear RGB in p to CIE XYZ.
+func (p Primaries) ToXYZ() Mat3 {
+	r, g, b := p.Red.xyz(), p.Green.xyz(), p.Blue.xyz()
+	primaries := Mat3{
+		{r[0], g[0], b[0]},
+		{r[1], g[1], b[1]},
+		{r[2], g[2], b[2]},
+	}
+	scale := primaries.Inverse().MulVec(p.White.xyz())
+	for i := range primaries {
+		for j := range primaries[i] {
+			primaries[i][j] *= scale[j]
+		}
+	}
+	return primaries
+}
+
+// Adaptation returns the Bradford chromatic adaptation from white point from
+// to white point to, in XYZ.
+func Adaptation(from, to XY) Mat3 {
+	src := bradford.MulVec(from.xyz())
+	dst := bradford.MulVec(to.xyz())
+	var scale Mat3
+	for i := range scale {
+		if src[i] != 0 {
+			scale[i][i] = dst[i] / src[i]
+		}
+	}
+	return bradford.Inverse().Mul(scale).Mul(bradford)
+}
+
+// Conversion returns the matrix converting linear RGB in from to linear RGB in
+// to. Colors matching neutral appear as the white point of to.
+func Conversion(from Primaries, neutral XY, to Primaries) Mat3 {
+	return to.ToXYZ().Inverse().Mul(Adaptation(neutral, to.White)).Mul(from.ToXYZ())
+}
+
+// Transform returns a copy of img, as seen through img.At, with m applied to
+// the color channels of every pixel.
+func Transform(img image.Image, m Mat3) *hdrColors.NRGBA128FImage {
+	return TransformFunc(img, m.Apply)
+}
+
+// TransformFunc is like Transform, with an arbitrary conversion of the color
+// channels.
+func TransformFunc(img image.Image, f func(r, g, b float32) (float32, float32, float32)) *hdrColors.NRGBA128FImage {
+	bounds := img.Bounds()
+	out := hdrColors.NewNRGBA128FImage(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := hdrColors.NRGBA128FModel.Convert(img.At(x, y)).(hdrColors.NRGBA128F)
+			c.R, c.G, c.B = f(c.R, c.G, c.B)
+			out.Set(x, y, c)
+		}
+	}
+	return out
+}
+
+// EncodeSRGB applies the sRGB transfer function to a linear value. Values
+// outside [0, 1] follow the curve's extension, mirrored for negative values,
+// so HDR data stays distinguishable.
+func EncodeSRGB(v float32) float32 {
+	if v < 0 {
+		return -EncodeSRGB(-v)
+	}
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return float32(1.055*math.Pow(float64(v), 1/2.4) - 0.055)
+}
+
+// DecodeSRGB is the inverse of EncodeSRGB, making an sRGB encoded value
+// linear.
+func DecodeSRGB(v float32) float32 {
+	if v < 0 {
+		return -DecodeSRGB(-v)
+	}
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return float32(math.Pow((float64(v)+0.055)/1.055, 2.4))
+}
+
+// EncodeSRGBColor applies EncodeSRGB to each color channel.
+func EncodeSRGBColor(r, g, b float32) (float32, float32, float32) {
+	return EncodeSRGB(r), EncodeSRGB(g), EncodeSRGB(b)
+}
+
+// OKLab matrices, from https://bottosson.github.io/posts/oklab/
+var (
+	okLabToLMS = Mat3{
+		{0.4122214708, 0.5363325363, 0.0514459929},
+		{0.2119034982, 0.6806995451, 0.1073969566},
+		{0.0883024619, 0.2817188376, 0.6299787005},
+	}
+	okLabFromLMS = Mat3{
+		{0.2104542553, 0.7936177850, -0.0040720468},
+		{1.9779984951, -2.4285922050, 0.4505937099},
+		{0.0259040371, 0.7827717662, -0.8086757660},
+	}
+	okLabToLMSCubed = Mat3{
+		{1, 0.3963377774, 0.2158037573},
+		{1, -0.1055613458, -0.0638541728},
+		{1, -0.0894841775, -1.2914855480},
+	}
+	okLabToRGB = Mat3{
+		{4.0767416621, -3.3077115913, 0.2309699292},
+		{-1.2684380046, 2.6097574011, -0.3413193965},
+		{-0.0041960863, -0.7034186147, 1.7076147010},
+	}
+)
+
+// OKLab converts a linear Rec709 color to OKLab lightness and a and b
+// opponent axes, a space where hue shifts keep perceived lightness.
+func OKLab(rgb [3]float64) [3]float64 {
+	lms := okLabToLMS.MulVec(rgb)
+	for i := range lms {
+		lms[i] = math.Cbrt(lms[i])
+	}
+	return okLabFromLMS.MulVec(lms)
+}
+
+// FromOKLab is the inverse of OKLab.
+func FromOKLab(lab [3]float64) [3]float64 {
+	lms := okLabToLMSCubed.MulVec(lab)
+	for i := range lms {
+		lms[i] = lms[i] * lms[i] * lms[i]
+	}
+	return okLabToRGB.MulVec(lms)
+}
@@ -0,0 +1,55 @@
+package colorspace_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ryanjsims/hd2-lut-editor/colorspace"
+)
+
+func TestConversionSamePrimaries(t *testing.T) {
+	m := colorspace.Conversion(colorspace.Rec709, colorspace.D65, colorspace.Rec709)
+	if !m.IsIdentity(1e-9) {
+		t.Fatalf("expected identity, got %v", m)
+	}
+}
+
+func TestConversionP3ToRec709(t *testing.T) {
+	p3 := colorspace.Primaries{
+		Red:   colorspace.XY{X: 0.680, Y: 0.320},
+		Green: colorspace.XY{X: 0.265, Y: 0.690},
+		Blue:  colorspace.XY{X: 0.150, Y: 0.060},
+		White: colorspace.D65,
+	}
+	expected := colorspace.Mat3{
+		{1.2249, -0.2249, 0},
+		{-0.0421, 1.0421, 0},
+		{-0.0196, -0.0786, 1.0983},
+	}
+	m := colorspace.Conversion(p3, p3.White, colorspace.Rec709)
+	for i := range m {
+		for j := range m[i] {
+			if math.Abs(m[i][j]-expected[i][j]) > 1e-3 {
+				t.Fatalf("unexpected conversion matrix %v", m)
+			}
+		}
+	}
+}
+
+func TestEncodeSRGB(t *testing.T) {
+	cases := []struct{ linear, encoded float32 }{
+		{0, 0},
+		{0.002, 0.02584},
+		{0.2140, 0.5},
+		{1, 1},
+		{-0.2140, -0.5},
+	}
+	for _, c := range cases {
+		if got := colorspace.EncodeSRGB(c.linear); math.Abs(float64(got-c.encoded)) > 1e-3 {
+			t.Errorf("EncodeSRGB(%v) = %v, want %v", c.linear, got, c.encoded)
+		}
+		if got := colorspace.DecodeSRGB(c.encoded); math.Abs(float64(got-c.linear)) > 1e-3 {
+			t.Errorf("DecodeSRGB(%v) = %v, want %v", c.encoded, got, c.linear)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package dds
+
+import "testing"
+
+// TestBC6HModes checks the layout of each mode against the block size: every
+// endpoint bit is stored exactly once, and the mode, endpoint, partition and
+// index bits add up to 128.
+func TestBC6HModes(t *testing.T) {
+	for modeBits, mode := range bc6hModes {
+		bits, err := parseBC6HLayout(mode.layout)
+		if err != nil {
+			t.Fatalf("mode %#x: %v", modeBits, err)
+		}
+		seen := make(map[bc6hBit]bool)
+		var count [4][3]uint8
+		for _, b := range bits {
+			if seen[b] {
+				t.Fatalf("mode %#x: %v is stored twice", modeBits, b)
+			}
+			seen[b] = true
+			count[b.endpoint][b.channel]++
+		}
+		endpoints := 2
+		if mode.partitioned {
+			endpoints = 4
+		}
+		for e := range count {
+			for c := range count[e] {
+				want := uint8(0)
+				switch {
+				case e >= endpoints:
+				case e == 0 || !mode.transformed:
+					want = mode.endpointBits
+				default:
+					want = mode.deltaBits[c]
+				}
+				if count[e][c] != want {
+					t.Fatalf("mode %#x: endpoint %d channel %d has %d bits, want %d", modeBits, e, c, count[e][c], want)
+				}
+				// Endpoint bits are stored from bit 0 up
+				for bit := uint8(0); bit < want; bit++ {
+					if !seen[bc6hBit{endpoint: uint8(e), channel: uint8(c), bit: bit}] {
+						t.Fatalf("mode %#x: endpoint %d channel %d is missing bit %d", modeBits, e, c, bit)
+					}
+				}
+			}
+		}
+
+		// Modes 0x00 and 0x01 have 2 mode bits, the others 5
+		size := 5 + len(bits)
+		if modeBits < 2 {
+			size = 2 + len(bits)
+		}
+		if mode.partitioned {
+			// 5 partition bits, and 3 bit indices but for the two anchors
+			size += 5 + 16*3 - 2
+		} else {
+			// 4 bit indices but for the anchor
+			size += 16*4 - 1
+		}
+		if size != 128 {
+			t.Fatalf("mode %#x: block has %d bits", modeBits, size)
+		}
+	}
+}
+
+// blockWriter stores the fields of a block from bit 0 up, each least
+// significant bit first.
+type blockWriter struct {
+	block [16]uint8
+	pos   int
+}
+
+func (w *blockWriter) put(value uint64, bits int) {
+	for i := 0; i < bits; i++ {
+		if value>>i&1 != 0 {
+			w.block[w.pos/8] |= 1 << (w.pos % 8)
+		}
+		w.pos++
+	}
+}
+
+// rgb is a texel with the same half float in each channel.
+func rgb(v uint16) [3]uint16 {
+	return [3]uint16{v, v, v}
+}
+
+func checkBC6HBlock(t *testing.T, name string, w *blockWriter, signed bool, want [16][3]uint16) {
+	t.Helper()
+	if w.pos != 128 {
+		t.Fatalf("%s: test block has %d bits", name, w.pos)
+	}
+	got := decodeBC6HBlock(w.block[:], signed)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: texel %d is %#04x, want %#04x", name, i, got[i], want[i])
+		}
+	}
+}
+
+// The expected texels below follow the unquantization and interpolation of
+// the D3D11 BC6H documentation. The largest unsigned endpoint decodes to
+// 0x7bff, the largest half float.
+
+func TestBC6HOneRegion(t *testing.T) {
+	// Mode 0x03: 10 bit endpoints stored as they are
+	block := func(w0, w1 uint64) *blockWriter {
+		w := &blockWriter{}
+		w.put(0x03, 5)
+		for _, e := range []uint64{w0, w1} {
+			w.put(e, 10)
+			w.put(e, 10)
+			w.put(e, 10)
+		}
+		// Texel 0 is the anchor, with a 3 bit index
+		w.put(0, 3)
+		w.put(8, 4)
+		for i := 2; i < 16; i++ {
+			w.put(15, 4)
+		}
+		return w
+	}
+
+	// Unsigned from 0 to 1023, with texel 1 at weight 34 of 64
+	want := [16][3]uint16{rgb(0), rgb(0x41df)}
+	for i := 2; i < 16; i++ {
+		want[i] = rgb(0x7bff)
+	}
+	checkBC6HBlock(t, "unsigned", block(0, 1023), false, want)
+
+	// Signed from -511 to 511, the largest magnitudes
+	want = [16][3]uint16{rgb(0xfbff), rgb(0x07c0)}
+	for i := 2; i < 16; i++ {
+		want[i] = rgb(0x7bff)
+	}
+	checkBC6HBlock(t, "signed", block(0x201, 0x1ff), true, want)
+}
+
+func TestBC6HOneRegionTransformed(t *testing.T) {
+	// Mode 0x07: an 11 bit endpoint, and the other as 9 bit deltas from it.
+	// A delta of -1 from 0 wraps around to the largest endpoint.
+	w := &blockWriter{}
+	w.put(0x07, 5)
+	w.put(0, 30)
+	for range 3 {
+		w.put(0x1ff, 9)
+		w.put(0, 1)
+	}
+	w.put(0, 3)
+	for i := 1; i < 16; i++ {
+		w.put(15, 4)
+	}
+	want := [16][3]uint16{}
+	for i := 1; i < 16; i++ {
+		want[i] = rgb(0x7bff)
+	}
+	checkBC6HBlock(t, "transformed", w, false, want)
+}
+
+func TestBC6HTwoRegions(t *testing.T) {
+	// Mode 0x1e: 6 bit endpoints stored as they are, with the first subset's
+	// endpoints 0 and the second's all ones, but for the red of its second
+	w := &blockWriter{}
+	w.put(0x1e, 5)
+	for _, field := range []struct {
+		value uint64
+		bits  int
+	}{
+		{0, 6},    // rw[5:0]
+		{0xf, 4},  // gz[4], bz[0], bz[1], by[4]
+		{0, 6},    // gw[5:0]
+		{0xf, 4},  // gy[5], by[5], bz[2], gy[4]
+		{0, 6},    // bw[5:0]
+		{0xf, 4},  // gz[5], bz[3], bz[5], bz[4]
+		{0, 6},    // rx[5:0]
+		{0xf, 4},  // gy[3:0]
+		{0, 6},    // gx[5:0]
+		{0xf, 4},  // gz[3:0]
+		{0, 6},    // bx[5:0]
+		{0xf, 4},  // by[3:0]
+		{0x3f, 6}, // ry[5:0]
+		{0, 6},    // rz[5:0]
+		{0, 5},    // partition 0: the right two columns are the second subset
+		{0, 2},    // texel 0, the first subset's anchor
+	} {
+		w.put(field.value, field.bits)
+	}
+	for i := 1; i < 15; i++ {
+		// The first subset decodes to 0 at any weight, the second is given
+		// weight 64
+		index := uint64(i % 4)
+		if i%4 >= 2 {
+			index = 7
+		}
+		w.put(index, 3)
+	}
+	// Texel 15 is the second subset's anchor, at weight 27 of 64
+	w.put(3, 2)
+
+	var unsigned, signed [16][3]uint16
+	for _, i := range []int{2, 3, 6, 7, 10, 11, 14} {
+		// Weight 64 of the second endpoint, whose green and blue are all
+		// ones, which is -1 when signed
+		unsigned[i] = [3]uint16{0, 0x7bff, 0x7bff}
+		signed[i] = [3]uint16{0, 0x85d0, 0x85d0}
+	}
+	unsigned[15] = [3]uint16{0x47af, 0x7bff, 0x7bff}
+	signed[15] = [3]uint16{0x835c, 0x85d0, 0x85d0}
+	checkBC6HBlock(t, "unsigned", w, false, unsigned)
+	checkBC6HBlock(t, "signed", w, true, signed)
+}
+
+func TestBC6HReservedMode(t *testing.T) {
+	w := &blockWriter{}
+	w.put(0x13, 5)
+	w.put(0xffffffffffffffff, 64)
+	w.put(0xffffffffffffffff, 59)
+	checkBC6HBlock(t, "reserved", w, false, [16][3]uint16{})
+}
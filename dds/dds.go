@@ -150,48 +150,9 @@ func DecodeInfo(r io.Reader) (Info, error) {
 				return Info{}, errors.New("unsupported DXT10 resource dimension")
 			}
 
-			switch dx10.DXGIFormat {
-			case DXGIFormatR32G32B32A32Float,
-				DXGIFormatR32G32B32Float:
-				info.ColorModel = hdrColors.NRGBA128FModel
-				info.Decompress = DecompressUncompressedDXT10
-			case DXGIFormatR16G16B16A16Float,
-				DXGIFormatR16G16B16A16UNorm:
-				info.ColorModel = hdrColors.NRGBA64FModel
-				info.Decompress = DecompressUncompressedDXT10
-			case DXGIFormatR32G32Float:
-				info.ColorModel = color.NRGBA64Model
-				info.Decompress = DecompressUncompressedDXT10
-			case DXGIFormatR32Float, DXGIFormatR16UNorm:
-				info.ColorModel = color.Gray16Model
-				info.Decompress = DecompressUncompressedDXT10
-			case DXGIFormatR8UNorm:
-				info.ColorModel = color.GrayModel
-				info.Decompress = DecompressUncompressedDXT10
-			case DXGIFormatR8G8B8A8UNorm:
-				info.ColorModel = color.NRGBAModel
-				info.Decompress = DecompressUncompressedDXT10
-			case DXGIFormatBC1UNorm:
-				info.ColorModel = color.NRGBAModel
-				info.Decompress = DecompressDXT1
-			case DXGIFormatBC2UNorm:
-				return Info{}, errors.New("DXT3 compression unsupported")
-			case DXGIFormatBC3UNorm:
-				info.ColorModel = color.NRGBAModel
-				info.Decompress = DecompressDXT5
-			case DXGIFormatBC4UNorm:
-				info.ColorModel = color.GrayModel
-				info.Decompress = Decompress3DcPlus
-			case DXGIFormatBC5UNorm:
-				info.ColorModel = color.NRGBAModel
-				info.Decompress = Decompress3Dc
-			case DXGIFormatBC7UNorm:
-				info.ColorModel = color.NRGBAModel
-				info.Decompress = DecompressBC7
-			case DXGIFormatBC7UNormSRGB:
-				return Info{}, errors.New("BC7 SRGB compression unsupported")
-			default:
-				return Info{}, fmt.Errorf("unsupported DXGI format: %v", dx10.DXGIFormat)
+			info.ColorModel, info.Decompress, err = formatDecoder(dx10.DXGIFormat)
+			if err != nil {
+				return Info{}, err
 			}
 
 			if dx10.MiscFlag&D3D10ResourceMiscFlagTextureCube != 0 {
@@ -250,6 +211,47 @@ func DecodeInfo(r io.Reader) (Info, error) {
 	return info, nil
 }
 
+// formatDecoder returns the color model and decompression function for
+// texture data in format.
+func formatDecoder(format DXGIFormat) (color.Model, DecompressFunc, error) {
+	switch format {
+	case DXGIFormatR32G32B32A32Float,
+		DXGIFormatR32G32B32Float:
+		return hdrColors.NRGBA128FModel, DecompressUncompressedDXT10, nil
+	case DXGIFormatR32G32B32A32UInt:
+		return hdrColors.NRGBA128UModel, DecompressUncompressedDXT10, nil
+	case DXGIFormatR16G16B16A16Float,
+		DXGIFormatR16G16B16A16UNorm:
+		return hdrColors.NRGBA64FModel, DecompressUncompressedDXT10, nil
+	case DXGIFormatR32G32Float:
+		return color.NRGBA64Model, DecompressUncompressedDXT10, nil
+	case DXGIFormatR32Float, DXGIFormatR16UNorm:
+		return color.Gray16Model, DecompressUncompressedDXT10, nil
+	case DXGIFormatR8UNorm:
+		return color.GrayModel, DecompressUncompressedDXT10, nil
+	case DXGIFormatR8G8B8A8UNorm:
+		return color.NRGBAModel, DecompressUncompressedDXT10, nil
+	case DXGIFormatBC1UNorm:
+		return color.NRGBAModel, DecompressDXT1, nil
+	case DXGIFormatBC2UNorm:
+		return nil, nil, errors.New("DXT3 compression unsupported")
+	case DXGIFormatBC3UNorm:
+		return color.NRGBAModel, DecompressDXT5, nil
+	case DXGIFormatBC4UNorm:
+		return color.GrayModel, Decompress3DcPlus, nil
+	case DXGIFormatBC5UNorm:
+		return color.NRGBAModel, Decompress3Dc, nil
+	case DXGIFormatBC6HUF16, DXGIFormatBC6HSF16:
+		return hdrColors.NRGBA64FModel, DecompressBC6H, nil
+	case DXGIFormatBC7UNorm:
+		return color.NRGBAModel, DecompressBC7, nil
+	case DXGIFormatBC7UNormSRGB:
+		return nil, nil, errors.New("BC7 SRGB compression unsupported")
+	default:
+		return nil, nil, fmt.Errorf("unsupported DXGI format: %v", format)
+	}
+}
+
 func DecodeConfig(r io.Reader) (image.Config, error) {
 	info, err := DecodeInfo(r)
 	if err != nil {
@@ -280,10 +282,60 @@ type DDS struct {
 	Images []*DDSImage
 }
 
+// ExtraBytes returns the memory used by the mipmaps and images after the
+// first, which are decoded but never saved.
+func (d *DDS) ExtraBytes() int {
+	n := 0
+	for i, img := range d.Images {
+		for j, mip := range img.MipMaps {
+			if i > 0 || j > 0 {
+				n += hdrColors.PixelBytes(mip.Image)
+			}
+		}
+	}
+	return n
+}
+
+// DropExtra frees the mipmaps and images after the first.
+func (d *DDS) DropExtra() {
+	d.Images = d.Images[:1]
+	d.Images[0].MipMaps = d.Images[0].MipMaps[:1]
+}
+
+// WriteHDROptions choose what is written along with the image.
+type WriteHDROptions struct {
+	// MipMaps writes a full mip chain made with Filter after the image
+	MipMaps bool
+	Filter  MipFilter
+}
+
 func WriteHDR(w io.Writer, hdrImg image.Image) error {
+	return WriteHDRWithOptions(w, hdrImg, WriteHDROptions{})
+}
+
+// WriteHDRWithOptions writes hdrImg as an uncompressed DDS, with a mip chain
+// if opts ask for one.
+func WriteHDRWithOptions(w io.Writer, hdrImg image.Image, opts WriteHDROptions) error {
+	var mipMaps []hdrColors.RawImage
+	if opts.MipMaps {
+		src := hdrImg
+		if ddsImg, ok := src.(*DDS); ok {
+			src = ddsImg.Image
+		}
+		raw, ok := src.(hdrColors.RawImage)
+		if !ok {
+			return fmt.Errorf("image does not have an HDR color model")
+		}
+		var err error
+		mipMaps, err = GenerateMipMaps(raw, opts.Filter)
+		if err != nil {
+			return err
+		}
+	}
+
 	ddsImg, ok := hdrImg.(*DDS)
 	if ok {
-		return ddsImg.dump(w)
+		return ddsImg.dump(w, mipMaps)
 	}
 
 	var dxgiFmt DXGIFormat
@@ -295,21 +347,21 @@ func WriteHDR(w io.Writer, hdrImg image.Image) error {
 		if !ok {
 			return fmt.Errorf("failed to convert dds to NRGBA128F")
 		}
-		pix = img.Pix
+		pix = packedPix(img.Pix, img.Stride, img.Rect, 16)
 	case hdrColors.NRGBA128UModel:
 		dxgiFmt = DXGIFormatR32G32B32A32UInt
 		img, ok := hdrImg.(*hdrColors.NRGBA128UImage)
 		if !ok {
 			return fmt.Errorf("failed to convert dds to NRGBA128U")
 		}
-		pix = img.Pix
+		pix = packedPix(img.Pix, img.Stride, img.Rect, 16)
 	case hdrColors.NRGBA64FModel:
 		dxgiFmt = DXGIFormatR16G16B16A16Float
 		img, ok := hdrImg.(*hdrColors.NRGBA64FImage)
 		if !ok {
 			return fmt.Errorf("failed to convert dds to NRGBA64F")
 		}
-		pix = img.Pix
+		pix = packedPix(img.Pix, img.Stride, img.Rect, 8)
 	default:
 		return fmt.Errorf("image does not have an HDR color model")
 	}
@@ -321,7 +373,7 @@ func WriteHDR(w io.Writer, hdrImg image.Image) error {
 			Height:            uint32(hdrImg.Bounds().Dy()),
 			PitchOrLinearSize: 0,
 			Depth:             0,
-			MipMapCount:       1,
+			MipMapCount:       uint32(1 + len(mipMaps)),
 			Reserved:          [11]uint32{0},
 			PixelFormat: PixelFormat{
 				Size:        32,
@@ -361,48 +413,121 @@ func WriteHDR(w io.Writer, hdrImg image.Image) error {
 		return err
 	}
 	err = binary.Write(w, binary.LittleEndian, pix)
-	return err
-}
-
-func (d *DDS) dump(w io.Writer) error {
-	d.Info.Header.MipMapCount = 1
-	err := binary.Write(w, binary.LittleEndian, []byte("DDS "))
 	if err != nil {
 		return err
 	}
-	err = binary.Write(w, binary.LittleEndian, d.Info.Header)
-	if err != nil {
-		return err
-	}
-	if d.Info.DXT10Header != nil {
-		err = binary.Write(w, binary.LittleEndian, *d.Info.DXT10Header)
-		if err != nil {
+	return writeMipMaps(w, mipMaps)
+}
+
+// writeMipMaps writes the pixels of each level of a mip chain after the
+// first, which GenerateMipMaps makes without padding.
+func writeMipMaps(w io.Writer, mipMaps []hdrColors.RawImage) error {
+	for _, mip := range mipMaps {
+		hdrImg, ok := mip.(hdrColors.HDRImage)
+		if !ok {
+			return fmt.Errorf("mip map does not have an HDR color model")
+		}
+		if _, err := w.Write(hdrImg.Pixels()); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// packedPix returns the pixels of rect without the padding between rows that
+// sub-images share with their parent, so only the image's own pixels are
+// written.
+func packedPix(pix []byte, stride int, rect image.Rectangle, bytesPerPixel int) []byte {
+	rowSize := rect.Dx() * bytesPerPixel
+	if stride == rowSize && len(pix) == rowSize*rect.Dy() {
+		return pix
+	}
+	packed := make([]byte, 0, rowSize*rect.Dy())
+	for y := 0; y < rect.Dy(); y++ {
+		packed = append(packed, pix[y*stride:y*stride+rowSize]...)
+	}
+	return packed
+}
+
+// dump writes d as it was read, with mipMaps in place of its own, which are
+// never kept after the first. Pixels decoded from another format, such as
+// BC6H, are written as they are stored, with a header that says so.
+func (d *DDS) dump(w io.Writer, mipMaps []hdrColors.RawImage) error {
 	var pix []byte
+	var format DXGIFormat
+	var bytesPerPixel int
 	switch d.Info.ColorModel {
 	case hdrColors.NRGBA64FModel:
 		img, ok := d.Image.(*hdrColors.NRGBA64FImage)
 		if !ok {
 			return fmt.Errorf("failed to convert dds to NRGBA64F")
 		}
-		pix = img.Pix
+		pix, format, bytesPerPixel = img.Pix, DXGIFormatR16G16B16A16Float, 8
 	case hdrColors.NRGBA128FModel:
 		img, ok := d.Image.(*hdrColors.NRGBA128FImage)
 		if !ok {
 			return fmt.Errorf("failed to convert dds to NRGBA128F")
 		}
-		pix = img.Pix
+		pix, format, bytesPerPixel = img.Pix, DXGIFormatR32G32B32A32Float, 16
 	case hdrColors.NRGBA128UModel:
 		img, ok := d.Image.(*hdrColors.NRGBA128UImage)
 		if !ok {
 			return fmt.Errorf("failed to convert dds to NRGBA128U")
 		}
-		pix = img.Pix
+		pix, format, bytesPerPixel = img.Pix, DXGIFormatR32G32B32A32UInt, 16
+	}
+	if pix != nil && (d.Info.DXT10Header == nil || d.Info.DXT10Header.DXGIFormat != format) {
+		d.Info.setStoredFormat(format, bytesPerPixel)
+	}
+
+	d.Info.Header.MipMapCount = uint32(1 + len(mipMaps))
+	if len(mipMaps) > 0 {
+		d.Info.Header.Flags |= HeaderFlagMipMapCount
+		d.Info.Header.Caps |= CapsFlag | CapsMipMap
+	}
+	err := binary.Write(w, binary.LittleEndian, []byte("DDS "))
+	if err != nil {
+		return err
+	}
+	err = binary.Write(w, binary.LittleEndian, d.Info.Header)
+	if err != nil {
+		return err
+	}
+	if d.Info.DXT10Header != nil {
+		err = binary.Write(w, binary.LittleEndian, *d.Info.DXT10Header)
+		if err != nil {
+			return err
+		}
 	}
 	err = binary.Write(w, binary.LittleEndian, pix)
-	return err
+	if err != nil {
+		return err
+	}
+	return writeMipMaps(w, mipMaps)
+}
+
+// setStoredFormat changes the header to describe uncompressed pixels in
+// format, bytesPerPixel each, in place of the compressed or differently laid
+// out data they were decoded from.
+func (info *Info) setStoredFormat(format DXGIFormat, bytesPerPixel int) {
+	info.Header.Flags &^= HeaderFlagLinearsize
+	info.Header.Flags |= HeaderFlagPitch | HeaderFlagPixelFormat
+	info.Header.PitchOrLinearSize = info.Header.Width * uint32(bytesPerPixel)
+	info.Header.PixelFormat = PixelFormat{
+		Size:   32,
+		Flags:  PixelFormatFlagFourCC,
+		FourCC: [4]byte{'D', 'X', '1', '0'},
+	}
+	dx10 := DXT10Header{
+		ResourceDimension: D3D10ResourceDimensionTexture2D,
+		ArraySize:         1,
+	}
+	if info.DXT10Header != nil {
+		dx10 = *info.DXT10Header
+	}
+	dx10.DXGIFormat = format
+	info.DXT10Header = &dx10
+	info.Decompress = DecompressUncompressedDXT10
 }
 
 // https://github.com/ImageMagick/ImageMagick/blob/main/coders/dds.c
@@ -427,37 +552,8 @@ func Decode(r io.Reader, readMipMaps bool) (*DDS, error) {
 				break
 			}
 
-			var buf []uint8
-			var img image.Image
-			switch info.ColorModel {
-			case color.GrayModel:
-				newImg := image.NewGray(image.Rect(0, 0, width, height))
-				buf = newImg.Pix
-				img = newImg
-			case color.Gray16Model:
-				newImg := image.NewGray16(image.Rect(0, 0, width, height))
-				buf = newImg.Pix
-				img = newImg
-			case color.NRGBAModel:
-				newImg := image.NewNRGBA(image.Rect(0, 0, width, height))
-				buf = newImg.Pix
-				img = newImg
-			case color.NRGBA64Model:
-				newImg := image.NewNRGBA64(image.Rect(0, 0, width, height))
-				buf = newImg.Pix
-				img = newImg
-			case hdrColors.NRGBA64FModel:
-				newImg := hdrColors.NewNRGBA64FImage(image.Rect(0, 0, width, height))
-				buf = newImg.Pix
-				img = newImg
-			case hdrColors.NRGBA128FModel:
-				newImg := hdrColors.NewNRGBA128FImage(image.Rect(0, 0, width, height))
-				buf = newImg.Pix
-				img = newImg
-			default:
-				return nil, errors.New("invalid color model passed by info structure")
-			}
-			if err := info.Decompress(buf, r, width, height, info); err != nil {
+			img, err := decodeMipMap(r, info, width, height)
+			if err != nil {
 				return nil, err
 			}
 			mipMaps = append(mipMaps, &DDSMipMap{
@@ -466,8 +562,9 @@ func Decode(r io.Reader, readMipMaps bool) (*DDS, error) {
 				Height: height,
 			})
 
-			width /= 2
-			height /= 2
+			// Levels of non-square textures stay 1 texel wide or high
+			width = max(width/2, 1)
+			height = max(height/2, 1)
 		}
 
 		if len(mipMaps) == 0 {
@@ -487,6 +584,48 @@ func Decode(r io.Reader, readMipMaps bool) (*DDS, error) {
 	}, nil
 }
 
+// decodeMipMap reads one width by height mip map as described by info.
+func decodeMipMap(r io.Reader, info Info, width, height int) (image.Image, error) {
+	var buf []uint8
+	var img image.Image
+	switch info.ColorModel {
+	case color.GrayModel:
+		newImg := image.NewGray(image.Rect(0, 0, width, height))
+		buf = newImg.Pix
+		img = newImg
+	case color.Gray16Model:
+		newImg := image.NewGray16(image.Rect(0, 0, width, height))
+		buf = newImg.Pix
+		img = newImg
+	case color.NRGBAModel:
+		newImg := image.NewNRGBA(image.Rect(0, 0, width, height))
+		buf = newImg.Pix
+		img = newImg
+	case color.NRGBA64Model:
+		newImg := image.NewNRGBA64(image.Rect(0, 0, width, height))
+		buf = newImg.Pix
+		img = newImg
+	case hdrColors.NRGBA64FModel:
+		newImg := hdrColors.NewNRGBA64FImage(image.Rect(0, 0, width, height))
+		buf = newImg.Pix
+		img = newImg
+	case hdrColors.NRGBA128FModel:
+		newImg := hdrColors.NewNRGBA128FImage(image.Rect(0, 0, width, height))
+		buf = newImg.Pix
+		img = newImg
+	case hdrColors.NRGBA128UModel:
+		newImg := hdrColors.NewNRGBA128UImage(image.Rect(0, 0, width, height))
+		buf = newImg.Pix
+		img = newImg
+	default:
+		return nil, errors.New("invalid color model passed by info structure")
+	}
+	if err := info.Decompress(buf, r, width, height, info); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
 func init() {
 	image.RegisterFormat(
 		"dds",
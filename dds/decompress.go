@@ -7,6 +7,7 @@ import (
 	"image/color"
 	"io"
 	"math/bits"
+	"strings"
 
 	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
 )
@@ -356,6 +357,16 @@ func DecompressUncompressedDXT10(buf []uint8, r io.Reader, width, height int, in
 			}
 			return nil
 		}
+	case DXGIFormatR32G32B32A32UInt:
+		if info.ColorModel != hdrColors.NRGBA128UModel {
+			return errors.New("expected RGBA32UModel model for R32G32B32A32UInt")
+		}
+		translatePixel = func(idx int) error {
+			if _, err := io.ReadFull(r, buf[idx:idx+16]); err != nil {
+				return err
+			}
+			return nil
+		}
 	case DXGIFormatR16G16B16A16Float:
 		if info.ColorModel != hdrColors.NRGBA64FModel {
 			return errors.New("expected RGBA16FModel model for R16G16B16A16Float")
@@ -429,6 +440,8 @@ func DecompressUncompressedDXT10(buf []uint8, r io.Reader, width, height int, in
 		stride = 8
 	case hdrColors.NRGBA64FModel:
 		stride = 8
+	case hdrColors.NRGBA128UModel:
+		stride = 16
 	case hdrColors.NRGBA128FModel:
 		stride = 16
 	default:
@@ -950,3 +963,247 @@ func DecompressBC7(buf []uint8, r io.Reader, width, height int, info Info) error
 	}
 	return nil
 }
+
+// bc6hMode describes one of the BC6H block modes.
+type bc6hMode struct {
+	// endpointBits is the precision of the endpoints, and deltaBits the
+	// precision per channel of the endpoints stored as deltas from the first
+	endpointBits uint8
+	deltaBits    [3]uint8
+	transformed  bool
+	partitioned  bool
+	// layout is the order of the endpoint bits after the mode bits, in the
+	// notation of the D3D11 BC6H documentation: gy[3:0] is bits 3 to 0 of the
+	// green channel of endpoint y, stored from bit 0 up, while rw[10:15] is
+	// stored from bit 15 down. Endpoints w and x belong to the first subset,
+	// y and z to the second.
+	layout string
+	bits   []bc6hBit
+}
+
+// bc6hBit is where one bit of a BC6H block goes among the endpoints.
+type bc6hBit struct {
+	endpoint, channel, bit uint8
+}
+
+// bc6hModes are the BC6H block modes by their mode bits. Other mode bits are
+// reserved, and decode to black.
+var bc6hModes = map[uint8]*bc6hMode{
+	0x00: {endpointBits: 10, deltaBits: [3]uint8{5, 5, 5}, transformed: true, partitioned: true,
+		layout: "gy[4], by[4], bz[4], rw[9:0], gw[9:0], bw[9:0], rx[4:0], gz[4], gy[3:0], gx[4:0], bz[0], gz[3:0], bx[4:0], bz[1], by[3:0], ry[4:0], bz[2], rz[4:0], bz[3]"},
+	0x01: {endpointBits: 7, deltaBits: [3]uint8{6, 6, 6}, transformed: true, partitioned: true,
+		layout: "gy[5], gz[4], gz[5], rw[6:0], bz[0], bz[1], by[4], gw[6:0], by[5], bz[2], gy[4], bw[6:0], bz[3], bz[5], bz[4], rx[5:0], gy[3:0], gx[5:0], gz[3:0], bx[5:0], by[3:0], ry[5:0], rz[5:0]"},
+	0x02: {endpointBits: 11, deltaBits: [3]uint8{5, 4, 4}, transformed: true, partitioned: true,
+		layout: "rw[9:0], gw[9:0], bw[9:0], rx[4:0], rw[10], gy[3:0], gx[3:0], gw[10], bz[0], gz[3:0], bx[3:0], bw[10], bz[1], by[3:0], ry[4:0], bz[2], rz[4:0], bz[3]"},
+	0x06: {endpointBits: 11, deltaBits: [3]uint8{4, 5, 4}, transformed: true, partitioned: true,
+		layout: "rw[9:0], gw[9:0], bw[9:0], rx[3:0], rw[10], gz[4], gy[3:0], gx[4:0], gw[10], gz[3:0], bx[3:0], bw[10], bz[1], by[3:0], ry[3:0], bz[0], bz[2], rz[3:0], gy[4], bz[3]"},
+	0x0a: {endpointBits: 11, deltaBits: [3]uint8{4, 4, 5}, transformed: true, partitioned: true,
+		layout: "rw[9:0], gw[9:0], bw[9:0], rx[3:0], rw[10], by[4], gy[3:0], gx[3:0], gw[10], bz[0], gz[3:0], bx[4:0], bw[10], by[3:0], ry[3:0], bz[1], bz[2], rz[3:0], bz[4], bz[3]"},
+	0x0e: {endpointBits: 9, deltaBits: [3]uint8{5, 5, 5}, transformed: true, partitioned: true,
+		layout: "rw[8:0], by[4], gw[8:0], gy[4], bw[8:0], bz[4], rx[4:0], gz[4], gy[3:0], gx[4:0], bz[0], gz[3:0], bx[4:0], bz[1], by[3:0], ry[4:0], bz[2], rz[4:0], bz[3]"},
+	0x12: {endpointBits: 8, deltaBits: [3]uint8{6, 5, 5}, transformed: true, partitioned: true,
+		layout: "rw[7:0], gz[4], by[4], gw[7:0], bz[2], gy[4], bw[7:0], bz[3], bz[4], rx[5:0], gy[3:0], gx[4:0], bz[0], gz[3:0], bx[4:0], bz[1], by[3:0], ry[5:0], rz[5:0]"},
+	0x16: {endpointBits: 8, deltaBits: [3]uint8{5, 6, 5}, transformed: true, partitioned: true,
+		layout: "rw[7:0], bz[0], by[4], gw[7:0], gy[5], gy[4], bw[7:0], gz[5], bz[4], rx[4:0], gz[4], gy[3:0], gx[5:0], gz[3:0], bx[4:0], bz[1], by[3:0], ry[4:0], bz[2], rz[4:0], bz[3]"},
+	0x1a: {endpointBits: 8, deltaBits: [3]uint8{5, 5, 6}, transformed: true, partitioned: true,
+		layout: "rw[7:0], bz[1], by[4], gw[7:0], by[5], gy[4], bw[7:0], bz[5], bz[4], rx[4:0], gz[4], gy[3:0], gx[4:0], bz[0], gz[3:0], bx[5:0], by[3:0], ry[4:0], bz[2], rz[4:0], bz[3]"},
+	0x1e: {endpointBits: 6, deltaBits: [3]uint8{6, 6, 6}, partitioned: true,
+		layout: "rw[5:0], gz[4], bz[0], bz[1], by[4], gw[5:0], gy[5], by[5], bz[2], gy[4], bw[5:0], gz[5], bz[3], bz[5], bz[4], rx[5:0], gy[3:0], gx[5:0], gz[3:0], bx[5:0], by[3:0], ry[5:0], rz[5:0]"},
+	0x03: {endpointBits: 10, deltaBits: [3]uint8{10, 10, 10},
+		layout: "rw[9:0], gw[9:0], bw[9:0], rx[9:0], gx[9:0], bx[9:0]"},
+	0x07: {endpointBits: 11, deltaBits: [3]uint8{9, 9, 9}, transformed: true,
+		layout: "rw[9:0], gw[9:0], bw[9:0], rx[8:0], rw[10], gx[8:0], gw[10], bx[8:0], bw[10]"},
+	0x0b: {endpointBits: 12, deltaBits: [3]uint8{8, 8, 8}, transformed: true,
+		layout: "rw[9:0], gw[9:0], bw[9:0], rx[7:0], rw[10:11], gx[7:0], gw[10:11], bx[7:0], bw[10:11]"},
+	0x0f: {endpointBits: 16, deltaBits: [3]uint8{4, 4, 4}, transformed: true,
+		layout: "rw[9:0], gw[9:0], bw[9:0], rx[3:0], rw[10:15], gx[3:0], gw[10:15], bx[3:0], bw[10:15]"},
+}
+
+func init() {
+	// A layout that doesn't parse leaves the mode without bits, decoding to
+	// black like the reserved modes. TestBC6HModes checks that they all parse.
+	for _, mode := range bc6hModes {
+		mode.bits, _ = parseBC6HLayout(mode.layout)
+	}
+}
+
+// parseBC6HLayout lists the bits of a bc6hMode layout in the order they are
+// stored.
+func parseBC6HLayout(layout string) ([]bc6hBit, error) {
+	var bits []bc6hBit
+	for _, field := range strings.Split(layout, ",") {
+		field = strings.TrimSpace(field)
+		var name string
+		var first, last int
+		if n, _ := fmt.Sscanf(field, "%2s[%d:%d]", &name, &first, &last); n != 3 {
+			if n, _ := fmt.Sscanf(field, "%2s[%d]", &name, &first); n != 2 {
+				return nil, fmt.Errorf("invalid field %q", field)
+			}
+			last = first
+		}
+		channel := strings.IndexByte("rgb", name[0])
+		endpoint := strings.IndexByte("wxyz", name[1])
+		if channel < 0 || endpoint < 0 || first > 15 || last > 15 {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+		// The bit on the right of the range is stored first
+		step := 1
+		if last > first {
+			step = -1
+		}
+		for bit := last; ; bit += step {
+			bits = append(bits, bc6hBit{endpoint: uint8(endpoint), channel: uint8(channel), bit: uint8(bit)})
+			if bit == first {
+				break
+			}
+		}
+	}
+	return bits, nil
+}
+
+func signExtend(x int32, bits uint8) int32 {
+	return x << (32 - bits) >> (32 - bits)
+}
+
+// unquantizeBC6H scales an endpoint of the given precision to 16 bits.
+func unquantizeBC6H(x int32, bits uint8, signed bool) int32 {
+	if !signed {
+		switch {
+		case bits >= 15 || x == 0:
+			return x
+		case x == 1<<bits-1:
+			return 0xffff
+		default:
+			return (x<<16 + 0x8000) >> bits
+		}
+	}
+	if bits >= 16 || x == 0 {
+		return x
+	}
+	neg := x < 0
+	if neg {
+		x = -x
+	}
+	if x >= 1<<(bits-1)-1 {
+		x = 0x7fff
+	} else {
+		x = (x<<15 + 0x4000) >> (bits - 1)
+	}
+	if neg {
+		x = -x
+	}
+	return x
+}
+
+// finishUnquantizeBC6H turns an interpolated value into the bits of a half
+// float.
+func finishUnquantizeBC6H(x int32, signed bool) uint16 {
+	if !signed {
+		return uint16(x * 31 >> 6)
+	}
+	if x < 0 {
+		return 0x8000 | uint16(-x*31>>5)
+	}
+	return uint16(x * 31 >> 5)
+}
+
+// decodeBC6HBlock returns the 16 RGB texels of a BC6H block as half floats,
+// row by row.
+func decodeBC6HBlock(block []uint8, signed bool) (texels [16][3]uint16) {
+	startBit := uint64(0)
+	modeBits := getBits(block, &startBit, 2)
+	if modeBits >= 2 {
+		modeBits |= getBits(block, &startBit, 3) << 2
+	}
+	mode, ok := bc6hModes[modeBits]
+	if !ok || mode.bits == nil {
+		return
+	}
+
+	var endpoints [4][3]int32
+	for _, b := range mode.bits {
+		if getBit(block, &startBit) {
+			endpoints[b.endpoint][b.channel] |= 1 << b.bit
+		}
+	}
+	numEndpoints := 2
+	partitionID := uint8(0)
+	indexPrec := uint8(4)
+	weights := bc7Weight4
+	if mode.partitioned {
+		numEndpoints = 4
+		partitionID = getBits(block, &startBit, 5)
+		indexPrec = 3
+		weights = bc7Weight3
+	}
+
+	prec := mode.endpointBits
+	for c := 0; c < 3; c++ {
+		if signed {
+			endpoints[0][c] = signExtend(endpoints[0][c], prec)
+		}
+		for e := 1; e < numEndpoints; e++ {
+			if mode.transformed {
+				delta := signExtend(endpoints[e][c], mode.deltaBits[c])
+				endpoints[e][c] = (endpoints[0][c] + delta) & (1<<prec - 1)
+			}
+			if signed {
+				endpoints[e][c] = signExtend(endpoints[e][c], prec)
+			}
+		}
+		for e := 0; e < numEndpoints; e++ {
+			endpoints[e][c] = unquantizeBC6H(endpoints[e][c], prec, signed)
+		}
+	}
+
+	for i := range texels {
+		numBits := indexPrec
+		subset := uint8(0)
+		if mode.partitioned {
+			subset = getBC7SubsetIndex(2, partitionID, i)
+			if isBC7PixelAnchorIndex(subset, 2, i, partitionID) {
+				numBits--
+			}
+		} else if i == 0 {
+			numBits--
+		}
+		weight := int32(weights[getBits(block, &startBit, numBits)])
+		e0, e1 := endpoints[2*subset], endpoints[2*subset+1]
+		for c := 0; c < 3; c++ {
+			texels[i][c] = finishUnquantizeBC6H((e0[c]*(64-weight)+e1[c]*weight+32)>>6, signed)
+		}
+	}
+	return
+}
+
+// DecompressBC6H decodes BC6H blocks of unsigned or signed half floats to
+// half float RGBA texels. BC6H has no alpha, so alpha is always 1.
+func DecompressBC6H(buf []uint8, r io.Reader, width, height int, info Info) error {
+	if info.ColorModel != hdrColors.NRGBA64FModel {
+		return errors.New("BC6H compression expects NRGBA64F color model")
+	}
+	signed := info.DXT10Header != nil && info.DXT10Header.DXGIFormat == DXGIFormatBC6HSF16
+	const halfOne = 0x3c00
+
+	for y := 0; y < height; y += 4 {
+		for x := 0; x < width; x += 4 {
+			var block [16]uint8
+			if _, err := io.ReadFull(r, block[:]); err != nil {
+				return err
+			}
+			texels := decodeBC6HBlock(block[:], signed)
+			for i, texel := range texels {
+				px, py := x+i%4, y+i/4
+				if px >= width || py >= height {
+					continue
+				}
+				idx := 8 * (py*width + px)
+				binary.LittleEndian.PutUint16(buf[idx:], texel[0])
+				binary.LittleEndian.PutUint16(buf[idx+2:], texel[1])
+				binary.LittleEndian.PutUint16(buf[idx+4:], texel[2])
+				binary.LittleEndian.PutUint16(buf[idx+6:], halfOne)
+			}
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package dds
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
@@ -132,6 +133,31 @@ const (
 	DXGIFormatForceUInt DXGIFormat = 0xffffffff
 )
 
+var formatNames = map[DXGIFormat]string{
+	DXGIFormatR32G32B32A32Float: "R32G32B32A32_FLOAT",
+	DXGIFormatR32G32B32A32UInt:  "R32G32B32A32_UINT",
+	DXGIFormatR32G32B32Float:    "R32G32B32_FLOAT",
+	DXGIFormatR16G16B16A16Float: "R16G16B16A16_FLOAT",
+	DXGIFormatR16G16B16A16UNorm: "R16G16B16A16_UNORM",
+	DXGIFormatR32G32Float:       "R32G32_FLOAT",
+	DXGIFormatR32Float:          "R32_FLOAT",
+	DXGIFormatR16UNorm:          "R16_UNORM",
+	DXGIFormatR8UNorm:           "R8_UNORM",
+	DXGIFormatR8G8B8A8UNorm:     "R8G8B8A8_UNORM",
+	DXGIFormatBC1UNorm:          "BC1_UNORM",
+	DXGIFormatBC3UNorm:          "BC3_UNORM",
+	DXGIFormatBC4UNorm:          "BC4_UNORM",
+	DXGIFormatBC5UNorm:          "BC5_UNORM",
+	DXGIFormatBC7UNorm:          "BC7_UNORM",
+}
+
+func (f DXGIFormat) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("DXGI format %d", uint32(f))
+}
+
 type D3D10ResourceDimension uint32
 
 const (
@@ -0,0 +1,190 @@
+package dds
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// MipFilter is how the smaller levels of a mip chain are made from the image.
+type MipFilter int
+
+const (
+	// MipFilterBox averages the texels each smaller texel covers.
+	MipFilterBox MipFilter = iota
+	// MipFilterKaiser is a Kaiser windowed sinc, which keeps smaller levels
+	// sharper than a box, at the cost of some overshoot at hard edges.
+	MipFilterKaiser
+)
+
+// MipFilters lists the filters, for choosing between them.
+var MipFilters = []MipFilter{MipFilterBox, MipFilterKaiser}
+
+func (f MipFilter) String() string {
+	switch f {
+	case MipFilterBox:
+		return "Box"
+	case MipFilterKaiser:
+		return "Kaiser"
+	}
+	return fmt.Sprintf("MipFilter(%d)", int(f))
+}
+
+const (
+	// kaiserRadius is the half width of the Kaiser filter, in texels of the
+	// level being made
+	kaiserRadius = 3
+	// kaiserAlpha shapes the Kaiser window; larger values taper it faster
+	kaiserAlpha = 4
+)
+
+// MipLevels returns the number of levels in a full mip chain for a width by
+// height image, including the image itself.
+func MipLevels(width, height int) int {
+	levels := 1
+	for width > 1 || height > 1 {
+		width, height = max(width/2, 1), max(height/2, 1)
+		levels++
+	}
+	return levels
+}
+
+// GenerateMipMaps returns the levels after the first of a full mip chain for
+// img, each half the size of the last, rounding down, until 1x1. They have
+// img's pixel type. Each level is filtered from img itself, on its stored
+// values, which are linear, so HDR values average correctly.
+func GenerateMipMaps(img hdrColors.RawImage, filter MipFilter) ([]hdrColors.RawImage, error) {
+	newImage := newRawImage(img.ColorModel())
+	if newImage == nil {
+		return nil, fmt.Errorf("image does not have an HDR color model")
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	src := make([][4]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			src[y*width+x] = img.RawAt(bounds.Min.X+x, bounds.Min.Y+y)
+		}
+	}
+
+	levels := make([]hdrColors.RawImage, 0, MipLevels(width, height)-1)
+	mipWidth, mipHeight := width, height
+	for mipWidth > 1 || mipHeight > 1 {
+		mipWidth, mipHeight = max(mipWidth/2, 1), max(mipHeight/2, 1)
+		columns := mipWeights(width, mipWidth, filter)
+		rows := mipWeights(height, mipHeight, filter)
+
+		// Filter the rows, then the columns of the result
+		tmp := make([][4]float64, mipWidth*height)
+		for y := 0; y < height; y++ {
+			for x, taps := range columns {
+				tmp[y*mipWidth+x] = weighted(taps, func(i int) [4]float64 { return src[y*width+i] })
+			}
+		}
+		level := newImage(image.Rect(0, 0, mipWidth, mipHeight))
+		for y, taps := range rows {
+			for x := 0; x < mipWidth; x++ {
+				level.SetRaw(x, y, weighted(taps, func(i int) [4]float64 { return tmp[i*mipWidth+x] }))
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// newRawImage returns the constructor of images with the color model model,
+// or nil if it isn't an HDR model.
+func newRawImage(model color.Model) func(image.Rectangle) hdrColors.RawImage {
+	for _, format := range hdrColors.Formats {
+		if format.Model == model {
+			return format.New
+		}
+	}
+	return nil
+}
+
+// mipTap is the weight of one source texel in a texel of a smaller level.
+type mipTap struct {
+	index  int
+	weight float64
+}
+
+// mipWeights returns the taps making each of the n texels of a row or column
+// of a smaller level from a row or column of size texels.
+func mipWeights(size, n int, filter MipFilter) [][]mipTap {
+	scale := float64(size) / float64(n)
+	weights := make([][]mipTap, n)
+	for j := range weights {
+		var taps []mipTap
+		switch filter {
+		case MipFilterKaiser:
+			center := (float64(j) + 0.5) * scale
+			first := int(math.Floor(center - kaiserRadius*scale))
+			last := int(math.Ceil(center + kaiserRadius*scale))
+			for i := first; i <= last; i++ {
+				// Distance in texels of the smaller level
+				d := (float64(i) + 0.5 - center) / scale
+				if math.Abs(d) >= kaiserRadius {
+					continue
+				}
+				// Texels past the edge repeat the edge texel
+				taps = append(taps, mipTap{index: min(max(i, 0), size-1), weight: sinc(d) * kaiser(d/kaiserRadius)})
+			}
+		default:
+			start, end := float64(j)*scale, float64(j+1)*scale
+			for i := int(start); float64(i) < end && i < size; i++ {
+				overlap := min(end, float64(i+1)) - max(start, float64(i))
+				if overlap > 0 {
+					taps = append(taps, mipTap{index: i, weight: overlap})
+				}
+			}
+		}
+		var total float64
+		for _, tap := range taps {
+			total += tap.weight
+		}
+		for i := range taps {
+			taps[i].weight /= total
+		}
+		weights[j] = taps
+	}
+	return weights
+}
+
+// weighted returns the sum of the texels at taps, read with at, by their
+// weights.
+func weighted(taps []mipTap, at func(i int) [4]float64) [4]float64 {
+	var c [4]float64
+	for _, tap := range taps {
+		texel := at(tap.index)
+		for ch := range c {
+			c[ch] += texel[ch] * tap.weight
+		}
+	}
+	return c
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser is the Kaiser window at t, from -1 to 1.
+func kaiser(t float64) float64 {
+	return besselI0(kaiserAlpha*math.Sqrt(max(1-t*t, 0))) / besselI0(kaiserAlpha)
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
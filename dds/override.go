@@ -0,0 +1,111 @@
+package dds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// OverrideFormats are the formats DecodeAs can read texture data as.
+var OverrideFormats = []DXGIFormat{
+	DXGIFormatR32G32B32A32Float,
+	DXGIFormatR16G16B16A16Float,
+	DXGIFormatR8G8B8A8UNorm,
+	DXGIFormatR32Float,
+	DXGIFormatR16UNorm,
+	DXGIFormatR8UNorm,
+	DXGIFormatBC1UNorm,
+	DXGIFormatBC3UNorm,
+	DXGIFormatBC4UNorm,
+	DXGIFormatBC5UNorm,
+	DXGIFormatBC6HUF16,
+	DXGIFormatBC6HSF16,
+	DXGIFormatBC7UNorm,
+}
+
+// DecodeAs reads the first width by height image of r as texture data in
+// format, whatever its header says. This opens extracted files whose header
+// is wrong or missing: a DDS header, with its DX10 extension if the header
+// has one, is skipped, and anything else is read as raw texture data.
+func DecodeAs(r io.Reader, format DXGIFormat, width, height int) (*DDS, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	colorModel, decompress, err := formatDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+	if r, err = skipHeader(r); err != nil {
+		return nil, err
+	}
+
+	info := Info{
+		// A header like the one the data would have had, so that saving
+		// writes a file that reads back
+		Header: Header{
+			Size:        124,
+			Flags:       HeaderFlagCaps | HeaderFlagHeight | HeaderFlagWidth | HeaderFlagPixelFormat,
+			Width:       uint32(width),
+			Height:      uint32(height),
+			MipMapCount: 1,
+			PixelFormat: PixelFormat{
+				Size:   32,
+				Flags:  PixelFormatFlagFourCC,
+				FourCC: [4]byte{'D', 'X', '1', '0'},
+			},
+			Caps: CapsTexture,
+		},
+		DXT10Header: &DXT10Header{
+			DXGIFormat:        format,
+			ResourceDimension: D3D10ResourceDimensionTexture2D,
+			ArraySize:         1,
+		},
+		Decompress: decompress,
+		ColorModel: colorModel,
+		NumMipMaps: 1,
+		NumImages:  1,
+	}
+	img, err := decodeMipMap(r, info, width, height)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("not enough data for a %dx%d %v image", width, height, format)
+	} else if err != nil {
+		return nil, err
+	}
+	mipMaps := []*DDSMipMap{{
+		Image:  img,
+		Width:  width,
+		Height: height,
+	}}
+	return &DDS{
+		Image:  img,
+		Info:   info,
+		Images: []*DDSImage{{Image: img, MipMaps: mipMaps}},
+	}, nil
+}
+
+// skipHeader returns the data following r's DDS header, or all of r if it
+// doesn't start with one. The header is not validated.
+func skipHeader(r io.Reader) (io.Reader, error) {
+	var magicNum [4]uint8
+	n, err := io.ReadFull(r, magicNum[:])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return bytes.NewReader(magicNum[:n]), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if magicNum != [4]uint8{'D', 'D', 'S', ' '} {
+		return io.MultiReader(bytes.NewReader(magicNum[:]), r), nil
+	}
+	var hdr Header
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	if hdr.PixelFormat.Flags&PixelFormatFlagFourCC != 0 && hdr.PixelFormat.FourCC == [4]byte{'D', 'X', '1', '0'} {
+		if _, err := DecodeDXT10Header(r); err != nil {
+			return nil, fmt.Errorf("reading DX10 header: %v", err)
+		}
+	}
+	return r, nil
+}
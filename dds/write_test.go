@@ -0,0 +1,151 @@
+package dds_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// TestWriteHDRRoundTrip saves an image of each pixel type new images can be
+// made in and checks it reads back the same, mipmaps included.
+func TestWriteHDRRoundTrip(t *testing.T) {
+	for _, format := range hdrColors.Formats {
+		want := format.New(image.Rect(0, 0, 5, 3))
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				want.SetRaw(x, y, [4]float64{float64(x) / 4, float64(y) / 2, 0.5, 1})
+			}
+		}
+		var buf bytes.Buffer
+		if err := dds.WriteHDRWithOptions(&buf, want, dds.WriteHDROptions{MipMaps: true}); err != nil {
+			t.Fatalf("%s: %v", format.Name, err)
+		}
+		img, err := dds.Decode(&buf, true)
+		if err != nil {
+			t.Fatalf("%s: %v", format.Name, err)
+		}
+		if img.ColorModel() != format.Model {
+			t.Fatalf("%s: read back as %v", format.Name, img.ColorModel())
+		}
+		got, ok := img.Image.(hdrColors.RawImage)
+		if !ok {
+			t.Fatalf("%s: read back without raw values", format.Name)
+		}
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				if got.RawAt(x, y) != want.RawAt(x, y) {
+					t.Fatalf("%s: texel (%d, %d) is %v, want %v", format.Name, x, y, got.RawAt(x, y), want.RawAt(x, y))
+				}
+			}
+		}
+		if levels := len(img.Images[0].MipMaps); levels != dds.MipLevels(5, 3) {
+			t.Fatalf("%s: read back %d levels, want %d", format.Name, levels, dds.MipLevels(5, 3))
+		}
+	}
+}
+
+// bc6hBlock is a mode 0x03 BC6H block with both endpoints at the largest
+// unsigned value, so every texel decodes to the largest half float.
+var bc6hBlock = []byte{0xe3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0, 0, 0, 0, 0, 0, 0}
+
+// TestWriteHDRDecodedBC6H saves a BC6H texture, which is kept as the half
+// floats it decodes to, and checks the file says so and reads back the same.
+func TestWriteHDRDecodedBC6H(t *testing.T) {
+	var file bytes.Buffer
+	file.WriteString("DDS ")
+	binary.Write(&file, binary.LittleEndian, dds.Header{
+		Size:              124,
+		Flags:             dds.HeaderFlagCaps | dds.HeaderFlagHeight | dds.HeaderFlagWidth | dds.HeaderFlagPixelFormat | dds.HeaderFlagLinearsize,
+		Width:             4,
+		Height:            4,
+		PitchOrLinearSize: uint32(len(bc6hBlock)),
+		MipMapCount:       1,
+		PixelFormat: dds.PixelFormat{
+			Size:   32,
+			Flags:  dds.PixelFormatFlagFourCC,
+			FourCC: [4]byte{'D', 'X', '1', '0'},
+		},
+		Caps: dds.CapsTexture,
+	})
+	binary.Write(&file, binary.LittleEndian, dds.DXT10Header{
+		DXGIFormat:        dds.DXGIFormatBC6HUF16,
+		ResourceDimension: dds.D3D10ResourceDimensionTexture2D,
+		ArraySize:         1,
+	})
+	file.Write(bc6hBlock)
+	img, err := dds.Decode(&file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSavedAsHalfFloats(t, img)
+}
+
+// TestWriteHDRDecodedAs saves textures read with DecodeAs, which have no
+// header of their own, and checks they read back the same.
+func TestWriteHDRDecodedAs(t *testing.T) {
+	img, err := dds.DecodeAs(bytes.NewReader(bc6hBlock), dds.DXGIFormatBC6HUF16, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSavedAsHalfFloats(t, img)
+
+	half := bytes.Repeat([]byte{0x00, 0x3c}, 4*2*3)
+	img, err = dds.DecodeAs(bytes.NewReader(half), dds.DXGIFormatR16G16B16A16Float, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := dds.WriteHDR(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := dds.Decode(&buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := saved.Image.(*hdrColors.NRGBA64FImage)
+	if !ok {
+		t.Fatalf("read back as %T, want half floats", saved.Image)
+	}
+	if !bytes.Equal(got.Pix, half) {
+		t.Fatalf("read back %x, want %x", got.Pix, half)
+	}
+}
+
+// checkSavedAsHalfFloats saves img, decoded from a compressed format, and
+// checks it reads back as uncompressed half floats with the same texels.
+func checkSavedAsHalfFloats(t *testing.T, img *dds.DDS) {
+	t.Helper()
+	want, ok := img.Image.(*hdrColors.NRGBA64FImage)
+	if !ok {
+		t.Fatalf("decoded as %T, want half floats", img.Image)
+	}
+	// Every texel is the largest half float, with an alpha of 1
+	if texel := want.Pix[:8]; !bytes.Equal(texel, []byte{0xff, 0x7b, 0xff, 0x7b, 0xff, 0x7b, 0x00, 0x3c}) {
+		t.Fatalf("decoded texel (0, 0) as %x", texel)
+	}
+	var buf bytes.Buffer
+	if err := dds.WriteHDR(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := dds.Decode(&buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := saved.Info.DXT10Header.DXGIFormat; format != dds.DXGIFormatR16G16B16A16Float {
+		t.Fatalf("saved as %v, want %v", format, dds.DXGIFormatR16G16B16A16Float)
+	}
+	if pitch := saved.Info.Header.PitchOrLinearSize; saved.Info.Header.Flags&dds.HeaderFlagPitch == 0 || pitch != 4*8 {
+		t.Fatalf("saved with flags %#x and pitch %d, want a pitch of %d", saved.Info.Header.Flags, pitch, 4*8)
+	}
+	got, ok := saved.Image.(*hdrColors.NRGBA64FImage)
+	if !ok {
+		t.Fatalf("read back as %T, want half floats", saved.Image)
+	}
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Fatalf("read back %x, want %x", got.Pix, want.Pix)
+	}
+}
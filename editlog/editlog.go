@@ -0,0 +1,172 @@
+// Package editlog records the edits made to an image as a portable log of the
+// texels each one changed, so they can be replayed onto another copy of the
+// image the edits started from. Replaying merges them with the edits already
+// made to that copy, so two modders can work on the same LUT independently.
+package editlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ryanjsims/hd2-lut-editor/changes"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Extension is the extension of edit log files.
+const Extension = ".lutlog"
+
+// Version is the newest edit log format this package understands.
+const Version = 1
+
+// Texel is a texel an edit changed, with its values before and after.
+type Texel struct {
+	X      int        `json:"x"`
+	Y      int        `json:"y"`
+	Before [4]float64 `json:"before"`
+	After  [4]float64 `json:"after"`
+}
+
+// Step is one edit, named after the undo history entry it came from.
+type Step struct {
+	Action string  `json:"action"`
+	Texels []Texel `json:"texels"`
+}
+
+type Log struct {
+	Version int `json:"version"`
+	// Source is the name of the file the edits were made to
+	Source string `json:"source,omitempty"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Steps  []Step `json:"steps"`
+}
+
+// New returns an empty log of edits to a width by height image.
+func New(source string, width, height int) *Log {
+	return &Log{Version: Version, Source: source, Width: width, Height: height, Steps: make([]Step, 0)}
+}
+
+// Add appends the texels that differ between before and after as a step
+// named action, and returns how many there were. Nothing is added if they
+// are the same.
+func (l *Log) Add(action string, before, after hdrColors.RawImage) (int, error) {
+	if after.Bounds().Dx() != l.Width || after.Bounds().Dy() != l.Height {
+		return 0, fmt.Errorf("'%s' changed the image size to %dx%d, which an edit log can't replay", action, after.Bounds().Dx(), after.Bounds().Dy())
+	}
+	diff, err := changes.Diff(before, after)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' changed the image size: %v", action, err)
+	}
+	if len(diff) == 0 {
+		return 0, nil
+	}
+	step := Step{Action: action, Texels: make([]Texel, len(diff))}
+	for i, c := range diff {
+		step.Texels[i] = Texel{X: c.X, Y: c.Y, Before: c.Before, After: c.After}
+	}
+	l.Steps = append(l.Steps, step)
+	return len(diff), nil
+}
+
+// Texels returns the number of texel changes in the log.
+func (l *Log) Texels() int {
+	n := 0
+	for _, step := range l.Steps {
+		n += len(step.Texels)
+	}
+	return n
+}
+
+// Conflict is a texel an edit changed that had also been changed differently
+// in the image the log was applied to. Those channels are left alone.
+type Conflict struct {
+	Action string
+	X, Y   int
+	// Channels are the indexes of the conflicting channels
+	Channels []int
+	// Current is the texel as it was left, and Want is what the edit set it to
+	Current, Want [4]float64
+}
+
+// Result is what applying a log did.
+type Result struct {
+	// Changed is the number of texel changes applied, counting a texel once
+	// for each step that changed it
+	Changed int
+	// Matched is the number of texel changes the image already had
+	Matched   int
+	Conflicts []Conflict
+}
+
+// Apply replays the steps of the log onto img, which must be the size the log
+// was recorded at. Each channel a step changed is set to its new value if img
+// still has the value it was changed from. If img already has the new value
+// it is left as it is, and if img has some other value, the channel was also
+// edited in img, so it is kept and reported as a conflict.
+func (l *Log) Apply(img hdrColors.RawImage) (Result, error) {
+	var result Result
+	bounds := img.Bounds()
+	if bounds.Dx() != l.Width || bounds.Dy() != l.Height {
+		return result, fmt.Errorf("the log is for a %dx%d image but the image is %dx%d", l.Width, l.Height, bounds.Dx(), bounds.Dy())
+	}
+	for _, step := range l.Steps {
+		for _, texel := range step.Texels {
+			if texel.X < 0 || texel.Y < 0 || texel.X >= l.Width || texel.Y >= l.Height {
+				return result, fmt.Errorf("'%s' changes texel (%d, %d), outside the image", step.Action, texel.X, texel.Y)
+			}
+			x, y := bounds.Min.X+texel.X, bounds.Min.Y+texel.Y
+			current := img.RawAt(x, y)
+			next := current
+			var conflicting []int
+			for ch := range current {
+				switch {
+				case texel.Before[ch] == texel.After[ch] || current[ch] == texel.After[ch]:
+				case current[ch] == texel.Before[ch]:
+					next[ch] = texel.After[ch]
+				default:
+					conflicting = append(conflicting, ch)
+				}
+			}
+			switch {
+			case len(conflicting) > 0:
+				result.Conflicts = append(result.Conflicts, Conflict{Action: step.Action, X: texel.X, Y: texel.Y, Channels: conflicting, Current: current, Want: texel.After})
+			case next == current:
+				result.Matched++
+			}
+			if next != current {
+				img.SetRaw(x, y, next)
+				result.Changed++
+			}
+		}
+	}
+	return result, nil
+}
+
+// Load reads the edit log at path.
+func Load(path string) (*Log, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var l Log
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("invalid edit log: %v", err)
+	}
+	if l.Version > Version {
+		return nil, fmt.Errorf("edit log version %d is newer than this editor supports (%d)", l.Version, Version)
+	}
+	if l.Width <= 0 || l.Height <= 0 {
+		return nil, fmt.Errorf("invalid edit log: image size %dx%d", l.Width, l.Height)
+	}
+	return &l, nil
+}
+
+// Save writes l to path.
+func Save(path string, l *Log) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,92 @@
+package editor
+
+import (
+	"image"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Copy returns a new image of the same format holding the texels of img in
+// selection, with its top left texel at (0, 0). It returns nil for images
+// that aren't HDR.
+func Copy(img image.Image, selection image.Rectangle) image.Image {
+	switch img.ColorModel() {
+	case hdrColors.NRGBA128FModel:
+		hdr, ok := img.(*hdrColors.NRGBA128FImage)
+		if !ok {
+			ddsImg, ok := img.(*dds.DDS)
+			if !ok {
+				break
+			}
+			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128FImage)
+			if !ok {
+				break
+			}
+		}
+		oldGray := hdr.Grayscale
+		hdr.SetGray(hdrColors.GraySettingNone)
+		toReturn := hdrColors.NewNRGBA128FImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
+		for y := selection.Min.Y; y < selection.Max.Y; y++ {
+			retY := y - selection.Min.Y
+			for x := selection.Min.X; x < selection.Max.X; x++ {
+				retX := x - selection.Min.X
+				toReturn.Set(retX, retY, hdr.NRGBA128FAt(x, y))
+			}
+		}
+		toReturn.SetGray(oldGray)
+		hdr.SetGray(oldGray)
+		return toReturn
+	case hdrColors.NRGBA128UModel:
+		hdr, ok := img.(*hdrColors.NRGBA128UImage)
+		if !ok {
+			ddsImg, ok := img.(*dds.DDS)
+			if !ok {
+				break
+			}
+			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA128UImage)
+			if !ok {
+				break
+			}
+		}
+		oldGray := hdr.Grayscale
+		hdr.SetGray(hdrColors.GraySettingNone)
+		toReturn := hdrColors.NewNRGBA128UImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
+		for y := selection.Min.Y; y < selection.Max.Y; y++ {
+			retY := y - selection.Min.Y
+			for x := selection.Min.X; x < selection.Max.X; x++ {
+				retX := x - selection.Min.X
+				toReturn.Set(retX, retY, hdr.NRGBA128UAt(x, y))
+			}
+		}
+		toReturn.SetGray(oldGray)
+		hdr.SetGray(oldGray)
+		return toReturn
+	case hdrColors.NRGBA64FModel:
+		hdr, ok := img.(*hdrColors.NRGBA64FImage)
+		if !ok {
+			ddsImg, ok := img.(*dds.DDS)
+			if !ok {
+				break
+			}
+			hdr, ok = ddsImg.Image.(*hdrColors.NRGBA64FImage)
+			if !ok {
+				break
+			}
+		}
+		oldGray := hdr.Grayscale
+		hdr.SetGray(hdrColors.GraySettingNone)
+		toReturn := hdrColors.NewNRGBA64FImage(image.Rect(0, 0, selection.Dx(), selection.Dy()))
+		for y := selection.Min.Y; y < selection.Max.Y; y++ {
+			retY := y - selection.Min.Y
+			for x := selection.Min.X; x < selection.Max.X; x++ {
+				retX := x - selection.Min.X
+				toReturn.Set(retX, retY, hdr.NRGBA64FAt(x, y))
+			}
+		}
+		toReturn.SetGray(oldGray)
+		hdr.SetGray(oldGray)
+		return toReturn
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Package editor edits LUT images independently of the user interface, so
+// the same edits can be made from the main window, the console, scripts or the
+// command line. Each edit is an Operation applied to a Document, which returns
+// a Change holding the texels it overwrote so it can be undone.
+package editor
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/mask"
+)
+
+// Document is an image being edited.
+type Document struct {
+	Image image.Image
+	// Locked texels are left alone by every operation. It may be nil
+	Locked *mask.Mask
+}
+
+// Operation is an edit of an image.
+type Operation interface {
+	// Name describes the operation, e.g. for an undo history
+	Name() string
+	// Area returns the texels of img the operation may change
+	Area(img image.Image) []image.Rectangle
+	// Apply edits img. It changes nothing outside Area, and pays no attention
+	// to locked texels, which Document.Apply puts back afterwards.
+	Apply(img hdrColors.RawImage) error
+}
+
+// Raw returns the stored texels of img, looking inside DDS images.
+func Raw(img image.Image) (hdrColors.RawImage, bool) {
+	if ddsImg, ok := img.(*dds.DDS); ok {
+		img = ddsImg.Image
+	}
+	raw, ok := img.(hdrColors.RawImage)
+	return raw, ok
+}
+
+// Apply applies op to the document's image, leaving locked texels unchanged,
+// and returns the change it made. The image is unchanged if op fails.
+func (d *Document) Apply(op Operation) (*Change, error) {
+	raw, ok := Raw(d.Image)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an HDR image", op.Name())
+	}
+	change := &Change{Name: op.Name()}
+	bounds := raw.Bounds()
+	for _, rect := range op.Area(d.Image) {
+		if rect = rect.Intersect(bounds); !rect.Empty() {
+			change.patches = append(change.patches, patch{rect: rect, before: readRect(raw, rect)})
+		}
+	}
+	restore := func() {}
+	if d.Locked != nil && d.Locked.Len() > 0 {
+		restore = d.Locked.Protect(raw)
+	}
+	err := op.Apply(raw)
+	restore()
+	if err != nil {
+		change.revert(raw)
+		return nil, fmt.Errorf("%s: %v", op.Name(), err)
+	}
+	for i := range change.patches {
+		change.patches[i].after = readRect(raw, change.patches[i].rect)
+	}
+	return change, nil
+}
+
+// Change records the texels an operation changed, before and after.
+type Change struct {
+	Name    string
+	patches []patch
+}
+
+type patch struct {
+	rect          image.Rectangle
+	before, after [][4]float64
+}
+
+func readRect(raw hdrColors.RawImage, rect image.Rectangle) [][4]float64 {
+	values := make([][4]float64, 0, rect.Dx()*rect.Dy())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			values = append(values, raw.RawAt(x, y))
+		}
+	}
+	return values
+}
+
+func writeRect(raw hdrColors.RawImage, rect image.Rectangle, values [][4]float64) {
+	i := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			raw.SetRaw(x, y, values[i])
+			i++
+		}
+	}
+}
+
+// Area returns the texels the change covers.
+func (c *Change) Area() []image.Rectangle {
+	rects := make([]image.Rectangle, len(c.patches))
+	for i, p := range c.patches {
+		rects[i] = p.rect
+	}
+	return rects
+}
+
+// revert puts back the texels from before the change.
+func (c *Change) revert(raw hdrColors.RawImage) {
+	for _, p := range c.patches {
+		writeRect(raw, p.rect, p.before)
+	}
+}
+
+// Undo puts the texels of d the change covers back as they were before it.
+func (c *Change) Undo(d *Document) error {
+	raw, ok := Raw(d.Image)
+	if !ok {
+		return fmt.Errorf("undo %s: not an HDR image", c.Name)
+	}
+	c.revert(raw)
+	return nil
+}
+
+// Redo makes the change again after Undo.
+func (c *Change) Redo(d *Document) error {
+	raw, ok := Raw(d.Image)
+	if !ok {
+		return fmt.Errorf("redo %s: not an HDR image", c.Name)
+	}
+	for _, p := range c.patches {
+		writeRect(raw, p.rect, p.after)
+	}
+	return nil
+}
+
+// History applies operations to a document and keeps their changes for undo
+// and redo. The zero value is empty and ready to use.
+type History struct {
+	Done   []*Change
+	Undone []*Change
+}
+
+// Do applies op to d and records its change. Any undone changes are dropped.
+func (h *History) Do(d *Document, op Operation) (*Change, error) {
+	change, err := d.Apply(op)
+	if err != nil {
+		return nil, err
+	}
+	h.Done = append(h.Done, change)
+	h.Undone = h.Undone[:0]
+	return change, nil
+}
+
+// Undo reverts the last change made, reporting false if there is none.
+func (h *History) Undo(d *Document) (bool, error) {
+	if len(h.Done) == 0 {
+		return false, nil
+	}
+	change := h.Done[len(h.Done)-1]
+	if err := change.Undo(d); err != nil {
+		return false, err
+	}
+	h.Done = h.Done[:len(h.Done)-1]
+	h.Undone = append(h.Undone, change)
+	return true, nil
+}
+
+// Redo makes the last undone change again, reporting false if there is none.
+func (h *History) Redo(d *Document) (bool, error) {
+	if len(h.Undone) == 0 {
+		return false, nil
+	}
+	change := h.Undone[len(h.Undone)-1]
+	if err := change.Redo(d); err != nil {
+		return false, err
+	}
+	h.Undone = h.Undone[:len(h.Undone)-1]
+	h.Done = append(h.Done, change)
+	return true, nil
+}
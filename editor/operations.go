@@ -0,0 +1,160 @@
+package editor
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Draw blends a color into single texels, as the draw tool does.
+type Draw struct {
+	Points []image.Point
+	Color  [4]float64
+	Mode   blend.Mode
+	Lock   blend.Lock
+	// Stroke, if set, is the stroke the texels are a dab of, which limits how
+	// much of the color they take
+	Stroke *Stroke
+	// Coverage, if set, is how much of the color each of Points takes, from 0
+	// to 1, for the soft edge of a brush. Points without one take all of it.
+	Coverage []float64
+}
+
+func (Draw) Name() string { return "Draw" }
+
+func (op Draw) Area(img image.Image) []image.Rectangle {
+	rects := make([]image.Rectangle, len(op.Points))
+	for i, p := range op.Points {
+		rects[i] = image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))}
+	}
+	return rects
+}
+
+func (op Draw) Apply(img hdrColors.RawImage) error {
+	blended := func(base [4]float64) [4]float64 {
+		return op.Lock.Pixel(op.Mode, base, op.Color)
+	}
+	for i, p := range op.Points {
+		if !p.In(img.Bounds()) {
+			continue
+		}
+		coverage := 1.0
+		if i < len(op.Coverage) {
+			coverage = op.Coverage[i]
+		}
+		current := img.RawAt(p.X, p.Y)
+		if op.Stroke == nil {
+			img.SetRaw(p.X, p.Y, partial(current, blended(current), coverage))
+		} else if c, ok := op.Stroke.dab(p, current, coverage, blended); ok {
+			img.SetRaw(p.X, p.Y, c)
+		}
+	}
+	return nil
+}
+
+// Fill blends a color into every texel of a rectangle.
+type Fill struct {
+	Rect  image.Rectangle
+	Color [4]float64
+	Mode  blend.Mode
+	Lock  blend.Lock
+}
+
+func (Fill) Name() string { return "Fill" }
+
+func (op Fill) Area(img image.Image) []image.Rectangle {
+	return []image.Rectangle{op.Rect}
+}
+
+func (op Fill) Apply(img hdrColors.RawImage) error {
+	rect := op.Rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRaw(x, y, op.Lock.Pixel(op.Mode, img.RawAt(x, y), op.Color))
+		}
+	}
+	return nil
+}
+
+// Combine blends Src into Rect, with the top left texel of Src at Rect.Min,
+// as pasted or moved texels are put down.
+type Combine struct {
+	Rect image.Rectangle
+	Src  image.Image
+	Mode blend.Mode
+	Lock blend.Lock
+}
+
+func (Combine) Name() string { return "Combine" }
+
+func (op Combine) Area(img image.Image) []image.Rectangle {
+	return []image.Rectangle{op.Rect}
+}
+
+func (op Combine) Apply(img hdrColors.RawImage) error {
+	src, ok := Raw(op.Src)
+	if !ok {
+		return fmt.Errorf("source is not an HDR image")
+	}
+	offset := op.Rect.Min.Sub(src.Bounds().Min)
+	rect := op.Rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRaw(x, y, op.Lock.Pixel(op.Mode, img.RawAt(x, y), src.RawAt(x-offset.X, y-offset.Y)))
+		}
+	}
+	return nil
+}
+
+// Cut copies Rect into Result and then clears its unlocked channels to 0.
+// Result keeps every channel.
+type Cut struct {
+	Rect   image.Rectangle
+	Lock   blend.Lock
+	Result image.Image
+}
+
+func (*Cut) Name() string { return "Cut" }
+
+func (op *Cut) Area(img image.Image) []image.Rectangle {
+	return []image.Rectangle{op.Rect}
+}
+
+func (op *Cut) Apply(img hdrColors.RawImage) error {
+	op.Result = Copy(img, op.Rect)
+	if op.Result == nil {
+		return fmt.Errorf("unsupported image format")
+	}
+	return Fill{Rect: op.Rect, Lock: op.Lock}.Apply(img)
+}
+
+// ShiftWrap moves the texels in Rect right by DX and down by DY. Texels pushed
+// past one edge of Rect come back in at the opposite edge.
+type ShiftWrap struct {
+	Rect   image.Rectangle
+	DX, DY int
+}
+
+func (ShiftWrap) Name() string { return "Shift with Wrap" }
+
+func (op ShiftWrap) Area(img image.Image) []image.Rectangle {
+	return []image.Rectangle{op.Rect}
+}
+
+func (op ShiftWrap) Apply(img hdrColors.RawImage) error {
+	rect := op.Rect.Intersect(img.Bounds())
+	if rect.Empty() {
+		return nil
+	}
+	w, h := rect.Dx(), rect.Dy()
+	dx, dy := ((op.DX%w)+w)%w, ((op.DY%h)+h)%h
+	original := readRect(img, rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRaw(rect.Min.X+(x+dx)%w, rect.Min.Y+(y+dy)%h, original[y*w+x])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package editor
+
+import "image"
+
+// Stroke builds up a color over one stroke of the draw tool. Each dab over a
+// texel covers Flow more of it, up to Opacity, blending from the value the
+// texel had when the stroke first reached it. Blending is done on the stored
+// values, which are linear, so partial coverage mixes HDR values evenly.
+// Going over a texel again in the same stroke adds no more than Opacity in
+// total; a new stroke builds on the result. Texels under the soft edge of a
+// brush take a share of both Flow and Opacity.
+type Stroke struct {
+	Opacity  float64
+	Flow     float64
+	base     map[image.Point][4]float64
+	coverage map[image.Point]float64
+}
+
+func NewStroke(opacity, flow float64) *Stroke {
+	return &Stroke{
+		Opacity:  min(max(opacity, 0), 1),
+		Flow:     min(max(flow, 0), 1),
+		base:     make(map[image.Point][4]float64),
+		coverage: make(map[image.Point]float64),
+	}
+}
+
+// dab covers p further, by weight of a full dab, and returns the value it
+// should have, given its current value and the fully covered value blended
+// from its base. It reports false if p is already covered as far as Opacity
+// and weight allow.
+func (s *Stroke) dab(p image.Point, current [4]float64, weight float64, blended func(base [4]float64) [4]float64) ([4]float64, bool) {
+	base, ok := s.base[p]
+	if !ok {
+		base = current
+		s.base[p] = base
+	}
+	coverage := min(s.coverage[p]+s.Flow*weight, s.Opacity*weight)
+	if coverage <= s.coverage[p] {
+		return current, false
+	}
+	s.coverage[p] = coverage
+	return partial(base, blended(base), coverage), true
+}
+
+// partial returns base covered by coverage of full.
+func partial(base, full [4]float64, coverage float64) [4]float64 {
+	if coverage >= 1 {
+		return full
+	}
+	var c [4]float64
+	for i := range c {
+		c[i] = base[i] + (full[i]-base[i])*coverage
+	}
+	return c
+}
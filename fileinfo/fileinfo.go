@@ -0,0 +1,221 @@
+// Package fileinfo summarizes the headers of DDS and OpenEXR files as
+// name/value pairs, so that files of either format can be compared.
+package fileinfo
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+)
+
+type Field struct {
+	Name  string
+	Value string
+}
+
+type Info struct {
+	Path   string
+	Fields []Field
+}
+
+// Row is one line of a comparison between two files. A value is empty if that
+// file does not have the field.
+type Row struct {
+	Name     string
+	Values   [2]string
+	Mismatch bool
+}
+
+func (i *Info) add(name, format string, args ...any) {
+	i.Fields = append(i.Fields, Field{Name: name, Value: fmt.Sprintf(format, args...)})
+}
+
+func (i *Info) Get(name string) (string, bool) {
+	for _, field := range i.Fields {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// Load reads the header of a DDS or EXR file.
+func Load(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &Info{Path: path}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dds":
+		ddsInfo, err := dds.DecodeInfo(f)
+		if err != nil {
+			return nil, err
+		}
+		describeDDS(info, ddsInfo)
+	case ".exr":
+		hdr, err := openexr.LoadOpenEXRHeader(f)
+		if err != nil {
+			return nil, err
+		}
+		describeEXR(info, hdr)
+	default:
+		return nil, fmt.Errorf("unsupported file type %v", filepath.Ext(path))
+	}
+	return info, nil
+}
+
+// Compare lines up the fields of a and b by name, in the order they first
+// appear in a and then b.
+func Compare(a, b *Info) []Row {
+	rows := make([]Row, 0, len(a.Fields)+len(b.Fields))
+	index := make(map[string]int)
+	for side, info := range []*Info{a, b} {
+		for _, field := range info.Fields {
+			i, ok := index[field.Name]
+			if !ok {
+				i = len(rows)
+				index[field.Name] = i
+				rows = append(rows, Row{Name: field.Name})
+			}
+			rows[i].Values[side] = field.Value
+		}
+	}
+	for i := range rows {
+		rows[i].Mismatch = rows[i].Values[0] != rows[i].Values[1]
+	}
+	return rows
+}
+
+func ModelName(model color.Model) string {
+	switch model {
+	case hdrColors.NRGBA128FModel:
+		return "float32"
+	case hdrColors.NRGBA64FModel:
+		return "float16"
+	case hdrColors.NRGBA128UModel:
+		return "uint32"
+	case color.NRGBAModel:
+		return "uint8"
+	case color.NRGBA64Model:
+		return "uint16"
+	case color.GrayModel:
+		return "uint8 gray"
+	case color.Gray16Model:
+		return "uint16 gray"
+	}
+	return "other"
+}
+
+func describeDDS(info *Info, ddsInfo dds.Info) {
+	hdr := ddsInfo.Header
+	info.add("Container", "DDS")
+	info.add("Width", "%d", hdr.Width)
+	info.add("Height", "%d", hdr.Height)
+	info.add("Pixel type", "%s", ModelName(ddsInfo.ColorModel))
+	info.add("Mip maps", "%d", ddsInfo.NumMipMaps)
+	info.add("Images", "%d", ddsInfo.NumImages)
+	info.add("Depth", "%d", hdr.Depth)
+	info.add("Header flags", "0x%08x", uint32(hdr.Flags))
+	info.add("Pitch or linear size", "%d", hdr.PitchOrLinearSize)
+	info.add("Caps", "0x%08x", uint32(hdr.Caps))
+	info.add("Caps2", "0x%08x", uint32(hdr.Caps2))
+	info.add("Pixel format flags", "0x%08x", uint32(hdr.PixelFormat.Flags))
+	if hdr.PixelFormat.Flags&dds.PixelFormatFlagFourCC != 0 {
+		info.add("FourCC", "%q", string(hdr.PixelFormat.FourCC[:]))
+	} else {
+		info.add("RGB bit count", "%d", hdr.PixelFormat.RGBBitCount)
+		info.add("Bit masks", "R 0x%08x G 0x%08x B 0x%08x A 0x%08x", hdr.PixelFormat.RBitMask, hdr.PixelFormat.GBitMask, hdr.PixelFormat.BBitMask, hdr.PixelFormat.ABitMask)
+	}
+	if dx10 := ddsInfo.DXT10Header; dx10 != nil {
+		info.add("DXGI format", "%s", dx10.DXGIFormat)
+		info.add("Resource dimension", "%d", dx10.ResourceDimension)
+		info.add("Misc flags", "0x%08x", uint32(dx10.MiscFlag))
+		info.add("Array size", "%d", dx10.ArraySize)
+		info.add("Alpha mode", "%d", dx10.MiscFlags2)
+	}
+}
+
+func describeEXR(info *Info, hdr *openexr.OpenEXRHeader) {
+	info.add("Container", "OpenEXR")
+	info.add("Width", "%d", hdr.DataWindow.Width())
+	info.add("Height", "%d", hdr.DataWindow.Height())
+	pixelTypes := make([]string, 0, len(hdr.Channels))
+	for _, channel := range hdr.Channels {
+		if len(pixelTypes) == 0 || pixelTypes[len(pixelTypes)-1] != channel.PixelFmt.String() {
+			pixelTypes = append(pixelTypes, channel.PixelFmt.String())
+		}
+	}
+	info.add("Pixel type", "%s", strings.Join(pixelTypes, ", "))
+	info.add("Mip maps", "1")
+	info.add("Images", "1")
+	info.add("Version", "%d", hdr.Version)
+	info.add("Compression", "%v", hdr.Compression)
+	info.add("Line order", "%v", hdr.LineOrder)
+	if hdr.Tiles != nil {
+		info.add("Tiles", "%v", hdr.Tiles)
+	}
+	info.add("Data window", "(%d, %d) - (%d, %d)", hdr.DataWindow.XMin, hdr.DataWindow.YMin, hdr.DataWindow.XMax, hdr.DataWindow.YMax)
+	info.add("Display window", "(%d, %d) - (%d, %d)", hdr.DisplayWindow.XMin, hdr.DisplayWindow.YMin, hdr.DisplayWindow.XMax, hdr.DisplayWindow.YMax)
+	info.add("Pixel aspect ratio", "%g", hdr.PixelAspectRatio)
+	info.add("Screen window", "center (%g, %g), width %g", hdr.ScreenWindowCenter[0], hdr.ScreenWindowCenter[1], hdr.ScreenWindowWidth)
+	info.add("Channels", "%d", len(hdr.Channels))
+	for _, channel := range hdr.Channels {
+		info.add("Channel "+channel.Name, "%v, linear %d, sampling %dx%d", channel.PixelFmt, channel.Linear, channel.XSampling, channel.YSampling)
+	}
+	info.Fields = append(info.Fields, DescribeAttributes(hdr.Attributes)...)
+}
+
+// DescribeAttributes lists optional EXR attributes with their values.
+func DescribeAttributes(attrs []openexr.Attribute) []Field {
+	fields := make([]Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = append(fields, Field{
+			Name:  fmt.Sprintf("Attribute %s (%s)", attr.Name, attr.Type),
+			Value: AttributeValue(attr),
+		})
+	}
+	return fields
+}
+
+// AttributeValue formats the common EXR attribute types, falling back to a hex
+// dump for anything else.
+func AttributeValue(attr openexr.Attribute) string {
+	data := attr.Data
+	switch {
+	case attr.Type == "string":
+		return fmt.Sprintf("%q", string(data))
+	case attr.Type == "chromaticities" && len(data) == 32:
+		values := make([]float32, 8)
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		return fmt.Sprintf("R (%g, %g) G (%g, %g) B (%g, %g) W (%g, %g)", values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7])
+	case attr.Type == "preview" && len(data) >= 8:
+		return fmt.Sprintf("%dx%d thumbnail", binary.LittleEndian.Uint32(data), binary.LittleEndian.Uint32(data[4:]))
+	case attr.Type == "int" && len(data) == 4:
+		return fmt.Sprint(int32(binary.LittleEndian.Uint32(data)))
+	case (attr.Type == "float" || attr.Type == "v2f" || attr.Type == "v3f") && len(data)%4 == 0:
+		values := make([]string, len(data)/4)
+		for i := range values {
+			values[i] = fmt.Sprintf("%g", math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+		}
+		return strings.Join(values, ", ")
+	}
+	const maxDumpBytes = 32
+	if len(data) > maxDumpBytes {
+		return fmt.Sprintf("%s... (%d bytes)", hex.EncodeToString(data[:maxDumpBytes]), len(data))
+	}
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"image"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// FeatherWeight is how much of an edit is kept at p, inside rect with its
+// edges softened over radius texels: 1 at least radius texels in from the
+// edge, falling linearly towards the edge texels. A rect one texel tall or
+// wide, such as a single LUT row, only fades along its length.
+func FeatherWeight(rect image.Rectangle, radius int, p image.Point) float64 {
+	if radius <= 0 {
+		return 1
+	}
+	d := radius
+	if rect.Dx() > 1 {
+		d = min(d, p.X-rect.Min.X, rect.Max.X-1-p.X)
+	}
+	if rect.Dy() > 1 {
+		d = min(d, p.Y-rect.Min.Y, rect.Max.Y-1-p.Y)
+	}
+	return min(float64(d+1)/float64(radius+1), 1)
+}
+
+// Feather returns a function that, once an edit has been made to rect, blends
+// each texel back towards its value from before the edit by FeatherWeight,
+// so the edit fades out towards the edges of rect.
+func Feather(img hdrColors.RawImage, rect image.Rectangle, radius int) (apply func()) {
+	rect = rect.Intersect(img.Bounds())
+	if radius <= 0 || rect.Empty() {
+		return func() {}
+	}
+	before := make([][4]float64, 0, rect.Dx()*rect.Dy())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			before = append(before, img.RawAt(x, y))
+		}
+	}
+	return func() {
+		i := 0
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				w := FeatherWeight(rect, radius, image.Pt(x, y))
+				old := before[i]
+				i++
+				if w == 1 {
+					continue
+				}
+				after := img.RawAt(x, y)
+				if after == old {
+					continue
+				}
+				var blended [4]float64
+				for c := range blended {
+					blended[c] = old[c] + (after[c]-old[c])*w
+				}
+				img.SetRaw(x, y, blended)
+			}
+		}
+	}
+}
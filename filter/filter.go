@@ -0,0 +1,26 @@
+// Package filter implements operations that rewrite the pixels of a region of
+// an image, for generating and cleaning up LUT data.
+package filter
+
+import (
+	"image"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// apply replaces every pixel in rect with f of its current value, leaving
+// locked channels alone, and returns how many pixels changed.
+func apply(img hdrColors.RawImage, rect image.Rectangle, lock blend.Lock, f func(x, y int, c [4]float64) [4]float64) int {
+	changed := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			before := img.RawAt(x, y)
+			img.SetRaw(x, y, lock.Pixel(blend.Replace, before, f(x, y, before)))
+			if img.RawAt(x, y) != before {
+				changed++
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"image"
+	"slices"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Stop is a color at a position along a gradient.
+type Stop struct {
+	Pos   float64
+	Color [4]float64
+}
+
+// Gradient is a ramp of HDR colors through its stops, which need not be in
+// order.
+type Gradient []Stop
+
+// At returns the color at pos, mixed linearly between the stops either side
+// of it. Before the first stop and after the last the color is theirs.
+func (g Gradient) At(pos float64) [4]float64 {
+	if len(g) == 0 {
+		return [4]float64{}
+	}
+	stops := g.sorted()
+	if pos <= stops[0].Pos {
+		return stops[0].Color
+	}
+	for i := 1; i < len(stops); i++ {
+		if pos > stops[i].Pos {
+			continue
+		}
+		a, b := stops[i-1], stops[i]
+		t := (pos - a.Pos) / (b.Pos - a.Pos)
+		var c [4]float64
+		for j := range c {
+			c[j] = a.Color[j] + (b.Color[j]-a.Color[j])*t
+		}
+		return c
+	}
+	return stops[len(stops)-1].Color
+}
+
+func (g Gradient) sorted() Gradient {
+	byPos := func(a, b Stop) int {
+		if a.Pos < b.Pos {
+			return -1
+		} else if a.Pos > b.Pos {
+			return 1
+		}
+		return 0
+	}
+	if slices.IsSortedFunc(g, byPos) {
+		return g
+	}
+	return slices.SortedStableFunc(slices.Values(g), byPos)
+}
+
+// GradientMap sets the channels marked in outputs of every texel in rect to
+// the gradient's color at the value of its input channel, and returns how
+// many texels changed. Locked channels are left alone.
+func GradientMap(img hdrColors.RawImage, rect image.Rectangle, g Gradient, input int, outputs [4]bool, lock blend.Lock) int {
+	for i, written := range outputs {
+		lock[i] = lock[i] || !written
+	}
+	g = g.sorted()
+	return apply(img, rect, lock, func(x, y int, c [4]float64) [4]float64 {
+		return g.At(c[input])
+	})
+}
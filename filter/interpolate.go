@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"image"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+type Easing int
+
+const (
+	EasingLinear     Easing = 0
+	EasingSmoothstep Easing = 1
+)
+
+func (e Easing) apply(t float64) float64 {
+	if e == EasingSmoothstep {
+		return t * t * (3 - 2*t)
+	}
+	return t
+}
+
+// Interpolate fills the inside of rect by interpolating between its first and
+// last row, or between its first and last column if columns is true. The
+// first and last rows (or columns) are left as they are.
+func Interpolate(img hdrColors.RawImage, rect image.Rectangle, columns bool, easing Easing, lock blend.Lock) int {
+	steps := rect.Dy() - 1
+	if columns {
+		steps = rect.Dx() - 1
+	}
+	if steps < 2 {
+		return 0
+	}
+	return apply(img, rect, lock, func(x, y int, c [4]float64) [4]float64 {
+		var first, last [4]float64
+		var step int
+		if columns {
+			first, last, step = img.RawAt(rect.Min.X, y), img.RawAt(rect.Max.X-1, y), x-rect.Min.X
+		} else {
+			first, last, step = img.RawAt(x, rect.Min.Y), img.RawAt(x, rect.Max.Y-1), y-rect.Min.Y
+		}
+		if step == 0 || step == steps {
+			return c
+		}
+		t := easing.apply(float64(step) / float64(steps))
+		for i := range c {
+			c[i] = first[i] + (last[i]-first[i])*t
+		}
+		return c
+	})
+}
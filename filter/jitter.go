@@ -0,0 +1,22 @@
+package filter
+
+import (
+	"image"
+	"math/rand/v2"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Jitter adds a uniformly distributed random amount in [minOffset, maxOffset)
+// to every unlocked channel in rect. The same seed always gives the same
+// offsets.
+func Jitter(img hdrColors.RawImage, rect image.Rectangle, minOffset, maxOffset float64, seed uint64, lock blend.Lock) int {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	return apply(img, rect, lock, func(x, y int, c [4]float64) [4]float64 {
+		for i := range c {
+			c[i] += minOffset + rng.Float64()*(maxOffset-minOffset)
+		}
+		return c
+	})
+}
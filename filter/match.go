@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"image"
+	"math"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Levels are the mean and standard deviation of each channel of a region.
+type Levels struct {
+	Mean, StdDev [4]float64
+}
+
+// MeasureLevels returns the levels of rect in img.
+func MeasureLevels(img hdrColors.RawImage, rect image.Rectangle) Levels {
+	var l Levels
+	rect = rect.Intersect(img.Bounds())
+	n := float64(rect.Dx() * rect.Dy())
+	if n == 0 {
+		return l
+	}
+	var sum, sumSq [4]float64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := img.RawAt(x, y)
+			for i, v := range c {
+				sum[i] += v
+				sumSq[i] += v * v
+			}
+		}
+	}
+	for i := range sum {
+		l.Mean[i] = sum[i] / n
+		l.StdDev[i] = math.Sqrt(max(sumSq[i]/n-l.Mean[i]*l.Mean[i], 0))
+	}
+	return l
+}
+
+// MatchLevels scales and offsets each unlocked channel in rect so its mean
+// and standard deviation match those of the same region of ref, and returns
+// how many pixels changed. Channels that are flat in img or ref are only
+// offset.
+func MatchLevels(img, ref hdrColors.RawImage, rect image.Rectangle, lock blend.Lock) int {
+	have, want := MeasureLevels(img, rect), MeasureLevels(ref, rect)
+	var gain [4]float64
+	for i := range gain {
+		gain[i] = 1
+		if have.StdDev[i] > 0 && want.StdDev[i] > 0 {
+			gain[i] = want.StdDev[i] / have.StdDev[i]
+		}
+	}
+	return apply(img, rect.Intersect(img.Bounds()), lock, func(x, y int, c [4]float64) [4]float64 {
+		for i := range c {
+			c[i] = (c[i]-have.Mean[i])*gain[i] + want.Mean[i]
+		}
+		return c
+	})
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// QuantizeStep rounds every unlocked channel in rect to the nearest multiple
+// of step and returns how many pixels changed.
+func QuantizeStep(img hdrColors.RawImage, rect image.Rectangle, step float64, lock blend.Lock) int {
+	if step <= 0 {
+		return 0
+	}
+	return apply(img, rect, lock, func(x, y int, c [4]float64) [4]float64 {
+		for i := range c {
+			c[i] = math.Round(c[i]/step) * step
+		}
+		return c
+	})
+}
+
+// QuantizeValues snaps every unlocked channel in rect to the closest of
+// values and returns how many pixels changed.
+func QuantizeValues(img hdrColors.RawImage, rect image.Rectangle, values []float64, lock blend.Lock) int {
+	if len(values) == 0 {
+		return 0
+	}
+	values = slices.Sorted(slices.Values(values))
+	return apply(img, rect, lock, func(x, y int, c [4]float64) [4]float64 {
+		for i := range c {
+			c[i] = nearest(values, c[i])
+		}
+		return c
+	})
+}
+
+// nearest returns the closest element of the sorted slice values to v.
+func nearest(values []float64, v float64) float64 {
+	i, _ := slices.BinarySearch(values, v)
+	if i == 0 {
+		return values[0]
+	} else if i == len(values) {
+		return values[len(values)-1]
+	}
+	if v-values[i-1] <= values[i]-v {
+		return values[i-1]
+	}
+	return values[i]
+}
+
+// ParseValues reads a list of numbers separated by commas or spaces.
+func ParseValues(s string) ([]float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+	values := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", field)
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no values given")
+	}
+	return values, nil
+}
@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"image"
+	"math"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/colorspace"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// HueSpace is the color model Recolor turns hue and scales saturation in.
+type HueSpace int
+
+const (
+	// HueSpaceHSV keeps each color's value, its largest channel
+	HueSpaceHSV HueSpace = iota
+	// HueSpaceOKLCh keeps each color's perceived lightness
+	HueSpaceOKLCh
+)
+
+// Recolor turns the hue of the R, G and B channels of every texel in rect by
+// degrees and multiplies their saturation by saturation, leaving alpha and
+// locked channels alone. Only columns x with columns[x] set are changed, or
+// every column if columns is nil. Colors are taken to be linear. It returns
+// how many pixels changed.
+func Recolor(img hdrColors.RawImage, rect image.Rectangle, columns []bool, space HueSpace, degrees, saturation float64, lock blend.Lock) int {
+	if degrees == 0 && saturation == 1 {
+		return 0
+	}
+	changed := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if columns != nil && (x < 0 || x >= len(columns) || !columns[x]) {
+				continue
+			}
+			before := img.RawAt(x, y)
+			after := before
+			rgb := [3]float64{before[0], before[1], before[2]}
+			if space == HueSpaceOKLCh {
+				rgb = recolorOKLCh(rgb, degrees, saturation)
+			} else {
+				rgb = recolorHSV(rgb, degrees, saturation)
+			}
+			copy(after[:3], rgb[:])
+			img.SetRaw(x, y, lock.Pixel(blend.Replace, before, after))
+			if img.RawAt(x, y) != before {
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+// recolorHSV shifts rgb in HSV. Values above 1 are kept, so HDR colors keep
+// their brightness.
+func recolorHSV(rgb [3]float64, degrees, saturation float64) [3]float64 {
+	hi, lo := max(rgb[0], rgb[1], rgb[2]), min(rgb[0], rgb[1], rgb[2])
+	chroma := hi - lo
+	if hi <= 0 || chroma <= 0 {
+		return rgb
+	}
+	var hue float64
+	switch hi {
+	case rgb[0]:
+		hue = math.Mod((rgb[1]-rgb[2])/chroma, 6)
+	case rgb[1]:
+		hue = (rgb[2]-rgb[0])/chroma + 2
+	default:
+		hue = (rgb[0]-rgb[1])/chroma + 4
+	}
+	hue = math.Mod(hue+degrees/60, 6)
+	if hue < 0 {
+		hue += 6
+	}
+	chroma = min(chroma*saturation, hi)
+	x := chroma * (1 - math.Abs(math.Mod(hue, 2)-1))
+	var r, g, b float64
+	switch int(hue) {
+	case 0:
+		r, g, b = chroma, x, 0
+	case 1:
+		r, g, b = x, chroma, 0
+	case 2:
+		r, g, b = 0, chroma, x
+	case 3:
+		r, g, b = 0, x, chroma
+	case 4:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	lo = hi - chroma
+	return [3]float64{r + lo, g + lo, b + lo}
+}
+
+// recolorOKLCh shifts rgb in the polar form of OKLab.
+func recolorOKLCh(rgb [3]float64, degrees, saturation float64) [3]float64 {
+	lab := colorspace.OKLab(rgb)
+	chroma := math.Hypot(lab[1], lab[2]) * saturation
+	hue := math.Atan2(lab[2], lab[1]) + degrees*math.Pi/180
+	lab[1], lab[2] = chroma*math.Cos(hue), chroma*math.Sin(hue)
+	return colorspace.FromOKLab(lab)
+}
@@ -0,0 +1,74 @@
+// Package gitrepo reads the committed versions of files from the git
+// repository they live in, so edits can be compared against them. It runs the
+// git command line, which must be on the PATH.
+package gitrepo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotInRepository = errors.New("not in a git repository")
+
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	hideWindow(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("git is not installed or not on the PATH")
+	} else if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Root returns the top folder of the repository containing path.
+func Root(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	out, err := git(filepath.Dir(abs), "rev-parse", "--show-toplevel")
+	if err != nil {
+		if strings.Contains(err.Error(), "not a git repository") {
+			return "", ErrNotInRepository
+		}
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(string(out))), nil
+}
+
+// Show returns the contents of the file at path as committed in revision,
+// such as "HEAD".
+func Show(path, revision string) ([]byte, error) {
+	root, err := Root(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve symlinks on both sides, git reports the real repository path
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+	out, err := git(root, "show", revision+":"+filepath.ToSlash(rel))
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s' from %s: %v", filepath.Base(path), revision, err)
+	}
+	return out, nil
+}
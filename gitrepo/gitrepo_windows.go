@@ -0,0 +1,11 @@
+package gitrepo
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// hideWindow keeps git from flashing a console window.
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+}
@@ -14,6 +14,8 @@ require (
 	github.com/jwalton/go-supportscolor v1.2.0
 	github.com/sqweek/dialog v0.0.0-20240226140203-065105509627
 	github.com/x448/float16 v0.8.4
+	github.com/xypwn/filediver v0.4.3
+	golang.org/x/image v0.19.0
 )
 
 require (
@@ -24,9 +26,7 @@ require (
 	github.com/gopxl/glhf/v2 v2.1.0 // indirect
 	github.com/gopxl/mainthread/v2 v2.1.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/xypwn/filediver v0.4.3 // indirect
 	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa // indirect
-	golang.org/x/image v0.19.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/term v0.5.0 // indirect
 )
@@ -29,6 +29,20 @@ type HDRImage interface {
 	GetStride() int
 }
 
+// PixelBytes returns the memory used by the pixels of img. Images of types
+// it doesn't know are assumed to use 4 bytes per pixel.
+func PixelBytes(img image.Image) int {
+	switch img := img.(type) {
+	case HDRImage:
+		return len(img.Pixels())
+	case *image.NRGBA:
+		return len(img.Pix)
+	case *image.RGBA:
+		return len(img.Pix)
+	}
+	return img.Bounds().Dx() * img.Bounds().Dy() * 4
+}
+
 type NRGBA128F struct {
 	R, G, B, A float32
 }
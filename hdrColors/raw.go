@@ -0,0 +1,81 @@
+package hdrColors
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/x448/float16"
+)
+
+// RawImage reads and writes the stored channel values of an image, whatever
+// its gray setting. Unsigned integer channels are normalized to [0, 1] and
+// float64 holds every stored value exactly.
+type RawImage interface {
+	image.Image
+	RawAt(x, y int) [4]float64
+	SetRaw(x, y int, c [4]float64)
+}
+
+func (p *NRGBA128FImage) RawAt(x, y int) [4]float64 {
+	gray := p.Grayscale
+	p.Grayscale = GraySettingNone
+	c := p.NRGBA128FAt(x, y)
+	p.Grayscale = gray
+	return [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+}
+
+func (p *NRGBA128FImage) SetRaw(x, y int, c [4]float64) {
+	p.Set(x, y, NRGBA128F{R: float32(c[0]), G: float32(c[1]), B: float32(c[2]), A: float32(c[3])})
+}
+
+func (p *NRGBA64FImage) RawAt(x, y int) [4]float64 {
+	gray := p.Grayscale
+	p.Grayscale = GraySettingNone
+	c := p.NRGBA64FAt(x, y)
+	p.Grayscale = gray
+	return [4]float64{float64(c.R.Float32()), float64(c.G.Float32()), float64(c.B.Float32()), float64(c.A.Float32())}
+}
+
+func (p *NRGBA64FImage) SetRaw(x, y int, c [4]float64) {
+	p.Set(x, y, NRGBA64F{
+		R: float16.Fromfloat32(float32(c[0])),
+		G: float16.Fromfloat32(float32(c[1])),
+		B: float16.Fromfloat32(float32(c[2])),
+		A: float16.Fromfloat32(float32(c[3])),
+	})
+}
+
+func (p *NRGBA128UImage) RawAt(x, y int) [4]float64 {
+	gray := p.Grayscale
+	p.Grayscale = GraySettingNone
+	c := p.NRGBA128UAt(x, y)
+	p.Grayscale = gray
+	return [4]float64{
+		float64(c.R) / math.MaxUint32,
+		float64(c.G) / math.MaxUint32,
+		float64(c.B) / math.MaxUint32,
+		float64(c.A) / math.MaxUint32,
+	}
+}
+
+func (p *NRGBA128UImage) SetRaw(x, y int, c [4]float64) {
+	unorm := func(v float64) uint32 {
+		return uint32(math.Round(min(max(v, 0), 1) * math.MaxUint32))
+	}
+	p.Set(x, y, NRGBA128U{R: unorm(c[0]), G: unorm(c[1]), B: unorm(c[2]), A: unorm(c[3])})
+}
+
+// Format is a pixel type images can be created in.
+type Format struct {
+	Name  string
+	Model color.Model
+	New   func(r image.Rectangle) RawImage
+}
+
+// Formats are the pixel types new images can be created in.
+var Formats = []Format{
+	{Name: "Float", Model: NRGBA128FModel, New: func(r image.Rectangle) RawImage { return NewNRGBA128FImage(r) }},
+	{Name: "Half", Model: NRGBA64FModel, New: func(r image.Rectangle) RawImage { return NewNRGBA64FImage(r) }},
+	{Name: "UInt", Model: NRGBA128UModel, New: func(r image.Rectangle) RawImage { return NewNRGBA128UImage(r) }},
+}
@@ -0,0 +1,109 @@
+package hdrColors
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/x448/float16"
+)
+
+// DataType is the type of each channel value in headerless image data.
+type DataType int
+
+const (
+	DataTypeHalf DataType = iota
+	DataTypeFloat
+	DataTypeUInt8
+	DataTypeUInt16
+	DataTypeUInt32
+	DataTypesCount
+)
+
+var dataTypeNames = []string{"half", "float", "uint8", "uint16", "uint32"}
+
+func (t DataType) String() string {
+	return dataTypeNames[t]
+}
+
+// Size is the number of bytes in one value of type t.
+func (t DataType) Size() int {
+	switch t {
+	case DataTypeHalf, DataTypeUInt16:
+		return 2
+	case DataTypeFloat, DataTypeUInt32:
+		return 4
+	}
+	return 1
+}
+
+// RawLayout describes headerless little-endian image data: Width by Height
+// pixels in rows from the top, each of Channels interleaved values of Type,
+// after Offset bytes of anything else.
+type RawLayout struct {
+	Width, Height int
+	Channels      int
+	Type          DataType
+	Offset        int64
+}
+
+// Size is the number of bytes the layout reads, including the offset.
+func (l RawLayout) Size() int64 {
+	return l.Offset + int64(l.Width)*int64(l.Height)*int64(l.Channels)*int64(l.Type.Size())
+}
+
+// DecodeRawData reads image data laid out as layout, such as a buffer dumped
+// by a graphics debugger. Unsigned integers are normalized to [0, 1]. Pixels
+// with fewer than four channels fill R, G and B in order, leaving the rest 0
+// and alpha 1.
+func DecodeRawData(r io.Reader, layout RawLayout) (*NRGBA128FImage, error) {
+	if layout.Width <= 0 || layout.Height <= 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", layout.Width, layout.Height)
+	}
+	if layout.Channels < 1 || layout.Channels > 4 {
+		return nil, fmt.Errorf("invalid channel count %d", layout.Channels)
+	}
+	if layout.Type < 0 || layout.Type >= DataTypesCount {
+		return nil, fmt.Errorf("invalid data type %d", layout.Type)
+	}
+	br := bufio.NewReader(r)
+	if _, err := br.Discard(int(layout.Offset)); err != nil {
+		return nil, fmt.Errorf("skipping %d bytes: %v", layout.Offset, err)
+	}
+
+	img := NewNRGBA128FImage(image.Rect(0, 0, layout.Width, layout.Height))
+	value := make([]byte, layout.Type.Size())
+	for y := 0; y < layout.Height; y++ {
+		for x := 0; x < layout.Width; x++ {
+			c := [4]float64{0, 0, 0, 1}
+			for i := 0; i < layout.Channels; i++ {
+				if _, err := io.ReadFull(br, value); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+					return nil, fmt.Errorf("data ends at pixel (%d, %d), %d bytes are needed", x, y, layout.Size())
+				} else if err != nil {
+					return nil, err
+				}
+				c[i] = decodeRawValue(value, layout.Type)
+			}
+			img.SetRaw(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+func decodeRawValue(b []byte, t DataType) float64 {
+	switch t {
+	case DataTypeHalf:
+		return float64(float16.Frombits(binary.LittleEndian.Uint16(b)).Float32())
+	case DataTypeFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case DataTypeUInt16:
+		return float64(binary.LittleEndian.Uint16(b)) / math.MaxUint16
+	case DataTypeUInt32:
+		return float64(binary.LittleEndian.Uint32(b)) / math.MaxUint32
+	}
+	return float64(b[0]) / math.MaxUint8
+}
@@ -0,0 +1,759 @@
+// Package help describes the layouts of known LUTs: what their rows, columns
+// and channels mean and which values are valid. Layouts are read from
+// help.json files, each of which can hold any number of schemas.
+package help
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// FileName is the name of the schema file shipped next to the editor.
+const FileName = "help.json"
+
+// Version is the newest schema file format this package understands.
+const Version = 1
+
+// PackURL is where Help > Update Schemas downloads the community schema pack
+// from unless told otherwise.
+const PackURL = "https://raw.githubusercontent.com/ryanjsims/hd2-lut-editor/main/help.json"
+
+// PackFileName is the name the community schema pack is installed under in
+// UserDir.
+const PackFileName = "community.json"
+
+// MaxPackSize is the largest schema pack Install accepts, in bytes.
+const MaxPackSize = 16 << 20
+
+// MinMax is an inclusive range of sizes. A Max of 0 leaves the range open
+// ended.
+type MinMax struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+func (m MinMax) Contains(v int) bool {
+	return v >= m.Min && (m.Max == 0 || v <= m.Max)
+}
+
+// Channel describes one channel of a column.
+type Channel struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Min and Max limit the valid values, where set
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Default is the value new images start with, where set
+	Default *float64 `json:"default,omitempty"`
+	// Values lists the only valid values, for channels holding IDs or flags,
+	// where set
+	Values []float64 `json:"values,omitempty"`
+}
+
+// valueTolerance is how far a value may be from one listed in Values and
+// still count as it, relative to its size, so values rounded when stored at
+// half precision still match.
+const valueTolerance = 1e-3
+
+// Valid reports whether v is within the channel's limits and, if it lists
+// them, one of its values.
+func (c *Channel) Valid(v float64) bool {
+	if c.Min != nil && v < *c.Min || c.Max != nil && v > *c.Max {
+		return false
+	}
+	return len(c.Values) == 0 || sameValue(c.Nearest(v), v)
+}
+
+// Range describes the channel's valid values, such as "0 to 1", or returns ""
+// if it has no limits.
+func (c *Channel) Range() string {
+	if len(c.Values) > 0 {
+		values := make([]string, len(c.Values))
+		for i, v := range c.Values {
+			values[i] = fmt.Sprintf("%g", v)
+		}
+		return "one of " + strings.Join(values, ", ")
+	}
+	switch {
+	case c.Min != nil && c.Max != nil:
+		return fmt.Sprintf("%g to %g", *c.Min, *c.Max)
+	case c.Min != nil:
+		return fmt.Sprintf("at least %g", *c.Min)
+	case c.Max != nil:
+		return fmt.Sprintf("at most %g", *c.Max)
+	}
+	return ""
+}
+
+func sameValue(a, b float64) bool {
+	return math.Abs(a-b) <= valueTolerance*max(1, math.Abs(b))
+}
+
+// Nearest returns the value in Values closest to v, or v if there are none.
+func (c *Channel) Nearest(v float64) float64 {
+	nearest := v
+	for i, value := range c.Values {
+		if i == 0 || math.Abs(value-v) < math.Abs(nearest-v) {
+			nearest = value
+		}
+	}
+	return nearest
+}
+
+type Column struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Channels describes R, G, B and A, in that order. Channels left out are
+	// unused.
+	Channels []Channel `json:"channels,omitempty"`
+	// Relations tie the column's channels to each other
+	Relations []Relation `json:"relations,omitempty"`
+	// Color marks columns whose R, G and B hold a color, rather than scalar
+	// parameters such as roughness, so recoloring changes only them
+	Color bool `json:"color,omitempty"`
+}
+
+// Kinds of Relation.
+const (
+	// RelationMaxSum limits the sum of the channels to Value
+	RelationMaxSum = "maxSum"
+	// RelationMirror keeps the channels after the first equal to it
+	RelationMirror = "mirror"
+)
+
+// Relation ties channels of a column together, so combinations of material
+// parameters that make no physical sense, such as color channels adding up to
+// more than 1, can be caught.
+type Relation struct {
+	Kind string `json:"kind"`
+	// Channels are the channels related, as letters, such as "RGB", or "RA"
+	// for A mirroring R
+	Channels string `json:"channels"`
+	// Value is the most the channels may add up to, for RelationMaxSum
+	Value float64 `json:"value,omitempty"`
+	// Enforce corrects edits that break the relation, instead of only
+	// counting them as violations
+	Enforce bool `json:"enforce,omitempty"`
+}
+
+// indexes returns the channels related, in the order given.
+func (r *Relation) indexes() []int {
+	indexes := make([]int, 0, len(r.Channels))
+	for _, c := range strings.ToUpper(r.Channels) {
+		indexes = append(indexes, strings.IndexRune("RGBA", c))
+	}
+	return indexes
+}
+
+func (r *Relation) validate() error {
+	indexes := r.indexes()
+	for _, i := range indexes {
+		if i < 0 {
+			return fmt.Errorf("channels %q are not all R, G, B or A", r.Channels)
+		}
+	}
+	switch r.Kind {
+	case RelationMaxSum:
+		if len(indexes) == 0 {
+			return fmt.Errorf("no channels to add up")
+		}
+	case RelationMirror:
+		if len(indexes) < 2 {
+			return fmt.Errorf("mirroring needs at least two channels")
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	return nil
+}
+
+// Holds reports whether value keeps to the relation.
+func (r *Relation) Holds(value [4]float64) bool {
+	indexes := r.indexes()
+	switch r.Kind {
+	case RelationMaxSum:
+		sum := 0.0
+		for _, i := range indexes {
+			sum += value[i]
+		}
+		return sum <= r.Value || sameValue(sum, r.Value)
+	case RelationMirror:
+		for _, i := range indexes[1:] {
+			if !sameValue(value[i], value[indexes[0]]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Correct returns value changed to keep to the relation: channels adding up
+// to too much are scaled down evenly, and mirroring channels are set to the
+// first. Channels in lock are left alone, so value may still break it.
+func (r *Relation) Correct(value [4]float64, lock [4]bool) [4]float64 {
+	if r.Holds(value) {
+		return value
+	}
+	indexes := r.indexes()
+	switch r.Kind {
+	case RelationMaxSum:
+		locked, free := 0.0, 0.0
+		for _, i := range indexes {
+			if lock[i] {
+				locked += value[i]
+			} else {
+				free += value[i]
+			}
+		}
+		if free <= 0 {
+			break
+		}
+		scale := max(r.Value-locked, 0) / free
+		for _, i := range indexes {
+			if !lock[i] {
+				value[i] *= scale
+			}
+		}
+	case RelationMirror:
+		for _, i := range indexes[1:] {
+			if !lock[i] {
+				value[i] = value[indexes[0]]
+			}
+		}
+	}
+	return value
+}
+
+// String writes the relation as a formula, such as "R+G+B <= 1" or "A = R".
+func (r *Relation) String() string {
+	channels := strings.Split(strings.ToUpper(r.Channels), "")
+	if r.Kind == RelationMirror {
+		return strings.Join(append(channels[1:], channels[0]), " = ")
+	}
+	return fmt.Sprintf("%s <= %g", strings.Join(channels, "+"), r.Value)
+}
+
+type Row struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// ColumnGroup names a run of columns that belong together, such as those
+// holding a material's base color or its emissive settings.
+type ColumnGroup struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// First and Last are the group's first and last columns
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+// Contains reports whether column x is in the group.
+func (g ColumnGroup) Contains(x int) bool {
+	return x >= g.First && x <= g.Last
+}
+
+// Schema is the layout of one kind of LUT.
+type Schema struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Width and Height are the image sizes the schema applies to
+	Width  MinMax `json:"width"`
+	Height MinMax `json:"height"`
+	// Rows and Columns describe the image's rows and columns from the top
+	// left. Either can be left empty, or cover only the first few.
+	Rows    []Row    `json:"rows,omitempty"`
+	Columns []Column `json:"columns,omitempty"`
+	// Groups collects the columns into named groups, left to right. Columns
+	// in no group are shown without a label.
+	Groups []ColumnGroup `json:"groups,omitempty"`
+	// SnapWidth and SnapHeight are the size of the blocks the image is laid
+	// out in, such as the columns of one material, which moved pixels can
+	// snap to. 0 is the same as 1.
+	SnapWidth  int `json:"snapWidth,omitempty"`
+	SnapHeight int `json:"snapHeight,omitempty"`
+	// Source is the file the schema was read from
+	Source string `json:"-"`
+}
+
+// File is the contents of a help.json file.
+type File struct {
+	Version int      `json:"version"`
+	Schemas []Schema `json:"schemas"`
+}
+
+// Load reads the schemas in the file at path.
+func Load(path string) ([]Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schemas {
+		schemas[i].Source = path
+	}
+	return schemas, nil
+}
+
+// Parse reads the schemas in the contents of a schema file, checking each.
+func Parse(data []byte) ([]Schema, error) {
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid schema file: %v", err)
+	}
+	if f.Version > Version {
+		return nil, fmt.Errorf("schema file version %d is newer than this editor supports (%d)", f.Version, Version)
+	}
+	for i := range f.Schemas {
+		if strings.TrimSpace(f.Schemas[i].Name) == "" {
+			return nil, fmt.Errorf("schema %d has no name", i)
+		}
+		if err := f.Schemas[i].Check(); err != nil {
+			return nil, err
+		}
+	}
+	return f.Schemas, nil
+}
+
+// Install checks the schema pack data and writes it to PackFileName in
+// UserDir, replacing the pack installed before. It returns the schemas the
+// pack holds. Nothing is written unless every schema is valid.
+func Install(data []byte) ([]Schema, error) {
+	if len(data) > MaxPackSize {
+		return nil, fmt.Errorf("schema pack is larger than %d MiB", MaxPackSize>>20)
+	}
+	schemas, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("schema pack holds no schemas")
+	}
+	dir, err := UserDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Write to a temporary file first so a failed write leaves the old pack
+	tmp, err := os.CreateTemp(dir, PackFileName+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, PackFileName)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	for i := range schemas {
+		schemas[i].Source = path
+	}
+	return schemas, nil
+}
+
+// Check reports the first problem that would stop the schema loading.
+func (s *Schema) Check() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("schema has no name")
+	}
+	for _, group := range s.Groups {
+		if group.First < 0 || group.Last < group.First {
+			return fmt.Errorf("schema %s: group %q has no columns", s.Name, group.Name)
+		}
+	}
+	for _, column := range s.Columns {
+		for _, relation := range column.Relations {
+			if err := relation.validate(); err != nil {
+				return fmt.Errorf("schema %s: column %q: %v", s.Name, column.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Save writes s to the schema file at path, replacing the schema named
+// replacing if the file has one, or adding it to the file otherwise. The
+// file and its folder are created if they don't exist.
+func Save(path string, s Schema, replacing string) error {
+	if err := s.Check(); err != nil {
+		return err
+	}
+	schemas, err := Load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	i := slices.IndexFunc(schemas, func(existing Schema) bool { return existing.Name == replacing })
+	if i >= 0 {
+		schemas[i] = s
+	} else {
+		schemas = append(schemas, s)
+	}
+	data, err := json.MarshalIndent(File{Version: Version, Schemas: schemas}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Clone returns a copy of s sharing nothing with it, so it can be edited.
+func (s Schema) Clone() Schema {
+	clonePtr := func(v *float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		c := *v
+		return &c
+	}
+	s.Rows = slices.Clone(s.Rows)
+	s.Groups = slices.Clone(s.Groups)
+	s.Columns = slices.Clone(s.Columns)
+	for i := range s.Columns {
+		column := &s.Columns[i]
+		column.Relations = slices.Clone(column.Relations)
+		column.Channels = slices.Clone(column.Channels)
+		for j := range column.Channels {
+			channel := &column.Channels[j]
+			channel.Min, channel.Max, channel.Default = clonePtr(channel.Min), clonePtr(channel.Max), clonePtr(channel.Default)
+			channel.Values = slices.Clone(channel.Values)
+		}
+	}
+	return s
+}
+
+// UserDir is the folder holding the user's own schema files.
+func UserDir() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "hd2-lut-editor", "schemas"), nil
+}
+
+// Paths returns the schema files the editor loads: help.json next to the
+// executable, followed by every .json file in UserDir.
+func Paths() []string {
+	var paths []string
+	if exePath, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(exePath), FileName)
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if dir, err := UserDir(); err == nil {
+		if matches, err := filepath.Glob(filepath.Join(dir, "*.json")); err == nil {
+			slices.Sort(matches)
+			paths = append(paths, matches...)
+		}
+	}
+	return paths
+}
+
+// LoadAll reads the schemas in every file in paths. Files that fail to load
+// are skipped, and their errors joined.
+func LoadAll(paths []string) ([]Schema, error) {
+	var schemas []Schema
+	var errs []error
+	for _, path := range paths {
+		loaded, err := Load(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			continue
+		}
+		schemas = append(schemas, loaded...)
+	}
+	return schemas, errors.Join(errs...)
+}
+
+func (s *Schema) Matches(size image.Point) bool {
+	return s.Width.Contains(size.X) && s.Height.Contains(size.Y)
+}
+
+// Match returns the indexes of the schemas that apply to an image of size.
+func Match(schemas []Schema, size image.Point) []int {
+	var matches []int
+	for i := range schemas {
+		if schemas[i].Matches(size) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// Row returns the description of row y, or nil if the schema has none.
+func (s *Schema) Row(y int) *Row {
+	if y < 0 || y >= len(s.Rows) {
+		return nil
+	}
+	return &s.Rows[y]
+}
+
+// Column returns the description of column x, or nil if the schema has none.
+func (s *Schema) Column(x int) *Column {
+	if x < 0 || x >= len(s.Columns) {
+		return nil
+	}
+	return &s.Columns[x]
+}
+
+// Channel returns the description of a channel of column x, or nil if the
+// schema has none.
+func (s *Schema) Channel(x, channel int) *Channel {
+	column := s.Column(x)
+	if column == nil || channel < 0 || channel >= len(column.Channels) {
+		return nil
+	}
+	return &column.Channels[channel]
+}
+
+// Defaults returns fill with the channels of column x replaced by their
+// default values, where the schema gives them.
+func (s *Schema) Defaults(x int, fill [4]float64) [4]float64 {
+	for i := range fill {
+		if channel := s.Channel(x, i); channel != nil && channel.Default != nil {
+			fill[i] = *channel.Default
+		}
+	}
+	return fill
+}
+
+// HasDefaults reports whether any channel has a default value.
+func (s *Schema) HasDefaults() bool {
+	for _, column := range s.Columns {
+		for _, channel := range column.Channels {
+			if channel.Default != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Size returns the size of a new image laid out by the schema: wide and tall
+// enough for the columns and rows it describes, within its limits, and at
+// least 1 by 1.
+func (s *Schema) Size() image.Point {
+	fit := func(limits MinMax, described int) int {
+		v := max(limits.Min, described, 1)
+		if limits.Max > 0 {
+			v = min(v, limits.Max)
+		}
+		return v
+	}
+	return image.Pt(fit(s.Width, len(s.Columns)), fit(s.Height, len(s.Rows)))
+}
+
+// Start returns the value a channel starts with in an image made from the
+// schema: its default if it has one, or otherwise the valid value closest to
+// v.
+func (c *Channel) Start(v float64) float64 {
+	if c.Default != nil {
+		return *c.Default
+	}
+	if c.Min != nil {
+		v = max(v, *c.Min)
+	}
+	if c.Max != nil {
+		v = min(v, *c.Max)
+	}
+	return c.Nearest(v)
+}
+
+// Template returns the value of column x in an image made from the schema:
+// fill, with the channels the schema describes started from it within their
+// limits.
+func (s *Schema) Template(x int, fill [4]float64) [4]float64 {
+	for i := range fill {
+		if channel := s.Channel(x, i); channel != nil {
+			fill[i] = channel.Start(fill[i])
+		}
+	}
+	return fill
+}
+
+// HasValues reports whether any channel lists its valid values.
+func (s *Schema) HasValues() bool {
+	for _, column := range s.Columns {
+		for _, channel := range column.Channels {
+			if len(channel.Values) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ColorColumns reports which of width columns hold colors.
+func (s *Schema) ColorColumns(width int) []bool {
+	columns := make([]bool, width)
+	for x := range columns {
+		if column := s.Column(x); column != nil {
+			columns[x] = column.Color
+		}
+	}
+	return columns
+}
+
+// HasColorColumns reports whether any column holds a color.
+func (s *Schema) HasColorColumns() bool {
+	for _, column := range s.Columns {
+		if column.Color {
+			return true
+		}
+	}
+	return false
+}
+
+// Snap returns the block size moved pixels snap to, at least 1 by 1.
+func (s *Schema) Snap() image.Point {
+	return image.Pt(max(s.SnapWidth, 1), max(s.SnapHeight, 1))
+}
+
+// Violation is a value outside its channel's limits, or a texel breaking one
+// of its column's relations.
+type Violation struct {
+	X, Y    int
+	Channel int
+	Value   float64
+	// Relation is the relation broken, or nil if the value is out of limits.
+	// Channel and Value are then the first channel related.
+	Relation *Relation
+}
+
+// HasRelations reports whether any column has relations, or with enforced
+// set, relations it enforces.
+func (s *Schema) HasRelations(enforced bool) bool {
+	for _, column := range s.Columns {
+		for _, relation := range column.Relations {
+			if relation.Enforce || !enforced {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate returns the values of img outside the limits the schema sets, or
+// not among the values it lists, and the texels breaking relations, in row
+// order.
+func (s *Schema) Validate(img hdrColors.RawImage) []Violation {
+	var violations []Violation
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			column := s.Column(x - bounds.Min.X)
+			if column == nil {
+				continue
+			}
+			value := img.RawAt(x, y)
+			for i, channel := range column.Channels {
+				if i >= len(value) {
+					break
+				}
+				v := value[i]
+				if !channel.Valid(v) {
+					violations = append(violations, Violation{X: x - bounds.Min.X, Y: y - bounds.Min.Y, Channel: i, Value: v})
+				}
+			}
+			for i := range column.Relations {
+				relation := &column.Relations[i]
+				if !relation.Holds(value) {
+					first := relation.indexes()[0]
+					violations = append(violations, Violation{X: x - bounds.Min.X, Y: y - bounds.Min.Y, Channel: first, Value: value[first], Relation: relation})
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// EnforceRelations corrects the texels of img at points that break the
+// relations the schema enforces, leaving channels in lock alone. It returns
+// how many texels it changed.
+func (s *Schema) EnforceRelations(img hdrColors.RawImage, points []image.Point, lock [4]bool) int {
+	changed := 0
+	bounds := img.Bounds()
+	for _, p := range points {
+		column := s.Column(p.X - bounds.Min.X)
+		if column == nil || !p.In(bounds) {
+			continue
+		}
+		value := img.RawAt(p.X, p.Y)
+		corrected := value
+		for i := range column.Relations {
+			if relation := &column.Relations[i]; relation.Enforce {
+				corrected = relation.Correct(corrected, lock)
+			}
+		}
+		if corrected != value {
+			img.SetRaw(p.X, p.Y, corrected)
+			changed++
+		}
+	}
+	return changed
+}
+
+// Snapped is a value moved to the nearest of its channel's valid values.
+type Snapped struct {
+	X, Y     int
+	Channel  int
+	From, To float64
+}
+
+// SnapValues moves every value in rect of img that isn't one of its
+// channel's listed values to the nearest that is, and returns what it
+// changed, in row order. Channels in lock and channels without listed values
+// are left alone.
+func (s *Schema) SnapValues(img hdrColors.RawImage, rect image.Rectangle, lock [4]bool) []Snapped {
+	var snapped []Snapped
+	bounds := img.Bounds()
+	rect = rect.Intersect(bounds)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			column := s.Column(x - bounds.Min.X)
+			if column == nil {
+				continue
+			}
+			value := img.RawAt(x, y)
+			changed := false
+			for i := range column.Channels {
+				channel := &column.Channels[i]
+				if i >= len(value) || lock[i] || len(channel.Values) == 0 {
+					continue
+				}
+				to := channel.Nearest(value[i])
+				if sameValue(to, value[i]) {
+					continue
+				}
+				snapped = append(snapped, Snapped{X: x - bounds.Min.X, Y: y - bounds.Min.Y, Channel: i, From: value[i], To: to})
+				value[i], changed = to, true
+			}
+			if changed {
+				img.SetRaw(x, y, value)
+			}
+		}
+	}
+	return snapped
+}
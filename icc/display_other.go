@@ -0,0 +1,9 @@
+//go:build !windows
+
+package icc
+
+import "errors"
+
+func DisplayProfilePath() (string, error) {
+	return "", errors.New("detecting the display profile is only supported on Windows")
+}
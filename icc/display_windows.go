@@ -0,0 +1,41 @@
+package icc
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32 = syscall.NewLazyDLL("user32")
+	// Retrieves a handle to a device context for the client area of a window
+	// or the entire screen.
+	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getdc
+	getDC = user32.NewProc("GetDC")
+	// Releases a device context.
+	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-releasedc
+	releaseDC = user32.NewProc("ReleaseDC")
+
+	gdi32 = syscall.NewLazyDLL("gdi32")
+	// Retrieves the file name of the current output color profile for a
+	// device context.
+	// https://learn.microsoft.com/en-us/windows/win32/api/wingdi/nf-wingdi-geticmprofilew
+	getICMProfile = gdi32.NewProc("GetICMProfileW")
+)
+
+// DisplayProfilePath returns the path of the color profile assigned to the
+// primary monitor in Windows color management.
+func DisplayProfilePath() (string, error) {
+	hdc, _, err := getDC.Call(0)
+	if hdc == 0 {
+		return "", err
+	}
+	defer releaseDC.Call(0, hdc)
+
+	size := uint32(syscall.MAX_PATH)
+	buf := make([]uint16, size)
+	ok, _, err := getICMProfile.Call(hdc, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+	if ok == 0 {
+		return "", err
+	}
+	return syscall.UTF16ToString(buf), nil
+}
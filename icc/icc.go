@@ -0,0 +1,261 @@
+// Package icc reads matrix/TRC ICC profiles, the kind monitor calibration
+// tools produce, so images can be converted for presentation on the display
+// they describe.
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ryanjsims/hd2-lut-editor/colorspace"
+)
+
+var ErrUnsupported = errors.New("unsupported ICC profile")
+
+// D50 is the white point of the ICC profile connection space.
+var D50 = colorspace.XY{X: 0.3457, Y: 0.3585}
+
+type Profile struct {
+	Description string
+	// ToXYZ converts linear device RGB to D50 XYZ
+	ToXYZ colorspace.Mat3
+	// trc maps device values to linear light, per channel
+	trc [3]func(float64) float64
+}
+
+type tagEntry struct {
+	offset, size uint32
+}
+
+// Load reads the profile at path.
+func Load(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes an RGB display profile described by colorants and tone
+// reproduction curves. LUT based profiles are not supported.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("not an ICC profile")
+	}
+	if string(data[16:20]) != "RGB " {
+		return nil, fmt.Errorf("%w: color space %q", ErrUnsupported, strings.TrimSpace(string(data[16:20])))
+	}
+	count := binary.BigEndian.Uint32(data[128:])
+	if uint64(count)*12+132 > uint64(len(data)) {
+		return nil, fmt.Errorf("tag table is truncated")
+	}
+	tags := make(map[string][]byte, count)
+	for i := uint32(0); i < count; i++ {
+		entry := data[132+12*i:]
+		offset := binary.BigEndian.Uint32(entry[4:])
+		size := binary.BigEndian.Uint32(entry[8:])
+		if uint64(offset)+uint64(size) > uint64(len(data)) || size < 8 {
+			return nil, fmt.Errorf("tag %q is out of bounds", entry[:4])
+		}
+		tags[string(entry[:4])] = data[offset : offset+size]
+	}
+
+	profile := &Profile{Description: description(tags["desc"])}
+	for i, name := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseXYZ(tags[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %v", ErrUnsupported, name, err)
+		}
+		for j := range xyz {
+			profile.ToXYZ[j][i] = xyz[j]
+		}
+	}
+	for i, name := range []string{"rTRC", "gTRC", "bTRC"} {
+		trc, err := parseCurve(tags[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %v", ErrUnsupported, name, err)
+		}
+		profile.trc[i] = trc
+	}
+	return profile, nil
+}
+
+func s15Fixed16(data []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(data))) / 65536.0
+}
+
+func parseXYZ(tag []byte) ([3]float64, error) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, fmt.Errorf("missing or invalid")
+	}
+	return [3]float64{s15Fixed16(tag[8:]), s15Fixed16(tag[12:]), s15Fixed16(tag[16:])}, nil
+}
+
+func parseCurve(tag []byte) (func(float64) float64, error) {
+	if len(tag) < 12 {
+		return nil, fmt.Errorf("missing or invalid")
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		count := binary.BigEndian.Uint32(tag[8:])
+		if uint64(len(tag)) < 12+2*uint64(count) {
+			return nil, fmt.Errorf("curve is truncated")
+		}
+		switch count {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256.0
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		}
+		table := make([]float64, count)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535.0
+		}
+		return func(x float64) float64 {
+			pos := min(max(x, 0), 1) * float64(len(table)-1)
+			i := min(int(pos), len(table)-2)
+			t := pos - float64(i)
+			return table[i]*(1-t) + table[i+1]*t
+		}, nil
+	case "para":
+		// Parametric curve types 0 to 4, see section 10.18 of the ICC specification
+		paramCounts := []int{1, 3, 4, 5, 7}
+		funcType := int(binary.BigEndian.Uint16(tag[8:]))
+		if funcType >= len(paramCounts) || len(tag) < 12+4*paramCounts[funcType] {
+			return nil, fmt.Errorf("unknown parametric curve type %d", funcType)
+		}
+		// g, a, b, c, d, e, f
+		p := []float64{1, 1, 0, 0, 0, 0, 0}
+		for i := 0; i < paramCounts[funcType]; i++ {
+			p[i] = s15Fixed16(tag[12+4*i:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		switch funcType {
+		case 1:
+			d = -b / a
+		case 2:
+			d, e, f, c = -b/a, c, c, 0
+		case 3:
+			e = 0
+		}
+		if funcType == 0 {
+			d = math.Inf(-1)
+		}
+		return func(x float64) float64 {
+			if x >= d {
+				return math.Pow(max(a*x+b, 0), g) + e
+			}
+			return c*x + f
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown curve type %q", tag[:4])
+}
+
+// description reads a v2 textDescriptionType or the first record of a v4
+// multiLocalizedUnicodeType.
+func description(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+	switch string(tag[:4]) {
+	case "desc":
+		count := binary.BigEndian.Uint32(tag[8:])
+		if uint64(len(tag)) < 12+uint64(count) {
+			return ""
+		}
+		return strings.TrimRight(string(tag[12:12+count]), "\x00")
+	case "mluc":
+		if binary.BigEndian.Uint32(tag[8:]) == 0 || len(tag) < 28 {
+			return ""
+		}
+		length := binary.BigEndian.Uint32(tag[20:])
+		offset := binary.BigEndian.Uint32(tag[24:])
+		if uint64(offset)+uint64(length) > uint64(len(tag)) {
+			return ""
+		}
+		units := make([]uint16, length/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[offset+2*uint32(i):])
+		}
+		return string(utf16.Decode(units))
+	}
+	return ""
+}
+
+// Display converts colors meant for an sRGB monitor to the values that look
+// the same on the monitor a profile describes.
+type Display struct {
+	m colorspace.Mat3
+	// linear and encoded sample each channel's tone curve, for inverting it
+	encoded []float64
+	linear  [3][]float64
+}
+
+const curveSamples = 4096
+
+// FromSRGB returns the conversion from sRGB to the profile's device values.
+func (p *Profile) FromSRGB() *Display {
+	display := &Display{
+		m:       p.ToXYZ.Inverse().Mul(colorspace.Adaptation(colorspace.D65, D50)).Mul(colorspace.Rec709.ToXYZ()),
+		encoded: make([]float64, curveSamples),
+	}
+	for i := range display.encoded {
+		display.encoded[i] = float64(i) / (curveSamples - 1)
+	}
+	for channel, trc := range p.trc {
+		samples := make([]float64, curveSamples)
+		for i, x := range display.encoded {
+			samples[i] = trc(x)
+			if i > 0 && samples[i] < samples[i-1] {
+				// Keep the curve monotonic so it can be searched
+				samples[i] = samples[i-1]
+			}
+		}
+		display.linear[channel] = samples
+	}
+	return display
+}
+
+// Apply converts an sRGB encoded color, clipped to [0, 1], to device values.
+func (d *Display) Apply(r, g, b float32) (float32, float32, float32) {
+	rgb := [3]float64{
+		srgbToLinear(min(max(float64(r), 0), 1)),
+		srgbToLinear(min(max(float64(g), 0), 1)),
+		srgbToLinear(min(max(float64(b), 0), 1)),
+	}
+	rgb = d.m.MulVec(rgb)
+	for i := range rgb {
+		rgb[i] = d.encode(i, rgb[i])
+	}
+	return float32(rgb[0]), float32(rgb[1]), float32(rgb[2])
+}
+
+func (d *Display) encode(channel int, v float64) float64 {
+	samples := d.linear[channel]
+	i := sort.SearchFloat64s(samples, v)
+	if i <= 0 {
+		return 0
+	} else if i >= len(samples) {
+		return 1
+	}
+	lo, hi := samples[i-1], samples[i]
+	t := 0.0
+	if hi > lo {
+		t = (v - lo) / (hi - lo)
+	}
+	return d.encoded[i-1] + t*(d.encoded[i]-d.encoded[i-1])
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
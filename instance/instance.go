@@ -0,0 +1,75 @@
+// Package instance lets a second launch of the editor hand the files it was
+// given to an editor that is already running, so they open as new tabs there
+// instead of in another window.
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The socket is per user, so different users on one machine each get their
+// own editor.
+func socketPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("hd2-lut-editor-%d.sock", os.Getuid()))
+}
+
+const dialTimeout = time.Second
+
+// Forward sends paths to the running editor. It returns false if there is no
+// editor listening, in which case the caller should open the paths itself.
+func Forward(paths []string) (bool, error) {
+	conn, err := net.DialTimeout("unix", socketPath(), dialTimeout)
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := json.NewEncoder(conn).Encode(paths); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Listen makes this editor the running instance. Paths forwarded by later
+// launches are sent on forwarded until the returned listener is closed.
+func Listen(forwarded chan<- []string) (net.Listener, error) {
+	path := socketPath()
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		// A socket left behind by an editor that crashed can't be dialed
+		if conn, dialErr := net.DialTimeout("unix", path, dialTimeout); dialErr == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another instance is already listening on %v", path)
+		}
+		if removeErr := os.Remove(path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			return nil, err
+		}
+		if listener, err = net.Listen("unix", path); err != nil {
+			return nil, err
+		}
+	}
+	go accept(listener, forwarded)
+	return listener, nil
+}
+
+func accept(listener net.Listener, forwarded chan<- []string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		var paths []string
+		conn.SetDeadline(time.Now().Add(dialTimeout))
+		err = json.NewDecoder(conn).Decode(&paths)
+		conn.Close()
+		if err == nil {
+			forwarded <- paths
+		}
+	}
+}
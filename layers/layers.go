@@ -0,0 +1,111 @@
+// Package layers blends a stack of HDR images into one. The channels of a LUT
+// are data rather than a color with transparency, so every channel, alpha
+// included, is blended the same way, with the layer's opacity deciding how
+// much of it is used.
+package layers
+
+import (
+	"fmt"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Blend is how a layer is combined with the layers below it.
+type Blend int
+
+const (
+	// BlendNormal replaces the values below with the layer's
+	BlendNormal Blend = iota
+	// BlendAdd adds the layer's values to the values below
+	BlendAdd
+	// BlendMultiply multiplies the values below by the layer's
+	BlendMultiply
+)
+
+// Blends lists the blend modes, for choosing between them.
+var Blends = []Blend{BlendNormal, BlendAdd, BlendMultiply}
+
+func (b Blend) String() string {
+	switch b {
+	case BlendNormal:
+		return "Normal"
+	case BlendAdd:
+		return "Add"
+	case BlendMultiply:
+		return "Multiply"
+	}
+	return fmt.Sprintf("Blend(%d)", int(b))
+}
+
+// Identity is the value a layer with blend b leaves the values below
+// unchanged with: 0 to add and 1 to multiply. Normal layers have none, as
+// they are usually started from a copy of what is below them.
+func (b Blend) Identity() ([4]float64, bool) {
+	switch b {
+	case BlendAdd:
+		return [4]float64{}, true
+	case BlendMultiply:
+		return [4]float64{1, 1, 1, 1}, true
+	}
+	return [4]float64{}, false
+}
+
+// Layer holds the settings of a layer in the stack.
+type Layer struct {
+	Name    string
+	Visible bool
+	// Opacity is from 0, for no effect, to 1
+	Opacity float64
+	Blend   Blend
+}
+
+// New returns a visible, opaque layer.
+func New(name string, blend Blend) Layer {
+	return Layer{Name: name, Visible: true, Opacity: 1, Blend: blend}
+}
+
+// Apply returns below with the values of a layer with settings l blended over
+// it.
+func (l Layer) Apply(below, value [4]float64) [4]float64 {
+	if !l.Visible {
+		return below
+	}
+	out := below
+	for ch := range out {
+		switch l.Blend {
+		case BlendAdd:
+			out[ch] = below[ch] + value[ch]*l.Opacity
+		case BlendMultiply:
+			out[ch] = below[ch] * (1 + (value[ch]-1)*l.Opacity)
+		default:
+			out[ch] = below[ch] + (value[ch]-below[ch])*l.Opacity
+		}
+	}
+	return out
+}
+
+// Flatten writes the layers in images, from the bottom up, blended with the
+// settings in stack, into out. The bottom layer is blended over zeros. All
+// the images must be the size of out.
+func Flatten(out hdrColors.RawImage, stack []Layer, images []hdrColors.RawImage) error {
+	if len(stack) != len(images) {
+		return fmt.Errorf("%d layers but %d images", len(stack), len(images))
+	}
+	bounds := out.Bounds()
+	for i, img := range images {
+		if img.Bounds().Size() != bounds.Size() {
+			return fmt.Errorf("layer '%s' is %dx%d but the image is %dx%d", stack[i].Name, img.Bounds().Dx(), img.Bounds().Dy(), bounds.Dx(), bounds.Dy())
+		}
+	}
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			var c [4]float64
+			for i, img := range images {
+				origin := img.Bounds().Min
+				c = stack[i].Apply(c, img.RawAt(origin.X+x, origin.Y+y))
+			}
+			out.SetRaw(bounds.Min.X+x, bounds.Min.Y+y, c)
+		}
+	}
+	return nil
+}
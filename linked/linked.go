@@ -0,0 +1,157 @@
+// Package linked relates a material LUT to a pattern LUT whose texels refer to
+// rows of the material LUT by index, so that the two can be edited together.
+package linked
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Encoding is how a pattern texel stores the material row it refers to.
+type Encoding int
+
+const (
+	// EncodingIndex stores the row index itself: 0, 1, 2...
+	EncodingIndex Encoding = iota
+	// EncodingNormalized spreads the rows over 0-1, storing row / (rows - 1)
+	EncodingNormalized
+)
+
+var Encodings = []Encoding{EncodingIndex, EncodingNormalized}
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingIndex:
+		return "Row index"
+	case EncodingNormalized:
+		return "Normalized 0-1"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// Link describes where a pattern LUT keeps its material row references.
+type Link struct {
+	Channel  int
+	Encoding Encoding
+	// Rows is the number of rows in the material LUT
+	Rows int
+}
+
+// Row returns the material row a pattern value refers to. The result may be
+// outside the material LUT.
+func (l Link) Row(value float64) int {
+	if l.Encoding == EncodingNormalized {
+		value *= float64(l.Rows - 1)
+	}
+	return int(math.Round(value))
+}
+
+// Value returns the pattern value referring to material row.
+func (l Link) Value(row int) float64 {
+	if l.Encoding == EncodingNormalized {
+		if l.Rows <= 1 {
+			return 0
+		}
+		return float64(row) / float64(l.Rows-1)
+	}
+	return float64(row)
+}
+
+// References returns the texels of pattern that refer to material rows first
+// through last - 1, in row order.
+func (l Link) References(pattern hdrColors.RawImage, first, last int) []image.Point {
+	var points []image.Point
+	bounds := pattern.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			row := l.Row(pattern.RawAt(x, y)[l.Channel])
+			if row >= first && row < last {
+				points = append(points, image.Pt(x, y))
+			}
+		}
+	}
+	return points
+}
+
+// Dangling counts the texels of pattern that refer to a row the material LUT
+// doesn't have.
+func (l Link) Dangling(pattern hdrColors.RawImage) int {
+	count := 0
+	bounds := pattern.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			row := l.Row(pattern.RawAt(x, y)[l.Channel])
+			if row < 0 || row >= l.Rows {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Use is how many texels of a pattern refer to a material row.
+type Use struct {
+	Row    int
+	Texels int
+}
+
+// Usage counts the texels of pattern referring to each material row, in row
+// order. Rows no texel refers to are left out, and rows the material LUT
+// doesn't have are included.
+func (l Link) Usage(pattern hdrColors.RawImage) []Use {
+	counts := make(map[int]int)
+	bounds := pattern.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[l.Row(pattern.RawAt(x, y)[l.Channel])]++
+		}
+	}
+	uses := make([]Use, 0, len(counts))
+	for row, texels := range counts {
+		uses = append(uses, Use{Row: row, Texels: texels})
+	}
+	slices.SortFunc(uses, func(a, b Use) int { return a.Row - b.Row })
+	return uses
+}
+
+// SwapRows exchanges rows a and b of material and rewrites the pattern texels
+// referring to either, so that every texel keeps its material. It returns the
+// number of pattern texels rewritten.
+func (l Link) SwapRows(material, pattern hdrColors.RawImage, a, b int) (int, error) {
+	mBounds := material.Bounds()
+	if a < 0 || b < 0 || a >= mBounds.Dy() || b >= mBounds.Dy() {
+		return 0, fmt.Errorf("rows must be between 0 and %d", mBounds.Dy()-1)
+	}
+	if a == b {
+		return 0, nil
+	}
+	ya, yb := mBounds.Min.Y+a, mBounds.Min.Y+b
+	for x := mBounds.Min.X; x < mBounds.Max.X; x++ {
+		va, vb := material.RawAt(x, ya), material.RawAt(x, yb)
+		material.SetRaw(x, ya, vb)
+		material.SetRaw(x, yb, va)
+	}
+
+	changed := 0
+	pBounds := pattern.Bounds()
+	for y := pBounds.Min.Y; y < pBounds.Max.Y; y++ {
+		for x := pBounds.Min.X; x < pBounds.Max.X; x++ {
+			value := pattern.RawAt(x, y)
+			switch l.Row(value[l.Channel]) {
+			case a:
+				value[l.Channel] = l.Value(b)
+			case b:
+				value[l.Channel] = l.Value(a)
+			default:
+				continue
+			}
+			pattern.SetRaw(x, y, value)
+			changed++
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,117 @@
+// Package mask keeps a set of texels, used to lock finished parts of an image
+// against further edits.
+package mask
+
+import (
+	"image"
+	"slices"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Mask is a set of texels. The zero value is empty and ready to use.
+type Mask struct {
+	texels map[image.Point]bool
+	// rects caches Rects until the mask next changes
+	rects []image.Rectangle
+	dirty bool
+}
+
+func (m *Mask) Add(r image.Rectangle) {
+	if m.texels == nil {
+		m.texels = make(map[image.Point]bool)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			m.texels[image.Pt(x, y)] = true
+		}
+	}
+	m.dirty = true
+}
+
+func (m *Mask) Remove(r image.Rectangle) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			delete(m.texels, image.Pt(x, y))
+		}
+	}
+	m.dirty = true
+}
+
+func (m *Mask) Clear() {
+	m.texels = nil
+	m.rects = nil
+	m.dirty = false
+}
+
+func (m *Mask) Len() int {
+	return len(m.texels)
+}
+
+func (m *Mask) Contains(p image.Point) bool {
+	return m.texels[p]
+}
+
+// Rects returns the mask as rectangles, covering each texel exactly once. Runs
+// of texels along a row are merged, as are identical runs on consecutive
+// rows.
+func (m *Mask) Rects() []image.Rectangle {
+	if !m.dirty {
+		return m.rects
+	}
+	points := make([]image.Point, 0, len(m.texels))
+	for p := range m.texels {
+		points = append(points, p)
+	}
+	slices.SortFunc(points, func(a, b image.Point) int {
+		if a.Y != b.Y {
+			return a.Y - b.Y
+		}
+		return a.X - b.X
+	})
+
+	var rects []image.Rectangle
+	// open maps the horizontal span of each rectangle that ended on the
+	// previous row to its index, so the same span on this row extends it
+	open := make(map[[2]int]int)
+	for i := 0; i < len(points); {
+		y := points[i].Y
+		next := make(map[[2]int]int)
+		for i < len(points) && points[i].Y == y {
+			start := points[i].X
+			end := start + 1
+			for i++; i < len(points) && points[i].Y == y && points[i].X == end; i++ {
+				end++
+			}
+			span := [2]int{start, end}
+			if index, ok := open[span]; ok && rects[index].Max.Y == y {
+				rects[index].Max.Y++
+				next[span] = index
+			} else {
+				next[span] = len(rects)
+				rects = append(rects, image.Rect(start, y, end, y+1))
+			}
+		}
+		open = next
+	}
+	m.rects, m.dirty = rects, false
+	return rects
+}
+
+// Protect records the values of the masked texels of img and returns a
+// function that puts them back, for edits that don't check the mask texel by
+// texel.
+func (m *Mask) Protect(img hdrColors.RawImage) (restore func()) {
+	saved := make(map[image.Point][4]float64, len(m.texels))
+	bounds := img.Bounds()
+	for p := range m.texels {
+		if p.In(bounds) {
+			saved[p] = img.RawAt(p.X, p.Y)
+		}
+	}
+	return func() {
+		for p, value := range saved {
+			img.SetRaw(p.X, p.Y, value)
+		}
+	}
+}
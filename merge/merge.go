@@ -0,0 +1,137 @@
+// Package merge combines two edited copies of an image with the image they
+// were both made from, so two modders' changes to the same LUT can be put
+// together. Each channel changed in only one copy takes that copy's value;
+// texels with a channel changed differently in both are conflicts, left for
+// the user to resolve.
+package merge
+
+import (
+	"fmt"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Choice is which version of a conflicting texel's channels is kept.
+type Choice int
+
+const (
+	ChooseMine Choice = iota
+	ChooseTheirs
+	ChooseBase
+)
+
+// Choices lists the choices, for choosing between them.
+var Choices = []Choice{ChooseMine, ChooseTheirs, ChooseBase}
+
+func (c Choice) String() string {
+	switch c {
+	case ChooseMine:
+		return "Mine"
+	case ChooseTheirs:
+		return "Theirs"
+	case ChooseBase:
+		return "Base"
+	}
+	return fmt.Sprintf("Choice(%d)", int(c))
+}
+
+// Conflict is a texel with channels changed differently in both copies.
+type Conflict struct {
+	X, Y int
+	// Channels are the indexes of the conflicting channels
+	Channels []int
+	// Merged is the texel with the channels that don't conflict merged
+	Merged             [4]float64
+	Base, Theirs, Mine [4]float64
+	Choice             Choice
+}
+
+// Version returns the texel as it is in the image choice picks.
+func (c Conflict) Version(choice Choice) [4]float64 {
+	switch choice {
+	case ChooseTheirs:
+		return c.Theirs
+	case ChooseBase:
+		return c.Base
+	}
+	return c.Mine
+}
+
+// Value returns the merged texel, with the conflicting channels taken from
+// the chosen version.
+func (c Conflict) Value() [4]float64 {
+	from := c.Version(c.Choice)
+	out := c.Merged
+	for _, ch := range c.Channels {
+		out[ch] = from[ch]
+	}
+	return out
+}
+
+// Result is what merging found.
+type Result struct {
+	// FromTheirs and FromMine are the number of texels with changes from only
+	// that copy, and Both the number with changes from each copy to different
+	// channels, or the same change made in both
+	FromTheirs, FromMine, Both int
+	Conflicts                  []Conflict
+}
+
+// Merge writes the merge of theirs and mine, both edited from base, into out,
+// which may be mine. Conflicting channels are left with mine's value, and are
+// listed in the result with ChooseMine chosen. All the images must be the same
+// size.
+func Merge(out, base, theirs, mine hdrColors.RawImage) (*Result, error) {
+	bounds := base.Bounds()
+	for _, img := range []hdrColors.RawImage{out, theirs, mine} {
+		if img.Bounds().Size() != bounds.Size() {
+			return nil, fmt.Errorf("the images must be the same size, but the base is %dx%d and another is %dx%d", bounds.Dx(), bounds.Dy(), img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+	result := &Result{}
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			b := base.RawAt(bounds.Min.X+x, bounds.Min.Y+y)
+			t := theirs.RawAt(theirs.Bounds().Min.X+x, theirs.Bounds().Min.Y+y)
+			m := mine.RawAt(mine.Bounds().Min.X+x, mine.Bounds().Min.Y+y)
+			merged := m
+			var conflicting []int
+			var fromTheirs, fromMine bool
+			for ch := range merged {
+				switch {
+				case t[ch] == m[ch]:
+					fromTheirs = fromTheirs || t[ch] != b[ch]
+					fromMine = fromMine || m[ch] != b[ch]
+				case m[ch] == b[ch]:
+					merged[ch] = t[ch]
+					fromTheirs = true
+				case t[ch] == b[ch]:
+					fromMine = true
+				default:
+					conflicting = append(conflicting, ch)
+				}
+			}
+			switch {
+			case len(conflicting) > 0:
+				result.Conflicts = append(result.Conflicts, Conflict{X: x, Y: y, Channels: conflicting, Merged: merged, Base: b, Theirs: t, Mine: m})
+			case fromTheirs && fromMine:
+				result.Both++
+			case fromTheirs:
+				result.FromTheirs++
+			case fromMine:
+				result.FromMine++
+			}
+			out.SetRaw(out.Bounds().Min.X+x, out.Bounds().Min.Y+y, merged)
+		}
+	}
+	return result, nil
+}
+
+// Resolve writes each conflict's chosen value into out, the image Merge wrote
+// to.
+func Resolve(out hdrColors.RawImage, conflicts []Conflict) {
+	origin := out.Bounds().Min
+	for _, c := range conflicts {
+		out.SetRaw(origin.X+c.X, origin.Y+c.Y, c.Value())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"image/color"
 	"io"
 	"slices"
+	"strings"
 
 	"github.com/ryanjsims/hd2-lut-editor/dds"
 	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
@@ -200,6 +201,94 @@ type Attribute struct {
 	Data []uint8
 }
 
+// requiredAttributes are stored in the OpenEXRHeader fields rather than in
+// Attributes
+var requiredAttributes = []string{
+	"channels",
+	"compression",
+	"dataWindow",
+	"displayWindow",
+	"lineOrder",
+	"pixelAspectRatio",
+	"screenWindowCenter",
+	"screenWindowWidth",
+}
+
+// FindAttribute returns the attribute with the given name, or nil.
+func FindAttribute(attrs []Attribute, name string) *Attribute {
+	for i := range attrs {
+		if attrs[i].Name == name {
+			return &attrs[i]
+		}
+	}
+	return nil
+}
+
+// StringAttribute returns a string attribute, such as the metadata modders
+// attach to record where a texture came from.
+func StringAttribute(name, value string) Attribute {
+	return Attribute{Name: name, Type: "string", Size: uint32(len(value)), Data: []byte(value)}
+}
+
+// RemoveAttribute returns attrs without the attribute with the given name.
+// attrs is not modified.
+func RemoveAttribute(attrs []Attribute, name string) []Attribute {
+	removed := make([]Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		if attr.Name != name {
+			removed = append(removed, attr)
+		}
+	}
+	return removed
+}
+
+// SetAttribute returns attrs with attr in place of any attribute of the same
+// name. attrs is not modified.
+func SetAttribute(attrs []Attribute, attr Attribute) []Attribute {
+	return append(RemoveAttribute(attrs, attr.Name), attr)
+}
+
+// Chromaticities holds the CIE xy coordinates of the RGB primaries and white
+// point, in the order they are stored in a chromaticities attribute.
+type Chromaticities struct {
+	RedX, RedY     float32
+	GreenX, GreenY float32
+	BlueX, BlueY   float32
+	WhiteX, WhiteY float32
+}
+
+// Colorimetry holds the optional attributes describing how RGB values map to
+// colors. Absent attributes are nil.
+type Colorimetry struct {
+	Chromaticities *Chromaticities
+	// WhiteLuminance is the luminance, in cd/m^2, of RGB (1, 1, 1)
+	WhiteLuminance *float32
+	// AdoptedNeutral is the CIE xy coordinate that should appear neutral
+	AdoptedNeutral *[2]float32
+}
+
+func decodeAttribute[T any](attrs []Attribute, name, typ string) *T {
+	attr := FindAttribute(attrs, name)
+	if attr == nil || attr.Type != typ {
+		return nil
+	}
+	var value T
+	if _, err := binary.Decode(attr.Data, binary.LittleEndian, &value); err != nil {
+		return nil
+	}
+	return &value
+}
+
+// ColorimetryFromAttributes parses the chromaticities, whiteLuminance and
+// adoptedNeutral attributes. Malformed attributes are treated as absent.
+func ColorimetryFromAttributes(attrs []Attribute) Colorimetry {
+	return Colorimetry{
+		Chromaticities: decodeAttribute[Chromaticities](attrs, "chromaticities", "chromaticities"),
+		WhiteLuminance: decodeAttribute[float32](attrs, "whiteLuminance", "float"),
+		AdoptedNeutral: decodeAttribute[[2]float32](attrs, "adoptedNeutral", "v2f"),
+	}
+}
+
 type ScanLine struct {
 	YCoord     uint32
 	Size       uint32
@@ -220,7 +309,14 @@ type OpenEXRHeader struct {
 	PixelAspectRatio   float32
 	ScreenWindowCenter [2]float32
 	ScreenWindowWidth  float32
-	OffsetTable        []uint64
+	// Tiles describes the tiles of tiled files, and is nil for scanline files
+	Tiles *TileDescription
+	// Attributes holds any attributes beyond the required ones, in file order
+	Attributes  []Attribute
+	OffsetTable []uint64
+	// chunksStart is where the chunks begin in the file, just after the
+	// offset table, whose offsets count from the start of the file
+	chunksStart uint64
 }
 
 type OpenEXR struct {
@@ -315,7 +411,7 @@ func loadEXRHeader(r *bufio.Reader) (*OpenEXRHeader, error) {
 		return nil, fmt.Errorf("unsupported EXR version %v", version_flags[0])
 	}
 
-	if version_flags[1] != 0 || version_flags[2] != 0 || version_flags[3] != 0 {
+	if version_flags[1]&^flagTiled != 0 || version_flags[2] != 0 || version_flags[3] != 0 {
 		return nil, fmt.Errorf("unsupported flags in EXR %02x %02x %02x", version_flags[1], version_flags[2], version_flags[3])
 	}
 
@@ -328,26 +424,22 @@ func loadEXRHeader(r *bufio.Reader) (*OpenEXRHeader, error) {
 		pixelAspectRatio   float32
 		screenWindowCenter [2]float32
 		screenWindowWidth  float32
+		tiles              *TileDescription
+		attributes         []Attribute
 	)
 
-	var requiredFields []string = []string{
-		"channels",
-		"compression",
-		"dataWindow",
-		"displayWindow",
-		"lineOrder",
-		"pixelAspectRatio",
-		"screenWindowCenter",
-		"screenWindowWidth",
-	}
+	var requiredFields []string = slices.Clone(requiredAttributes)
 
+	// The magic number and version come before the attributes
+	headerSize := uint64(8)
 	name, err := r.ReadString(0)
 	for len(name) > 1 {
 		if err != nil {
 			return nil, err
 		}
 
-		_, err = r.ReadString(0)
+		var typ string
+		typ, err = r.ReadString(0)
 		if err != nil {
 			return nil, err
 		}
@@ -357,6 +449,7 @@ func loadEXRHeader(r *bufio.Reader) (*OpenEXRHeader, error) {
 		if err != nil {
 			return nil, err
 		}
+		headerSize += uint64(len(name)+len(typ)+4) + uint64(size)
 
 		switch name[:len(name)-1] {
 		case "channels":
@@ -375,9 +468,18 @@ func loadEXRHeader(r *bufio.Reader) (*OpenEXRHeader, error) {
 			err = binary.Read(r, binary.LittleEndian, &screenWindowCenter)
 		case "screenWindowWidth":
 			err = binary.Read(r, binary.LittleEndian, &screenWindowWidth)
+		case "tiles":
+			tiles = &TileDescription{}
+			err = binary.Read(r, binary.LittleEndian, tiles)
 		default:
 			var data []byte = make([]byte, size)
 			err = binary.Read(r, binary.LittleEndian, data)
+			attributes = append(attributes, Attribute{
+				Name: name[:len(name)-1],
+				Type: typ[:len(typ)-1],
+				Size: size,
+				Data: data,
+			})
 		}
 
 		if err != nil {
@@ -406,6 +508,18 @@ func loadEXRHeader(r *bufio.Reader) (*OpenEXRHeader, error) {
 	if lineCount%uint32(compression.LineCount()) != 0 {
 		scanlineCount += 1
 	}
+	if version_flags[1]&flagTiled != 0 {
+		if tiles == nil {
+			return nil, fmt.Errorf("tiled exr missing tiles attribute")
+		}
+		chunkCount, err := tiles.chunkCount(dataWindow)
+		if err != nil {
+			return nil, err
+		}
+		scanlineCount = uint32(chunkCount)
+	} else {
+		tiles = nil
+	}
 
 	var offsetTable []uint64 = make([]uint64, scanlineCount)
 	err = binary.Read(r, binary.LittleEndian, offsetTable)
@@ -424,17 +538,28 @@ func loadEXRHeader(r *bufio.Reader) (*OpenEXRHeader, error) {
 		PixelAspectRatio:   pixelAspectRatio,
 		ScreenWindowCenter: screenWindowCenter,
 		ScreenWindowWidth:  screenWindowWidth,
+		Tiles:              tiles,
+		Attributes:         attributes,
 		OffsetTable:        offsetTable,
+		chunksStart:        headerSize + 1 + 8*uint64(len(offsetTable)),
 	}, nil
 }
 
+// LoadOpenEXRHeader reads only the header and offset table of an EXR file.
+func LoadOpenEXRHeader(r io.Reader) (*OpenEXRHeader, error) {
+	return loadEXRHeader(bufio.NewReader(r))
+}
+
 func LoadOpenEXR(r bufio.Reader) (*OpenEXR, error) {
 	header, err := loadEXRHeader(&r)
 	if err != nil {
 		return nil, err
 	}
+	if header.Tiles != nil {
+		return loadTiles(&r, header)
+	}
 	height := (header.DataWindow.YMax - header.DataWindow.YMin + 1)
-	width := (header.DataWindow.YMax - header.DataWindow.YMin + 1)
+	width := (header.DataWindow.XMax - header.DataWindow.XMin + 1)
 
 	pixelSize := 0
 	for _, channel := range header.Channels {
@@ -618,6 +743,14 @@ func dumpAttributes(w io.Writer, exr *OpenEXR) (int64, error) {
 		return -1, err
 	}
 
+	for _, attr := range sortedAttributes(exr.Attributes) {
+		offset += written
+		written, err = dumpAttribute(w, attr.Name, attr.Type, attr.Data)
+		if err != nil {
+			return -1, err
+		}
+	}
+
 	err = binary.Write(w, binary.LittleEndian, byte(0))
 	if err != nil {
 		return -1, err
@@ -627,22 +760,48 @@ func dumpAttributes(w io.Writer, exr *OpenEXR) (int64, error) {
 	return int64(offset), nil
 }
 
-func (exr *OpenEXR) dump(w io.Writer) error {
+// sortedAttributes returns the optional attributes in attrs ordered by name,
+// keeping the first of any with the same name, so that saving a file doesn't
+// depend on the order the tool that wrote it used.
+func sortedAttributes(attrs []Attribute) []Attribute {
+	sorted := make([]Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		if slices.Contains(requiredAttributes, attr.Name) ||
+			slices.ContainsFunc(sorted, func(a Attribute) bool { return a.Name == attr.Name }) {
+			continue
+		}
+		sorted = append(sorted, attr)
+	}
+	slices.SortStableFunc(sorted, func(a, b Attribute) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return sorted
+}
+
+// dumpHeader writes everything before the offset table and returns its size.
+func (exr *OpenEXR) dumpHeader(w io.Writer) (int64, error) {
 	err := binary.Write(w, binary.LittleEndian, exr.Magic)
 	if err != nil {
-		return err
+		return -1, err
 	}
 
 	err = binary.Write(w, binary.LittleEndian, [4]byte{exr.Version, 0, 0, 0})
 	if err != nil {
-		return err
+		return -1, err
 	}
 
 	offset, err := dumpAttributes(w, exr)
+	if err != nil {
+		return -1, err
+	}
+	return offset + int64(binary.Size(exr.Magic)) + int64(binary.Size([4]byte{exr.Version, 0, 0, 0})), nil
+}
+
+func (exr *OpenEXR) dump(w io.Writer) error {
+	offset, err := exr.dumpHeader(w)
 	if err != nil {
 		return err
 	}
-	offset += int64(binary.Size(exr.Magic)) + int64(binary.Size([4]byte{exr.Version, 0, 0, 0}))
 
 	offset += int64(8 * len(exr.ScanLines))
 	for i := range exr.ScanLines {
@@ -680,7 +839,46 @@ func (exr *OpenEXR) dump(w io.Writer) error {
 	return nil
 }
 
-func openEXRFromHDRImage(img image.Image) (*OpenEXR, error) {
+// pixelSource reads the raw channel values of one of the HDR image types.
+type pixelSource struct {
+	pix      []byte
+	offset   func(x, y int) int
+	bounds   image.Rectangle
+	pixelFmt PixelType
+}
+
+func newPixelSource(img image.Image) (*pixelSource, error) {
+	if ddsImg, ok := img.(*dds.DDS); ok {
+		img = ddsImg.Image
+	}
+	src := &pixelSource{bounds: img.Bounds()}
+	switch img.ColorModel() {
+	case hdrColors.NRGBA128UModel:
+		imgView, ok := img.(*hdrColors.NRGBA128UImage)
+		if !ok {
+			return nil, fmt.Errorf("could not convert image to PixelType uint")
+		}
+		src.pix, src.offset, src.pixelFmt = imgView.Pix, imgView.PixOffset, TypeUInt
+	case hdrColors.NRGBA64FModel:
+		imgView, ok := img.(*hdrColors.NRGBA64FImage)
+		if !ok {
+			return nil, fmt.Errorf("could not convert image to PixelType half")
+		}
+		src.pix, src.offset, src.pixelFmt = imgView.Pix, imgView.PixOffset, TypeHalf
+	case hdrColors.NRGBA128FModel:
+		imgView, ok := img.(*hdrColors.NRGBA128FImage)
+		if !ok {
+			return nil, fmt.Errorf("could not convert image to PixelType float")
+		}
+		src.pix, src.offset, src.pixelFmt = imgView.Pix, imgView.PixOffset, TypeFloat
+	default:
+		return nil, fmt.Errorf("not currently implemented")
+	}
+	return src, nil
+}
+
+// headerFromHDRImage returns the header the editor writes for img.
+func headerFromHDRImage(src *pixelSource) *OpenEXRHeader {
 	var (
 		channels           []Channel   = make([]Channel, 4)
 		compression        Compression = CompressionZIP
@@ -692,153 +890,176 @@ func openEXRFromHDRImage(img image.Image) (*OpenEXR, error) {
 		screenWindowWidth  float32    = 1.0
 	)
 
+	// The data window always starts at the origin, so the same pixels are
+	// written the same way whatever the bounds of the image they came from
 	dataWindow = Box2i{
-		XMin: uint32(img.Bounds().Min.X),
-		XMax: uint32(img.Bounds().Max.X - 1),
-		YMin: uint32(img.Bounds().Min.Y),
-		YMax: uint32(img.Bounds().Max.Y - 1),
+		XMin: 0,
+		XMax: uint32(src.bounds.Dx() - 1),
+		YMin: 0,
+		YMax: uint32(src.bounds.Dy() - 1),
 	}
 
 	displayWindow = dataWindow
 
-	var pixelFmt PixelType
-	switch img.ColorModel() {
-	case hdrColors.NRGBA128UModel:
-		pixelFmt = TypeUInt
-	case hdrColors.NRGBA64FModel:
-		pixelFmt = TypeHalf
-	case hdrColors.NRGBA128FModel:
-		pixelFmt = TypeFloat
-	default:
-		pixelFmt = TypeFloat
-	}
-
 	for i := range channels {
 		channels[i].Linear = 0
 		channels[i].XSampling = 1
 		channels[i].YSampling = 1
 		channels[i].Name = []string{"A", "B", "G", "R"}[i]
-		channels[i].PixelFmt = pixelFmt
+		channels[i].PixelFmt = src.pixelFmt
 	}
 
-	var pixels []byte
-	var offsetFunc func(x, y int) int
-	switch img.ColorModel() {
-	case hdrColors.NRGBA128UModel:
-		imgView, ok := img.(*hdrColors.NRGBA128UImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				return nil, fmt.Errorf("could not convert image to PixelType uint")
-			}
-			imgView, ok = ddsImg.Image.(*hdrColors.NRGBA128UImage)
-			if !ok {
-				return nil, fmt.Errorf("could not convert image to PixelType uint")
-			}
-		}
-		pixels = imgView.Pix
-		offsetFunc = func(x, y int) int {
-			return imgView.PixOffset(x, y)
-		}
-	case hdrColors.NRGBA64FModel:
-		imgView, ok := img.(*hdrColors.NRGBA64FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				return nil, fmt.Errorf("could not convert image to PixelType half")
-			}
-			imgView, ok = ddsImg.Image.(*hdrColors.NRGBA64FImage)
-			if !ok {
-				return nil, fmt.Errorf("could not convert image to PixelType half")
-			}
-		}
-		pixels = imgView.Pix
-		offsetFunc = func(x, y int) int {
-			return imgView.PixOffset(x, y)
-		}
-	case hdrColors.NRGBA128FModel:
-		imgView, ok := img.(*hdrColors.NRGBA128FImage)
-		if !ok {
-			ddsImg, ok := img.(*dds.DDS)
-			if !ok {
-				return nil, fmt.Errorf("could not convert image to PixelType float")
-			}
-			imgView, ok = ddsImg.Image.(*hdrColors.NRGBA128FImage)
-			if !ok {
-				return nil, fmt.Errorf("could not convert image to PixelType float")
-			}
-		}
-		pixels = imgView.Pix
-		offsetFunc = func(x, y int) int {
-			return imgView.PixOffset(x, y)
-		}
-	default:
-		return nil, fmt.Errorf("not currently implemented")
+	blocks := (dataWindow.Height() + uint32(compression.LineCount()) - 1) / uint32(compression.LineCount())
+	return &OpenEXRHeader{
+		Magic:              EXR_MAGIC,
+		Version:            2,
+		Flags:              [3]uint8{0, 0, 0},
+		Channels:           channels,
+		Compression:        compression,
+		DataWindow:         dataWindow,
+		DisplayWindow:      displayWindow,
+		LineOrder:          lineOrder,
+		PixelAspectRatio:   pixelAspectRatio,
+		ScreenWindowCenter: screenWindowCenter,
+		ScreenWindowWidth:  screenWindowWidth,
+		OffsetTable:        make([]uint64, blocks),
 	}
+}
 
-	var scanlines []ScanLine = make([]ScanLine, 0, 1)
-	uncompressedSize := min(uint32(compression.LineCount()), dataWindow.Height()) * dataWindow.Width() * uint32(pixelFmt.Size()) * uint32(len(channels))
+// scanlineBlock returns the uncompressed block of scanlines starting at row
+// yCoord of the data window.
+func (header *OpenEXRHeader) scanlineBlock(src *pixelSource, yCoord uint32) ScanLine {
+	dataWindow := header.DataWindow
+	pixelFmt := src.pixelFmt
+	depth := len(header.Channels)
+	lineCount := uint32(header.Compression.LineCount())
+	uncompressedSize := min(lineCount, dataWindow.Height()-yCoord) * dataWindow.Width() * uint32(pixelFmt.Size()) * uint32(depth)
 	scanline := ScanLine{
-		YCoord:     0,
+		YCoord:     yCoord,
 		Size:       uncompressedSize,
-		LineCount:  uint32(compression.LineCount()),
+		LineCount:  lineCount,
 		Compressed: false,
 		Data:       make([]uint8, uncompressedSize),
 	}
-	for row := 0; row < int(dataWindow.Height()); row++ {
-		for channel := 0; channel < len(channels); channel++ {
+	for row := int(yCoord); row < int(min(yCoord+lineCount, dataWindow.Height())); row++ {
+		for channel := 0; channel < depth; channel++ {
 			for column := 0; column < int(dataWindow.Width()); column++ {
-				scanOffset := scanline.offset(column, row, channel, len(channels), pixelFmt, dataWindow)
+				scanOffset := scanline.offset(column, row, channel, depth, pixelFmt, dataWindow)
 				scanEnd := scanOffset + int64(pixelFmt.Size())
 
-				pixOffset := offsetFunc(column, row) + (3-channel)*pixelFmt.Size()
+				pixOffset := src.offset(src.bounds.Min.X+column, src.bounds.Min.Y+row) + (3-channel)*pixelFmt.Size()
 				pixEnd := pixOffset + pixelFmt.Size()
-				copy(scanline.Data[scanOffset:scanEnd], pixels[pixOffset:pixEnd])
-			}
-		}
-
-		if uint32(row) == scanline.YCoord+scanline.LineCount-1 && row < int(dataWindow.Height())-1 {
-			uncompressedSize := min(uint32(compression.LineCount()), dataWindow.Height()-uint32(row)) * dataWindow.Width() * uint32(pixelFmt.Size()) * uint32(len(channels))
-			scanlines = append(scanlines, scanline)
-			scanline = ScanLine{
-				YCoord:     uint32(row + 1),
-				Size:       uncompressedSize,
-				LineCount:  uint32(compression.LineCount()),
-				Compressed: false,
-				Data:       make([]uint8, uncompressedSize),
+				copy(scanline.Data[scanOffset:scanEnd], src.pix[pixOffset:pixEnd])
 			}
 		}
 	}
-	scanlines = append(scanlines, scanline)
+	return scanline
+}
 
+func openEXRFromHDRImage(img image.Image) (*OpenEXR, error) {
+	src, err := newPixelSource(img)
+	if err != nil {
+		return nil, err
+	}
+	header := headerFromHDRImage(src)
+	scanlines := make([]ScanLine, 0, len(header.OffsetTable))
+	lineCount := uint32(header.Compression.LineCount())
+	for yCoord := uint32(0); yCoord < header.DataWindow.Height(); yCoord += lineCount {
+		scanlines = append(scanlines, header.scanlineBlock(src, yCoord))
+	}
 	return &OpenEXR{
-		OpenEXRHeader: OpenEXRHeader{
-			Magic:              EXR_MAGIC,
-			Version:            2,
-			Flags:              [3]uint8{0, 0, 0},
-			Channels:           channels,
-			Compression:        compression,
-			DataWindow:         dataWindow,
-			DisplayWindow:      displayWindow,
-			LineOrder:          lineOrder,
-			PixelAspectRatio:   pixelAspectRatio,
-			ScreenWindowCenter: screenWindowCenter,
-			ScreenWindowWidth:  screenWindowWidth,
-			OffsetTable:        make([]uint64, len(scanlines)),
-		},
-		ScanLines: scanlines,
+		OpenEXRHeader: *header,
+		ScanLines:     scanlines,
 	}, nil
 }
 
 func WriteHDR(w io.Writer, img image.Image) error {
+	return WriteHDRWithAttributes(w, img, nil)
+}
+
+// WriteHDRWithAttributes writes img along with extra header attributes, such
+// as those loaded from the file the image came from. Writers that can seek,
+// such as files, are written to with WriteHDRStream.
+func WriteHDRWithAttributes(w io.Writer, img image.Image, attrs []Attribute) error {
+	if ws, ok := w.(io.WriteSeeker); ok {
+		return WriteHDRStream(ws, img, attrs)
+	}
 	exr, err := openEXRFromHDRImage(img)
 	if err != nil {
 		return err
 	}
+	exr.Attributes = attrs
 	err = exr.dump(w)
 	return err
 }
 
+// WriteHDRStream writes the same file as WriteHDRWithAttributes, compressing
+// and writing each block of scanlines as it walks the image instead of
+// building them all in memory first. The offset table is written as zeroes and
+// filled in once the block sizes are known, leaving w positioned at the end of
+// the file.
+func WriteHDRStream(w io.WriteSeeker, img image.Image, attrs []Attribute) error {
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	src, err := newPixelSource(img)
+	if err != nil {
+		return err
+	}
+	exr := &OpenEXR{OpenEXRHeader: *headerFromHDRImage(src)}
+	exr.Attributes = attrs
+
+	offset, err := exr.dumpHeader(w)
+	if err != nil {
+		return err
+	}
+	offsetTable := exr.OffsetTable
+	tableOffset := offset
+	err = binary.Write(w, binary.LittleEndian, offsetTable)
+	if err != nil {
+		return err
+	}
+	offset += int64(8 * len(offsetTable))
+
+	lineCount := uint32(exr.Compression.LineCount())
+	for i := range offsetTable {
+		scanline := exr.scanlineBlock(src, uint32(i)*lineCount)
+		if exr.Compression != CompressionNone {
+			err = scanline.Compress(exr.Compression)
+			if err != nil {
+				return err
+			}
+		}
+		offsetTable[i] = uint64(offset)
+
+		err = binary.Write(w, binary.LittleEndian, scanline.YCoord)
+		if err != nil {
+			return err
+		}
+		err = binary.Write(w, binary.LittleEndian, uint32(len(scanline.Data)))
+		if err != nil {
+			return err
+		}
+		err = binary.Write(w, binary.LittleEndian, scanline.Data)
+		if err != nil {
+			return err
+		}
+		offset += int64(8 + len(scanline.Data))
+	}
+
+	_, err = w.Seek(start+tableOffset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	err = binary.Write(w, binary.LittleEndian, offsetTable)
+	if err != nil {
+		return err
+	}
+	_, err = w.Seek(start+offset, io.SeekStart)
+	return err
+}
+
 func reconstruct(data []byte) []byte {
 	output := make([]byte, len(data))
 	output[0] = data[0]
@@ -899,19 +1120,25 @@ func decompressNotImplemented(data []byte) ([]byte, error) {
 	return nil, fmt.Errorf("unimplemented compression scheme")
 }
 
-func (scanline *ScanLine) Decompress(compression Compression) error {
+// Decompress replaces the scanline's data with its pixels, laid out as
+// described by header.
+func (scanline *ScanLine) Decompress(header *OpenEXRHeader) error {
 	if !scanline.Compressed {
 		return nil
 	}
 
 	var decompressFn func([]byte) ([]byte, error)
-	switch compression {
+	switch header.Compression {
 	case CompressionNone:
 		decompressFn = decompressNone
 	case CompressionZIPS:
 		fallthrough
 	case CompressionZIP:
 		decompressFn = decompressZip
+	case CompressionPIZ:
+		decompressFn = func(data []byte) ([]byte, error) {
+			return decompressPIZ(data, header.Channels, int(header.DataWindow.Width()), int(scanline.LineCount))
+		}
 	default:
 		decompressFn = decompressNotImplemented
 	}
@@ -926,12 +1153,19 @@ func (scanline *ScanLine) Decompress(compression Compression) error {
 	return nil
 }
 
+// zipLevel is the zlib level ZIP and ZIPS scanlines are compressed with. It is
+// fixed so that the same pixels always compress to the same bytes.
+const zipLevel = zlib.DefaultCompression
+
 func compressZip(data []byte) ([]byte, error) {
 	reordered := reorder(data)
 	deconstructed := deconstruct(reordered)
 	compressed := bytes.Buffer{}
-	w := zlib.NewWriter(&compressed)
-	n, err := w.Write(deconstructed)
+	w, err := zlib.NewWriterLevel(&compressed, zipLevel)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(deconstructed)
 	if err != nil {
 		return nil, err
 	}
@@ -939,7 +1173,7 @@ func compressZip(data []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return compressed.Next(n), nil
+	return compressed.Bytes(), nil
 }
 
 func compressNone(data []byte) ([]byte, error) {
@@ -990,7 +1224,7 @@ func (exr *OpenEXR) Pixels() ([][][4]float32, error) {
 	output := make([][][4]float32, height)
 
 	for _, scanline := range exr.ScanLines {
-		if err := scanline.Decompress(exr.Compression); err != nil {
+		if err := scanline.Decompress(&exr.OpenEXRHeader); err != nil {
 			return nil, err
 		}
 
@@ -1076,7 +1310,7 @@ func (exr *OpenEXR) HdrImage() (image.Image, error) {
 	}
 
 	for _, scanline := range exr.ScanLines {
-		if err := scanline.Decompress(exr.Compression); err != nil {
+		if err := scanline.Decompress(&exr.OpenEXRHeader); err != nil {
 			return nil, err
 		}
 
@@ -1115,7 +1349,7 @@ func (exr *OpenEXR) At(x, y int) color.Color {
 		}
 	}
 
-	err := exr.ScanLines[index].Decompress(exr.Compression)
+	err := exr.ScanLines[index].Decompress(&exr.OpenEXRHeader)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,433 @@
+package openexr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// PIZ compression, ported from ImfPizCompressor.cpp, ImfHuf.cpp and
+// ImfWav.cpp of the OpenEXR reference implementation. A block is the 16 bit
+// words of its channels, mapped through a lookup table of the values used,
+// run through a Haar wavelet per channel and then Huffman coded.
+
+const (
+	pizUShortRange = 1 << 16
+	pizBitmapSize  = pizUShortRange >> 3
+
+	hufEncBits  = 16
+	hufDecBits  = 14
+	hufEncSize  = 1<<hufEncBits + 1
+	hufDecSize  = 1 << hufDecBits
+	hufDecMask  = hufDecSize - 1
+	hufMaxCodes = 58
+
+	hufShortZeroCodeRun = 59
+	hufLongZeroCodeRun  = 63
+	hufShortestLongRun  = 2 + hufLongZeroCodeRun - hufShortZeroCodeRun
+)
+
+var errPIZCorrupt = errors.New("corrupt PIZ data")
+
+// pizChannel is where one channel of a block sits in the decoded words.
+type pizChannel struct {
+	start, nx, ny int
+	// size is the number of 16 bit words per value
+	size int
+}
+
+// decompressPIZ decodes a PIZ block of lines lines of width texels of
+// channels.
+func decompressPIZ(data []byte, channels []Channel, width, lines int) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	chans := make([]pizChannel, len(channels))
+	total := 0
+	for i, channel := range channels {
+		if channel.XSampling > 1 || channel.YSampling > 1 {
+			return nil, fmt.Errorf("PIZ: subsampled channel %q unsupported", channel.Name)
+		}
+		chans[i] = pizChannel{start: total, nx: width, ny: lines, size: channel.PixelFmt.Size() / 2}
+		total += width * lines * chans[i].size
+	}
+
+	if len(data) < 4 {
+		return nil, errPIZCorrupt
+	}
+	minNonZero := int(binary.LittleEndian.Uint16(data))
+	maxNonZero := int(binary.LittleEndian.Uint16(data[2:]))
+	data = data[4:]
+	if maxNonZero >= pizBitmapSize {
+		return nil, errPIZCorrupt
+	}
+	var bitmap [pizBitmapSize]byte
+	if minNonZero <= maxNonZero {
+		n := maxNonZero - minNonZero + 1
+		if len(data) < n {
+			return nil, errPIZCorrupt
+		}
+		copy(bitmap[minNonZero:], data[:n])
+		data = data[n:]
+	}
+	lut, maxValue := pizReverseLUT(&bitmap)
+
+	if len(data) < 4 {
+		return nil, errPIZCorrupt
+	}
+	length := int(int32(binary.LittleEndian.Uint32(data)))
+	data = data[4:]
+	if length < 0 || length > len(data) {
+		return nil, errPIZCorrupt
+	}
+	words := make([]uint16, total)
+	if err := hufUncompress(data[:length], words); err != nil {
+		return nil, err
+	}
+
+	for _, c := range chans {
+		for j := 0; j < c.size; j++ {
+			wav2Decode(words[c.start+j:], c.nx, c.size, c.ny, c.nx*c.size, maxValue)
+		}
+	}
+	for i, w := range words {
+		words[i] = lut[w]
+	}
+
+	out := make([]byte, 0, 2*total)
+	ends := make([]int, len(chans))
+	for i, c := range chans {
+		ends[i] = c.start
+	}
+	for y := 0; y < lines; y++ {
+		for i, c := range chans {
+			for _, w := range words[ends[i] : ends[i]+c.nx*c.size] {
+				out = binary.LittleEndian.AppendUint16(out, w)
+			}
+			ends[i] += c.nx * c.size
+		}
+	}
+	return out, nil
+}
+
+// pizReverseLUT returns the table mapping the indexes the block was coded
+// with back to the values set in bitmap, and the largest index.
+func pizReverseLUT(bitmap *[pizBitmapSize]byte) ([]uint16, uint16) {
+	lut := make([]uint16, pizUShortRange)
+	k := 0
+	for i := 0; i < pizUShortRange; i++ {
+		if i == 0 || bitmap[i>>3]&(1<<(i&7)) != 0 {
+			lut[k] = uint16(i)
+			k++
+		}
+	}
+	return lut, uint16(k - 1)
+}
+
+// wdec14 inverts the 14 bit wavelet step.
+func wdec14(l, h uint16) (uint16, uint16) {
+	hi := int(int16(h))
+	ai := int(int16(l)) + hi&1 + hi>>1
+	return uint16(int16(ai)), uint16(int16(ai - hi))
+}
+
+// wdec16 inverts the 16 bit wavelet step, which wraps around.
+func wdec16(l, h uint16) (uint16, uint16) {
+	const aOffset, modMask = 1 << 15, 1<<16 - 1
+	m, d := int(l), int(h)
+	b := (m - d>>1) & modMask
+	a := (d + b - aOffset) & modMask
+	return uint16(a), uint16(b)
+}
+
+// wav2Decode inverts the 2D wavelet transform of an nx by ny channel in
+// in, whose values are ox words apart along x and oy along y.
+func wav2Decode(in []uint16, nx, ox, ny, oy int, mx uint16) {
+	wdec := wdec16
+	if mx < 1<<14 {
+		wdec = wdec14
+	}
+	n := min(nx, ny)
+	p := 1
+	for p <= n {
+		p <<= 1
+	}
+	p >>= 1
+	p2 := p
+	p >>= 1
+
+	for p >= 1 {
+		py := 0
+		ey := oy * (ny - p2)
+		oy1, oy2 := oy*p, oy*p2
+		ox1, ox2 := ox*p, ox*p2
+		for ; py <= ey; py += oy2 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				p10 := px + oy1
+				p11 := p10 + ox1
+				i00, i10 := wdec(in[px], in[p10])
+				i01, i11 := wdec(in[p01], in[p11])
+				in[px], in[p01] = wdec(i00, i01)
+				in[p10], in[p11] = wdec(i10, i11)
+			}
+			if nx&p != 0 {
+				p10 := px + oy1
+				in[px], in[p10] = wdec(in[px], in[p10])
+			}
+		}
+		if ny&p != 0 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				in[px], in[p01] = wdec(in[px], in[p01])
+			}
+		}
+		p2 = p
+		p >>= 1
+	}
+}
+
+// hufDec is an entry of the Huffman decoding table: a code of at most
+// hufDecBits bits decoding to lit, or the symbols of the longer codes
+// starting with those bits.
+type hufDec struct {
+	len  int
+	lit  int
+	long []int
+}
+
+// hufBits reads bits most significant first.
+type hufBits struct {
+	data []byte
+	pos  int
+	c    uint64
+	lc   int
+}
+
+func (b *hufBits) getChar() {
+	b.c = b.c<<8 | uint64(b.data[b.pos])
+	b.pos++
+	b.lc += 8
+}
+
+func (b *hufBits) get(n int) (uint64, error) {
+	for b.lc < n {
+		if b.pos >= len(b.data) {
+			return 0, errPIZCorrupt
+		}
+		b.getChar()
+	}
+	b.lc -= n
+	return b.c >> b.lc & (1<<n - 1), nil
+}
+
+func hufLength(code uint64) int { return int(code & 63) }
+
+func hufCode(code uint64) uint64 { return code >> 6 }
+
+// hufUncompress decodes Huffman coded data into raw, which must be exactly
+// as long as the data decodes to.
+func hufUncompress(data []byte, raw []uint16) error {
+	if len(data) == 0 {
+		if len(raw) != 0 {
+			return errPIZCorrupt
+		}
+		return nil
+	}
+	if len(data) < 20 {
+		return errPIZCorrupt
+	}
+	im := int(binary.LittleEndian.Uint32(data))
+	iM := int(binary.LittleEndian.Uint32(data[4:]))
+	nBits := int(binary.LittleEndian.Uint32(data[12:]))
+	if im < 0 || im >= hufEncSize || iM < 0 || iM >= hufEncSize {
+		return errPIZCorrupt
+	}
+	bits := &hufBits{data: data[20:]}
+	codes := make([]uint64, hufEncSize)
+	if err := hufUnpackEncTable(bits, im, iM, codes); err != nil {
+		return err
+	}
+	if nBits > 8*(len(data)-20-bits.pos) {
+		return errPIZCorrupt
+	}
+	table, err := hufBuildDecTable(codes, im, iM)
+	if err != nil {
+		return err
+	}
+	return hufDecode(codes, table, data[20+bits.pos:], nBits, iM, raw)
+}
+
+// hufUnpackEncTable reads the code lengths of symbols im to iM and turns
+// them into canonical codes.
+func hufUnpackEncTable(bits *hufBits, im, iM int, codes []uint64) error {
+	for ; im <= iM; im++ {
+		l, err := bits.get(6)
+		if err != nil {
+			return err
+		}
+		codes[im] = l
+		zeroRun := 0
+		if l == hufLongZeroCodeRun {
+			n, err := bits.get(8)
+			if err != nil {
+				return err
+			}
+			zeroRun = int(n) + hufShortestLongRun
+		} else if l >= hufShortZeroCodeRun {
+			zeroRun = int(l) - hufShortZeroCodeRun + 2
+		}
+		if zeroRun > 0 {
+			if im+zeroRun > iM+1 {
+				return errPIZCorrupt
+			}
+			for ; zeroRun > 0; zeroRun-- {
+				codes[im] = 0
+				im++
+			}
+			im--
+		}
+	}
+	hufCanonicalCodeTable(codes)
+	return nil
+}
+
+// hufCanonicalCodeTable replaces each code length in codes with the length
+// in the low 6 bits and the canonical code above them.
+func hufCanonicalCodeTable(codes []uint64) {
+	var n [hufMaxCodes + 1]uint64
+	for _, l := range codes {
+		n[l]++
+	}
+	var c uint64
+	for i := hufMaxCodes; i > 0; i-- {
+		nc := (c + n[i]) >> 1
+		n[i] = c
+		c = nc
+	}
+	for i, l := range codes {
+		if l > 0 {
+			codes[i] = l | n[l]<<6
+			n[l]++
+		}
+	}
+}
+
+func hufBuildDecTable(codes []uint64, im, iM int) ([]hufDec, error) {
+	table := make([]hufDec, hufDecSize)
+	for ; im <= iM; im++ {
+		c := hufCode(codes[im])
+		l := hufLength(codes[im])
+		if c>>l != 0 {
+			return nil, errPIZCorrupt
+		}
+		if l > hufDecBits {
+			pl := &table[c>>(l-hufDecBits)]
+			if pl.len != 0 {
+				return nil, errPIZCorrupt
+			}
+			pl.long = append(pl.long, im)
+		} else if l > 0 {
+			start := int(c << (hufDecBits - l))
+			for i := start; i < start+1<<(hufDecBits-l); i++ {
+				pl := &table[i]
+				if pl.len != 0 || pl.long != nil {
+					return nil, errPIZCorrupt
+				}
+				pl.len, pl.lit = l, im
+			}
+		}
+	}
+	return table, nil
+}
+
+// hufDecode decodes nBits of data into out. Symbol rlc is followed by an 8
+// bit count of times to repeat the previous symbol.
+func hufDecode(codes []uint64, table []hufDec, data []byte, nBits, rlc int, out []uint16) error {
+	bits := &hufBits{data: data[:(nBits+7)/8]}
+	n := 0
+	emit := func(symbol int) error {
+		if symbol == rlc {
+			if bits.lc < 8 {
+				if bits.pos >= len(bits.data) {
+					return errPIZCorrupt
+				}
+				bits.getChar()
+			}
+			bits.lc -= 8
+			count := int(byte(bits.c >> bits.lc))
+			if n+count > len(out) || n < 1 {
+				return errPIZCorrupt
+			}
+			for s := out[n-1]; count > 0; count-- {
+				out[n] = s
+				n++
+			}
+			return nil
+		}
+		if n >= len(out) {
+			return errPIZCorrupt
+		}
+		out[n] = uint16(symbol)
+		n++
+		return nil
+	}
+
+	for bits.pos < len(bits.data) {
+		bits.getChar()
+		for bits.lc >= hufDecBits {
+			pl := table[bits.c>>(bits.lc-hufDecBits)&hufDecMask]
+			if pl.len != 0 {
+				bits.lc -= pl.len
+				if err := emit(pl.lit); err != nil {
+					return err
+				}
+				continue
+			}
+			if pl.long == nil {
+				return errPIZCorrupt
+			}
+			found := false
+			for _, symbol := range pl.long {
+				l := hufLength(codes[symbol])
+				for bits.lc < l && bits.pos < len(bits.data) {
+					bits.getChar()
+				}
+				if bits.lc >= l && hufCode(codes[symbol]) == bits.c>>(bits.lc-l)&(1<<l-1) {
+					bits.lc -= l
+					if err := emit(symbol); err != nil {
+						return err
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				return errPIZCorrupt
+			}
+		}
+	}
+
+	// The last codes are shorter than hufDecBits
+	i := (8 - nBits) & 7
+	bits.c >>= i
+	bits.lc -= i
+	for bits.lc > 0 {
+		pl := table[bits.c<<(hufDecBits-bits.lc)&hufDecMask]
+		if pl.len == 0 || pl.len > bits.lc {
+			return errPIZCorrupt
+		}
+		bits.lc -= pl.len
+		if err := emit(pl.lit); err != nil {
+			return err
+		}
+	}
+	if n != len(out) {
+		return errPIZCorrupt
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+package openexr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// The encoder below is the other half of piz.go, ported from the same files
+// of the OpenEXR reference implementation, so the decoder can be tested on
+// blocks it didn't write itself.
+
+func wenc14(a, b uint16) (uint16, uint16) {
+	as, bs := int(int16(a)), int(int16(b))
+	return uint16(int16((as + bs) >> 1)), uint16(int16(as - bs))
+}
+
+func wenc16(a, b uint16) (uint16, uint16) {
+	const aOffset, mOffset, modMask = 1 << 15, 1 << 15, 1<<16 - 1
+	ao := (int(a) + aOffset) & modMask
+	m := (ao + int(b)) >> 1
+	d := ao - int(b)
+	if d < 0 {
+		m = (m + mOffset) & modMask
+	}
+	return uint16(m), uint16(d & modMask)
+}
+
+func wav2Encode(in []uint16, nx, ox, ny, oy int, mx uint16) {
+	wenc := wenc16
+	if mx < 1<<14 {
+		wenc = wenc14
+	}
+	n := min(nx, ny)
+	for p, p2 := 1, 2; p2 <= n; p, p2 = p2, p2<<1 {
+		py := 0
+		ey := oy * (ny - p2)
+		oy1, oy2 := oy*p, oy*p2
+		ox1, ox2 := ox*p, ox*p2
+		for ; py <= ey; py += oy2 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				p10 := px + oy1
+				p11 := p10 + ox1
+				i00, i01 := wenc(in[px], in[p01])
+				i10, i11 := wenc(in[p10], in[p11])
+				in[px], in[p10] = wenc(i00, i10)
+				in[p01], in[p11] = wenc(i01, i11)
+			}
+			if nx&p != 0 {
+				p10 := px + oy1
+				in[px], in[p10] = wenc(in[px], in[p10])
+			}
+		}
+		if ny&p != 0 {
+			px := py
+			ex := py + ox*(nx-p2)
+			for ; px <= ex; px += ox2 {
+				p01 := px + ox1
+				in[px], in[p01] = wenc(in[px], in[p01])
+			}
+		}
+	}
+}
+
+// hufWriter writes bits most significant first.
+type hufWriter struct {
+	out []byte
+	c   uint64
+	lc  int
+}
+
+func (w *hufWriter) put(n int, bits uint64) {
+	w.c = w.c<<n | bits&(1<<n-1)
+	w.lc += n
+	for w.lc >= 8 {
+		w.lc -= 8
+		w.out = append(w.out, byte(w.c>>w.lc))
+	}
+}
+
+func (w *hufWriter) code(code uint64) {
+	w.put(hufLength(code), hufCode(code))
+}
+
+func (w *hufWriter) flush() {
+	if w.lc > 0 {
+		w.out = append(w.out, byte(w.c<<(8-w.lc)))
+	}
+}
+
+// hufCodeLengths returns the Huffman code length of each symbol of freq.
+func hufCodeLengths(freq []uint64) []uint64 {
+	type node struct {
+		freq   uint64
+		parent int
+	}
+	var nodes []node
+	var open []int
+	leaves := make(map[int]int)
+	for symbol, f := range freq {
+		if f > 0 {
+			leaves[symbol] = len(nodes)
+			open = append(open, len(nodes))
+			nodes = append(nodes, node{freq: f, parent: -1})
+		}
+	}
+	for len(open) > 1 {
+		sort.Slice(open, func(i, j int) bool { return nodes[open[i]].freq < nodes[open[j]].freq })
+		parent := len(nodes)
+		nodes = append(nodes, node{freq: nodes[open[0]].freq + nodes[open[1]].freq, parent: -1})
+		nodes[open[0]].parent, nodes[open[1]].parent = parent, parent
+		open = append(open[2:], parent)
+	}
+	lengths := make([]uint64, len(freq))
+	for symbol, leaf := range leaves {
+		for i := leaf; nodes[i].parent >= 0; i = nodes[i].parent {
+			lengths[symbol]++
+		}
+	}
+	return lengths
+}
+
+// hufCompress Huffman codes raw the way hufUncompress reads it.
+func hufCompress(raw []uint16) []byte {
+	if len(raw) == 0 {
+		return nil
+	}
+	freq := make([]uint64, hufEncSize)
+	for _, v := range raw {
+		freq[v]++
+	}
+	im := 0
+	for freq[im] == 0 {
+		im++
+	}
+	iM := 0
+	for i := range freq {
+		if freq[i] > 0 {
+			iM = i
+		}
+	}
+	// The symbol after the last is the run length code
+	iM++
+	freq[iM] = 1
+	codes := hufCodeLengths(freq)
+	hufCanonicalCodeTable(codes)
+
+	table := &hufWriter{}
+	for i := im; i <= iM; i++ {
+		l := hufLength(codes[i])
+		if l == 0 {
+			run := 1
+			for i < iM && run < 255+hufShortestLongRun && hufLength(codes[i+1]) == 0 {
+				i++
+				run++
+			}
+			if run >= hufShortestLongRun {
+				table.put(6, hufLongZeroCodeRun)
+				table.put(8, uint64(run-hufShortestLongRun))
+				continue
+			} else if run >= 2 {
+				table.put(6, uint64(hufShortZeroCodeRun+run-2))
+				continue
+			}
+		}
+		table.put(6, uint64(l))
+	}
+	table.flush()
+
+	data := &hufWriter{}
+	send := func(symbol uint16, run int) {
+		code, rlc := codes[symbol], codes[iM]
+		if hufLength(code)+hufLength(rlc)+8 < hufLength(code)*run {
+			data.code(code)
+			data.code(rlc)
+			data.put(8, uint64(run))
+			return
+		}
+		for ; run >= 0; run-- {
+			data.code(code)
+		}
+	}
+	s, run := raw[0], 0
+	for _, v := range raw[1:] {
+		if v == s && run < 255 {
+			run++
+		} else {
+			send(s, run)
+			run = 0
+		}
+		s = v
+	}
+	send(s, run)
+	nBits := 8*len(data.out) + data.lc
+	data.flush()
+
+	out := make([]byte, 20, 20+len(table.out)+len(data.out))
+	binary.LittleEndian.PutUint32(out, uint32(im))
+	binary.LittleEndian.PutUint32(out[4:], uint32(iM))
+	binary.LittleEndian.PutUint32(out[8:], uint32(len(table.out)))
+	binary.LittleEndian.PutUint32(out[12:], uint32(nBits))
+	out = append(out, table.out...)
+	return append(out, data.out...)
+}
+
+// compressPIZ codes a block of lines lines of width texels of channels.
+func compressPIZ(data []byte, channels []Channel, width, lines int) []byte {
+	chans := make([]pizChannel, len(channels))
+	total := 0
+	for i, channel := range channels {
+		chans[i] = pizChannel{start: total, nx: width, ny: lines, size: channel.PixelFmt.Size() / 2}
+		total += width * lines * chans[i].size
+	}
+	words := make([]uint16, total)
+	ends := make([]int, len(chans))
+	for i, c := range chans {
+		ends[i] = c.start
+	}
+	for y := 0; y < lines; y++ {
+		for i, c := range chans {
+			for j := range c.nx * c.size {
+				words[ends[i]+j] = binary.LittleEndian.Uint16(data)
+				data = data[2:]
+			}
+			ends[i] += c.nx * c.size
+		}
+	}
+
+	var bitmap [pizBitmapSize]byte
+	for _, w := range words {
+		bitmap[w>>3] |= 1 << (w & 7)
+	}
+	bitmap[0] &^= 1
+	minNonZero, maxNonZero := pizBitmapSize-1, 0
+	for i, b := range bitmap {
+		if b != 0 {
+			minNonZero = min(minNonZero, i)
+			maxNonZero = i
+		}
+	}
+	lut := make([]uint16, pizUShortRange)
+	k := 0
+	for i := range lut {
+		if i == 0 || bitmap[i>>3]&(1<<(i&7)) != 0 {
+			lut[i] = uint16(k)
+			k++
+		}
+	}
+	maxValue := uint16(k - 1)
+	for i, w := range words {
+		words[i] = lut[w]
+	}
+
+	out := binary.LittleEndian.AppendUint16(nil, uint16(minNonZero))
+	out = binary.LittleEndian.AppendUint16(out, uint16(maxNonZero))
+	if minNonZero <= maxNonZero {
+		out = append(out, bitmap[minNonZero:maxNonZero+1]...)
+	}
+	for _, c := range chans {
+		for j := 0; j < c.size; j++ {
+			wav2Encode(words[c.start+j:], c.nx, c.size, c.ny, c.nx*c.size, maxValue)
+		}
+	}
+	coded := hufCompress(words)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(coded)))
+	return append(out, coded...)
+}
+
+func TestPIZRoundTripRandom(t *testing.T) {
+	// Random words use more than 1<<14 values, so the 16 bit wavelet and
+	// codes longer than hufDecBits are used
+	channels := []Channel{{Name: "A", PixelFmt: TypeHalf}, {Name: "B", PixelFmt: TypeFloat}, {Name: "G", PixelFmt: TypeUInt}}
+	width, lines := 123, 32
+	data := make([]byte, width*lines*(2+4+4))
+	rand.New(rand.NewSource(1)).Read(data)
+
+	decoded, err := decompressPIZ(compressPIZ(data, channels, width, lines), channels, width, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("decoded block differs from the one coded")
+	}
+}
+
+// writePIZ writes img to a PIZ compressed file, keeping blocks that don't
+// compress uncompressed like the reference implementation.
+func writePIZ(t *testing.T, img image.Image) []byte {
+	src, err := newPixelSource(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := headerFromHDRImage(src)
+	header.Compression = CompressionPIZ
+	lineCount := uint32(header.Compression.LineCount())
+	header.OffsetTable = make([]uint64, (header.DataWindow.Height()+lineCount-1)/lineCount)
+	exr := &OpenEXR{OpenEXRHeader: *header}
+	for yCoord := uint32(0); yCoord < header.DataWindow.Height(); yCoord += lineCount {
+		scanline := header.scanlineBlock(src, yCoord)
+		lines := min(lineCount, header.DataWindow.Height()-yCoord)
+		compressed := compressPIZ(scanline.Data, header.Channels, int(header.DataWindow.Width()), int(lines))
+		if len(compressed) >= len(scanline.Data) {
+			t.Fatalf("block at %d did not compress, so PIZ decoding isn't tested", yCoord)
+		}
+		scanline.Data, scanline.Size, scanline.Compressed = compressed, uint32(len(compressed)), true
+		exr.ScanLines = append(exr.ScanLines, scanline)
+	}
+	var buf bytes.Buffer
+	if err := exr.dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func loadHDR(t *testing.T, data []byte) image.Image {
+	exr, err := LoadOpenEXR(*bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := exr.HdrImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// testImage returns a non-square image of a few values, which compresses.
+func testImage(format hdrColors.Format, width, height int) hdrColors.RawImage {
+	img := format.New(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetRaw(x, y, [4]float64{float64(x%4) / 4, float64(y%2) / 2, float64((x+y)%3) / 2, 1})
+		}
+	}
+	return img
+}
+
+func sameImages(t *testing.T, got, want hdrColors.RawImage) {
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("loaded %v image, want %v", got.Bounds(), want.Bounds())
+	}
+	for y := range want.Bounds().Dy() {
+		for x := range want.Bounds().Dx() {
+			if got.RawAt(x, y) != want.RawAt(x, y) {
+				t.Fatalf("texel (%d, %d) is %v, want %v", x, y, got.RawAt(x, y), want.RawAt(x, y))
+			}
+		}
+	}
+}
+
+func TestPIZRoundTripNonSquare(t *testing.T) {
+	for _, format := range hdrColors.Formats[:2] {
+		for _, size := range []image.Point{{23, 8}, {17, 64}} {
+			want := testImage(format, size.X, size.Y)
+			got, ok := loadHDR(t, writePIZ(t, want)).(hdrColors.RawImage)
+			if !ok {
+				t.Fatalf("%s %v: loaded image has no raw values", format.Name, size)
+			}
+			sameImages(t, got, want)
+		}
+	}
+}
+
+func TestZIPRoundTripNonSquare(t *testing.T) {
+	// Random values don't compress, so the blocks are stored as they are and
+	// the loader must tell them apart from compressed ones by their size
+	random := rand.New(rand.NewSource(1))
+	for _, size := range []image.Point{{23, 8}, {8, 23}} {
+		want := testImage(hdrColors.Formats[0], size.X, size.Y)
+		for y := range size.Y {
+			for x := range size.X {
+				want.SetRaw(x, y, [4]float64{random.Float64(), random.Float64(), random.Float64(), random.Float64()})
+			}
+		}
+		var buf bytes.Buffer
+		if err := WriteHDR(&buf, want); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := loadHDR(t, buf.Bytes()).(hdrColors.RawImage)
+		if !ok {
+			t.Fatalf("%v: loaded image has no raw values", size)
+		}
+		sameImages(t, got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package openexr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// PreviewAttribute is the name of the optional attribute holding a small
+// 8-bit version of the image, which file managers and image viewers can show
+// without decoding the pixels.
+const PreviewAttribute = "preview"
+
+// DecodePreview returns the image in the preview attribute of attrs, or nil
+// if there isn't one.
+func DecodePreview(attrs []Attribute) (*image.NRGBA, error) {
+	attr := FindAttribute(attrs, PreviewAttribute)
+	if attr == nil {
+		return nil, nil
+	}
+	if attr.Type != "preview" || len(attr.Data) < 8 {
+		return nil, fmt.Errorf("malformed preview attribute")
+	}
+	width := binary.LittleEndian.Uint32(attr.Data)
+	height := binary.LittleEndian.Uint32(attr.Data[4:])
+	if uint64(len(attr.Data)-8) != 4*uint64(width)*uint64(height) {
+		return nil, fmt.Errorf("preview attribute has %d bytes of pixels for %dx%d", len(attr.Data)-8, width, height)
+	}
+	// Preview pixels are stored like image.NRGBA, as unpremultiplied RGBA rows
+	// from the top
+	img := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	copy(img.Pix, attr.Data[8:])
+	return img, nil
+}
+
+// EncodePreview returns a preview attribute holding img.
+func EncodePreview(img *image.NRGBA) Attribute {
+	bounds := img.Bounds()
+	data := make([]byte, 8, 8+4*bounds.Dx()*bounds.Dy())
+	binary.LittleEndian.PutUint32(data, uint32(bounds.Dx()))
+	binary.LittleEndian.PutUint32(data[4:], uint32(bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		i := img.PixOffset(bounds.Min.X, y)
+		data = append(data, img.Pix[i:i+4*bounds.Dx()]...)
+	}
+	return Attribute{Name: PreviewAttribute, Type: "preview", Size: uint32(len(data)), Data: data}
+}
@@ -0,0 +1,217 @@
+package openexr
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// flagTiled is bit 9 of the version field, set in files storing their pixels
+// in tiles rather than scanlines. It is the second bit of Flags[0].
+const flagTiled = 0x02
+
+// Level modes of a TileDescription.
+const (
+	LevelModeOne    = 0
+	LevelModeMipmap = 1
+	LevelModeRipmap = 2
+)
+
+// TileDescription is the tiles attribute of tiled files: the size of the
+// tiles, and which levels of detail are stored.
+type TileDescription struct {
+	XSize uint32
+	YSize uint32
+	// Mode holds the level mode in its low 4 bits and the rounding mode,
+	// 1 to round level sizes up, in its high 4 bits
+	Mode uint8
+}
+
+// LevelMode returns whether the file has one level, mipmap or ripmap levels.
+func (t TileDescription) LevelMode() uint8 {
+	return t.Mode & 0x0f
+}
+
+func (t TileDescription) roundsUp() bool {
+	return t.Mode>>4 == 1
+}
+
+func (t TileDescription) String() string {
+	mode := fmt.Sprint(t.LevelMode())
+	switch t.LevelMode() {
+	case LevelModeOne:
+		mode = "one level"
+	case LevelModeMipmap:
+		mode = "mipmap levels"
+	case LevelModeRipmap:
+		mode = "ripmap levels"
+	}
+	return fmt.Sprintf("%dx%d, %s", t.XSize, t.YSize, mode)
+}
+
+// levels returns the number of levels of detail of a side n texels long.
+func (t TileDescription) levels(n uint32) int {
+	levels := 1
+	for ; n > 1; levels++ {
+		n = t.levelSize(n, 1)
+	}
+	return levels
+}
+
+// levelSize returns the length of a side n texels long at level l.
+func (t TileDescription) levelSize(n uint32, l int) uint32 {
+	size := n >> l
+	if t.roundsUp() && size<<l < n {
+		size++
+	}
+	return max(size, 1)
+}
+
+// tileCount returns the number of tiles covering a side n texels long with
+// tiles size texels long.
+func tileCount(n, size uint32) uint32 {
+	return (n + size - 1) / size
+}
+
+// chunkCount returns the number of tiles in every level of an image the size
+// of window, which is the length of the offset table.
+func (t TileDescription) chunkCount(window Box2i) (int, error) {
+	if t.XSize == 0 || t.YSize == 0 {
+		return 0, fmt.Errorf("invalid tile size %dx%d", t.XSize, t.YSize)
+	}
+	width, height := window.Width(), window.Height()
+	count := 0
+	switch t.LevelMode() {
+	case LevelModeOne:
+		count = int(tileCount(width, t.XSize) * tileCount(height, t.YSize))
+	case LevelModeMipmap:
+		for l := range t.levels(max(width, height)) {
+			count += int(tileCount(t.levelSize(width, l), t.XSize) * tileCount(t.levelSize(height, l), t.YSize))
+		}
+	case LevelModeRipmap:
+		for ly := range t.levels(height) {
+			for lx := range t.levels(width) {
+				count += int(tileCount(t.levelSize(width, lx), t.XSize) * tileCount(t.levelSize(height, ly), t.YSize))
+			}
+		}
+	default:
+		return 0, fmt.Errorf("unsupported tile level mode %d", t.LevelMode())
+	}
+	return count, nil
+}
+
+// tileHeader precedes each tile's data in a tiled file.
+type tileHeader struct {
+	TileX, TileY   int32
+	LevelX, LevelY int32
+	Size           uint32
+}
+
+// loadTiles reads the tiles of a tiled file and reassembles the full
+// resolution level into uncompressed scanlines, one block per row of tiles.
+// The tiles are found through the offset table, in which the full resolution
+// level comes first, so they can be stored in any order and the lower levels
+// of detail are never read. The header is changed to describe the scanlines
+// instead of the tiles, without an offset table.
+func loadTiles(r *bufio.Reader, header *OpenEXRHeader) (*OpenEXR, error) {
+	tiles := *header.Tiles
+	window := header.DataWindow
+	width, height := window.Width(), window.Height()
+	tilesX, tilesY := tileCount(width, tiles.XSize), tileCount(height, tiles.YSize)
+	if uint64(len(header.OffsetTable)) < uint64(tilesX)*uint64(tilesY) {
+		return nil, fmt.Errorf("offset table has %d tiles, want at least %d", len(header.OffsetTable), tilesX*tilesY)
+	}
+
+	pixelSize := uint32(0)
+	for _, channel := range header.Channels {
+		pixelSize += uint32(channel.PixelFmt.Size())
+	}
+
+	scanlines := make([]ScanLine, tilesY)
+	for i := range scanlines {
+		lines := min(tiles.YSize, height-uint32(i)*tiles.YSize)
+		scanlines[i] = ScanLine{
+			YCoord:    window.YMin + uint32(i)*tiles.YSize,
+			Size:      lines * width * pixelSize,
+			Data:      make([]uint8, lines*width*pixelSize),
+			LineCount: lines,
+		}
+	}
+
+	// The reader only goes forward, so the tiles are read in file order
+	order := make([]int, tilesX*tilesY)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(header.OffsetTable[a], header.OffsetTable[b])
+	})
+	pos := header.chunksStart
+	for _, i := range order {
+		tileX, tileY := int32(uint32(i)%tilesX), int32(uint32(i)/tilesX)
+		offset := header.OffsetTable[i]
+		if offset < pos {
+			return nil, fmt.Errorf("tile (%d, %d) has invalid offset %d", tileX, tileY, offset)
+		}
+		if _, err := r.Discard(int(offset - pos)); err != nil {
+			return nil, err
+		}
+		var chunk tileHeader
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return nil, err
+		}
+		if chunk.TileX != tileX || chunk.TileY != tileY || chunk.LevelX != 0 || chunk.LevelY != 0 {
+			return nil, fmt.Errorf("offset table entry for tile (%d, %d) points to tile (%d, %d) of level (%d, %d)", tileX, tileY, chunk.TileX, chunk.TileY, chunk.LevelX, chunk.LevelY)
+		}
+		data := make([]uint8, chunk.Size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		pos = offset + uint64(binary.Size(chunk)) + uint64(chunk.Size)
+
+		scanline := &scanlines[chunk.TileY]
+		x0 := uint32(chunk.TileX) * tiles.XSize
+		tileWidth, lines := min(tiles.XSize, width-x0), scanline.LineCount
+		tile := ScanLine{
+			Size:       chunk.Size,
+			Data:       data,
+			Compressed: tileWidth*lines*pixelSize > chunk.Size,
+			LineCount:  lines,
+		}
+		// A tile is compressed like a block of scanlines as wide as the tile
+		tileImage := *header
+		tileImage.DataWindow = Box2i{XMax: tileWidth - 1, YMax: lines - 1}
+		if err := tile.Decompress(&tileImage); err != nil {
+			return nil, err
+		}
+		if uint32(len(tile.Data)) < tileWidth*lines*pixelSize {
+			return nil, fmt.Errorf("tile (%d, %d) is truncated", chunk.TileX, chunk.TileY)
+		}
+
+		// Both hold each line's channels one after the other, each channel
+		// as wide as the tile or the image
+		src := uint32(0)
+		for line := range lines {
+			channelOffset := line * width * pixelSize
+			for _, channel := range header.Channels {
+				size := uint32(channel.PixelFmt.Size())
+				dst := channelOffset + x0*size
+				copy(scanline.Data[dst:dst+tileWidth*size], tile.Data[src:src+tileWidth*size])
+				src += tileWidth * size
+				channelOffset += width * size
+			}
+		}
+	}
+
+	header.Flags[0] &^= flagTiled
+	header.Tiles = nil
+	header.LineOrder = OrderIncreasingY
+	header.OffsetTable = nil
+	return &OpenEXR{
+		OpenEXRHeader: *header,
+		ScanLines:     scanlines,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package openexr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// tileChunk is one chunk of a tiled file written by writeTiled.
+type tileChunk struct {
+	tileHeader
+	data []byte
+}
+
+// writeTiled writes img to an uncompressed tiled file with tiles. The full
+// resolution level holds img, and any lower levels hold chunks that can't be
+// read, claiming more data than they have. The chunks are stored in the order
+// arrange leaves them in, gap bytes apart, while the offset table lists them
+// by level, row and column like the reference implementation.
+func writeTiled(t *testing.T, img hdrColors.RawImage, tiles TileDescription, gap int, arrange func([]tileChunk)) []byte {
+	t.Helper()
+	src, err := newPixelSource(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := headerFromHDRImage(src)
+	header.Compression = CompressionNone
+	var desc bytes.Buffer
+	binary.Write(&desc, binary.LittleEndian, tiles)
+	header.Attributes = []Attribute{{Name: "tiles", Type: "tiledesc", Size: uint32(desc.Len()), Data: desc.Bytes()}}
+	width, height := header.DataWindow.Width(), header.DataWindow.Height()
+	size := uint32(src.pixelFmt.Size())
+
+	var chunks []tileChunk
+	for ty := range tileCount(height, tiles.YSize) {
+		for tx := range tileCount(width, tiles.XSize) {
+			x0, y0 := tx*tiles.XSize, ty*tiles.YSize
+			tileWidth := min(tiles.XSize, width-x0)
+			var data []byte
+			for y := y0; y < min(y0+tiles.YSize, height); y++ {
+				line := header.scanlineBlock(src, y).Data
+				for c := range header.Channels {
+					start := (uint32(c)*width + x0) * size
+					data = append(data, line[start:start+tileWidth*size]...)
+				}
+			}
+			chunks = append(chunks, tileChunk{tileHeader{TileX: int32(tx), TileY: int32(ty), Size: uint32(len(data))}, data})
+		}
+	}
+	if tiles.LevelMode() == LevelModeMipmap {
+		for l := 1; l < tiles.levels(max(width, height)); l++ {
+			levelWidth, levelHeight := tiles.levelSize(width, l), tiles.levelSize(height, l)
+			for ty := range tileCount(levelHeight, tiles.YSize) {
+				for tx := range tileCount(levelWidth, tiles.XSize) {
+					junk := bytes.Repeat([]byte{0xff}, 7)
+					chunks = append(chunks, tileChunk{tileHeader{TileX: int32(tx), TileY: int32(ty), LevelX: int32(l), LevelY: int32(l), Size: 1 << 24}, junk})
+				}
+			}
+		}
+	}
+	count, err := tiles.chunkCount(header.DataWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != len(chunks) {
+		t.Fatalf("wrote %d chunks, want %d", len(chunks), count)
+	}
+
+	var buf bytes.Buffer
+	exr := &OpenEXR{OpenEXRHeader: *header}
+	if _, err := exr.dumpHeader(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.Bytes()[5] |= flagTiled
+	table := buf.Len()
+	buf.Write(make([]byte, 8*len(chunks)))
+	stored := slices.Clone(chunks)
+	arrange(stored)
+	for _, chunk := range stored {
+		buf.Write(make([]byte, gap))
+		i := slices.IndexFunc(chunks, func(c tileChunk) bool { return c.tileHeader == chunk.tileHeader })
+		binary.LittleEndian.PutUint64(buf.Bytes()[table+8*i:], uint64(buf.Len()))
+		binary.Write(&buf, binary.LittleEndian, chunk.tileHeader)
+		buf.Write(chunk.data)
+	}
+	return buf.Bytes()
+}
+
+func TestTiledOneLevel(t *testing.T) {
+	// Tiles that don't divide the image, stored last to first
+	want := testImage(hdrColors.Formats[0], 7, 5)
+	data := writeTiled(t, want, TileDescription{XSize: 4, YSize: 2, Mode: LevelModeOne}, 0, slices.Reverse)
+	sameImages(t, loadHDR(t, data).(hdrColors.RawImage), want)
+}
+
+func TestTiledMipmapSkipsLevels(t *testing.T) {
+	// The lower levels, which can't be read, are stored first and between
+	// the tiles of the full resolution level, with padding between chunks
+	want := testImage(hdrColors.Formats[0], 7, 5)
+	tiles := TileDescription{XSize: 4, YSize: 4, Mode: LevelModeMipmap}
+	data := writeTiled(t, want, tiles, 3, func(chunks []tileChunk) {
+		slices.Reverse(chunks)
+		chunks[0], chunks[len(chunks)-2] = chunks[len(chunks)-2], chunks[0]
+	})
+	sameImages(t, loadHDR(t, data).(hdrColors.RawImage), want)
+}
@@ -0,0 +1,192 @@
+// Package precision converts images between float and half-float pixels and
+// measures what the conversion lost, so a LUT can be checked after changing
+// formats.
+package precision
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Format is the pixel type images are converted to before saving.
+type Format int
+
+const (
+	// Keep leaves images in the pixel type they already have
+	Keep Format = iota
+	// Float stores 32-bit float channels
+	Float
+	// Half stores 16-bit float channels
+	Half
+)
+
+var Formats = []Format{Keep, Float, Half}
+
+func (f Format) String() string {
+	switch f {
+	case Keep:
+		return "Keep"
+	case Float:
+		return "Float (32-bit)"
+	case Half:
+		return "Half (16-bit)"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// DefaultThreshold is the absolute error above which a converted value is
+// listed in a Report. Half floats keep values below 1 well within it, but
+// start to exceed it from about 2 upwards.
+const DefaultThreshold = 0.001
+
+// FormatOf returns the format img is stored in, and false for images that
+// aren't float or half.
+func FormatOf(img image.Image) (Format, bool) {
+	switch img.ColorModel() {
+	case hdrColors.NRGBA128FModel:
+		return Float, true
+	case hdrColors.NRGBA64FModel:
+		return Half, true
+	}
+	return Keep, false
+}
+
+// Convert returns a copy of img stored in format f. It returns img itself, and
+// false, if f is Keep or img is already stored that way.
+func Convert(img image.Image, f Format) (image.Image, bool, error) {
+	if current, ok := FormatOf(img); f == Keep || ok && current == f {
+		return img, false, nil
+	}
+	src, ok := raw(img)
+	if !ok {
+		return nil, false, fmt.Errorf("image does not have an HDR color model")
+	}
+	rect := image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+	var dst hdrColors.RawImage
+	switch f {
+	case Float:
+		dst = hdrColors.NewNRGBA128FImage(rect)
+	case Half:
+		dst = hdrColors.NewNRGBA64FImage(rect)
+	default:
+		return nil, false, fmt.Errorf("unknown format %v", f)
+	}
+	bounds := src.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dst.SetRaw(x-bounds.Min.X, y-bounds.Min.Y, src.RawAt(x, y))
+		}
+	}
+	return dst, true, nil
+}
+
+func raw(img image.Image) (hdrColors.RawImage, bool) {
+	if ddsImg, ok := img.(*dds.DDS); ok {
+		img = ddsImg.Image
+	}
+	r, ok := img.(hdrColors.RawImage)
+	return r, ok
+}
+
+// Loss is a channel value that changed by more than the report's threshold,
+// or clipped.
+type Loss struct {
+	X, Y          int
+	Channel       int
+	Before, After float64
+	// Clipped is set when a finite value became infinite or NaN, because it
+	// is out of the range the new format can hold
+	Clipped bool
+}
+
+func (l Loss) Error() float64 {
+	return math.Abs(l.After - l.Before)
+}
+
+// Report sums up the error a conversion introduced.
+type Report struct {
+	// Values is the number of channel values compared. NaN and infinite
+	// values have nothing to lose, so they aren't
+	Values    int
+	MaxError  float64
+	MeanError float64
+	// Clipped counts the values that clipped, which are left out of MaxError
+	// and MeanError
+	Clipped   int
+	Threshold float64
+	// Losses lists the values that clipped or changed by more than
+	// Threshold, in row order
+	Losses []Loss
+}
+
+// Measure compares img with the result of converting it, texel by texel.
+// Both must have the same size.
+func Measure(before, after image.Image, threshold float64) (Report, error) {
+	rawBefore, ok := raw(before)
+	if !ok {
+		return Report{}, fmt.Errorf("image does not have an HDR color model")
+	}
+	rawAfter, ok := raw(after)
+	if !ok {
+		return Report{}, fmt.Errorf("converted image does not have an HDR color model")
+	}
+	if rawBefore.Bounds().Size() != rawAfter.Bounds().Size() {
+		return Report{}, fmt.Errorf("converted image is %dx%d but image is %dx%d", rawAfter.Bounds().Dx(), rawAfter.Bounds().Dy(), rawBefore.Bounds().Dx(), rawBefore.Bounds().Dy())
+	}
+
+	report := Report{Threshold: threshold}
+	var total float64
+	bounds := rawBefore.Bounds()
+	offset := rawAfter.Bounds().Min.Sub(bounds.Min)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			b, a := rawBefore.RawAt(x, y), rawAfter.RawAt(x+offset.X, y+offset.Y)
+			for i := range b {
+				if !finite(b[i]) {
+					continue
+				}
+				report.Values++
+				loss := Loss{X: x - bounds.Min.X, Y: y - bounds.Min.Y, Channel: i, Before: b[i], After: a[i]}
+				if !finite(a[i]) {
+					loss.Clipped = true
+					report.Clipped++
+					report.Losses = append(report.Losses, loss)
+					continue
+				}
+				err := loss.Error()
+				total += err
+				report.MaxError = max(report.MaxError, err)
+				if err > threshold {
+					report.Losses = append(report.Losses, loss)
+				}
+			}
+		}
+	}
+	if measured := report.Values - report.Clipped; measured > 0 {
+		report.MeanError = total / float64(measured)
+	}
+	return report, nil
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// Lossless reports whether every value survived the conversion exactly.
+func (r Report) Lossless() bool {
+	return r.MaxError == 0 && r.Clipped == 0
+}
+
+// Summary describes the report in a line, e.g. "max error 0.00049, mean
+// 1.2e-05, 3 values clipped, 2 over 0.001".
+func (r Report) Summary() string {
+	if r.Lossless() {
+		return "no precision lost"
+	}
+	return fmt.Sprintf("max error %.3g, mean %.3g, %d values clipped, %d over %g",
+		r.MaxError, r.MeanError, r.Clipped, len(r.Losses)-r.Clipped, r.Threshold)
+}
@@ -0,0 +1,80 @@
+package prefs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ColorsFileName is the name of the file in the user config folder keeping
+// the colors recently used on each image.
+const ColorsFileName = "colors.json"
+
+// MaxRecentColors is how many colors are kept for each image.
+const MaxRecentColors = 16
+
+// RecentColors holds the colors recently used on each image, newest first, by
+// the image's absolute path.
+type RecentColors map[string][][4]float32
+
+// ColorsPath is where the recently used colors are kept.
+func ColorsPath() (string, error) {
+	path, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), ColorsFileName), nil
+}
+
+// LoadRecentColors reads the recently used colors, which are empty if they
+// were never saved.
+func LoadRecentColors() (RecentColors, error) {
+	colors := make(RecentColors)
+	path, err := ColorsPath()
+	if err != nil {
+		return colors, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return colors, nil
+	} else if err != nil {
+		return colors, err
+	}
+	if err := json.Unmarshal(data, &colors); err != nil {
+		return make(RecentColors), fmt.Errorf("invalid recent colors: %v", err)
+	}
+	return colors, nil
+}
+
+// SaveRecentColors writes the recently used colors, creating their folder if
+// needed.
+func SaveRecentColors(colors RecentColors) error {
+	path, err := ColorsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(colors, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// AddRecentColor returns colors with c moved to the front, or added there if
+// it is new, keeping at most MaxRecentColors.
+func AddRecentColor(colors [][4]float32, c [4]float32) [][4]float32 {
+	recent := make([][4]float32, 0, min(len(colors)+1, MaxRecentColors))
+	recent = append(recent, c)
+	for _, color := range colors {
+		if color != c && len(recent) < MaxRecentColors {
+			recent = append(recent, color)
+		}
+	}
+	return recent
+}
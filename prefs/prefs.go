@@ -0,0 +1,134 @@
+// Package prefs stores the editor's preferences in the user config folder.
+package prefs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ryanjsims/hd2-lut-editor/help"
+)
+
+// FileName is the name of the preferences file in the user config folder.
+const FileName = "preferences.json"
+
+// Undo says how quick runs of edits are merged into undo steps.
+type Undo struct {
+	// Delay is how long, in seconds, an edit waits for another of the same
+	// kind before it becomes an undo step
+	Delay float32 `json:"delay"`
+	// Draw, Color and Selection say whether drawing or erasing, picking or
+	// editing the color, and changing, moving or nudging the selection are
+	// merged while they follow each other within Delay. If not, each stroke,
+	// color edit or nudge is its own undo step.
+	Draw      bool `json:"draw"`
+	Color     bool `json:"color"`
+	Selection bool `json:"selection"`
+	// Checkpoint is how often, in undo steps, the whole image is kept rather
+	// than only the parts of it that changed
+	Checkpoint int32 `json:"checkpoint"`
+}
+
+// Hook is a command run after each save, such as a script repacking the mod
+// or copying the file into the game folder.
+type Hook struct {
+	Enabled bool `json:"enabled"`
+	// Command is run by the system shell, with the tokens {path}, {dir} and
+	// {name} replaced by the quoted path, folder and name of the saved file
+	Command string `json:"command"`
+}
+
+// Pipeline is a named set of files written from the open image by one
+// command, such as an EXR and a DDS copy of it in a mod folder.
+type Pipeline struct {
+	Name  string         `json:"name"`
+	Steps []PipelineStep `json:"steps"`
+}
+
+// PipelineStep writes one file of a pipeline, or runs a command once the
+// files before it are written.
+type PipelineStep struct {
+	// Path is where the file is written, relative to the image's folder, with
+	// {name} replaced by the image's file name without its extension. Its
+	// extension, .exr, .dds or .png, picks the format.
+	Path string `json:"path,omitempty"`
+	// Precision is "float" or "half" to convert the file's pixels to, or
+	// empty to keep the image's
+	Precision string `json:"precision,omitempty"`
+	// MipMaps is "box" or "kaiser" to write a DDS file with a full mip chain
+	// made with that filter, or empty for none
+	MipMaps string `json:"mipmaps,omitempty"`
+	// Command is run by the system shell in the image's folder instead of
+	// writing a file. It takes the tokens of Hook.Command for the image, and
+	// {out}, the quoted path of the last file written.
+	Command string `json:"command,omitempty"`
+}
+
+// Preferences holds every preference.
+type Preferences struct {
+	Undo      Undo       `json:"undo"`
+	SaveHook  Hook       `json:"saveHook"`
+	Pipelines []Pipeline `json:"pipelines,omitempty"`
+	// Inbox is the folder watched for new files to open, if any
+	Inbox string `json:"inbox,omitempty"`
+	// Provenance records which edit last changed each texel of open images
+	Provenance bool `json:"provenance,omitempty"`
+	// SchemaURL is where Help > Update Schemas downloads the schema pack from
+	SchemaURL string `json:"schemaURL,omitempty"`
+}
+
+// Default returns the preferences used until others are saved.
+func Default() Preferences {
+	return Preferences{
+		Undo:      Undo{Delay: 1, Draw: true, Color: true, Selection: true, Checkpoint: 16},
+		SchemaURL: help.PackURL,
+	}
+}
+
+// Path is where the preferences are kept.
+func Path() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "hd2-lut-editor", FileName), nil
+}
+
+// Load reads the saved preferences. Preferences missing from the file, or
+// all of them if it doesn't exist, are the defaults.
+func Load() (Preferences, error) {
+	p := Default()
+	path, err := Path()
+	if err != nil {
+		return p, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	} else if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Default(), fmt.Errorf("invalid preferences: %v", err)
+	}
+	return p, nil
+}
+
+// Save writes the preferences, creating their folder if needed.
+func Save(p Preferences) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
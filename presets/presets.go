@@ -0,0 +1,135 @@
+// Package presets stores named material parameter sets: the values of every
+// column of one LUT row, such as "Brushed steel" or "Worn leather", so they
+// can be written into any row of a LUT with the same layout.
+package presets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/blend"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// FileName is the name of the user's preset library in the config folder.
+const FileName = "presets.json"
+
+// Version is the newest preset file format this package understands.
+const Version = 1
+
+type Preset struct {
+	Name string `json:"name"`
+	// Schema is the schema of the LUT the preset was captured from, if any
+	Schema string `json:"schema,omitempty"`
+	// Values holds the R, G, B and A values of each column, from the left
+	Values [][4]float64 `json:"values"`
+}
+
+// File is the contents of the preset library, or of a preset pack shared
+// between users.
+type File struct {
+	Version int      `json:"version"`
+	Presets []Preset `json:"presets"`
+}
+
+// LibraryPath is where the user's preset library is kept.
+func LibraryPath() (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "hd2-lut-editor", FileName), nil
+}
+
+// Load reads the presets in the library or pack at path.
+func Load(path string) ([]Preset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid preset file: %v", err)
+	}
+	if f.Version > Version {
+		return nil, fmt.Errorf("preset file version %d is newer than this editor supports (%d)", f.Version, Version)
+	}
+	for i, preset := range f.Presets {
+		if strings.TrimSpace(preset.Name) == "" {
+			return nil, fmt.Errorf("preset %d has no name", i)
+		}
+	}
+	return f.Presets, nil
+}
+
+// Save writes presets to path, creating its folder if needed.
+func Save(path string, presets []Preset) error {
+	data, err := json.MarshalIndent(File{Version: Version, Presets: presets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Capture reads row y of img as a preset.
+func Capture(name, schema string, img hdrColors.RawImage, y int) Preset {
+	bounds := img.Bounds()
+	preset := Preset{Name: name, Schema: schema}
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		preset.Values = append(preset.Values, img.RawAt(x, y))
+	}
+	return preset
+}
+
+// Apply writes the preset's values into row y of img, leaving locked
+// channels alone, and returns how many texels changed. Columns past the end
+// of the shorter of the two are left out.
+func Apply(img hdrColors.RawImage, y int, preset Preset, lock blend.Lock) int {
+	bounds := img.Bounds()
+	changed := 0
+	for i, value := range preset.Values {
+		x := bounds.Min.X + i
+		if x >= bounds.Max.X {
+			break
+		}
+		before := img.RawAt(x, y)
+		img.SetRaw(x, y, lock.Pixel(blend.Replace, before, value))
+		if img.RawAt(x, y) != before {
+			changed++
+		}
+	}
+	return changed
+}
+
+// Merge adds imported to presets. Imported presets replace existing ones
+// with the same name, keeping their place in the list.
+func Merge(presets, imported []Preset) (merged []Preset, added, replaced int) {
+	merged = append(merged, presets...)
+	for _, preset := range imported {
+		i := Find(merged, preset.Name)
+		if i >= 0 {
+			merged[i] = preset
+			replaced++
+		} else {
+			merged = append(merged, preset)
+			added++
+		}
+	}
+	return
+}
+
+// Find returns the index of the preset called name, ignoring case, or -1.
+func Find(presets []Preset, name string) int {
+	for i, preset := range presets {
+		if strings.EqualFold(preset.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,100 @@
+package presets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/help"
+)
+
+// RowExtension is the extension of shared row files.
+const RowExtension = ".lutrow"
+
+// RowVersion is the newest shared row format this package understands.
+const RowVersion = 1
+
+// SharedRow is a single LUT row in a file of its own, for modders to exchange
+// material definitions without sending whole textures. Column and channel
+// names come from the schema the row was exported with, so the file can be
+// read without the editor.
+type SharedRow struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	// Schema is the name of the schema describing the row, if any, and
+	// SchemaVersion the schema file format it was described in
+	Schema        string         `json:"schema,omitempty"`
+	SchemaVersion int            `json:"schemaVersion,omitempty"`
+	Columns       []SharedColumn `json:"columns"`
+}
+
+type SharedColumn struct {
+	Name string `json:"name,omitempty"`
+	// Channels names the R, G, B and A values, where the schema does
+	Channels []string   `json:"channels,omitempty"`
+	Values   [4]float64 `json:"values"`
+}
+
+// NewSharedRow describes preset's values with the names schema gives them.
+// schema may be nil.
+func NewSharedRow(preset Preset, schema *help.Schema) SharedRow {
+	row := SharedRow{Version: RowVersion, Name: preset.Name, Schema: preset.Schema}
+	if schema != nil {
+		row.Schema, row.SchemaVersion = schema.Name, help.Version
+	}
+	for x, values := range preset.Values {
+		column := SharedColumn{Values: values}
+		if schema != nil {
+			if c := schema.Column(x); c != nil {
+				column.Name = c.Name
+				for _, channel := range c.Channels {
+					column.Channels = append(column.Channels, channel.Name)
+				}
+			}
+		}
+		row.Columns = append(row.Columns, column)
+	}
+	return row
+}
+
+// Preset returns the row's values as a preset.
+func (r SharedRow) Preset() Preset {
+	preset := Preset{Name: r.Name, Schema: r.Schema}
+	for _, column := range r.Columns {
+		preset.Values = append(preset.Values, column.Values)
+	}
+	return preset
+}
+
+func WriteRow(path string, row SharedRow) error {
+	data, err := json.MarshalIndent(row, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func ReadRow(path string) (SharedRow, error) {
+	var row SharedRow
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return row, err
+	}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return row, fmt.Errorf("invalid row file: %v", err)
+	}
+	if row.Version > RowVersion {
+		return row, fmt.Errorf("row file version %d is newer than this editor supports (%d)", row.Version, RowVersion)
+	}
+	if row.SchemaVersion > help.Version {
+		return row, fmt.Errorf("row was described with schema version %d, newer than this editor supports (%d)", row.SchemaVersion, help.Version)
+	}
+	if strings.TrimSpace(row.Name) == "" {
+		return row, fmt.Errorf("row has no name")
+	}
+	if len(row.Columns) == 0 {
+		return row, fmt.Errorf("row has no columns")
+	}
+	return row, nil
+}
@@ -0,0 +1,95 @@
+package preview
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"time"
+)
+
+// ErrSizeMismatch is returned when the before and after images of a
+// comparison have different sizes.
+var ErrSizeMismatch = errors.New("before and after images are different sizes")
+
+// StripGap is the width in pixels of the gap between the images of a strip.
+const StripGap = 8
+
+// Strip renders before and after with opts and places them side by side,
+// before on the left.
+func Strip(before, after image.Image, opts Options) (*image.NRGBA, error) {
+	if before.Bounds().Size() != after.Bounds().Size() {
+		return nil, ErrSizeMismatch
+	}
+	left, right := Render(before, opts), Render(after, opts)
+	width, height := left.Bounds().Dx(), left.Bounds().Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, 2*width+StripGap, height))
+	draw.Draw(out, left.Bounds(), left, image.Point{}, draw.Src)
+	draw.Draw(out, right.Bounds().Add(image.Pt(width+StripGap, 0)), right, image.Point{}, draw.Src)
+	return out, nil
+}
+
+// WriteStripPNG encodes the Strip of before and after as a PNG.
+func WriteStripPNG(w io.Writer, before, after image.Image, opts Options) error {
+	strip, err := Strip(before, after, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, strip)
+}
+
+// WriteGIF encodes an animated GIF that flashes between before and after,
+// rendered with opts, showing each for delay. GIFs are paletted: if both
+// renderings use 256 colors or fewer they are kept exactly, otherwise each
+// pixel takes the nearest Plan 9 palette color. Neither is dithered, so
+// neighbouring texels never pick up each other's colors. Alpha is dropped, as
+// GIFs only have on/off transparency.
+func WriteGIF(w io.Writer, before, after image.Image, opts Options, delay time.Duration) error {
+	if before.Bounds().Size() != after.Bounds().Size() {
+		return ErrSizeMismatch
+	}
+	frames := []*image.NRGBA{Render(before, opts), Render(after, opts)}
+	for _, frame := range frames {
+		for i := 3; i < len(frame.Pix); i += 4 {
+			frame.Pix[i] = 0xff
+		}
+	}
+	pal := exactPalette(frames, 256)
+	if pal == nil {
+		pal = palette.Plan9
+	}
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		paletted := image.NewPaletted(frame.Bounds(), pal)
+		draw.Draw(paletted, paletted.Bounds(), frame, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, paletted)
+		// GIF delays are in hundredths of a second
+		anim.Delay = append(anim.Delay, max(int(delay/(10*time.Millisecond)), 1))
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// exactPalette returns every color used in frames, or nil if there are more
+// than limit.
+func exactPalette(frames []*image.NRGBA, limit int) color.Palette {
+	seen := make(map[color.NRGBA]bool)
+	var pal color.Palette
+	for _, frame := range frames {
+		for i := 0; i < len(frame.Pix); i += 4 {
+			c := color.NRGBA{R: frame.Pix[i], G: frame.Pix[i+1], B: frame.Pix[i+2], A: frame.Pix[i+3]}
+			if seen[c] {
+				continue
+			}
+			if len(pal) == limit {
+				return nil
+			}
+			seen[c] = true
+			pal = append(pal, c)
+		}
+	}
+	return pal
+}
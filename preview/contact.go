@@ -0,0 +1,110 @@
+package preview
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// SheetEntry is one image on a contact sheet, made by SheetOptions.Thumbnail.
+// Entries without a thumbnail, such as files that failed to load, get an
+// empty cell with their name.
+type SheetEntry struct {
+	Name      string
+	Thumbnail *image.NRGBA
+}
+
+type SheetOptions struct {
+	// Columns is the number of images in each row of the sheet
+	Columns int
+	// CellSize is the width and height each image is fitted into. Small
+	// images are scaled up by whole pixels
+	CellSize int
+	ToneMap  ToneMap
+}
+
+func DefaultSheetOptions() SheetOptions {
+	return SheetOptions{
+		Columns:  6,
+		CellSize: 128,
+		ToneMap:  ToneMapReinhard,
+	}
+}
+
+const sheetPadding = 8
+
+// Thumbnail tone maps img to fit a cell of the sheet. Keeping only thumbnails
+// saves holding every image of a large folder in memory.
+func (opts SheetOptions) Thumbnail(img image.Image) *image.NRGBA {
+	cell := max(opts.CellSize, 1)
+	thumb := Thumbnail(img, cell, opts.ToneMap)
+	size := thumb.Bounds().Size()
+	if scale := cell / max(size.X, size.Y); scale > 1 {
+		thumb = Render(thumb, Options{Scale: int32(scale)})
+	}
+	return thumb
+}
+
+var (
+	sheetBackground = color.NRGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+	sheetCell       = color.NRGBA{R: 0x30, G: 0x30, B: 0x30, A: 0xff}
+	sheetLabel      = color.NRGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+)
+
+// ContactSheet renders a grid of thumbnails of the entries, each labeled with
+// its name, for cataloguing a folder of textures at a glance.
+func ContactSheet(entries []SheetEntry, opts SheetOptions) *image.NRGBA {
+	columns := max(min(opts.Columns, len(entries)), 1)
+	rows := max((len(entries)+columns-1)/columns, 1)
+	cell := max(opts.CellSize, 1)
+	labelHeight := basicfont.Face7x13.Height + 4
+	pitch := image.Pt(cell+sheetPadding, cell+labelHeight+sheetPadding)
+	out := image.NewNRGBA(image.Rect(0, 0, sheetPadding+columns*pitch.X, sheetPadding+rows*pitch.Y))
+	draw.Draw(out, out.Bounds(), image.NewUniform(sheetBackground), image.Point{}, draw.Src)
+
+	for i, entry := range entries {
+		origin := image.Pt(sheetPadding+i%columns*pitch.X, sheetPadding+i/columns*pitch.Y)
+		cellRect := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(cell, cell))}
+		draw.Draw(out, cellRect, image.NewUniform(sheetCell), image.Point{}, draw.Src)
+		if thumb := entry.Thumbnail; thumb != nil {
+			// Centered in the cell
+			size := thumb.Bounds().Size()
+			at := origin.Add(image.Pt((cell-size.X)/2, (cell-size.Y)/2))
+			draw.Draw(out, image.Rectangle{Min: at, Max: at.Add(size)}, thumb, thumb.Bounds().Min, draw.Src)
+		}
+		drawLabel(out, entry.Name, image.Pt(origin.X, origin.Y+cell+2), cell)
+	}
+	return out
+}
+
+// drawLabel writes text with its top left at pt, shortened with an ellipsis
+// to fit in width.
+func drawLabel(dst draw.Image, text string, pt image.Point, width int) {
+	face := basicfont.Face7x13
+	if font.MeasureString(face, text).Ceil() > width {
+		runes := []rune(text)
+		for len(runes) > 1 && font.MeasureString(face, string(runes)+"...").Ceil() > width {
+			runes = runes[:len(runes)-1]
+		}
+		text = string(runes) + "..."
+	}
+	drawer := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(sheetLabel),
+		Face: face,
+		Dot:  fixed.P(pt.X, pt.Y+face.Ascent),
+	}
+	drawer.DrawString(text)
+}
+
+// WriteContactSheetPNG renders a contact sheet of the entries and encodes it
+// as a PNG.
+func WriteContactSheetPNG(w io.Writer, entries []SheetEntry, opts SheetOptions) error {
+	return png.Encode(w, ContactSheet(entries, opts))
+}
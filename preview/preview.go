@@ -0,0 +1,304 @@
+// Package preview renders HDR images to 8-bit images for display outside
+// the editor, e.g. screenshots and PNG exports for tutorials.
+package preview
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+type ToneMap int
+
+const (
+	// ToneMapClamp clips values to [0, 1], matching the editor viewport
+	ToneMapClamp ToneMap = 0
+	// ToneMapReinhard compresses values above 1 instead of clipping them
+	ToneMapReinhard ToneMap = 1
+)
+
+// Names labels the rows and columns of an image.
+type Names interface {
+	Row(y int) string
+	Column(x int) string
+}
+
+type Options struct {
+	// Scale is the width and height in output pixels of each image pixel
+	Scale int32
+	// Exposure brightens (positive) or darkens (negative) the image, in stops
+	Exposure float32
+	ToneMap  ToneMap
+	// Grid draws a line between each pixel, like the viewport grid
+	Grid      bool
+	GridColor color.NRGBA
+	// Bounds limits the rendered area of the image. Empty renders everything
+	Bounds image.Rectangle
+	// Selection is outlined when it is not empty
+	Selection      image.Rectangle
+	SelectionColor color.NRGBA
+	// Labels writes the name of each row to the left of the image and of
+	// each column below it, leaving out names that don't fit the scale
+	Labels bool
+	Names  Names
+	// Diff highlights the Changed texels, given in image coordinates, like
+	// the editor does when diffing against git
+	Diff         bool
+	Changed      []image.Point
+	ChangedColor color.NRGBA
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Scale:     16,
+		Exposure:  0,
+		ToneMap:   ToneMapClamp,
+		Grid:      true,
+		GridColor: color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+
+		SelectionColor: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		ChangedColor:   color.NRGBA{R: 0xe6, G: 0xb3, B: 0x1a, A: 0xff},
+	}
+}
+
+// Render tone maps img and scales it up by opts.Scale. Pixels are read through
+// img.At, so the channel currently viewed in the editor is what gets rendered.
+// UInt images are scaled by the values they hold, see scaledUInt.
+func Render(img image.Image, opts Options) *image.NRGBA {
+	scale := max(int(opts.Scale), 1)
+	bounds := img.Bounds()
+	if !opts.Bounds.Empty() {
+		bounds = bounds.Intersect(opts.Bounds)
+	}
+	img = scaledUInt(img, bounds)
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()*scale, bounds.Dy()*scale))
+	exposure := float32(math.Exp2(float64(opts.Exposure)))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := ToneMapColor(img.At(x, y), exposure, opts.ToneMap)
+			x0, y0 := (x-bounds.Min.X)*scale, (y-bounds.Min.Y)*scale
+			for py := y0; py < y0+scale; py++ {
+				for px := x0; px < x0+scale; px++ {
+					out.SetNRGBA(px, py, c)
+				}
+			}
+		}
+	}
+
+	if opts.Grid && scale > 2 {
+		drawGrid(out, scale, opts.GridColor)
+	}
+	if opts.Diff {
+		for _, p := range opts.Changed {
+			if !p.In(bounds) {
+				continue
+			}
+			p = p.Sub(bounds.Min).Mul(scale)
+			texel := image.Rectangle{Min: p, Max: p.Add(image.Pt(scale, scale))}
+			drawHighlight(out, texel, max(scale/8, 1), opts.ChangedColor)
+		}
+	}
+	if selection := opts.Selection.Intersect(bounds); !selection.Empty() {
+		selection = selection.Sub(bounds.Min)
+		selection.Min = selection.Min.Mul(scale)
+		selection.Max = selection.Max.Mul(scale)
+		drawOutline(out, selection, max(scale/8, 1), opts.SelectionColor)
+	}
+	if opts.Labels && opts.Names != nil {
+		out = drawLabels(out, bounds, scale, opts.Names)
+	}
+	return out
+}
+
+// WritePNG renders img with the given options and encodes it as a PNG.
+func WritePNG(w io.Writer, img image.Image, opts Options) error {
+	return png.Encode(w, Render(img, opts))
+}
+
+// Thumbnail tone maps img into at most size by size pixels, keeping its
+// aspect ratio. Images smaller than that keep their size. Each thumbnail pixel
+// is the image pixel under its center, and is opaque, since LUTs often keep
+// data rather than coverage in alpha.
+func Thumbnail(img image.Image, size int, toneMap ToneMap) *image.NRGBA {
+	bounds := img.Bounds()
+	img = scaledUInt(img, bounds)
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > size || h > size {
+		scale := float64(size) / float64(max(w, h))
+		w, h = max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1)
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			srcX := bounds.Min.X + (2*x+1)*bounds.Dx()/(2*w)
+			srcY := bounds.Min.Y + (2*y+1)*bounds.Dy()/(2*h)
+			c := ToneMapColor(img.At(srcX, srcY), 1, toneMap)
+			c.A = 0xff
+			out.SetNRGBA(x, y, c)
+		}
+	}
+	return out
+}
+
+// scaledUInt returns the part of img within bounds with its values scaled to
+// [0, 1] from the smallest to the largest it holds there, if img is a UInt
+// image. UInt LUTs store IDs and counts, which would all be close to black as
+// fractions of the largest integer. The color channels share a range, and
+// alpha has its own. A channel holding one value is 1 unless that is 0. Other
+// images are returned as they are.
+func scaledUInt(img image.Image, bounds image.Rectangle) image.Image {
+	if img.ColorModel() != hdrColors.NRGBA128UModel {
+		return img
+	}
+	// NRGBA128UModel converts through 16 bit RGBA, so the values are read as
+	// they are rather than converted
+	at := func(x, y int) hdrColors.NRGBA128U {
+		c, _ := img.At(x, y).(hdrColors.NRGBA128U)
+		return c
+	}
+	lo, hi := [2]uint32{math.MaxUint32, math.MaxUint32}, [2]uint32{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := at(x, y)
+			lo[0], hi[0] = min(lo[0], c.R, c.G, c.B), max(hi[0], c.R, c.G, c.B)
+			lo[1], hi[1] = min(lo[1], c.A), max(hi[1], c.A)
+		}
+	}
+	scale := func(v uint32, i int) float32 {
+		if lo[i] == hi[i] {
+			return float32(min(v, 1))
+		}
+		return float32(float64(v-lo[i]) / float64(hi[i]-lo[i]))
+	}
+	out := hdrColors.NewNRGBA128FImage(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := at(x, y)
+			out.Set(x, y, hdrColors.NRGBA128F{R: scale(c.R, 0), G: scale(c.G, 0), B: scale(c.B, 0), A: scale(c.A, 1)})
+		}
+	}
+	return out
+}
+
+// ToneMapColor converts an HDR color to 8-bit, scaling the color channels by
+// exposure before applying the tone map. Alpha is only clipped.
+func ToneMapColor(c color.Color, exposure float32, toneMap ToneMap) color.NRGBA {
+	r, g, b, a := floats(c)
+	mapChannel := func(v float32) uint8 {
+		v *= exposure
+		if toneMap == ToneMapReinhard && v > 0 {
+			v = v / (1 + v)
+		}
+		return uint8(min(max(v, 0.0), 1.0)*255.0 + 0.5)
+	}
+	return color.NRGBA{
+		R: mapChannel(r),
+		G: mapChannel(g),
+		B: mapChannel(b),
+		A: uint8(min(max(a, 0.0), 1.0)*255.0 + 0.5),
+	}
+}
+
+func floats(c color.Color) (r, g, b, a float32) {
+	switch c := c.(type) {
+	case hdrColors.NRGBA128F:
+		return c.R, c.G, c.B, c.A
+	case hdrColors.NRGBA64F:
+		return c.R.Float32(), c.G.Float32(), c.B.Float32(), c.A.Float32()
+	case hdrColors.NRGBA128U:
+		return float32(c.R) / 4294967295.0, float32(c.G) / 4294967295.0, float32(c.B) / 4294967295.0, float32(c.A) / 4294967295.0
+	}
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return float32(nrgba.R) / 255.0, float32(nrgba.G) / 255.0, float32(nrgba.B) / 255.0, float32(nrgba.A) / 255.0
+}
+
+func drawGrid(img *image.NRGBA, scale int, gridColor color.NRGBA) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if x%scale == 0 || y%scale == 0 || x == bounds.Max.X-1 || y == bounds.Max.Y-1 {
+				img.SetNRGBA(x, y, gridColor)
+			}
+		}
+	}
+}
+
+func drawOutline(img *image.NRGBA, rect image.Rectangle, width int, outlineColor color.NRGBA) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if x < rect.Min.X+width || x >= rect.Max.X-width || y < rect.Min.Y+width || y >= rect.Max.Y-width {
+				img.SetNRGBA(x, y, outlineColor)
+			}
+		}
+	}
+}
+
+// drawHighlight tints rect with c and outlines it, as the editor highlights
+// texels.
+func drawHighlight(img *image.NRGBA, rect image.Rectangle, width int, c color.NRGBA) {
+	c.A = 0x59
+	draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Over)
+	c.A = 0xe6
+	outline := image.NewNRGBA(rect)
+	drawOutline(outline, rect, width, c)
+	draw.Draw(img, rect, outline, rect.Min, draw.Over)
+}
+
+// labelPadding is the space in pixels around row and column names.
+const labelPadding = 3
+
+// drawLabels returns img, a rendering of bounds at scale, with margins for
+// the row names on its left and the column names below it. Row names are
+// left out when the rows are shorter than the text, and column names that are
+// wider than a column.
+func drawLabels(img *image.NRGBA, bounds image.Rectangle, scale int, names Names) *image.NRGBA {
+	face := basicfont.Face7x13
+	left, bottom := 0, 0
+	if scale >= face.Height {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			left = max(left, font.MeasureString(face, names.Row(y)).Ceil()+labelPadding*2)
+		}
+	}
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		if width := font.MeasureString(face, names.Column(x)).Ceil(); width > 0 && width <= scale {
+			bottom = face.Height + labelPadding*2
+		}
+	}
+	if left == 0 && bottom == 0 {
+		return img
+	}
+
+	size := img.Bounds().Size()
+	out := image.NewNRGBA(image.Rect(0, 0, left+size.X, size.Y+bottom))
+	draw.Draw(out, out.Bounds(), image.NewUniform(sheetBackground), image.Point{}, draw.Src)
+	draw.Draw(out, image.Rect(left, 0, left+size.X, size.Y), img, image.Point{}, draw.Src)
+	if left > 0 {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			label := names.Row(y)
+			width := font.MeasureString(face, label).Ceil()
+			top := (y-bounds.Min.Y)*scale + (scale-face.Height)/2
+			drawLabel(out, label, image.Pt(left-width-labelPadding, top), width)
+		}
+	}
+	if bottom > 0 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			label := names.Column(x)
+			width := font.MeasureString(face, label).Ceil()
+			if width > scale {
+				continue
+			}
+			center := left + (x-bounds.Min.X)*scale + scale/2
+			drawLabel(out, label, image.Pt(center-width/2, size.Y+labelPadding), width)
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+// Package project reads and writes .lutproj files, which bundle an image with
+// the notes, names and settings of a modding session so that it can be
+// picked up again, or handed to someone else, as a single file.
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const Extension = ".lutproj"
+
+// Version is the newest project format this package understands.
+const Version = 1
+
+// Region is an area of the image, in image coordinates.
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func NewRegion(rect image.Rectangle) Region {
+	return Region{X: rect.Min.X, Y: rect.Min.Y, Width: rect.Dx(), Height: rect.Dy()}
+}
+
+func (r Region) Rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// Selection is a named region.
+type Selection struct {
+	Name string `json:"name"`
+	Region
+}
+
+func NewSelection(name string, rect image.Rectangle) Selection {
+	return Selection{Name: name, Region: NewRegion(rect)}
+}
+
+// View is how the image was being looked at when the project was saved.
+type View struct {
+	CamX    float64 `json:"camX"`
+	CamY    float64 `json:"camY"`
+	Zoom    float64 `json:"zoom"`
+	Channel int     `json:"channel"`
+	Grid    bool    `json:"grid"`
+}
+
+type Project struct {
+	Version int `json:"version"`
+	// Image is the path of the image file. Paths inside the project file's
+	// folder are stored relative to it, so the folder can be moved as a whole.
+	Image string `json:"image,omitempty"`
+	// EmbeddedImage holds the image file itself instead, in EmbeddedFormat
+	// ("exr" or "dds")
+	EmbeddedImage  []byte      `json:"embeddedImage,omitempty"`
+	EmbeddedFormat string      `json:"embeddedFormat,omitempty"`
+	Baseline       string      `json:"baseline,omitempty"`
+	RowNames       []string    `json:"rowNames,omitempty"`
+	ColumnNames    []string    `json:"columnNames,omitempty"`
+	Selections     []Selection `json:"selections,omitempty"`
+	// Locked covers the texels locked against editing
+	Locked []Region `json:"locked,omitempty"`
+	// RecentColors are the colors last drawn with, newest first
+	RecentColors [][4]float32 `json:"recentColors,omitempty"`
+	Notes        string       `json:"notes,omitempty"`
+	View         View         `json:"view"`
+}
+
+// Embedded reports whether the image is stored in the project file.
+func (p *Project) Embedded() bool {
+	return len(p.EmbeddedImage) > 0
+}
+
+// Load reads the project at path, resolving its file references to absolute
+// paths.
+func Load(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Project
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid project file: %v", err)
+	}
+	if p.Version > Version {
+		return nil, fmt.Errorf("project version %d is newer than this editor supports (%d)", p.Version, Version)
+	}
+	if p.Embedded() && p.EmbeddedFormat != "exr" && p.EmbeddedFormat != "dds" {
+		return nil, fmt.Errorf("unsupported embedded image format %q", p.EmbeddedFormat)
+	}
+	if !p.Embedded() && p.Image == "" {
+		return nil, fmt.Errorf("project has no image")
+	}
+	dir := filepath.Dir(path)
+	p.Image = resolve(dir, p.Image)
+	p.Baseline = resolve(dir, p.Baseline)
+	return &p, nil
+}
+
+// Save writes p to path. p itself is not modified.
+func (p *Project) Save(path string) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	out := *p
+	out.Version = Version
+	out.Image = relative(dir, p.Image)
+	out.Baseline = relative(dir, p.Baseline)
+	data, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func resolve(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
+
+// relative returns path relative to dir if it is inside dir, otherwise as an
+// absolute path.
+func relative(dir, path string) string {
+	if path == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return filepath.ToSlash(rel)
+}
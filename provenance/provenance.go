@@ -0,0 +1,238 @@
+// Package provenance records which edit last changed each texel of an image,
+// so a value that looks wrong can be traced back to the edit that set it. The
+// record is kept in a sidecar file next to the image.
+package provenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Suffix is added to the path of an image to name its sidecar file.
+const Suffix = ".provenance.json"
+
+// SidecarPath returns the path of the sidecar file of the image at path.
+func SidecarPath(path string) string {
+	return path + Suffix
+}
+
+// Stamp names the edit that last changed a texel.
+type Stamp struct {
+	Action string    `json:"action"`
+	Author string    `json:"author,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+func (s Stamp) String() string {
+	text := fmt.Sprintf("set by %s on %s", s.Action, s.Time.Local().Format("2006-01-02 15:04"))
+	if s.Author != "" {
+		text += " by " + s.Author
+	}
+	return text
+}
+
+// none marks texels not changed since tracking began.
+const none = -1
+
+// Map holds the stamp of the edit that last changed each texel of an image.
+// The zero Map tracks nothing until Start is called. Its methods may be
+// called from any goroutine.
+type Map struct {
+	mu            sync.Mutex
+	tracking      bool
+	width, height int
+	stamps        []Stamp
+	// texels index stamps for each texel, row by row, or are none
+	texels []int32
+	// values are the texels as they were when last recorded
+	values [][4]float64
+}
+
+// Start begins tracking img, with no texels stamped.
+func (m *Map) Start(img hdrColors.RawImage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reset(img)
+	m.tracking = true
+}
+
+// Stop stops tracking and forgets the stamps.
+func (m *Map) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tracking = false
+	m.width, m.height = 0, 0
+	m.stamps, m.texels, m.values = nil, nil, nil
+}
+
+// Tracking reports whether Start has been called since the last Stop.
+func (m *Map) Tracking() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tracking
+}
+
+// reset takes the size and values of img, with no texels stamped. m.mu must
+// be held.
+func (m *Map) reset(img hdrColors.RawImage) {
+	bounds := img.Bounds()
+	m.width, m.height = bounds.Dx(), bounds.Dy()
+	m.stamps = nil
+	m.texels = make([]int32, m.width*m.height)
+	for i := range m.texels {
+		m.texels[i] = none
+	}
+	m.values = readValues(img)
+}
+
+func readValues(img hdrColors.RawImage) [][4]float64 {
+	bounds := img.Bounds()
+	values := make([][4]float64, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			values = append(values, img.RawAt(x, y))
+		}
+	}
+	return values
+}
+
+// Record stamps the texels of img that changed since it was last recorded
+// with stamp, and returns how many it stamped. If img changed size, every
+// texel is stamped. It does nothing unless tracking.
+func (m *Map) Record(stamp Stamp, img hdrColors.RawImage) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.tracking {
+		return 0
+	}
+	values := readValues(img)
+	bounds := img.Bounds()
+	if bounds.Dx() != m.width || bounds.Dy() != m.height {
+		m.reset(img)
+		m.values = nil
+	}
+	index := int32(len(m.stamps))
+	changed := 0
+	for i, value := range values {
+		if m.values != nil && sameValue(value, m.values[i]) {
+			continue
+		}
+		m.texels[i] = index
+		changed++
+	}
+	if changed > 0 {
+		m.stamps = append(m.stamps, stamp)
+	}
+	m.values = values
+	return changed
+}
+
+// sameValue compares the bits of the channels, so NaN texels that weren't
+// touched aren't stamped.
+func sameValue(a, b [4]float64) bool {
+	for ch := range a {
+		if math.Float64bits(a[ch]) != math.Float64bits(b[ch]) {
+			return false
+		}
+	}
+	return true
+}
+
+// At returns the stamp of the texel at (x, y), from the top left, if it was
+// changed since tracking began.
+func (m *Map) At(x, y int) (Stamp, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.tracking || x < 0 || y < 0 || x >= m.width || y >= m.height {
+		return Stamp{}, false
+	}
+	index := m.texels[y*m.width+x]
+	if index == none {
+		return Stamp{}, false
+	}
+	return m.stamps[index], true
+}
+
+// sidecar is the file format: the stamps, and runs of texels, row by row,
+// with the same stamp, as pairs of the index of the stamp, or -1 for none, and
+// the length of the run.
+type sidecar struct {
+	Width  int        `json:"width"`
+	Height int        `json:"height"`
+	Stamps []Stamp    `json:"stamps"`
+	Runs   [][2]int32 `json:"runs"`
+}
+
+// Save writes the stamps to path, dropping those no texel has any more.
+func (m *Map) Save(path string) error {
+	m.mu.Lock()
+	file := sidecar{Width: m.width, Height: m.height, Stamps: make([]Stamp, 0)}
+	used := make(map[int32]int32)
+	for _, index := range m.texels {
+		if index != none {
+			if _, ok := used[index]; !ok {
+				used[index] = int32(len(file.Stamps))
+				file.Stamps = append(file.Stamps, m.stamps[index])
+			}
+			index = used[index]
+		}
+		if n := len(file.Runs); n > 0 && file.Runs[n-1][0] == index {
+			file.Runs[n-1][1]++
+		} else {
+			file.Runs = append(file.Runs, [2]int32{index, 1})
+		}
+	}
+	m.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Load starts tracking img with the stamps saved at path. It returns an error
+// wrapping fs.ErrNotExist if there is no file at path, and fails if the file
+// is for an image of another size.
+func (m *Map) Load(path string, img hdrColors.RawImage) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file sidecar
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid provenance file: %v", err)
+	}
+	bounds := img.Bounds()
+	if file.Width != bounds.Dx() || file.Height != bounds.Dy() {
+		return fmt.Errorf("provenance file is for a %dx%d image, not %dx%d", file.Width, file.Height, bounds.Dx(), bounds.Dy())
+	}
+	texels := make([]int32, 0, file.Width*file.Height)
+	for _, run := range file.Runs {
+		index, length := run[0], int(run[1])
+		if index < none || index >= int32(len(file.Stamps)) || length < 0 || len(texels)+length > cap(texels) {
+			return errors.New("invalid provenance file: bad texel run")
+		}
+		for range length {
+			texels = append(texels, index)
+		}
+	}
+	if len(texels) != cap(texels) {
+		return errors.New("invalid provenance file: missing texels")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tracking = true
+	m.width, m.height = file.Width, file.Height
+	m.stamps, m.texels = file.Stamps, texels
+	m.values = readValues(img)
+	return nil
+}
@@ -0,0 +1,232 @@
+// Package script parses and runs the commands typed into the console, such as
+// fill(sel, 0.2, 0.2, 0.2, 1) or stats(ch="R"). A command is a name followed
+// by optional arguments in parentheses. Arguments are numbers, quoted strings
+// or bare words, and may be named with name=value.
+package script
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Call is a parsed command. Argument values are float64 for numbers and
+// string for quoted strings and bare words.
+type Call struct {
+	Name  string
+	Args  []any
+	Named map[string]any
+}
+
+// arg returns the argument named name, or else the positional argument i.
+// Either can be left out with "" or -1.
+func (c Call) arg(i int, name string) (any, bool) {
+	if v, ok := c.Named[name]; ok && name != "" {
+		return v, true
+	}
+	if i >= 0 && i < len(c.Args) {
+		return c.Args[i], true
+	}
+	return nil, false
+}
+
+func argName(i int, name string) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprintf("argument %d", i+1)
+}
+
+// Has is true if the argument is given.
+func (c Call) Has(i int, name string) bool {
+	_, ok := c.arg(i, name)
+	return ok
+}
+
+// Number returns a number argument, named name or at position i.
+func (c Call) Number(i int, name string) (float64, error) {
+	v, ok := c.arg(i, name)
+	if !ok {
+		return 0, fmt.Errorf("%s: missing %s", c.Name, argName(i, name))
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s: %s must be a number, not %q", c.Name, argName(i, name), v)
+	}
+	return n, nil
+}
+
+// Text returns a string argument, named name or at position i, or def if it
+// isn't given.
+func (c Call) Text(i int, name, def string) (string, error) {
+	v, ok := c.arg(i, name)
+	if !ok {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: %s must be text, not %v", c.Name, argName(i, name), v)
+	}
+	return s, nil
+}
+
+// Parse reads a single command from line.
+func Parse(line string) (Call, error) {
+	p := parser{src: []rune(strings.TrimSpace(line))}
+	name := p.word()
+	if name == "" {
+		return Call{}, fmt.Errorf("expected a command name")
+	}
+	call := Call{Name: name, Named: make(map[string]any)}
+	p.space()
+	if p.done() {
+		return call, nil
+	}
+	if !p.accept('(') {
+		return Call{}, fmt.Errorf("expected ( after %s", name)
+	}
+	p.space()
+	for !p.accept(')') {
+		if len(call.Args)+len(call.Named) > 0 {
+			if !p.accept(',') {
+				return Call{}, fmt.Errorf("expected , or ) at column %d", p.pos+1)
+			}
+			p.space()
+		}
+		v, err := p.value()
+		if err != nil {
+			return Call{}, err
+		}
+		p.space()
+		if word, ok := v.(string); ok && p.quoted == 0 && p.accept('=') {
+			p.space()
+			if v, err = p.value(); err != nil {
+				return Call{}, err
+			}
+			call.Named[word] = v
+		} else {
+			if len(call.Named) > 0 {
+				return Call{}, fmt.Errorf("positional arguments must come before named ones")
+			}
+			call.Args = append(call.Args, v)
+		}
+		p.space()
+		if p.done() {
+			return Call{}, fmt.Errorf("missing )")
+		}
+	}
+	p.space()
+	if !p.done() {
+		return Call{}, fmt.Errorf("unexpected %q after )", string(p.src[p.pos:]))
+	}
+	return call, nil
+}
+
+type parser struct {
+	src []rune
+	pos int
+	// quoted is the quote that started the last value, or 0 for a bare value
+	quoted rune
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) space() {
+	for !p.done() && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) accept(r rune) bool {
+	if !p.done() && p.src[p.pos] == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) word() string {
+	start := p.pos
+	for !p.done() && (unicode.IsLetter(p.src[p.pos]) || unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '_') {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *parser) value() (any, error) {
+	p.quoted = 0
+	if p.done() {
+		return nil, fmt.Errorf("missing )")
+	}
+	switch r := p.src[p.pos]; {
+	case r == '"' || r == '\'':
+		p.quoted = r
+		end := slices.Index(p.src[p.pos+1:], r)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string at column %d", p.pos+1)
+		}
+		s := string(p.src[p.pos+1 : p.pos+1+end])
+		p.pos += end + 2
+		return s, nil
+	case unicode.IsDigit(r) || r == '-' || r == '+' || r == '.':
+		start := p.pos
+		for !p.done() && strings.ContainsRune("0123456789.eE+-", p.src[p.pos]) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(string(p.src[start:p.pos]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", string(p.src[start:p.pos]))
+		}
+		return n, nil
+	}
+	if word := p.word(); word != "" {
+		return word, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at column %d", p.src[p.pos], p.pos+1)
+}
+
+// Command is an operation the console can run. Run returns text to show, if
+// any.
+type Command struct {
+	Name string
+	// Usage shows the arguments, e.g. "fill(target, r, g, b, a)"
+	Usage string
+	Help  string
+	Run   func(call Call) (string, error)
+}
+
+// Commands is the set of commands available to a console.
+type Commands []Command
+
+// Run parses line and runs the command it names. help() lists the commands.
+func (cs Commands) Run(line string) (string, error) {
+	call, err := Parse(line)
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(call.Name, "help") {
+		return cs.Help(), nil
+	}
+	for _, cmd := range cs {
+		if strings.EqualFold(cmd.Name, call.Name) {
+			return cmd.Run(call)
+		}
+	}
+	return "", fmt.Errorf("unknown command %s, help() lists them", call.Name)
+}
+
+// Help lists the usage and description of each command.
+func (cs Commands) Help() string {
+	var b strings.Builder
+	for i, cmd := range cs {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%s - %s", cmd.Usage, cmd.Help)
+	}
+	return b.String()
+}
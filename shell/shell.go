@@ -0,0 +1,14 @@
+// Package shell integrates the editor with the desktop shell, so that DDS and
+// EXR files can be opened in it from the file browser, and runs commands with
+// the system's command interpreter.
+package shell
+
+import "errors"
+
+// ProgID identifies the editor's file type registration.
+const ProgID = "HD2LUTEditor.Image"
+
+// Extensions are the file types the editor registers itself for.
+var Extensions = []string{".dds", ".exr"}
+
+var ErrUnsupported = errors.New("file associations are only supported on Windows")
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package shell
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+func RegisterFileTypes(exePath string, args []string, makeDefault bool) error {
+	return ErrUnsupported
+}
+
+func UnregisterFileTypes() error {
+	return ErrUnsupported
+}
+
+// Command returns a command running line with sh.
+func Command(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// Quote quotes s as a single sh argument.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,101 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+var (
+	shell32 = syscall.NewLazyDLL("shell32")
+	// Notifies the system of an event that an application has performed.
+	// https://learn.microsoft.com/en-us/windows/win32/api/shlobj_core/nf-shlobj_core-shchangenotify
+	shChangeNotify = shell32.NewProc("SHChangeNotify")
+)
+
+const (
+	shcneAssocChanged = 0x08000000
+	shcnfIDList       = 0x0000
+)
+
+// Per-user registrations don't require administrator rights.
+const classesKey = `HKCU\Software\Classes`
+
+func reg(args ...string) error {
+	cmd := exec.Command("reg", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("reg %v: %v: %s", args[0], err, out)
+	}
+	return nil
+}
+
+// RegisterFileTypes adds the editor at exePath, run with args before the file
+// name, to the "Open with" list for DDS and EXR files. If makeDefault is true it also becomes the program used
+// when the files are double clicked, unless the user has picked a different
+// default in Windows settings.
+func RegisterFileTypes(exePath string, args []string, makeDefault bool) error {
+	progKey := classesKey + `\` + ProgID
+	if err := reg("add", progKey, "/ve", "/d", "Helldivers 2 LUT Image", "/f"); err != nil {
+		return err
+	}
+	command := fmt.Sprintf(`"%s"`, exePath)
+	for _, arg := range args {
+		command += fmt.Sprintf(` "%s"`, arg)
+	}
+	command += ` "%1"`
+	if err := reg("add", progKey+`\shell\open\command`, "/ve", "/d", command, "/f"); err != nil {
+		return err
+	}
+	for _, ext := range Extensions {
+		extKey := classesKey + `\` + ext
+		if err := reg("add", extKey+`\OpenWithProgids`, "/v", ProgID, "/t", "REG_NONE", "/f"); err != nil {
+			return err
+		}
+		if makeDefault {
+			if err := reg("add", extKey, "/ve", "/d", ProgID, "/f"); err != nil {
+				return err
+			}
+		}
+	}
+	shChangeNotify.Call(shcneAssocChanged, shcnfIDList, 0, 0)
+	return nil
+}
+
+// UnregisterFileTypes removes everything RegisterFileTypes may have added.
+func UnregisterFileTypes() error {
+	if err := reg("delete", classesKey+`\`+ProgID, "/f"); err != nil {
+		return err
+	}
+	for _, ext := range Extensions {
+		extKey := classesKey + `\` + ext
+		// Either may be missing if the extension was never registered
+		reg("delete", extKey+`\OpenWithProgids`, "/v", ProgID, "/f")
+		if out, err := exec.Command("reg", "query", extKey, "/ve").Output(); err == nil && bytes.Contains(out, []byte(ProgID)) {
+			reg("delete", extKey, "/ve", "/f")
+		}
+	}
+	shChangeNotify.Call(shcneAssocChanged, shcnfIDList, 0, 0)
+	return nil
+}
+
+// Command returns a command running line with cmd.exe, without showing a
+// console window. line is passed on as it is, since cmd.exe doesn't follow
+// the quoting rules exec uses for arguments.
+func Command(ctx context.Context, line string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true,
+		CmdLine:    `cmd.exe /s /c "` + line + `"`,
+	}
+	return cmd
+}
+
+// Quote quotes s as a single cmd.exe argument. Windows paths can't contain
+// double quotes, so they are left as they are.
+func Quote(s string) string {
+	return `"` + s + `"`
+}
@@ -0,0 +1,46 @@
+// Package tablet reads the stylus of a pen tablet, which the windowing
+// library only reports as a mouse: how hard it is pressed, and whether it is
+// turned over to its eraser tip.
+package tablet
+
+// Pen is the state of a tablet's stylus.
+type Pen struct {
+	// InRange is set while the stylus is over the tablet. The mouse follows
+	// it then, so the left mouse button is its tip
+	InRange bool
+	// Pressure is how hard the tip is pressed, from 0 to 1
+	Pressure float64
+	// Eraser is set while the stylus is turned over to its eraser tip
+	Eraser bool
+}
+
+// Weight returns the pressure while the stylus is in range, and 1 otherwise,
+// so the mouse always presses fully.
+func (p Pen) Weight() float64 {
+	if !p.InRange {
+		return 1
+	}
+	return p.Pressure
+}
+
+// Erasing reports whether the stylus is in range with its eraser tip down.
+func (p Pen) Erasing() bool {
+	return p.InRange && p.Eraser
+}
+
+// Pen returns the state of the stylus as of its latest report. It is the
+// zero Pen, out of range, for a nil Tablet, so callers can keep using the
+// mouse when Open fails.
+func (t *Tablet) Pen() Pen {
+	if t == nil {
+		return Pen{}
+	}
+	return t.poll()
+}
+
+// Close stops reading the tablet. A nil Tablet is ignored.
+func (t *Tablet) Close() {
+	if t != nil {
+		t.close()
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package tablet
+
+import "errors"
+
+// Tablet is a connection to the tablet driver. There is none on this
+// platform, so Open always fails.
+type Tablet struct{}
+
+func Open() (*Tablet, error) {
+	return nil, errors.New("pen tablets are only supported on Windows")
+}
+
+func (t *Tablet) poll() Pen {
+	return Pen{}
+}
+
+func (t *Tablet) close() {}
@@ -0,0 +1,196 @@
+package tablet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Tablets are read through Wintab, which the drivers of most pen tablets
+// install.
+// https://developer-docs.wacom.com/docs/icbt/windows/wintab/wintab-reference/
+
+var (
+	wintab32 = syscall.NewLazyDLL("wintab32")
+	// Retrieves information about Wintab, the tablet and its contexts.
+	wtInfo = wintab32.NewProc("WTInfoW")
+	// Opens a context for the window, whose packets are queued for it.
+	wtOpen = wintab32.NewProc("WTOpenW")
+	// Closes a context and throws away its queued packets.
+	wtClose = wintab32.NewProc("WTClose")
+	// Copies up to a number of the oldest queued packets, removing them from
+	// the queue.
+	wtPacketsGet = wintab32.NewProc("WTPacketsGet")
+
+	user32 = syscall.NewLazyDLL("user32")
+	// Calls a function with each top level window.
+	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-enumwindows
+	enumWindows = user32.NewProc("EnumWindows")
+	// Retrieves the ids of the thread and process that made a window.
+	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getwindowthreadprocessid
+	getWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-iswindowvisible
+	isWindowVisible = user32.NewProc("IsWindowVisible")
+)
+
+const (
+	// WTInfo categories and indexes
+	wtiDefSysCtx = 4
+	wtiDevices   = 100
+	dvcNPressure = 15
+
+	// cxoSystem keeps the stylus moving the system cursor, so it still works
+	// as the mouse
+	cxoSystem = 0x0001
+
+	// Packet fields, which a packet holds in this order
+	pkStatus         = 0x0002
+	pkNormalPressure = 0x0400
+
+	// Packet status bits
+	tpsProximity = 0x0001
+	tpsInvert    = 0x0010
+)
+
+// hoverTimeout is how long a stylus that isn't touching the tablet is taken
+// to be in range without sending packets. Drivers don't always send a packet
+// when the stylus leaves, but lifting the tip always sends one with no
+// pressure, so a stylus that is still pressed is never timed out.
+const hoverTimeout = 100 * time.Millisecond
+
+// logContext is Wintab's LOGCONTEXTW, describing a context.
+type logContext struct {
+	Name                      [40]uint16
+	Options                   uint32
+	Status                    uint32
+	Locks                     uint32
+	MsgBase                   uint32
+	Device                    uint32
+	PktRate                   uint32
+	PktData                   uint32
+	PktMode                   uint32
+	MoveMask                  uint32
+	BtnDnMask                 uint32
+	BtnUpMask                 uint32
+	InOrgX, InOrgY, InOrgZ    int32
+	InExtX, InExtY, InExtZ    int32
+	OutOrgX, OutOrgY, OutOrgZ int32
+	OutExtX, OutExtY, OutExtZ int32
+	SensX, SensY, SensZ       uint32
+	SysMode                   int32
+	SysOrgX, SysOrgY          int32
+	SysExtX, SysExtY          int32
+	SysSensX, SysSensY        uint32
+}
+
+// axis is Wintab's AXIS, the range of a value the tablet reports.
+type axis struct {
+	Min, Max   int32
+	Units      uint32
+	Resolution uint32
+}
+
+// packet holds the fields asked for in logContext.PktData.
+type packet struct {
+	Status   uint32
+	Pressure uint32
+}
+
+// Tablet is a Wintab context of the editor's window.
+type Tablet struct {
+	context     uintptr
+	minPressure int32
+	maxPressure int32
+	pen         Pen
+	// lastPacket is when the latest packet was read
+	lastPacket time.Time
+}
+
+// Open starts reading the tablet for the process's visible window, which
+// must already be open. It fails if no tablet driver is installed.
+func Open() (*Tablet, error) {
+	if err := wintab32.Load(); err != nil {
+		return nil, fmt.Errorf("no tablet driver: %w", err)
+	}
+	hwnd := processWindow()
+	if hwnd == 0 {
+		return nil, errors.New("no window to read the tablet for")
+	}
+
+	var ctx logContext
+	if n, _, _ := wtInfo.Call(wtiDefSysCtx, 0, uintptr(unsafe.Pointer(&ctx))); n == 0 {
+		return nil, errors.New("no tablet found")
+	}
+	var pressure axis
+	if n, _, _ := wtInfo.Call(wtiDevices, dvcNPressure, uintptr(unsafe.Pointer(&pressure))); n == 0 || pressure.Max <= pressure.Min {
+		return nil, errors.New("tablet does not report pressure")
+	}
+	ctx.Options |= cxoSystem
+	ctx.PktData = pkStatus | pkNormalPressure
+	ctx.PktMode = 0
+	ctx.MoveMask = ctx.PktData
+	context, _, _ := wtOpen.Call(hwnd, uintptr(unsafe.Pointer(&ctx)), 1)
+	if context == 0 {
+		return nil, errors.New("failed to open a tablet context")
+	}
+	return &Tablet{context: context, minPressure: pressure.Min, maxPressure: pressure.Max}, nil
+}
+
+// processWindow returns the first visible top level window of this process,
+// or 0 if it has none.
+func processWindow() uintptr {
+	pid := uint32(os.Getpid())
+	var found uintptr
+	callback := syscall.NewCallback(func(hwnd, _ uintptr) uintptr {
+		var owner uint32
+		getWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&owner)))
+		if visible, _, _ := isWindowVisible.Call(hwnd); owner == pid && visible != 0 {
+			found = hwnd
+			return 0
+		}
+		return 1
+	})
+	enumWindows.Call(callback, 0)
+	return found
+}
+
+// poll empties the packet queue and keeps the latest state. The tablet only
+// sends packets when something changes, so a stylus held still keeps its
+// last state, until it leaves the tablet.
+func (t *Tablet) poll() Pen {
+	var packets [32]packet
+	for {
+		n, _, _ := wtPacketsGet.Call(t.context, uintptr(len(packets)), uintptr(unsafe.Pointer(&packets[0])))
+		if n == 0 {
+			break
+		}
+		last := packets[n-1]
+		t.lastPacket = time.Now()
+		if last.Status&tpsProximity != 0 {
+			t.pen = Pen{}
+		} else {
+			pressure := float64(int32(last.Pressure)-t.minPressure) / float64(t.maxPressure-t.minPressure)
+			t.pen = Pen{
+				InRange:  true,
+				Pressure: min(max(pressure, 0), 1),
+				Eraser:   last.Status&tpsInvert != 0,
+			}
+		}
+		if n < uintptr(len(packets)) {
+			break
+		}
+	}
+	if t.pen.InRange && t.pen.Pressure == 0 && time.Since(t.lastPacket) > hoverTimeout {
+		// Lifted and gone without saying so, after which the mouse presses
+		// fully again
+		t.pen = Pen{}
+	}
+	return t.pen
+}
+
+func (t *Tablet) close() {
+	wtClose.Call(t.context)
+}
@@ -0,0 +1,114 @@
+// Package texhash identifies textures by the values of their texels rather
+// than their files, so the same LUT saved as DDS or EXR, or with different
+// header attributes, hashes the same.
+package texhash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Sum is the content hash of an image.
+type Sum uint64
+
+func (s Sum) String() string {
+	return fmt.Sprintf("%016x", uint64(s))
+}
+
+// Image hashes the size of img and the stored value of each channel of each
+// texel as a little-endian float32, with XXH64. Images without float values
+// are hashed through their colors, normalized to [0, 1].
+func Image(img image.Image) Sum {
+	if ddsImg, ok := img.(*dds.DDS); ok {
+		img = ddsImg.Image
+	}
+	raw, _ := img.(hdrColors.RawImage)
+	bounds := img.Bounds()
+	buf := make([]byte, 8, 8+16*bounds.Dx()*bounds.Dy())
+	binary.LittleEndian.PutUint32(buf, uint32(bounds.Dx()))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var c [4]float64
+			if raw != nil {
+				c = raw.RawAt(x, y)
+			} else {
+				nrgba := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+				c = [4]float64{float64(nrgba.R), float64(nrgba.G), float64(nrgba.B), float64(nrgba.A)}
+				for i := range c {
+					c[i] /= math.MaxUint16
+				}
+			}
+			for _, v := range c {
+				buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v)))
+			}
+		}
+	}
+	return Sum(Sum64(buf))
+}
+
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// Sum64 is the XXH64 hash of b with a seed of 0.
+func Sum64(b []byte) uint64 {
+	n := len(b)
+	var h uint64
+	if n >= 32 {
+		var seed uint64
+		v1, v2, v3, v4 := seed+prime1+prime2, seed+prime2, seed, seed-prime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = round(v1, binary.LittleEndian.Uint64(b))
+			v2 = round(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = round(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = round(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		for _, v := range []uint64{v1, v2, v3, v4} {
+			h ^= round(0, v)
+			h = h*prime1 + prime4
+		}
+	} else {
+		h = prime5
+	}
+	h += uint64(n)
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		b = b[4:]
+	}
+	for ; len(b) > 0; b = b[1:] {
+		h ^= uint64(b[0]) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+	return h
+}
+
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime1
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,6 +14,24 @@ type BackgroundStatus struct {
 	Progress int
 	Total    int
 	Status   TaskStatus
+	// Cancellable shows a button in the status bar to cancel the task while
+	// it runs
+	Cancellable bool
+	// Output is text the task captured, such as the output of a command it
+	// ran, shown when its finished status is hovered
+	Output    string
+	cancelled atomic.Bool
+}
+
+// Cancel asks the task to stop. The task checks Cancelled as it runs, and
+// calls OnCancel once it has stopped.
+func (b *BackgroundStatus) Cancel() {
+	b.cancelled.Store(true)
+}
+
+// Cancelled reports whether Cancel has been called.
+func (b *BackgroundStatus) Cancelled() bool {
+	return b.cancelled.Load()
 }
 
 func (b *BackgroundStatus) OnProgress(current, total int, err error) {
@@ -35,8 +54,44 @@ func (b *BackgroundStatus) OnComplete(success, failed, total int) {
 	}()
 }
 
+func (b *BackgroundStatus) OnError(err error) {
+	b.Status = TaskFailed
+	b.Message = fmt.Sprintf("Error: %v", err)
+	go func() {
+		time.Sleep(8 * time.Second)
+		b.Status = TaskCancelled
+	}()
+}
+
+// Report finishes the task with message, for tasks that run instantly but have
+// something to say in the status bar.
+func (b *BackgroundStatus) Report(message string) {
+	b.Status = TaskFinished
+	b.Message = message
+	go func() {
+		time.Sleep(8 * time.Second)
+		b.Status = TaskCancelled
+	}()
+}
+
 func (b *BackgroundStatus) OnCancel() {
 	b.Status = TaskCancelled
 }
 
 type TaskMap map[TaskID]*BackgroundStatus
+
+// Add registers a new idle task under an unused ID and returns it.
+func (t TaskMap) Add(name string) *BackgroundStatus {
+	var id TaskID
+	for existing := range t {
+		id = max(id, existing+1)
+	}
+	t[id] = &BackgroundStatus{
+		Name:     name,
+		Message:  "",
+		Progress: 0,
+		Total:    -1,
+		Status:   TaskIdle,
+	}
+	return t[id]
+}
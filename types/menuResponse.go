@@ -3,21 +3,121 @@ package types
 type MenuResponse uint8
 
 const (
-	MenuResponseNone             MenuResponse = iota
-	MenuResponseImageOpen        MenuResponse = iota
-	MenuResponseImageSave        MenuResponse = iota
-	MenuResponseImageSaveAs      MenuResponse = iota
-	MenuResponseImageNew         MenuResponse = iota
-	MenuResponseViewChannels     MenuResponse = iota
-	MenuResponseViewColor        MenuResponse = iota
-	MenuResponseViewHelp         MenuResponse = iota
-	MenuResponseViewTools        MenuResponse = iota
-	MenuResponseViewGrid         MenuResponse = iota
-	MenuResponseUndo             MenuResponse = iota
-	MenuResponseRedo             MenuResponse = iota
-	MenuResponseCopy             MenuResponse = iota
-	MenuResponseCut              MenuResponse = iota
-	MenuResponsePaste            MenuResponse = iota
-	MenuResponseBulkConvertToDDS MenuResponse = iota
-	MenuResponseBulkConvertToEXR MenuResponse = iota
+	MenuResponseNone                     MenuResponse = iota
+	MenuResponseImageOpen                MenuResponse = iota
+	MenuResponseImageSave                MenuResponse = iota
+	MenuResponseImageSaveAs              MenuResponse = iota
+	MenuResponseImageNew                 MenuResponse = iota
+	MenuResponseViewChannels             MenuResponse = iota
+	MenuResponseViewColor                MenuResponse = iota
+	MenuResponseViewHelp                 MenuResponse = iota
+	MenuResponseViewTools                MenuResponse = iota
+	MenuResponseViewGrid                 MenuResponse = iota
+	MenuResponseUndo                     MenuResponse = iota
+	MenuResponseRedo                     MenuResponse = iota
+	MenuResponseCopy                     MenuResponse = iota
+	MenuResponseCut                      MenuResponse = iota
+	MenuResponsePaste                    MenuResponse = iota
+	MenuResponseBulkConvertToDDS         MenuResponse = iota
+	MenuResponseBulkConvertToEXR         MenuResponse = iota
+	MenuResponseViewTrackpad             MenuResponse = iota
+	MenuResponseImageNewFromClipboard    MenuResponse = iota
+	MenuResponseImageSaveCopy            MenuResponse = iota
+	MenuResponseImageDuplicate           MenuResponse = iota
+	MenuResponseImageExportPreview       MenuResponse = iota
+	MenuResponseCopyViewport             MenuResponse = iota
+	MenuResponseCopyViewportNoOverlays   MenuResponse = iota
+	MenuResponseSnapshotStoreA           MenuResponse = iota
+	MenuResponseSnapshotStoreB           MenuResponse = iota
+	MenuResponseSnapshotFlip             MenuResponse = iota
+	MenuResponseSnapshotLive             MenuResponse = iota
+	MenuResponseToolsCompareHeaders      MenuResponse = iota
+	MenuResponseViewFileInfo             MenuResponse = iota
+	MenuResponseViewColorManaged         MenuResponse = iota
+	MenuResponseImageOpenURL             MenuResponse = iota
+	MenuResponseToolsRegisterFileTypes   MenuResponse = iota
+	MenuResponseToolsRegisterDefault     MenuResponse = iota
+	MenuResponseToolsUnregisterFileTypes MenuResponse = iota
+	MenuResponseViewDisplayProfileNone   MenuResponse = iota
+	MenuResponseViewDisplayProfileDetect MenuResponse = iota
+	MenuResponseViewDisplayProfileChoose MenuResponse = iota
+	MenuResponseImageShiftWrap           MenuResponse = iota
+	MenuResponsePasteSpecial             MenuResponse = iota
+	MenuResponseFilterInterpolate        MenuResponse = iota
+	MenuResponseFilterJitter             MenuResponse = iota
+	MenuResponseFilterQuantize           MenuResponse = iota
+	MenuResponseViewGraph                MenuResponse = iota
+	MenuResponseToolsSetBaseline         MenuResponse = iota
+	MenuResponseToolsExportChangeReport  MenuResponse = iota
+	MenuResponseProjectOpen              MenuResponse = iota
+	MenuResponseProjectSave              MenuResponse = iota
+	MenuResponseProjectSaveAs            MenuResponse = iota
+	MenuResponseViewProject              MenuResponse = iota
+	MenuResponseToolsLinkLUTs            MenuResponse = iota
+	MenuResponseToolsUnlinkLUTs          MenuResponse = iota
+	MenuResponseLockTexels               MenuResponse = iota
+	MenuResponseUnlockTexels             MenuResponse = iota
+	MenuResponseUnlockAllTexels          MenuResponse = iota
+	MenuResponseViewLockedTexels         MenuResponse = iota
+	MenuResponseViewEncodeSRGB           MenuResponse = iota
+	MenuResponseImageSavePrecision       MenuResponse = iota
+	MenuResponseToolsDiffHEAD            MenuResponse = iota
+	MenuResponseViewSchema               MenuResponse = iota
+	MenuResponseViewSchemaViolations     MenuResponse = iota
+	MenuResponseImageBrowse              MenuResponse = iota
+	MenuResponseViewBrowser              MenuResponse = iota
+	MenuResponseToolsExportBeforeAfter   MenuResponse = iota
+	MenuResponseViewPresets              MenuResponse = iota
+	MenuResponseImageImportRow           MenuResponse = iota
+	MenuResponseImageExportRow           MenuResponse = iota
+	MenuResponseSelectGrow               MenuResponse = iota
+	MenuResponseSelectShrink             MenuResponse = iota
+	MenuResponseSelectFeather            MenuResponse = iota
+	MenuResponseEditGoTo                 MenuResponse = iota
+	MenuResponseEditFind                 MenuResponse = iota
+	MenuResponseEditFindNext             MenuResponse = iota
+	MenuResponseImageOpenAs              MenuResponse = iota
+	MenuResponseImageImportRaw           MenuResponse = iota
+	MenuResponseViewBytes                MenuResponse = iota
+	MenuResponseImageRepeatSelection     MenuResponse = iota
+	MenuResponseToolsSampleRamp          MenuResponse = iota
+	MenuResponseToolsContactSheet        MenuResponse = iota
+	MenuResponseViewConsole              MenuResponse = iota
+	MenuResponseImageRestoreOriginal     MenuResponse = iota
+	MenuResponseImageBackupFolder        MenuResponse = iota
+	MenuResponseViewMemory               MenuResponse = iota
+	MenuResponseViewHistory              MenuResponse = iota
+	MenuResponseFilterGradientMap        MenuResponse = iota
+	MenuResponseFilterSnapSchemaValues   MenuResponse = iota
+	MenuResponseViewColumnGroups         MenuResponse = iota
+	MenuResponseViewShowAllGroups        MenuResponse = iota
+	MenuResponseViewToggleGroup          MenuResponse = iota
+	MenuResponseViewRowFilter            MenuResponse = iota
+	MenuResponseSelectAddMultiRows       MenuResponse = iota
+	MenuResponseSelectRemoveMultiRows    MenuResponse = iota
+	MenuResponseSelectClearMultiRows     MenuResponse = iota
+	MenuResponseEditCopyRowStyle         MenuResponse = iota
+	MenuResponseEditPasteRowStyle        MenuResponse = iota
+	MenuResponseViewSmoothZoom           MenuResponse = iota
+	MenuResponseToolsGenerateVariants    MenuResponse = iota
+	MenuResponseFilterRecolor            MenuResponse = iota
+	MenuResponseToolsMatchBaseline       MenuResponse = iota
+	MenuResponseImageSaveHook            MenuResponse = iota
+	MenuResponseImageExportPipeline      MenuResponse = iota
+	MenuResponseImageReloadPreferences   MenuResponse = iota
+	MenuResponseImageWatchInbox          MenuResponse = iota
+	MenuResponseImageStopInbox           MenuResponse = iota
+	MenuResponseToolsBaselineLibrary     MenuResponse = iota
+	MenuResponseToolsProvenance          MenuResponse = iota
+	MenuResponseToolsExportEditLog       MenuResponse = iota
+	MenuResponseToolsApplyEditLog        MenuResponse = iota
+	MenuResponseViewLayers               MenuResponse = iota
+	MenuResponseToolsMerge               MenuResponse = iota
+	MenuResponseImageNewFromTemplate     MenuResponse = iota
+	MenuResponseViewTexelLabels          MenuResponse = iota
+	MenuResponseSelectChanged            MenuResponse = iota
+	MenuResponseViewPatternUsage         MenuResponse = iota
+	MenuResponseViewTexelTooltips        MenuResponse = iota
+	MenuResponseHelpSchemaEditor         MenuResponse = iota
+	MenuResponseHelpUpdateSchemas        MenuResponse = iota
 )
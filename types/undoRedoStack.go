@@ -1,21 +1,20 @@
 package types
 
 import (
-	"bytes"
 	"fmt"
 	"image"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/gopxl/pixel/v2"
-	"github.com/ryanjsims/hd2-lut-editor/openexr"
 )
 
 type UndoRedoState struct {
 	Action    string
 	filename  string
 	saved     bool
-	Img       []byte
+	snap      *snapshot
 	Color     [4]float32
 	Selection pixel.Rect
 }
@@ -23,40 +22,224 @@ type UndoRedoState struct {
 type UndoRedoStack struct {
 	UndoStack []UndoRedoState
 	RedoStack []UndoRedoState
-	timer     *time.Timer
+	// mu guards the delayed push, which happens on the timer's goroutine
+	mu          sync.Mutex
+	timer       *time.Timer
+	pending     string
+	pendingPush func()
+	// last guards checkpointEvery, and holds a copy of the image of the last
+	// snapshot pushed, for the next push to compare against
+	last struct {
+		sync.Mutex
+		snap            *snapshot
+		img             pixelImage
+		checkpointEvery int
+	}
 }
 
 func (u *UndoRedoStack) Clear() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
 	if u.timer != nil {
 		u.timer.Stop()
-		u.timer = nil
 	}
+	u.timer, u.pending, u.pendingPush = nil, "", nil
 	u.UndoStack = make([]UndoRedoState, 0)
 	u.RedoStack = make([]UndoRedoState, 0)
+	u.forgetLast()
 }
 
-func (u *UndoRedoStack) Push(action, filename string, saved bool, img image.Image, currColor [4]float32, selection pixel.Rect) {
+// SetCheckpointEvery makes every nth state pushed keep its whole image, and
+// the others only what changed since the state before them. Smaller values
+// make undoing quicker, and larger ones use less memory. 0 uses
+// DefaultCheckpointEvery.
+func (u *UndoRedoStack) SetCheckpointEvery(n int) {
+	u.last.Lock()
+	defer u.last.Unlock()
+	u.last.checkpointEvery = n
+}
+
+// NewUndoRedoState captures a snapshot of the editor state, copying the whole
+// of img so that it is unaffected by later edits.
+func NewUndoRedoState(action, filename string, saved bool, img image.Image, currColor [4]float32, selection pixel.Rect) UndoRedoState {
 	undoState := UndoRedoState{
 		Action:    action,
 		filename:  filename,
 		saved:     saved,
-		Img:       make([]byte, 0),
 		Color:     currColor,
 		Selection: selection,
 	}
-	if img != nil {
-		buf := &bytes.Buffer{}
-		openexr.WriteHDR(buf, img)
-		undoState.Img = append(undoState.Img, buf.Bytes()...)
+	if p, ok := pixels(img); ok {
+		undoState.snap = wholeSnapshot(p)
+	} else if img != nil {
+		undoState.snap = encodedSnapshot(img)
+	}
+	return undoState
+}
+
+// Image rebuilds the image stored in the state, returning nil if the state
+// does not hold one.
+func (s *UndoRedoState) Image() (image.Image, error) {
+	if s.snap == nil {
+		return nil, nil
+	}
+	return s.snap.image()
+}
+
+// Bytes returns the memory used by the state's image, which for most states
+// is only what changed since the state before it.
+func (s *UndoRedoState) Bytes() int {
+	if s.snap == nil {
+		return 0
 	}
-	u.UndoStack = append(u.UndoStack, undoState)
+	return s.snap.bytes()
 }
 
+// Bytes returns the memory used by the stored images.
+func (u *UndoRedoStack) Bytes() int {
+	n := 0
+	for _, states := range [][]UndoRedoState{u.UndoStack, u.RedoStack} {
+		for _, state := range states {
+			n += state.Bytes()
+		}
+	}
+	u.last.Lock()
+	defer u.last.Unlock()
+	if u.last.img != nil {
+		n += len(u.last.img.Pixels())
+	}
+	return n
+}
+
+// Trim drops the oldest undo states, keeping the newest keep of them, and
+// returns how many were dropped. States made from the dropped states' images
+// are given their whole image, so that the dropped ones can be freed.
+func (u *UndoRedoStack) Trim(keep int) int {
+	keep = max(keep, 1)
+	dropped := max(len(u.UndoStack)-keep, 0)
+	droppedSnaps := make(map[*snapshot]bool)
+	for _, state := range u.UndoStack[:dropped] {
+		droppedSnaps[state.snap] = true
+	}
+	u.UndoStack = slices.Delete(u.UndoStack, 0, dropped)
+	for _, states := range [][]UndoRedoState{u.UndoStack, u.RedoStack} {
+		for i := range states {
+			if states[i].snap == nil || !states[i].snap.dependsOn(droppedSnaps) {
+				continue
+			}
+			img, err := states[i].snap.image()
+			if p, ok := pixels(img); err == nil && ok {
+				states[i].snap = wholeSnapshot(p)
+			}
+		}
+	}
+	u.forgetLast()
+	return dropped
+}
+
+func (u *UndoRedoStack) Push(action, filename string, saved bool, img image.Image, currColor [4]float32, selection pixel.Rect) {
+	state := UndoRedoState{
+		Action:    action,
+		filename:  filename,
+		saved:     saved,
+		snap:      u.snapshot(img),
+		Color:     currColor,
+		Selection: selection,
+	}
+	u.UndoStack = append(u.UndoStack, state)
+}
+
+// snapshot stores img as what changed since the newest undo state, or whole
+// if it is time for a checkpoint or it can't be compared with that state.
+func (u *UndoRedoStack) snapshot(img image.Image) *snapshot {
+	if img == nil {
+		return nil
+	}
+	u.last.Lock()
+	defer u.last.Unlock()
+	p, ok := pixels(img)
+	if !ok {
+		u.last.snap, u.last.img = nil, nil
+		return encodedSnapshot(img)
+	}
+	var parent *snapshot
+	if n := len(u.UndoStack); n > 0 {
+		parent = u.UndoStack[n-1].snap
+	}
+	every := u.last.checkpointEvery
+	if every <= 0 {
+		every = DefaultCheckpointEvery
+	}
+	if parent != nil && parent.encoded == nil && parent.depth+1 < every && parent.model == p.ColorModel() && parent.rect == p.Bounds() {
+		if u.last.snap != parent {
+			// An undo went back to an older state since the last push
+			img, err := parent.image()
+			if prev, ok := pixels(img); err == nil && ok {
+				u.last.snap, u.last.img = parent, prev
+			}
+		}
+		if u.last.snap == parent {
+			s := changeSnapshot(parent, u.last.img, p)
+			u.last.snap = s
+			return s
+		}
+	}
+	s := wholeSnapshot(p)
+	u.last.snap, u.last.img = s, clonePixels(p)
+	return s
+}
+
+// forgetLast drops the copy of the last image pushed.
+func (u *UndoRedoStack) forgetLast() {
+	u.last.Lock()
+	defer u.last.Unlock()
+	u.last.snap, u.last.img = nil, nil
+}
+
+// DelayedPush pushes the state pointed to after d, unless DelayedPush is
+// called again first, so a run of quick edits becomes one undo step.
 func (u *UndoRedoStack) DelayedPush(d time.Duration, action string, filename *string, saved *bool, img *image.Image, currColor *[4]float32, selection *pixel.Rect) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.timer != nil {
+		u.timer.Stop()
+	}
+	u.pending = action
+	u.pendingPush = func() { u.Push(action, *filename, *saved, *img, *currColor, *selection) }
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		// A later DelayedPush or Flush may have replaced this one
+		if u.timer == timer {
+			u.pushPending()
+		}
+	})
+	u.timer = timer
+}
+
+// pushPending pushes the delayed state. u.mu must be held.
+func (u *UndoRedoStack) pushPending() {
+	push := u.pendingPush
+	u.timer, u.pending, u.pendingPush = nil, "", nil
+	push()
+}
+
+// Pending returns the action of a delayed push that hasn't happened yet.
+func (u *UndoRedoStack) Pending() (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.pending, u.timer != nil
+}
+
+// Flush makes a delayed push happen now, ending the run of edits it merges.
+func (u *UndoRedoStack) Flush() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
 	if u.timer != nil {
 		u.timer.Stop()
+		u.pushPending()
 	}
-	u.timer = time.AfterFunc(d, func() { u.Push(action, *filename, *saved, *img, *currColor, *selection) })
 }
 
 func (u *UndoRedoStack) Undo(index int) (*UndoRedoState, error) {
@@ -0,0 +1,221 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/ryanjsims/hd2-lut-editor/dds"
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+	"github.com/ryanjsims/hd2-lut-editor/openexr"
+)
+
+// An undo state keeps its image as a snapshot: either the whole image, or the
+// squares of texels that changed since the snapshot of the state pushed
+// before it. Getting the image back starts from the nearest whole snapshot
+// and copies in the changes after it, so a whole snapshot is taken every so
+// often to keep that quick.
+
+// DefaultCheckpointEvery is how often a whole snapshot is taken, in states,
+// unless the stack is told otherwise.
+const DefaultCheckpointEvery = 16
+
+// snapshotTile is the width and height of the squares of texels compared and
+// stored by snapshots of changes.
+const snapshotTile = 16
+
+// pixelImage is an image whose pixels can be copied as bytes.
+type pixelImage interface {
+	image.Image
+	hdrColors.HDRImage
+}
+
+type snapshot struct {
+	// parent is the snapshot the tiles change, or nil if they cover the
+	// whole image
+	parent *snapshot
+	// encoded holds the image as an EXR instead, for images whose pixels
+	// can't be copied as bytes
+	encoded []byte
+	model   color.Model
+	rect    image.Rectangle
+	// tiles are the parts of the image stored, and pix their pixels, row by
+	// row and tile by tile, deflated
+	tiles []image.Rectangle
+	pix   []byte
+	// depth is the number of snapshots from the nearest whole one
+	depth int
+}
+
+// bytes returns the memory the snapshot holds, not counting its parents.
+func (s *snapshot) bytes() int {
+	return len(s.encoded) + len(s.pix)
+}
+
+// pixels returns the image holding the pixels of img, looking inside DDS
+// images, if they can be copied as bytes.
+func pixels(img image.Image) (pixelImage, bool) {
+	if ddsImg, ok := img.(*dds.DDS); ok {
+		img = ddsImg.Image
+	}
+	p, ok := img.(pixelImage)
+	if !ok || bytesPerPixel(p.ColorModel()) == 0 {
+		return nil, false
+	}
+	return p, true
+}
+
+func bytesPerPixel(model color.Model) int {
+	switch model {
+	case hdrColors.NRGBA128FModel, hdrColors.NRGBA128UModel:
+		return 16
+	case hdrColors.NRGBA64FModel:
+		return 8
+	}
+	return 0
+}
+
+// newPixelImage returns a blank image with the pixel type of model.
+func newPixelImage(model color.Model, rect image.Rectangle) (pixelImage, error) {
+	for _, format := range hdrColors.Formats {
+		if format.Model == model {
+			if p, ok := format.New(rect).(pixelImage); ok {
+				return p, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("undo: unsupported pixel type")
+}
+
+// row returns the bytes of the pixels of p from x0 to x1 in row y.
+func row(p pixelImage, y, x0, x1 int) []byte {
+	bpp := bytesPerPixel(p.ColorModel())
+	origin := p.Bounds().Min
+	offset := (y-origin.Y)*p.GetStride() + (x0-origin.X)*bpp
+	return p.Pixels()[offset : offset+(x1-x0)*bpp]
+}
+
+// clonePixels returns a copy of p that doesn't share its pixels.
+func clonePixels(p pixelImage) pixelImage {
+	clone, err := newPixelImage(p.ColorModel(), p.Bounds())
+	if err != nil {
+		return nil
+	}
+	r := p.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(row(clone, y, r.Min.X, r.Max.X), row(p, y, r.Min.X, r.Max.X))
+	}
+	return clone
+}
+
+// encodedSnapshot stores img whole as an EXR.
+func encodedSnapshot(img image.Image) *snapshot {
+	buf := &bytes.Buffer{}
+	openexr.WriteHDR(buf, img)
+	return &snapshot{encoded: buf.Bytes(), rect: img.Bounds()}
+}
+
+// wholeSnapshot stores all of p.
+func wholeSnapshot(p pixelImage) *snapshot {
+	s := &snapshot{model: p.ColorModel(), rect: p.Bounds(), tiles: []image.Rectangle{p.Bounds()}}
+	s.pix = deflateTiles(p, s.tiles)
+	return s
+}
+
+// changeSnapshot stores the tiles of p that differ from prev, which is the
+// image of parent, and copies them into prev so that it becomes the image of
+// the new snapshot.
+func changeSnapshot(parent *snapshot, prev, p pixelImage) *snapshot {
+	s := &snapshot{parent: parent, model: p.ColorModel(), rect: p.Bounds(), depth: parent.depth + 1}
+	r := p.Bounds()
+	for ty := r.Min.Y; ty < r.Max.Y; ty += snapshotTile {
+		for tx := r.Min.X; tx < r.Max.X; tx += snapshotTile {
+			tile := image.Rect(tx, ty, tx+snapshotTile, ty+snapshotTile).Intersect(r)
+			for y := tile.Min.Y; y < tile.Max.Y; y++ {
+				if !bytes.Equal(row(prev, y, tile.Min.X, tile.Max.X), row(p, y, tile.Min.X, tile.Max.X)) {
+					s.tiles = append(s.tiles, tile)
+					break
+				}
+			}
+		}
+	}
+	s.pix = deflateTiles(p, s.tiles)
+	for _, tile := range s.tiles {
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			copy(row(prev, y, tile.Min.X, tile.Max.X), row(p, y, tile.Min.X, tile.Max.X))
+		}
+	}
+	return s
+}
+
+func deflateTiles(p pixelImage, tiles []image.Rectangle) []byte {
+	if len(tiles) == 0 {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	w, _ := flate.NewWriter(buf, flate.BestSpeed)
+	for _, tile := range tiles {
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			w.Write(row(p, y, tile.Min.X, tile.Max.X))
+		}
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// image rebuilds the image of the snapshot.
+func (s *snapshot) image() (image.Image, error) {
+	if s.encoded != nil {
+		exr, err := openexr.LoadOpenEXR(*bufio.NewReader(bytes.NewBuffer(s.encoded)))
+		if err != nil {
+			return nil, err
+		}
+		return exr.HdrImage()
+	}
+	var p pixelImage
+	if s.parent == nil {
+		blank, err := newPixelImage(s.model, s.rect)
+		if err != nil {
+			return nil, err
+		}
+		p = blank
+	} else {
+		img, err := s.parent.image()
+		if err != nil {
+			return nil, err
+		}
+		parent, ok := pixels(img)
+		if !ok || parent.ColorModel() != s.model || parent.Bounds() != s.rect {
+			return nil, fmt.Errorf("undo: state does not match the state before it")
+		}
+		p = parent
+	}
+	if len(s.tiles) == 0 {
+		return p, nil
+	}
+	r := flate.NewReader(bytes.NewReader(s.pix))
+	defer r.Close()
+	for _, tile := range s.tiles {
+		for y := tile.Min.Y; y < tile.Max.Y; y++ {
+			if _, err := io.ReadFull(r, row(p, y, tile.Min.X, tile.Max.X)); err != nil {
+				return nil, fmt.Errorf("undo: %v", err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// dependsOn reports whether any snapshot s is made from, or s itself, is in
+// snapshots.
+func (s *snapshot) dependsOn(snapshots map[*snapshot]bool) bool {
+	for ; s != nil; s = s.parent {
+		if snapshots[s] {
+			return true
+		}
+	}
+	return false
+}
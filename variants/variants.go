@@ -0,0 +1,277 @@
+// Package variants makes variants of a LUT from a CSV file of per-row
+// overrides, such as the color variants of an armor set.
+//
+// The CSV file starts with a header naming its columns, in any order:
+//
+//	variant  name of the variant the override belongs to (required)
+//	op       set, add, multiply or hue (required)
+//	rows     the rows changed: an index, a range such as 2-5, or * for all
+//	columns  the columns changed, in the same form
+//	r,g,b,a  the values to set, add or multiply by; blank leaves the channel
+//	value    the hue shift in degrees, for hue
+//
+// rows and columns default to *. Each variant starts from the original LUT,
+// and its overrides are applied in the order they appear.
+package variants
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ryanjsims/hd2-lut-editor/hdrColors"
+)
+
+// Op is how an override changes texels.
+type Op string
+
+const (
+	OpSet      Op = "set"
+	OpAdd      Op = "add"
+	OpMultiply Op = "multiply"
+	// OpHue rotates the hue of the color channels, keeping their saturation
+	// and value
+	OpHue Op = "hue"
+)
+
+// Span is an inclusive range of rows or columns. A Last of -1 runs to the end
+// of the image.
+type Span struct {
+	First, Last int
+}
+
+// All spans every row or column.
+var All = Span{First: 0, Last: -1}
+
+// ParseSpan reads an index, a range such as 2-5, or * for all.
+func ParseSpan(s string) (Span, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return All, nil
+	}
+	first, last, isRange := strings.Cut(s, "-")
+	a, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil || a < 0 {
+		return Span{}, fmt.Errorf("invalid index %q", s)
+	}
+	if !isRange {
+		return Span{First: a, Last: a}, nil
+	}
+	b, err := strconv.Atoi(strings.TrimSpace(last))
+	if err != nil || b < a {
+		return Span{}, fmt.Errorf("invalid range %q", s)
+	}
+	return Span{First: a, Last: b}, nil
+}
+
+// Contains reports whether i is in the span.
+func (s Span) Contains(i int) bool {
+	return i >= s.First && (s.Last < 0 || i <= s.Last)
+}
+
+// Override is one line of the CSV file.
+type Override struct {
+	Rows, Columns Span
+	Op            Op
+	// Values are the values set, added or multiplied by, per channel, or
+	// nil to leave the channel alone
+	Values [4]*float64
+	// Degrees is the hue shift, for OpHue
+	Degrees float64
+	// Line is the line of the CSV file the override was read from
+	Line int
+}
+
+// Variant is a named set of overrides.
+type Variant struct {
+	Name      string
+	Overrides []Override
+}
+
+// Parse reads the variants in a CSV file, in the order they first appear.
+func Parse(r io.Reader) ([]Variant, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("empty file")
+	} else if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"variant", "op"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("no %q column", required)
+		}
+	}
+
+	var variants []Variant
+	byName := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		override, err := parseOverride(field)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		override.Line = line
+		name := field("variant")
+		if name == "" {
+			return nil, fmt.Errorf("line %d: no variant name", line)
+		}
+		i, ok := byName[name]
+		if !ok {
+			i = len(variants)
+			byName[name] = i
+			variants = append(variants, Variant{Name: name})
+		}
+		variants[i].Overrides = append(variants[i].Overrides, override)
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no overrides")
+	}
+	return variants, nil
+}
+
+func parseOverride(field func(name string) string) (Override, error) {
+	var o Override
+	var err error
+	if o.Rows, err = ParseSpan(field("rows")); err != nil {
+		return o, fmt.Errorf("rows: %v", err)
+	}
+	if o.Columns, err = ParseSpan(field("columns")); err != nil {
+		return o, fmt.Errorf("columns: %v", err)
+	}
+	o.Op = Op(strings.ToLower(field("op")))
+	switch o.Op {
+	case OpSet, OpAdd, OpMultiply:
+		set := false
+		for i, name := range []string{"r", "g", "b", "a"} {
+			text := field(name)
+			if text == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return o, fmt.Errorf("%s: invalid number %q", name, text)
+			}
+			o.Values[i], set = &v, true
+		}
+		if !set {
+			return o, fmt.Errorf("%s changes no channels", o.Op)
+		}
+	case OpHue:
+		text := field("value")
+		if o.Degrees, err = strconv.ParseFloat(text, 64); err != nil {
+			return o, fmt.Errorf("value: invalid number %q", text)
+		}
+	default:
+		return o, fmt.Errorf("unknown op %q", field("op"))
+	}
+	return o, nil
+}
+
+// Apply makes the variant's changes to img, and returns the number of texels
+// changed.
+func (v *Variant) Apply(img hdrColors.RawImage) int {
+	bounds := img.Bounds()
+	changed := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			before := img.RawAt(x, y)
+			value := before
+			for i := range v.Overrides {
+				o := &v.Overrides[i]
+				if o.Rows.Contains(y-bounds.Min.Y) && o.Columns.Contains(x-bounds.Min.X) {
+					value = o.apply(value)
+				}
+			}
+			if value != before {
+				img.SetRaw(x, y, value)
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+func (o *Override) apply(value [4]float64) [4]float64 {
+	if o.Op == OpHue {
+		return RotateHue(value, o.Degrees)
+	}
+	for i, v := range o.Values {
+		if v == nil {
+			continue
+		}
+		switch o.Op {
+		case OpSet:
+			value[i] = *v
+		case OpAdd:
+			value[i] += *v
+		case OpMultiply:
+			value[i] *= *v
+		}
+	}
+	return value
+}
+
+// RotateHue turns the hue of the color in c by degrees, keeping its
+// saturation, value and alpha. Values above 1 are kept, so HDR colors keep
+// their brightness.
+func RotateHue(c [4]float64, degrees float64) [4]float64 {
+	r, g, b := c[0], c[1], c[2]
+	hi, lo := max(r, g, b), min(r, g, b)
+	chroma := hi - lo
+	if chroma <= 0 {
+		return c
+	}
+	var hue float64
+	switch hi {
+	case r:
+		hue = math.Mod((g-b)/chroma, 6)
+	case g:
+		hue = (b-r)/chroma + 2
+	default:
+		hue = (r-g)/chroma + 4
+	}
+	hue = math.Mod(hue+degrees/60, 6)
+	if hue < 0 {
+		hue += 6
+	}
+	x := chroma * (1 - math.Abs(math.Mod(hue, 2)-1))
+	switch int(hue) {
+	case 0:
+		r, g, b = chroma, x, 0
+	case 1:
+		r, g, b = x, chroma, 0
+	case 2:
+		r, g, b = 0, chroma, x
+	case 3:
+		r, g, b = 0, x, chroma
+	case 4:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	return [4]float64{r + lo, g + lo, b + lo, c[3]}
+}